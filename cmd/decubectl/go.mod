@@ -3,6 +3,7 @@ module github.com/decube/decubectl
 go 1.19
 
 require (
+	github.com/decube/client v0.0.0
 	github.com/spf13/cobra v1.7.0
 	github.com/spf13/viper v1.16.0
 	gopkg.in/yaml.v2 v2.4.0
@@ -23,3 +24,5 @@ require (
 	golang.org/x/sys v0.8.0 // indirect
 	golang.org/x/text v0.9.0 // indirect
 )
+
+replace github.com/decube/client => ../../pkg/client
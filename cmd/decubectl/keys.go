@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	keyFile string
+	txID    string
+)
+
+// defaultKeyPath returns the signing key location, $HOME/.decube/key unless --key is set
+func defaultKeyPath() (string, error) {
+	if keyFile != "" {
+		return keyFile, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".decube", "key"), nil
+}
+
+// generateKey creates a new Ed25519 key and writes it as a PKCS#8 PEM file with 0600 perms
+func generateKey(path string) (ed25519.PrivateKey, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create key directory: %w", err)
+	}
+
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, pemData, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write key: %w", err)
+	}
+
+	return priv, nil
+}
+
+// loadKey reads an Ed25519 private key from a PKCS#8 PEM file
+func loadKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%s does not contain a PEM private key", path)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key: %w", err)
+	}
+
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an Ed25519 key", path)
+	}
+
+	return priv, nil
+}
+
+// loadOrCreateKey loads the signing key, generating one on first use
+func loadOrCreateKey(path string) (ed25519.PrivateKey, error) {
+	priv, err := loadKey(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return generateKey(path)
+	}
+	return priv, err
+}
+
+// canonicalTxBytes returns the bytes covered by a transaction signature.
+// encoding/json sorts map keys, so the payload serializes deterministically.
+func canonicalTxBytes(tx Transaction) ([]byte, error) {
+	return json.Marshal(struct {
+		ID       string                 `json:"id"`
+		Type     string                 `json:"type"`
+		Payload  map[string]interface{} `json:"payload"`
+		Priority int                    `json:"priority"`
+	}{tx.ID, tx.Type, tx.Payload, tx.Priority})
+}
+
+// newTxID returns a random transaction ID. The GCL accepts each ID once per
+// signing key, which is what stops a signed transaction being replayed.
+func newTxID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// signTransaction signs the canonical transaction bytes and attaches the signature and public key
+func signTransaction(tx *Transaction, priv ed25519.PrivateKey) error {
+	data, err := canonicalTxBytes(*tx)
+	if err != nil {
+		return err
+	}
+
+	tx.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(priv, data))
+	tx.PublicKey = base64.StdEncoding.EncodeToString(priv.Public().(ed25519.PublicKey))
+	return nil
+}
+
+// verifyTransaction checks a transaction signature against its embedded public key
+func verifyTransaction(tx Transaction) error {
+	pub, err := base64.StdEncoding.DecodeString(tx.PublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(tx.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding")
+	}
+
+	data, err := canonicalTxBytes(tx)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(ed25519.PublicKey(pub), data, sig) {
+		return fmt.Errorf("signature verification failed")
+	}
+	return nil
+}
+
+func keysGenerate(cmd *cobra.Command, args []string) {
+	path, err := defaultKeyPath()
+	if err != nil {
+		log.Fatalf("Failed to resolve key path: %v", err)
+	}
+
+	force, _ := cmd.Flags().GetBool("force")
+	if _, err := os.Stat(path); err == nil && !force {
+		log.Fatalf("Key already exists at %s (use --force to overwrite)", path)
+	}
+
+	priv, err := generateKey(path)
+	if err != nil {
+		log.Fatalf("Failed to generate key: %v", err)
+	}
+
+	fmt.Printf("Generated Ed25519 key at %s\n", path)
+	fmt.Printf("Public key: %s\n", base64.StdEncoding.EncodeToString(priv.Public().(ed25519.PublicKey)))
+}
+
+func keysShow(cmd *cobra.Command, args []string) {
+	path, err := defaultKeyPath()
+	if err != nil {
+		log.Fatalf("Failed to resolve key path: %v", err)
+	}
+
+	priv, err := loadKey(path)
+	if err != nil {
+		log.Fatalf("Failed to load key: %v", err)
+	}
+
+	fmt.Printf("Key file: %s\n", path)
+	fmt.Printf("Algorithm: ed25519\n")
+	fmt.Printf("Public key: %s\n", base64.StdEncoding.EncodeToString(priv.Public().(ed25519.PublicKey)))
+}
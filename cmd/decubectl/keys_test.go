@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSignAndVerifyTransaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+
+	priv, err := loadOrCreateKey(path)
+	if err != nil {
+		t.Fatalf("failed to create key: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("key file not written: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected key perms 0600, got %o", info.Mode().Perm())
+	}
+
+	// A second load must return the persisted key rather than a new one
+	reloaded, err := loadOrCreateKey(path)
+	if err != nil {
+		t.Fatalf("failed to reload key: %v", err)
+	}
+	if !priv.Equal(reloaded) {
+		t.Fatal("reloaded key differs from generated key")
+	}
+
+	tx := Transaction{
+		Type:    "snapshot",
+		Payload: map[string]interface{}{"id": "snap-1", "size": 42.0},
+	}
+	if err := signTransaction(&tx, reloaded); err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	if err := verifyTransaction(tx); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+
+	raised := tx
+	raised.Priority = 10
+	if err := verifyTransaction(raised); err == nil {
+		t.Fatal("transaction with a raised priority verified")
+	}
+
+	tx.Payload["size"] = 43.0
+	if err := verifyTransaction(tx); err == nil {
+		t.Fatal("tampered transaction verified")
+	}
+}
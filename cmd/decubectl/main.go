@@ -1,21 +1,18 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
-	"net/http"
 	"os"
 	"path/filepath"
-	"strconv"
-	"strings"
+	"sort"
 	"time"
 
+	"github.com/decube/client"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v2"
@@ -29,6 +26,7 @@ type Config struct {
 	StorageURL      string `yaml:"storage_url" mapstructure:"storage_url"`
 	ClusterID       string `yaml:"cluster_id" mapstructure:"cluster_id"`
 	Timeout         int    `yaml:"timeout" mapstructure:"timeout"`
+	Retries         int    `yaml:"retries" mapstructure:"retries"`
 }
 
 type SnapshotMetadata struct {
@@ -40,33 +38,40 @@ type SnapshotMetadata struct {
 	Metadata map[string]interface{} `json:"metadata"`
 }
 
-type Transaction struct {
-	ID        string                 `json:"id"`
-	Type      string                 `json:"type"`
-	Payload   map[string]interface{} `json:"payload"`
-	Signature string                 `json:"signature"`
-}
-
-type CommitProof struct {
-	TxHash     string   `json:"tx_hash"`
-	BlockHash  string   `json:"block_hash"`
-	Height     int64    `json:"height"`
-	Signatures []string `json:"signatures"`
-}
+// Transaction and CommitProof are the GCL types of the service client
+type (
+	Transaction = client.Transaction
+	CommitProof = client.CommitProof
+)
 
 var config Config
 var cfgFile string
+var outputFormat string
 
 func main() {
 	cobra.OnInitialize(initConfig)
 
+	if err := newRootCmd().Execute(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// newRootCmd builds the decubectl command tree
+func newRootCmd() *cobra.Command {
 	rootCmd := &cobra.Command{
 		Use:   "decubectl",
 		Short: "DeCube CLI tool",
 		Long:  `A command-line tool for managing DeCube clusters`,
 	}
+	// Replaced by newCompletionCmd, which only offers the shells we document
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.decube/config.yaml)")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "table", "output format: table, json, or yaml")
+	rootCmd.PersistentFlags().StringVar(&keyFile, "key", "", "signing key file (default is $HOME/.decube/key)")
+	rootCmd.PersistentFlags().Int("retries", 3, "number of retries for idempotent requests")
+	viper.BindPFlag("retries", rootCmd.PersistentFlags().Lookup("retries"))
 
 	// Snapshot commands
 	snapshotCmd := &cobra.Command{
@@ -102,6 +107,7 @@ func main() {
 		Args:  cobra.ExactArgs(2),
 		Run:   gclTxPublish,
 	}
+	gclTxPublishCmd.Flags().StringVar(&txID, "id", "", "Transaction ID, unique per signing key (default: random)")
 	gclTxProofCmd := &cobra.Command{
 		Use:   "proof <tx-hash>",
 		Short: "Get transaction proof",
@@ -136,6 +142,24 @@ func main() {
 	}
 	gossipCmd.AddCommand(gossipSyncCmd)
 
+	// Key commands
+	keysCmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Manage transaction signing keys",
+	}
+	keysGenerateCmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate a new signing key",
+		Run:   keysGenerate,
+	}
+	keysGenerateCmd.Flags().Bool("force", false, "overwrite an existing key")
+	keysShowCmd := &cobra.Command{
+		Use:   "show",
+		Short: "Show the signing public key",
+		Run:   keysShow,
+	}
+	keysCmd.AddCommand(keysGenerateCmd, keysShowCmd)
+
 	// Status command
 	statusCmd := &cobra.Command{
 		Use:   "status",
@@ -143,12 +167,9 @@ func main() {
 		Run:   showStatus,
 	}
 
-	rootCmd.AddCommand(snapshotCmd, gclCmd, crdtCmd, gossipCmd, statusCmd)
+	rootCmd.AddCommand(snapshotCmd, gclCmd, crdtCmd, gossipCmd, keysCmd, statusCmd, newVersionCmd(), newCompletionCmd())
 
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
-	}
+	return rootCmd
 }
 
 func initConfig() {
@@ -166,7 +187,8 @@ func initConfig() {
 	viper.AutomaticEnv()
 
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		// Keep stdout clean for machine-readable output
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 
 	err := viper.Unmarshal(&config)
@@ -180,21 +202,21 @@ func initConfig() {
 	}
 }
 
-func httpClient() *http.Client {
-	return &http.Client{
-		Timeout: time.Duration(config.Timeout) * time.Second,
-	}
-}
-
-func makeRequest(method, url string, body io.Reader) (*http.Response, error) {
-	req, err := http.NewRequest(method, url, body)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	client := httpClient()
-	return client.Do(req)
+// retryBaseDelay is the backoff before the first retry; it doubles on each attempt
+var retryBaseDelay = client.DefaultRetryBaseDelay
+
+// newClient creates a service client from the loaded config
+func newClient() *client.Client {
+	return client.New(client.Config{
+		ControlPlaneURL: config.ControlPlaneURL,
+		GCLURL:          config.GCLURL,
+		CatalogURL:      config.CatalogURL,
+		GossipURL:       config.GossipURL,
+		StorageURL:      config.StorageURL,
+		Timeout:         time.Duration(config.Timeout) * time.Second,
+		Retries:         config.Retries,
+		RetryBaseDelay:  retryBaseDelay,
+	})
 }
 
 func snapshotCreate(cmd *cobra.Command, args []string) {
@@ -205,28 +227,16 @@ func snapshotCreate(cmd *cobra.Command, args []string) {
 	fmt.Printf("Creating snapshot %s from %s and %s...\n", id, etcdDir, volumeDir)
 
 	// Call control plane to create snapshot
-	payload := map[string]interface{}{
-		"id":        id,
-		"etcd_dir":  etcdDir,
-		"volume_dir": volumeDir,
-	}
-
-	jsonData, _ := json.Marshal(payload)
-	resp, err := makeRequest("POST", config.ControlPlaneURL+"/api/v1/snapshots", bytes.NewBuffer(jsonData))
+	snapshot, err := newClient().CreateSnapshot(context.Background(), client.CreateSnapshotRequest{
+		ID:        id,
+		EtcdDir:   etcdDir,
+		VolumeDir: volumeDir,
+	})
 	if err != nil {
 		log.Fatalf("Failed to create snapshot: %v", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		log.Fatalf("Snapshot creation failed: %s", string(body))
-	}
-
-	var result map[string]interface{}
-	json.NewDecoder(resp.Body).Decode(&result)
-
-	fmt.Printf("Snapshot created successfully: %v\n", result)
+	fmt.Printf("Snapshot created successfully: %+v\n", *snapshot)
 }
 
 func snapshotRestore(cmd *cobra.Command, args []string) {
@@ -236,25 +246,10 @@ func snapshotRestore(cmd *cobra.Command, args []string) {
 	fmt.Printf("Restoring snapshot %s to %s...\n", id, restoreDir)
 
 	// Call control plane to restore snapshot
-	payload := map[string]interface{}{
-		"id":          id,
-		"restore_dir": restoreDir,
-	}
-
-	jsonData, _ := json.Marshal(payload)
-	resp, err := makeRequest("POST", config.ControlPlaneURL+"/api/v1/snapshots/restore", bytes.NewBuffer(jsonData))
+	result, err := newClient().RestoreSnapshot(context.Background(), id, restoreDir)
 	if err != nil {
 		log.Fatalf("Failed to restore snapshot: %v", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		log.Fatalf("Snapshot restore failed: %s", string(body))
-	}
-
-	var result map[string]interface{}
-	json.NewDecoder(resp.Body).Decode(&result)
 
 	fmt.Printf("Snapshot restored successfully: %v\n", result)
 }
@@ -270,27 +265,35 @@ func gclTxPublish(cmd *cobra.Command, args []string) {
 
 	fmt.Printf("Publishing %s transaction...\n", txType)
 
+	keyPath, err := defaultKeyPath()
+	if err != nil {
+		log.Fatalf("Failed to resolve key path: %v", err)
+	}
+	priv, err := loadOrCreateKey(keyPath)
+	if err != nil {
+		log.Fatalf("Failed to load signing key: %v", err)
+	}
+
+	id := txID
+	if id == "" {
+		if id, err = newTxID(); err != nil {
+			log.Fatalf("Failed to generate transaction ID: %v", err)
+		}
+	}
+
 	tx := Transaction{
+		ID:      id,
 		Type:    txType,
 		Payload: payload,
-		// In real implementation, sign the transaction
-		Signature: "dummy-signature",
+	}
+	if err := signTransaction(&tx, priv); err != nil {
+		log.Fatalf("Failed to sign transaction: %v", err)
 	}
 
-	jsonData, _ := json.Marshal(tx)
-	resp, err := makeRequest("POST", config.GCLURL+"/api/v1/transactions", bytes.NewBuffer(jsonData))
+	result, err := newClient().SubmitTx(context.Background(), &tx)
 	if err != nil {
 		log.Fatalf("Failed to publish transaction: %v", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		log.Fatalf("Transaction publish failed: %s", string(body))
-	}
-
-	var result map[string]interface{}
-	json.NewDecoder(resp.Body).Decode(&result)
 
 	fmt.Printf("Transaction published: %v\n", result)
 }
@@ -300,19 +303,10 @@ func gclTxProof(cmd *cobra.Command, args []string) {
 
 	fmt.Printf("Getting proof for transaction %s...\n", txHash)
 
-	resp, err := makeRequest("GET", config.GCLURL+"/api/v1/transactions/"+txHash+"/proof", nil)
+	proof, err := newClient().GetTxProof(context.Background(), txHash)
 	if err != nil {
 		log.Fatalf("Failed to get proof: %v", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		log.Fatalf("Proof retrieval failed: %s", string(body))
-	}
-
-	var proof CommitProof
-	json.NewDecoder(resp.Body).Decode(&proof)
 
 	fmt.Printf("Transaction Proof:\n")
 	fmt.Printf("  Tx Hash: %s\n", proof.TxHash)
@@ -328,26 +322,14 @@ func crdtMerge(cmd *cobra.Command, args []string) {
 
 	fmt.Printf("Merging %s CRDT: %s = %s\n", crdtType, key, value)
 
-	payload := map[string]interface{}{
-		"type":  crdtType,
-		"key":   key,
-		"value": value,
-	}
-
-	jsonData, _ := json.Marshal(payload)
-	resp, err := makeRequest("POST", config.CatalogURL+"/api/v1/crdt/merge", bytes.NewBuffer(jsonData))
+	result, err := newClient().MergeCRDT(context.Background(), client.MergeRequest{
+		Type:  crdtType,
+		Key:   key,
+		Value: value,
+	})
 	if err != nil {
 		log.Fatalf("Failed to merge CRDT: %v", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		log.Fatalf("CRDT merge failed: %s", string(body))
-	}
-
-	var result map[string]interface{}
-	json.NewDecoder(resp.Body).Decode(&result)
 
 	fmt.Printf("CRDT merged successfully: %v\n", result)
 }
@@ -355,94 +337,96 @@ func crdtMerge(cmd *cobra.Command, args []string) {
 func gossipSync(cmd *cobra.Command, args []string) {
 	fmt.Println("Triggering gossip synchronization...")
 
-	resp, err := makeRequest("POST", config.GossipURL+"/api/v1/sync", nil)
+	result, err := newClient().SyncGossip(context.Background())
 	if err != nil {
 		log.Fatalf("Failed to trigger sync: %v", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		log.Fatalf("Gossip sync failed: %s", string(body))
-	}
-
-	var result map[string]interface{}
-	json.NewDecoder(resp.Body).Decode(&result)
+	fmt.Printf("Gossip sync completed: %+v\n", *result)
+}
 
-	fmt.Printf("Gossip sync completed: %v\n", result)
+// ServiceStatus is the status of a single DeCube service
+type ServiceStatus struct {
+	Reachable bool                   `json:"reachable" yaml:"reachable"`
+	Status    map[string]interface{} `json:"status,omitempty" yaml:"status,omitempty"`
+	Error     string                 `json:"error,omitempty" yaml:"error,omitempty"`
 }
 
-func showStatus(cmd *cobra.Command, args []string) {
-	fmt.Println("DeCube Cluster Status")
-	fmt.Println("====================")
-
-	// Get control plane status
-	fmt.Println("\nControl Plane:")
-	resp, err := makeRequest("GET", config.ControlPlaneURL+"/api/v1/status", nil)
-	if err == nil && resp.StatusCode == http.StatusOK {
-		var status map[string]interface{}
-		json.NewDecoder(resp.Body).Decode(&status)
-		for k, v := range status {
-			fmt.Printf("  %s: %v\n", k, v)
-		}
-		resp.Body.Close()
-	} else {
-		fmt.Println("  Status: Unavailable")
+// ClusterStatus aggregates the status of every service, keyed by service name
+type ClusterStatus map[string]ServiceStatus
+
+// fetchServiceStatus queries a service's status endpoint
+func fetchServiceStatus(c *client.Client, svc client.Service) ServiceStatus {
+	status, err := c.Status(context.Background(), svc)
+	if errors.Is(err, client.ErrInvalidResponse) {
+		return ServiceStatus{Reachable: true, Error: err.Error()}
+	}
+	if err != nil {
+		return ServiceStatus{Reachable: false, Error: err.Error()}
 	}
 
-	// Get GCL status
-	fmt.Println("\nGlobal Consensus Layer:")
-	resp, err = makeRequest("GET", config.GCLURL+"/api/v1/status", nil)
-	if err == nil && resp.StatusCode == http.StatusOK {
-		var status map[string]interface{}
-		json.NewDecoder(resp.Body).Decode(&status)
-		for k, v := range status {
-			fmt.Printf("  %s: %v\n", k, v)
-		}
-		resp.Body.Close()
-	} else {
-		fmt.Println("  Status: Unavailable")
+	return ServiceStatus{Reachable: true, Status: status}
+}
+
+// collectStatus gathers the status of all configured services
+func collectStatus() ClusterStatus {
+	c := newClient()
+	status := make(ClusterStatus, len(client.Services))
+	for _, svc := range client.Services {
+		status[string(svc)] = fetchServiceStatus(c, svc)
 	}
+	return status
+}
 
-	// Get catalog status
-	fmt.Println("\nCatalog Service:")
-	resp, err = makeRequest("GET", config.CatalogURL+"/api/v1/status", nil)
-	if err == nil && resp.StatusCode == http.StatusOK {
-		var status map[string]interface{}
-		json.NewDecoder(resp.Body).Decode(&status)
-		for k, v := range status {
-			fmt.Printf("  %s: %v\n", k, v)
-		}
-		resp.Body.Close()
-	} else {
-		fmt.Println("  Status: Unavailable")
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
+	return keys
+}
 
-	// Get gossip status
-	fmt.Println("\nGossip Service:")
-	resp, err = makeRequest("GET", config.GossipURL+"/api/v1/status", nil)
-	if err == nil && resp.StatusCode == http.StatusOK {
-		var status map[string]interface{}
-		json.NewDecoder(resp.Body).Decode(&status)
-		for k, v := range status {
-			fmt.Printf("  %s: %v\n", k, v)
+// renderStatus writes the cluster status in the requested output format
+func renderStatus(w io.Writer, status ClusterStatus, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(status)
+	case "yaml":
+		data, err := yaml.Marshal(status)
+		if err != nil {
+			return err
 		}
-		resp.Body.Close()
-	} else {
-		fmt.Println("  Status: Unavailable")
+		_, err = w.Write(data)
+		return err
+	case "table", "":
+		fmt.Fprintln(w, "DeCube Cluster Status")
+		fmt.Fprintln(w, "====================")
+		for _, name := range sortedKeys(status) {
+			svc := status[name]
+			fmt.Fprintf(w, "\n%s:\n", name)
+			if !svc.Reachable {
+				fmt.Fprintf(w, "  reachable: false\n")
+				if svc.Error != "" {
+					fmt.Fprintf(w, "  error: %s\n", svc.Error)
+				}
+				continue
+			}
+			for _, k := range sortedKeys(svc.Status) {
+				fmt.Fprintf(w, "  %s: %v\n", k, svc.Status[k])
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q (expected table, json, or yaml)", format)
 	}
+}
 
-	// Get storage status
-	fmt.Println("\nStorage Service:")
-	resp, err = makeRequest("GET", config.StorageURL+"/api/v1/status", nil)
-	if err == nil && resp.StatusCode == http.StatusOK {
-		var status map[string]interface{}
-		json.NewDecoder(resp.Body).Decode(&status)
-		for k, v := range status {
-			fmt.Printf("  %s: %v\n", k, v)
-		}
-		resp.Body.Close()
-	} else {
-		fmt.Println("  Status: Unavailable")
+func showStatus(cmd *cobra.Command, args []string) {
+	if err := renderStatus(cmd.OutOrStdout(), collectStatus(), outputFormat); err != nil {
+		log.Fatalf("Failed to render status: %v", err)
 	}
 }
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/decube/client"
+	"github.com/spf13/cobra"
+)
+
+func TestStatusJSONOutput(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"healthy": true, "peers": 3})
+	}))
+	defer srv.Close()
+
+	config = Config{
+		ControlPlaneURL: "http://127.0.0.1:1", // nothing listens here
+		GCLURL:          srv.URL,
+		CatalogURL:      srv.URL,
+		GossipURL:       srv.URL,
+		StorageURL:      srv.URL,
+		Timeout:         2,
+	}
+	outputFormat = "json"
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{Run: showStatus}
+	cmd.SetOut(&out)
+	cmd.Run(cmd, nil)
+
+	var status ClusterStatus
+	if err := json.Unmarshal(out.Bytes(), &status); err != nil {
+		t.Fatalf("status output is not valid JSON: %v\n%s", err, out.String())
+	}
+
+	if status["control_plane"].Reachable {
+		t.Error("expected control_plane to be unreachable")
+	}
+	if !status["gcl"].Reachable {
+		t.Error("expected gcl to be reachable")
+	}
+	if status["gcl"].Status["peers"] != 3.0 {
+		t.Errorf("expected gcl peers 3, got %v", status["gcl"].Status["peers"])
+	}
+}
+
+func TestIdempotentRequestsRetryOn5xx(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(CommitProof{TxHash: "abc", Height: 7})
+	}))
+	defer srv.Close()
+
+	config = Config{GCLURL: srv.URL, Timeout: 5, Retries: 3}
+	retryBaseDelay = time.Millisecond
+
+	// gclTxProof exits via log.Fatalf if the request ultimately fails
+	cmd := &cobra.Command{Run: gclTxProof}
+	cmd.Run(cmd, []string{"abc"})
+
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestPostIsNotRetried(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	config = Config{GossipURL: srv.URL, Timeout: 5, Retries: 3}
+	retryBaseDelay = time.Millisecond
+
+	if _, err := newClient().SyncGossip(context.Background()); client.StatusCode(err) != http.StatusServiceUnavailable {
+		t.Fatalf("expected a 503 error, got %v", err)
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected a single attempt for POST, got %d", got)
+	}
+}
+
+func TestVersionJSONOutput(t *testing.T) {
+	withVersion := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"node_id": "n1", "version": "0.1.0"})
+	}))
+	defer withVersion.Close()
+	healthOnly := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "healthy", "version": "2.0.0"})
+	}))
+	defer healthOnly.Close()
+
+	config = Config{
+		ControlPlaneURL: "http://127.0.0.1:1", // nothing listens here
+		GCLURL:          withVersion.URL,
+		CatalogURL:      healthOnly.URL,
+		GossipURL:       withVersion.URL,
+		StorageURL:      withVersion.URL,
+		Timeout:         2,
+	}
+	version, commit = "v1.2.3", "abc1234"
+
+	var out bytes.Buffer
+	root := newRootCmd()
+	root.SetOut(&out)
+	root.SetArgs([]string{"version", "--output", "json"})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("version failed: %v", err)
+	}
+
+	var info VersionInfo
+	if err := json.Unmarshal(out.Bytes(), &info); err != nil {
+		t.Fatalf("version output is not valid JSON: %v\n%s", err, out.String())
+	}
+
+	if info.Client.Version != "v1.2.3" || info.Client.Commit != "abc1234" {
+		t.Errorf("unexpected client version %+v", info.Client)
+	}
+	if info.Servers["control_plane"].Reachable {
+		t.Error("expected control_plane to be unreachable")
+	}
+	if got := info.Servers["gcl"].Version; got != "0.1.0" {
+		t.Errorf("expected gcl version 0.1.0 from /node/info, got %q", got)
+	}
+	if got := info.Servers["catalog"].Version; got != "2.0.0" {
+		t.Errorf("expected catalog version 2.0.0 from /health, got %q", got)
+	}
+}
+
+func TestCompletionShells(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var out bytes.Buffer
+		root := newRootCmd()
+		root.SetOut(&out)
+		root.SetArgs([]string{"completion", shell})
+		if err := root.Execute(); err != nil {
+			t.Fatalf("completion %s failed: %v", shell, err)
+		}
+		if out.Len() == 0 {
+			t.Errorf("completion %s produced no script", shell)
+		}
+	}
+
+	root := newRootCmd()
+	root.SetOut(&bytes.Buffer{})
+	root.SetErr(&bytes.Buffer{})
+	root.SetArgs([]string{"completion", "tcsh"})
+	if err := root.Execute(); err == nil {
+		t.Error("expected an unsupported shell to be rejected")
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/decube/client"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// Build metadata, set at link time:
+//
+//	go build -ldflags "-X main.version=v1.2.3 -X main.commit=$(git rev-parse --short HEAD)"
+var (
+	version = "dev"
+	commit  = "unknown"
+)
+
+// ServerVersion is the version reported by a single DeCube service
+type ServerVersion struct {
+	Reachable bool   `json:"reachable" yaml:"reachable"`
+	Version   string `json:"version,omitempty" yaml:"version,omitempty"`
+	Error     string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// VersionInfo is the output of `decubectl version`
+type VersionInfo struct {
+	Client  ClientVersion            `json:"client" yaml:"client"`
+	Servers map[string]ServerVersion `json:"servers" yaml:"servers"`
+}
+
+// ClientVersion identifies the decubectl build
+type ClientVersion struct {
+	Version string `json:"version" yaml:"version"`
+	Commit  string `json:"commit" yaml:"commit"`
+}
+
+// fetchServerVersion reads the version from a service's /node/info, falling back to /health
+func fetchServerVersion(c *client.Client, svc client.Service) ServerVersion {
+	reachable := false
+	var lastErr string
+	for _, fetch := range []func(context.Context, client.Service) (client.Result, error){c.NodeInfo, c.Health} {
+		body, err := fetch(context.Background(), svc)
+		if err != nil {
+			lastErr = err.Error()
+			continue
+		}
+		reachable = true
+		if v, ok := body["version"].(string); ok && v != "" {
+			return ServerVersion{Reachable: true, Version: v}
+		}
+	}
+
+	if reachable {
+		// The service answered but does not report a version
+		return ServerVersion{Reachable: true}
+	}
+	return ServerVersion{Reachable: false, Error: lastErr}
+}
+
+// collectVersions gathers the client build and the versions of all configured services
+func collectVersions() VersionInfo {
+	c := newClient()
+	servers := make(map[string]ServerVersion, len(client.Services))
+	for _, svc := range client.Services {
+		servers[string(svc)] = fetchServerVersion(c, svc)
+	}
+	return VersionInfo{
+		Client:  ClientVersion{Version: version, Commit: commit},
+		Servers: servers,
+	}
+}
+
+// renderVersion writes the version information in the requested output format
+func renderVersion(w io.Writer, info VersionInfo, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	case "yaml":
+		data, err := yaml.Marshal(info)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case "table", "":
+		fmt.Fprintf(w, "Client: %s (commit %s)\n", info.Client.Version, info.Client.Commit)
+		for _, name := range sortedKeys(info.Servers) {
+			svc := info.Servers[name]
+			switch {
+			case !svc.Reachable:
+				fmt.Fprintf(w, "%s: unreachable (%s)\n", name, svc.Error)
+			case svc.Version == "":
+				fmt.Fprintf(w, "%s: unknown\n", name)
+			default:
+				fmt.Fprintf(w, "%s: %s\n", name, svc.Version)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q (expected table, json, or yaml)", format)
+	}
+}
+
+func showVersion(cmd *cobra.Command, args []string) {
+	info := VersionInfo{Client: ClientVersion{Version: version, Commit: commit}}
+	if clientOnly, _ := cmd.Flags().GetBool("client"); !clientOnly {
+		info = collectVersions()
+	}
+	if err := renderVersion(cmd.OutOrStdout(), info, outputFormat); err != nil {
+		log.Fatalf("Failed to render version: %v", err)
+	}
+}
+
+func newVersionCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Show client and server versions",
+		Args:  cobra.NoArgs,
+		Run:   showVersion,
+	}
+	cmd.Flags().Bool("client", false, "show only the client version without contacting services")
+	return cmd
+}
+
+func newCompletionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion [bash|zsh|fish]",
+		Short: "Generate a shell completion script",
+		Long: `Generate a shell completion script for decubectl.
+
+  bash: source <(decubectl completion bash)
+  zsh:  decubectl completion zsh > "${fpath[1]}/_decubectl"
+  fish: decubectl completion fish > ~/.config/fish/completions/decubectl.fish`,
+		ValidArgs:             []string{"bash", "zsh", "fish"},
+		Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			switch args[0] {
+			case "bash":
+				return cmd.Root().GenBashCompletionV2(out, true)
+			case "zsh":
+				return cmd.Root().GenZshCompletion(out)
+			default:
+				return cmd.Root().GenFishCompletion(out, true)
+			}
+		},
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+const (
+	// defaultCacheMaxBytes bounds the total size of objects cached in LevelDB
+	defaultCacheMaxBytes = 1 << 30
+	// defaultCacheMaxObjectBytes is the largest object that is cached at all
+	defaultCacheMaxObjectBytes = 16 << 20
+)
+
+// chunkCache caches objects in LevelDB under their hash, evicting the least
+// recently used ones once their total size exceeds maxBytes. Access order and
+// sizes are tracked in memory; objects larger than maxObject are not cached.
+type chunkCache struct {
+	db        *leveldb.DB
+	maxBytes  int64
+	maxObject int64
+
+	mu      sync.Mutex
+	size    int64
+	order   *list.List // front is the most recently used hash
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	hash string
+	size int64
+}
+
+// newChunkCache tracks the objects already cached in db, treating them as
+// least recently used, and evicts until they fit the limits
+func newChunkCache(db *leveldb.DB, maxBytes, maxObject int64) (*chunkCache, error) {
+	c := &chunkCache{
+		db:        db,
+		maxBytes:  maxBytes,
+		maxObject: maxObject,
+		order:     list.New(),
+		entries:   make(map[string]*list.Element),
+	}
+
+	iter := db.NewIterator(nil, nil)
+	for iter.Next() {
+		// Reference counts and chunk lists live in the same database
+		key := string(iter.Key())
+		if strings.Contains(key, ":") {
+			continue
+		}
+		c.entries[key] = c.order.PushBack(&cacheEntry{hash: key, size: int64(len(iter.Value()))})
+		c.size += int64(len(iter.Value()))
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c, c.evict()
+}
+
+// Get returns the cached object and marks it as recently used
+func (c *chunkCache) Get(hash string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := c.db.Get([]byte(hash), nil)
+	if err != nil {
+		return nil, err
+	}
+	if elem, ok := c.entries[hash]; ok {
+		c.order.MoveToFront(elem)
+	}
+	return data, nil
+}
+
+// Put caches data under hash unless it is larger than the per-object limit
+func (c *chunkCache) Put(hash string, data []byte) error {
+	size := int64(len(data))
+	if size > c.maxObject || size > c.maxBytes {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.db.Put([]byte(hash), data, nil); err != nil {
+		return err
+	}
+	if elem, ok := c.entries[hash]; ok {
+		c.size -= elem.Value.(*cacheEntry).size
+		c.order.Remove(elem)
+	}
+	c.entries[hash] = c.order.PushFront(&cacheEntry{hash: hash, size: size})
+	c.size += size
+	return c.evict()
+}
+
+// Delete drops hash from the cache
+func (c *chunkCache) Delete(hash string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[hash]; ok {
+		c.size -= elem.Value.(*cacheEntry).size
+		c.order.Remove(elem)
+		delete(c.entries, hash)
+	}
+	return c.db.Delete([]byte(hash), nil)
+}
+
+// Contains reports whether hash is cached, without marking it as used
+func (c *chunkCache) Contains(hash string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.entries[hash]
+	return ok
+}
+
+// SetLimits changes the cache limits, evicting entries that no longer fit
+func (c *chunkCache) SetLimits(maxBytes, maxObject int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.maxBytes, c.maxObject = maxBytes, maxObject
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		if entry := elem.Value.(*cacheEntry); entry.size > maxObject {
+			if err := c.db.Delete([]byte(entry.hash), nil); err != nil {
+				return err
+			}
+			c.size -= entry.size
+			c.order.Remove(elem)
+			delete(c.entries, entry.hash)
+		}
+		elem = next
+	}
+	return c.evict()
+}
+
+// evict removes least recently used entries until the cache is within
+// maxBytes. The caller holds c.mu.
+func (c *chunkCache) evict() error {
+	for c.size > c.maxBytes {
+		elem := c.order.Back()
+		entry := elem.Value.(*cacheEntry)
+		if err := c.db.Delete([]byte(entry.hash), nil); err != nil {
+			return err
+		}
+		c.size -= entry.size
+		c.order.Remove(elem)
+		delete(c.entries, entry.hash)
+	}
+	return nil
+}
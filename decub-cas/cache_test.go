@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cas, _ := newTestCAS(t)
+	ctx := context.Background()
+	if err := cas.cache.SetLimits(30, 20); err != nil {
+		t.Fatalf("set limits: %v", err)
+	}
+
+	store := func(data string) string {
+		t.Helper()
+		hash, err := cas.Store(ctx, []byte(data))
+		if err != nil {
+			t.Fatalf("store %q: %v", data, err)
+		}
+		return hash
+	}
+	cached := func(hash string) bool {
+		_, err := cas.db.Get([]byte(hash), nil)
+		return err == nil
+	}
+
+	a, b, c := store("aaaaaaaaaa"), store("bbbbbbbbbb"), store("cccccccccc")
+	// Reading a makes b the least recently used entry
+	if _, err := cas.Retrieve(ctx, a); err != nil {
+		t.Fatalf("retrieve: %v", err)
+	}
+	d := store("dddddddddd")
+
+	if cached(b) || cas.cache.Contains(b) {
+		t.Fatal("least recently used entry was not evicted")
+	}
+	for _, hash := range []string{a, c, d} {
+		if !cached(hash) {
+			t.Fatalf("recently used entry %s was evicted", hash)
+		}
+	}
+
+	// Evicted and oversized objects are still served from MinIO
+	large := bytes.Repeat([]byte("x"), 25)
+	hash, err := cas.Store(ctx, large)
+	if err != nil {
+		t.Fatalf("store large object: %v", err)
+	}
+	if cached(hash) {
+		t.Fatal("object above the per-object limit was cached")
+	}
+	if data, err := cas.Retrieve(ctx, hash); err != nil || !bytes.Equal(data, large) {
+		t.Fatalf("retrieve large object: %v", err)
+	}
+	if data, err := cas.Retrieve(ctx, b); err != nil || string(data) != "bbbbbbbbbb" {
+		t.Fatalf("retrieve evicted object: %v", err)
+	}
+	if !cached(b) || cached(c) {
+		t.Fatal("retrieving an evicted object should cache it again, evicting the oldest entry")
+	}
+}
@@ -3,7 +3,10 @@ module github.com/decub/cas
 go 1.19
 
 require (
+	github.com/decube/httpserve v0.0.0
 	github.com/minio/minio-go/v7 v7.0.52
 	github.com/gorilla/mux v1.8.0
 	github.com/syndtr/goleveldb v1.0.1-0.20210819022825-2ae1ddf898db
 )
+
+replace github.com/decube/httpserve => ../pkg/httpserve
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var startTime = time.Now()
+
+// HealthResponse is returned by the /health endpoint
+type HealthResponse struct {
+	Status        string `json:"status"`
+	Service       string `json:"service"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+	Error         string `json:"error,omitempty"`
+}
+
+// writeHealth reports the service as healthy, or as unavailable with 503 when readyErr is set
+func writeHealth(w http.ResponseWriter, service string, readyErr error) {
+	resp := HealthResponse{
+		Status:        "healthy",
+		Service:       service,
+		UptimeSeconds: int64(time.Since(startTime).Seconds()),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if readyErr != nil {
+		resp.Status = "unhealthy"
+		resp.Error = readyErr.Error()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ready checks that the LevelDB cache is open and MinIO is reachable
+func (c *CAS) ready(ctx context.Context) error {
+	if _, err := c.db.GetProperty("leveldb.stats"); err != nil {
+		return fmt.Errorf("leveldb: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if _, err := c.minioClient.BucketExists(ctx, c.bucket); err != nil {
+		return fmt.Errorf("minio: %w", err)
+	}
+
+	return nil
+}
+
+func (c *CAS) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, "decub-cas", c.ready(r.Context()))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOversizedBodyRejected(t *testing.T) {
+	cas, _ := newTestCAS(t)
+	r := newRouter(cas)
+	r.Use(requestLimits{maxBodyBytes: 16, timeout: time.Second}.middleware)
+
+	body := strings.Repeat("x", 32)
+	for _, path := range []string{"/store", "/chunk/store"} {
+		// Declared length over the limit is rejected up front
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("%s with Content-Length: expected 413, got %d", path, rec.Code)
+		}
+
+		// Streamed bodies of unknown length are cut off while reading
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.ContentLength = -1
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("%s without Content-Length: expected 413, got %d", path, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/store", strings.NewReader("small")))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("body under the limit: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		http.Error(w, r.Context().Err().Error(), errorStatus(r.Context().Err(), http.StatusInternalServerError))
+	})
+
+	rec := httptest.NewRecorder()
+	requestLimits{timeout: 10 * time.Millisecond}.middleware(slow).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d", rec.Code)
+	}
+}
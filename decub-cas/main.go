@@ -1,17 +1,24 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/decube/httpserve"
 	"github.com/gorilla/mux"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
@@ -23,10 +30,12 @@ type CAS struct {
 	minioClient *minio.Client
 	bucket      string
 	db          *leveldb.DB
+	cache       *chunkCache
+	refMu       sync.Mutex // serializes reference count updates
 }
 
-// NewCAS creates a new CAS instance
-func NewCAS(endpoint, accessKey, secretKey, bucket string) (*CAS, error) {
+// NewCAS creates a new CAS instance keeping its LevelDB cache under dataDir
+func NewCAS(endpoint, accessKey, secretKey, bucket, dataDir string) (*CAS, error) {
 	minioClient, err := minio.New(endpoint, &minio.Options{
 		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
 		Secure: false, // For local MinIO
@@ -45,8 +54,17 @@ func NewCAS(endpoint, accessKey, secretKey, bucket string) (*CAS, error) {
 	}
 
 	// Open LevelDB
-	db, err := leveldb.OpenFile("./cas.db", nil)
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data dir %s: %w", dataDir, err)
+	}
+	db, err := leveldb.OpenFile(filepath.Join(dataDir, "cas.db"), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := newChunkCache(db, defaultCacheMaxBytes, defaultCacheMaxObjectBytes)
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
@@ -54,6 +72,7 @@ func NewCAS(endpoint, accessKey, secretKey, bucket string) (*CAS, error) {
 		minioClient: minioClient,
 		bucket:      bucket,
 		db:          db,
+		cache:       cache,
 	}, nil
 }
 
@@ -78,8 +97,8 @@ func (c *CAS) Store(ctx context.Context, data []byte) (string, error) {
 		return "", err
 	}
 
-	// Store metadata in LevelDB
-	err = c.db.Put([]byte(hashStr), data, nil)
+	// Cache in LevelDB
+	err = c.cache.Put(hashStr, data)
 	if err != nil {
 		return "", err
 	}
@@ -90,7 +109,7 @@ func (c *CAS) Store(ctx context.Context, data []byte) (string, error) {
 // Retrieve retrieves data by its content address
 func (c *CAS) Retrieve(ctx context.Context, hash string) ([]byte, error) {
 	// First check LevelDB
-	data, err := c.db.Get([]byte(hash), nil)
+	data, err := c.cache.Get(hash)
 	if err == nil {
 		return data, nil
 	}
@@ -108,27 +127,105 @@ func (c *CAS) Retrieve(ctx context.Context, hash string) ([]byte, error) {
 	}
 
 	// Cache in LevelDB
-	c.db.Put([]byte(hash), data, nil)
+	c.cache.Put(hash, data)
 
 	return data, nil
 }
 
+// chunkStoreWorkers bounds the number of chunks ChunkAndStoreReader stores at once
+const chunkStoreWorkers = 4
+
 // ChunkAndStore chunks large data and stores chunks
 func (c *CAS) ChunkAndStore(ctx context.Context, data []byte, chunkSize int) ([]string, error) {
-	var hashes []string
-	for i := 0; i < len(data); i += chunkSize {
-		end := i + chunkSize
-		if end > len(data) {
-			end = len(data)
+	hashes, _, err := c.ChunkAndStoreReader(ctx, bytes.NewReader(data), chunkSize)
+	return hashes, err
+}
+
+// ChunkAndStoreReader reads r in chunks of chunkSize and stores them with a
+// bounded pool of workers. It returns the chunk hashes in input order and
+// their Merkle root, under which the chunks stay referenced until the object
+// is deleted with DeleteChunked. A chunk is only read once a worker is free, so at most
+// chunkStoreWorkers chunks are held in memory and a slow store slows down
+// reading instead of buffering the input.
+func (c *CAS) ChunkAndStoreReader(ctx context.Context, r io.Reader, chunkSize int) ([]string, string, error) {
+	if chunkSize <= 0 {
+		return nil, "", fmt.Errorf("invalid chunk size %d", chunkSize)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		hashes   []string
+		storeErr error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if storeErr == nil {
+			storeErr = err
+			cancel()
 		}
-		chunk := data[i:end]
-		hash, err := c.Store(ctx, chunk)
-		if err != nil {
-			return nil, err
+	}
+
+	workers := make(chan struct{}, chunkStoreWorkers)
+	for index := 0; ; index++ {
+		select {
+		case workers <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		chunk := make([]byte, chunkSize)
+		n, err := io.ReadFull(r, chunk)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			fail(fmt.Errorf("failed to read chunk %d: %w", index, err))
+			break
+		}
+
+		mu.Lock()
+		hashes = append(hashes, "")
+		mu.Unlock()
+
+		wg.Add(1)
+		go func(index int, chunk []byte) {
+			defer wg.Done()
+			defer func() { <-workers }()
+
+			hash, err := c.Store(ctx, chunk)
+			if err != nil {
+				fail(fmt.Errorf("failed to store chunk %d: %w", index, err))
+				return
+			}
+			mu.Lock()
+			hashes[index] = hash
+			mu.Unlock()
+		}(index, chunk[:n])
+
+		if n < chunkSize {
+			break
 		}
-		hashes = append(hashes, hash)
 	}
-	return hashes, nil
+	wg.Wait()
+
+	if storeErr != nil {
+		return nil, "", storeErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	root := BuildMerkleTree(hashes).Hash
+	if err := c.addChunkRefs(root, hashes); err != nil {
+		return nil, "", err
+	}
+	return hashes, root, nil
 }
 
 // RetrieveChunks retrieves and reassembles chunks
@@ -151,10 +248,15 @@ type MerkleNode struct {
 	Right *MerkleNode
 }
 
-// BuildMerkleTree builds a Merkle tree from hashes
+// EmptyMerkleRoot is the root hash of a tree without leaves: the SHA-256 of the empty string
+const EmptyMerkleRoot = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// BuildMerkleTree builds a Merkle tree over chunk hashes in chunk order. A
+// level with an odd number of nodes pairs its last node with itself, and an
+// empty input yields a single node with EmptyMerkleRoot.
 func BuildMerkleTree(hashes []string) *MerkleNode {
 	if len(hashes) == 0 {
-		return nil
+		return &MerkleNode{Hash: EmptyMerkleRoot}
 	}
 
 	nodes := make([]*MerkleNode, len(hashes))
@@ -181,26 +283,26 @@ func BuildMerkleTree(hashes []string) *MerkleNode {
 	return nodes[0]
 }
 
-// GenerateMerkleProof generates a Merkle proof for a chunk
+// GenerateMerkleProof returns the sibling hashes from the chunk at index up
+// to the root of a tree built by BuildMerkleTree. Levels pair nodes from the
+// leaves up, so the path from the root follows the bits of index from the
+// most significant one down.
 func GenerateMerkleProof(root *MerkleNode, index int) []string {
-	var proof []string
+	height := 0
+	for node := root; node.Left != nil; node = node.Left {
+		height++
+	}
+
+	proof := make([]string, height)
 	current := root
-	for current.Left != nil || current.Right != nil {
-		if index%2 == 0 {
-			if current.Right != nil {
-				proof = append(proof, current.Right.Hash)
-			}
-		} else {
-			if current.Left != nil {
-				proof = append(proof, current.Left.Hash)
-			}
-		}
-		if index%2 == 0 {
+	for level := height - 1; level >= 0; level-- {
+		if (index>>level)&1 == 0 {
+			proof[level] = current.Right.Hash
 			current = current.Left
 		} else {
+			proof[level] = current.Left.Hash
 			current = current.Right
 		}
-		index /= 2
 	}
 	return proof
 }
@@ -209,27 +311,35 @@ func GenerateMerkleProof(root *MerkleNode, index int) []string {
 func VerifyMerkleProof(rootHash string, chunkHash string, proof []string, index int) bool {
 	hash := chunkHash
 	for _, p := range proof {
+		var sum [32]byte
 		if index%2 == 0 {
-			hash = hex.EncodeToString(sha256.Sum256([]byte(hash + p)))
+			sum = sha256.Sum256([]byte(hash + p))
 		} else {
-			hash = hex.EncodeToString(sha256.Sum256([]byte(p + hash)))
+			sum = sha256.Sum256([]byte(p + hash))
 		}
+		hash = hex.EncodeToString(sum[:])
 		index /= 2
 	}
 	return hash == rootHash
 }
 
+// ChunkStoreResponse is returned by the chunk store endpoint
+type ChunkStoreResponse struct {
+	Hashes     []string `json:"hashes"`
+	MerkleRoot string   `json:"merkle_root"`
+}
+
 // API handlers
 func (c *CAS) handleStore(w http.ResponseWriter, r *http.Request) {
 	data, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
 		return
 	}
 
 	hash, err := c.Store(r.Context(), data)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
@@ -252,36 +362,21 @@ func (c *CAS) handleRetrieve(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *CAS) handleChunkStore(w http.ResponseWriter, r *http.Request) {
-	data, err := io.ReadAll(r.Body)
+	hashes, root, err := c.ChunkAndStoreReader(r.Context(), r.Body, 1024*1024) // 1MB chunks
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	hashes, err := c.ChunkAndStore(r.Context(), data, 1024*1024) // 1MB chunks
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	root := BuildMerkleTree(hashes)
-	if root == nil {
-		http.Error(w, "Failed to build Merkle tree", http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	fmt.Fprintf(w, `{"hashes": %q, "merkle_root": "%s"}`, hashes, root.Hash)
+	json.NewEncoder(w).Encode(ChunkStoreResponse{Hashes: hashes, MerkleRoot: root})
 }
 
+// handleChunkRetrieve reassembles chunks from a JSON array of hashes in the request body
 func (c *CAS) handleChunkRetrieve(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	hashStr := vars["hashes"]
-
 	var hashes []string
-	err := json.Unmarshal([]byte(hashStr), &hashes)
-	if err != nil {
-		http.Error(w, "Invalid hashes format", http.StatusBadRequest)
+	if err := json.NewDecoder(r.Body).Decode(&hashes); err != nil {
+		http.Error(w, "Invalid hashes format: expected a JSON array", errorStatus(err, http.StatusBadRequest))
 		return
 	}
 
@@ -299,32 +394,100 @@ func (c *CAS) Close() error {
 	return c.db.Close()
 }
 
+// newRouter registers the CAS API routes
+func newRouter(cas *CAS) *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/health", cas.handleHealth).Methods("GET")
+	r.HandleFunc("/store", cas.handleStore).Methods("POST")
+	r.HandleFunc("/retrieve/{hash}", cas.handleRetrieve).Methods("GET")
+	r.HandleFunc("/retrieve/{hash}", cas.handleDelete).Methods("DELETE")
+	r.HandleFunc("/chunk/store", cas.handleChunkStore).Methods("POST")
+	r.HandleFunc("/chunk/retrieve", cas.handleChunkRetrieve).Methods("POST")
+	r.HandleFunc("/chunk/verify", cas.handleChunkVerify).Methods("POST")
+	r.HandleFunc("/chunk/{merkle_root}", cas.handleDeleteChunked).Methods("DELETE")
+	r.HandleFunc("/chunk/{merkle_root}/proof/{index}", cas.handleChunkProof).Methods("GET")
+	return r
+}
+
+// envBytes reads a byte count from the environment variable name
+func envBytes(name string, def int64) (int64, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q: expected a byte count", name, v)
+	}
+	return n, nil
+}
+
 func main() {
-	if len(os.Args) < 4 {
-		fmt.Println("Usage: go run main.go <minio-endpoint> <access-key> <secret-key> [bucket]")
+	envPort, err := httpserve.PortFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	port := flag.Int("port", envPort, "port to listen on (env PORT); 0 picks a free port")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) < 3 {
+		fmt.Println("Usage: go run main.go [--port N] <minio-endpoint> <access-key> <secret-key> [bucket]")
 		os.Exit(1)
 	}
 
-	endpoint := os.Args[1]
-	accessKey := os.Args[2]
-	secretKey := os.Args[3]
+	endpoint := args[0]
+	accessKey := args[1]
+	secretKey := args[2]
 	bucket := "decub-cas"
-	if len(os.Args) > 4 {
-		bucket = os.Args[4]
+	if len(args) > 3 {
+		bucket = args[3]
+	}
+	dataDir := os.Getenv("DECUB_DATA_DIR")
+	if dataDir == "" {
+		dataDir = "data"
+	}
+
+	cacheMaxBytes, err := envBytes("DECUB_CACHE_MAX_BYTES", defaultCacheMaxBytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cacheMaxObjectBytes, err := envBytes("DECUB_CACHE_MAX_OBJECT_BYTES", defaultCacheMaxObjectBytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	maxBodyBytes, err := envBytes("DECUB_MAX_BODY_BYTES", defaultMaxBodyBytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	requestTimeout, err := envDuration("DECUB_REQUEST_TIMEOUT", defaultRequestTimeout)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	cas, err := NewCAS(endpoint, accessKey, secretKey, bucket)
+	cas, err := NewCAS(endpoint, accessKey, secretKey, bucket, dataDir)
 	if err != nil {
 		log.Fatalf("Failed to create CAS: %v", err)
 	}
-	defer cas.Close()
+	if err := cas.cache.SetLimits(cacheMaxBytes, cacheMaxObjectBytes); err != nil {
+		log.Fatalf("Failed to apply cache limits: %v", err)
+	}
 
-	r := mux.NewRouter()
-	r.HandleFunc("/store", cas.handleStore).Methods("POST")
-	r.HandleFunc("/retrieve/{hash}", cas.handleRetrieve).Methods("GET")
-	r.HandleFunc("/chunk/store", cas.handleChunkStore).Methods("POST")
-	r.HandleFunc("/chunk/retrieve/{hashes}", cas.handleChunkRetrieve).Methods("GET")
+	r := newRouter(cas)
+	r.Use(requestLimits{maxBodyBytes: maxBodyBytes, timeout: requestTimeout}.middleware)
 
-	fmt.Println("CAS server starting on :8080")
-	log.Fatal(http.ListenAndServe(":8080", r))
+	lis, err := net.Listen("tcp", httpserve.PortAddr(*port))
+	if err != nil {
+		log.Fatalf("Failed to listen on port %d: %v", *port, err)
+	}
+	fmt.Printf("CAS server listening on %s\n", lis.Addr())
+	err = httpserve.ServeUntilSignal(lis, r)
+
+	// Close the store only after in-flight requests have finished with it
+	cas.Close()
+	if err != nil {
+		log.Fatalf("CAS server failed: %v", err)
+	}
+	fmt.Println("CAS server stopped")
 }
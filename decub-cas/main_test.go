@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChunkStoreAndRetrieve(t *testing.T) {
+	cas, _ := newTestCAS(t)
+	router := newRouter(cas)
+
+	// Three 1MB chunks, the last one partial
+	data := bytes.Repeat([]byte("decube-chunk-data"), 150000)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/chunk/store", bytes.NewReader(data)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("chunk store: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var stored ChunkStoreResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &stored); err != nil {
+		t.Fatalf("chunk store returned invalid JSON: %v", err)
+	}
+	if len(stored.Hashes) != 3 || stored.MerkleRoot == "" {
+		t.Fatalf("unexpected chunk store response: %+v", stored)
+	}
+
+	body, _ := json.Marshal(stored.Hashes)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/chunk/retrieve", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("chunk retrieve: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !bytes.Equal(rec.Body.Bytes(), data) {
+		t.Fatal("retrieved data does not match stored data")
+	}
+}
+
+func TestTwoInstancesUseSeparateDataDirs(t *testing.T) {
+	srv := httptest.NewServer(&fakeS3{objects: make(map[string][]byte)})
+	defer srv.Close()
+	endpoint := strings.TrimPrefix(srv.URL, "http://")
+
+	first, err := NewCAS(endpoint, "test", "test", "test", t.TempDir())
+	if err != nil {
+		t.Fatalf("first instance: %v", err)
+	}
+	defer first.Close()
+
+	// A second instance in the same process must not contend for the first one's LevelDB lock
+	second, err := NewCAS(endpoint, "test", "test", "test", t.TempDir())
+	if err != nil {
+		t.Fatalf("second instance: %v", err)
+	}
+	defer second.Close()
+
+	hash, err := first.Store(context.Background(), []byte("only in the first cache"))
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	if _, err := second.db.Get([]byte(hash), nil); err == nil {
+		t.Fatal("second instance shares the first instance's cache")
+	}
+}
+
+func TestBuildMerkleTreeLeafCounts(t *testing.T) {
+	pair := func(a, b string) string {
+		sum := sha256.Sum256([]byte(a + b))
+		return hex.EncodeToString(sum[:])
+	}
+
+	tests := []struct {
+		name   string
+		hashes []string
+		want   string
+	}{
+		{"Empty", nil, EmptyMerkleRoot},
+		{"One", []string{"a"}, "a"},
+		{"Two", []string{"a", "b"}, pair("a", "b")},
+		{"Three", []string{"a", "b", "c"}, pair(pair("a", "b"), pair("c", "c"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := BuildMerkleTree(tt.hashes)
+			if root == nil || root.Hash != tt.want {
+				t.Fatalf("expected root %s, got %+v", tt.want, root)
+			}
+		})
+	}
+
+	sum := sha256.Sum256(nil)
+	if hex.EncodeToString(sum[:]) != EmptyMerkleRoot {
+		t.Fatal("EmptyMerkleRoot is not the SHA-256 of the empty string")
+	}
+
+	// Chunk order is significant, so reordering changes the root
+	if BuildMerkleTree([]string{"a", "b"}).Hash == BuildMerkleTree([]string{"b", "a"}).Hash {
+		t.Fatal("chunk order should affect the root")
+	}
+}
+
+func TestChunkAndStoreReaderMatchesByteSlice(t *testing.T) {
+	cas, _ := newTestCAS(t)
+	ctx := context.Background()
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1000)
+
+	for _, size := range []int{len(data), 1000, 4096, 7} {
+		want, err := cas.ChunkAndStore(ctx, data, size)
+		if err != nil {
+			t.Fatalf("chunk size %d: ChunkAndStore: %v", size, err)
+		}
+		got, root, err := cas.ChunkAndStoreReader(ctx, bytes.NewReader(data), size)
+		if err != nil {
+			t.Fatalf("chunk size %d: ChunkAndStoreReader: %v", size, err)
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Fatalf("chunk size %d: reader hashes differ from byte-slice hashes", size)
+		}
+		if wantChunks := (len(data) + size - 1) / size; len(got) != wantChunks {
+			t.Fatalf("chunk size %d: expected %d chunks, got %d", size, wantChunks, len(got))
+		}
+		if root != BuildMerkleTree(want).Hash {
+			t.Fatalf("chunk size %d: unexpected Merkle root %s", size, root)
+		}
+	}
+
+	hashes, root, err := cas.ChunkAndStoreReader(ctx, bytes.NewReader(nil), 1024)
+	if err != nil || len(hashes) != 0 || root != EmptyMerkleRoot {
+		t.Fatalf("empty input: got %v, %s, %v", hashes, root, err)
+	}
+}
+
+func TestChunkAndStoreReaderHonorsCancellation(t *testing.T) {
+	cas, _ := newTestCAS(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err := cas.ChunkAndStoreReader(ctx, bytes.NewReader(make([]byte, 1<<16)), 1024)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func BenchmarkChunkAndStoreReader(b *testing.B) {
+	cas, _ := newTestCAS(b)
+	data := make([]byte, 8<<20)
+	rand.Read(data)
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := cas.ChunkAndStoreReader(context.Background(), bytes.NewReader(data), 1024*1024); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
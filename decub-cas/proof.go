@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// ProofStep is a sibling hash on the path from a chunk to the Merkle root.
+// Left is set when the sibling is hashed in front of the running hash.
+type ProofStep struct {
+	Hash string `json:"hash"`
+	Left bool   `json:"left"`
+}
+
+// ChunkProof proves that the chunk at Index belongs to the chunked object
+// with MerkleRoot. It is returned by the proof endpoint and accepted by the
+// verify endpoint.
+type ChunkProof struct {
+	MerkleRoot string      `json:"merkle_root"`
+	ChunkHash  string      `json:"chunk_hash"`
+	Index      int         `json:"index"`
+	ChunkCount int         `json:"chunk_count"`
+	Proof      []ProofStep `json:"proof"`
+}
+
+// VerifyResponse is returned by the verify endpoint
+type VerifyResponse struct {
+	Valid bool `json:"valid"`
+}
+
+// chunkHashes returns the chunk hashes of the chunked object root
+func (c *CAS) chunkHashes(root string) ([]string, error) {
+	list, err := c.db.Get(objectKey(root), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, fmt.Errorf("object %s: %w", root, errNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var hashes []string
+	if err := json.Unmarshal(list, &hashes); err != nil {
+		return nil, fmt.Errorf("invalid chunk list for object %s: %w", root, err)
+	}
+	return hashes, nil
+}
+
+// ChunkProof builds the proof for the chunk at index of the chunked object root
+func (c *CAS) ChunkProof(root string, index int) (*ChunkProof, error) {
+	hashes, err := c.chunkHashes(root)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(hashes) {
+		return nil, fmt.Errorf("object %s has %d chunks, no chunk %d: %w", root, len(hashes), index, errNotFound)
+	}
+
+	siblings := GenerateMerkleProof(BuildMerkleTree(hashes), index)
+	proof := &ChunkProof{
+		MerkleRoot: root,
+		ChunkHash:  hashes[index],
+		Index:      index,
+		ChunkCount: len(hashes),
+		Proof:      make([]ProofStep, len(siblings)),
+	}
+	for level, hash := range siblings {
+		proof.Proof[level] = ProofStep{Hash: hash, Left: (index>>level)&1 == 1}
+	}
+	return proof, nil
+}
+
+// Verify checks the proof against its Merkle root. The direction of each
+// step must match the one implied by Index, so a proof for one position
+// cannot be presented for another.
+func (p *ChunkProof) Verify() bool {
+	if p.Index < 0 || p.Index >= p.ChunkCount {
+		return false
+	}
+	height := 0
+	for n := 1; n < p.ChunkCount; n *= 2 {
+		height++
+	}
+	if len(p.Proof) != height {
+		return false
+	}
+
+	siblings := make([]string, len(p.Proof))
+	for level, step := range p.Proof {
+		if step.Left != ((p.Index>>level)&1 == 1) {
+			return false
+		}
+		siblings[level] = step.Hash
+	}
+	return VerifyMerkleProof(p.MerkleRoot, p.ChunkHash, siblings, p.Index)
+}
+
+func (c *CAS) handleChunkProof(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	index, err := strconv.Atoi(vars["index"])
+	if err != nil {
+		http.Error(w, "Invalid chunk index", http.StatusBadRequest)
+		return
+	}
+
+	proof, err := c.ChunkProof(vars["merkle_root"], index)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(proof)
+}
+
+func (c *CAS) handleChunkVerify(w http.ResponseWriter, r *http.Request) {
+	var proof ChunkProof
+	if err := json.NewDecoder(r.Body).Decode(&proof); err != nil {
+		http.Error(w, "Invalid proof format", errorStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(VerifyResponse{Valid: proof.Verify()})
+}
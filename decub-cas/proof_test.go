@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGenerateMerkleProofVerifiesEveryLeaf(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		hashes := make([]string, n)
+		for i := range hashes {
+			hashes[i] = fmt.Sprintf("leaf-%d", i)
+		}
+		root := BuildMerkleTree(hashes)
+		for i, hash := range hashes {
+			if !VerifyMerkleProof(root.Hash, hash, GenerateMerkleProof(root, i), i) {
+				t.Fatalf("%d leaves: proof for leaf %d does not verify", n, i)
+			}
+		}
+	}
+}
+
+func TestChunkProofEndpoints(t *testing.T) {
+	cas, _ := newTestCAS(t)
+	router := newRouter(cas)
+
+	_, root, err := cas.ChunkAndStoreReader(context.Background(), strings.NewReader("aaaabbbbccccddddeeee"), 4)
+	if err != nil {
+		t.Fatalf("chunk store: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/chunk/"+root+"/proof/2", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("proof: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var proof ChunkProof
+	if err := json.Unmarshal(rec.Body.Bytes(), &proof); err != nil {
+		t.Fatalf("proof returned invalid JSON: %v", err)
+	}
+	if proof.ChunkHash != chunkHashOf("cccc") || proof.ChunkCount != 5 || len(proof.Proof) != 3 {
+		t.Fatalf("unexpected proof: %+v", proof)
+	}
+
+	verify := func(p ChunkProof) bool {
+		t.Helper()
+		body, _ := json.Marshal(p)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/chunk/verify", bytes.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("verify: expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp VerifyResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return resp.Valid
+	}
+
+	if !verify(proof) {
+		t.Fatal("valid proof was rejected")
+	}
+
+	tampered := proof
+	tampered.Proof = append([]ProofStep(nil), proof.Proof...)
+	tampered.Proof[1].Hash = chunkHashOf("evil")
+	if verify(tampered) {
+		t.Fatal("proof with a tampered sibling hash was accepted")
+	}
+
+	wrongChunk := proof
+	wrongChunk.ChunkHash = chunkHashOf("dddd")
+	if verify(wrongChunk) {
+		t.Fatal("proof was accepted for another chunk")
+	}
+
+	for path, want := range map[string]int{
+		"/chunk/" + root + "/proof/5":            http.StatusNotFound,
+		"/chunk/" + root + "/proof/x":            http.StatusBadRequest,
+		"/chunk/" + EmptyMerkleRoot + "/proof/0": http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Fatalf("%s: expected %d, got %d", path, want, rec.Code)
+		}
+	}
+}
+
+func chunkHashOf(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/minio/minio-go/v7"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// LevelDB key prefixes for chunk reference counts and the chunk lists of
+// chunked objects. Cached data is keyed by the bare hash.
+const (
+	refKeyPrefix    = "ref:"
+	objectKeyPrefix = "object:"
+)
+
+var (
+	errNotFound   = errors.New("not found")
+	errReferenced = errors.New("still referenced by a chunked object")
+)
+
+func refKey(hash string) []byte { return []byte(refKeyPrefix + hash) }
+
+func objectKey(root string) []byte { return []byte(objectKeyPrefix + root) }
+
+// refCount returns how many chunked objects reference hash
+func (c *CAS) refCount(hash string) (int, error) {
+	data, err := c.db.Get(refKey(hash), nil)
+	if err == leveldb.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(data))
+}
+
+// addChunkRefs records the chunked object root and takes a reference on each
+// of its chunks. Storing the same object again does not add references.
+func (c *CAS) addChunkRefs(root string, hashes []string) error {
+	c.refMu.Lock()
+	defer c.refMu.Unlock()
+
+	if ok, err := c.db.Has(objectKey(root), nil); err != nil || ok {
+		return err
+	}
+
+	list, err := json.Marshal(hashes)
+	if err != nil {
+		return err
+	}
+	counts := make(map[string]int)
+	for _, hash := range hashes {
+		if _, ok := counts[hash]; !ok {
+			if counts[hash], err = c.refCount(hash); err != nil {
+				return err
+			}
+		}
+		counts[hash]++
+	}
+
+	batch := new(leveldb.Batch)
+	batch.Put(objectKey(root), list)
+	for hash, n := range counts {
+		batch.Put(refKey(hash), []byte(strconv.Itoa(n)))
+	}
+	return c.db.Write(batch, nil)
+}
+
+// Delete removes an unreferenced chunk from MinIO and the LevelDB cache
+func (c *CAS) Delete(ctx context.Context, hash string) error {
+	c.refMu.Lock()
+	defer c.refMu.Unlock()
+
+	refs, err := c.refCount(hash)
+	if err != nil {
+		return err
+	}
+	if refs > 0 {
+		return fmt.Errorf("chunk %s: %w (%d references)", hash, errReferenced, refs)
+	}
+
+	if _, err := c.minioClient.StatObject(ctx, c.bucket, hash, minio.StatObjectOptions{}); err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return fmt.Errorf("chunk %s: %w", hash, errNotFound)
+		}
+		return err
+	}
+	return c.removeChunk(ctx, hash)
+}
+
+// DeleteChunked deletes the chunked object with the given Merkle root,
+// dropping its references and removing the chunks no other object uses
+func (c *CAS) DeleteChunked(ctx context.Context, root string) error {
+	c.refMu.Lock()
+	defer c.refMu.Unlock()
+
+	hashes, err := c.chunkHashes(root)
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[string]int)
+	for _, hash := range hashes {
+		if _, ok := counts[hash]; !ok {
+			if counts[hash], err = c.refCount(hash); err != nil {
+				return err
+			}
+		}
+		counts[hash]--
+	}
+
+	// Drop the references before removing chunks so a failure part-way
+	// leaves unreferenced chunks behind rather than dangling references
+	batch := new(leveldb.Batch)
+	batch.Delete(objectKey(root))
+	for hash, n := range counts {
+		if n > 0 {
+			batch.Put(refKey(hash), []byte(strconv.Itoa(n)))
+		} else {
+			batch.Delete(refKey(hash))
+		}
+	}
+	if err := c.db.Write(batch, nil); err != nil {
+		return err
+	}
+
+	for hash, n := range counts {
+		if n > 0 {
+			continue
+		}
+		if err := c.removeChunk(ctx, hash); err != nil {
+			return fmt.Errorf("failed to remove chunk %s: %w", hash, err)
+		}
+	}
+	return nil
+}
+
+func (c *CAS) removeChunk(ctx context.Context, hash string) error {
+	if err := c.minioClient.RemoveObject(ctx, c.bucket, hash, minio.RemoveObjectOptions{}); err != nil {
+		return err
+	}
+	return c.cache.Delete(hash)
+}
+
+// deleteStatus maps a delete error to its HTTP status
+func deleteStatus(err error) int {
+	switch {
+	case errors.Is(err, errNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errReferenced):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (c *CAS) handleDelete(w http.ResponseWriter, r *http.Request) {
+	if err := c.Delete(r.Context(), mux.Vars(r)["hash"]); err != nil {
+		http.Error(w, err.Error(), deleteStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *CAS) handleDeleteChunked(w http.ResponseWriter, r *http.Request) {
+	if err := c.DeleteChunked(r.Context(), mux.Vars(r)["merkle_root"]); err != nil {
+		http.Error(w, err.Error(), deleteStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
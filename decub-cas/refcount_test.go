@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDeleteChunkedKeepsSharedChunk(t *testing.T) {
+	cas, s3 := newTestCAS(t)
+	router := newRouter(cas)
+	ctx := context.Background()
+
+	del := func(path string) int {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, path, nil))
+		return rec.Code
+	}
+
+	first, firstRoot, err := cas.ChunkAndStoreReader(ctx, strings.NewReader("baseAAAA"), 4)
+	if err != nil {
+		t.Fatalf("store first object: %v", err)
+	}
+	second, secondRoot, err := cas.ChunkAndStoreReader(ctx, strings.NewReader("baseBBBB"), 4)
+	if err != nil {
+		t.Fatalf("store second object: %v", err)
+	}
+	shared := first[0]
+	if second[0] != shared {
+		t.Fatal("objects were expected to share their first chunk")
+	}
+
+	if code := del("/retrieve/" + shared); code != http.StatusConflict {
+		t.Fatalf("deleting a referenced chunk: expected 409, got %d", code)
+	}
+	if code := del("/chunk/" + firstRoot); code != http.StatusNoContent {
+		t.Fatalf("delete first object: expected 204, got %d", code)
+	}
+	if !s3.has("test", shared) {
+		t.Fatal("shared chunk was removed while the second object references it")
+	}
+	if s3.has("test", first[1]) {
+		t.Fatal("unshared chunk of the deleted object was not removed")
+	}
+	if _, err := cas.RetrieveChunks(ctx, second); err != nil {
+		t.Fatalf("second object is no longer retrievable: %v", err)
+	}
+	if code := del("/chunk/" + firstRoot); code != http.StatusNotFound {
+		t.Fatalf("deleting an object twice: expected 404, got %d", code)
+	}
+
+	if code := del("/chunk/" + secondRoot); code != http.StatusNoContent {
+		t.Fatalf("delete second object: expected 204, got %d", code)
+	}
+	if s3.has("test", shared) {
+		t.Fatal("shared chunk survived the deletion of its last object")
+	}
+	if _, err := cas.db.Get([]byte(shared), nil); err == nil {
+		t.Fatal("shared chunk is still cached in LevelDB")
+	}
+}
+
+func TestDeleteUnreferencedChunk(t *testing.T) {
+	cas, s3 := newTestCAS(t)
+	router := newRouter(cas)
+
+	hash, err := cas.Store(context.Background(), []byte("standalone"))
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+
+	for _, want := range []int{http.StatusNoContent, http.StatusNotFound} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/retrieve/"+hash, nil))
+		if rec.Code != want {
+			t.Fatalf("expected %d, got %d: %s", want, rec.Code, rec.Body.String())
+		}
+	}
+	if s3.has("test", hash) {
+		t.Fatal("deleted chunk is still in MinIO")
+	}
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -89,13 +91,23 @@ func (r *LWWRegister) Merge(other *LWWRegister) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if other.timestamp > r.timestamp {
+	if r.supersededBy(other.timestamp, other.nodeID) {
 		r.value = other.value
 		r.timestamp = other.timestamp
 		r.nodeID = other.nodeID
 	}
 }
 
+// supersededBy reports whether a write at timestamp from nodeID supersedes the
+// current value. Equal timestamps go to the higher node ID so that every
+// replica picks the same write whichever order merges arrive in.
+func (r *LWWRegister) supersededBy(timestamp int64, nodeID string) bool {
+	if timestamp != r.timestamp {
+		return timestamp > r.timestamp
+	}
+	return nodeID > r.nodeID
+}
+
 // Get returns the current value
 func (r *LWWRegister) Get() interface{} {
 	r.mu.RLock()
@@ -103,11 +115,74 @@ func (r *LWWRegister) Get() interface{} {
 	return r.value
 }
 
-// Delta represents a CRDT delta for gossip
+// PNCounter represents a Positive-Negative Counter CRDT. Each node only
+// grows its own increment and decrement totals, so merging takes the
+// per-node maximum.
+type PNCounter struct {
+	inc map[string]int64
+	dec map[string]int64
+	mu  sync.RWMutex
+}
+
+// NewPNCounter creates a new PN-Counter
+func NewPNCounter() *PNCounter {
+	return &PNCounter{
+		inc: make(map[string]int64),
+		dec: make(map[string]int64),
+	}
+}
+
+// Add adds n, which may be negative, on behalf of nodeID
+func (pn *PNCounter) Add(nodeID string, n int64) {
+	pn.mu.Lock()
+	defer pn.mu.Unlock()
+	if n >= 0 {
+		pn.inc[nodeID] += n
+	} else {
+		pn.dec[nodeID] -= n
+	}
+}
+
+// MergeNode merges one node's totals
+func (pn *PNCounter) MergeNode(nodeID string, inc, dec int64) {
+	pn.mu.Lock()
+	defer pn.mu.Unlock()
+	if inc > pn.inc[nodeID] {
+		pn.inc[nodeID] = inc
+	}
+	if dec > pn.dec[nodeID] {
+		pn.dec[nodeID] = dec
+	}
+}
+
+// Totals returns nodeID's increment and decrement totals
+func (pn *PNCounter) Totals(nodeID string) (int64, int64) {
+	pn.mu.RLock()
+	defer pn.mu.RUnlock()
+	return pn.inc[nodeID], pn.dec[nodeID]
+}
+
+// Value returns the counter value
+func (pn *PNCounter) Value() int64 {
+	pn.mu.RLock()
+	defer pn.mu.RUnlock()
+	var total int64
+	for _, n := range pn.inc {
+		total += n
+	}
+	for _, n := range pn.dec {
+		total -= n
+	}
+	return total
+}
+
+// Delta represents a CRDT delta for gossip. Seq numbers the deltas of the
+// sending node in the order they were made, starting at 1.
 type Delta struct {
 	NodeID      string                 `json:"node_id"`
+	Seq         uint64                 `json:"seq,omitempty"`
 	VectorClock VectorClock            `json:"vector_clock"`
-	Type        string                 `json:"type"` // "orset" or "lww"
+	Type        string                 `json:"type"` // "orset", "lww" or "counter"
 	Key         string                 `json:"key"`
 	Data        map[string]interface{} `json:"data"`
 	Timestamp   int64                  `json:"timestamp"`
@@ -115,9 +190,12 @@ type Delta struct {
 
 // NewDelta creates a new delta
 func NewDelta(nodeID string, vc VectorClock, deltaType, key string, data map[string]interface{}) *Delta {
+	// Copy the clock so the delta keeps the time it was created at
+	clock := NewVectorClock()
+	clock.Merge(vc)
 	return &Delta{
 		NodeID:      nodeID,
-		VectorClock: vc,
+		VectorClock: clock,
 		Type:        deltaType,
 		Key:         key,
 		Data:        data,
@@ -149,8 +227,23 @@ type CRDTCatalog struct {
 	snapshotMetadata map[string]*LWWRegister // snapshotID -> metadata register
 	imageMetadata    map[string]*LWWRegister // imageID -> metadata register
 
-	// Pending deltas for gossip
-	deltas []*Delta
+	// Named registers and counters merged through the CRDT API
+	registers map[string]*LWWRegister
+	counters  map[string]*PNCounter
+
+	// CRDTs created by name at runtime
+	registry *CRDTRegistry
+
+	// Pending deltas for gossip, numbered up to lastSeq
+	deltas         []*Delta
+	lastSeq        uint64
+	peerAcks       map[string]uint64 // peer -> highest acknowledged seq
+	deltaRetention time.Duration
+
+	// Deltas applied from each other node, to drop replayed ones: every seq
+	// up to appliedSeqs, and those above it that arrived ahead of a gap
+	appliedSeqs  map[string]uint64
+	appliedAhead map[string]map[uint64]int64 // seq -> delta timestamp
 
 	mu sync.RWMutex
 }
@@ -164,7 +257,14 @@ func NewCRDTCatalog(nodeID string) *CRDTCatalog {
 		images:           NewORSet(),
 		snapshotMetadata: make(map[string]*LWWRegister),
 		imageMetadata:    make(map[string]*LWWRegister),
+		registers:        make(map[string]*LWWRegister),
+		counters:         make(map[string]*PNCounter),
+		registry:         NewCRDTRegistry(nodeID),
 		deltas:           make([]*Delta, 0),
+		peerAcks:         make(map[string]uint64),
+		deltaRetention:   defaultDeltaRetention,
+		appliedSeqs:      make(map[string]uint64),
+		appliedAhead:     make(map[string]map[uint64]int64),
 	}
 }
 
@@ -173,9 +273,22 @@ func (c *CRDTCatalog) AddSnapshot(snapshotID string, metadata map[string]interfa
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// Add to OR-Set
-	tag := c.snapshots.Add(snapshotID)
+	c.addSnapshot(snapshotID, c.snapshots.Add(snapshotID), metadata)
+}
+
+// AddSnapshotUnique adds a snapshot tagged with clientTag, typically its
+// content hash, so a retried add does not create a second tag that a
+// single remove would miss. See ORSet.AddUnique.
+func (c *CRDTCatalog) AddSnapshotUnique(snapshotID, clientTag string, metadata map[string]interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.addSnapshot(snapshotID, c.snapshots.AddUnique(snapshotID, clientTag), metadata)
+}
 
+// addSnapshot records metadata for a snapshot added under tag and queues
+// the delta
+func (c *CRDTCatalog) addSnapshot(snapshotID, tag string, metadata map[string]interface{}) {
 	// Update metadata LWW register
 	if c.snapshotMetadata[snapshotID] == nil {
 		c.snapshotMetadata[snapshotID] = NewLWWRegister(c.nodeID)
@@ -191,7 +304,7 @@ func (c *CRDTCatalog) AddSnapshot(snapshotID string, metadata map[string]interfa
 		"metadata": metadata,
 	}
 	delta := NewDelta(c.nodeID, c.vectorClock, "orset", "snapshots:"+snapshotID, deltaData)
-	c.deltas = append(c.deltas, delta)
+	c.queueDelta(delta)
 
 	fmt.Printf("Added snapshot %s with tag %s\n", snapshotID, tag)
 }
@@ -211,7 +324,7 @@ func (c *CRDTCatalog) RemoveSnapshot(snapshotID string) {
 		"removed": true,
 	}
 	delta := NewDelta(c.nodeID, c.vectorClock, "orset", "snapshots:"+snapshotID+":remove", deltaData)
-	c.deltas = append(c.deltas, delta)
+	c.queueDelta(delta)
 
 	fmt.Printf("Removed snapshot %s\n", snapshotID)
 }
@@ -231,7 +344,7 @@ func (c *CRDTCatalog) UpdateSnapshotMetadata(snapshotID string, metadata map[str
 
 	// Create delta
 	delta := NewDelta(c.nodeID, c.vectorClock, "lww", "snapshot_metadata:"+snapshotID, metadata)
-	c.deltas = append(c.deltas, delta)
+	c.queueDelta(delta)
 
 	fmt.Printf("Updated metadata for snapshot %s\n", snapshotID)
 }
@@ -255,7 +368,7 @@ func (c *CRDTCatalog) AddImage(imageID string, metadata map[string]interface{})
 		"metadata": metadata,
 	}
 	delta := NewDelta(c.nodeID, c.vectorClock, "orset", "images:"+imageID, deltaData)
-	c.deltas = append(c.deltas, delta)
+	c.queueDelta(delta)
 
 	fmt.Printf("Added image %s with tag %s\n", imageID, tag)
 }
@@ -317,13 +430,20 @@ func (c *CRDTCatalog) ApplyDelta(delta *Delta) bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// Check if delta is already applied (causal ordering)
-	comparison := c.vectorClock.Compare(delta.VectorClock)
-	if comparison > 0 {
+	if c.isReplay(delta) {
+		return false
+	}
+
+	// Check if delta is already applied (causal ordering). A numbered delta
+	// that arrives after a later one is behind our clock but still new, and
+	// isReplay has already ruled out seeing it before.
+	if delta.Seq == 0 && c.vectorClock.Compare(delta.VectorClock) > 0 {
 		// Our clock is ahead, ignore this delta
 		return false
 	}
 
+	c.markApplied(delta, time.Now())
+
 	// Update our vector clock
 	c.vectorClock.Merge(delta.VectorClock)
 	c.vectorClock.Increment(c.nodeID)
@@ -334,6 +454,10 @@ func (c *CRDTCatalog) ApplyDelta(delta *Delta) bool {
 		c.applyORSetDelta(delta)
 	case "lww":
 		c.applyLWWDelta(delta)
+	case "counter":
+		c.applyCounterDelta(delta)
+	case "registry":
+		c.applyRegistryDelta(delta)
 	}
 
 	return true
@@ -397,14 +521,200 @@ func (c *CRDTCatalog) applyLWWDelta(delta *Delta) {
 			timestamp: delta.Timestamp,
 			nodeID:    delta.NodeID,
 		})
+	case "register":
+		key := strings.TrimPrefix(delta.Key, "register:")
+		if c.registers[key] == nil {
+			c.registers[key] = NewLWWRegister(delta.NodeID)
+		}
+		c.registers[key].Merge(&LWWRegister{
+			value:     delta.Data["value"],
+			timestamp: delta.Timestamp,
+			nodeID:    delta.NodeID,
+		})
 	}
 }
 
-// ClearDeltas clears processed deltas
-func (c *CRDTCatalog) ClearDeltas() {
+// applyCounterDelta merges the sending node's counter totals
+func (c *CRDTCatalog) applyCounterDelta(delta *Delta) {
+	inc, _ := delta.Data["inc"].(float64)
+	dec, _ := delta.Data["dec"].(float64)
+	if c.counters[delta.Key] == nil {
+		c.counters[delta.Key] = NewPNCounter()
+	}
+	c.counters[delta.Key].MergeNode(delta.NodeID, int64(inc), int64(dec))
+}
+
+// CreateCRDT creates a named CRDT in the registry and queues a delta so
+// other nodes create it too
+func (c *CRDTCatalog) CreateCRDT(name, crdtType string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	created, err := c.registry.Create(name, crdtType)
+	if err != nil || !created {
+		return err
+	}
+	crdt, err := c.registry.Get(name)
+	if err != nil {
+		return err
+	}
+	return c.queueRegistryDelta(name, crdt)
+}
+
+// ApplyCRDTOp runs op on a named CRDT and returns its new value
+func (c *CRDTCatalog) ApplyCRDTOp(name string, op CRDTOp) (interface{}, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.deltas = c.deltas[:0]
+
+	crdt, err := c.registry.Apply(name, op)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.queueRegistryDelta(name, crdt); err != nil {
+		return nil, err
+	}
+	return crdt.Value(), nil
+}
+
+// CRDTValue returns the type and value of a named CRDT
+func (c *CRDTCatalog) CRDTValue(name string) (string, interface{}, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	crdt, err := c.registry.Get(name)
+	if err != nil {
+		return "", nil, err
+	}
+	return crdt.Type(), crdt.Value(), nil
+}
+
+// CRDTStats returns the type and size of a named CRDT
+func (c *CRDTCatalog) CRDTStats(name string) (string, CRDTStats, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.registry.Stats(name)
+}
+
+// CompactCRDT drops the tombstones of a named CRDT. Nothing is gossiped:
+// other nodes keep their tombstones until they are compacted as well.
+func (c *CRDTCatalog) CompactCRDT(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.registry.Compact(name)
+}
+
+// queueRegistryDelta queues the full state of a named CRDT for gossip
+func (c *CRDTCatalog) queueRegistryDelta(name string, crdt RegistryCRDT) error {
+	state, err := crdt.State()
+	if err != nil {
+		return err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(state, &decoded); err != nil {
+		return err
+	}
+
+	c.vectorClock.Increment(c.nodeID)
+	data := map[string]interface{}{"crdt_type": crdt.Type(), "state": decoded}
+	c.queueDelta(NewDelta(c.nodeID, c.vectorClock, "registry", name, data))
+	return nil
+}
+
+// applyRegistryDelta merges a named CRDT's state from another node
+func (c *CRDTCatalog) applyRegistryDelta(delta *Delta) {
+	crdtType, _ := delta.Data["crdt_type"].(string)
+	state, err := json.Marshal(delta.Data["state"])
+	if err != nil {
+		return
+	}
+	if err := c.registry.MergeState(delta.Key, crdtType, state); err != nil {
+		fmt.Printf("Failed to merge CRDT %s: %v\n", delta.Key, err)
+	}
+}
+
+// Merge applies a local update to the CRDT of the given type and returns
+// its merged value. "lww" sets the register key to value, "orset" adds
+// value to the "snapshots" or "images" set, and "counter" adds value to the
+// counter key.
+func (c *CRDTCatalog) Merge(crdtType, key string, value interface{}) (interface{}, error) {
+	if key == "" {
+		return nil, fmt.Errorf("key cannot be empty")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch crdtType {
+	case "lww":
+		if c.registers[key] == nil {
+			c.registers[key] = NewLWWRegister(c.nodeID)
+		}
+		c.registers[key].Set(value)
+		c.vectorClock.Increment(c.nodeID)
+		delta := NewDelta(c.nodeID, c.vectorClock, "lww", "register:"+key, map[string]interface{}{"value": value})
+		c.queueDelta(delta)
+		return c.registers[key].Get(), nil
+
+	case "orset":
+		item, ok := value.(string)
+		if !ok || item == "" {
+			return nil, fmt.Errorf("orset value must be a non-empty string")
+		}
+		var set *ORSet
+		switch key {
+		case "snapshots":
+			set = c.snapshots
+		case "images":
+			set = c.images
+		default:
+			return nil, fmt.Errorf("unknown set %q", key)
+		}
+		tag := set.Add(item)
+		c.vectorClock.Increment(c.nodeID)
+		delta := NewDelta(c.nodeID, c.vectorClock, "orset", key+":"+item, map[string]interface{}{"tag": tag})
+		c.queueDelta(delta)
+		return set.Contains(item), nil
+
+	case "counter":
+		n, err := counterValue(value)
+		if err != nil {
+			return nil, err
+		}
+		if c.counters[key] == nil {
+			c.counters[key] = NewPNCounter()
+		}
+		counter := c.counters[key]
+		counter.Add(c.nodeID, n)
+		c.vectorClock.Increment(c.nodeID)
+		inc, dec := counter.Totals(c.nodeID)
+		delta := NewDelta(c.nodeID, c.vectorClock, "counter", key, map[string]interface{}{"inc": inc, "dec": dec})
+		c.queueDelta(delta)
+		return counter.Value(), nil
+
+	default:
+		return nil, fmt.Errorf("unknown CRDT type %q", crdtType)
+	}
+}
+
+// counterValue accepts a JSON number or a numeric string, as sent by decubectl
+func counterValue(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case float64:
+		if v != float64(int64(v)) {
+			return 0, fmt.Errorf("counter value must be an integer")
+		}
+		return int64(v), nil
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("counter value must be an integer")
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("counter value must be an integer")
+	}
 }
 
 // addWithTag adds an item with a specific tag (for delta application)
@@ -2,13 +2,21 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 
+	"github.com/decube/httpserve"
 	"github.com/gorilla/mux"
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
 // CRDTService represents the CRDT catalog service
@@ -16,11 +24,14 @@ type CRDTService struct {
 	catalog *CRDTCatalog
 	db      *leveldb.DB
 	mu      sync.RWMutex
+
+	// debug serves the full catalog state on /debug/state
+	debug bool
 }
 
-// NewCRDTService creates a new CRDT service
-func NewCRDTService(nodeID string) (*CRDTService, error) {
-	db, err := leveldb.OpenFile("./crdt_catalog.db", nil)
+// NewCRDTService creates a new CRDT service persisted under dataDir
+func NewCRDTService(nodeID, dataDir string) (*CRDTService, error) {
+	db, err := openDB(dataDir, "crdt_catalog.db")
 	if err != nil {
 		return nil, err
 	}
@@ -43,6 +54,17 @@ func (s *CRDTService) loadState() {
 		json.Unmarshal(data, &s.catalog.vectorClock)
 	}
 
+	// Load delta sequence numbers so that peers do not take new deltas for replays
+	if data, err := s.db.Get([]byte("delta_seq"), nil); err == nil {
+		json.Unmarshal(data, &s.catalog.lastSeq)
+	}
+	if data, err := s.db.Get([]byte("applied_seqs"), nil); err == nil {
+		json.Unmarshal(data, &s.catalog.appliedSeqs)
+	}
+	if data, err := s.db.Get([]byte("applied_ahead"), nil); err == nil {
+		json.Unmarshal(data, &s.catalog.appliedAhead)
+	}
+
 	// Load OR-Sets
 	if data, err := s.db.Get([]byte("snapshots"), nil); err == nil {
 		s.catalog.snapshots.Deserialize(data)
@@ -51,9 +73,32 @@ func (s *CRDTService) loadState() {
 		s.catalog.images.Deserialize(data)
 	}
 
+	// Load named CRDTs
+	iter := s.db.NewIterator(util.BytesPrefix([]byte(registryKeyPrefix)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		var stored storedCRDT
+		if err := json.Unmarshal(iter.Value(), &stored); err != nil {
+			continue
+		}
+		name := strings.TrimPrefix(string(iter.Key()), registryKeyPrefix)
+		if err := s.catalog.registry.MergeState(name, stored.Type, stored.State); err != nil {
+			log.Printf("Failed to load CRDT %s: %v", name, err)
+		}
+	}
+
 	// Load metadata (simplified - in production, use proper serialization)
 }
 
+// registryKeyPrefix prefixes the database keys of named CRDTs
+const registryKeyPrefix = "crdt:"
+
+// storedCRDT is how a named CRDT is persisted
+type storedCRDT struct {
+	Type  string          `json:"type"`
+	State json.RawMessage `json:"state"`
+}
+
 // saveState persists the catalog state
 func (s *CRDTService) saveState() {
 	// Save vector clock
@@ -61,9 +106,35 @@ func (s *CRDTService) saveState() {
 		s.db.Put([]byte("vector_clock"), vcData, nil)
 	}
 
+	// Save delta sequence numbers
+	if seqData, err := json.Marshal(s.catalog.lastSeq); err == nil {
+		s.db.Put([]byte("delta_seq"), seqData, nil)
+	}
+	if appliedData, err := json.Marshal(s.catalog.appliedSeqs); err == nil {
+		s.db.Put([]byte("applied_seqs"), appliedData, nil)
+	}
+	if aheadData, err := json.Marshal(s.catalog.appliedAhead); err == nil {
+		s.db.Put([]byte("applied_ahead"), aheadData, nil)
+	}
+
 	// Save OR-Sets
 	s.db.Put([]byte("snapshots"), s.catalog.snapshots.Serialize(), nil)
 	s.db.Put([]byte("images"), s.catalog.images.Serialize(), nil)
+
+	// Save named CRDTs
+	for _, name := range s.catalog.registry.Names() {
+		crdt, err := s.catalog.registry.Get(name)
+		if err != nil {
+			continue
+		}
+		state, err := crdt.State()
+		if err != nil {
+			continue
+		}
+		if data, err := json.Marshal(storedCRDT{Type: crdt.Type(), State: state}); err == nil {
+			s.db.Put([]byte(registryKeyPrefix+name), data, nil)
+		}
+	}
 }
 
 // AddSnapshot adds a snapshot with metadata
@@ -75,6 +146,15 @@ func (s *CRDTService) AddSnapshot(snapshotID string, metadata map[string]interfa
 	s.saveState()
 }
 
+// AddSnapshotUnique adds a snapshot under an idempotency key
+func (s *CRDTService) AddSnapshotUnique(snapshotID, clientTag string, metadata map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.catalog.AddSnapshotUnique(snapshotID, clientTag, metadata)
+	s.saveState()
+}
+
 // RemoveSnapshot removes a snapshot
 func (s *CRDTService) RemoveSnapshot(snapshotID string) {
 	s.mu.Lock()
@@ -125,6 +205,22 @@ func (s *CRDTService) GetDeltas() []*Delta {
 	return s.catalog.GenerateDelta()
 }
 
+// DeltasSince returns the pending deltas numbered after seq for peerID
+func (s *CRDTService) DeltasSince(peerID string, seq uint64) []*Delta {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.catalog.DeltasSince(peerID, seq)
+}
+
+// AckDeltas records a peer's acknowledgment and clears what all peers have
+func (s *CRDTService) AckDeltas(peerID string, upToSeq uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.catalog.AckDeltas(peerID, upToSeq)
+}
+
 // ApplyDelta applies a received delta
 func (s *CRDTService) ApplyDelta(delta *Delta) bool {
 	s.mu.Lock()
@@ -137,6 +233,80 @@ func (s *CRDTService) ApplyDelta(delta *Delta) bool {
 	return applied
 }
 
+// Merge applies a local update to a CRDT and returns its merged value
+func (s *CRDTService) Merge(crdtType, key string, value interface{}) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	merged, err := s.catalog.Merge(crdtType, key, value)
+	if err != nil {
+		return nil, err
+	}
+	s.saveState()
+	return merged, nil
+}
+
+// CreateCRDT creates a named CRDT of the given type
+func (s *CRDTService) CreateCRDT(name, crdtType string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.catalog.CreateCRDT(name, crdtType); err != nil {
+		return err
+	}
+	s.saveState()
+	return nil
+}
+
+// ApplyCRDTOp runs an operation on a named CRDT and returns its new value
+func (s *CRDTService) ApplyCRDTOp(name string, op CRDTOp) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, err := s.catalog.ApplyCRDTOp(name, op)
+	if err != nil {
+		return nil, err
+	}
+	s.saveState()
+	return value, nil
+}
+
+// CRDTValue returns the type and value of a named CRDT
+func (s *CRDTService) CRDTValue(name string) (string, interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.catalog.CRDTValue(name)
+}
+
+// CRDTStats returns the type and size of a named CRDT
+func (s *CRDTService) CRDTStats(name string) (string, CRDTStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.catalog.CRDTStats(name)
+}
+
+// CompactCRDT drops the tombstones of a named CRDT
+func (s *CRDTService) CompactCRDT(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.catalog.CompactCRDT(name); err != nil {
+		return err
+	}
+	s.saveState()
+	return nil
+}
+
+// DebugState returns the full catalog state
+func (s *CRDTService) DebugState() DebugState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.catalog.DebugState()
+}
+
 // ClearDeltas clears processed deltas
 func (s *CRDTService) ClearDeltas() {
 	s.mu.Lock()
@@ -162,7 +332,12 @@ func (s *CRDTService) handleAddSnapshot(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	s.AddSnapshot(snapshotID, metadata)
+	// Retries that carry the same Idempotency-Key reuse one tag
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		s.AddSnapshotUnique(snapshotID, key, metadata)
+	} else {
+		s.AddSnapshot(snapshotID, metadata)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "added", "id": snapshotID})
 }
@@ -215,8 +390,21 @@ func (s *CRDTService) handleQuery(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(results)
 }
 
+// handleGetDeltas serves pending deltas. A peer that passes ?peer=<node id>
+// is tracked, and deltas are only cleared once it acknowledges them; ?since=
+// skips the deltas it already has.
 func (s *CRDTService) handleGetDeltas(w http.ResponseWriter, r *http.Request) {
-	deltas := s.GetDeltas()
+	var since uint64
+	if v := r.URL.Query().Get("since"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, "since must be a sequence number", http.StatusBadRequest)
+			return
+		}
+		since = n
+	}
+
+	deltas := s.DeltasSince(r.URL.Query().Get("peer"), since)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(deltas)
 }
@@ -234,22 +422,137 @@ func (s *CRDTService) handleApplyDelta(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-func (s *CRDTService) handleClearDeltas(w http.ResponseWriter, r *http.Request) {
-	s.ClearDeltas()
+func (s *CRDTService) handleAckDeltas(w http.ResponseWriter, r *http.Request) {
+	var ack DeltaAck
+	if err := json.NewDecoder(r.Body).Decode(&ack); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if ack.NodeID == "" {
+		http.Error(w, "node_id is required", http.StatusBadRequest)
+		return
+	}
+
+	s.AckDeltas(ack.NodeID, ack.UpToSeq)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "cleared"})
+	json.NewEncoder(w).Encode(map[string]int{"pending": len(s.GetDeltas())})
 }
 
-func main() {
-	nodeID := "node1" // In production, generate unique node ID
+// MergeRequest is the body of POST /api/v1/crdt/merge
+type MergeRequest struct {
+	Type  string      `json:"type"`
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+func (s *CRDTService) handleMerge(w http.ResponseWriter, r *http.Request) {
+	var req MergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
 
-	service, err := NewCRDTService(nodeID)
+	merged, err := s.Merge(req.Type, req.Key, req.Value)
 	if err != nil {
-		log.Fatalf("Failed to create CRDT service: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	defer service.Close()
 
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"type": req.Type, "key": req.Key, "value": merged})
+}
+
+// crdtError maps registry errors to HTTP status codes
+func crdtError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, errCRDTNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, errCRDTExists):
+		status = http.StatusConflict
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// writeCRDT responds with the current type and value of a named CRDT
+func (s *CRDTService) writeCRDT(w http.ResponseWriter, name string) {
+	crdtType, value, err := s.CRDTValue(name)
+	if err != nil {
+		crdtError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"name": name, "type": crdtType, "value": value})
+}
+
+func (s *CRDTService) handleCreateCRDT(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if err := s.CreateCRDT(name, r.URL.Query().Get("type")); err != nil {
+		crdtError(w, err)
+		return
+	}
+	s.writeCRDT(w, name)
+}
+
+func (s *CRDTService) handleGetCRDT(w http.ResponseWriter, r *http.Request) {
+	s.writeCRDT(w, mux.Vars(r)["name"])
+}
+
+func (s *CRDTService) handleCRDTOp(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var op CRDTOp
+	if err := json.NewDecoder(r.Body).Decode(&op); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.ApplyCRDTOp(name, op); err != nil {
+		crdtError(w, err)
+		return
+	}
+	s.writeCRDT(w, name)
+}
+
+// writeCRDTStats responds with the current type and size of a named CRDT
+func (s *CRDTService) writeCRDTStats(w http.ResponseWriter, name string) {
+	crdtType, stats, err := s.CRDTStats(name)
+	if err != nil {
+		crdtError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"name": name, "type": crdtType, "stats": stats})
+}
+
+func (s *CRDTService) handleCRDTStats(w http.ResponseWriter, r *http.Request) {
+	s.writeCRDTStats(w, mux.Vars(r)["name"])
+}
+
+func (s *CRDTService) handleCompactCRDT(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if err := s.CompactCRDT(name); err != nil {
+		crdtError(w, err)
+		return
+	}
+	s.writeCRDTStats(w, name)
+}
+
+func (s *CRDTService) handleDebugState(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.DebugState())
+}
+
+func (s *CRDTService) handleClearDeltas(w http.ResponseWriter, r *http.Request) {
+	s.ClearDeltas()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "cleared"})
+}
+
+// newCRDTRouter registers the CRDT catalog routes
+func newCRDTRouter(service *CRDTService) *mux.Router {
 	r := mux.NewRouter()
+	r.HandleFunc("/health", service.handleHealth).Methods("GET")
 
 	// Snapshot operations
 	r.HandleFunc("/snapshots/add/{id}", service.handleAddSnapshot).Methods("POST")
@@ -265,8 +568,57 @@ func main() {
 	// CRDT operations for gossip
 	r.HandleFunc("/crdt/delta", service.handleGetDeltas).Methods("GET")
 	r.HandleFunc("/crdt/delta", service.handleApplyDelta).Methods("POST")
+	r.HandleFunc("/crdt/delta/ack", service.handleAckDeltas).Methods("POST")
 	r.HandleFunc("/crdt/delta/clear", service.handleClearDeltas).Methods("POST")
 
-	fmt.Printf("CRDT Catalog service starting on :8080 (Node ID: %s)\n", nodeID)
-	log.Fatal(http.ListenAndServe(":8080", r))
+	// Merge endpoint used by decubectl crdt merge
+	r.HandleFunc("/api/v1/crdt/merge", service.handleMerge).Methods("POST")
+
+	// Named CRDTs, registered after /crdt/delta so that name stays reserved
+	r.HandleFunc("/crdt/{name}", service.handleCreateCRDT).Methods("POST")
+	r.HandleFunc("/crdt/{name}", service.handleGetCRDT).Methods("GET")
+	r.HandleFunc("/crdt/{name}/op", service.handleCRDTOp).Methods("POST")
+	r.HandleFunc("/crdt/{name}/stats", service.handleCRDTStats).Methods("GET")
+	r.HandleFunc("/crdt/{name}/compact", service.handleCompactCRDT).Methods("POST")
+
+	// The full state dump can be large, so it is only served with --debug
+	if service.debug {
+		r.HandleFunc("/debug/state", service.handleDebugState).Methods("GET")
+	}
+
+	return r
+}
+
+func main() {
+	envPort, err := httpserve.PortFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	debug := flag.Bool("debug", false, "serve the full catalog state on /debug/state")
+	port := flag.Int("port", envPort, "port to listen on (env PORT); 0 picks a free port")
+	flag.Parse()
+
+	nodeID := "node1" // In production, generate unique node ID
+
+	service, err := NewCRDTService(nodeID, dataDirFromEnv(filepath.Join("data", nodeID)))
+	if err != nil {
+		log.Fatalf("Failed to create CRDT service: %v", err)
+	}
+
+	service.debug = *debug
+	r := newCRDTRouter(service)
+
+	lis, err := net.Listen("tcp", httpserve.PortAddr(*port))
+	if err != nil {
+		log.Fatalf("Failed to listen on port %d: %v", *port, err)
+	}
+	fmt.Printf("CRDT Catalog service listening on %s (Node ID: %s)\n", lis.Addr(), nodeID)
+	err = httpserve.ServeUntilSignal(lis, r)
+
+	// Close the store only after in-flight requests have finished with it
+	service.Close()
+	if err != nil {
+		log.Fatalf("CRDT Catalog service failed: %v", err)
+	}
+	fmt.Println("CRDT Catalog service stopped")
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// postMerge sends a merge request through the handler and decodes the result
+func postMerge(t *testing.T, s *CRDTService, req MergeRequest) (int, map[string]interface{}) {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	rec := httptest.NewRecorder()
+	s.handleMerge(rec, httptest.NewRequest(http.MethodPost, "/api/v1/crdt/merge", bytes.NewReader(body)))
+
+	var result map[string]interface{}
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatalf("invalid merge response: %v", err)
+		}
+	}
+	return rec.Code, result
+}
+
+func TestMergeEndpoint(t *testing.T) {
+	service, err := NewCRDTService("node1", filepath.Join(t.TempDir(), "node1"))
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	code, result := postMerge(t, service, MergeRequest{Type: "lww", Key: "release", Value: "v1.2.0"})
+	if code != http.StatusOK || result["value"] != "v1.2.0" {
+		t.Fatalf("lww merge returned %d %v", code, result)
+	}
+	if got := service.catalog.registers["release"].Get(); got != "v1.2.0" {
+		t.Fatalf("expected stored register v1.2.0, got %v", got)
+	}
+
+	// decubectl sends the value as a string
+	postMerge(t, service, MergeRequest{Type: "counter", Key: "restores", Value: "3"})
+	code, result = postMerge(t, service, MergeRequest{Type: "counter", Key: "restores", Value: 2})
+	if code != http.StatusOK || result["value"] != float64(5) {
+		t.Fatalf("counter merge returned %d %v", code, result)
+	}
+	if got := service.catalog.counters["restores"].Value(); got != 5 {
+		t.Fatalf("expected stored counter 5, got %d", got)
+	}
+
+	code, _ = postMerge(t, service, MergeRequest{Type: "orset", Key: "snapshots", Value: "snap-1"})
+	if code != http.StatusOK || !service.catalog.snapshots.Contains("snap-1") {
+		t.Fatalf("orset merge returned %d", code)
+	}
+
+	// Every merge produces a delta for gossip
+	if deltas := service.GetDeltas(); len(deltas) != 4 {
+		t.Fatalf("expected 4 deltas, got %d", len(deltas))
+	}
+
+	for name, req := range map[string]MergeRequest{
+		"unknown type":    {Type: "gset", Key: "k", Value: "v"},
+		"empty key":       {Type: "lww", Value: "v"},
+		"non-int counter": {Type: "counter", Key: "k", Value: "many"},
+		"unknown set":     {Type: "orset", Key: "volumes", Value: "v"},
+	} {
+		if code, _ := postMerge(t, service, req); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, code)
+		}
+	}
+}
+
+func TestCounterDeltaConverges(t *testing.T) {
+	a, b := NewCRDTCatalog("a"), NewCRDTCatalog("b")
+	a.Merge("counter", "hits", "4")
+	b.Merge("counter", "hits", "-1")
+
+	for _, d := range a.GenerateDelta() {
+		b.ApplyDelta(roundTrip(t, d))
+	}
+	for _, d := range b.GenerateDelta() {
+		a.ApplyDelta(roundTrip(t, d))
+	}
+	if a.counters["hits"].Value() != 3 || b.counters["hits"].Value() != 3 {
+		t.Fatalf("counters diverged: a=%d b=%d", a.counters["hits"].Value(), b.counters["hits"].Value())
+	}
+}
+
+// roundTrip sends a delta through JSON the way gossip does
+func roundTrip(t *testing.T, d *Delta) *Delta {
+	t.Helper()
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("marshal delta: %v", err)
+	}
+	var out Delta
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("unmarshal delta: %v", err)
+	}
+	return &out
+}
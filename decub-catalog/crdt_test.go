@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestLWWRegisterTieBreaksOnNodeID(t *testing.T) {
+	const timestamp = 1700000000000000000
+	r1 := &LWWRegister{value: "from node1", timestamp: timestamp, nodeID: "node1"}
+	r2 := &LWWRegister{value: "from node2", timestamp: timestamp, nodeID: "node2"}
+
+	// Each side merges a copy of the other's state taken before any merge
+	r1.Merge(&LWWRegister{value: r2.value, timestamp: r2.timestamp, nodeID: r2.nodeID})
+	r2.Merge(&LWWRegister{value: r1.value, timestamp: r1.timestamp, nodeID: r1.nodeID})
+
+	if r1.Get() != "from node2" || r2.Get() != "from node2" {
+		t.Fatalf("registers did not converge on the higher node ID: %v, %v", r1.Get(), r2.Get())
+	}
+
+	r1.Merge(r2)
+	r2.Merge(r1)
+	if r1.Get() != "from node2" || r2.Get() != "from node2" {
+		t.Fatalf("repeated merges changed the value: %v, %v", r1.Get(), r2.Get())
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// CatalogItem is a live snapshot or image with its metadata
+type CatalogItem struct {
+	ID       string      `json:"id"`
+	Metadata interface{} `json:"metadata"`
+}
+
+// DebugState is a full dump of a catalog node, served by GET /debug/state.
+// Two nodes that have converged report the same MerkleRoot.
+type DebugState struct {
+	NodeID        string            `json:"node_id"`
+	VectorClock   VectorClock       `json:"vector_clock"`
+	Snapshots     []CatalogItem     `json:"snapshots"`
+	Images        []CatalogItem     `json:"images"`
+	PendingDeltas int               `json:"pending_deltas"`
+	LastSeq       uint64            `json:"last_seq"`
+	PeerAcks      map[string]uint64 `json:"peer_acks"`
+	MerkleRoot    string            `json:"merkle_root"`
+}
+
+// DebugState returns the full state of the catalog
+func (c *CRDTCatalog) DebugState() DebugState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	clock := NewVectorClock()
+	clock.Merge(c.vectorClock)
+	acks := make(map[string]uint64, len(c.peerAcks))
+	for peer, seq := range c.peerAcks {
+		acks[peer] = seq
+	}
+
+	state := DebugState{
+		NodeID:        c.nodeID,
+		VectorClock:   clock,
+		Snapshots:     liveItems(c.snapshots, c.snapshotMetadata),
+		Images:        liveItems(c.images, c.imageMetadata),
+		PendingDeltas: len(c.deltas),
+		LastSeq:       c.lastSeq,
+		PeerAcks:      acks,
+	}
+	state.MerkleRoot = hex.EncodeToString(catalogMerkleRoot(state.Snapshots, state.Images))
+	return state
+}
+
+// liveItems lists the items in set, sorted by ID, with their metadata
+func liveItems(set *ORSet, metadata map[string]*LWWRegister) []CatalogItem {
+	ids := set.Elements()
+	items := make([]CatalogItem, 0, len(ids))
+	for _, id := range ids {
+		item := CatalogItem{ID: id}
+		if reg := metadata[id]; reg != nil {
+			item.Metadata = reg.Get()
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// catalogMerkleRoot hashes every live snapshot and image, with its metadata,
+// into a Merkle tree. An odd node at any level is paired with itself.
+func catalogMerkleRoot(snapshots, images []CatalogItem) []byte {
+	var level [][]byte
+	for _, group := range []struct {
+		kind  string
+		items []CatalogItem
+	}{{"snapshot", snapshots}, {"image", images}} {
+		for _, item := range group.items {
+			metadata, _ := json.Marshal(item.Metadata)
+			leaf := sha256.Sum256([]byte(group.kind + "/" + item.ID + "\x00" + string(metadata)))
+			level = append(level, leaf[:])
+		}
+	}
+	if len(level) == 0 {
+		return make([]byte, sha256.Size)
+	}
+
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			right := level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			pair := sha256.Sum256(append(append([]byte{}, level[i]...), right...))
+			next = append(next, pair[:])
+		}
+		level = next
+	}
+	return level[0]
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDebugStateDumpsLiveItemsAndClock(t *testing.T) {
+	root := t.TempDir()
+	node := newCRDTNode(t, "node-a", filepath.Join(root, "a"))
+	defer node.service.Close()
+
+	// Without --debug the dump is not served
+	if code, _ := node.do(t, http.MethodGet, "/debug/state", nil); code != http.StatusNotFound {
+		t.Fatalf("expected /debug/state to be disabled, got %d", code)
+	}
+
+	node.service.debug = true
+	node.router = newCRDTRouter(node.service)
+
+	node.service.AddSnapshot("snap-1", map[string]interface{}{"size": 1024})
+	node.service.AddSnapshot("snap-2", nil)
+	node.service.AddImage("img-1", map[string]interface{}{"arch": "amd64"})
+	node.service.RemoveSnapshot("snap-2")
+
+	code, state := node.do(t, http.MethodGet, "/debug/state", nil)
+	if code != http.StatusOK {
+		t.Fatalf("debug state returned %d", code)
+	}
+
+	wantSnapshots := []interface{}{map[string]interface{}{"id": "snap-1", "metadata": map[string]interface{}{"size": float64(1024)}}}
+	if !reflect.DeepEqual(state["snapshots"], wantSnapshots) {
+		t.Errorf("expected snapshots %v, got %v", wantSnapshots, state["snapshots"])
+	}
+	wantImages := []interface{}{map[string]interface{}{"id": "img-1", "metadata": map[string]interface{}{"arch": "amd64"}}}
+	if !reflect.DeepEqual(state["images"], wantImages) {
+		t.Errorf("expected images %v, got %v", wantImages, state["images"])
+	}
+	if clock := state["vector_clock"].(map[string]interface{}); clock["node-a"] != float64(4) {
+		t.Errorf("expected node-a at 4 in the vector clock, got %v", clock)
+	}
+	if state["pending_deltas"] != float64(4) {
+		t.Errorf("expected 4 pending deltas, got %v", state["pending_deltas"])
+	}
+
+	// A node that has applied the same deltas reports the same Merkle root
+	other := newCRDTNode(t, "node-b", filepath.Join(root, "b"))
+	defer other.service.Close()
+	for _, delta := range node.service.GetDeltas() {
+		other.service.ApplyDelta(roundTrip(t, delta))
+	}
+	if root := other.service.DebugState().MerkleRoot; root != state["merkle_root"] {
+		t.Errorf("converged nodes report different Merkle roots: %s and %v", root, state["merkle_root"])
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import "time"
+
+// defaultDeltaRetention is how long a delta is kept for peers that have not
+// acknowledged it. Older deltas are cleared anyway, and a peer that falls that
+// far behind has to catch up from a full state sync.
+const defaultDeltaRetention = time.Hour
+
+// DeltaAck is the body of POST /crdt/delta/ack. It acknowledges every delta
+// from this node up to and including UpToSeq.
+type DeltaAck struct {
+	NodeID  string `json:"node_id"`
+	UpToSeq uint64 `json:"up_to_seq"`
+}
+
+// queueDelta numbers a local delta and queues it for gossip
+func (c *CRDTCatalog) queueDelta(delta *Delta) {
+	c.lastSeq++
+	delta.Seq = c.lastSeq
+	c.deltas = append(c.deltas, delta)
+}
+
+// DeltasSince returns the pending deltas numbered after seq and records
+// peerID, if set, as a peer whose acknowledgment ClearDeltas waits for
+func (c *CRDTCatalog) DeltasSince(peerID string, seq uint64) []*Delta {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, known := c.peerAcks[peerID]; peerID != "" && !known {
+		c.peerAcks[peerID] = 0
+	}
+
+	deltas := make([]*Delta, 0, len(c.deltas))
+	for _, delta := range c.deltas {
+		if delta.Seq > seq {
+			deltas = append(deltas, delta)
+		}
+	}
+	return deltas
+}
+
+// AckDeltas records that peerID has applied every delta up to upToSeq and
+// clears the deltas that all known peers have now acknowledged
+func (c *CRDTCatalog) AckDeltas(peerID string, upToSeq uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if upToSeq > c.lastSeq {
+		upToSeq = c.lastSeq
+	}
+	if upToSeq > c.peerAcks[peerID] {
+		c.peerAcks[peerID] = upToSeq
+	}
+	c.pruneDeltas(time.Now())
+}
+
+// ClearDeltas clears the deltas acknowledged by every known peer, and those
+// older than the retention period. With no known peers every delta is cleared.
+func (c *CRDTCatalog) ClearDeltas() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pruneDeltas(time.Now())
+}
+
+// pruneDeltas drops the deltas that no peer still needs
+func (c *CRDTCatalog) pruneDeltas(now time.Time) {
+	acked := c.lastSeq
+	for _, seq := range c.peerAcks {
+		if seq < acked {
+			acked = seq
+		}
+	}
+
+	cutoff := now.Add(-c.deltaRetention).UnixNano()
+	kept := c.deltas[:0]
+	for _, delta := range c.deltas {
+		if delta.Seq > acked && delta.Timestamp > cutoff {
+			kept = append(kept, delta)
+		}
+	}
+	for i := len(kept); i < len(c.deltas); i++ {
+		c.deltas[i] = nil
+	}
+	c.deltas = kept
+}
+
+// isReplay reports whether delta has already been applied. Deltas from a
+// node carry increasing sequence numbers but concurrent pushes can deliver
+// them out of order, so a number is seen if it is at or below the contiguous
+// watermark or among those applied ahead of a gap. Deltas without a sequence
+// number, from older nodes, are never treated as replays.
+func (c *CRDTCatalog) isReplay(delta *Delta) bool {
+	if delta.Seq == 0 {
+		return false
+	}
+	if delta.Seq <= c.appliedSeqs[delta.NodeID] {
+		return true
+	}
+	_, applied := c.appliedAhead[delta.NodeID][delta.Seq]
+	return applied
+}
+
+// markApplied records a numbered delta as applied, advancing its node's
+// watermark over any deltas that were waiting on the gap it fills. A gap
+// older than the retention period is skipped as well: the sender has
+// cleared the missing deltas by then, so they only arrive through a full
+// state sync, and waiting on them would keep every later seq forever.
+func (c *CRDTCatalog) markApplied(delta *Delta, now time.Time) {
+	if delta.Seq == 0 {
+		return
+	}
+
+	ahead := c.appliedAhead[delta.NodeID]
+	if ahead == nil {
+		ahead = make(map[uint64]int64)
+		c.appliedAhead[delta.NodeID] = ahead
+	}
+	ahead[delta.Seq] = delta.Timestamp
+
+	cutoff := now.Add(-c.deltaRetention).UnixNano()
+	for len(ahead) > 0 {
+		next := c.appliedSeqs[delta.NodeID] + 1
+		if _, applied := ahead[next]; applied {
+			delete(ahead, next)
+			c.appliedSeqs[delta.NodeID] = next
+			continue
+		}
+
+		// A node numbers its deltas in creation order, so the deltas missing
+		// below the lowest seq applied ahead are older than it
+		lowest := uint64(0)
+		for seq := range ahead {
+			if lowest == 0 || seq < lowest {
+				lowest = seq
+			}
+		}
+		if ahead[lowest] > cutoff {
+			break
+		}
+		delete(ahead, lowest)
+		c.appliedSeqs[delta.NodeID] = lowest
+	}
+	if len(ahead) == 0 {
+		delete(c.appliedAhead, delta.NodeID)
+	}
+}
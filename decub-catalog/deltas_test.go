@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// pullDeltas fetches n's pending deltas as peer would during gossip
+func (n *crdtNode) pullDeltas(t *testing.T, peer string) []*Delta {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	n.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/crdt/delta?peer="+peer, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("pulling deltas returned %d", rec.Code)
+	}
+	var deltas []*Delta
+	if err := json.Unmarshal(rec.Body.Bytes(), &deltas); err != nil {
+		t.Fatalf("invalid deltas: %v", err)
+	}
+	return deltas
+}
+
+func TestDeltaAcksClearOnlyAcknowledgedDeltas(t *testing.T) {
+	root := t.TempDir()
+	source := newCRDTNode(t, "node-a", filepath.Join(root, "a"))
+	peer := newCRDTNode(t, "node-b", filepath.Join(root, "b"))
+	defer source.service.Close()
+	defer peer.service.Close()
+
+	for _, id := range []string{"snap-1", "snap-2", "snap-3"} {
+		source.service.AddSnapshot(id, nil)
+	}
+
+	deltas := source.pullDeltas(t, "node-b")
+	if len(deltas) != 3 || deltas[0].Seq != 1 || deltas[2].Seq != 3 {
+		t.Fatalf("expected deltas numbered 1 to 3, got %+v", deltas)
+	}
+	// A second peer has pulled but not acknowledged anything yet
+	source.pullDeltas(t, "node-c")
+
+	for _, delta := range deltas[:2] {
+		if !peer.service.ApplyDelta(delta) {
+			t.Fatalf("delta %d was not applied", delta.Seq)
+		}
+	}
+	if peer.service.ApplyDelta(deltas[0]) {
+		t.Fatal("replayed delta 1 was applied again")
+	}
+
+	source.do(t, http.MethodPost, "/crdt/delta/ack", DeltaAck{NodeID: "node-b", UpToSeq: 2})
+	source.service.ClearDeltas()
+	if pending := source.service.GetDeltas(); len(pending) != 3 {
+		t.Fatalf("deltas were cleared before node-c acknowledged them: %d left", len(pending))
+	}
+
+	_, result := source.do(t, http.MethodPost, "/crdt/delta/ack", DeltaAck{NodeID: "node-c", UpToSeq: 3})
+	pending := source.service.GetDeltas()
+	if result["pending"] != float64(1) || len(pending) != 1 || pending[0].Seq != 3 {
+		t.Fatalf("expected only delta 3 to stay pending, got %+v", pending)
+	}
+
+	if code, _ := source.do(t, http.MethodPost, "/crdt/delta/ack", DeltaAck{UpToSeq: 3}); code != http.StatusBadRequest {
+		t.Errorf("ack without node_id: expected 400, got %d", code)
+	}
+}
+
+func TestOutOfOrderDeltasAreAppliedOnce(t *testing.T) {
+	root := t.TempDir()
+	source := newCRDTNode(t, "node-a", filepath.Join(root, "a"))
+	peer := newCRDTNode(t, "node-b", filepath.Join(root, "b"))
+	defer source.service.Close()
+
+	for _, id := range []string{"snap-1", "snap-2", "snap-3", "snap-4"} {
+		source.service.AddSnapshot(id, nil)
+	}
+	deltas := source.pullDeltas(t, "node-b")
+	if len(deltas) != 4 {
+		t.Fatalf("expected 4 deltas, got %d", len(deltas))
+	}
+
+	// Concurrent pushes deliver seq 3 before seq 1 and 2
+	for _, i := range []int{2, 0} {
+		if !peer.service.ApplyDelta(deltas[i]) {
+			t.Fatalf("delta %d was not applied", deltas[i].Seq)
+		}
+	}
+
+	// The gap at seq 2 survives a restart
+	peer.service.Close()
+	peer = newCRDTNode(t, "node-b", filepath.Join(root, "b"))
+	defer peer.service.Close()
+
+	for _, i := range []int{0, 2} {
+		if peer.service.ApplyDelta(deltas[i]) {
+			t.Fatalf("replayed delta %d was applied again", deltas[i].Seq)
+		}
+	}
+	for _, i := range []int{1, 3} {
+		if !peer.service.ApplyDelta(deltas[i]) {
+			t.Fatalf("delta %d was taken for a replay", deltas[i].Seq)
+		}
+	}
+	for _, delta := range deltas {
+		if peer.service.ApplyDelta(delta) {
+			t.Fatalf("replayed delta %d was applied again", delta.Seq)
+		}
+	}
+
+	for _, id := range []string{"snap-1", "snap-2", "snap-3", "snap-4"} {
+		if !peer.service.catalog.snapshots.Contains(id) {
+			t.Errorf("%s is missing after out-of-order delivery", id)
+		}
+	}
+	if seq := peer.service.catalog.appliedSeqs["node-a"]; seq != 4 {
+		t.Errorf("expected the watermark to reach 4, got %d", seq)
+	}
+}
+
+func TestGapOlderThanRetentionIsSkipped(t *testing.T) {
+	c := NewCRDTCatalog("node-b")
+	created := time.Now()
+
+	// Seq 1 and 2 are lost; 3 waits on them
+	c.markApplied(&Delta{NodeID: "node-a", Seq: 3, Timestamp: created.UnixNano()}, created)
+	if len(c.appliedAhead["node-a"]) != 1 || c.appliedSeqs["node-a"] != 0 {
+		t.Fatalf("a recent gap must be waited on, got watermark %d", c.appliedSeqs["node-a"])
+	}
+
+	// Once node-a has cleared them they can only come back in a state sync
+	later := created.Add(2 * defaultDeltaRetention)
+	c.markApplied(&Delta{NodeID: "node-a", Seq: 4, Timestamp: later.UnixNano()}, later)
+	if seq := c.appliedSeqs["node-a"]; seq != 4 {
+		t.Errorf("expected the watermark to skip the stale gap to 4, got %d", seq)
+	}
+	if _, waiting := c.appliedAhead["node-a"]; waiting {
+		t.Errorf("expected nothing left applied ahead, got %v", c.appliedAhead["node-a"])
+	}
+}
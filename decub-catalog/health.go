@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+var startTime = time.Now()
+
+// HealthResponse is returned by the /health endpoint
+type HealthResponse struct {
+	Status        string `json:"status"`
+	Service       string `json:"service"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+	Error         string `json:"error,omitempty"`
+}
+
+// writeHealth reports the service as healthy, or as unavailable with 503 when readyErr is set
+func writeHealth(w http.ResponseWriter, service string, readyErr error) {
+	resp := HealthResponse{
+		Status:        "healthy",
+		Service:       service,
+		UptimeSeconds: int64(time.Since(startTime).Seconds()),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if readyErr != nil {
+		resp.Status = "unhealthy"
+		resp.Error = readyErr.Error()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// dbReady checks that a LevelDB handle is still open
+func dbReady(db *leveldb.DB) error {
+	if _, err := db.GetProperty("leveldb.stats"); err != nil {
+		return fmt.Errorf("leveldb: %w", err)
+	}
+	return nil
+}
+
+func (c *Catalog) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, "decub-catalog", dbReady(c.db))
+}
+
+func (s *CRDTService) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, "decub-catalog", dbReady(s.db))
+}
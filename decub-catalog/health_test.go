@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+func openTestDB(t *testing.T) *leveldb.DB {
+	t.Helper()
+	db, err := leveldb.OpenFile(filepath.Join(t.TempDir(), "catalog.db"), nil)
+	if err != nil {
+		t.Fatalf("failed to open leveldb: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func checkHealth(t *testing.T, handler http.HandlerFunc, wantCode int) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != wantCode {
+		t.Fatalf("expected %d, got %d: %s", wantCode, rec.Code, rec.Body.String())
+	}
+
+	var health HealthResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+		t.Fatalf("invalid health response: %v", err)
+	}
+	if health.Service != "decub-catalog" {
+		t.Fatalf("unexpected service name %q", health.Service)
+	}
+}
+
+func TestCatalogHealth(t *testing.T) {
+	catalog := &Catalog{snapshots: NewORSet(), images: NewORSet(), db: openTestDB(t)}
+
+	checkHealth(t, catalog.handleHealth, http.StatusOK)
+	catalog.db.Close()
+	checkHealth(t, catalog.handleHealth, http.StatusServiceUnavailable)
+}
+
+func TestCRDTServiceHealth(t *testing.T) {
+	service := &CRDTService{catalog: NewCRDTCatalog("node1"), db: openTestDB(t)}
+
+	checkHealth(t, service.handleHealth, http.StatusOK)
+	service.db.Close()
+	checkHealth(t, service.handleHealth, http.StatusServiceUnavailable)
+}
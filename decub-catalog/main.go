@@ -2,13 +2,19 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
 
+	"github.com/decube/httpserve"
 	"github.com/gorilla/mux"
 	"github.com/syndtr/goleveldb/leveldb"
 )
@@ -41,6 +47,24 @@ func (s *ORSet) Add(item string) string {
 	return tag
 }
 
+// AddUnique adds an item under a caller-supplied tag, such as the
+// snapshot's content hash, so retrying the same add is a no-op.
+//
+// Add gives every call a fresh tag, so an add concurrent with a remove wins,
+// but each retried add needs its own remove. AddUnique deduplicates retries
+// instead: a remove covers all of them, and re-adding with a tag that was
+// already removed leaves the item removed; use a new tag to add it back.
+func (s *ORSet) AddUnique(item, clientTag string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.addSet[item] == nil {
+		s.addSet[item] = make(map[string]bool)
+	}
+	s.addSet[item][clientTag] = true
+	return clientTag
+}
+
 // Remove removes an item from the set
 func (s *ORSet) Remove(item string) {
 	s.mu.Lock()
@@ -73,6 +97,24 @@ func (s *ORSet) Contains(item string) bool {
 	return false
 }
 
+// Elements returns the items in the set, sorted
+func (s *ORSet) Elements() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	items := make([]string, 0, len(s.addSet))
+	for item, tags := range s.addSet {
+		for tag := range tags {
+			if !s.rmSet[item][tag] {
+				items = append(items, item)
+				break
+			}
+		}
+	}
+	sort.Strings(items)
+	return items
+}
+
 // Merge merges another OR-Set into this one
 func (s *ORSet) Merge(other *ORSet) {
 	s.mu.Lock()
@@ -97,6 +139,43 @@ func (s *ORSet) Merge(other *ORSet) {
 	}
 }
 
+// tagCounts returns the number of add tags that have not been removed and the
+// number of removed tags kept as tombstones
+func (s *ORSet) tagCounts() (live, tombstones int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for item, tags := range s.addSet {
+		for tag := range tags {
+			if !s.rmSet[item][tag] {
+				live++
+			}
+		}
+	}
+	for _, tags := range s.rmSet {
+		tombstones += len(tags)
+	}
+	return live, tombstones
+}
+
+// Compact drops removed add tags together with their tombstones. It is only
+// safe once every replica has merged the removes: merging a replica that
+// still holds a dropped add tag would bring the item back.
+func (s *ORSet) Compact() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for item, tags := range s.rmSet {
+		for tag := range tags {
+			delete(s.addSet[item], tag)
+		}
+		if len(s.addSet[item]) == 0 {
+			delete(s.addSet, item)
+		}
+		delete(s.rmSet, item)
+	}
+}
+
 // Serialize serializes the OR-Set
 func (s *ORSet) Serialize() []byte {
 	s.mu.RLock()
@@ -148,9 +227,9 @@ type Catalog struct {
 	db        *leveldb.DB
 }
 
-// NewCatalog creates a new catalog
-func NewCatalog() (*Catalog, error) {
-	db, err := leveldb.OpenFile("./catalog.db", nil)
+// NewCatalog creates a new catalog persisted under dataDir
+func NewCatalog(dataDir string) (*Catalog, error) {
+	db, err := openDB(dataDir, "catalog.db")
 	if err != nil {
 		return nil, err
 	}
@@ -181,6 +260,15 @@ func (c *Catalog) AddSnapshot(snapshotID string) {
 	fmt.Printf("Added snapshot %s with tag %s\n", snapshotID, tag)
 }
 
+// AddSnapshotUnique adds a snapshot under an idempotency key, so retries
+// share one tag. See ORSet.AddUnique.
+func (c *Catalog) AddSnapshotUnique(snapshotID, clientTag string) {
+	tag := c.snapshots.AddUnique(snapshotID, clientTag)
+	c.save("snapshots")
+
+	fmt.Printf("Added snapshot %s with tag %s\n", snapshotID, tag)
+}
+
 // RemoveSnapshot removes a snapshot from the catalog
 func (c *Catalog) RemoveSnapshot(snapshotID string) {
 	c.snapshots.Remove(snapshotID)
@@ -242,7 +330,11 @@ func (c *Catalog) Close() error {
 func (c *Catalog) handleAddSnapshot(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	snapshotID := vars["id"]
-	c.AddSnapshot(snapshotID)
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		c.AddSnapshotUnique(snapshotID, key)
+	} else {
+		c.AddSnapshot(snapshotID)
+	}
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprintf(w, "Snapshot %s added", snapshotID)
 }
@@ -294,14 +386,25 @@ func (c *Catalog) handleMerge(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, "Catalog merged")
 }
 
-func main() {
-	catalog, err := NewCatalog()
-	if err != nil {
-		log.Fatalf("Failed to create catalog: %v", err)
+// openDB opens the LevelDB database name inside dataDir, creating the directory if needed
+func openDB(dataDir, name string) (*leveldb.DB, error) {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data dir %s: %w", dataDir, err)
 	}
-	defer catalog.Close()
+	return leveldb.OpenFile(filepath.Join(dataDir, name), nil)
+}
 
+// dataDirFromEnv returns DECUB_DATA_DIR, or fallback when it is unset
+func dataDirFromEnv(fallback string) string {
+	if dataDir := os.Getenv("DECUB_DATA_DIR"); dataDir != "" {
+		return dataDir
+	}
+	return fallback
+}
+
+func newRouter(catalog *Catalog) *mux.Router {
 	r := mux.NewRouter()
+	r.HandleFunc("/health", catalog.handleHealth).Methods("GET")
 	r.HandleFunc("/snapshots/add/{id}", catalog.handleAddSnapshot).Methods("POST")
 	r.HandleFunc("/snapshots/remove/{id}", catalog.handleRemoveSnapshot).Methods("DELETE")
 	r.HandleFunc("/images/add/{id}", catalog.handleAddImage).Methods("POST")
@@ -309,7 +412,33 @@ func main() {
 	r.HandleFunc("/snapshots/query", catalog.handleQuerySnapshots).Methods("GET")
 	r.HandleFunc("/images/query", catalog.handleQueryImages).Methods("GET")
 	r.HandleFunc("/merge", catalog.handleMerge).Methods("POST")
+	return r
+}
+
+func main() {
+	envPort, err := httpserve.PortFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	port := flag.Int("port", envPort, "port to listen on (env PORT); 0 picks a free port")
+	flag.Parse()
 
-	fmt.Println("Catalog server starting on :8080")
-	log.Fatal(http.ListenAndServe(":8080", r))
+	catalog, err := NewCatalog(dataDirFromEnv("data"))
+	if err != nil {
+		log.Fatalf("Failed to create catalog: %v", err)
+	}
+
+	lis, err := net.Listen("tcp", httpserve.PortAddr(*port))
+	if err != nil {
+		log.Fatalf("Failed to listen on port %d: %v", *port, err)
+	}
+	fmt.Printf("Catalog server listening on %s\n", lis.Addr())
+	err = httpserve.ServeUntilSignal(lis, newRouter(catalog))
+
+	// Close the store only after in-flight requests have finished with it
+	catalog.Close()
+	if err != nil {
+		log.Fatalf("Catalog server failed: %v", err)
+	}
+	fmt.Println("Catalog server stopped")
 }
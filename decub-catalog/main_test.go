@@ -0,0 +1,71 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTwoNodesUseSeparateDataDirs(t *testing.T) {
+	root := t.TempDir()
+
+	first, err := NewCRDTService("node1", filepath.Join(root, "node1"))
+	if err != nil {
+		t.Fatalf("first node: %v", err)
+	}
+	defer first.Close()
+
+	// The second node would fail to take the LevelDB lock if it shared the first node's directory
+	second, err := NewCRDTService("node2", filepath.Join(root, "node2"))
+	if err != nil {
+		t.Fatalf("second node: %v", err)
+	}
+	defer second.Close()
+
+	catalog, err := NewCatalog(filepath.Join(root, "catalog"))
+	if err != nil {
+		t.Fatalf("catalog: %v", err)
+	}
+	defer catalog.Close()
+
+	if _, err := NewCRDTService("node1", filepath.Join(root, "node1")); err == nil {
+		t.Fatal("expected reopening a data dir in use to fail on the LevelDB lock")
+	}
+}
+
+func TestAddUniqueDeduplicatesRetries(t *testing.T) {
+	set := NewORSet()
+
+	// A retried add with the same client tag leaves a single tag behind
+	set.AddUnique("snap-1", "sha256:abc")
+	set.AddUnique("snap-1", "sha256:abc")
+	set.Remove("snap-1")
+	if set.Contains("snap-1") {
+		t.Fatal("snap-1 still present after removing an idempotent add")
+	}
+
+	// Classic adds each get their own tag, so a remove only covers the
+	// tags it observed and a concurrent add survives
+	set.Add("snap-2")
+	other := NewORSet()
+	other.Merge(set)
+	other.Remove("snap-2")
+	set.Add("snap-2")
+	set.Merge(other)
+	if !set.Contains("snap-2") {
+		t.Fatal("add concurrent with a remove should win")
+	}
+}
+
+func TestAddSnapshotUniqueThroughDeltas(t *testing.T) {
+	a, b := NewCRDTCatalog("a"), NewCRDTCatalog("b")
+	a.AddSnapshotUnique("snap-1", "sha256:abc", nil)
+	a.AddSnapshotUnique("snap-1", "sha256:abc", nil)
+	for _, d := range a.GenerateDelta() {
+		b.ApplyDelta(roundTrip(t, d))
+	}
+
+	b.RemoveSnapshot("snap-1")
+	if b.snapshots.Contains("snap-1") {
+		t.Fatal("snap-1 still present on b after one remove")
+	}
+}
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	errCRDTNotFound = errors.New("CRDT not found")
+	errCRDTExists   = errors.New("CRDT already exists with a different type")
+)
+
+// CRDTOp is an operation on a registry CRDT. Key is only used by ormap.
+//
+//	gcounter:  increment
+//	pncounter: increment, decrement
+//	lww:       set
+//	orset:     add, remove
+//	ormap:     put, remove
+type CRDTOp struct {
+	Op    string      `json:"op"`
+	Key   string      `json:"key,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// RegistryCRDT is a named CRDT held in the registry. Replicas exchange
+// their full state, which Merge combines with the local one.
+type RegistryCRDT interface {
+	Type() string
+	Apply(nodeID string, op CRDTOp) error
+	Value() interface{}
+	State() ([]byte, error)
+	Merge(state []byte) error
+	Stats() CRDTStats
+}
+
+// CRDTStats describes the size of a named CRDT. Tombstones are the removal
+// markers kept so that removes converge; only compaction drops them.
+type CRDTStats struct {
+	EntryCount     int `json:"entry_count"`
+	TombstoneCount int `json:"tombstone_count"`
+	ApproxBytes    int `json:"approx_bytes"` // size of the replicated state
+}
+
+// compactable is implemented by CRDTs that keep tombstones
+type compactable interface {
+	Compact()
+}
+
+// sizedStats fills in the state size of crdt
+func sizedStats(crdt RegistryCRDT, entries, tombstones int) CRDTStats {
+	state, _ := crdt.State()
+	return CRDTStats{EntryCount: entries, TombstoneCount: tombstones, ApproxBytes: len(state)}
+}
+
+// newRegistryCRDT creates an empty CRDT of the given type
+func newRegistryCRDT(crdtType, nodeID string) (RegistryCRDT, error) {
+	switch crdtType {
+	case "gcounter":
+		return &gCounterCRDT{counts: make(map[string]int64)}, nil
+	case "pncounter":
+		return &pnCounterCRDT{NewPNCounter()}, nil
+	case "lww":
+		return &lwwCRDT{NewLWWRegister(nodeID)}, nil
+	case "orset":
+		return &orSetCRDT{NewORSet()}, nil
+	case "ormap":
+		return &orMapCRDT{keys: NewORSet(), values: make(map[string]*LWWRegister)}, nil
+	default:
+		return nil, fmt.Errorf("unknown CRDT type %q", crdtType)
+	}
+}
+
+// CRDTRegistry holds arbitrary named CRDTs created at runtime
+type CRDTRegistry struct {
+	nodeID string
+	crdts  map[string]RegistryCRDT
+	mu     sync.RWMutex
+}
+
+// NewCRDTRegistry creates an empty registry
+func NewCRDTRegistry(nodeID string) *CRDTRegistry {
+	return &CRDTRegistry{
+		nodeID: nodeID,
+		crdts:  make(map[string]RegistryCRDT),
+	}
+}
+
+// Create adds an empty CRDT called name. It reports whether the CRDT was
+// new; creating an existing CRDT with the same type is a no-op.
+func (r *CRDTRegistry) Create(name, crdtType string) (bool, error) {
+	if name == "" || name == "delta" {
+		return false, fmt.Errorf("invalid CRDT name %q", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.crdts[name]; ok {
+		if existing.Type() != crdtType {
+			return false, fmt.Errorf("%s is a %s: %w", name, existing.Type(), errCRDTExists)
+		}
+		return false, nil
+	}
+	crdt, err := newRegistryCRDT(crdtType, r.nodeID)
+	if err != nil {
+		return false, err
+	}
+	r.crdts[name] = crdt
+	return true, nil
+}
+
+// Get returns the CRDT called name
+func (r *CRDTRegistry) Get(name string) (RegistryCRDT, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	crdt, ok := r.crdts[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, errCRDTNotFound)
+	}
+	return crdt, nil
+}
+
+// Apply runs op on the CRDT called name
+func (r *CRDTRegistry) Apply(name string, op CRDTOp) (RegistryCRDT, error) {
+	crdt, err := r.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := crdt.Apply(r.nodeID, op); err != nil {
+		return nil, err
+	}
+	return crdt, nil
+}
+
+// Stats returns the type and size of the CRDT called name
+func (r *CRDTRegistry) Stats(name string) (string, CRDTStats, error) {
+	crdt, err := r.Get(name)
+	if err != nil {
+		return "", CRDTStats{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return crdt.Type(), crdt.Stats(), nil
+}
+
+// Compact drops the tombstones of the CRDT called name. CRDTs without
+// tombstones are left as they are.
+func (r *CRDTRegistry) Compact(name string) error {
+	crdt, err := r.Get(name)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := crdt.(compactable); ok {
+		c.Compact()
+	}
+	return nil
+}
+
+// MergeState merges a replica's state into the CRDT called name, creating
+// it if this node has not seen it yet
+func (r *CRDTRegistry) MergeState(name, crdtType string, state []byte) error {
+	if _, err := r.Create(name, crdtType); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.crdts[name].Merge(state)
+}
+
+// Names returns the registered CRDT names in order
+func (r *CRDTRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.crdts))
+	for name := range r.crdts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// gCounterCRDT is a grow-only counter
+type gCounterCRDT struct {
+	counts map[string]int64
+}
+
+func (g *gCounterCRDT) Type() string { return "gcounter" }
+
+func (g *gCounterCRDT) Apply(nodeID string, op CRDTOp) error {
+	if op.Op != "increment" {
+		return fmt.Errorf("unknown gcounter operation %q", op.Op)
+	}
+	n, err := counterValue(op.Value)
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return fmt.Errorf("gcounter cannot be decremented")
+	}
+	g.counts[nodeID] += n
+	return nil
+}
+
+func (g *gCounterCRDT) Value() interface{} {
+	var total int64
+	for _, n := range g.counts {
+		total += n
+	}
+	return total
+}
+
+func (g *gCounterCRDT) State() ([]byte, error) {
+	return json.Marshal(g.counts)
+}
+
+func (g *gCounterCRDT) Merge(state []byte) error {
+	var counts map[string]int64
+	if err := json.Unmarshal(state, &counts); err != nil {
+		return err
+	}
+	for node, n := range counts {
+		if n > g.counts[node] {
+			g.counts[node] = n
+		}
+	}
+	return nil
+}
+
+func (g *gCounterCRDT) Stats() CRDTStats { return sizedStats(g, len(g.counts), 0) }
+
+// pnCounterCRDT exposes a PNCounter through the registry
+type pnCounterCRDT struct {
+	*PNCounter
+}
+
+type pnCounterState struct {
+	Inc map[string]int64 `json:"inc"`
+	Dec map[string]int64 `json:"dec"`
+}
+
+func (pn *pnCounterCRDT) Type() string { return "pncounter" }
+
+func (pn *pnCounterCRDT) Apply(nodeID string, op CRDTOp) error {
+	n, err := counterValue(op.Value)
+	if err != nil {
+		return err
+	}
+	switch op.Op {
+	case "increment":
+		pn.Add(nodeID, n)
+	case "decrement":
+		pn.Add(nodeID, -n)
+	default:
+		return fmt.Errorf("unknown pncounter operation %q", op.Op)
+	}
+	return nil
+}
+
+func (pn *pnCounterCRDT) Value() interface{} { return pn.PNCounter.Value() }
+
+func (pn *pnCounterCRDT) State() ([]byte, error) {
+	pn.mu.RLock()
+	defer pn.mu.RUnlock()
+	return json.Marshal(pnCounterState{Inc: pn.inc, Dec: pn.dec})
+}
+
+func (pn *pnCounterCRDT) Merge(state []byte) error {
+	var s pnCounterState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return err
+	}
+	for node, inc := range s.Inc {
+		pn.MergeNode(node, inc, 0)
+	}
+	for node, dec := range s.Dec {
+		pn.MergeNode(node, 0, dec)
+	}
+	return nil
+}
+
+func (pn *pnCounterCRDT) Stats() CRDTStats {
+	pn.mu.RLock()
+	entries := len(pn.inc) + len(pn.dec)
+	pn.mu.RUnlock()
+	return sizedStats(pn, entries, 0)
+}
+
+// lwwCRDT exposes an LWWRegister through the registry
+type lwwCRDT struct {
+	*LWWRegister
+}
+
+type lwwState struct {
+	Value     interface{} `json:"value"`
+	Timestamp int64       `json:"timestamp"`
+	NodeID    string      `json:"node_id"`
+}
+
+func (l *lwwCRDT) Type() string { return "lww" }
+
+func (l *lwwCRDT) Apply(nodeID string, op CRDTOp) error {
+	if op.Op != "set" {
+		return fmt.Errorf("unknown lww operation %q", op.Op)
+	}
+	l.Set(op.Value)
+	return nil
+}
+
+func (l *lwwCRDT) Value() interface{} { return l.Get() }
+
+func (l *lwwCRDT) State() ([]byte, error) {
+	return json.Marshal(registerState(l.LWWRegister))
+}
+
+func (l *lwwCRDT) Merge(state []byte) error {
+	var s lwwState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return err
+	}
+	l.LWWRegister.Merge(&LWWRegister{value: s.Value, timestamp: s.Timestamp, nodeID: s.NodeID})
+	return nil
+}
+
+func (l *lwwCRDT) Stats() CRDTStats {
+	entries := 0
+	if registerState(l.LWWRegister).Timestamp != 0 {
+		entries = 1
+	}
+	return sizedStats(l, entries, 0)
+}
+
+func registerState(r *LWWRegister) lwwState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return lwwState{Value: r.value, Timestamp: r.timestamp, NodeID: r.nodeID}
+}
+
+// orSetCRDT exposes an ORSet through the registry
+type orSetCRDT struct {
+	*ORSet
+}
+
+func (s *orSetCRDT) Type() string { return "orset" }
+
+func (s *orSetCRDT) Apply(nodeID string, op CRDTOp) error {
+	item, ok := op.Value.(string)
+	if !ok || item == "" {
+		return fmt.Errorf("orset value must be a non-empty string")
+	}
+	switch op.Op {
+	case "add":
+		s.Add(item)
+	case "remove":
+		s.Remove(item)
+	default:
+		return fmt.Errorf("unknown orset operation %q", op.Op)
+	}
+	return nil
+}
+
+func (s *orSetCRDT) Value() interface{} { return s.Elements() }
+
+func (s *orSetCRDT) State() ([]byte, error) { return s.Serialize(), nil }
+
+func (s *orSetCRDT) Merge(state []byte) error {
+	other := NewORSet()
+	other.Deserialize(state)
+	s.ORSet.Merge(other)
+	return nil
+}
+
+func (s *orSetCRDT) Stats() CRDTStats {
+	live, tombstones := s.tagCounts()
+	return sizedStats(s, live, tombstones)
+}
+
+// orMapCRDT maps keys held in an OR-Set to LWW registers: a put after a
+// concurrent remove keeps the key, and concurrent puts keep the later value
+type orMapCRDT struct {
+	keys   *ORSet
+	values map[string]*LWWRegister
+}
+
+type orMapState struct {
+	Keys   json.RawMessage     `json:"keys"`
+	Values map[string]lwwState `json:"values"`
+}
+
+func (m *orMapCRDT) Type() string { return "ormap" }
+
+func (m *orMapCRDT) Apply(nodeID string, op CRDTOp) error {
+	if op.Key == "" {
+		return fmt.Errorf("ormap operations need a key")
+	}
+	switch op.Op {
+	case "put":
+		m.keys.Add(op.Key)
+		if m.values[op.Key] == nil {
+			m.values[op.Key] = NewLWWRegister(nodeID)
+		}
+		m.values[op.Key].Set(op.Value)
+	case "remove":
+		m.keys.Remove(op.Key)
+	default:
+		return fmt.Errorf("unknown ormap operation %q", op.Op)
+	}
+	return nil
+}
+
+func (m *orMapCRDT) Value() interface{} {
+	value := make(map[string]interface{})
+	for _, key := range m.keys.Elements() {
+		if reg := m.values[key]; reg != nil {
+			value[key] = reg.Get()
+		}
+	}
+	return value
+}
+
+func (m *orMapCRDT) State() ([]byte, error) {
+	values := make(map[string]lwwState, len(m.values))
+	for key, reg := range m.values {
+		values[key] = registerState(reg)
+	}
+	return json.Marshal(orMapState{Keys: m.keys.Serialize(), Values: values})
+}
+
+func (m *orMapCRDT) Merge(state []byte) error {
+	var s orMapState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return err
+	}
+	keys := NewORSet()
+	keys.Deserialize(s.Keys)
+	m.keys.Merge(keys)
+	for key, v := range s.Values {
+		if m.values[key] == nil {
+			m.values[key] = NewLWWRegister(v.NodeID)
+		}
+		m.values[key].Merge(&LWWRegister{value: v.Value, timestamp: v.Timestamp, nodeID: v.NodeID})
+	}
+	return nil
+}
+
+// Stats counts the live key tags plus one entry per value register
+func (m *orMapCRDT) Stats() CRDTStats {
+	live, tombstones := m.keys.tagCounts()
+	return sizedStats(m, live+len(m.values), tombstones)
+}
+
+// Compact compacts the key set and drops the values of removed keys
+func (m *orMapCRDT) Compact() {
+	m.keys.Compact()
+	for key := range m.values {
+		if !m.keys.Contains(key) {
+			delete(m.values, key)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// crdtNode is a CRDT service behind its router
+type crdtNode struct {
+	service *CRDTService
+	router  http.Handler
+}
+
+func newCRDTNode(t *testing.T, nodeID, dir string) *crdtNode {
+	t.Helper()
+	service, err := NewCRDTService(nodeID, dir)
+	if err != nil {
+		t.Fatalf("failed to create %s: %v", nodeID, err)
+	}
+	return &crdtNode{service: service, router: newCRDTRouter(service)}
+}
+
+// do sends a request and decodes a JSON object response
+func (n *crdtNode) do(t *testing.T, method, path string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	rec := httptest.NewRecorder()
+	n.router.ServeHTTP(rec, httptest.NewRequest(method, path, reader))
+
+	var result map[string]interface{}
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatalf("invalid response from %s %s: %v", method, path, err)
+		}
+	}
+	return rec.Code, result
+}
+
+// syncTo applies n's pending deltas on other, as gossip would
+func (n *crdtNode) syncTo(t *testing.T, other *crdtNode) {
+	t.Helper()
+	for _, delta := range n.service.GetDeltas() {
+		other.service.ApplyDelta(roundTrip(t, delta))
+	}
+	n.service.ClearDeltas()
+}
+
+func TestNamedCRDTsConverge(t *testing.T) {
+	root := t.TempDir()
+	a := newCRDTNode(t, "node-a", filepath.Join(root, "a"))
+	b := newCRDTNode(t, "node-b", filepath.Join(root, "b"))
+	defer b.service.Close()
+
+	if code, _ := a.do(t, http.MethodPost, "/crdt/downloads?type=gcounter", nil); code != http.StatusOK {
+		t.Fatalf("create gcounter returned %d", code)
+	}
+	if code, _ := a.do(t, http.MethodPost, "/crdt/regions?type=orset", nil); code != http.StatusOK {
+		t.Fatalf("create orset returned %d", code)
+	}
+	a.do(t, http.MethodPost, "/crdt/downloads/op", CRDTOp{Op: "increment", Value: 3})
+	a.do(t, http.MethodPost, "/crdt/regions/op", CRDTOp{Op: "add", Value: "eu-west"})
+	a.syncTo(t, b)
+
+	// b learned both CRDTs from the deltas and updates them concurrently
+	b.do(t, http.MethodPost, "/crdt/downloads/op", CRDTOp{Op: "increment", Value: 2})
+	b.do(t, http.MethodPost, "/crdt/regions/op", CRDTOp{Op: "add", Value: "us-east"})
+	a.do(t, http.MethodPost, "/crdt/regions/op", CRDTOp{Op: "add", Value: "ap-south"})
+	a.syncTo(t, b)
+	b.syncTo(t, a)
+
+	for _, node := range []*crdtNode{a, b} {
+		_, counter := node.do(t, http.MethodGet, "/crdt/downloads", nil)
+		if counter["type"] != "gcounter" || counter["value"] != float64(5) {
+			t.Fatalf("expected downloads to converge on 5, got %v", counter)
+		}
+		_, set := node.do(t, http.MethodGet, "/crdt/regions", nil)
+		want := []interface{}{"ap-south", "eu-west", "us-east"}
+		if !reflect.DeepEqual(set["value"], want) {
+			t.Fatalf("expected regions %v, got %v", want, set["value"])
+		}
+	}
+
+	// The registry survives a restart
+	a.service.Close()
+	a = newCRDTNode(t, "node-a", filepath.Join(root, "a"))
+	defer a.service.Close()
+	if _, counter := a.do(t, http.MethodGet, "/crdt/downloads", nil); counter["value"] != float64(5) {
+		t.Fatalf("expected persisted downloads 5, got %v", counter)
+	}
+}
+
+func TestNamedCRDTErrors(t *testing.T) {
+	node := newCRDTNode(t, "node-a", t.TempDir())
+	defer node.service.Close()
+
+	node.do(t, http.MethodPost, "/crdt/settings?type=ormap", nil)
+	if code, _ := node.do(t, http.MethodPost, "/crdt/settings?type=orset", nil); code != http.StatusConflict {
+		t.Errorf("re-creating with another type: expected 409, got %d", code)
+	}
+	if code, _ := node.do(t, http.MethodPost, "/crdt/settings?type=ormap", nil); code != http.StatusOK {
+		t.Errorf("re-creating with the same type: expected 200, got %d", code)
+	}
+	if code, _ := node.do(t, http.MethodGet, "/crdt/missing", nil); code != http.StatusNotFound {
+		t.Errorf("missing CRDT: expected 404, got %d", code)
+	}
+	if code, _ := node.do(t, http.MethodPost, "/crdt/other?type=bloom", nil); code != http.StatusBadRequest {
+		t.Errorf("unknown type: expected 400, got %d", code)
+	}
+	if code, _ := node.do(t, http.MethodPost, "/crdt/settings/op", CRDTOp{Op: "put"}); code != http.StatusBadRequest {
+		t.Errorf("ormap put without key: expected 400, got %d", code)
+	}
+
+	node.do(t, http.MethodPost, "/crdt/settings/op", CRDTOp{Op: "put", Key: "mode", Value: "fast"})
+	_, got := node.do(t, http.MethodPost, "/crdt/settings/op", CRDTOp{Op: "put", Key: "replicas", Value: 3})
+	want := map[string]interface{}{"mode": "fast", "replicas": float64(3)}
+	if !reflect.DeepEqual(got["value"], want) {
+		t.Fatalf("expected ormap %v, got %v", want, got["value"])
+	}
+}
+
+// crdtStats fetches the stats of a named CRDT
+func (n *crdtNode) crdtStats(t *testing.T, name string) map[string]interface{} {
+	t.Helper()
+	code, result := n.do(t, http.MethodGet, "/crdt/"+name+"/stats", nil)
+	if code != http.StatusOK {
+		t.Fatalf("stats for %s returned %d", name, code)
+	}
+	return result["stats"].(map[string]interface{})
+}
+
+func TestNamedCRDTStatsAndCompaction(t *testing.T) {
+	node := newCRDTNode(t, "node-a", t.TempDir())
+	defer node.service.Close()
+
+	node.do(t, http.MethodPost, "/crdt/regions?type=orset", nil)
+	for _, region := range []string{"eu-west", "us-east", "ap-south"} {
+		node.do(t, http.MethodPost, "/crdt/regions/op", CRDTOp{Op: "add", Value: region})
+	}
+	if stats := node.crdtStats(t, "regions"); stats["entry_count"] != float64(3) || stats["tombstone_count"] != float64(0) {
+		t.Fatalf("expected 3 entries and no tombstones, got %v", stats)
+	}
+
+	node.do(t, http.MethodPost, "/crdt/regions/op", CRDTOp{Op: "remove", Value: "eu-west"})
+	node.do(t, http.MethodPost, "/crdt/regions/op", CRDTOp{Op: "remove", Value: "us-east"})
+	removed := node.crdtStats(t, "regions")
+	if removed["entry_count"] != float64(1) || removed["tombstone_count"] != float64(2) {
+		t.Fatalf("expected 1 entry and 2 tombstones after removes, got %v", removed)
+	}
+
+	code, result := node.do(t, http.MethodPost, "/crdt/regions/compact", nil)
+	if code != http.StatusOK {
+		t.Fatalf("compact returned %d", code)
+	}
+	compacted := result["stats"].(map[string]interface{})
+	if compacted["entry_count"] != float64(1) || compacted["tombstone_count"] != float64(0) {
+		t.Fatalf("expected tombstones to be dropped by compaction, got %v", compacted)
+	}
+	if compacted["approx_bytes"].(float64) >= removed["approx_bytes"].(float64) {
+		t.Fatalf("expected compaction to shrink the state: %v -> %v", removed, compacted)
+	}
+	if _, set := node.do(t, http.MethodGet, "/crdt/regions", nil); !reflect.DeepEqual(set["value"], []interface{}{"ap-south"}) {
+		t.Fatalf("expected only ap-south after compaction, got %v", set["value"])
+	}
+
+	if code, _ := node.do(t, http.MethodGet, "/crdt/missing/stats", nil); code != http.StatusNotFound {
+		t.Errorf("stats for a missing CRDT: expected 404, got %d", code)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// BridgeConfig selects the etcd prefixes mirrored into the catalog service
+type BridgeConfig struct {
+	CatalogURL     string
+	SnapshotPrefix string
+	ImagePrefix    string
+}
+
+// bridgeConfigFromViper reads the catalog.* settings
+func bridgeConfigFromViper() BridgeConfig {
+	return BridgeConfig{
+		CatalogURL:     viper.GetString("catalog.url"),
+		SnapshotPrefix: viper.GetString("catalog.snapshot_prefix"),
+		ImagePrefix:    viper.GetString("catalog.image_prefix"),
+	}
+}
+
+// CatalogBridge keeps the catalog service in step with etcd: every key put
+// under a watched prefix is added to the catalog, and every deleted key is
+// removed, using the part of the key after the prefix as the ID
+type CatalogBridge struct {
+	cli        *clientv3.Client
+	catalogURL string
+	client     *http.Client
+
+	// prefix -> catalog collection ("snapshots" or "images")
+	collections map[string]string
+}
+
+// NewCatalogBridge creates a bridge from cp's etcd client to the catalog at cfg.CatalogURL
+func NewCatalogBridge(cp *ControlPlane, cfg BridgeConfig) *CatalogBridge {
+	collections := make(map[string]string)
+	if cfg.SnapshotPrefix != "" {
+		collections[cfg.SnapshotPrefix] = "snapshots"
+	}
+	if cfg.ImagePrefix != "" {
+		collections[cfg.ImagePrefix] = "images"
+	}
+
+	return &CatalogBridge{
+		cli:         cp.etcdClient,
+		catalogURL:  strings.TrimRight(cfg.CatalogURL, "/"),
+		client:      &http.Client{Timeout: 10 * time.Second},
+		collections: collections,
+	}
+}
+
+// Run mirrors every watched prefix until ctx is cancelled
+func (b *CatalogBridge) Run(ctx context.Context) {
+	done := make(chan struct{}, len(b.collections))
+	for prefix, collection := range b.collections {
+		go func(prefix, collection string) {
+			b.mirror(ctx, prefix, collection)
+			done <- struct{}{}
+		}(prefix, collection)
+	}
+	for range b.collections {
+		<-done
+	}
+}
+
+// mirror adds the keys already under prefix, then applies changes from the
+// revision that listing saw onwards so none are missed in between
+func (b *CatalogBridge) mirror(ctx context.Context, prefix, collection string) {
+	resp, err := b.cli.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		log.Printf("Catalog bridge: failed to list %s: %v", prefix, err)
+		return
+	}
+	for _, kv := range resp.Kvs {
+		b.apply(ctx, collection, strings.TrimPrefix(string(kv.Key), prefix), false)
+	}
+
+	watch := b.cli.Watch(ctx, prefix, clientv3.WithPrefix(), clientv3.WithRev(resp.Header.Revision+1))
+	for wresp := range watch {
+		if err := wresp.Err(); err != nil {
+			log.Printf("Catalog bridge: watch on %s failed: %v", prefix, err)
+			return
+		}
+		for _, ev := range wresp.Events {
+			b.apply(ctx, collection, strings.TrimPrefix(string(ev.Kv.Key), prefix), ev.Type == clientv3.EventTypeDelete)
+		}
+	}
+}
+
+// apply adds id to the catalog collection, or removes it if deleted
+func (b *CatalogBridge) apply(ctx context.Context, collection, id string, deleted bool) {
+	if id == "" {
+		return
+	}
+
+	method, action := http.MethodPost, "add"
+	if deleted {
+		method, action = http.MethodDelete, "remove"
+	}
+
+	endpoint := fmt.Sprintf("%s/%s/%s/%s", b.catalogURL, collection, action, url.PathEscape(id))
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		log.Printf("Catalog bridge: %v", err)
+		return
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		log.Printf("Catalog bridge: failed to %s %s %s: %v", action, collection, id, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Catalog bridge: catalog returned %d for %s %s %s", resp.StatusCode, action, collection, id)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCatalogBridgeMirrorsEtcdChanges(t *testing.T) {
+	requests := make(chan string, 10)
+	catalog := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r.Method + " " + r.URL.Path
+	}))
+	defer catalog.Close()
+
+	cp, err := NewControlPlane([]string{startTestEtcd(t)})
+	if err != nil {
+		t.Fatalf("failed to create control plane: %v", err)
+	}
+	defer cp.Close()
+
+	// Present before the bridge starts, so it must be picked up by the initial listing
+	if err := cp.Put("/catalog/snapshots/snap-0", "{}"); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go NewCatalogBridge(cp, BridgeConfig{
+		CatalogURL:     catalog.URL,
+		SnapshotPrefix: "/catalog/snapshots/",
+	}).Run(ctx)
+
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case got := <-requests:
+			if got != want {
+				t.Fatalf("catalog received %q, want %q", got, want)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("catalog never received %q", want)
+		}
+	}
+
+	expect("POST /snapshots/add/snap-0")
+
+	if err := cp.Put("/catalog/snapshots/snap-1", "{}"); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	expect("POST /snapshots/add/snap-1")
+
+	// Keys outside the watched prefix are ignored
+	if err := cp.Put("/other/snap-2", "{}"); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+
+	if _, err := cp.etcdClient.Delete(context.Background(), "/catalog/snapshots/snap-1"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	expect("DELETE /snapshots/remove/snap-1")
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/viper"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// EtcdConfig describes how the control plane connects to etcd. TLS is used
+// when CAFile or a client certificate is set; authentication when Username is set.
+type EtcdConfig struct {
+	Endpoints   []string
+	DialTimeout time.Duration
+
+	// CAFile verifies the etcd server; CertFile and KeyFile are the client
+	// certificate presented to it and must be set together
+	CertFile string
+	KeyFile  string
+	CAFile   string
+
+	Username string
+	Password string
+}
+
+// etcdConfigFromViper reads the etcd.* settings
+func etcdConfigFromViper() EtcdConfig {
+	return EtcdConfig{
+		Endpoints:   viper.GetStringSlice("etcd.endpoints"),
+		DialTimeout: viper.GetDuration("etcd.dial_timeout"),
+		CertFile:    viper.GetString("etcd.cert_file"),
+		KeyFile:     viper.GetString("etcd.key_file"),
+		CAFile:      viper.GetString("etcd.ca_file"),
+		Username:    viper.GetString("etcd.username"),
+		Password:    viper.GetString("etcd.password"),
+	}
+}
+
+// Validate checks that the TLS and auth settings come in complete sets
+func (c EtcdConfig) Validate() error {
+	var errs []error
+	if len(c.Endpoints) == 0 {
+		errs = append(errs, errors.New("etcd.endpoints cannot be empty"))
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		errs = append(errs, errors.New("etcd.cert_file and etcd.key_file must be set together"))
+	}
+	if c.CertFile != "" && c.CAFile == "" {
+		errs = append(errs, errors.New("etcd.ca_file is required when a client certificate is set"))
+	}
+	if (c.Username == "") != (c.Password == "") {
+		errs = append(errs, errors.New("etcd.username and etcd.password must be set together"))
+	}
+	return errors.Join(errs...)
+}
+
+// tlsEnabled reports whether any TLS setting is present
+func (c EtcdConfig) tlsEnabled() bool {
+	return c.CAFile != "" || c.CertFile != "" || c.KeyFile != ""
+}
+
+// clientConfig builds the clientv3 configuration, loading any TLS material
+func (c EtcdConfig) clientConfig() (clientv3.Config, error) {
+	if err := c.Validate(); err != nil {
+		return clientv3.Config{}, err
+	}
+
+	cfg := clientv3.Config{
+		Endpoints:   c.Endpoints,
+		DialTimeout: c.DialTimeout,
+		Username:    c.Username,
+		Password:    c.Password,
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = 5 * time.Second
+	}
+
+	if c.tlsEnabled() {
+		tlsConfig, err := c.tlsConfig()
+		if err != nil {
+			return clientv3.Config{}, err
+		}
+		cfg.TLS = tlsConfig
+	}
+	return cfg, nil
+}
+
+// tlsConfig loads the CA and client certificate files
+func (c EtcdConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read etcd CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in etcd CA %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load etcd client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+// testCA signs certificates for TLS tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string
+}
+
+func newTestCA(t *testing.T, dir, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	file := filepath.Join(dir, name+".pem")
+	writePEM(t, file, "CERTIFICATE", der)
+	return &testCA{cert: cert, key: key, file: file}
+}
+
+// issue writes a certificate for 127.0.0.1 signed by ca and returns the cert and key paths
+func (ca *testCA) issue(t *testing.T, dir, name string, usage x509.ExtKeyUsage) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("failed to issue certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+"-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+// startTLSEtcd starts an embedded etcd that serves clients over TLS and
+// requires a client certificate signed by ca
+func startTLSEtcd(t *testing.T, ca *testCA, dir string) string {
+	t.Helper()
+
+	certFile, keyFile := ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+
+	cfg := embed.NewConfig()
+	cfg.Dir = filepath.Join(dir, "etcd")
+	clientURL, peerURL := freeURL(t), freeURL(t)
+	clientURL.Scheme = "https"
+	cfg.ListenClientUrls, cfg.AdvertiseClientUrls = []url.URL{clientURL}, []url.URL{clientURL}
+	cfg.ListenPeerUrls, cfg.AdvertisePeerUrls = []url.URL{peerURL}, []url.URL{peerURL}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+	cfg.ClientTLSInfo = transport.TLSInfo{
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		TrustedCAFile:  ca.file,
+		ClientCertAuth: true,
+	}
+
+	e, err := embed.StartEtcd(cfg)
+	if err != nil {
+		t.Fatalf("failed to start etcd: %v", err)
+	}
+	t.Cleanup(e.Close)
+
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(30 * time.Second):
+		t.Fatal("etcd took too long to start")
+	}
+
+	return clientURL.String()
+}
+
+func TestControlPlaneConnectsOverTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "ca")
+	endpoint := startTLSEtcd(t, ca, dir)
+	certFile, keyFile := ca.issue(t, dir, "client", x509.ExtKeyUsageClientAuth)
+
+	cp, err := NewControlPlaneWithConfig(EtcdConfig{
+		Endpoints:   []string{endpoint},
+		DialTimeout: 5 * time.Second,
+		CertFile:    certFile,
+		KeyFile:     keyFile,
+		CAFile:      ca.file,
+	})
+	if err != nil {
+		t.Fatalf("failed to connect over TLS: %v", err)
+	}
+	defer cp.Close()
+
+	if err := cp.Put("tls-key", "secured"); err != nil {
+		t.Fatalf("put over TLS failed: %v", err)
+	}
+	value, err := cp.Get("tls-key")
+	if err != nil || value != "secured" {
+		t.Fatalf("get over TLS returned %q, %v", value, err)
+	}
+}
+
+func TestControlPlaneRejectsUntrustedServer(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "ca")
+	endpoint := startTLSEtcd(t, ca, dir)
+	certFile, keyFile := ca.issue(t, dir, "client", x509.ExtKeyUsageClientAuth)
+	otherCA := newTestCA(t, dir, "other-ca")
+
+	cp, err := NewControlPlaneWithConfig(EtcdConfig{
+		Endpoints:   []string{endpoint},
+		DialTimeout: 2 * time.Second,
+		CertFile:    certFile,
+		KeyFile:     keyFile,
+		CAFile:      otherCA.file,
+	})
+	if err == nil {
+		// Depending on the client version the handshake fails at dial or on first use
+		defer cp.Close()
+		err = cp.Put("tls-key", "should not be stored")
+	}
+	if err == nil {
+		t.Fatal("expected the connection to fail with the wrong CA")
+	}
+}
+
+func TestEtcdConfigValidate(t *testing.T) {
+	valid := EtcdConfig{Endpoints: []string{"localhost:2379"}}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("plain config rejected: %v", err)
+	}
+
+	for name, cfg := range map[string]EtcdConfig{
+		"no endpoints":         {},
+		"cert without key":     {Endpoints: valid.Endpoints, CertFile: "c.pem", CAFile: "ca.pem"},
+		"key without cert":     {Endpoints: valid.Endpoints, KeyFile: "k.pem", CAFile: "ca.pem"},
+		"client cert, no CA":   {Endpoints: valid.Endpoints, CertFile: "c.pem", KeyFile: "k.pem"},
+		"username no password": {Endpoints: valid.Endpoints, Username: "root"},
+		"password no username": {Endpoints: valid.Endpoints, Password: "secret"},
+	} {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+
+	if _, err := NewControlPlaneWithConfig(EtcdConfig{Endpoints: valid.Endpoints, CAFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Fatal("expected an error for a missing CA file")
+	}
+}
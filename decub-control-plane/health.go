@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var startTime = time.Now()
+
+// HealthResponse is returned by the /health endpoint
+type HealthResponse struct {
+	Status        string `json:"status"`
+	Service       string `json:"service"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+	Error         string `json:"error,omitempty"`
+}
+
+// writeHealth reports the service as healthy, or as unavailable with 503 when readyErr is set
+func writeHealth(w http.ResponseWriter, service string, readyErr error) {
+	resp := HealthResponse{
+		Status:        "healthy",
+		Service:       service,
+		UptimeSeconds: int64(time.Since(startTime).Seconds()),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if readyErr != nil {
+		resp.Status = "unhealthy"
+		resp.Error = readyErr.Error()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ready checks that etcd answers a read within a short timeout
+func (cp *ControlPlane) ready(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	if _, err := cp.etcdClient.Get(ctx, "health"); err != nil {
+		return fmt.Errorf("etcd: %w", err)
+	}
+	return nil
+}
+
+func (cp *ControlPlane) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, "decub-control-plane", cp.ready(r.Context()))
+}
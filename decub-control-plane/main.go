@@ -3,14 +3,17 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"time"
 
-	"github.com/coreos/etcd/clientv3"
+	"github.com/decube/httpserve"
 	"github.com/gorilla/mux"
 	"github.com/spf13/viper"
+	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 // ControlPlane represents the local control plane
@@ -18,16 +21,23 @@ type ControlPlane struct {
 	etcdClient *clientv3.Client
 }
 
-// NewControlPlane creates a new control plane
+// NewControlPlane creates a new control plane connected to etcd without TLS or auth
 func NewControlPlane(etcdEndpoints []string) (*ControlPlane, error) {
-	cli, err := clientv3.New(clientv3.Config{
-		Endpoints:   etcdEndpoints,
-		DialTimeout: 5 * time.Second,
-	})
+	return NewControlPlaneWithConfig(EtcdConfig{Endpoints: etcdEndpoints})
+}
+
+// NewControlPlaneWithConfig creates a control plane using cfg's TLS and auth settings
+func NewControlPlaneWithConfig(cfg EtcdConfig) (*ControlPlane, error) {
+	clientCfg, err := cfg.clientConfig()
 	if err != nil {
 		return nil, err
 	}
 
+	cli, err := clientv3.New(clientCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to etcd: %w", err)
+	}
+
 	return &ControlPlane{etcdClient: cli}, nil
 }
 
@@ -156,27 +166,55 @@ func (cp *ControlPlane) handleGet(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
+	envPort, err := httpserve.PortFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	port := flag.Int("port", envPort, "port to listen on (env PORT); 0 picks a free port")
+	flag.Parse()
+
 	// Load config
 	viper.SetDefault("etcd.endpoints", []string{"localhost:2379"})
+	viper.SetDefault("etcd.dial_timeout", 5*time.Second)
+	viper.SetDefault("catalog.snapshot_prefix", "/catalog/snapshots/")
+	viper.SetDefault("catalog.image_prefix", "/catalog/images/")
+	viper.BindEnv("catalog.url", "CATALOG_URL")
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath(".")
 	viper.ReadInConfig()
 
-	endpoints := viper.GetStringSlice("etcd.endpoints")
-
-	cp, err := NewControlPlane(endpoints)
+	cp, err := NewControlPlaneWithConfig(etcdConfigFromViper())
 	if err != nil {
 		log.Fatalf("Failed to create control plane: %v", err)
 	}
-	defer cp.Close()
+
+	// Mirror catalog keys in etcd into the catalog service
+	bridgeCtx, stopBridge := context.WithCancel(context.Background())
+	if cfg := bridgeConfigFromViper(); cfg.CatalogURL != "" {
+		go NewCatalogBridge(cp, cfg).Run(bridgeCtx)
+	}
 
 	r := mux.NewRouter()
+	r.HandleFunc("/health", cp.handleHealth).Methods("GET")
 	r.HandleFunc("/snapshot/create", cp.handleCreateSnapshot).Methods("POST")
 	r.HandleFunc("/snapshot/restore", cp.handleRestoreSnapshot).Methods("POST")
+	r.HandleFunc("/kv/txn", cp.handleTxn).Methods("POST")
 	r.HandleFunc("/kv/{key}", cp.handlePut).Methods("PUT")
 	r.HandleFunc("/kv/{key}", cp.handleGet).Methods("GET")
 
-	fmt.Println("Control plane server starting on :8080")
-	log.Fatal(http.ListenAndServe(":8080", r))
+	lis, err := net.Listen("tcp", httpserve.PortAddr(*port))
+	if err != nil {
+		log.Fatalf("Failed to listen on port %d: %v", *port, err)
+	}
+	fmt.Printf("Control plane server listening on %s\n", lis.Addr())
+	err = httpserve.ServeUntilSignal(lis, r)
+
+	// Close the store only after in-flight requests have finished with it
+	stopBridge()
+	cp.Close()
+	if err != nil {
+		log.Fatalf("Control plane server failed: %v", err)
+	}
+	fmt.Println("Control plane server stopped")
 }
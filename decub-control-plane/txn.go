@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// maxTxnOps matches etcd's default limit on operations per transaction
+const maxTxnOps = 128
+
+// txnError marks a request that could not be turned into a transaction
+type txnError struct{ error }
+
+// TxnCompare is one condition of a transaction. Target selects what is
+// compared: "value" (the default) compares Value, while "version",
+// "create_revision" and "mod_revision" compare Revision. A key that does not
+// exist has a create_revision of 0.
+type TxnCompare struct {
+	Key      string `json:"key"`
+	Target   string `json:"target,omitempty"`
+	Result   string `json:"result"` // "=", "!=", "<" or ">"
+	Value    string `json:"value,omitempty"`
+	Revision int64  `json:"revision,omitempty"`
+}
+
+// TxnOp is a put, get or delete run in one branch of a transaction
+type TxnOp struct {
+	Type  string `json:"type"`
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
+
+// TxnRequest runs Success if every Compare holds and Failure otherwise, atomically
+type TxnRequest struct {
+	Compare []TxnCompare `json:"compare"`
+	Success []TxnOp      `json:"success"`
+	Failure []TxnOp      `json:"failure"`
+}
+
+// TxnOpResult is the outcome of one operation in the branch that ran
+type TxnOpResult struct {
+	Type    string `json:"type"`
+	Key     string `json:"key"`
+	Value   string `json:"value,omitempty"`
+	Found   bool   `json:"found,omitempty"`
+	Deleted int64  `json:"deleted,omitempty"`
+}
+
+// TxnResponse reports which branch ran and its results
+type TxnResponse struct {
+	Succeeded bool          `json:"succeeded"`
+	Revision  int64         `json:"revision"`
+	Results   []TxnOpResult `json:"results"`
+}
+
+// Txn executes req atomically with etcd's If/Then/Else transaction
+func (cp *ControlPlane) Txn(req TxnRequest) (*TxnResponse, error) {
+	if n := len(req.Compare) + len(req.Success) + len(req.Failure); n > maxTxnOps {
+		return nil, txnError{fmt.Errorf("transaction has %d operations, the limit is %d", n, maxTxnOps)}
+	}
+
+	cmps := make([]clientv3.Cmp, len(req.Compare))
+	for i, c := range req.Compare {
+		cmp, err := c.cmp()
+		if err != nil {
+			return nil, txnError{fmt.Errorf("compare[%d]: %w", i, err)}
+		}
+		cmps[i] = cmp
+	}
+	success, err := txnOps("success", req.Success)
+	if err != nil {
+		return nil, txnError{err}
+	}
+	failure, err := txnOps("failure", req.Failure)
+	if err != nil {
+		return nil, txnError{err}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := cp.etcdClient.Txn(ctx).If(cmps...).Then(success...).Else(failure...).Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	ran := req.Failure
+	if resp.Succeeded {
+		ran = req.Success
+	}
+	results := make([]TxnOpResult, len(resp.Responses))
+	for i, r := range resp.Responses {
+		result := TxnOpResult{Type: ran[i].Type, Key: ran[i].Key}
+		switch {
+		case r.GetResponseRange() != nil:
+			if kvs := r.GetResponseRange().Kvs; len(kvs) > 0 {
+				result.Value, result.Found = string(kvs[0].Value), true
+			}
+		case r.GetResponseDeleteRange() != nil:
+			result.Deleted = r.GetResponseDeleteRange().Deleted
+		}
+		results[i] = result
+	}
+
+	return &TxnResponse{Succeeded: resp.Succeeded, Revision: resp.Header.Revision, Results: results}, nil
+}
+
+// cmp converts c to an etcd comparison
+func (c TxnCompare) cmp() (clientv3.Cmp, error) {
+	if c.Key == "" {
+		return clientv3.Cmp{}, fmt.Errorf("key cannot be empty")
+	}
+	switch c.Result {
+	case "=", "!=", "<", ">":
+	default:
+		return clientv3.Cmp{}, fmt.Errorf("unknown result %q", c.Result)
+	}
+
+	switch c.Target {
+	case "", "value":
+		return clientv3.Compare(clientv3.Value(c.Key), c.Result, c.Value), nil
+	case "version":
+		return clientv3.Compare(clientv3.Version(c.Key), c.Result, c.Revision), nil
+	case "create_revision":
+		return clientv3.Compare(clientv3.CreateRevision(c.Key), c.Result, c.Revision), nil
+	case "mod_revision":
+		return clientv3.Compare(clientv3.ModRevision(c.Key), c.Result, c.Revision), nil
+	default:
+		return clientv3.Cmp{}, fmt.Errorf("unknown target %q", c.Target)
+	}
+}
+
+// txnOps converts one branch of a transaction to etcd operations
+func txnOps(branch string, ops []TxnOp) ([]clientv3.Op, error) {
+	out := make([]clientv3.Op, len(ops))
+	for i, op := range ops {
+		if op.Key == "" {
+			return nil, fmt.Errorf("%s[%d]: key cannot be empty", branch, i)
+		}
+		switch op.Type {
+		case "put":
+			out[i] = clientv3.OpPut(op.Key, op.Value)
+		case "get":
+			out[i] = clientv3.OpGet(op.Key)
+		case "delete":
+			out[i] = clientv3.OpDelete(op.Key)
+		default:
+			return nil, fmt.Errorf("%s[%d]: unknown operation %q", branch, i, op.Type)
+		}
+	}
+	return out, nil
+}
+
+func (cp *ControlPlane) handleTxn(w http.ResponseWriter, r *http.Request) {
+	var req TxnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := cp.Txn(req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.As(err, new(txnError)) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
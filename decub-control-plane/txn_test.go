@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// postTxn runs req through the /kv/txn handler
+func postTxn(t *testing.T, cp *ControlPlane, req TxnRequest) (int, TxnResponse) {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	rec := httptest.NewRecorder()
+	cp.handleTxn(rec, httptest.NewRequest(http.MethodPost, "/kv/txn", bytes.NewReader(body)))
+
+	var resp TxnResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid txn response: %v", err)
+		}
+	}
+	return rec.Code, resp
+}
+
+func TestTxnCompareAndSwap(t *testing.T) {
+	cp, err := NewControlPlane([]string{startTestEtcd(t)})
+	if err != nil {
+		t.Fatalf("failed to create control plane: %v", err)
+	}
+	defer cp.Close()
+
+	if err := cp.Put("leader", "node-a"); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+
+	cas := func(expected, next string) TxnRequest {
+		return TxnRequest{
+			Compare: []TxnCompare{{Key: "leader", Result: "=", Value: expected}},
+			Success: []TxnOp{{Type: "put", Key: "leader", Value: next}},
+			Failure: []TxnOp{{Type: "get", Key: "leader"}},
+		}
+	}
+
+	// Expected value matches: the swap happens
+	code, resp := postTxn(t, cp, cas("node-a", "node-b"))
+	if code != http.StatusOK || !resp.Succeeded {
+		t.Fatalf("CAS with matching value failed: %d %+v", code, resp)
+	}
+	if value, _ := cp.Get("leader"); value != "node-b" {
+		t.Fatalf("expected leader node-b after CAS, got %q", value)
+	}
+
+	// Value changed underneath: the Else branch reports the current value
+	code, resp = postTxn(t, cp, cas("node-a", "node-c"))
+	if code != http.StatusOK || resp.Succeeded {
+		t.Fatalf("CAS with stale value succeeded: %d %+v", code, resp)
+	}
+	if len(resp.Results) != 1 || resp.Results[0].Type != "get" || !resp.Results[0].Found || resp.Results[0].Value != "node-b" {
+		t.Fatalf("Else branch did not run: %+v", resp.Results)
+	}
+	if value, _ := cp.Get("leader"); value != "node-b" {
+		t.Fatalf("failed CAS changed leader to %q", value)
+	}
+}
+
+func TestTxnCreateIfAbsent(t *testing.T) {
+	cp, err := NewControlPlane([]string{startTestEtcd(t)})
+	if err != nil {
+		t.Fatalf("failed to create control plane: %v", err)
+	}
+	defer cp.Close()
+
+	create := TxnRequest{
+		Compare: []TxnCompare{{Key: "lock", Target: "create_revision", Result: "=", Revision: 0}},
+		Success: []TxnOp{{Type: "put", Key: "lock", Value: "owner-1"}},
+	}
+	if _, resp := postTxn(t, cp, create); !resp.Succeeded {
+		t.Fatal("create on an absent key failed")
+	}
+	if _, resp := postTxn(t, cp, create); resp.Succeeded {
+		t.Fatal("create on an existing key succeeded")
+	}
+}
+
+func TestTxnRejectsInvalidRequests(t *testing.T) {
+	cp := &ControlPlane{}
+	for name, req := range map[string]TxnRequest{
+		"unknown op":     {Success: []TxnOp{{Type: "append", Key: "k"}}},
+		"empty key":      {Success: []TxnOp{{Type: "put"}}},
+		"unknown result": {Compare: []TxnCompare{{Key: "k", Result: ">="}}},
+		"unknown target": {Compare: []TxnCompare{{Key: "k", Target: "lease", Result: "="}}},
+	} {
+		if code, _ := postTxn(t, cp, req); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, code)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// defaultHealthPath is probed on upstreams that do not set HealthPath
+const defaultHealthPath = "/health"
+
+// Upstream is a service the gateway proxies to under /<Name>/
+type Upstream struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// HealthPath is probed by the aggregated /health and /status; defaults to /health
+	HealthPath string `json:"health_path,omitempty"`
+}
+
+// DefaultUpstreams are the services as named in docker-compose.yml
+func DefaultUpstreams() []Upstream {
+	return []Upstream{
+		{Name: "control-plane", URL: "http://control-plane:8080"},
+		{Name: "cas", URL: "http://cas:8080"},
+		{Name: "catalog", URL: "http://catalog:8080"},
+		{Name: "gossip", URL: "http://gossip:8080", HealthPath: "/api/v1/status"},
+		{Name: "object-storage", URL: "http://object-storage:8080"},
+	}
+}
+
+// ParseUpstreams parses a comma-separated list of name=url pairs, such as
+// "catalog=http://localhost:8083,cas=http://localhost:8082"
+func ParseUpstreams(s string) ([]Upstream, error) {
+	var upstreams []Upstream
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, target, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid upstream %q: expected name=url", pair)
+		}
+		upstreams = append(upstreams, Upstream{Name: strings.TrimSpace(name), URL: strings.TrimSpace(target)})
+	}
+	return upstreams, nil
+}
+
+// LoadUpstreams reads a JSON array of upstreams from a file
+func LoadUpstreams(path string) ([]Upstream, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upstreams: %w", err)
+	}
+	var upstreams []Upstream
+	if err := json.Unmarshal(data, &upstreams); err != nil {
+		return nil, fmt.Errorf("failed to decode upstreams: %w", err)
+	}
+	return upstreams, nil
+}
+
+// route is a validated upstream
+type route struct {
+	Upstream
+	target *url.URL
+}
+
+// buildRoutes validates upstreams and returns them sorted by name
+func buildRoutes(upstreams []Upstream) ([]*route, error) {
+	if len(upstreams) == 0 {
+		return nil, fmt.Errorf("no upstreams configured")
+	}
+
+	seen := make(map[string]bool)
+	routes := make([]*route, 0, len(upstreams))
+	for _, u := range upstreams {
+		if u.Name == "" || strings.Contains(u.Name, "/") {
+			return nil, fmt.Errorf("invalid upstream name %q", u.Name)
+		}
+		if u.Name == "health" || u.Name == "status" {
+			return nil, fmt.Errorf("upstream name %q is reserved", u.Name)
+		}
+		if seen[u.Name] {
+			return nil, fmt.Errorf("duplicate upstream %q", u.Name)
+		}
+		seen[u.Name] = true
+
+		target, err := url.Parse(u.URL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return nil, fmt.Errorf("invalid URL for upstream %s: %q", u.Name, u.URL)
+		}
+		if u.HealthPath == "" {
+			u.HealthPath = defaultHealthPath
+		}
+		routes = append(routes, &route{Upstream: u, target: target})
+	}
+
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Name < routes[j].Name })
+	return routes, nil
+}
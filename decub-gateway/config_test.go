@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestParseUpstreams(t *testing.T) {
+	upstreams, err := ParseUpstreams(" catalog=http://localhost:8083, cas=http://localhost:8082 ,")
+	if err != nil {
+		t.Fatalf("ParseUpstreams failed: %v", err)
+	}
+	if len(upstreams) != 2 || upstreams[0] != (Upstream{Name: "catalog", URL: "http://localhost:8083"}) {
+		t.Fatalf("unexpected upstreams %+v", upstreams)
+	}
+	if _, err := ParseUpstreams("catalog"); err == nil {
+		t.Error("expected an error for a pair without a URL")
+	}
+
+	if _, err := NewGateway(DefaultUpstreams()); err != nil {
+		t.Errorf("default upstreams are invalid: %v", err)
+	}
+	for _, bad := range [][]Upstream{
+		nil,
+		{{Name: "catalog", URL: "localhost:8083"}},
+		{{Name: "health", URL: "http://localhost:8083"}},
+		{{Name: "a/b", URL: "http://localhost:8083"}},
+		{{Name: "cas", URL: "http://a"}, {Name: "cas", URL: "http://b"}},
+	} {
+		if _, err := NewGateway(bad); err == nil {
+			t.Errorf("expected %+v to be rejected", bad)
+		}
+	}
+}
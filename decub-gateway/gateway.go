@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"time"
+)
+
+// healthCheckTimeout bounds each upstream probe of /health and /status
+const healthCheckTimeout = 3 * time.Second
+
+// Gateway reverse-proxies /<name>/... to the upstream called name, with the
+// prefix stripped, and aggregates the health of every upstream
+type Gateway struct {
+	routes  []*route
+	proxies map[string]*httputil.ReverseProxy
+	client  *http.Client
+}
+
+// NewGateway creates a gateway over upstreams
+func NewGateway(upstreams []Upstream) (*Gateway, error) {
+	routes, err := buildRoutes(upstreams)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Gateway{
+		routes:  routes,
+		proxies: make(map[string]*httputil.ReverseProxy, len(routes)),
+		client:  &http.Client{Timeout: healthCheckTimeout},
+	}
+	for _, rt := range routes {
+		g.proxies[rt.Name] = newProxy(rt)
+	}
+	return g, nil
+}
+
+// newProxy forwards requests to rt; the request path arrives with the
+// gateway prefix already stripped
+func newProxy(rt *route) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(rt.target)
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		// Upstreams see their own host, as if called directly
+		r.Host = rt.target.Host
+		r.Header.Set("X-Forwarded-Prefix", "/"+rt.Name)
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		log.Printf("Proxy to %s failed: %v", rt.Name, err)
+		writeJSON(w, http.StatusBadGateway, map[string]string{
+			"error":    "upstream unavailable",
+			"upstream": rt.Name,
+		})
+	}
+	return proxy
+}
+
+// ServeHTTP routes /health and /status to the gateway and everything else to an upstream
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/health":
+		g.handleHealth(w, r)
+		return
+	case "/status":
+		g.handleStatus(w, r)
+		return
+	}
+
+	name, rest := splitPrefix(r.URL.Path)
+	proxy, ok := g.proxies[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Copy so the caller's request keeps its path
+	out := r.Clone(r.Context())
+	out.URL.Path = rest
+	out.URL.RawPath = ""
+	proxy.ServeHTTP(w, out)
+}
+
+// splitPrefix splits "/catalog/snapshots/1" into "catalog" and "/snapshots/1"
+func splitPrefix(path string) (string, string) {
+	path = strings.TrimPrefix(path, "/")
+	name, rest, _ := strings.Cut(path, "/")
+	return name, "/" + rest
+}
+
+// writeJSON writes v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
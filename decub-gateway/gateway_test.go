@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func newTestGateway(t *testing.T, upstreams ...Upstream) *httptest.Server {
+	t.Helper()
+	g, err := NewGateway(upstreams)
+	if err != nil {
+		t.Fatalf("NewGateway failed: %v", err)
+	}
+	srv := httptest.NewServer(g)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProxiesRequestToUpstreamUnderPrefix(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.NewEncoder(w).Encode(map[string]string{
+			"method": r.Method,
+			"uri":    r.URL.RequestURI(),
+			"body":   string(body),
+			"prefix": r.Header.Get("X-Forwarded-Prefix"),
+		})
+	}))
+	defer backend.Close()
+
+	gw := newTestGateway(t,
+		Upstream{Name: "catalog", URL: backend.URL},
+		Upstream{Name: "cas", URL: "http://127.0.0.1:1"}, // nothing listens here
+	)
+
+	resp, err := http.Post(gw.URL+"/catalog/snapshots/add/snap-1?dry=1", "application/json", strings.NewReader(`{"size":1}`))
+	if err != nil {
+		t.Fatalf("request through gateway failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var seen map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&seen); err != nil {
+		t.Fatalf("invalid backend response: %v", err)
+	}
+	want := map[string]string{"method": "POST", "uri": "/snapshots/add/snap-1?dry=1", "body": `{"size":1}`, "prefix": "/catalog"}
+	for k, v := range want {
+		if seen[k] != v {
+			t.Errorf("backend saw %s %q, want %q", k, seen[k], v)
+		}
+	}
+
+	for path, code := range map[string]int{
+		"/unknown/x":  http.StatusNotFound,
+		"/cas/health": http.StatusBadGateway,
+	} {
+		resp, err := http.Get(gw.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != code {
+			t.Errorf("GET %s: expected %d, got %d", path, code, resp.StatusCode)
+		}
+	}
+}
+
+func TestAggregatesUpstreamHealth(t *testing.T) {
+	var casHealthy atomic.Bool
+	cas := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			http.NotFound(w, r)
+			return
+		}
+		if !casHealthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		io.WriteString(w, `{"status":"ok"}`)
+	}))
+	defer cas.Close()
+	gossip := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/status" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, `{"node_id":"n1","peers":2}`)
+	}))
+	defer gossip.Close()
+
+	gw := newTestGateway(t,
+		Upstream{Name: "cas", URL: cas.URL},
+		Upstream{Name: "gossip", URL: gossip.URL, HealthPath: "/api/v1/status"},
+	)
+
+	get := func(path string) (int, HealthResponse) {
+		t.Helper()
+		resp, err := http.Get(gw.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var health HealthResponse
+		if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+			t.Fatalf("invalid %s response: %v", path, err)
+		}
+		return resp.StatusCode, health
+	}
+
+	code, health := get("/health")
+	if code != http.StatusServiceUnavailable || health.Status != "degraded" {
+		t.Fatalf("expected 503 degraded, got %d %s", code, health.Status)
+	}
+	if h := health.Upstreams["cas"]; h.Status != "unhealthy" || h.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("unexpected cas health %+v", h)
+	}
+	if h := health.Upstreams["gossip"]; h.Status != "healthy" || h.Response != nil {
+		t.Errorf("unexpected gossip health %+v", h)
+	}
+
+	casHealthy.Store(true)
+	code, health = get("/health")
+	if code != http.StatusOK || health.Status != "healthy" || len(health.Upstreams) != 2 {
+		t.Fatalf("expected 200 healthy over 2 upstreams, got %d %+v", code, health)
+	}
+
+	// /status carries each upstream's own response
+	code, status := get("/status")
+	if code != http.StatusOK || status.Upstreams["gossip"].URL != gossip.URL {
+		t.Fatalf("unexpected status %d %+v", code, status)
+	}
+	var node map[string]interface{}
+	if err := json.Unmarshal(status.Upstreams["gossip"].Response, &node); err != nil || node["node_id"] != "n1" {
+		t.Errorf("gossip status not passed through: %s", status.Upstreams["gossip"].Response)
+	}
+}
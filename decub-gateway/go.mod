@@ -0,0 +1,7 @@
+module github.com/decub/gateway
+
+go 1.19
+
+require github.com/decube/httpserve v0.0.0
+
+replace github.com/decube/httpserve => ../pkg/httpserve
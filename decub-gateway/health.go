@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var startTime = time.Now()
+
+// UpstreamHealth is the result of probing one upstream
+type UpstreamHealth struct {
+	Status     string `json:"status"`
+	URL        string `json:"url,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+	LatencyMS  int64  `json:"latency_ms"`
+	Error      string `json:"error,omitempty"`
+	// Response is the upstream's own health or status body; only /status includes it
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+// HealthResponse is returned by the aggregated /health and /status endpoints
+type HealthResponse struct {
+	Status        string                    `json:"status"`
+	Service       string                    `json:"service"`
+	UptimeSeconds int64                     `json:"uptime_seconds"`
+	Upstreams     map[string]UpstreamHealth `json:"upstreams"`
+}
+
+// checkAll probes every upstream concurrently
+func (g *Gateway) checkAll(ctx context.Context) map[string]UpstreamHealth {
+	results := make(map[string]UpstreamHealth, len(g.routes))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, rt := range g.routes {
+		wg.Add(1)
+		go func(rt *route) {
+			defer wg.Done()
+			h := g.check(ctx, rt)
+			mu.Lock()
+			results[rt.Name] = h
+			mu.Unlock()
+		}(rt)
+	}
+	wg.Wait()
+	return results
+}
+
+// check probes one upstream's health path; any 2xx answer counts as healthy
+func (g *Gateway) check(ctx context.Context, rt *route) UpstreamHealth {
+	h := UpstreamHealth{Status: "unhealthy", URL: rt.URL}
+	start := time.Now()
+	defer func() { h.LatencyMS = time.Since(start).Milliseconds() }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rt.target.String()+rt.HealthPath, nil)
+	if err != nil {
+		h.Error = err.Error()
+		return h
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		h.Error = err.Error()
+		return h
+	}
+	defer resp.Body.Close()
+
+	h.StatusCode = resp.StatusCode
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if json.Valid(body) {
+		h.Response = body
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		h.Error = fmt.Sprintf("health check returned %d", resp.StatusCode)
+		return h
+	}
+	h.Status = "healthy"
+	return h
+}
+
+// aggregate probes every upstream; the gateway is healthy only if all of them are
+func (g *Gateway) aggregate(ctx context.Context) HealthResponse {
+	resp := HealthResponse{
+		Status:        "healthy",
+		Service:       "decub-gateway",
+		UptimeSeconds: int64(time.Since(startTime).Seconds()),
+		Upstreams:     g.checkAll(ctx),
+	}
+	for _, h := range resp.Upstreams {
+		if h.Status != "healthy" {
+			resp.Status = "degraded"
+		}
+	}
+	return resp
+}
+
+// handleHealth answers 200 when every upstream is healthy and 503 otherwise
+func (g *Gateway) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp := g.aggregate(r.Context())
+	for name, h := range resp.Upstreams {
+		h.URL = ""
+		h.Response = nil
+		resp.Upstreams[name] = h
+	}
+
+	code := http.StatusOK
+	if resp.Status != "healthy" {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, resp)
+}
+
+// handleStatus always answers 200 with each upstream's URL and own response
+func (g *Gateway) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, g.aggregate(r.Context()))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"os"
+
+	"github.com/decube/httpserve"
+)
+
+func main() {
+	addr := flag.String("addr", envOr("DECUB_GATEWAY_ADDR", ":8080"), "listen address")
+	upstreamList := flag.String("upstreams", os.Getenv("DECUB_GATEWAY_UPSTREAMS"),
+		"comma-separated name=url upstreams, replacing the defaults")
+	upstreamFile := flag.String("config", os.Getenv("DECUB_GATEWAY_CONFIG"),
+		"JSON file listing upstreams as [{\"name\", \"url\", \"health_path\"}]")
+	flag.Parse()
+
+	upstreams := DefaultUpstreams()
+	var err error
+	switch {
+	case *upstreamFile != "":
+		upstreams, err = LoadUpstreams(*upstreamFile)
+	case *upstreamList != "":
+		upstreams, err = ParseUpstreams(*upstreamList)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	gateway, err := NewGateway(upstreams)
+	if err != nil {
+		log.Fatalf("Invalid upstreams: %v", err)
+	}
+	for _, rt := range gateway.routes {
+		log.Printf("Proxying /%s/ to %s", rt.Name, rt.URL)
+	}
+
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", *addr, err)
+	}
+	log.Printf("Gateway listening on %s", lis.Addr())
+	if err := httpserve.ServeUntilSignal(lis, gateway); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+	log.Println("Gateway stopped")
+}
+
+// envOr returns the environment variable name, or def if it is unset
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
@@ -2,21 +2,39 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
-	"sync"
 )
 
-var (
-	ledger   []Block
-	ledgerMu sync.RWMutex
-	cons     *Consensus
-)
+// API serves the GCL REST endpoints from a ledger
+type API struct {
+	ledger *Ledger
+}
+
+// NewAPI creates the REST API for ledger
+func NewAPI(ledger *Ledger) *API {
+	return &API{ledger: ledger}
+}
+
+// Routes returns the handler serving every endpoint
+func (a *API) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/gcl/tx", a.SubmitTx)
+	mux.HandleFunc("/gcl/block/", a.GetBlock)
+	mux.HandleFunc("/gcl/proof/", a.GetProof)
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
 
 // SubmitTx handles POST /gcl/tx
-func SubmitTx(w http.ResponseWriter, r *http.Request) {
+func (a *API) SubmitTx(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -28,27 +46,23 @@ func SubmitTx(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Simulate adding to pending txs, for simplicity add to new block immediately
-	ledgerMu.Lock()
-	height := len(ledger) + 1
-	var prevHash string
-	if height > 1 {
-		prevHash = HashBlock(ledger[height-2])
-	}
-	block := cons.ProposeBlock(height, prevHash, []Transaction{tx}, "validator1")
-	sigs := cons.SignBlock(block)
-	if cons.VerifyQuorum(sigs) {
-		ledger = append(ledger, block)
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, "Transaction submitted, block %d created", height)
-	} else {
-		http.Error(w, "Consensus failed", http.StatusInternalServerError)
+	height, err := a.ledger.Submit(tx)
+	switch {
+	case errors.Is(err, ErrDuplicateTx):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrInvalidTx):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	case height > 0:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"tx_id": tx.TxID, "status": "committed", "height": height})
+	default:
+		writeJSON(w, http.StatusAccepted, map[string]interface{}{"tx_id": tx.TxID, "status": "pending"})
 	}
-	ledgerMu.Unlock()
 }
 
 // GetBlock handles GET /gcl/block/{height}
-func GetBlock(w http.ResponseWriter, r *http.Request) {
+func (a *API) GetBlock(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -61,43 +75,29 @@ func GetBlock(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ledgerMu.RLock()
-	if height < 1 || height > len(ledger) {
-		ledgerMu.RUnlock()
+	block, ok := a.ledger.Block(height)
+	if !ok {
 		http.Error(w, "Block not found", http.StatusNotFound)
 		return
 	}
-	block := ledger[height-1]
-	ledgerMu.RUnlock()
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(block)
+	writeJSON(w, http.StatusOK, block)
 }
 
 // GetProof handles GET /gcl/proof/{tx_id}
-func GetProof(w http.ResponseWriter, r *http.Request) {
+func (a *API) GetProof(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	path := strings.TrimPrefix(r.URL.Path, "/gcl/proof/")
-	txID := path
-
-	ledgerMu.RLock()
-	defer ledgerMu.RUnlock()
-
-	for _, block := range ledger {
-		for i, tx := range block.Txs {
-			if tx.TxID == txID {
-				root, _ := BuildMerkleTree(block.Txs)
-				proof := GenerateMerkleProof(root, i)
-				w.Header().Set("Content-Type", "application/json")
-				json.NewEncoder(w).Encode(proof)
-				return
-			}
-		}
+	txID := strings.TrimPrefix(r.URL.Path, "/gcl/proof/")
+	proof, err := a.ledger.Proof(txID)
+	switch {
+	case errors.Is(err, ErrTxPending):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		writeJSON(w, http.StatusOK, proof)
 	}
-
-	http.Error(w, "Transaction not found", http.StatusNotFound)
 }
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// newTestLedger creates a single-validator ledger storing blocks in dir
+func newTestLedger(t *testing.T, dir string) (*Ledger, *Consensus) {
+	t.Helper()
+
+	validators, err := LoadOrCreateValidators(filepath.Join(dir, "validators.json"), []string{"val1"})
+	if err != nil {
+		t.Fatalf("failed to create validators: %v", err)
+	}
+	store, err := OpenBlockStore(filepath.Join(dir, "blocks"))
+	if err != nil {
+		t.Fatalf("failed to open block store: %v", err)
+	}
+	cons := NewConsensus(validators)
+	ledger, err := NewLedger(cons, store, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ledger, cons
+}
+
+func postTx(t *testing.T, srv *httptest.Server, tx Transaction) *http.Response {
+	t.Helper()
+	body, _ := json.Marshal(tx)
+	resp, err := http.Post(srv.URL+"/gcl/tx", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST /gcl/tx failed: %v", err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func getProof(t *testing.T, srv *httptest.Server, txID string) (*MerkleProof, int) {
+	t.Helper()
+	resp, err := http.Get(srv.URL + "/gcl/proof/" + txID)
+	if err != nil {
+		t.Fatalf("GET /gcl/proof failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode
+	}
+	var proof MerkleProof
+	if err := json.NewDecoder(resp.Body).Decode(&proof); err != nil {
+		t.Fatalf("invalid proof: %v", err)
+	}
+	return &proof, resp.StatusCode
+}
+
+func TestSubmittedTxHasVerifiableProofAfterCommit(t *testing.T) {
+	dir := t.TempDir()
+	ledger, cons := newTestLedger(t, dir)
+	srv := httptest.NewServer(NewAPI(ledger).Routes())
+	defer srv.Close()
+
+	txs := []Transaction{
+		{TxID: "tx1", Type: "transfer", Origin: "user1", Payload: "a", Sig: "sig1"},
+		{TxID: "tx2", Type: "transfer", Origin: "user2", Payload: "b", Sig: "sig2"},
+		{TxID: "tx3", Type: "transfer", Origin: "user3", Payload: "c", Sig: "sig3"},
+	}
+	for _, tx := range txs {
+		if resp := postTx(t, srv, tx); resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("submit %s: expected 202, got %d", tx.TxID, resp.StatusCode)
+		}
+	}
+	if resp := postTx(t, srv, txs[0]); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("duplicate submit: expected 409, got %d", resp.StatusCode)
+	}
+
+	// No proof until the transaction is committed
+	if _, code := getProof(t, srv, "tx3"); code != http.StatusConflict {
+		t.Fatalf("proof of pending tx: expected 409, got %d", code)
+	}
+
+	block, err := ledger.Commit()
+	if err != nil || block == nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+
+	proof, code := getProof(t, srv, "tx3")
+	if code != http.StatusOK {
+		t.Fatalf("proof: expected 200, got %d", code)
+	}
+	if proof.Index != 2 || proof.Header.Height != 1 || len(proof.Path) != 2 {
+		t.Fatalf("unexpected proof: %+v", proof)
+	}
+	if err := cons.VerifyProof(proof); err != nil {
+		t.Fatalf("proof does not verify: %v", err)
+	}
+
+	// Tampering with the transaction, the header or the commit breaks the proof
+	tampered := *proof
+	tampered.TxHash = HashTransaction(Transaction{TxID: "tx3", Payload: "forged"})
+	if cons.VerifyProof(&tampered) == nil {
+		t.Fatal("proof of a forged transaction verified")
+	}
+	tampered = *proof
+	tampered.Header.Proposer = "someone-else"
+	if cons.VerifyProof(&tampered) == nil {
+		t.Fatal("proof with a modified header verified")
+	}
+	tampered = *proof
+	tampered.Commit = nil
+	if cons.VerifyProof(&tampered) == nil {
+		t.Fatal("proof without signatures verified")
+	}
+
+	if _, code := getProof(t, srv, "missing"); code != http.StatusNotFound {
+		t.Fatalf("proof of unknown tx: expected 404, got %d", code)
+	}
+
+	// Blocks are read back from storage after a restart, still verifiable
+	reopened, cons := newTestLedger(t, dir)
+	srv2 := httptest.NewServer(NewAPI(reopened).Routes())
+	defer srv2.Close()
+
+	resp, err := http.Get(srv2.URL + "/gcl/block/1")
+	if err != nil {
+		t.Fatalf("GET /gcl/block/1 failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var stored Block
+	if err := json.NewDecoder(resp.Body).Decode(&stored); err != nil {
+		t.Fatalf("invalid block: %v", err)
+	}
+	if len(stored.Txs) != 3 || !cons.VerifyQuorum(HashBlock(stored), stored.Commit) {
+		t.Fatalf("stored block does not verify: %+v", stored)
+	}
+	proof, _ = getProof(t, srv2, "tx1")
+	if proof == nil || cons.VerifyProof(proof) != nil {
+		t.Fatalf("proof after restart does not verify: %+v", proof)
+	}
+}
+
+func TestMockLedgerCommitsOnSubmit(t *testing.T) {
+	var validators []Validator
+	for _, id := range []string{"val1", "val2", "val3"} {
+		v, err := NewValidator(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		validators = append(validators, v)
+	}
+	store, _ := OpenBlockStore("")
+	cons := NewConsensus(validators)
+
+	// Only a mock ledger may sign for several validators from one process
+	if _, err := NewLedger(cons, store, false); err == nil {
+		t.Fatal("a ledger holding every validator key must require mock mode")
+	}
+	ledger, err := NewLedger(cons, store, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(NewAPI(ledger).Routes())
+	defer srv.Close()
+
+	if resp := postTx(t, srv, Transaction{TxID: "tx1", Type: "transfer"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("mock submit: expected 200, got %d", resp.StatusCode)
+	}
+	proof, code := getProof(t, srv, "tx1")
+	if code != http.StatusOK || cons.VerifyProof(proof) != nil {
+		t.Fatalf("mock proof does not verify: %d %+v", code, proof)
+	}
+	tampered := *proof
+	tampered.Commit = proof.Commit[:1]
+	if cons.VerifyProof(&tampered) == nil {
+		t.Fatal("proof without a quorum of signatures verified")
+	}
+}
@@ -1,57 +1,177 @@
 package main
 
 import (
-	"crypto/rand"
-	"crypto/sha256"
+	"crypto/ed25519"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"time"
 )
 
-// Validator represents a validator
+// Validator represents a validator. PubKey is its hex Ed25519 public key;
+// the private key is only held for the validators this node signs for.
 type Validator struct {
-	ID   string
+	ID     string
 	PubKey string
+	key    ed25519.PrivateKey
 }
 
-// Consensus simulates BFT consensus with quorum signatures
+// Consensus commits blocks once more than two thirds of the validators have
+// signed them
 type Consensus struct {
 	Validators []Validator
-	Threshold  int // >=2/3
+	Threshold  int // signatures needed, more than 2/3 of the validators
 }
 
 // NewConsensus creates a new consensus instance
 func NewConsensus(validators []Validator) *Consensus {
-	threshold := (2 * len(validators)) / 3
+	threshold := (2*len(validators))/3 + 1
 	return &Consensus{Validators: validators, Threshold: threshold}
 }
 
-// SignBlock simulates signing the block by validators
-func (c *Consensus) SignBlock(block Block) []string {
-	var signatures []string
+// NewValidator creates a validator with a fresh key pair
+func NewValidator(id string) (Validator, error) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return Validator{}, err
+	}
+	return Validator{ID: id, PubKey: hex.EncodeToString(pub), key: key}, nil
+}
+
+// validatorKey is a validator as stored in the keys file
+type validatorKey struct {
+	ID         string `json:"id"`
+	PrivateKey string `json:"private_key"`
+}
+
+// LoadOrCreateValidators reads validator keys from path, creating keys for
+// the given IDs when the file does not exist yet. Keeping the keys across
+// restarts keeps the signatures on stored blocks verifiable.
+func LoadOrCreateValidators(path string, ids []string) ([]Validator, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		validators := make([]Validator, len(ids))
+		stored := make([]validatorKey, len(ids))
+		for i, id := range ids {
+			if validators[i], err = NewValidator(id); err != nil {
+				return nil, err
+			}
+			stored[i] = validatorKey{ID: id, PrivateKey: hex.EncodeToString(validators[i].key)}
+		}
+		data, err := json.MarshalIndent(stored, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return nil, err
+		}
+		return validators, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stored []validatorKey
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("invalid validator keys file %s: %w", path, err)
+	}
+	validators := make([]Validator, len(stored))
+	for i, v := range stored {
+		key, err := hex.DecodeString(v.PrivateKey)
+		if err != nil || len(key) != ed25519.PrivateKeySize {
+			return nil, fmt.Errorf("invalid key for validator %s in %s", v.ID, path)
+		}
+		validators[i] = Validator{
+			ID:     v.ID,
+			PubKey: hex.EncodeToString(ed25519.PrivateKey(key).Public().(ed25519.PublicKey)),
+			key:    key,
+		}
+	}
+	return validators, nil
+}
+
+// SignBlock signs the block hash with every validator key held locally.
+// Signatures are not collected from other nodes, so only a mock ledger holds
+// the keys of more than one validator; see NewLedger.
+func (c *Consensus) SignBlock(block Block) []CommitSig {
+	hash := HashBlock(block)
+	var signatures []CommitSig
 	for _, v := range c.Validators {
-		// Simulate signature (in real impl, use crypto)
-		data := fmt.Sprintf("%s%s", v.ID, HashBlock(block))
-		hash := sha256.Sum256([]byte(data))
-		sig := hex.EncodeToString(hash[:])
-		signatures = append(signatures, sig)
+		if v.key == nil {
+			continue
+		}
+		signatures = append(signatures, CommitSig{
+			Validator: v.ID,
+			PubKey:    v.PubKey,
+			Signature: hex.EncodeToString(ed25519.Sign(v.key, []byte(hash))),
+		})
 	}
 	return signatures
 }
 
-// VerifyQuorum checks if there are enough signatures (>=2/3)
-func (c *Consensus) VerifyQuorum(signatures []string) bool {
-	return len(signatures) >= c.Threshold
+// VerifyQuorum checks that enough distinct validators validly signed the block hash
+func (c *Consensus) VerifyQuorum(blockHash string, signatures []CommitSig) bool {
+	return c.countValid(blockHash, signatures) >= c.Threshold
+}
+
+// countValid counts the validators with a valid signature over blockHash.
+// Signatures from keys outside the validator set do not count.
+func (c *Consensus) countValid(blockHash string, signatures []CommitSig) int {
+	keys := make(map[string]string, len(c.Validators))
+	for _, v := range c.Validators {
+		keys[v.ID] = v.PubKey
+	}
+
+	signed := make(map[string]bool)
+	for _, sig := range signatures {
+		if keys[sig.Validator] != sig.PubKey || signed[sig.Validator] {
+			continue
+		}
+		pub, err := hex.DecodeString(sig.PubKey)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			continue
+		}
+		signature, err := hex.DecodeString(sig.Signature)
+		if err != nil {
+			continue
+		}
+		if ed25519.Verify(pub, []byte(blockHash), signature) {
+			signed[sig.Validator] = true
+		}
+	}
+	return len(signed)
+}
+
+// VerifyProof checks a Merkle proof end to end: the path leads to the
+// header's Merkle root, the block hash is the hash of that header, and a
+// quorum of validators signed it
+func (c *Consensus) VerifyProof(proof *MerkleProof) error {
+	if !proof.VerifyPath() {
+		return fmt.Errorf("merkle path does not lead to the block's merkle root")
+	}
+	if hashHeader(proof.Header) != proof.BlockHash {
+		return fmt.Errorf("block hash does not match the header")
+	}
+	if n := c.countValid(proof.BlockHash, proof.Commit); n < c.Threshold {
+		return fmt.Errorf("block has %d valid validator signatures, %d needed", n, c.Threshold)
+	}
+	return nil
 }
 
-// ProposeBlock simulates proposing a new block
+// ProposeBlock builds the next block over the given transactions
 func (c *Consensus) ProposeBlock(height int, prevHash string, txs []Transaction, proposer string) Block {
-	root, _ := BuildMerkleTree(txs)
 	header := Header{
 		Height:     height,
 		PrevHash:   prevHash,
-		MerkleRoot: root.Hash,
+		MerkleRoot: MerkleRoot(txs),
 		Proposer:   proposer,
-		Timestamp:  time.Now(),
+		Timestamp:  time.Now().UTC(),
 	}
 	return Block{Header: header, Txs: txs}
 }
@@ -1,3 +1,7 @@
 module decub-gcl
 
 go 1.21
+
+require github.com/decube/httpserve v0.0.0
+
+replace github.com/decube/httpserve => ../../pkg/httpserve
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidTx is returned for a transaction the ledger cannot accept
+	ErrInvalidTx = errors.New("invalid transaction")
+	// ErrDuplicateTx is returned for a transaction ID that is already pending or committed
+	ErrDuplicateTx = errors.New("duplicate transaction")
+	// ErrTxPending is returned for a proof of a transaction that is not committed yet
+	ErrTxPending = errors.New("transaction is pending")
+	// ErrTxNotFound is returned for a transaction that was never submitted
+	ErrTxNotFound = errors.New("transaction not found")
+)
+
+// Ledger collects submitted transactions in a mempool and commits them in
+// blocks signed by the validators. In mock mode every transaction is
+// committed in its own block as soon as it is submitted, signed by every
+// validator with keys held in this one process.
+type Ledger struct {
+	mu      sync.Mutex
+	cons    *Consensus
+	store   *BlockStore
+	mempool []Transaction
+	pending map[string]bool
+	mock    bool
+}
+
+// NewLedger creates a ledger committing to store. Outside mock mode the
+// validator set must be a single validator: blocks are only signed with keys
+// held locally, and one process signing for several validators would make
+// the quorum meaningless.
+func NewLedger(cons *Consensus, store *BlockStore, mock bool) (*Ledger, error) {
+	if !mock && len(cons.Validators) != 1 {
+		return nil, fmt.Errorf("%d validators need signatures collected from other nodes, which is not supported; run a single validator or use -mock", len(cons.Validators))
+	}
+	return &Ledger{cons: cons, store: store, pending: make(map[string]bool), mock: mock}, nil
+}
+
+// Submit adds a transaction to the mempool. In mock mode it is committed
+// right away and the height of its block is returned; otherwise the height
+// is 0 until a later Commit.
+func (l *Ledger) Submit(tx Transaction) (int, error) {
+	if tx.TxID == "" {
+		return 0, fmt.Errorf("%w: tx_id is required", ErrInvalidTx)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, _, committed := l.store.FindTx(tx.TxID); committed || l.pending[tx.TxID] {
+		return 0, fmt.Errorf("%w: %s", ErrDuplicateTx, tx.TxID)
+	}
+	l.mempool = append(l.mempool, tx)
+	l.pending[tx.TxID] = true
+
+	if !l.mock {
+		return 0, nil
+	}
+	block, err := l.commit()
+	if err != nil {
+		return 0, err
+	}
+	return block.Header.Height, nil
+}
+
+// Commit commits the mempool in a new block. It returns nil when the mempool is empty.
+func (l *Ledger) Commit() (*Block, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.commit()
+}
+
+// commit commits the mempool. Callers must hold mu.
+func (l *Ledger) commit() (*Block, error) {
+	if len(l.mempool) == 0 {
+		return nil, nil
+	}
+
+	height := l.store.Height() + 1
+	proposer := l.cons.Validators[(height-1)%len(l.cons.Validators)].ID
+	block := l.cons.ProposeBlock(height, l.store.LastHash(), l.mempool, proposer)
+	// Every validator key is local; NewLedger only allows several in mock mode
+	block.Commit = l.cons.SignBlock(block)
+	if !l.cons.VerifyQuorum(HashBlock(block), block.Commit) {
+		return nil, fmt.Errorf("block %d has %d signatures, %d needed", height, len(block.Commit), l.cons.Threshold)
+	}
+	if err := l.store.Append(block); err != nil {
+		return nil, fmt.Errorf("failed to store block %d: %w", height, err)
+	}
+
+	l.mempool = nil
+	l.pending = make(map[string]bool)
+	return &block, nil
+}
+
+// Run commits the mempool every interval until ctx is done
+func (l *Ledger) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			block, err := l.Commit()
+			if err != nil {
+				log.Printf("Failed to commit block: %v", err)
+				continue
+			}
+			if block != nil {
+				log.Printf("Committed block %d with %d txs", block.Header.Height, len(block.Txs))
+			}
+		}
+	}
+}
+
+// Block returns the committed block at height
+func (l *Ledger) Block(height int) (Block, bool) {
+	return l.store.Block(height)
+}
+
+// Proof returns the inclusion proof of a committed transaction
+func (l *Ledger) Proof(txID string) (*MerkleProof, error) {
+	block, index, ok := l.store.FindTx(txID)
+	if !ok {
+		l.mu.Lock()
+		pending := l.pending[txID]
+		l.mu.Unlock()
+		if pending {
+			return nil, fmt.Errorf("%w: %s", ErrTxPending, txID)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrTxNotFound, txID)
+	}
+
+	return &MerkleProof{
+		TxID:      txID,
+		TxHash:    HashTransaction(block.Txs[index]),
+		Index:     index,
+		Path:      MerklePath(block.Txs, index),
+		Header:    block.Header,
+		BlockHash: HashBlock(block),
+		Commit:    block.Commit,
+	}, nil
+}
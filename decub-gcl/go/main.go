@@ -1,45 +1,77 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
-	"net/http"
+	"net"
+	"path/filepath"
+	"strings"
 	"time"
+
+	"github.com/decube/httpserve"
 )
 
 func main() {
-	// Initialize consensus with mock validators
-	validators := []Validator{
-		{ID: "val1", PubKey: "pub1"},
-		{ID: "val2", PubKey: "pub2"},
-		{ID: "val3", PubKey: "pub3"},
+	envPort, err := httpserve.PortFromEnv()
+	if err != nil {
+		log.Fatal(err)
 	}
-	cons = NewConsensus(validators)
+	port := flag.Int("port", envPort, "port to serve the REST API on (env PORT); 0 picks a free port")
+	addr := flag.String("addr", "", "address to serve the REST API on; overrides --port")
+	dataDir := flag.String("data-dir", "data", "directory holding committed blocks and validator keys")
+	validatorIDs := flag.String("validators", "val1", "comma-separated validator IDs; more than one requires -mock")
+	blockInterval := flag.Duration("block-interval", time.Second, "how often pending transactions are committed")
+	mock := flag.Bool("mock", false, "offline development mode: keep blocks in memory and commit each transaction on submission")
+	flag.Parse()
 
-	// Sample block JSON (as comment)
-	// {
-	//   "header": {
-	//     "height": 1,
-	//     "prev_hash": "",
-	//     "merkle_root": "hash...",
-	//     "proposer": "validator1",
-	//     "timestamp": "2023-01-01T00:00:00Z"
-	//   },
-	//   "txs": [
-	//     {
-	//       "tx_id": "tx1",
-	//       "type": "transfer",
-	//       "origin": "user1",
-	//       "payload": "data",
-	//       "sig": "sig1"
-	//     }
-	//   ]
-	// }
+	ids := strings.Split(*validatorIDs, ",")
+	var (
+		validators []Validator
+		store      *BlockStore
+	)
+	if *mock {
+		// Throwaway keys and an in-memory chain, gone on restart
+		for _, id := range ids {
+			v, err := NewValidator(id)
+			if err != nil {
+				log.Fatalf("Failed to create validator %s: %v", id, err)
+			}
+			validators = append(validators, v)
+		}
+		store, err = OpenBlockStore("")
+	} else {
+		validators, err = LoadOrCreateValidators(filepath.Join(*dataDir, "validators.json"), ids)
+		if err != nil {
+			log.Fatalf("Failed to load validator keys: %v", err)
+		}
+		store, err = OpenBlockStore(filepath.Join(*dataDir, "blocks"))
+	}
+	if err != nil {
+		log.Fatalf("Failed to open block store: %v", err)
+	}
 
-	http.HandleFunc("/gcl/tx", SubmitTx)
-	http.HandleFunc("/gcl/block/", GetBlock)
-	http.HandleFunc("/gcl/proof/", GetProof)
+	ledger, err := NewLedger(NewConsensus(validators), store, *mock)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if !*mock {
+		go ledger.Run(ctx, *blockInterval)
+	}
 
-	fmt.Println("Starting GCL server on :8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	if *addr == "" {
+		*addr = httpserve.PortAddr(*port)
+	}
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", *addr, err)
+	}
+	fmt.Printf("GCL server listening on %s (mock: %v, height: %d)\n", lis.Addr(), *mock, store.Height())
+	if err := httpserve.ServeUntilSignal(lis, NewAPI(ledger).Routes()); err != nil {
+		log.Fatalf("GCL server failed: %v", err)
+	}
+	fmt.Println("GCL server stopped")
 }
@@ -5,58 +5,74 @@ import (
 	"encoding/hex"
 )
 
-// BuildMerkleTree builds a Merkle tree from transactions and returns the root hash
-func BuildMerkleTree(txs []Transaction) (*MerkleNode, string) {
+// MerkleRoot computes the Merkle root over the transaction hashes. An odd node
+// at any level is paired with itself; a block without transactions has an
+// empty root.
+func MerkleRoot(txs []Transaction) string {
 	if len(txs) == 0 {
-		return nil, ""
+		return ""
 	}
 
-	nodes := make([]*MerkleNode, len(txs))
-	for i, tx := range txs {
-		hash := HashTransaction(tx)
-		nodes[i] = &MerkleNode{Hash: hash}
+	level := merkleLeaves(txs)
+	for len(level) > 1 {
+		level = nextMerkleLevel(level)
 	}
+	return level[0]
+}
+
+// MerklePath returns the sibling hashes from the transaction at index up to the root
+func MerklePath(txs []Transaction, index int) []ProofStep {
+	var path []ProofStep
 
-	for len(nodes) > 1 {
-		var newNodes []*MerkleNode
-		for i := 0; i < len(nodes); i += 2 {
-			left := nodes[i]
-			var right *MerkleNode
-			if i+1 < len(nodes) {
-				right = nodes[i+1]
-			} else {
-				right = left // Duplicate for odd number
-			}
-			hash := sha256.Sum256([]byte(left.Hash + right.Hash))
-			newNodes = append(newNodes, &MerkleNode{Hash: hex.EncodeToString(hash[:]), Left: left, Right: right})
+	level := merkleLeaves(txs)
+	for len(level) > 1 {
+		sibling := index ^ 1
+		if sibling >= len(level) {
+			sibling = index // odd node, paired with itself
 		}
-		nodes = newNodes
-	}
+		path = append(path, ProofStep{Hash: level[sibling], Left: sibling < index})
 
-	return nodes[0], nodes[0].Hash
+		level = nextMerkleLevel(level)
+		index /= 2
+	}
+	return path
 }
 
-// GenerateMerkleProof generates a Merkle proof for a transaction at the given index
-func GenerateMerkleProof(root *MerkleNode, index int) MerkleProof {
-	var proof MerkleProof
-	proof.Index = index
-	current := root
-	for current.Left != nil || current.Right != nil {
-		if index%2 == 0 {
-			if current.Right != nil {
-				proof.Hashes = append(proof.Hashes, current.Right.Hash)
-			}
+// VerifyPath recomputes the Merkle root from the transaction hash and the
+// path and checks it against the header
+func (p *MerkleProof) VerifyPath() bool {
+	current := p.TxHash
+	for _, step := range p.Path {
+		if step.Left {
+			current = hashPair(step.Hash, current)
 		} else {
-			if current.Left != nil {
-				proof.Hashes = append(proof.Hashes, current.Left.Hash)
-			}
+			current = hashPair(current, step.Hash)
 		}
-		if index%2 == 0 {
-			current = current.Left
-		} else {
-			current = current.Right
+	}
+	return current != "" && current == p.Header.MerkleRoot
+}
+
+func merkleLeaves(txs []Transaction) []string {
+	leaves := make([]string, len(txs))
+	for i, tx := range txs {
+		leaves[i] = HashTransaction(tx)
+	}
+	return leaves
+}
+
+func nextMerkleLevel(level []string) []string {
+	next := make([]string, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		right := level[i]
+		if i+1 < len(level) {
+			right = level[i+1]
 		}
-		index /= 2
+		next = append(next, hashPair(level[i], right))
 	}
-	return proof
+	return next
+}
+
+func hashPair(left, right string) string {
+	hash := sha256.Sum256([]byte(left + right))
+	return hex.EncodeToString(hash[:])
 }
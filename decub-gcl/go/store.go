@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// txLocation is where a committed transaction is stored
+type txLocation struct {
+	Height int
+	Index  int
+}
+
+// BlockStore keeps committed blocks, one JSON file per height, and indexes
+// their transactions by ID. With no directory blocks are only kept in memory.
+type BlockStore struct {
+	mu     sync.RWMutex
+	dir    string
+	blocks []Block
+	txs    map[string]txLocation
+}
+
+// OpenBlockStore loads the blocks stored in dir, or starts an in-memory
+// store if dir is empty
+func OpenBlockStore(dir string) (*BlockStore, error) {
+	s := &BlockStore{dir: dir, txs: make(map[string]txLocation)}
+	if dir == "" {
+		return s, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	for height := 1; ; height++ {
+		data, err := os.ReadFile(s.blockPath(height))
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var block Block
+		if err := json.Unmarshal(data, &block); err != nil {
+			return nil, fmt.Errorf("corrupt block %d: %w", height, err)
+		}
+		s.index(block)
+	}
+	return s, nil
+}
+
+func (s *BlockStore) blockPath(height int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%08d.json", height))
+}
+
+// index appends a block to the in-memory chain. Callers must hold mu or own s.
+func (s *BlockStore) index(block Block) {
+	s.blocks = append(s.blocks, block)
+	for i, tx := range block.Txs {
+		s.txs[tx.TxID] = txLocation{Height: block.Header.Height, Index: i}
+	}
+}
+
+// Height returns the height of the latest block, 0 before the first
+func (s *BlockStore) Height() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.blocks)
+}
+
+// LastHash returns the hash of the latest block, empty before the first
+func (s *BlockStore) LastHash() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.blocks) == 0 {
+		return ""
+	}
+	return HashBlock(s.blocks[len(s.blocks)-1])
+}
+
+// Append stores the next block. The file is written before the block becomes
+// visible, through a rename so a crash never leaves a partial block.
+func (s *BlockStore) Append(block Block) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if block.Header.Height != len(s.blocks)+1 {
+		return fmt.Errorf("block height %d does not follow %d", block.Header.Height, len(s.blocks))
+	}
+	if s.dir != "" {
+		data, err := json.Marshal(block)
+		if err != nil {
+			return err
+		}
+		tmp := s.blockPath(block.Header.Height) + ".tmp"
+		if err := os.WriteFile(tmp, data, 0o644); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, s.blockPath(block.Header.Height)); err != nil {
+			return err
+		}
+	}
+	s.index(block)
+	return nil
+}
+
+// Block returns the block at height
+func (s *BlockStore) Block(height int) (Block, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if height < 1 || height > len(s.blocks) {
+		return Block{}, false
+	}
+	return s.blocks[height-1], true
+}
+
+// FindTx returns the block holding a committed transaction and its index in it
+func (s *BlockStore) FindTx(txID string) (Block, int, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	loc, ok := s.txs[txID]
+	if !ok {
+		return Block{}, 0, false
+	}
+	return s.blocks[loc.Height-1], loc.Index, true
+}
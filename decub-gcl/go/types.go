@@ -3,16 +3,17 @@ package main
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"strconv"
 	"time"
 )
 
 // Transaction represents a transaction in the block
 type Transaction struct {
-	TxID   string `json:"tx_id"`
-	Type   string `json:"type"`
-	Origin string `json:"origin"`
+	TxID    string `json:"tx_id"`
+	Type    string `json:"type"`
+	Origin  string `json:"origin"`
 	Payload string `json:"payload"`
-	Sig    string `json:"sig"`
+	Sig     string `json:"sig"`
 }
 
 // Header represents the block header
@@ -28,19 +29,35 @@ type Header struct {
 type Block struct {
 	Header Header        `json:"header"`
 	Txs    []Transaction `json:"txs"`
+	// Commit holds the validator signatures over the block hash
+	Commit []CommitSig `json:"commit"`
 }
 
-// MerkleNode represents a node in the Merkle tree
-type MerkleNode struct {
-	Hash  string
-	Left  *MerkleNode
-	Right *MerkleNode
+// CommitSig is a validator's Ed25519 signature over a block hash. Keys and
+// signatures are hex.
+type CommitSig struct {
+	Validator string `json:"validator"`
+	PubKey    string `json:"pub_key"`
+	Signature string `json:"signature"`
 }
 
-// MerkleProof represents a proof for a transaction
+// ProofStep is one sibling hash on the path from a transaction to the Merkle root
+type ProofStep struct {
+	Hash string `json:"hash"`
+	Left bool   `json:"left"` // the sibling is hashed on the left
+}
+
+// MerkleProof shows that a transaction is in a committed block: the path
+// leads from the transaction hash to the header's Merkle root, and the
+// commit signs the hash of that header
 type MerkleProof struct {
-	Hashes []string `json:"hashes"`
-	Index  int      `json:"index"`
+	TxID      string      `json:"tx_id"`
+	TxHash    string      `json:"tx_hash"`
+	Index     int         `json:"index"`
+	Path      []ProofStep `json:"path"`
+	Header    Header      `json:"header"`
+	BlockHash string      `json:"block_hash"`
+	Commit    []CommitSig `json:"commit"`
 }
 
 // HashTransaction computes the hash of a transaction
@@ -50,9 +67,14 @@ func HashTransaction(tx Transaction) string {
 	return hex.EncodeToString(hash[:])
 }
 
-// HashBlock computes the hash of a block
+// HashBlock computes the hash of a block from its header. The timestamp is
+// hashed in UTC RFC 3339 form so the hash survives a JSON round trip.
 func HashBlock(block Block) string {
-	data := block.Header.PrevHash + block.Header.MerkleRoot + block.Header.Proposer + block.Header.Timestamp.String()
+	return hashHeader(block.Header)
+}
+
+func hashHeader(h Header) string {
+	data := strconv.Itoa(h.Height) + h.PrevHash + h.MerkleRoot + h.Proposer + h.Timestamp.UTC().Format(time.RFC3339Nano)
 	hash := sha256.Sum256([]byte(data))
 	return hex.EncodeToString(hash[:])
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// Anti-entropy message types
+const (
+	MsgMerkleRoot  = "merkle_root"
+	MsgSyncRequest = "sync_request"
+	MsgFullState   = "full_state"
+)
+
+// AntiEntropyMessage is the envelope for everything sent on decub/anti-entropy
+type AntiEntropyMessage struct {
+	Type        string                 `json:"type"`
+	MerkleRoot  string                 `json:"merkle_root,omitempty"`
+	State       map[string]interface{} `json:"state,omitempty"`
+	VectorClock VectorClock            `json:"vector_clock,omitempty"`
+}
+
+// encodeAntiEntropy marshals an anti-entropy envelope
+func encodeAntiEntropy(msg *AntiEntropyMessage) []byte {
+	data, _ := json.Marshal(msg)
+	return data
+}
+
+// processAntiEntropy handles one anti-entropy message and returns the reply
+// to publish, or nil if none is needed
+func (n *GossipNode) processAntiEntropy(data []byte) (*AntiEntropyMessage, error) {
+	var msg AntiEntropyMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal anti-entropy: %w", err)
+	}
+
+	switch msg.Type {
+	case MsgMerkleRoot:
+		n.mu.RLock()
+		localRoot := n.merkleRoot
+		n.mu.RUnlock()
+		if msg.MerkleRoot != localRoot {
+			log.Printf("Merkle root mismatch detected, requesting full sync")
+			return &AntiEntropyMessage{Type: MsgSyncRequest}, nil
+		}
+		return nil, nil
+
+	case MsgSyncRequest:
+		state, clock := n.catalog.FullState()
+		return &AntiEntropyMessage{Type: MsgFullState, State: state, VectorClock: clock}, nil
+
+	case MsgFullState:
+		n.catalog.MergeState(msg.State, msg.VectorClock)
+		log.Printf("Applied full state sync")
+		return nil, nil
+
+	default:
+		return nil, fmt.Errorf("unknown anti-entropy message type %q", msg.Type)
+	}
+}
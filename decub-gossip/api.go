@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+)
+
+// startHTTP serves the node's HTTP API on addr in the background
+func (n *GossipNode) startHTTP(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	n.httpServer = &http.Server{Handler: n.routes()}
+	n.httpAddr = lis.Addr().String()
+	log.Printf("Gossip HTTP API listening on %s", n.httpAddr)
+
+	go func() {
+		if err := n.httpServer.Serve(lis); err != nil && err != http.ErrServerClosed {
+			log.Printf("Gossip HTTP API stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// routes returns the HTTP API handler
+func (n *GossipNode) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/status", n.handleStatus)
+	mux.HandleFunc("/api/v1/sync", n.handleSync)
+	return mux
+}
+
+func (n *GossipNode) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, n.GetStatus())
+}
+
+func (n *GossipNode) handleSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	published, err := n.Sync()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	n.mu.RLock()
+	root := n.merkleRoot
+	n.mu.RUnlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":           "synced",
+		"deltas_published": published,
+		"merkle_root":      root,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
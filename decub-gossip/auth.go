@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Peer authentication modes
+const (
+	// AuthOpen accepts gossip from any peer; intended for development
+	AuthOpen = "open"
+	// AuthAllowlist accepts gossip only from the configured peer IDs
+	AuthAllowlist = "allowlist"
+)
+
+// identityFile holds the node's libp2p private key inside its data directory
+const identityFile = "identity.key"
+
+// gossipTopics lists the topics whose messages are authenticated
+var gossipTopics = []string{"decub/delta", "decub/anti-entropy"}
+
+// peerAllowlist holds the peers allowed to publish and relay gossip. A nil
+// allowlist allows every peer.
+type peerAllowlist map[peer.ID]struct{}
+
+// newPeerAllowlist builds the allowlist for the configured auth mode
+func newPeerAllowlist(config *GossipConfig) (peerAllowlist, error) {
+	switch config.AuthMode {
+	case "", AuthOpen:
+		return nil, nil
+	case AuthAllowlist:
+	default:
+		return nil, fmt.Errorf("unknown auth mode %q", config.AuthMode)
+	}
+
+	allowed := make(peerAllowlist, len(config.AllowedPeers))
+	for _, s := range config.AllowedPeers {
+		id, err := peer.Decode(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed peer %q: %w", s, err)
+		}
+		allowed[id] = struct{}{}
+	}
+	return allowed, nil
+}
+
+// allows reports whether id may publish or relay gossip
+func (a peerAllowlist) allows(id peer.ID) bool {
+	if a == nil {
+		return true
+	}
+	_, ok := a[id]
+	return ok
+}
+
+// loadIdentity returns the private key stored in dataDir, generating and
+// saving a new one on first start
+func loadIdentity(dataDir string) (crypto.PrivKey, error) {
+	path := filepath.Join(dataDir, identityFile)
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		priv, err := crypto.UnmarshalPrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode identity %s: %w", path, err)
+		}
+		return priv, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read identity %s: %w", path, err)
+	}
+
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	data, err = crypto.MarshalPrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save identity %s: %w", path, err)
+	}
+	return priv, nil
+}
+
+// registerValidators installs validateMessage on every gossip topic
+func (n *GossipNode) registerValidators() error {
+	for _, topic := range gossipTopics {
+		if err := n.pubsub.RegisterTopicValidator(topic, n.validateMessage); err != nil {
+			return fmt.Errorf("failed to register validator for %s: %w", topic, err)
+		}
+	}
+	return nil
+}
+
+// validateMessage rejects gossip authored or relayed by a peer outside the
+// allowlist. Both IDs are authenticated: pubsub checks each message's
+// signature against its author's key, and the transport handshake proves the
+// relaying peer holds the key behind its ID. An unauthorized relay is also
+// blacklisted and disconnected so it cannot keep flooding the mesh.
+func (n *GossipNode) validateMessage(ctx context.Context, from peer.ID, msg *pubsub.Message) pubsub.ValidationResult {
+	if !n.allowlist.allows(from) {
+		log.Printf("Rejecting gossip relayed by unauthorized peer %s", from)
+		go n.dropPeer(from)
+		return pubsub.ValidationReject
+	}
+	if author := msg.GetFrom(); !n.allowlist.allows(author) {
+		log.Printf("Rejecting gossip authored by unauthorized peer %s", author)
+		return pubsub.ValidationReject
+	}
+	return pubsub.ValidationAccept
+}
+
+// dropPeer blacklists id in pubsub and closes its connections
+func (n *GossipNode) dropPeer(id peer.ID) {
+	if n.pubsub != nil {
+		n.pubsub.BlacklistPeer(id)
+	}
+	if n.host != nil {
+		n.host.Network().ClosePeer(id)
+	}
+}
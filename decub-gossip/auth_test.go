@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func newTestPeerID(t *testing.T) peer.ID {
+	t.Helper()
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	id, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		t.Fatalf("failed to derive peer ID: %v", err)
+	}
+	return id
+}
+
+// deltaMessageFrom builds a pubsub delta message authored by author
+func deltaMessageFrom(author peer.ID) *pubsub.Message {
+	topic := "decub/delta"
+	return &pubsub.Message{Message: &pb.Message{From: []byte(author), Topic: &topic, Data: []byte(`[]`)}}
+}
+
+func TestValidateMessageEnforcesAllowlist(t *testing.T) {
+	allowed := newTestPeerID(t)
+	intruder := newTestPeerID(t)
+
+	cfg := DefaultConfig()
+	cfg.AuthMode = AuthAllowlist
+	cfg.AllowedPeers = []string{allowed.String()}
+	allowlist, err := newPeerAllowlist(cfg)
+	if err != nil {
+		t.Fatalf("failed to build allowlist: %v", err)
+	}
+	node := &GossipNode{allowlist: allowlist}
+
+	cases := []struct {
+		name   string
+		from   peer.ID
+		author peer.ID
+		want   pubsub.ValidationResult
+	}{
+		{"authorized author and relay", allowed, allowed, pubsub.ValidationAccept},
+		{"unauthorized author", allowed, intruder, pubsub.ValidationReject},
+		{"unauthorized relay", intruder, allowed, pubsub.ValidationReject},
+	}
+	for _, tc := range cases {
+		if got := node.validateMessage(context.Background(), tc.from, deltaMessageFrom(tc.author)); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	open := &GossipNode{}
+	if got := open.validateMessage(context.Background(), intruder, deltaMessageFrom(intruder)); got != pubsub.ValidationAccept {
+		t.Fatalf("open mode rejected a message: %v", got)
+	}
+}
+
+func TestLoadIdentityIsStable(t *testing.T) {
+	dir := t.TempDir()
+
+	first, err := loadIdentity(dir)
+	if err != nil {
+		t.Fatalf("failed to create identity: %v", err)
+	}
+	second, err := loadIdentity(dir)
+	if err != nil {
+		t.Fatalf("failed to reload identity: %v", err)
+	}
+	if !first.Equals(second) {
+		t.Fatal("identity changed across restarts")
+	}
+}
+
+func TestValidateChecksAllowlistConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AuthMode = AuthAllowlist
+	cfg.AllowedPeers = []string{newTestPeerID(t).String()}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("valid allowlist config rejected: %v", err)
+	}
+
+	for name, mutate := range map[string]func(*GossipConfig){
+		"empty allowlist":    func(c *GossipConfig) { c.AllowedPeers = nil },
+		"bad peer ID":        func(c *GossipConfig) { c.AllowedPeers = []string{"not-a-peer-id"} },
+		"unknown mode":       func(c *GossipConfig) { c.AuthMode = "strict" },
+		"peers in open mode": func(c *GossipConfig) { c.AuthMode = AuthOpen },
+	} {
+		bad := *cfg
+		mutate(&bad)
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+// VectorClock represents a vector clock for causal ordering
+type VectorClock map[string]int64
+
+// Merge merges another vector clock
+func (vc VectorClock) Merge(other VectorClock) {
+	for node, time := range other {
+		if time > vc[node] {
+			vc[node] = time
+		}
+	}
+}
+
+// Compare compares two vector clocks
+// Returns: -1 if vc < other, 0 if concurrent or equal, 1 if vc > other
+func (vc VectorClock) Compare(other VectorClock) int {
+	vcLess := false
+	otherLess := false
+
+	for node := range vc {
+		if vc[node] < other[node] {
+			vcLess = true
+		} else if vc[node] > other[node] {
+			otherLess = true
+		}
+	}
+
+	for node := range other {
+		if vc[node] < other[node] {
+			vcLess = true
+		} else if vc[node] > other[node] {
+			otherLess = true
+		}
+	}
+
+	if vcLess && otherLess {
+		return 0 // concurrent
+	} else if vcLess {
+		return -1 // vc < other
+	} else if otherLess {
+		return 1 // vc > other
+	}
+	return 0 // equal
+}
+
+// Copy returns an independent copy of the clock
+func (vc VectorClock) Copy() VectorClock {
+	copied := make(VectorClock, len(vc))
+	for node, time := range vc {
+		copied[node] = time
+	}
+	return copied
+}
+
+// deliverable reports whether a delta stamped with clock by sender can be
+// applied on top of vc: it must be the sender's next event, and vc must
+// already include everything the sender had seen from other nodes
+func (vc VectorClock) deliverable(sender string, clock VectorClock) bool {
+	if clock[sender] != vc[sender]+1 {
+		return false
+	}
+	for node, time := range clock {
+		if node != sender && time > vc[node] {
+			return false
+		}
+	}
+	return true
+}
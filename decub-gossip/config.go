@@ -3,9 +3,15 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/multiformats/go-multiaddr"
 )
 
 // GossipConfig holds configuration for the gossip synchronization layer
@@ -29,6 +35,17 @@ type GossipConfig struct {
 	// Catalog service configuration
 	CatalogAddr string `json:"catalog_addr"`
 
+	// HTTP API address for status and sync; empty disables the API
+	HTTPAddr string `json:"http_addr"`
+
+	// Storage configuration; an empty DataDir resolves to data/<node_id>
+	DataDir string `json:"data_dir"`
+
+	// Peer authentication: "open" accepts gossip from any peer, "allowlist"
+	// only from the peer IDs in AllowedPeers
+	AuthMode     string   `json:"auth_mode"`
+	AllowedPeers []string `json:"allowed_peers"`
+
 	// TLS configuration
 	EnableTLS     bool   `json:"enable_tls"`
 	CertFile      string `json:"cert_file"`
@@ -56,6 +73,9 @@ func DefaultConfig() *GossipConfig {
 		SyncInterval:         60 * time.Second,
 		MerkleTreeDepth:      16,
 		CatalogAddr:          "http://localhost:8080",
+		HTTPAddr:             ":8080",
+		AuthMode:             AuthOpen,
+		AllowedPeers:         []string{},
 		EnableTLS:            false,
 		CertFile:             "",
 		KeyFile:             "",
@@ -129,6 +149,21 @@ func (c *GossipConfig) overrideFromEnv() {
 	if catalogAddr := os.Getenv("DECUB_CATALOG_ADDR"); catalogAddr != "" {
 		c.CatalogAddr = catalogAddr
 	}
+	if httpAddr := os.Getenv("DECUB_HTTP_ADDR"); httpAddr != "" {
+		c.HTTPAddr = httpAddr
+	} else if port := os.Getenv("PORT"); port != "" {
+		// PORT only moves the HTTP API; the p2p port comes from listen_addr
+		c.HTTPAddr = ":" + port
+	}
+	if dataDir := os.Getenv("DECUB_DATA_DIR"); dataDir != "" {
+		c.DataDir = dataDir
+	}
+	if authMode := os.Getenv("DECUB_AUTH_MODE"); authMode != "" {
+		c.AuthMode = authMode
+	}
+	if allowedPeers := os.Getenv("DECUB_ALLOWED_PEERS"); allowedPeers != "" {
+		c.AllowedPeers = parseCommaSeparatedList(allowedPeers)
+	}
 	if enableTLS := os.Getenv("DECUB_ENABLE_TLS"); enableTLS != "" {
 		if enable, err := strconv.ParseBool(enableTLS); err == nil {
 			c.EnableTLS = enable
@@ -148,37 +183,131 @@ func (c *GossipConfig) overrideFromEnv() {
 	}
 }
 
-// Validate checks if the configuration is valid
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d configuration problem(s): %s", len(e.Problems), strings.Join(e.Problems, "; "))
+}
+
+// Validate checks the configuration and reports every problem at once as a
+// *ValidationError, so bad addresses fail fast instead of deep inside libp2p
 func (c *GossipConfig) Validate() error {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
 	if c.NodeID == "" {
-		return fmt.Errorf("node_id cannot be empty")
+		addf("node_id cannot be empty")
 	}
 	if c.ListenAddr == "" {
-		return fmt.Errorf("listen_addr cannot be empty")
+		addf("listen_addr cannot be empty")
+	} else if _, err := multiaddr.NewMultiaddr(c.ListenAddr); err != nil {
+		addf("listen_addr %q is not a valid multiaddr: %v", c.ListenAddr, err)
+	}
+	if c.AdvertiseAddr != "" {
+		if _, err := multiaddr.NewMultiaddr(c.AdvertiseAddr); err != nil {
+			addf("advertise_addr %q is not a valid multiaddr: %v", c.AdvertiseAddr, err)
+		}
+	}
+	for i, peerAddr := range c.InitialPeers {
+		if err := validatePeerAddr(peerAddr); err != nil {
+			addf("initial_peers[%d] %q: %v", i, peerAddr, err)
+		}
 	}
 	if c.GossipInterval <= 0 {
-		return fmt.Errorf("gossip_interval must be positive")
+		addf("gossip_interval must be positive")
 	}
 	if c.AntiEntropyInterval <= 0 {
-		return fmt.Errorf("anti_entropy_interval must be positive")
+		addf("anti_entropy_interval must be positive")
 	}
 	if c.SyncInterval <= 0 {
-		return fmt.Errorf("sync_interval must be positive")
+		addf("sync_interval must be positive")
 	}
 	if c.MerkleTreeDepth <= 0 {
-		return fmt.Errorf("merkle_tree_depth must be positive")
+		addf("merkle_tree_depth must be positive")
 	}
 	if c.CatalogAddr == "" {
-		return fmt.Errorf("catalog_addr cannot be empty")
+		addf("catalog_addr cannot be empty")
+	} else if u, err := url.Parse(c.CatalogAddr); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		addf("catalog_addr %q must be an http(s) URL", c.CatalogAddr)
+	}
+	if c.HTTPAddr != "" {
+		if _, port, err := net.SplitHostPort(c.HTTPAddr); err != nil {
+			addf("http_addr %q must be host:port", c.HTTPAddr)
+		} else if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			addf("http_addr %q has an invalid port", c.HTTPAddr)
+		} else if port != "0" && port == c.p2pTCPPort() {
+			addf("http_addr port %s is also the p2p port of listen_addr", port)
+		}
+	}
+	switch c.AuthMode {
+	case AuthOpen:
+		if len(c.AllowedPeers) > 0 {
+			addf("allowed_peers is only used when auth_mode is %q", AuthAllowlist)
+		}
+	case AuthAllowlist:
+		if len(c.AllowedPeers) == 0 {
+			addf("allowed_peers cannot be empty when auth_mode is %q", AuthAllowlist)
+		}
+		for i, id := range c.AllowedPeers {
+			if _, err := multiaddr.NewMultiaddr("/p2p/" + id); err != nil {
+				addf("allowed_peers[%d] %q is not a valid peer ID", i, id)
+			}
+		}
+	default:
+		addf("auth_mode must be %q or %q", AuthOpen, AuthAllowlist)
 	}
 	if c.EnableTLS {
 		if c.CertFile == "" || c.KeyFile == "" {
-			return fmt.Errorf("cert_file and key_file are required when TLS is enabled")
+			addf("cert_file and key_file are required when TLS is enabled")
 		}
 	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// p2pTCPPort returns the TCP port of listen_addr, or "" if it has none
+func (c *GossipConfig) p2pTCPPort() string {
+	maddr, err := multiaddr.NewMultiaddr(c.ListenAddr)
+	if err != nil {
+		return ""
+	}
+	port, _ := maddr.ValueForProtocol(multiaddr.P_TCP)
+	return port
+}
+
+// validatePeerAddr checks that addr is a dialable multiaddr ending in a peer ID
+func validatePeerAddr(addr string) error {
+	maddr, err := multiaddr.NewMultiaddr(addr)
+	if err != nil {
+		return fmt.Errorf("not a valid multiaddr: %w", err)
+	}
+	if _, err := maddr.ValueForProtocol(multiaddr.P_P2P); err != nil {
+		return fmt.Errorf("missing /p2p/<peer-id> component")
+	}
+	transport, _ := multiaddr.SplitLast(maddr)
+	if transport == nil {
+		return fmt.Errorf("missing transport address before /p2p/")
+	}
 	return nil
 }
 
+// StorageDir returns the directory holding this node's databases, so nodes
+// sharing a working directory never open the same LevelDB
+func (c *GossipConfig) StorageDir() string {
+	if c.DataDir != "" {
+		return c.DataDir
+	}
+	return filepath.Join("data", c.NodeID)
+}
+
 // parseCommaSeparatedList parses a comma-separated string into a slice
 func parseCommaSeparatedList(s string) []string {
 	var result []string
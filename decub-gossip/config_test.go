@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	const peerID = "QmYyQSo1c1Ym7orWxLYvCrM2EmxFTANf8wXmmE7DWjhx5N"
+
+	cfg := DefaultConfig()
+	cfg.InitialPeers = []string{
+		"/ip4/10.0.0.1/tcp/4001/p2p/" + peerID,          // valid
+		"/ip4/10.0.0.2/tcp/4001",                        // no peer ID
+		"not-a-multiaddr",                               // unparseable
+		"/dns4/peer.example.com/tcp/4001/p2p/" + peerID, // valid
+		"/ip4/10.0.0.3/tcp/4001/p2p/not-a-peer-id",      // bad peer ID
+	}
+	cfg.GossipInterval = 0
+	cfg.CatalogAddr = "localhost:8080"
+
+	err := cfg.Validate()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected *ValidationError, got %v", err)
+	}
+
+	wantMentioned := []string{
+		"initial_peers[1]",
+		"initial_peers[2]",
+		"initial_peers[4]",
+		"gossip_interval",
+		"catalog_addr",
+	}
+	if len(verr.Problems) != len(wantMentioned) {
+		t.Fatalf("expected %d problems, got %d: %v", len(wantMentioned), len(verr.Problems), verr.Problems)
+	}
+	for i, want := range wantMentioned {
+		if !strings.Contains(verr.Problems[i], want) {
+			t.Errorf("problem %d = %q, want it to mention %s", i, verr.Problems[i], want)
+		}
+	}
+	for _, problem := range verr.Problems {
+		if strings.Contains(problem, "initial_peers[0]") || strings.Contains(problem, "initial_peers[3]") {
+			t.Errorf("valid peer reported as invalid: %s", problem)
+		}
+	}
+}
+
+func TestValidateAcceptsDefaults(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Fatalf("default config should be valid: %v", err)
+	}
+}
+
+func TestValidateRejectsBadListenAddr(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ListenAddr = "0.0.0.0:4001"
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "listen_addr") {
+		t.Fatalf("expected listen_addr problem, got %v", err)
+	}
+}
+
+func TestStorageDirDefaultsPerNode(t *testing.T) {
+	t.Setenv("DECUB_DATA_DIR", "")
+
+	a, b := DefaultConfig(), DefaultConfig()
+	a.NodeID, b.NodeID = "node-a", "node-b"
+	if a.StorageDir() == b.StorageDir() {
+		t.Fatalf("nodes share storage dir %s", a.StorageDir())
+	}
+	if want := filepath.Join("data", "node-a"); a.StorageDir() != want {
+		t.Fatalf("expected %s, got %s", want, a.StorageDir())
+	}
+
+	t.Setenv("DECUB_DATA_DIR", "/var/lib/decub")
+	if dir := LoadConfigFromEnv().StorageDir(); dir != "/var/lib/decub" {
+		t.Fatalf("DECUB_DATA_DIR not honoured, got %s", dir)
+	}
+}
+
+func TestHTTPPortIsSeparateFromP2PPort(t *testing.T) {
+	t.Setenv("DECUB_HTTP_ADDR", "")
+	t.Setenv("PORT", "9090")
+	cfg := LoadConfigFromEnv()
+	if cfg.HTTPAddr != ":9090" {
+		t.Fatalf("PORT not honoured, got %s", cfg.HTTPAddr)
+	}
+
+	// DECUB_HTTP_ADDR is the more specific setting
+	t.Setenv("DECUB_HTTP_ADDR", "127.0.0.1:7070")
+	if addr := LoadConfigFromEnv().HTTPAddr; addr != "127.0.0.1:7070" {
+		t.Fatalf("DECUB_HTTP_ADDR not preferred over PORT, got %s", addr)
+	}
+
+	cfg.ListenAddr = "/ip4/0.0.0.0/tcp/9090"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "p2p port") {
+		t.Fatalf("expected a port clash, got %v", err)
+	}
+	cfg.ListenAddr = "/ip4/0.0.0.0/tcp/4001"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("distinct ports rejected: %v", err)
+	}
+
+	cfg.HTTPAddr = "9090"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "http_addr") {
+		t.Fatalf("expected an http_addr problem, got %v", err)
+	}
+}
@@ -2,19 +2,22 @@ package main
 
 import (
 	"context"
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/libp2p/go-libp2p"
-	"github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-pubsub"
@@ -58,11 +61,13 @@ func (r *LWWRegister) Merge(other *LWWRegister) {
 // Delta represents a CRDT delta for gossip
 type Delta struct {
 	NodeID      string                 `json:"node_id"`
-	VectorClock map[string]int64       `json:"vector_clock"`
+	VectorClock VectorClock            `json:"vector_clock"`
 	Type        string                 `json:"type"` // "orset" or "lww"
 	Key         string                 `json:"key"`
 	Data        map[string]interface{} `json:"data"`
 	Timestamp   int64                  `json:"timestamp"`
+
+	outSeq uint64 // position in the local outbound buffer
 }
 
 // GossipNode represents a gossip node for catalog synchronization
@@ -76,6 +81,15 @@ type GossipNode struct {
 	catalogAddr string
 	merkleRoot  string
 	mu          sync.RWMutex
+
+	topics   map[string]*pubsub.Topic
+	topicsMu sync.Mutex
+
+	// Peers allowed to publish and relay gossip; nil in open mode
+	allowlist peerAllowlist
+
+	httpServer *http.Server
+	httpAddr   string
 }
 
 // GossipConfig holds configuration for gossip synchronization
@@ -100,13 +114,20 @@ func NewGossipConfig() *GossipConfig {
 	}
 }
 
+// maxPendingDeltas bounds the deltas buffered while waiting for their causal predecessors
+const maxPendingDeltas = 1024
+
 // CatalogCRDT represents the CRDT-backed catalog (simplified interface)
 type CatalogCRDT struct {
 	nodeID      string
-	vectorClock map[string]int64
+	vectorClock VectorClock
 	snapshots   map[string]*LWWRegister
 	images      map[string]*LWWRegister
-	deltas      []*Delta
+	deltas      []*Delta // local deltas not yet published
+	outSeq      uint64   // last sequence assigned to an outbound delta
+	handedOut   uint64   // highest outSeq handed to a publisher
+	pending     []*Delta // received deltas waiting for causal predecessors
+	db          *leveldb.DB // nil keeps the catalog in memory only
 	mu          sync.RWMutex
 }
 
@@ -114,7 +135,7 @@ type CatalogCRDT struct {
 func NewCatalogCRDT(nodeID string) *CatalogCRDT {
 	return &CatalogCRDT{
 		nodeID:      nodeID,
-		vectorClock: make(map[string]int64),
+		vectorClock: make(VectorClock),
 		snapshots:   make(map[string]*LWWRegister),
 		images:      make(map[string]*LWWRegister),
 		deltas:      make([]*Delta, 0),
@@ -126,21 +147,14 @@ func (c *CatalogCRDT) AddSnapshot(id string, metadata map[string]interface{}) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.snapshots[id] = NewLWWRegister(metadata)
-	c.vectorClock[c.nodeID]++
+	reg := NewLWWRegister(metadata)
+	c.snapshots[id] = reg
 
-	delta := &Delta{
-		NodeID:      c.nodeID,
-		VectorClock: c.vectorClock,
-		Type:        "lww",
-		Key:         "snapshots:" + id,
-		Data:        map[string]interface{}{"metadata": metadata},
-		Timestamp:   time.Now().UnixNano(),
-	}
-	c.deltas = append(c.deltas, delta)
+	delta := c.queueDelta("lww", "snapshots:"+id, map[string]interface{}{"metadata": metadata})
+	c.persist(snapshotPrefix, id, reg, delta)
 }
 
-// GetDeltas returns pending deltas
+// GetDeltas returns the local deltas waiting to be published
 func (c *CatalogCRDT) GetDeltas() []*Delta {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -150,50 +164,108 @@ func (c *CatalogCRDT) GetDeltas() []*Delta {
 	return deltas
 }
 
-// ApplyDelta applies a received delta
+// ApplyDelta applies a received delta once its causal predecessors have been
+// applied, buffering it until then. It reports whether the delta itself was
+// applied by this call; buffered deltas are applied by the call that
+// delivers their last missing dependency.
 func (c *CatalogCRDT) ApplyDelta(delta *Delta) bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// Simple causal ordering check
-	if delta.VectorClock[delta.NodeID] <= c.vectorClock[delta.NodeID] {
-		return false // Already applied
+	if c.applied(delta) {
+		return false
+	}
+	if !c.vectorClock.deliverable(delta.NodeID, delta.VectorClock) {
+		c.bufferDelta(delta)
+		return false
+	}
+
+	c.applyDelta(delta)
+	c.drainPending()
+	return true
+}
+
+// applied reports whether the delta is already covered by the local clock.
+// The clock is restored from LevelDB on startup, so deltas applied before a
+// restart are not replayed.
+func (c *CatalogCRDT) applied(delta *Delta) bool {
+	if c.vectorClock.Compare(delta.VectorClock) > 0 {
+		return true // we have seen everything the sender had
 	}
+	return delta.VectorClock[delta.NodeID] <= c.vectorClock[delta.NodeID]
+}
 
-	// Update vector clock
-	for node, time := range delta.VectorClock {
-		if time > c.vectorClock[node] {
-			c.vectorClock[node] = time
+// bufferDelta holds a delta whose dependencies have not arrived, once per
+// sender event, dropping the oldest when the buffer is full
+func (c *CatalogCRDT) bufferDelta(delta *Delta) {
+	for _, pending := range c.pending {
+		if pending.NodeID == delta.NodeID && pending.VectorClock[pending.NodeID] == delta.VectorClock[delta.NodeID] {
+			return
 		}
 	}
+	if len(c.pending) >= maxPendingDeltas {
+		log.Printf("Pending delta buffer full, dropping delta %s from %s", c.pending[0].Key, c.pending[0].NodeID)
+		c.pending = c.pending[1:]
+	}
+	c.pending = append(c.pending, delta)
+}
+
+// drainPending applies buffered deltas until none of the remaining ones are
+// deliverable
+func (c *CatalogCRDT) drainPending() {
+	for progress := true; progress; {
+		progress = false
+		remaining := c.pending[:0]
+		for _, pending := range c.pending {
+			switch {
+			case c.applied(pending):
+				// superseded while buffered
+			case c.vectorClock.deliverable(pending.NodeID, pending.VectorClock):
+				c.applyDelta(pending)
+				progress = true
+			default:
+				remaining = append(remaining, pending)
+			}
+		}
+		c.pending = remaining
+	}
+}
+
+// applyDelta merges a deliverable delta into the catalog and persists it.
+// Callers must hold c.mu.
+func (c *CatalogCRDT) applyDelta(delta *Delta) {
+	c.vectorClock.Merge(delta.VectorClock)
 
 	// Apply delta based on type
+	var id string
+	var reg *LWWRegister
 	switch delta.Type {
 	case "lww":
 		if strings.HasPrefix(delta.Key, "snapshots:") {
-			id := strings.TrimPrefix(delta.Key, "snapshots:")
+			id = strings.TrimPrefix(delta.Key, "snapshots:")
 			if metadata, ok := delta.Data["metadata"].(map[string]interface{}); ok {
 				if existing, exists := c.snapshots[id]; exists {
 					existing.Merge(NewLWWRegister(metadata))
+					reg = existing
 				} else {
-					c.snapshots[id] = NewLWWRegister(metadata)
+					reg = NewLWWRegister(metadata)
+					c.snapshots[id] = reg
 				}
 			}
 		}
 	}
 
-	return true
-}
-
-// ClearDeltas clears processed deltas
-func (c *CatalogCRDT) ClearDeltas() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.deltas = c.deltas[:0]
+	c.persist(snapshotPrefix, id, reg, delta)
 }
 
 // GetState returns the current catalog state for Merkle calculation
 func (c *CatalogCRDT) GetState() map[string]interface{} {
+	state, _ := c.FullState()
+	return state
+}
+
+// FullState returns the catalog state together with the vector clock it reflects
+func (c *CatalogCRDT) FullState() (map[string]interface{}, VectorClock) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
@@ -204,7 +276,40 @@ func (c *CatalogCRDT) GetState() map[string]interface{} {
 	for id, reg := range c.images {
 		state["image:"+id] = reg.Get()
 	}
-	return state
+	return state, c.vectorClock.Copy()
+}
+
+// MergeState merges a full catalog state, as produced by FullState, into the
+// local registers. Merging the sender's clock marks every delta it covers as
+// applied, which also releases buffered deltas stuck behind a dropped one.
+func (c *CatalogCRDT) MergeState(state map[string]interface{}, clock VectorClock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.vectorClock.Merge(clock)
+
+	for key, value := range state {
+		var prefix, id string
+		var registers map[string]*LWWRegister
+		switch {
+		case strings.HasPrefix(key, "snapshot:"):
+			prefix, id, registers = snapshotPrefix, strings.TrimPrefix(key, "snapshot:"), c.snapshots
+		case strings.HasPrefix(key, "image:"):
+			prefix, id, registers = imagePrefix, strings.TrimPrefix(key, "image:"), c.images
+		default:
+			continue
+		}
+
+		reg, exists := registers[id]
+		if exists {
+			reg.Merge(NewLWWRegister(value))
+		} else {
+			reg = NewLWWRegister(value)
+			registers[id] = reg
+		}
+		c.persist(prefix, id, reg, nil)
+	}
+	c.drainPending()
 }
 
 // MerkleNode represents a node in the Merkle tree
@@ -214,14 +319,23 @@ type MerkleNode struct {
 	Right *MerkleNode
 }
 
-// BuildMerkleTree builds a Merkle tree from data
+// EmptyMerkleRoot is the root hash of a tree without leaves: the SHA-256 of the empty string
+const EmptyMerkleRoot = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// BuildMerkleTree builds a Merkle tree over the given leaves. Leaves are
+// sorted first, so the same set of items yields the same root on every node.
+// A level with an odd number of nodes pairs its last node with itself, and an
+// empty input yields a single node with EmptyMerkleRoot.
 func BuildMerkleTree(data []string) *MerkleNode {
 	if len(data) == 0 {
-		return nil
+		return &MerkleNode{Hash: EmptyMerkleRoot}
 	}
 
-	nodes := make([]*MerkleNode, len(data))
-	for i, d := range data {
+	leaves := append([]string(nil), data...)
+	sort.Strings(leaves)
+
+	nodes := make([]*MerkleNode, len(leaves))
+	for i, d := range leaves {
 		hash := sha256.Sum256([]byte(d))
 		nodes[i] = &MerkleNode{Hash: hex.EncodeToString(hash[:])}
 	}
@@ -245,10 +359,32 @@ func BuildMerkleTree(data []string) *MerkleNode {
 	return nodes[0]
 }
 
+// stateLeaves turns a catalog state into one "key:hash" leaf per item, so a
+// single changed item only changes one leaf
+func stateLeaves(state map[string]interface{}) []string {
+	leaves := make([]string, 0, len(state))
+	for key, value := range state {
+		data, _ := json.Marshal(value)
+		hash := sha256.Sum256(data)
+		leaves = append(leaves, key+":"+hex.EncodeToString(hash[:]))
+	}
+	return leaves
+}
+
 // NewGossipNode creates a new gossip node
 func NewGossipNode(config *GossipConfig) (*GossipNode, error) {
-	// Generate a new private key
-	priv, _, err := crypto.GenerateKeyPairWithReader(crypto.RSA, 2048, rand.Reader)
+	dataDir := config.StorageDir()
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data dir %s: %w", dataDir, err)
+	}
+
+	// Keep the same peer ID across restarts so it can be allowlisted
+	priv, err := loadIdentity(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	allowlist, err := newPeerAllowlist(config)
 	if err != nil {
 		return nil, err
 	}
@@ -268,13 +404,17 @@ func NewGossipNode(config *GossipConfig) (*GossipNode, error) {
 		return nil, err
 	}
 
-	// Open LevelDB
-	db, err := leveldb.OpenFile("./gossip.db", nil)
+	// Open LevelDB under the node's own data directory
+	db, err := leveldb.OpenFile(filepath.Join(dataDir, "gossip.db"), nil)
 	if err != nil {
 		return nil, err
 	}
 
-	catalog := NewCatalogCRDT(config.NodeID)
+	catalog, err := LoadCatalogCRDT(config.NodeID, db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to load catalog state: %w", err)
+	}
 	merkleTree := NewCatalogMerkleTree()
 
 	node := &GossipNode{
@@ -285,11 +425,30 @@ func NewGossipNode(config *GossipConfig) (*GossipNode, error) {
 		merkleTree:  merkleTree,
 		config:      config,
 		catalogAddr: config.CatalogAddr,
+		topics:      make(map[string]*pubsub.Topic),
+		allowlist:   allowlist,
+	}
+	if allowlist != nil {
+		// Our own messages pass through the validators too
+		allowlist[host.ID()] = struct{}{}
+	}
+
+	if err := node.registerValidators(); err != nil {
+		node.Close()
+		return nil, err
 	}
 
 	// Subscribe to topics
 	node.subscribeToTopics()
 
+	// Serve the status and sync API used by decubectl
+	if config.HTTPAddr != "" {
+		if err := node.startHTTP(config.HTTPAddr); err != nil {
+			node.Close()
+			return nil, fmt.Errorf("failed to start HTTP API: %w", err)
+		}
+	}
+
 	// Connect to initial peers
 	for _, peerAddr := range config.InitialPeers {
 		go func(addr string) {
@@ -305,7 +464,7 @@ func NewGossipNode(config *GossipConfig) (*GossipNode, error) {
 // subscribeToTopics subscribes to gossip topics
 func (n *GossipNode) subscribeToTopics() {
 	// Delta sync topic
-	deltaTopic, err := n.pubsub.Join("decub/delta")
+	deltaTopic, err := n.topic("decub/delta")
 	if err != nil {
 		log.Printf("Failed to join delta topic: %v", err)
 		return
@@ -318,9 +477,10 @@ func (n *GossipNode) subscribeToTopics() {
 	}
 
 	go n.handleDeltas(sub)
+	go n.publishDeltas()
 
 	// Anti-entropy topic
-	antiEntropyTopic, err := n.pubsub.Join("decub/anti-entropy")
+	antiEntropyTopic, err := n.topic("decub/anti-entropy")
 	if err != nil {
 		log.Printf("Failed to join anti-entropy topic: %v", err)
 		return
@@ -335,48 +495,61 @@ func (n *GossipNode) subscribeToTopics() {
 	go n.handleAntiEntropy(subAE)
 }
 
-// handleDeltas handles incoming delta messages
-func (n *GossipNode) handleDeltas(sub *pubsub.Subscription) {
+// publishDeltas periodically publishes the local outbound deltas
+func (n *GossipNode) publishDeltas() {
 	ticker := time.NewTicker(n.config.SyncInterval)
 	defer ticker.Stop()
 
-	for {
-		select {
-		case <-ticker.C:
-			// Send pending deltas
-			deltas := n.catalog.GetDeltas()
-			if len(deltas) > 0 {
-				data, _ := json.Marshal(deltas)
-				n.publish("decub/delta", data)
-			}
+	for range ticker.C {
+		if _, err := n.flushDeltas(); err != nil {
+			log.Printf("Failed to publish deltas: %v", err)
+		}
+	}
+}
 
-		default:
-			msg, err := sub.Next(context.Background())
-			if err != nil {
-				log.Printf("Delta subscription error: %v", err)
-				continue
-			}
+// flushDeltas publishes the queued local deltas, keeping them queued if
+// publishing fails, and returns how many were published
+func (n *GossipNode) flushDeltas() (int, error) {
+	published := 0
+	err := n.catalog.FlushDeltas(func(deltas []*Delta) error {
+		data, err := json.Marshal(deltas)
+		if err != nil {
+			return err
+		}
+		if err := n.publish("decub/delta", data); err != nil {
+			return err
+		}
+		published = len(deltas)
+		return nil
+	})
+	return published, err
+}
 
-			if msg.ReceivedFrom == n.host.ID() {
-				continue // Ignore own messages
-			}
+// handleDeltas applies deltas received from peers
+func (n *GossipNode) handleDeltas(sub *pubsub.Subscription) {
+	for {
+		msg, err := sub.Next(context.Background())
+		if err != nil {
+			log.Printf("Delta subscription error: %v", err)
+			continue
+		}
 
-			var deltas []*Delta
-			if err := json.Unmarshal(msg.Data, &deltas); err != nil {
-				log.Printf("Failed to unmarshal deltas: %v", err)
-				continue
-			}
+		if msg.ReceivedFrom == n.host.ID() {
+			continue // Ignore own messages
+		}
 
-			// Apply received deltas
-			for _, delta := range deltas {
-				applied := n.catalog.ApplyDelta(delta)
-				if applied {
-					log.Printf("Applied delta: %s (%s)", delta.Key, delta.Type)
-				}
-			}
+		var deltas []*Delta
+		if err := json.Unmarshal(msg.Data, &deltas); err != nil {
+			log.Printf("Failed to unmarshal deltas: %v", err)
+			continue
+		}
 
-			// Clear processed deltas
-			n.catalog.ClearDeltas()
+		// Apply received deltas
+		for _, delta := range deltas {
+			applied := n.catalog.ApplyDelta(delta)
+			if applied {
+				log.Printf("Applied delta: %s (%s)", delta.Key, delta.Type)
+			}
 		}
 	}
 }
@@ -389,15 +562,7 @@ func (n *GossipNode) handleAntiEntropy(sub *pubsub.Subscription) {
 	for {
 		select {
 		case <-ticker.C:
-			// Send Merkle root for anti-entropy
-			state := n.catalog.GetState()
-			stateData, _ := json.Marshal(state)
-			root := BuildMerkleTree([]string{string(stateData)})
-			if root != nil {
-				n.merkleRoot = root.Hash
-				data, _ := json.Marshal(map[string]string{"merkle_root": root.Hash})
-				n.publish("decub/anti-entropy", data)
-			}
+			n.announceMerkleRoot()
 
 		default:
 			msg, err := sub.Next(context.Background())
@@ -410,54 +575,71 @@ func (n *GossipNode) handleAntiEntropy(sub *pubsub.Subscription) {
 				continue
 			}
 
-			var aeMsg map[string]interface{}
-			if err := json.Unmarshal(msg.Data, &aeMsg); err != nil {
-				log.Printf("Failed to unmarshal anti-entropy: %v", err)
+			reply, err := n.processAntiEntropy(msg.Data)
+			if err != nil {
+				log.Printf("Dropping anti-entropy message: %v", err)
 				continue
 			}
-
-			// Check if it's a Merkle root message
-			if merkleRoot, ok := aeMsg["merkle_root"].(string); ok {
-				if merkleRoot != n.merkleRoot {
-					log.Printf("Merkle root mismatch detected, requesting full sync")
-					// Request full state sync
-					n.publish("decub/anti-entropy", []byte(`{"sync_request": true}`))
-				}
-			}
-
-			// Check if it's a sync request or full state
-			if _, ok := aeMsg["sync_request"]; ok {
-				// Send full state
-				state := n.catalog.GetState()
-				data, _ := json.Marshal(state)
-				n.publish("decub/anti-entropy", data)
-			} else if _, ok := aeMsg["snapshot:snap1"]; ok {
-				// Received full state, apply it
-				for key, value := range aeMsg {
-					if strings.HasPrefix(key, "snapshot:") {
-						id := strings.TrimPrefix(key, "snapshot:")
-						if metadata, ok := value.(map[string]interface{}); ok {
-							n.catalog.snapshots[id] = metadata
-						}
-					}
-				}
-				log.Printf("Applied full state sync")
+			if reply != nil {
+				n.publish("decub/anti-entropy", encodeAntiEntropy(reply))
 			}
 		}
 	}
 }
 
+// announceMerkleRoot recomputes the state root and publishes it for anti-entropy
+func (n *GossipNode) announceMerkleRoot() error {
+	root := BuildMerkleTree(stateLeaves(n.catalog.GetState()))
+
+	n.mu.Lock()
+	n.merkleRoot = root.Hash
+	n.mu.Unlock()
+	return n.publish("decub/anti-entropy", encodeAntiEntropy(&AntiEntropyMessage{Type: MsgMerkleRoot, MerkleRoot: root.Hash}))
+}
+
+// Sync immediately publishes pending deltas and starts an anti-entropy
+// round, returning the number of deltas published
+func (n *GossipNode) Sync() (int, error) {
+	published, err := n.flushDeltas()
+	if err != nil {
+		return 0, fmt.Errorf("failed to publish deltas: %w", err)
+	}
+	if err := n.announceMerkleRoot(); err != nil {
+		return published, fmt.Errorf("failed to announce Merkle root: %w", err)
+	}
+	return published, nil
+}
+
+// topic returns the handle for a topic, joining it on first use; pubsub
+// refuses to join the same topic twice
+func (n *GossipNode) topic(name string) (*pubsub.Topic, error) {
+	n.topicsMu.Lock()
+	defer n.topicsMu.Unlock()
+
+	if t, ok := n.topics[name]; ok {
+		return t, nil
+	}
+	t, err := n.pubsub.Join(name)
+	if err != nil {
+		return nil, err
+	}
+	n.topics[name] = t
+	return t, nil
+}
+
 // publish publishes a message to a topic
-func (n *GossipNode) publish(topic string, data []byte) {
-	t, err := n.pubsub.Join(topic)
+func (n *GossipNode) publish(topic string, data []byte) error {
+	t, err := n.topic(topic)
 	if err != nil {
 		log.Printf("Failed to join topic %s: %v", topic, err)
-		return
+		return err
 	}
 
 	if err := t.Publish(context.Background(), data); err != nil {
 		log.Printf("Failed to publish to %s: %v", topic, err)
+		return err
 	}
+	return nil
 }
 
 // Connect connects to a peer
@@ -480,33 +662,47 @@ func (n *GossipNode) GetStatus() map[string]interface{} {
 	n.mu.RLock()
 	defer n.mu.RUnlock()
 
+	n.catalog.mu.RLock()
+	defer n.catalog.mu.RUnlock()
+
 	return map[string]interface{}{
 		"node_id":       n.catalog.nodeID,
 		"merkle_root":  n.merkleRoot,
 		"peers":        len(n.host.Peerstore().Peers()),
 		"snapshots":    len(n.catalog.snapshots),
 		"pending_deltas": len(n.catalog.deltas),
+		"vector_clock":   n.catalog.vectorClock.Copy(),
 	}
 }
 
 // Close closes the gossip node
 func (n *GossipNode) Close() error {
+	if n.httpServer != nil {
+		n.httpServer.Close()
+	}
 	n.db.Close()
 	return n.host.Close()
 }
 
 func main() {
+	port := flag.Int("port", -1, "HTTP API port, separate from the p2p port in the listen multiaddr (default from DECUB_HTTP_ADDR, PORT or 8080)")
+	flag.Parse()
+
 	config := LoadConfigFromEnv()
+	if *port >= 0 {
+		config.HTTPAddr = ":" + strconv.Itoa(*port)
+	}
 
 	// Override with command line args if provided
-	if len(os.Args) > 1 {
-		config.ListenAddr = os.Args[1]
+	args := flag.Args()
+	if len(args) > 0 {
+		config.ListenAddr = args[0]
 	}
-	if len(os.Args) > 2 {
-		config.NodeID = os.Args[2]
+	if len(args) > 1 {
+		config.NodeID = args[1]
 	}
-	if len(os.Args) > 3 {
-		config.InitialPeers = []string{os.Args[3]}
+	if len(args) > 2 {
+		config.InitialPeers = []string{args[2]}
 	}
 
 	if err := config.Validate(); err != nil {
@@ -553,8 +749,7 @@ func (n *GossipNode) startMerkleBroadcast() {
 
 				rootHash := n.merkleTree.GetRootHash()
 				if rootHash != "" {
-					data, _ := json.Marshal(map[string]string{"merkle_root": rootHash})
-					n.publish("decub/anti-entropy", data)
+					n.publish("decub/anti-entropy", encodeAntiEntropy(&AntiEntropyMessage{Type: MsgMerkleRoot, MerkleRoot: rootHash}))
 					log.Printf("Broadcasted Merkle root: %s", rootHash[:8]+"...")
 				}
 			}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+func openTestDB(t *testing.T, dir string) *leveldb.DB {
+	t.Helper()
+	db, err := leveldb.OpenFile(filepath.Join(dir, "gossip.db"), nil)
+	if err != nil {
+		t.Fatalf("failed to open leveldb: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newTestNodeConfig(t *testing.T, dataDir string) *GossipConfig {
+	t.Helper()
+
+	config := DefaultConfig()
+	config.NodeID = "node-a"
+	config.ListenAddr = "/ip4/127.0.0.1/tcp/0"
+	config.DataDir = dataDir
+	config.HTTPAddr = ""
+	return config
+}
+
+func TestNodeStateSurvivesRestart(t *testing.T) {
+	dataDir := t.TempDir()
+
+	node, err := NewGossipNode(newTestNodeConfig(t, dataDir))
+	if err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	node.catalog.AddSnapshot("snap-1", map[string]interface{}{"cluster": "a"})
+	if err := node.Close(); err != nil {
+		t.Fatalf("failed to close node: %v", err)
+	}
+
+	node, err = NewGossipNode(newTestNodeConfig(t, dataDir))
+	if err != nil {
+		t.Fatalf("failed to restart node: %v", err)
+	}
+	defer node.Close()
+
+	reg, ok := node.catalog.snapshots["snap-1"]
+	if !ok {
+		t.Fatal("snapshot lost across restart")
+	}
+	if metadata, _ := reg.Get().(map[string]interface{}); metadata["cluster"] != "a" {
+		t.Fatalf("unexpected snapshot metadata %v", reg.Get())
+	}
+	if clock := node.catalog.vectorClock["node-a"]; clock != 1 {
+		t.Fatalf("expected vector clock 1 for node-a, got %d", clock)
+	}
+}
+
+func TestApplyDeltaSkipsReplayAfterRestart(t *testing.T) {
+	db := openTestDB(t, t.TempDir())
+	catalog, err := LoadCatalogCRDT("node-a", db)
+	if err != nil {
+		t.Fatalf("failed to load catalog: %v", err)
+	}
+
+	delta := &Delta{
+		NodeID:      "node-b",
+		VectorClock: VectorClock{"node-b": 1},
+		Type:        "lww",
+		Key:         "snapshots:snap-b",
+		Data:        map[string]interface{}{"metadata": map[string]interface{}{"size": 1.0}},
+	}
+	if !catalog.ApplyDelta(delta) {
+		t.Fatal("expected first delivery to apply")
+	}
+
+	reloaded, err := LoadCatalogCRDT("node-a", db)
+	if err != nil {
+		t.Fatalf("failed to reload catalog: %v", err)
+	}
+	if _, ok := reloaded.snapshots["snap-b"]; !ok {
+		t.Fatal("applied delta was not persisted")
+	}
+	if reloaded.ApplyDelta(delta) {
+		t.Fatal("delta replayed after reload")
+	}
+}
+
+func snapshotDelta(sender string, clock VectorClock, id, value string) *Delta {
+	return &Delta{
+		NodeID:      sender,
+		VectorClock: clock,
+		Type:        "lww",
+		Key:         "snapshots:" + id,
+		Data:        map[string]interface{}{"metadata": map[string]interface{}{"value": value}},
+	}
+}
+
+func TestApplyDeltaWaitsForCausalPredecessors(t *testing.T) {
+	catalog := NewCatalogCRDT("node-c")
+
+	a1 := snapshotDelta("node-a", VectorClock{"node-a": 1}, "snap", "a1")
+	a2 := snapshotDelta("node-a", VectorClock{"node-a": 2}, "snap", "a2")
+	b1 := snapshotDelta("node-b", VectorClock{"node-a": 2, "node-b": 1}, "other", "b1") // node-b had seen a2
+	d1 := snapshotDelta("node-d", VectorClock{"node-d": 1}, "concurrent", "d1")
+
+	// Deliver in reverse causal order; nothing from node-a or node-b can apply yet
+	for _, delta := range []*Delta{b1, a2} {
+		if catalog.ApplyDelta(delta) {
+			t.Fatalf("delta %s applied before its predecessors", delta.Key)
+		}
+	}
+	if len(catalog.snapshots) != 0 {
+		t.Fatalf("expected no snapshots yet, got %d", len(catalog.snapshots))
+	}
+
+	// A concurrent delta from an unrelated node applies immediately
+	if !catalog.ApplyDelta(d1) {
+		t.Fatal("concurrent delta was not applied")
+	}
+
+	// The missing predecessor releases the whole buffered chain
+	if !catalog.ApplyDelta(a1) {
+		t.Fatal("first delta from node-a was not applied")
+	}
+	if len(catalog.pending) != 0 {
+		t.Fatalf("expected empty buffer, %d deltas still pending", len(catalog.pending))
+	}
+
+	want := VectorClock{"node-a": 2, "node-b": 1, "node-d": 1}
+	if catalog.vectorClock.Compare(want) != 0 || len(catalog.vectorClock) != len(want) {
+		t.Fatalf("expected clock %v, got %v", want, catalog.vectorClock)
+	}
+	if metadata, _ := catalog.snapshots["snap"].Get().(map[string]interface{}); metadata["value"] != "a2" {
+		t.Fatalf("expected the later write from node-a to win, got %v", catalog.snapshots["snap"].Get())
+	}
+	if _, ok := catalog.snapshots["other"]; !ok {
+		t.Fatal("buffered delta from node-b was never applied")
+	}
+
+	// Redelivery after convergence is ignored
+	if catalog.ApplyDelta(a2) || catalog.ApplyDelta(b1) {
+		t.Fatal("already applied delta was applied again")
+	}
+}
+
+func TestProcessAntiEntropyMessageTypes(t *testing.T) {
+	node := &GossipNode{catalog: NewCatalogCRDT("node-a"), merkleRoot: "local-root"}
+	node.catalog.AddSnapshot("snap-a", map[string]interface{}{"cluster": "a"})
+
+	t.Run("MerkleRoot", func(t *testing.T) {
+		reply, err := node.processAntiEntropy(encodeAntiEntropy(&AntiEntropyMessage{Type: MsgMerkleRoot, MerkleRoot: "local-root"}))
+		if err != nil || reply != nil {
+			t.Fatalf("matching root should need no reply, got %+v, %v", reply, err)
+		}
+
+		reply, err = node.processAntiEntropy(encodeAntiEntropy(&AntiEntropyMessage{Type: MsgMerkleRoot, MerkleRoot: "other-root"}))
+		if err != nil || reply == nil || reply.Type != MsgSyncRequest {
+			t.Fatalf("mismatched root should request a sync, got %+v, %v", reply, err)
+		}
+	})
+
+	t.Run("SyncRequest", func(t *testing.T) {
+		reply, err := node.processAntiEntropy(encodeAntiEntropy(&AntiEntropyMessage{Type: MsgSyncRequest}))
+		if err != nil || reply == nil || reply.Type != MsgFullState {
+			t.Fatalf("sync request should be answered with full state, got %+v, %v", reply, err)
+		}
+		if _, ok := reply.State["snapshot:snap-a"]; !ok {
+			t.Fatalf("full state is missing snap-a: %v", reply.State)
+		}
+	})
+
+	t.Run("FullState", func(t *testing.T) {
+		// Any snapshot name works, not just the one the old detection looked for
+		state := map[string]interface{}{"snapshot:snap-b": map[string]interface{}{"cluster": "b"}}
+		reply, err := node.processAntiEntropy(encodeAntiEntropy(&AntiEntropyMessage{Type: MsgFullState, State: state}))
+		if err != nil || reply != nil {
+			t.Fatalf("full state should need no reply, got %+v, %v", reply, err)
+		}
+
+		reg, ok := node.catalog.snapshots["snap-b"]
+		if !ok {
+			t.Fatal("full state was not applied")
+		}
+		if metadata, _ := reg.Get().(map[string]interface{}); metadata["cluster"] != "b" {
+			t.Fatalf("unexpected snapshot metadata %v", reg.Get())
+		}
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		if _, err := node.processAntiEntropy([]byte(`{"snapshot:snap1":{}}`)); err == nil {
+			t.Fatal("expected an untyped message to be rejected")
+		}
+	})
+}
+
+func TestFlushDeltasKeepsDeltasQueuedDuringInboundBatch(t *testing.T) {
+	catalog := NewCatalogCRDT("node-a")
+	catalog.AddSnapshot("before", map[string]interface{}{"n": 1})
+
+	var published []*Delta
+	err := catalog.FlushDeltas(func(deltas []*Delta) error {
+		// A local change and an inbound batch land while the publish is in flight
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := int64(1); i <= 50; i++ {
+				catalog.ApplyDelta(snapshotDelta("node-b", VectorClock{"node-b": i}, "remote", "b"))
+			}
+		}()
+		catalog.AddSnapshot("during", map[string]interface{}{"n": 2})
+		<-done
+
+		published = append(published, deltas...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	if len(published) != 1 || published[0].Key != "snapshots:before" {
+		t.Fatalf("expected only the first delta in the first flush, got %v", published)
+	}
+
+	published = nil
+	catalog.FlushDeltas(func(deltas []*Delta) error {
+		published = append(published, deltas...)
+		return nil
+	})
+	if len(published) != 1 || published[0].Key != "snapshots:during" {
+		t.Fatalf("local delta queued during the inbound batch was not broadcast, got %v", published)
+	}
+	if len(catalog.GetDeltas()) != 0 {
+		t.Fatalf("expected an empty outbound buffer, got %d deltas", len(catalog.GetDeltas()))
+	}
+}
+
+func TestFlushDeltasRetainsDeltasWhenPublishFails(t *testing.T) {
+	catalog := NewCatalogCRDT("node-a")
+	catalog.AddSnapshot("snap", map[string]interface{}{"n": 1})
+
+	if err := catalog.FlushDeltas(func([]*Delta) error { return errors.New("no peers") }); err == nil {
+		t.Fatal("expected the publish error to be returned")
+	}
+	if len(catalog.GetDeltas()) != 1 {
+		t.Fatalf("failed publish dropped deltas, %d left", len(catalog.GetDeltas()))
+	}
+}
+
+func TestQueueDeltaCoalescesUnsentUpdates(t *testing.T) {
+	catalog := NewCatalogCRDT("node-a")
+	catalog.AddSnapshot("snap", map[string]interface{}{"n": 1})
+	catalog.AddSnapshot("other", map[string]interface{}{"n": 1})
+	catalog.AddSnapshot("snap", map[string]interface{}{"n": 2})
+
+	deltas := catalog.GetDeltas()
+	if len(deltas) != 2 {
+		t.Fatalf("expected updates to snap to coalesce into 2 deltas, got %d", len(deltas))
+	}
+	latest := deltas[1]
+	if latest.Key != "snapshots:snap" || latest.VectorClock["node-a"] != 1 {
+		t.Fatalf("coalesced delta should keep its sequence number, got %s at %d", latest.Key, latest.VectorClock["node-a"])
+	}
+	if metadata, _ := latest.Data["metadata"].(map[string]interface{}); metadata["n"] != 2 {
+		t.Fatalf("coalesced delta should carry the latest value, got %v", latest.Data)
+	}
+
+	// A receiver can still deliver both without gaps
+	peer := NewCatalogCRDT("node-b")
+	for _, delta := range deltas {
+		peer.ApplyDelta(delta)
+	}
+	if peer.vectorClock["node-a"] != 2 || len(peer.pending) != 0 {
+		t.Fatalf("peer did not converge: clock %v, %d pending", peer.vectorClock, len(peer.pending))
+	}
+
+	// Once handed to a publisher a delta is never amended
+	catalog.FlushDeltas(func([]*Delta) error {
+		catalog.AddSnapshot("snap", map[string]interface{}{"n": 3})
+		return nil
+	})
+	if deltas := catalog.GetDeltas(); len(deltas) != 1 || deltas[0].VectorClock["node-a"] != 3 {
+		t.Fatalf("expected a fresh delta for the post-publish update, got %v", deltas)
+	}
+}
+
+func getStatus(t *testing.T, baseURL string) map[string]interface{} {
+	t.Helper()
+
+	resp, err := http.Get(baseURL + "/api/v1/status")
+	if err != nil {
+		t.Fatalf("status request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 from status, got %d", resp.StatusCode)
+	}
+
+	var status map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("invalid status response: %v", err)
+	}
+	return status
+}
+
+func TestStatusAndSyncEndpoints(t *testing.T) {
+	config := newTestNodeConfig(t, t.TempDir())
+	config.HTTPAddr = "127.0.0.1:0"
+
+	node, err := NewGossipNode(config)
+	if err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	defer node.Close()
+	baseURL := "http://" + node.httpAddr
+
+	node.catalog.AddSnapshot("snap-1", map[string]interface{}{"cluster": "a"})
+
+	status := getStatus(t, baseURL)
+	if status["node_id"] != "node-a" || status["pending_deltas"] != float64(1) {
+		t.Fatalf("unexpected status before sync: %v", status)
+	}
+
+	resp, err := http.Post(baseURL+"/api/v1/sync", "application/json", nil)
+	if err != nil {
+		t.Fatalf("sync request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 from sync, got %d", resp.StatusCode)
+	}
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("invalid sync response: %v", err)
+	}
+	if result["deltas_published"] != float64(1) || result["merkle_root"] == "" {
+		t.Fatalf("sync did not publish: %v", result)
+	}
+
+	// Published deltas leave the outbound buffer
+	if status := getStatus(t, baseURL); status["pending_deltas"] != float64(0) {
+		t.Fatalf("expected no pending deltas after sync, got %v", status["pending_deltas"])
+	}
+
+	resp, err = http.Get(baseURL + "/api/v1/sync")
+	if err != nil {
+		t.Fatalf("GET sync request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for GET sync, got %d", resp.StatusCode)
+	}
+}
+
+func TestBuildMerkleTreeLeafCounts(t *testing.T) {
+	leaf := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	pair := func(a, b string) string { return leaf(a + b) }
+
+	tests := []struct {
+		name string
+		data []string
+		want string
+	}{
+		{"Empty", nil, EmptyMerkleRoot},
+		{"One", []string{"a"}, leaf("a")},
+		{"Two", []string{"a", "b"}, pair(leaf("a"), leaf("b"))},
+		{"Three", []string{"a", "b", "c"}, pair(pair(leaf("a"), leaf("b")), pair(leaf("c"), leaf("c")))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if root := BuildMerkleTree(tt.data); root.Hash != tt.want {
+				t.Fatalf("expected root %s, got %s", tt.want, root.Hash)
+			}
+		})
+	}
+
+	if BuildMerkleTree([]string{"c", "a", "b"}).Hash != BuildMerkleTree([]string{"b", "c", "a"}).Hash {
+		t.Fatal("root depends on leaf order")
+	}
+}
+
+func TestStateLeavesChangeOnlyForChangedItems(t *testing.T) {
+	state := map[string]interface{}{
+		"snapshot:a": map[string]interface{}{"size": 1},
+		"snapshot:b": map[string]interface{}{"size": 2},
+	}
+	root := BuildMerkleTree(stateLeaves(state)).Hash
+	for i := 0; i < 10; i++ {
+		if got := BuildMerkleTree(stateLeaves(state)).Hash; got != root {
+			t.Fatal("root is not stable across map iteration orders")
+		}
+	}
+
+	state["snapshot:b"] = map[string]interface{}{"size": 3}
+	if BuildMerkleTree(stateLeaves(state)).Hash == root {
+		t.Fatal("changing an item did not change the root")
+	}
+}
@@ -155,7 +155,7 @@ func (mt *CatalogMerkleTree) GenerateProof(itemType, itemID string) (*MerkleProo
 	}
 
 	// Find the leaf node for this item
-	leafNode, path := mt.findLeaf(mt.root, itemType, itemID, []string{})
+	leafNode, path, index := mt.findLeaf(mt.root, itemType, itemID)
 	if leafNode == nil {
 		return nil, fmt.Errorf("item %s:%s not found in Merkle tree", itemType, itemID)
 	}
@@ -164,62 +164,81 @@ func (mt *CatalogMerkleTree) GenerateProof(itemType, itemID string) (*MerkleProo
 		RootHash:  mt.GetRootHash(),
 		LeafHash:  leafNode.Hash,
 		Proof:     path,
-		Index:     0, // Simplified - would need proper indexing
-		NumLeaves: 1, // Simplified
+		Index:     uint64(index),
+		NumLeaves: uint64(mt.countLeaves(mt.root)),
 	}
 
 	return proof, nil
 }
 
-// findLeaf recursively finds a leaf node and builds the proof path
-func (mt *CatalogMerkleTree) findLeaf(node *CatalogMerkleNode, itemType, itemID string, path []string) (*CatalogMerkleNode, []string) {
+// findLeaf recursively finds a leaf node, returning the sibling hashes from
+// the leaf up to node and the leaf's position among node's leaves
+func (mt *CatalogMerkleTree) findLeaf(node *CatalogMerkleNode, itemType, itemID string) (*CatalogMerkleNode, []ProofStep, int) {
 	if node == nil {
-		return nil, path
+		return nil, nil, 0
 	}
 
 	// Check if this is the target leaf
 	if node.Data != nil && node.Data.Type == itemType && node.Data.ID == itemID {
-		return node, path
+		return node, nil, 0
 	}
 
-	// Search left subtree
+	// Search left subtree; the sibling is on the right
 	if node.Left != nil {
-		if found, newPath := mt.findLeaf(node.Left, itemType, itemID, path); found != nil {
-			// Add sibling hash to path
+		if found, path, index := mt.findLeaf(node.Left, itemType, itemID); found != nil {
 			if node.Right != nil {
-				newPath = append(newPath, node.Right.Hash)
+				path = append(path, ProofStep{Hash: node.Right.Hash})
 			}
-			return found, newPath
+			return found, path, index
 		}
 	}
 
-	// Search right subtree
+	// Search right subtree; the sibling is on the left
 	if node.Right != nil {
-		if found, newPath := mt.findLeaf(node.Right, itemType, itemID, path); found != nil {
-			// Add sibling hash to path
+		if found, path, index := mt.findLeaf(node.Right, itemType, itemID); found != nil {
 			if node.Left != nil {
-				newPath = append(newPath, node.Left.Hash)
+				path = append(path, ProofStep{Hash: node.Left.Hash, Left: true})
 			}
-			return found, newPath
+			return found, path, index + mt.countLeaves(node.Left)
 		}
 	}
 
-	return nil, path
+	return nil, nil, 0
 }
 
 // VerifyProof verifies a Merkle proof
 func (mt *CatalogMerkleTree) VerifyProof(proof *MerkleProof) bool {
-	if proof == nil {
+	return VerifyMerkleProof(proof)
+}
+
+// VerifyMerkleProof checks a proof without access to the tree. Besides
+// hashing up to the root, it checks that each step's side matches the one
+// implied by Index and NumLeaves, so a proof cannot claim another position.
+func VerifyMerkleProof(proof *MerkleProof) bool {
+	if proof == nil || proof.NumLeaves == 0 || proof.Index >= proof.NumLeaves {
+		return false
+	}
+
+	sides := proofSides(proof.Index, proof.NumLeaves)
+	if len(sides) != len(proof.Proof) {
 		return false
 	}
 
 	// Start with the leaf hash
 	currentHash := proof.LeafHash
 
-	// Apply proof hashes
-	for _, siblingHash := range proof.Proof {
-		// Simplified verification - in practice, need proper left/right ordering
-		combined := currentHash + siblingHash
+	// Apply proof hashes in leaf-to-root order
+	for i, step := range proof.Proof {
+		if step.Left != sides[i] {
+			return false
+		}
+
+		var combined string
+		if step.Left {
+			combined = step.Hash + currentHash
+		} else {
+			combined = currentHash + step.Hash
+		}
 		hash := sha256.Sum256([]byte(combined))
 		currentHash = hex.EncodeToString(hash[:])
 	}
@@ -227,6 +246,29 @@ func (mt *CatalogMerkleTree) VerifyProof(proof *MerkleProof) bool {
 	return currentHash == proof.RootHash
 }
 
+// proofSides recomputes, leaf to root, whether each sibling of leaf index sits
+// on the left, following buildTree's split of n leaves into n/2 and n-n/2
+func proofSides(index, numLeaves uint64) []bool {
+	var sides []bool
+	for numLeaves > 1 {
+		mid := numLeaves / 2
+		if index < mid {
+			sides = append(sides, false)
+			numLeaves = mid
+		} else {
+			sides = append(sides, true)
+			index -= mid
+			numLeaves -= mid
+		}
+	}
+
+	// Collected root to leaf; proofs run leaf to root
+	for i, j := 0, len(sides)-1; i < j; i, j = i+1, j-1 {
+		sides[i], sides[j] = sides[j], sides[i]
+	}
+	return sides
+}
+
 // Serialize serializes the Merkle tree to JSON
 func (mt *CatalogMerkleTree) Serialize() ([]byte, error) {
 	return json.Marshal(mt.root)
@@ -274,11 +316,17 @@ func (mt *CatalogMerkleTree) countLeaves(node *CatalogMerkleNode) int {
 	return mt.countLeaves(node.Left) + mt.countLeaves(node.Right)
 }
 
-// MerkleProof represents a Merkle proof (simplified version)
+// ProofStep is one sibling hash on the path from a leaf to the root
+type ProofStep struct {
+	Hash string `json:"hash"`
+	Left bool   `json:"left"` // sibling is hashed before the running hash
+}
+
+// MerkleProof proves that a leaf is part of a tree with the given root
 type MerkleProof struct {
-	RootHash  string   `json:"root_hash"`
-	LeafHash  string   `json:"leaf_hash"`
-	Proof     []string `json:"proof"`
-	Index     uint64   `json:"index"`
-	NumLeaves uint64   `json:"num_leaves"`
+	RootHash  string      `json:"root_hash"`
+	LeafHash  string      `json:"leaf_hash"`
+	Proof     []ProofStep `json:"proof"`
+	Index     uint64      `json:"index"`      // position of the leaf in sorted order
+	NumLeaves uint64      `json:"num_leaves"` // leaves in the tree
 }
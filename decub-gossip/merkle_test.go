@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// buildTestCatalogTree builds a tree over the given number of snapshots and images
+func buildTestCatalogTree(t *testing.T, numSnapshots, numImages int) (*CatalogMerkleTree, [][2]string) {
+	t.Helper()
+
+	snapshots := make(map[string]*LWWRegister)
+	images := make(map[string]*LWWRegister)
+	var items [][2]string
+	for i := 0; i < numSnapshots; i++ {
+		id := fmt.Sprintf("snap-%d", i)
+		snapshots[id] = NewLWWRegister(map[string]interface{}{"size": i * 1024, "cluster": "a"})
+		items = append(items, [2]string{"snapshot", id})
+	}
+	for i := 0; i < numImages; i++ {
+		id := fmt.Sprintf("image-%d", i)
+		images[id] = NewLWWRegister(map[string]interface{}{"os": "linux", "version": i})
+		items = append(items, [2]string{"image", id})
+	}
+
+	tree := NewCatalogMerkleTree()
+	if err := tree.BuildFromCatalog(snapshots, images); err != nil {
+		t.Fatalf("failed to build tree: %v", err)
+	}
+	return tree, items
+}
+
+func TestProofsVerifyForEveryLeaf(t *testing.T) {
+	for _, size := range [][2]int{{1, 0}, {1, 1}, {2, 1}, {3, 2}, {4, 3}, {5, 6}} {
+		tree, items := buildTestCatalogTree(t, size[0], size[1])
+		numLeaves := uint64(len(items))
+
+		seen := make(map[uint64]bool)
+		for _, item := range items {
+			proof, err := tree.GenerateProof(item[0], item[1])
+			if err != nil {
+				t.Fatalf("%d leaves: proof for %s/%s: %v", numLeaves, item[0], item[1], err)
+			}
+			if proof.NumLeaves != numLeaves || proof.Index >= numLeaves || seen[proof.Index] {
+				t.Fatalf("%d leaves: bad position %d/%d for %s", numLeaves, proof.Index, proof.NumLeaves, item[1])
+			}
+			seen[proof.Index] = true
+
+			if !VerifyMerkleProof(proof) {
+				t.Fatalf("%d leaves: proof for %s/%s at index %d does not verify", numLeaves, item[0], item[1], proof.Index)
+			}
+		}
+	}
+}
+
+func TestVerifyProofRejectsTampering(t *testing.T) {
+	tree, _ := buildTestCatalogTree(t, 3, 2)
+
+	proof, err := tree.GenerateProof("snapshot", "snap-2")
+	if err != nil {
+		t.Fatalf("failed to generate proof: %v", err)
+	}
+	if !tree.VerifyProof(proof) {
+		t.Fatal("untampered proof does not verify")
+	}
+
+	wrongIndex := *proof
+	wrongIndex.Index = (proof.Index + 1) % proof.NumLeaves
+	if VerifyMerkleProof(&wrongIndex) {
+		t.Fatal("proof verified at the wrong index")
+	}
+
+	wrongSibling := *proof
+	wrongSibling.Proof = append([]ProofStep(nil), proof.Proof...)
+	wrongSibling.Proof[0].Hash = EmptyMerkleRoot
+	if VerifyMerkleProof(&wrongSibling) {
+		t.Fatal("proof verified with a tampered sibling")
+	}
+
+	if _, err := tree.GenerateProof("snapshot", "missing"); err == nil {
+		t.Fatal("expected an error for an item not in the tree")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// maxOutboundDeltas bounds the local deltas waiting to be published
+const maxOutboundDeltas = 1024
+
+// queueDelta records a local change for publishing. A change to a key whose
+// previous delta has not been handed to a publisher yet amends that delta in
+// place of adding another event, so peers only ever see the latest value.
+// Callers must hold c.mu.
+func (c *CatalogCRDT) queueDelta(deltaType, key string, data map[string]interface{}) *Delta {
+	c.outSeq++
+
+	for i, queued := range c.deltas {
+		if queued.outSeq <= c.handedOut || queued.Type != deltaType || queued.Key != key {
+			continue
+		}
+
+		// Keep the queued event's sequence number but pick up anything applied since
+		clock := c.vectorClock.Copy()
+		clock[c.nodeID] = queued.VectorClock[c.nodeID]
+
+		amended := *queued
+		amended.VectorClock = clock
+		amended.Data = data
+		amended.Timestamp = time.Now().UnixNano()
+		amended.outSeq = c.outSeq
+
+		// Move it to the back so the buffer stays ordered by outSeq
+		c.deltas = append(append(c.deltas[:i:i], c.deltas[i+1:]...), &amended)
+		return &amended
+	}
+
+	c.vectorClock[c.nodeID]++
+	delta := &Delta{
+		NodeID:      c.nodeID,
+		VectorClock: c.vectorClock.Copy(),
+		Type:        deltaType,
+		Key:         key,
+		Data:        data,
+		Timestamp:   time.Now().UnixNano(),
+		outSeq:      c.outSeq,
+	}
+
+	if len(c.deltas) >= maxOutboundDeltas {
+		// Peers will wait for the dropped event until a full-state sync carries our clock past it
+		log.Printf("Outbound delta buffer full, dropping delta %s", c.deltas[0].Key)
+		c.deltas = c.deltas[1:]
+	}
+	c.deltas = append(c.deltas, delta)
+	return delta
+}
+
+// FlushDeltas hands the queued local deltas to send and, once send succeeds,
+// removes exactly those deltas from the buffer. Deltas queued or amended
+// while send runs stay queued for the next flush.
+func (c *CatalogCRDT) FlushDeltas(send func([]*Delta) error) error {
+	c.mu.Lock()
+	if len(c.deltas) == 0 {
+		c.mu.Unlock()
+		return nil
+	}
+	batch := make([]*Delta, len(c.deltas))
+	copy(batch, c.deltas)
+	sentUpTo := batch[len(batch)-1].outSeq
+	c.handedOut = sentUpTo
+	c.mu.Unlock()
+
+	if err := send(batch); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	remaining := c.deltas[:0]
+	for _, delta := range c.deltas {
+		if delta.outSeq > sentUpTo {
+			remaining = append(remaining, delta)
+		}
+	}
+	c.deltas = remaining
+	return nil
+}
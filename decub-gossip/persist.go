@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// LevelDB key layout for the gossip catalog
+const (
+	clockKey       = "clock"
+	snapshotPrefix = "snapshot/"
+	imagePrefix    = "image/"
+	deltaPrefix    = "delta/"
+)
+
+// storedRegister is the on-disk form of an LWWRegister
+type storedRegister struct {
+	Value     interface{} `json:"value"`
+	Timestamp int64       `json:"timestamp"`
+}
+
+// LoadCatalogCRDT creates a catalog CRDT backed by db, restoring the vector
+// clock and registers a previous run persisted there
+func LoadCatalogCRDT(nodeID string, db *leveldb.DB) (*CatalogCRDT, error) {
+	c := NewCatalogCRDT(nodeID)
+	c.db = db
+
+	data, err := db.Get([]byte(clockKey), nil)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &c.vectorClock); err != nil {
+			return nil, fmt.Errorf("failed to decode vector clock: %w", err)
+		}
+	case err != leveldb.ErrNotFound:
+		return nil, fmt.Errorf("failed to read vector clock: %w", err)
+	}
+
+	if err := loadRegisters(db, snapshotPrefix, c.snapshots); err != nil {
+		return nil, err
+	}
+	if err := loadRegisters(db, imagePrefix, c.images); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// loadRegisters decodes every register stored under prefix into registers
+func loadRegisters(db *leveldb.DB, prefix string, registers map[string]*LWWRegister) error {
+	iter := db.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		var stored storedRegister
+		if err := json.Unmarshal(iter.Value(), &stored); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", iter.Key(), err)
+		}
+		id := strings.TrimPrefix(string(iter.Key()), prefix)
+		registers[id] = &LWWRegister{value: stored.Value, timestamp: stored.Timestamp}
+	}
+	return iter.Error()
+}
+
+// persist writes a register (if any), the delta that produced it (if any)
+// and the vector clock in one batch, so a restart never sees a clock ahead of
+// the state it covers. Callers must hold c.mu.
+func (c *CatalogCRDT) persist(prefix, id string, reg *LWWRegister, delta *Delta) {
+	if c.db == nil {
+		return
+	}
+
+	batch := new(leveldb.Batch)
+
+	if reg != nil {
+		regData, err := json.Marshal(storedRegister{Value: reg.value, Timestamp: reg.timestamp})
+		if err != nil {
+			log.Printf("Failed to encode %s%s: %v", prefix, id, err)
+			return
+		}
+		batch.Put([]byte(prefix+id), regData)
+	}
+
+	if delta != nil {
+		deltaData, err := json.Marshal(delta)
+		if err != nil {
+			log.Printf("Failed to encode delta %s: %v", delta.Key, err)
+			return
+		}
+		batch.Put([]byte(fmt.Sprintf("%s%s/%020d", deltaPrefix, delta.NodeID, delta.VectorClock[delta.NodeID])), deltaData)
+	}
+
+	clockData, _ := json.Marshal(c.vectorClock)
+	batch.Put([]byte(clockKey), clockData)
+
+	if err := c.db.Write(batch, nil); err != nil {
+		log.Printf("Failed to persist %s%s: %v", prefix, id, err)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"lukechampine.com/blake3"
+)
+
+// HashAlgorithm names the hash function a chunk's CID was computed with.
+// CIDs carry it as a prefix, like "blake3:<hex>"; a bare hex CID is a legacy
+// SHA-256 one.
+type HashAlgorithm string
+
+const (
+	SHA256 HashAlgorithm = "sha256"
+	BLAKE3 HashAlgorithm = "blake3"
+)
+
+// parseHashAlgorithm validates an algorithm name; empty selects SHA-256
+func parseHashAlgorithm(name string) (HashAlgorithm, error) {
+	switch alg := HashAlgorithm(strings.ToLower(name)); alg {
+	case "":
+		return SHA256, nil
+	case SHA256, BLAKE3:
+		return alg, nil
+	default:
+		return "", fmt.Errorf("unknown hash algorithm %q", name)
+	}
+}
+
+// digest returns the hex digest of data
+func (a HashAlgorithm) digest(data []byte) string {
+	switch a {
+	case BLAKE3:
+		sum := blake3.Sum256(data)
+		return hex.EncodeToString(sum[:])
+	default:
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:])
+	}
+}
+
+// cid returns the prefixed CID of data
+func (a HashAlgorithm) cid(data []byte) string {
+	return string(a) + ":" + a.digest(data)
+}
+
+// parseCID splits a CID into its algorithm and hex digest
+func parseCID(cid string) (HashAlgorithm, string, error) {
+	alg, digest := SHA256, cid
+	if prefix, rest, ok := strings.Cut(cid, ":"); ok {
+		var err error
+		if alg, err = parseHashAlgorithm(prefix); err != nil || prefix == "" {
+			return "", "", fmt.Errorf("invalid CID %q: unknown hash algorithm", cid)
+		}
+		digest = rest
+	}
+	if len(digest) != 2*sha256.Size {
+		return "", "", fmt.Errorf("invalid CID %q: expected a %d character digest", cid, 2*sha256.Size)
+	}
+	if _, err := hex.DecodeString(digest); err != nil {
+		return "", "", fmt.Errorf("invalid CID %q: digest is not hex", cid)
+	}
+	return alg, strings.ToLower(digest), nil
+}
+
+// chunkKey returns the name a chunk is stored under. SHA-256 chunks keep the
+// bare digest used before CIDs were prefixed, so legacy chunks resolve by
+// either form.
+func chunkKey(cid string) (string, error) {
+	alg, digest, err := parseCID(cid)
+	if err != nil {
+		return "", err
+	}
+	if alg == SHA256 {
+		return digest, nil
+	}
+	return string(alg) + "-" + digest, nil
+}
+
+// verifyCID reports whether data hashes to cid with the algorithm cid names
+func verifyCID(cid string, data []byte) bool {
+	alg, digest, err := parseCID(cid)
+	return err == nil && alg.digest(data) == digest
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestStoreWithBLAKE3(t *testing.T) {
+	storage, err := NewObjectStorage(t.TempDir(), make([]byte, 32))
+	if err != nil {
+		t.Fatalf("failed to create object storage: %v", err)
+	}
+	defer storage.Close()
+	storage.hashAlg = BLAKE3
+
+	for _, encrypt := range []bool{false, true} {
+		cid, err := storage.storeChunk([]byte("fast hashing"), encrypt)
+		if err != nil {
+			t.Fatalf("store: %v", err)
+		}
+		if !strings.HasPrefix(cid, "blake3:") {
+			t.Fatalf("expected a blake3 CID, got %s", cid)
+		}
+		data, err := storage.retrieveChunk(cid)
+		if err != nil || string(data) != "fast hashing" {
+			t.Fatalf("retrieve %s: %q, %v", cid, data, err)
+		}
+		if valid, err := storage.verifyChunk(cid); err != nil || !valid {
+			t.Fatalf("verify %s: %v, %v", cid, valid, err)
+		}
+	}
+
+	// The same digest under another algorithm names a different chunk
+	_, digest, _ := parseCID(storage.computeCID([]byte("fast hashing")))
+	if _, err := storage.retrieveChunk("sha256:" + digest); err == nil {
+		t.Fatal("a blake3 chunk was served for a sha256 CID")
+	}
+}
+
+func TestRetrieveLegacySHA256Chunk(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewObjectStorage(dir, make([]byte, 32))
+	if err != nil {
+		t.Fatalf("failed to create object storage: %v", err)
+	}
+	defer storage.Close()
+	storage.hashAlg = BLAKE3
+
+	// Lay the chunk out the way it was stored before CIDs were prefixed
+	data := []byte("stored before algorithm agility")
+	sum := sha256.Sum256(data)
+	legacy := hex.EncodeToString(sum[:])
+	if err := os.WriteFile(filepath.Join(dir, "chunks", legacy), data, 0644); err != nil {
+		t.Fatalf("failed to write legacy chunk: %v", err)
+	}
+	err = storage.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("chunks")).Put([]byte(legacy), []byte(`{"sha256":"`+legacy+`","size":31,"encrypted":false}`))
+	})
+	if err != nil {
+		t.Fatalf("failed to write legacy metadata: %v", err)
+	}
+
+	for _, cid := range []string{legacy, "sha256:" + legacy} {
+		got, err := storage.retrieveChunk(cid)
+		if err != nil || string(got) != string(data) {
+			t.Fatalf("retrieve %s: %q, %v", cid, got, err)
+		}
+	}
+
+	for _, cid := range []string{"md5:" + legacy, "sha256:../metadata.db", legacy[:10]} {
+		if _, err := storage.retrieveChunk(cid); err == nil {
+			t.Fatalf("expected invalid CID %q to be rejected", cid)
+		}
+	}
+}
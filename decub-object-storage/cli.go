@@ -76,7 +76,7 @@ func (c *CLI) upload(filePath string, encrypt bool) error {
 		return err
 	}
 
-	fmt.Printf("Upload successful. SHA256: %s\n", result["sha256"])
+	fmt.Printf("Upload successful. CID: %s\n", result["cid"])
 	return nil
 }
 
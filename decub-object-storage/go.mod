@@ -3,6 +3,11 @@ module github.com/decub/object-storage
 go 1.19
 
 require (
+	github.com/decube/httpserve v0.0.0
 	github.com/gorilla/mux v1.8.0
 	github.com/boltdb/bolt v1.3.1
+	github.com/minio/minio-go/v7 v7.0.52
+	lukechampine.com/blake3 v1.1.7
 )
+
+replace github.com/decube/httpserve => ../pkg/httpserve
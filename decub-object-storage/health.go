@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+var startTime = time.Now()
+
+// HealthResponse is returned by the /health endpoint
+type HealthResponse struct {
+	Status        string `json:"status"`
+	Service       string `json:"service"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+	Error         string `json:"error,omitempty"`
+}
+
+// writeHealth reports the service as healthy, or as unavailable with 503 when readyErr is set
+func writeHealth(w http.ResponseWriter, service string, readyErr error) {
+	resp := HealthResponse{
+		Status:        "healthy",
+		Service:       service,
+		UptimeSeconds: int64(time.Since(startTime).Seconds()),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if readyErr != nil {
+		resp.Status = "unhealthy"
+		resp.Error = readyErr.Error()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ready checks that the metadata database is open and the chunks bucket exists
+func (s *ObjectStorage) ready() error {
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte("chunks")) == nil {
+			return fmt.Errorf("chunks bucket missing")
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("boltdb: %w", err)
+	}
+	return nil
+}
+
+func (s *ObjectStorage) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, "decub-object-storage", s.ready())
+}
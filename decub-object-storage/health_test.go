@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	storage, err := NewObjectStorage(t.TempDir(), make([]byte, 32))
+	if err != nil {
+		t.Fatalf("failed to create object storage: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	storage.handleHealth(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var health HealthResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+		t.Fatalf("invalid health response: %v", err)
+	}
+	if health.Status != "healthy" || health.Service != "decub-object-storage" {
+		t.Fatalf("unexpected health response: %+v", health)
+	}
+
+	storage.Close()
+
+	rec = httptest.NewRecorder()
+	storage.handleHealth(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 with closed store, got %d", rec.Code)
+	}
+}
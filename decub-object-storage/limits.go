@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMaxBodyBytes   = 64 << 20 // 64 MiB
+	defaultRequestTimeout = 30 * time.Second
+)
+
+// requestLimits bounds the size of request bodies and how long a handler may run
+type requestLimits struct {
+	maxBodyBytes int64
+	timeout      time.Duration
+}
+
+// middleware rejects bodies over the limit with 413 and gives each request a
+// deadline; handlers report an expired deadline as 504
+func (l requestLimits) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.maxBodyBytes > 0 {
+			if r.ContentLength > l.maxBodyBytes {
+				http.Error(w, fmt.Sprintf("request body exceeds %d bytes", l.maxBodyBytes), http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, l.maxBodyBytes)
+		}
+		if l.timeout > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), l.timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// errorStatus maps request limit failures to 413 and 504, and anything else to def
+func errorStatus(err error, def int) int {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return def
+	}
+}
+
+// envBytes reads a byte count from the environment variable name
+func envBytes(name string, def int64) (int64, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q: expected a byte count", name, v)
+	}
+	return n, nil
+}
+
+// envDuration reads a duration such as "30s" from the environment variable name
+func envDuration(name string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s %q: expected a duration", name, v)
+	}
+	return d, nil
+}
+
+// envString reads the environment variable name, or def if it is unset
+func envString(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// envCount reads a non-negative count from the environment variable name
+func envCount(name string, def int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q: expected a count", name, v)
+	}
+	return n, nil
+}
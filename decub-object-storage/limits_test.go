@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOversizedChunkRejected(t *testing.T) {
+	storage, err := NewObjectStorage(t.TempDir(), make([]byte, 32))
+	if err != nil {
+		t.Fatalf("failed to create object storage: %v", err)
+	}
+	defer storage.Close()
+
+	r := newRouter(storage)
+	r.Use(requestLimits{maxBodyBytes: 16, timeout: time.Second}.middleware)
+
+	body := strings.Repeat("x", 32)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/chunk", strings.NewReader(body)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("with Content-Length: expected 413, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/chunk", strings.NewReader(body))
+	req.ContentLength = -1
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("without Content-Length: expected 413, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/chunk", strings.NewReader("small")))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("chunk under the limit: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -1,21 +1,25 @@
 package main
 
 import (
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
+	"time"
 
 	"github.com/boltdb/bolt"
+	"github.com/decube/httpserve"
 	"github.com/gorilla/mux"
 )
 
@@ -24,13 +28,25 @@ type ObjectStorage struct {
 	dataDir string
 	db      *bolt.DB
 	key     []byte // AES-256 key
+	hashAlg HashAlgorithm
+
+	chunkSize int // size objects are split into
+	scrubber  *scrubber
+	tier      *tier // nil keeps chunks on local disk only
 }
 
 // ChunkMetadata represents metadata for a stored chunk
 type ChunkMetadata struct {
-	SHA256    string `json:"sha256"`
+	CID       string `json:"cid"`
 	Size      int64  `json:"size"`
 	Encrypted bool   `json:"encrypted"`
+
+	// Successful retrievals, and when the last one happened
+	AccessCount uint64    `json:"access_count,omitempty"`
+	LastAccess  time.Time `json:"last_access,omitempty"`
+
+	// Tiered is set once the chunk has been uploaded to the cold tier
+	Tiered bool `json:"tiered,omitempty"`
 }
 
 // NewObjectStorage creates a new object storage instance
@@ -50,29 +66,37 @@ func NewObjectStorage(dataDir string, key []byte) (*ObjectStorage, error) {
 
 	// Create buckets
 	err = db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte("chunks"))
-		return err
+		for _, bucket := range []string{"chunks", "manifests"} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &ObjectStorage{
+	s := &ObjectStorage{
 		dataDir: dataDir,
 		db:      db,
 		key:     key,
-	}, nil
+		hashAlg: SHA256,
+
+		chunkSize: defaultChunkSize,
+	}
+	s.scrubber = newScrubber(s)
+	return s, nil
 }
 
-// computeSHA256 computes SHA256 hash of data
-func (os *ObjectStorage) computeSHA256(data []byte) string {
-	hash := sha256.Sum256(data)
-	return hex.EncodeToString(hash[:])
+// computeCID computes the CID of data with the configured hash algorithm
+func (s *ObjectStorage) computeCID(data []byte) string {
+	return s.hashAlg.cid(data)
 }
 
 // encrypt encrypts data using AES-256-GCM
-func (os *ObjectStorage) encrypt(plaintext []byte) ([]byte, error) {
-	block, err := aes.NewCipher(os.key)
+func (s *ObjectStorage) encrypt(plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(s.key)
 	if err != nil {
 		return nil, err
 	}
@@ -92,8 +116,8 @@ func (os *ObjectStorage) encrypt(plaintext []byte) ([]byte, error) {
 }
 
 // decrypt decrypts data using AES-256-GCM
-func (os *ObjectStorage) decrypt(ciphertext []byte) ([]byte, error) {
-	block, err := aes.NewCipher(os.key)
+func (s *ObjectStorage) decrypt(ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(s.key)
 	if err != nil {
 		return nil, err
 	}
@@ -118,12 +142,12 @@ func (os *ObjectStorage) decrypt(ciphertext []byte) ([]byte, error) {
 }
 
 // storeChunk stores a chunk with optional encryption
-func (os *ObjectStorage) storeChunk(data []byte, encrypt bool) (string, error) {
+func (s *ObjectStorage) storeChunk(data []byte, encrypt bool) (string, error) {
 	var finalData []byte
 	var encrypted bool
 
 	if encrypt {
-		encryptedData, err := os.encrypt(data)
+		encryptedData, err := s.encrypt(data)
 		if err != nil {
 			return "", err
 		}
@@ -134,11 +158,15 @@ func (os *ObjectStorage) storeChunk(data []byte, encrypt bool) (string, error) {
 		encrypted = false
 	}
 
-	// Compute SHA256 of original data for integrity
-	sha256 := os.computeSHA256(data)
+	// Compute the CID of original data for integrity
+	cid := s.computeCID(data)
+	key, err := chunkKey(cid)
+	if err != nil {
+		return "", err
+	}
 
 	// Store file
-	filePath := filepath.Join(os.dataDir, "chunks", sha256)
+	filePath := filepath.Join(s.dataDir, "chunks", key)
 	file, err := os.Create(filePath)
 	if err != nil {
 		return "", err
@@ -151,34 +179,83 @@ func (os *ObjectStorage) storeChunk(data []byte, encrypt bool) (string, error) {
 
 	// Store metadata
 	metadata := ChunkMetadata{
-		SHA256:    sha256,
+		CID:       cid,
 		Size:      int64(len(data)),
 		Encrypted: encrypted,
 	}
 
-	err = os.db.Update(func(tx *bolt.Tx) error {
+	err = s.db.Update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte("chunks"))
+
+		// Storing a chunk again keeps its access history
+		var existing ChunkMetadata
+		if data := bucket.Get([]byte(key)); data != nil && json.Unmarshal(data, &existing) == nil {
+			metadata.AccessCount = existing.AccessCount
+			metadata.LastAccess = existing.LastAccess
+		}
+
 		jsonData, err := json.Marshal(metadata)
 		if err != nil {
 			return err
 		}
-		return bucket.Put([]byte(sha256), jsonData)
+		return bucket.Put([]byte(key), jsonData)
 	})
 
 	if err != nil {
 		return "", err
 	}
 
-	return sha256, nil
+	if s.tier != nil {
+		s.tier.enqueue(key)
+	}
+
+	return cid, nil
 }
 
-// retrieveChunk retrieves a chunk by SHA256
-func (os *ObjectStorage) retrieveChunk(sha256 string) ([]byte, error) {
+// retrieveChunk retrieves a chunk by CID, prefixed or legacy bare SHA-256,
+// and counts the access
+func (s *ObjectStorage) retrieveChunk(cid string) ([]byte, error) {
+	data, err := s.fetchChunk(cid)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.recordAccess(cid, time.Now()); err != nil {
+		log.Printf("Failed to record access to chunk %s: %v", cid, err)
+	}
+	return data, nil
+}
+
+// fetchChunk reads a chunk like readChunk, first restoring it from the cold
+// tier if its local copy was evicted
+func (s *ObjectStorage) fetchChunk(cid string) ([]byte, error) {
+	data, err := s.readChunk(cid)
+	if !errors.Is(err, errChunkEvicted) || s.tier == nil {
+		return data, err
+	}
+
+	key, err := chunkKey(cid)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.tier.restore(context.Background(), key); err != nil {
+		return nil, fmt.Errorf("restore chunk from cold tier: %w", err)
+	}
+	return s.readChunk(cid)
+}
+
+// readChunk reads, decrypts and verifies a local chunk without counting an access
+func (s *ObjectStorage) readChunk(cid string) ([]byte, error) {
+	key, err := chunkKey(cid)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get metadata
 	var metadata ChunkMetadata
-	err := os.db.View(func(tx *bolt.Tx) error {
+	err = s.db.View(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte("chunks"))
-		data := bucket.Get([]byte(sha256))
+		data := bucket.Get([]byte(key))
 		if data == nil {
 			return fmt.Errorf("chunk not found")
 		}
@@ -189,9 +266,12 @@ func (os *ObjectStorage) retrieveChunk(sha256 string) ([]byte, error) {
 	}
 
 	// Read file
-	filePath := filepath.Join(os.dataDir, "chunks", sha256)
+	filePath := filepath.Join(s.dataDir, "chunks", key)
 	file, err := os.Open(filePath)
 	if err != nil {
+		if os.IsNotExist(err) && metadata.Tiered {
+			return nil, errChunkEvicted
+		}
 		return nil, err
 	}
 	defer file.Close()
@@ -203,15 +283,14 @@ func (os *ObjectStorage) retrieveChunk(sha256 string) ([]byte, error) {
 
 	// Decrypt if necessary
 	if metadata.Encrypted {
-		data, err = os.decrypt(data)
+		data, err = s.decrypt(data)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	// Verify integrity
-	computedSHA256 := os.computeSHA256(data)
-	if computedSHA256 != sha256 {
+	// Verify integrity with the algorithm the CID names
+	if !verifyCID(cid, data) {
 		return nil, fmt.Errorf("integrity check failed")
 	}
 
@@ -219,47 +298,54 @@ func (os *ObjectStorage) retrieveChunk(sha256 string) ([]byte, error) {
 }
 
 // verifyChunk verifies a chunk's integrity
-func (os *ObjectStorage) verifyChunk(sha256 string) (bool, error) {
-	data, err := os.retrieveChunk(sha256)
+func (s *ObjectStorage) verifyChunk(cid string) (bool, error) {
+	data, err := s.fetchChunk(cid)
 	if err != nil {
 		return false, err
 	}
 
-	computedSHA256 := os.computeSHA256(data)
-	return computedSHA256 == sha256, nil
+	return verifyCID(cid, data), nil
 }
 
 // Close closes the object storage
-func (os *ObjectStorage) Close() error {
-	return os.db.Close()
+func (s *ObjectStorage) Close() error {
+	return s.db.Close()
 }
 
 // API handlers
-func (os *ObjectStorage) handlePutChunk(w http.ResponseWriter, r *http.Request) {
+func (s *ObjectStorage) handlePutChunk(w http.ResponseWriter, r *http.Request) {
 	data, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
+		return
+	}
+	if err := r.Context().Err(); err != nil {
+		http.Error(w, err.Error(), errorStatus(err, http.StatusServiceUnavailable))
 		return
 	}
 
 	encrypt := r.URL.Query().Get("encrypt") == "true"
 
-	sha256, err := os.storeChunk(data, encrypt)
+	cid, err := s.storeChunk(data, encrypt)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	response := map[string]string{"sha256": sha256}
+	response := map[string]string{"cid": cid}
+	if alg, digest, _ := parseCID(cid); alg == SHA256 {
+		// Clients that predate prefixed CIDs read the bare digest
+		response["sha256"] = digest
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-func (os *ObjectStorage) handleGetChunk(w http.ResponseWriter, r *http.Request) {
+func (s *ObjectStorage) handleGetChunk(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	sha256 := vars["sha256"]
+	cid := vars["cid"]
 
-	data, err := os.retrieveChunk(sha256)
+	data, err := s.retrieveChunk(cid)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
@@ -269,11 +355,11 @@ func (os *ObjectStorage) handleGetChunk(w http.ResponseWriter, r *http.Request)
 	w.Write(data)
 }
 
-func (os *ObjectStorage) handleVerifyChunk(w http.ResponseWriter, r *http.Request) {
+func (s *ObjectStorage) handleVerifyChunk(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	sha256 := vars["sha256"]
+	cid := vars["cid"]
 
-	valid, err := os.verifyChunk(sha256)
+	valid, err := s.verifyChunk(cid)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -284,28 +370,50 @@ func (os *ObjectStorage) handleVerifyChunk(w http.ResponseWriter, r *http.Reques
 	json.NewEncoder(w).Encode(response)
 }
 
-func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage:")
-		fmt.Println("  go run main.go <data-dir> [encryption-key]  # Start server")
-		fmt.Println("  go run main.go cli <command> ...            # CLI mode")
-		os.Exit(1)
-	}
+// newRouter registers the object storage API routes
+func newRouter(storage *ObjectStorage) *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/health", storage.handleHealth).Methods("GET")
+	r.HandleFunc("/chunk", storage.handlePutChunk).Methods("PUT")
+	r.HandleFunc("/chunk/{cid}", storage.handleGetChunk).Methods("GET")
+	r.HandleFunc("/chunk/{cid}/verify", storage.handleVerifyChunk).Methods("GET")
+	r.HandleFunc("/object", storage.handlePutObject).Methods("PUT")
+	r.HandleFunc("/object/{id}", storage.handleGetObject).Methods("GET")
+	r.HandleFunc("/object/{id}/manifest", storage.handleGetManifest).Methods("GET")
+	r.HandleFunc("/stats", storage.handleStats).Methods("GET")
+	r.HandleFunc("/scrub/report", storage.handleScrubReport).Methods("GET")
+	return r
+}
 
+func main() {
 	if len(os.Args) > 2 && os.Args[1] == "cli" {
 		RunCLI()
 		return
 	}
 
-	dataDir := os.Args[1]
+	envPort, err := httpserve.PortFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	port := flag.Int("port", envPort, "port to listen on (env PORT); 0 picks a free port")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) < 1 {
+		fmt.Println("Usage:")
+		fmt.Println("  go run main.go [--port N] <data-dir> [encryption-key]  # Start server")
+		fmt.Println("  go run main.go cli <command> ...                       # CLI mode")
+		os.Exit(1)
+	}
+
+	dataDir := args[0]
 
 	var key []byte
-	if len(os.Args) > 2 {
-		keyStr := os.Args[2]
+	if len(args) > 1 {
+		keyStr := args[1]
 		if len(keyStr) != 64 { // 32 bytes * 2 for hex
 			log.Fatal("Encryption key must be 64 hex characters (32 bytes)")
 		}
-		var err error
 		key, err = hex.DecodeString(keyStr)
 		if err != nil {
 			log.Fatal("Invalid encryption key format")
@@ -319,17 +427,79 @@ func main() {
 		fmt.Printf("Generated encryption key: %s\n", hex.EncodeToString(key))
 	}
 
-	os, err := NewObjectStorage(dataDir, key)
+	hashAlg, err := parseHashAlgorithm(os.Getenv("DECUB_HASH_ALGORITHM"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	maxBodyBytes, err := envBytes("DECUB_MAX_BODY_BYTES", defaultMaxBodyBytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	requestTimeout, err := envDuration("DECUB_REQUEST_TIMEOUT", defaultRequestTimeout)
+	if err != nil {
+		log.Fatal(err)
+	}
+	chunkSize, err := envBytes("DECUB_CHUNK_SIZE", defaultChunkSize)
+	if err != nil || chunkSize == 0 {
+		log.Fatalf("invalid DECUB_CHUNK_SIZE: expected a positive byte count")
+	}
+	scrubInterval, err := envDuration("DECUB_SCRUB_INTERVAL", defaultScrubInterval)
+	if err != nil {
+		log.Fatal(err)
+	}
+	scrubRate, err := envCount("DECUB_SCRUB_RATE", defaultScrubRate)
+	if err != nil {
+		log.Fatal(err)
+	}
+	evictAfter, err := envDuration("DECUB_TIER_EVICT_AFTER", 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sweepInterval, err := envDuration("DECUB_TIER_SWEEP_INTERVAL", defaultTierSweepInterval)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	storage, err := NewObjectStorage(dataDir, key)
 	if err != nil {
 		log.Fatalf("Failed to create object storage: %v", err)
 	}
-	defer os.Close()
+	storage.hashAlg = hashAlg
+	storage.chunkSize = int(chunkSize)
+	storage.scrubber.interval = scrubInterval
+	storage.scrubber.rate = scrubRate
+	stopScrubber := storage.scrubber.start()
+
+	stopTier := func() {}
+	if endpoint := os.Getenv("DECUB_S3_ENDPOINT"); endpoint != "" {
+		cold, err := newS3ColdStore(endpoint, os.Getenv("DECUB_S3_ACCESS_KEY"), os.Getenv("DECUB_S3_SECRET_KEY"),
+			envString("DECUB_S3_BUCKET", "decub-chunks"), os.Getenv("DECUB_S3_SECURE") == "true")
+		if err != nil {
+			log.Fatalf("Failed to connect to cold tier: %v", err)
+		}
+		storage.tier = newTier(storage, cold)
+		storage.tier.evictAfter = evictAfter
+		storage.tier.sweepInterval = sweepInterval
+		stopTier = storage.tier.start()
+	}
 
-	r := mux.NewRouter()
-	r.HandleFunc("/chunk", os.handlePutChunk).Methods("PUT")
-	r.HandleFunc("/chunk/{sha256}", os.handleGetChunk).Methods("GET")
-	r.HandleFunc("/chunk/{sha256}/verify", os.handleVerifyChunk).Methods("GET")
+	r := newRouter(storage)
+	r.Use(requestLimits{maxBodyBytes: maxBodyBytes, timeout: requestTimeout}.middleware)
 
-	fmt.Println("Object storage server starting on :8080")
-	log.Fatal(http.ListenAndServe(":8080", r))
+	lis, err := net.Listen("tcp", httpserve.PortAddr(*port))
+	if err != nil {
+		log.Fatalf("Failed to listen on port %d: %v", *port, err)
+	}
+	fmt.Printf("Object storage server listening on %s\n", lis.Addr())
+	err = httpserve.ServeUntilSignal(lis, r)
+
+	// Close the store only after in-flight requests and scrubbing have finished with it
+	stopScrubber()
+	stopTier()
+	storage.Close()
+	if err != nil {
+		log.Fatalf("Object storage server failed: %v", err)
+	}
+	fmt.Println("Object storage server stopped")
 }
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/mux"
+)
+
+// defaultChunkSize is the size objects are split into by PUT /object
+const defaultChunkSize = 4 << 20 // 4 MiB
+
+var errObjectNotFound = errors.New("object not found")
+
+// ObjectManifest lists the chunks an object is made of, in order
+type ObjectManifest struct {
+	ID        string   `json:"id"`
+	Chunks    []string `json:"chunks"` // chunk CIDs
+	Size      int64    `json:"size"`
+	Encrypted bool     `json:"encrypted"`
+}
+
+// storeObject splits data into chunks, stores them and records the manifest
+// under id. An empty id names the object by the CID of its whole content.
+func (s *ObjectStorage) storeObject(id string, data []byte, encrypt bool) (*ObjectManifest, error) {
+	if id == "" {
+		id = s.computeCID(data)
+	}
+
+	manifest := &ObjectManifest{ID: id, Chunks: []string{}, Size: int64(len(data)), Encrypted: encrypt}
+	for offset := 0; offset < len(data); offset += s.chunkSize {
+		end := offset + s.chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		cid, err := s.storeChunk(data[offset:end], encrypt)
+		if err != nil {
+			return nil, fmt.Errorf("store chunk %d: %w", len(manifest.Chunks), err)
+		}
+		manifest.Chunks = append(manifest.Chunks, cid)
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		jsonData, err := json.Marshal(manifest)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket([]byte("manifests")).Put([]byte(id), jsonData)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// getManifest returns the manifest of the object id
+func (s *ObjectStorage) getManifest(id string) (*ObjectManifest, error) {
+	var manifest ObjectManifest
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte("manifests")).Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("%s: %w", id, errObjectNotFound)
+		}
+		return json.Unmarshal(data, &manifest)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// retrieveObject reassembles the object id from its chunks, each of which is
+// verified against its CID as it is read
+func (s *ObjectStorage) retrieveObject(id string) ([]byte, error) {
+	manifest, err := s.getManifest(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, 0, manifest.Size)
+	for i, cid := range manifest.Chunks {
+		chunk, err := s.retrieveChunk(cid)
+		if err != nil {
+			return nil, fmt.Errorf("object %s chunk %d (%s): %w", id, i, cid, err)
+		}
+		data = append(data, chunk...)
+	}
+	if int64(len(data)) != manifest.Size {
+		return nil, fmt.Errorf("object %s: reassembled %d bytes, manifest says %d", id, len(data), manifest.Size)
+	}
+	return data, nil
+}
+
+// objectStatus maps object errors to 404 for unknown objects and def otherwise
+func objectStatus(err error, def int) int {
+	if errors.Is(err, errObjectNotFound) {
+		return http.StatusNotFound
+	}
+	return def
+}
+
+func (s *ObjectStorage) handlePutObject(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
+		return
+	}
+	if err := r.Context().Err(); err != nil {
+		http.Error(w, err.Error(), errorStatus(err, http.StatusServiceUnavailable))
+		return
+	}
+
+	encrypt := r.URL.Query().Get("encrypt") == "true"
+
+	manifest, err := s.storeObject(r.URL.Query().Get("id"), data, encrypt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(manifest)
+}
+
+func (s *ObjectStorage) handleGetObject(w http.ResponseWriter, r *http.Request) {
+	data, err := s.retrieveObject(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), objectStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+func (s *ObjectStorage) handleGetManifest(w http.ResponseWriter, r *http.Request) {
+	manifest, err := s.getManifest(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), objectStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(manifest)
+}
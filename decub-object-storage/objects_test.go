@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStoreAndReassembleObject(t *testing.T) {
+	storage, err := NewObjectStorage(t.TempDir(), make([]byte, 32))
+	if err != nil {
+		t.Fatalf("failed to create object storage: %v", err)
+	}
+	defer storage.Close()
+	storage.chunkSize = 8
+
+	r := newRouter(storage)
+	object := []byte("three chunks of snapshot")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/object?id=snap-1&encrypt=true", bytes.NewReader(object)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("put object returned %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/object/snap-1", nil))
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), object) {
+		t.Fatalf("get object returned %d: %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/object/snap-1/manifest", nil))
+	var manifest ObjectManifest
+	if err := json.Unmarshal(rec.Body.Bytes(), &manifest); err != nil {
+		t.Fatalf("invalid manifest: %v", err)
+	}
+	if manifest.ID != "snap-1" || manifest.Size != int64(len(object)) || len(manifest.Chunks) != 3 || !manifest.Encrypted {
+		t.Fatalf("unexpected manifest: %+v", manifest)
+	}
+	var chunks [][]byte
+	for i, cid := range manifest.Chunks {
+		chunk, err := storage.retrieveChunk(cid)
+		if err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	if !bytes.Equal(bytes.Join(chunks, nil), object) {
+		t.Fatalf("manifest chunks do not make up the object: %q", chunks)
+	}
+
+	// A damaged chunk fails reassembly instead of returning bad data
+	key, _ := chunkKey(manifest.Chunks[1])
+	if err := os.WriteFile(filepath.Join(storage.dataDir, "chunks", key), []byte("bit rot"), 0644); err != nil {
+		t.Fatalf("failed to damage chunk: %v", err)
+	}
+	if _, err := storage.retrieveObject("snap-1"); err == nil {
+		t.Fatal("object with a damaged chunk was reassembled")
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/object/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("missing object: expected 404, got %d", rec.Code)
+	}
+}
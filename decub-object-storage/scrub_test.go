@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScrubberFlagsCorruptChunk(t *testing.T) {
+	storage, err := NewObjectStorage(t.TempDir(), make([]byte, 32))
+	if err != nil {
+		t.Fatalf("failed to create object storage: %v", err)
+	}
+	defer storage.Close()
+	storage.scrubber.rate = 0
+
+	if _, err := storage.storeChunk([]byte("healthy chunk"), false); err != nil {
+		t.Fatalf("failed to store chunk: %v", err)
+	}
+	rotten, err := storage.storeChunk([]byte("rotten chunk"), true)
+	if err != nil {
+		t.Fatalf("failed to store chunk: %v", err)
+	}
+
+	storage.scrubber.scrubOnce(context.Background())
+	if report := storage.scrubber.Report(); report.Scanned != 2 || len(report.Corrupt) != 0 {
+		t.Fatalf("expected a clean pass over 2 chunks, got %+v", report)
+	}
+
+	key, _ := chunkKey(rotten)
+	if err := os.WriteFile(filepath.Join(storage.dataDir, "chunks", key), []byte("bit rot"), 0644); err != nil {
+		t.Fatalf("failed to damage chunk: %v", err)
+	}
+	storage.scrubber.scrubOnce(context.Background())
+
+	rec := httptest.NewRecorder()
+	newRouter(storage).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/scrub/report", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("scrub report returned %d: %s", rec.Code, rec.Body.String())
+	}
+	var report ScrubReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("invalid scrub report: %v", err)
+	}
+	if report.Passes != 2 || report.Scanned != 2 || report.TotalScanned != 4 || report.TotalCorrupt != 1 {
+		t.Fatalf("unexpected scrub report: %+v", report)
+	}
+	if len(report.Corrupt) != 1 || report.Corrupt[0].CID != rotten {
+		t.Fatalf("expected %s to be flagged, got %+v", rotten, report.Corrupt)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// defaultTopChunks is how many of the most accessed chunks GET /stats lists
+const defaultTopChunks = 10
+
+// ChunkAccess is the access history of one chunk
+type ChunkAccess struct {
+	CID         string    `json:"cid"`
+	AccessCount uint64    `json:"access_count"`
+	LastAccess  time.Time `json:"last_access"`
+}
+
+// StorageStats summarizes the stored chunks, served by GET /stats
+type StorageStats struct {
+	Chunks          int           `json:"chunks"`
+	TotalBytes      int64         `json:"total_bytes"` // plaintext size of all chunks
+	EncryptedChunks int           `json:"encrypted_chunks"`
+	PlaintextChunks int           `json:"plaintext_chunks"`
+	TopChunks       []ChunkAccess `json:"top_chunks"` // most accessed first
+}
+
+// recordAccess counts a retrieval of the chunk cid at now
+func (s *ObjectStorage) recordAccess(cid string, now time.Time) error {
+	key, err := chunkKey(cid)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("chunks"))
+		data := bucket.Get([]byte(key))
+		if data == nil {
+			return fmt.Errorf("chunk not found")
+		}
+
+		var metadata ChunkMetadata
+		if err := json.Unmarshal(data, &metadata); err != nil {
+			return err
+		}
+		metadata.AccessCount++
+		metadata.LastAccess = now
+
+		jsonData, err := json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(key), jsonData)
+	})
+}
+
+// stats aggregates the chunk metadata and lists the top most accessed chunks
+func (s *ObjectStorage) stats(top int) (*StorageStats, error) {
+	stats := &StorageStats{TopChunks: []ChunkAccess{}}
+	var accessed []ChunkAccess
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("chunks")).ForEach(func(k, v []byte) error {
+			var metadata ChunkMetadata
+			if err := json.Unmarshal(v, &metadata); err != nil {
+				return fmt.Errorf("chunk %s: %w", k, err)
+			}
+
+			stats.Chunks++
+			stats.TotalBytes += metadata.Size
+			if metadata.Encrypted {
+				stats.EncryptedChunks++
+			} else {
+				stats.PlaintextChunks++
+			}
+
+			if metadata.AccessCount > 0 {
+				cid := metadata.CID
+				if cid == "" {
+					cid = string(k) // legacy chunks are keyed by their bare digest
+				}
+				accessed = append(accessed, ChunkAccess{
+					CID:         cid,
+					AccessCount: metadata.AccessCount,
+					LastAccess:  metadata.LastAccess,
+				})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(accessed, func(i, j int) bool {
+		if accessed[i].AccessCount != accessed[j].AccessCount {
+			return accessed[i].AccessCount > accessed[j].AccessCount
+		}
+		return accessed[i].CID < accessed[j].CID
+	})
+	if len(accessed) > top {
+		accessed = accessed[:top]
+	}
+	stats.TopChunks = append(stats.TopChunks, accessed...)
+	return stats, nil
+}
+
+func (s *ObjectStorage) handleStats(w http.ResponseWriter, r *http.Request) {
+	top := defaultTopChunks
+	if v := r.URL.Query().Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, fmt.Sprintf("invalid top %q", v), http.StatusBadRequest)
+			return
+		}
+		top = n
+	}
+
+	stats, err := s.stats(top)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatsReportAccessCountsAndBytes(t *testing.T) {
+	storage, err := NewObjectStorage(t.TempDir(), make([]byte, 32))
+	if err != nil {
+		t.Fatalf("failed to create object storage: %v", err)
+	}
+	defer storage.Close()
+
+	hot, err := storage.storeChunk([]byte("hot chunk"), false)
+	if err != nil {
+		t.Fatalf("failed to store chunk: %v", err)
+	}
+	warm, err := storage.storeChunk([]byte("warm chunk"), true)
+	if err != nil {
+		t.Fatalf("failed to store chunk: %v", err)
+	}
+	if _, err := storage.storeChunk([]byte("cold"), false); err != nil {
+		t.Fatalf("failed to store chunk: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := storage.retrieveChunk(hot); err != nil {
+			t.Fatalf("failed to retrieve chunk: %v", err)
+		}
+	}
+	if _, err := storage.retrieveChunk(warm); err != nil {
+		t.Fatalf("failed to retrieve chunk: %v", err)
+	}
+	// Verification is not an access
+	if _, err := storage.verifyChunk(warm); err != nil {
+		t.Fatalf("failed to verify chunk: %v", err)
+	}
+	// Re-storing a chunk keeps its history
+	if _, err := storage.storeChunk([]byte("hot chunk"), false); err != nil {
+		t.Fatalf("failed to store chunk: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	newRouter(storage).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats?top=2", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("stats returned %d: %s", rec.Code, rec.Body.String())
+	}
+	var stats StorageStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("invalid stats: %v", err)
+	}
+
+	if stats.Chunks != 3 || stats.TotalBytes != int64(len("hot chunk")+len("warm chunk")+len("cold")) {
+		t.Fatalf("unexpected totals: %+v", stats)
+	}
+	if stats.EncryptedChunks != 1 || stats.PlaintextChunks != 2 {
+		t.Fatalf("unexpected encryption counts: %+v", stats)
+	}
+	if len(stats.TopChunks) != 2 {
+		t.Fatalf("expected the 2 accessed chunks, got %+v", stats.TopChunks)
+	}
+	if got := stats.TopChunks[0]; got.CID != hot || got.AccessCount != 3 || got.LastAccess.IsZero() {
+		t.Fatalf("unexpected top chunk: %+v", got)
+	}
+	if got := stats.TopChunks[1]; got.CID != warm || got.AccessCount != 1 {
+		t.Fatalf("unexpected second chunk: %+v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	newRouter(storage).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats?top=-1", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a negative top, got %d", rec.Code)
+	}
+}
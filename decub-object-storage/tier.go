@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	defaultTierSweepInterval = 10 * time.Minute
+	tierUploadQueue          = 1024
+)
+
+// errChunkEvicted is returned for a chunk whose local copy was evicted to the
+// cold tier; it has to be restored before it can be read
+var errChunkEvicted = errors.New("chunk evicted to cold tier")
+
+// ColdStore is the object store chunks are tiered to. Chunks are stored as
+// they are on disk, so encrypted chunks stay encrypted.
+type ColdStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// s3ColdStore keeps chunks in an S3-compatible bucket, such as MinIO
+type s3ColdStore struct {
+	client *minio.Client
+	bucket string
+}
+
+// newS3ColdStore connects to endpoint and creates bucket if it doesn't exist
+func newS3ColdStore(endpoint, accessKey, secretKey, bucket string, secure bool) (*s3ColdStore, error) {
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: secure,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = client.MakeBucket(context.Background(), bucket, minio.MakeBucketOptions{})
+	if err != nil {
+		exists, errBucketExists := client.BucketExists(context.Background(), bucket)
+		if errBucketExists != nil || !exists {
+			return nil, err
+		}
+	}
+	return &s3ColdStore{client: client, bucket: bucket}, nil
+}
+
+func (s *s3ColdStore) Put(ctx context.Context, key string, data []byte) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/octet-stream",
+	})
+	return err
+}
+
+func (s *s3ColdStore) Get(ctx context.Context, key string) ([]byte, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	return io.ReadAll(obj)
+}
+
+// tier uploads chunks to a cold store in the background and evicts local
+// copies that have gone unread for evictAfter. Evicted chunks are restored
+// from the cold store on their next read.
+type tier struct {
+	storage *ObjectStorage
+	cold    ColdStore
+
+	evictAfter    time.Duration // 0 keeps every local copy
+	sweepInterval time.Duration // how often to evict and retry failed uploads
+
+	uploads chan string // chunk keys waiting to be uploaded
+	pending sync.WaitGroup
+}
+
+func newTier(storage *ObjectStorage, cold ColdStore) *tier {
+	return &tier{
+		storage:       storage,
+		cold:          cold,
+		sweepInterval: defaultTierSweepInterval,
+		uploads:       make(chan string, tierUploadQueue),
+	}
+}
+
+// enqueue schedules the chunk key for upload. When the queue is full the
+// chunk is left for the next sweep instead of blocking the caller.
+func (t *tier) enqueue(key string) {
+	t.pending.Add(1)
+	select {
+	case t.uploads <- key:
+	default:
+		t.pending.Done()
+		log.Printf("Tier: upload queue full, chunk %s left for the next sweep", key)
+	}
+}
+
+// flush waits for every queued upload to finish
+func (t *tier) flush() {
+	t.pending.Wait()
+}
+
+// start runs the upload worker and the eviction sweep until the returned stop
+// function is called; stop waits for queued uploads to finish first
+func (t *tier) start() (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for key := range t.uploads {
+			if err := t.upload(ctx, key); err != nil {
+				log.Printf("Tier: failed to upload chunk %s: %v", key, err)
+			}
+			t.pending.Done()
+		}
+	}()
+
+	if t.sweepInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(t.sweepInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case now := <-ticker.C:
+					t.sweep(ctx, now)
+				}
+			}
+		}()
+	}
+
+	return func() {
+		t.flush()
+		cancel()
+		close(t.uploads)
+		wg.Wait()
+	}
+}
+
+// upload copies the local chunk file to the cold store and marks the chunk
+// as tiered, which makes its local copy eligible for eviction
+func (t *tier) upload(ctx context.Context, key string) error {
+	data, err := os.ReadFile(filepath.Join(t.storage.dataDir, "chunks", key))
+	if err != nil {
+		return err
+	}
+	if err := t.cold.Put(ctx, key, data); err != nil {
+		return err
+	}
+
+	return t.storage.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("chunks"))
+		metaData := bucket.Get([]byte(key))
+		if metaData == nil {
+			return fmt.Errorf("chunk not found")
+		}
+		var metadata ChunkMetadata
+		if err := json.Unmarshal(metaData, &metadata); err != nil {
+			return err
+		}
+		metadata.Tiered = true
+
+		jsonData, err := json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(key), jsonData)
+	})
+}
+
+// restore writes the cold copy of the chunk key back to local disk
+func (t *tier) restore(ctx context.Context, key string) error {
+	data, err := t.cold.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a reader never sees a partial chunk
+	filePath := filepath.Join(t.storage.dataDir, "chunks", key)
+	tmp := filePath + ".restore"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filePath)
+}
+
+// sweep uploads chunks that never made it to the cold store and evicts the
+// local copies of tiered chunks not read since now - evictAfter
+func (t *tier) sweep(ctx context.Context, now time.Time) {
+	chunks := make(map[string]ChunkMetadata)
+	err := t.storage.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("chunks")).ForEach(func(k, v []byte) error {
+			var metadata ChunkMetadata
+			if err := json.Unmarshal(v, &metadata); err != nil {
+				return fmt.Errorf("chunk %s: %w", k, err)
+			}
+			chunks[string(k)] = metadata
+			return nil
+		})
+	})
+	if err != nil {
+		log.Printf("Tier: failed to list chunks: %v", err)
+		return
+	}
+
+	for key, metadata := range chunks {
+		if ctx.Err() != nil {
+			return
+		}
+		if !metadata.Tiered {
+			if err := t.upload(ctx, key); err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Printf("Tier: failed to upload chunk %s: %v", key, err)
+			}
+			continue
+		}
+		if t.evictAfter <= 0 {
+			continue
+		}
+
+		filePath := filepath.Join(t.storage.dataDir, "chunks", key)
+		info, err := os.Stat(filePath)
+		if err != nil {
+			continue // already evicted
+		}
+		lastUse := info.ModTime()
+		if metadata.LastAccess.After(lastUse) {
+			lastUse = metadata.LastAccess
+		}
+		if now.Sub(lastUse) >= t.evictAfter {
+			if err := os.Remove(filePath); err != nil {
+				log.Printf("Tier: failed to evict chunk %s: %v", key, err)
+			}
+		}
+	}
+}
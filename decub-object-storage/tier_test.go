@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryColdStore is an in-memory stand-in for the S3 cold tier
+type memoryColdStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	gets    int
+}
+
+func (m *memoryColdStore) Put(_ context.Context, key string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (m *memoryColdStore) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gets++
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("no such key %s", key)
+	}
+	return data, nil
+}
+
+func TestTierRestoresEvictedChunk(t *testing.T) {
+	storage, err := NewObjectStorage(t.TempDir(), make([]byte, 32))
+	if err != nil {
+		t.Fatalf("failed to create object storage: %v", err)
+	}
+	defer storage.Close()
+
+	cold := &memoryColdStore{objects: make(map[string][]byte)}
+	storage.tier = newTier(storage, cold)
+	storage.tier.sweepInterval = 0
+	stop := storage.tier.start()
+	defer stop()
+
+	data := []byte("cold snapshot chunk")
+	cid, err := storage.storeChunk(data, true)
+	if err != nil {
+		t.Fatalf("failed to store chunk: %v", err)
+	}
+	storage.tier.flush()
+
+	key, _ := chunkKey(cid)
+	if _, ok := cold.objects[key]; !ok {
+		t.Fatalf("chunk %s was not uploaded to the cold tier", cid)
+	}
+
+	localPath := filepath.Join(storage.dataDir, "chunks", key)
+	if err := os.Remove(localPath); err != nil {
+		t.Fatalf("failed to remove local copy: %v", err)
+	}
+
+	got, err := storage.retrieveChunk(cid)
+	if err != nil {
+		t.Fatalf("failed to retrieve evicted chunk: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("restored chunk = %q, want %q", got, data)
+	}
+	if _, err := os.Stat(localPath); err != nil {
+		t.Fatalf("local copy was not repopulated: %v", err)
+	}
+
+	// The next read is served locally
+	if _, err := storage.retrieveChunk(cid); err != nil {
+		t.Fatalf("failed to retrieve restored chunk: %v", err)
+	}
+	if cold.gets != 1 {
+		t.Fatalf("expected 1 cold tier read, got %d", cold.gets)
+	}
+}
+
+func TestTierSweepEvictsColdChunks(t *testing.T) {
+	storage, err := NewObjectStorage(t.TempDir(), make([]byte, 32))
+	if err != nil {
+		t.Fatalf("failed to create object storage: %v", err)
+	}
+	defer storage.Close()
+
+	cold := &memoryColdStore{objects: make(map[string][]byte)}
+	storage.tier = newTier(storage, cold)
+	storage.tier.evictAfter = time.Hour
+
+	// Without a running worker the upload is left to the sweep
+	cid, err := storage.storeChunk([]byte("evict me"), false)
+	if err != nil {
+		t.Fatalf("failed to store chunk: %v", err)
+	}
+	key, _ := chunkKey(cid)
+	localPath := filepath.Join(storage.dataDir, "chunks", key)
+
+	storage.tier.sweep(context.Background(), time.Now())
+	if _, ok := cold.objects[key]; !ok {
+		t.Fatalf("sweep did not upload chunk %s", cid)
+	}
+	if _, err := os.Stat(localPath); err != nil {
+		t.Fatalf("a recently stored chunk was evicted: %v", err)
+	}
+
+	storage.tier.sweep(context.Background(), time.Now().Add(2*time.Hour))
+	if _, err := os.Stat(localPath); !os.IsNotExist(err) {
+		t.Fatalf("expected the local copy to be evicted, got %v", err)
+	}
+
+	// The scrubber leaves evicted chunks alone
+	storage.scrubber.rate = 0
+	storage.scrubber.scrubOnce(context.Background())
+	if report := storage.scrubber.Report(); len(report.Corrupt) != 0 {
+		t.Fatalf("scrubber flagged an evicted chunk: %+v", report.Corrupt)
+	}
+
+	if ok, err := storage.verifyChunk(cid); err != nil || !ok {
+		t.Fatalf("evicted chunk failed verification: %v", err)
+	}
+}
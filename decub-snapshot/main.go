@@ -1,25 +1,48 @@
 package main
 
 import (
+	"context"
+	"crypto/ed25519"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
 )
 
-const chunkSize = 64 * 1024 * 1024 // 64MB
+const defaultChunkSize = 64 * 1024 * 1024 // 64MB
 
 type SnapshotManager struct {
 	etcdEndpoint string
 	objectStore  string
 	gclEndpoint  string
+
+	store ObjectStore
+	// metadataDir is where the simulated GCL keeps snapshot manifests
+	metadataDir string
+	// tempDir is where each operation creates its scratch directory, the
+	// system default if empty. keepTemp leaves scratch directories behind
+	// for debugging.
+	tempDir   string
+	keepTemp  bool
+	chunkSize int
+
+	// Progress, if set, is called as chunks are produced, uploaded and
+	// downloaded
+	Progress ProgressFunc
+
+	// signingKey signs the manifests of created snapshots; verifyKey is
+	// the public key restored manifests must be signed with
+	signingKey ed25519.PrivateKey
+	verifyKey  ed25519.PublicKey
 }
 
 func NewSnapshotManager(etcd, objStore, gcl string) *SnapshotManager {
@@ -27,38 +50,61 @@ func NewSnapshotManager(etcd, objStore, gcl string) *SnapshotManager {
 		etcdEndpoint: etcd,
 		objectStore:  objStore,
 		gclEndpoint:  gcl,
+		store:        &s3Store{endpoint: objStore},
+		metadataDir:  "/tmp",
+		chunkSize:    defaultChunkSize,
 	}
 }
 
-func (sm *SnapshotManager) CreateSnapshot(snapshotID, etcdPath, volumePath string) error {
-	log.Printf("Step 1: Creating snapshot %s", snapshotID)
-
-	// Create etcd snapshot
-	etcdSnapPath := fmt.Sprintf("/tmp/etcd-%s.snap", snapshotID)
-	cmd := fmt.Sprintf("etcdctl snapshot save %s --endpoints=%s", etcdSnapPath, sm.etcdEndpoint)
-	log.Printf("Running: %s", cmd)
-	// Execute command (simulated)
-	log.Printf("Etcd snapshot created at %s", etcdSnapPath)
-
-	// Create volume snapshot (simulated)
-	volumeSnapPath := fmt.Sprintf("/tmp/volume-%s.tar.gz", snapshotID)
-	cmd = fmt.Sprintf("tar -czf %s %s", volumeSnapPath, volumePath)
-	log.Printf("Running: %s", cmd)
-	log.Printf("Volume snapshot created at %s", volumeSnapPath)
-
-	// Combine snapshots
-	combinedPath := fmt.Sprintf("/tmp/combined-%s.snap", snapshotID)
-	cmd = fmt.Sprintf("cat %s %s > %s", etcdSnapPath, volumeSnapPath, combinedPath)
-	log.Printf("Running: %s", cmd)
-	log.Printf("Combined snapshot created at %s", combinedPath)
+// CreateSnapshot snapshots etcd and the volume at volumePath and uploads the
+// result. If ctx is cancelled the chunks uploaded so far are removed again.
+func (sm *SnapshotManager) CreateSnapshot(ctx context.Context, snapshotID, etcdPath, volumePath string) error {
+	return sm.withScratchDir("create", func(dir string) error {
+		log.Printf("Step 1: Creating snapshot %s", snapshotID)
+
+		// Create etcd snapshot
+		etcdSnapPath := filepath.Join(dir, "etcd.snap")
+		cmd := fmt.Sprintf("etcdctl snapshot save %s --endpoints=%s", etcdSnapPath, sm.etcdEndpoint)
+		log.Printf("Running: %s", cmd)
+		// Execute command (simulated)
+		log.Printf("Etcd snapshot created at %s", etcdSnapPath)
+
+		// Create volume snapshot (simulated)
+		volumeSnapPath := filepath.Join(dir, "volume.tar.gz")
+		cmd = fmt.Sprintf("tar -czf %s %s", volumeSnapPath, volumePath)
+		log.Printf("Running: %s", cmd)
+		log.Printf("Volume snapshot created at %s", volumeSnapPath)
+
+		// Combine snapshots
+		combinedPath := filepath.Join(dir, "combined.snap")
+		cmd = fmt.Sprintf("cat %s %s > %s", etcdSnapPath, volumeSnapPath, combinedPath)
+		log.Printf("Running: %s", cmd)
+		log.Printf("Combined snapshot created at %s", combinedPath)
+
+		return sm.processAndUpload(ctx, snapshotID, combinedPath, dir)
+	})
+}
 
-	return sm.processAndUpload(snapshotID, combinedPath)
+// withScratchDir runs fn with a fresh scratch directory for one operation
+// and removes it afterwards, whether fn succeeded or not
+func (sm *SnapshotManager) withScratchDir(operation string, fn func(dir string) error) error {
+	dir, err := os.MkdirTemp(sm.tempDir, "decub-snapshot-"+operation+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	if sm.keepTemp {
+		defer log.Printf("Keeping temporary files in %s", dir)
+	} else {
+		defer os.RemoveAll(dir)
+	}
+	return fn(dir)
 }
 
-func (sm *SnapshotManager) processAndUpload(snapshotID, combinedPath string) error {
-	log.Printf("Step 2: Chunking data into 64MB files")
+// processAndUpload chunks combinedPath into dir and uploads the chunks
+func (sm *SnapshotManager) processAndUpload(ctx context.Context, snapshotID, combinedPath, dir string) (err error) {
+	log.Printf("Step 2: Chunking data into %dMB files", sm.chunkSize/(1024*1024))
 
-	chunks, err := sm.chunkFile(combinedPath, snapshotID)
+	chunks, err := sm.chunkFile(ctx, combinedPath, dir)
 	if err != nil {
 		return err
 	}
@@ -67,41 +113,70 @@ func (sm *SnapshotManager) processAndUpload(snapshotID, combinedPath string) err
 
 	log.Printf("Step 3: Uploading to object store with sha256 verification")
 
-	hashes := make([]string, len(chunks))
+	total := sm.getFileSize(combinedPath)
+	var uploaded int64
+	var hashes []string
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			sm.removeUploaded(hashes)
+		}
+	}()
+
 	for i, chunkPath := range chunks {
-		hash, err := sm.uploadChunk(chunkPath, snapshotID, i)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hash, err := sm.uploadChunk(ctx, chunkPath)
 		if err != nil {
 			return err
 		}
-		hashes[i] = hash
+		hashes = append(hashes, hash)
 		log.Printf("Uploaded chunk %d with hash %s", i, hash)
+		uploaded += sm.getFileSize(chunkPath)
+		sm.reportProgress(uploaded, total, StageUploading)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
 	log.Printf("Step 4: Registering snapshot metadata via GCL tx")
 
-	metadata := map[string]interface{}{
-		"id":          snapshotID,
-		"timestamp":   time.Now().Unix(),
-		"chunk_count": len(chunks),
-		"hashes":      hashes,
-		"total_size":  sm.getFileSize(combinedPath),
+	if sm.signingKey == nil {
+		return fmt.Errorf("no signing key configured for snapshot manifests")
+	}
+	manifest, err := newManifest(snapshotID, hashes, total)
+	if err != nil {
+		return err
 	}
+	manifest.Sign(sm.signingKey)
+	log.Printf("Signed manifest with Merkle root %s", manifest.MerkleRoot)
 
-	return sm.registerMetadata(metadata)
+	if err := sm.registerManifest(manifest); err != nil {
+		return err
+	}
+	sm.reportProgress(total, total, StageComplete)
+	return nil
 }
 
-func (sm *SnapshotManager) chunkFile(filePath, snapshotID string) ([]string, error) {
+func (sm *SnapshotManager) chunkFile(ctx context.Context, filePath, dir string) ([]string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
 
+	total := sm.getFileSize(filePath)
+	var written int64
 	var chunks []string
-	buffer := make([]byte, chunkSize)
+	buffer := make([]byte, sm.chunkSize)
 	chunkIndex := 0
 
 	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		n, err := file.Read(buffer)
 		if err != nil && err != io.EOF {
 			return nil, err
@@ -110,7 +185,7 @@ func (sm *SnapshotManager) chunkFile(filePath, snapshotID string) ([]string, err
 			break
 		}
 
-		chunkPath := fmt.Sprintf("/tmp/%s-chunk-%d", snapshotID, chunkIndex)
+		chunkPath := filepath.Join(dir, fmt.Sprintf("chunk-%d", chunkIndex))
 		chunkFile, err := os.Create(chunkPath)
 		if err != nil {
 			return nil, err
@@ -124,12 +199,14 @@ func (sm *SnapshotManager) chunkFile(filePath, snapshotID string) ([]string, err
 
 		chunks = append(chunks, chunkPath)
 		chunkIndex++
+		written += int64(n)
+		sm.reportProgress(written, total, StageChunking)
 	}
 
 	return chunks, nil
 }
 
-func (sm *SnapshotManager) uploadChunk(chunkPath, snapshotID string, index int) (string, error) {
+func (sm *SnapshotManager) uploadChunk(ctx context.Context, chunkPath string) (string, error) {
 	file, err := os.Open(chunkPath)
 	if err != nil {
 		return "", err
@@ -143,24 +220,69 @@ func (sm *SnapshotManager) uploadChunk(chunkPath, snapshotID string, index int)
 	}
 	hash := hex.EncodeToString(hasher.Sum(nil))
 
-	// Simulate upload to object store
-	objectKey := fmt.Sprintf("snapshots/%s/chunk-%d", snapshotID, index)
-	cmd := fmt.Sprintf("aws s3 cp %s s3://%s/%s --endpoint-url=%s", chunkPath, sm.objectStore, objectKey, sm.objectStore)
-	log.Printf("Running: %s", cmd)
+	objectKey := chunkKey(hash)
+	if err := sm.store.Upload(ctx, chunkPath, objectKey); err != nil {
+		return "", err
+	}
 	log.Printf("Uploaded chunk to %s", objectKey)
 
 	return hash, nil
 }
 
-func (sm *SnapshotManager) registerMetadata(metadata map[string]interface{}) error {
+// manifestPath is where the simulated GCL keeps the manifest of snapshotID
+func (sm *SnapshotManager) manifestPath(snapshotID string) string {
+	return filepath.Join(sm.metadataDir, fmt.Sprintf("manifest-%s.json", snapshotID))
+}
+
+func (sm *SnapshotManager) registerManifest(manifest *Manifest) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
 	// Simulate GCL transaction
-	cmd := fmt.Sprintf("gcl-cli tx register-snapshot --metadata='%v' --endpoint=%s", metadata, sm.gclEndpoint)
+	cmd := fmt.Sprintf("gcl-cli tx register-snapshot --metadata='%s' --endpoint=%s", data, sm.gclEndpoint)
 	log.Printf("Running: %s", cmd)
-	log.Printf("Snapshot metadata registered with ID: %s", metadata["id"])
+	if err := os.WriteFile(sm.manifestPath(manifest.ID), data, 0644); err != nil {
+		return err
+	}
+	log.Printf("Snapshot metadata registered with ID: %s", manifest.ID)
 
 	return nil
 }
 
+// removeUploaded deletes the chunks with the given hashes from the object
+// store unless a registered snapshot references them. It runs after ctx was
+// cancelled, so it uses a context of its own.
+func (sm *SnapshotManager) removeUploaded(hashes []string) {
+	if len(hashes) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	referenced := make(map[string]bool)
+	manifests, err := sm.listManifests()
+	if err != nil {
+		log.Printf("Not removing uploaded chunks, failed to list snapshots: %v", err)
+		return
+	}
+	for _, m := range manifests {
+		for _, hash := range m.Hashes {
+			referenced[hash] = true
+		}
+	}
+
+	for _, hash := range uniqueHashes(hashes) {
+		if referenced[hash] {
+			continue
+		}
+		if err := sm.store.Delete(ctx, chunkKey(hash)); err != nil {
+			log.Printf("Failed to remove uploaded chunk %s: %v", hash, err)
+		}
+	}
+}
+
 func (sm *SnapshotManager) getFileSize(filePath string) int64 {
 	info, err := os.Stat(filePath)
 	if err != nil {
@@ -169,66 +291,108 @@ func (sm *SnapshotManager) getFileSize(filePath string) int64 {
 	return info.Size()
 }
 
-func (sm *SnapshotManager) VerifyAndRestore(snapshotID, restorePath string) error {
+// VerifyAndRestore checks the snapshot's signed manifest, downloads its
+// chunks and restores them to restorePath, stopping if ctx is cancelled
+func (sm *SnapshotManager) VerifyAndRestore(ctx context.Context, snapshotID, restorePath string) error {
 	log.Printf("Step 5: Verifying proof and restoring snapshot %s", snapshotID)
 
 	// Get metadata from GCL
-	metadata, err := sm.getMetadata(snapshotID)
+	manifest, err := sm.getManifest(snapshotID)
 	if err != nil {
 		return err
 	}
 
 	log.Printf("Retrieved metadata for snapshot %s", snapshotID)
 
-	// Download and verify chunks
-	var combinedData []byte
-	hashes := metadata["hashes"].([]string)
-	chunkCount := int(metadata["chunk_count"].(float64))
+	// Refuse manifests that were not signed by the trusted key or whose
+	// hashes were changed after signing
+	if sm.verifyKey == nil {
+		return fmt.Errorf("no verification key configured for snapshot manifests")
+	}
+	if err := manifest.Verify(sm.verifyKey); err != nil {
+		return err
+	}
+
+	return sm.withScratchDir("restore", func(dir string) error {
+		// Download and verify chunks
+		var combinedData []byte
+		downloaded := make([]string, manifest.ChunkCount)
+		total := manifest.TotalSize
+
+		for i := 0; i < manifest.ChunkCount; i++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			chunkData, err := sm.downloadAndVerifyChunk(ctx, dir, i, manifest.Hashes[i])
+			if err != nil {
+				return err
+			}
+			sum := sha256.Sum256(chunkData)
+			downloaded[i] = hex.EncodeToString(sum[:])
+			combinedData = append(combinedData, chunkData...)
+			log.Printf("Verified and downloaded chunk %d", i)
+			if int64(len(combinedData)) > total {
+				total = int64(len(combinedData))
+			}
+			sm.reportProgress(int64(len(combinedData)), total, StageDownloading)
+		}
 
-	for i := 0; i < chunkCount; i++ {
-		chunkData, err := sm.downloadAndVerifyChunk(snapshotID, i, hashes[i])
+		// The chunks as downloaded, in order, must reproduce the signed root
+		root, err := merkleRoot(downloaded)
 		if err != nil {
 			return err
 		}
-		combinedData = append(combinedData, chunkData...)
-		log.Printf("Verified and downloaded chunk %d", i)
-	}
+		if root != manifest.MerkleRoot {
+			return fmt.Errorf("snapshot %s: downloaded chunks do not match the signed Merkle root %s (got %s)", snapshotID, manifest.MerkleRoot, root)
+		}
 
-	// Restore combined snapshot
-	combinedPath := fmt.Sprintf("/tmp/restore-%s.snap", snapshotID)
-	err = os.WriteFile(combinedPath, combinedData, 0644)
-	if err != nil {
-		return err
-	}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
-	log.Printf("Combined snapshot restored to %s", combinedPath)
+		// Restore combined snapshot
+		combinedPath := filepath.Join(dir, "restore.snap")
+		err = os.WriteFile(combinedPath, combinedData, 0644)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Combined snapshot restored to %s", combinedPath)
 
-	// Extract etcd and volume data
-	return sm.extractSnapshots(combinedPath, restorePath)
+		// Extract etcd and volume data
+		if err := sm.extractSnapshots(combinedPath, restorePath); err != nil {
+			return err
+		}
+		sm.reportProgress(total, total, StageComplete)
+		return nil
+	})
 }
 
-func (sm *SnapshotManager) getMetadata(snapshotID string) (map[string]interface{}, error) {
+func (sm *SnapshotManager) getManifest(snapshotID string) (*Manifest, error) {
 	// Simulate getting metadata from GCL
 	cmd := fmt.Sprintf("gcl-cli query snapshot %s --endpoint=%s", snapshotID, sm.gclEndpoint)
 	log.Printf("Running: %s", cmd)
 
-	// Mock metadata
-	return map[string]interface{}{
-		"id":          snapshotID,
-		"timestamp":   time.Now().Unix(),
-		"chunk_count": 2,
-		"hashes":      []string{"mockhash1", "mockhash2"},
-		"total_size":  128 * 1024 * 1024,
-	}, nil
+	data, err := os.ReadFile(sm.manifestPath(snapshotID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata for snapshot %s: %w", snapshotID, err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid metadata for snapshot %s: %w", snapshotID, err)
+	}
+	if manifest.ID != snapshotID {
+		return nil, fmt.Errorf("metadata for snapshot %s is for %s", snapshotID, manifest.ID)
+	}
+	return &manifest, nil
 }
 
-func (sm *SnapshotManager) downloadAndVerifyChunk(snapshotID string, index int, expectedHash string) ([]byte, error) {
-	objectKey := fmt.Sprintf("snapshots/%s/chunk-%d", snapshotID, index)
-	localPath := fmt.Sprintf("/tmp/download-%s-%d", snapshotID, index)
-
-	// Simulate download
-	cmd := fmt.Sprintf("aws s3 cp s3://%s/%s %s --endpoint-url=%s", sm.objectStore, objectKey, localPath, sm.objectStore)
-	log.Printf("Running: %s", cmd)
+// downloadAndVerifyChunk downloads the chunk with expectedHash into dir
+func (sm *SnapshotManager) downloadAndVerifyChunk(ctx context.Context, dir string, index int, expectedHash string) ([]byte, error) {
+	localPath := filepath.Join(dir, fmt.Sprintf("download-%d", index))
+	if err := sm.store.Download(ctx, chunkKey(expectedHash), localPath); err != nil {
+		return nil, err
+	}
 
 	// Read file and verify hash
 	data, err := os.ReadFile(localPath)
@@ -267,8 +431,24 @@ func (sm *SnapshotManager) extractSnapshots(combinedPath, restorePath string) er
 	return nil
 }
 
+// commandContext is cancelled on SIGINT or SIGTERM, or once timeout elapses
+// if it is set
+func commandContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	if timeout <= 0 {
+		return ctx, stop
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
 func main() {
-	var etcdEndpoint, objectStore, gclEndpoint string
+	var etcdEndpoint, objectStore, gclEndpoint, signingKeyPath, verifyKeyPath string
+	var timeout time.Duration
+	var keepTemp, showProgress bool
 
 	rootCmd := &cobra.Command{
 		Use:   "decub-snapshot",
@@ -281,7 +461,18 @@ func main() {
 		Args:  cobra.ExactArgs(3),
 		Run: func(cmd *cobra.Command, args []string) {
 			sm := NewSnapshotManager(etcdEndpoint, objectStore, gclEndpoint)
-			err := sm.CreateSnapshot(args[0], args[1], args[2])
+			sm.keepTemp = keepTemp
+			if showProgress {
+				sm.Progress = progressBar(os.Stderr)
+			}
+			key, err := loadSigningKey(signingKeyPath)
+			if err != nil {
+				log.Fatal(err)
+			}
+			sm.signingKey = key
+			ctx, cancel := commandContext(timeout)
+			defer cancel()
+			err = sm.CreateSnapshot(ctx, args[0], args[1], args[2])
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -295,7 +486,18 @@ func main() {
 		Args:  cobra.ExactArgs(2),
 		Run: func(cmd *cobra.Command, args []string) {
 			sm := NewSnapshotManager(etcdEndpoint, objectStore, gclEndpoint)
-			err := sm.VerifyAndRestore(args[0], args[1])
+			sm.keepTemp = keepTemp
+			if showProgress {
+				sm.Progress = progressBar(os.Stderr)
+			}
+			key, err := loadVerifyKey(verifyKeyPath)
+			if err != nil {
+				log.Fatal(err)
+			}
+			sm.verifyKey = key
+			ctx, cancel := commandContext(timeout)
+			defer cancel()
+			err = sm.VerifyAndRestore(ctx, args[0], args[1])
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -303,11 +505,49 @@ func main() {
 		},
 	}
 
+	var keep int
+	var olderThan string
+
+	pruneCmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete old snapshots and the chunks only they reference",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			opts := PruneOptions{Keep: keep}
+			if olderThan != "" {
+				age, err := parseAge(olderThan)
+				if err != nil {
+					log.Fatal(err)
+				}
+				opts.OlderThan = age
+			}
+
+			sm := NewSnapshotManager(etcdEndpoint, objectStore, gclEndpoint)
+			sm.keepTemp = keepTemp
+			ctx, cancel := commandContext(timeout)
+			defer cancel()
+			pruned, err := sm.Prune(ctx, opts)
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("Pruned %d snapshots", len(pruned))
+		},
+	}
+
 	rootCmd.PersistentFlags().StringVar(&etcdEndpoint, "etcd", "http://localhost:2379", "Etcd endpoint")
 	rootCmd.PersistentFlags().StringVar(&objectStore, "object-store", "http://localhost:9000", "Object store endpoint")
 	rootCmd.PersistentFlags().StringVar(&gclEndpoint, "gcl", "http://localhost:8080", "GCL endpoint")
+	rootCmd.PersistentFlags().BoolVar(&keepTemp, "keep-temp", false, "Keep each operation's scratch directory for debugging")
+	rootCmd.PersistentFlags().BoolVar(&showProgress, "progress", true, "Show a progress bar on stderr")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "Abort the operation after this long, 0 for no limit")
+
+	createCmd.Flags().StringVar(&signingKeyPath, "signing-key", "snapshot.key", "Ed25519 key that signs snapshot manifests, generated if missing")
+	restoreCmd.Flags().StringVar(&verifyKeyPath, "verify-key", "snapshot.key.pub", "Public key snapshot manifests must be signed with")
+
+	pruneCmd.Flags().IntVar(&keep, "keep", 10, "Number of newest snapshots to keep, -1 for no limit")
+	pruneCmd.Flags().StringVar(&olderThan, "older-than", "", "Only prune snapshots older than this age, e.g. 30d or 12h")
 
-	rootCmd.AddCommand(createCmd, restoreCmd)
+	rootCmd.AddCommand(createCmd, restoreCmd, pruneCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatal(err)
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memStore is an in-memory ObjectStore that remembers deleted keys
+type memStore struct {
+	objects map[string][]byte
+	deleted map[string]bool
+}
+
+func newMemStore() *memStore {
+	return &memStore{objects: make(map[string][]byte), deleted: make(map[string]bool)}
+}
+
+func (s *memStore) Upload(ctx context.Context, localPath, key string) error {
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+	s.objects[key] = data
+	return nil
+}
+
+func (s *memStore) Download(ctx context.Context, key, localPath string) error {
+	data, ok := s.objects[key]
+	if !ok {
+		return fmt.Errorf("object %s not found", key)
+	}
+	return os.WriteFile(localPath, data, 0644)
+}
+
+func (s *memStore) Delete(ctx context.Context, key string) error {
+	delete(s.objects, key)
+	s.deleted[key] = true
+	return nil
+}
+
+func chunkHash(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// registerTestSnapshot uploads chunks to the manager's memStore and
+// registers a manifest for them signed with key
+func registerTestSnapshot(t *testing.T, sm *SnapshotManager, snapshotID string, key ed25519.PrivateKey, chunks ...string) *Manifest {
+	t.Helper()
+
+	store := sm.store.(*memStore)
+	hashes := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		hashes[i] = chunkHash(chunk)
+		store.objects[chunkKey(hashes[i])] = []byte(chunk)
+	}
+
+	manifest, err := newManifest(snapshotID, hashes, 0)
+	if err != nil {
+		t.Fatalf("failed to build manifest: %v", err)
+	}
+	manifest.Sign(key)
+	if err := sm.registerManifest(manifest); err != nil {
+		t.Fatalf("failed to register manifest: %v", err)
+	}
+	return manifest
+}
+
+func newTestManager(t *testing.T) (*SnapshotManager, ed25519.PrivateKey) {
+	t.Helper()
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	sm := NewSnapshotManager("", "", "")
+	sm.store = newMemStore()
+	sm.metadataDir = t.TempDir()
+	sm.tempDir = t.TempDir()
+	sm.signingKey, sm.verifyKey = key, pub
+	return sm, key
+}
+
+// assertScratchRemoved fails if an operation left files in the manager's temp dir
+func assertScratchRemoved(t *testing.T, sm *SnapshotManager) {
+	t.Helper()
+	entries, err := os.ReadDir(sm.tempDir)
+	if err != nil {
+		t.Fatalf("failed to read temp dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected scratch directories to be removed, found %s", entries[0].Name())
+	}
+}
+
+func TestRestoreVerifiesSignedManifest(t *testing.T) {
+	sm, key := newTestManager(t)
+	registerTestSnapshot(t, sm, "snap", key, "chunk-0", "chunk-1", "chunk-2")
+
+	if err := sm.VerifyAndRestore(context.Background(), "snap", t.TempDir()); err != nil {
+		t.Fatalf("restore of an untampered snapshot failed: %v", err)
+	}
+	assertScratchRemoved(t, sm)
+}
+
+func TestRestoreRejectsTamperedChunkHash(t *testing.T) {
+	sm, key := newTestManager(t)
+	manifest := registerTestSnapshot(t, sm, "tampered", key, "chunk-0", "chunk-1", "chunk-2")
+
+	// Point the second chunk at different data, keeping the signature
+	manifest.Hashes[1] = chunkHash("evil")
+	sm.store.(*memStore).objects[chunkKey(manifest.Hashes[1])] = []byte("evil")
+	data, _ := json.Marshal(manifest)
+	if err := os.WriteFile(sm.manifestPath("tampered"), data, 0644); err != nil {
+		t.Fatalf("failed to tamper with manifest: %v", err)
+	}
+
+	err := sm.VerifyAndRestore(context.Background(), "tampered", t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "Merkle root") {
+		t.Fatalf("expected restore to refuse the tampered manifest, got %v", err)
+	}
+	assertScratchRemoved(t, sm)
+}
+
+func TestRestoreRejectsUntrustedSigner(t *testing.T) {
+	sm, _ := newTestManager(t)
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	registerTestSnapshot(t, sm, "untrusted", other, "chunk-0")
+
+	err := sm.VerifyAndRestore(context.Background(), "untrusted", t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Fatalf("expected restore to refuse a manifest signed by another key, got %v", err)
+	}
+}
+
+func TestMerkleRootDependsOnOrder(t *testing.T) {
+	a, _ := merkleRoot([]string{"aa", "bb", "cc"})
+	b, _ := merkleRoot([]string{"bb", "aa", "cc"})
+	if a == b {
+		t.Fatal("reordering chunk hashes did not change the Merkle root")
+	}
+	if _, err := merkleRoot(nil); err == nil {
+		t.Fatal("expected an error for a snapshot without chunks")
+	}
+}
+
+// createFrom uploads data as snapshotID the way CreateSnapshot does once
+// the etcd and volume snapshots are combined
+func createFrom(ctx context.Context, sm *SnapshotManager, snapshotID, data string) error {
+	return sm.withScratchDir("create", func(dir string) error {
+		combined := filepath.Join(dir, "combined.snap")
+		if err := os.WriteFile(combined, []byte(data), 0644); err != nil {
+			return err
+		}
+		return sm.processAndUpload(ctx, snapshotID, combined, dir)
+	})
+}
+
+func TestCreateRemovesScratchDir(t *testing.T) {
+	sm, _ := newTestManager(t)
+	sm.chunkSize = 4
+
+	if err := createFrom(context.Background(), sm, "ok", "chunkdatathatspans"); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	assertScratchRemoved(t, sm)
+	if err := sm.VerifyAndRestore(context.Background(), "ok", t.TempDir()); err != nil {
+		t.Fatalf("restore of the created snapshot failed: %v", err)
+	}
+	assertScratchRemoved(t, sm)
+
+	// The simulated etcd and volume steps produce nothing, so this fails
+	// after the scratch directory was created
+	if err := sm.CreateSnapshot(context.Background(), "failed", "/var/lib/etcd", "/var/lib/volumes"); err == nil {
+		t.Fatal("expected create without a combined snapshot to fail")
+	}
+	assertScratchRemoved(t, sm)
+
+	sm.keepTemp = true
+	if err := createFrom(context.Background(), sm, "kept", "data"); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if entries, _ := os.ReadDir(sm.tempDir); len(entries) != 1 {
+		t.Fatalf("expected --keep-temp to keep the scratch directory, found %d entries", len(entries))
+	}
+}
+
+// slowStore uploads the first chunk, then cancels the operation and blocks
+// the next upload until the context is done
+type slowStore struct {
+	*memStore
+	uploads int
+	cancel  context.CancelFunc
+}
+
+func (s *slowStore) Upload(ctx context.Context, localPath, key string) error {
+	s.uploads++
+	if s.uploads > 1 {
+		s.cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return s.memStore.Upload(ctx, localPath, key)
+}
+
+func TestCreateCancelledMidUploadCleansUp(t *testing.T) {
+	sm, _ := newTestManager(t)
+	sm.chunkSize = 4
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := &slowStore{memStore: newMemStore(), cancel: cancel}
+	sm.store = store
+
+	// "base" is already part of a registered snapshot and must survive
+	registerAt(t, sm, "existing", time.Hour, "base")
+
+	err := createFrom(ctx, sm, "cancelled", "baseblobcafe")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	assertScratchRemoved(t, sm)
+	if _, err := sm.getManifest("cancelled"); err == nil {
+		t.Fatal("cancelled snapshot was registered")
+	}
+	if store.deleted[chunkKey(chunkHash("base"))] {
+		t.Fatal("removed a chunk referenced by another snapshot")
+	}
+
+	// Cancel after an unshared chunk was uploaded: it is removed again
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	store.uploads, store.cancel = 0, cancel
+	if err := createFrom(ctx, sm, "cancelled", "uniqblob"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if !store.deleted[chunkKey(chunkHash("uniq"))] {
+		t.Fatal("uploaded chunk was not removed after cancellation")
+	}
+}
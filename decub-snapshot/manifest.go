@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Manifest is the snapshot metadata registered with the GCL. The signature
+// covers the Merkle root of the ordered chunk hashes, so neither a hash nor
+// the chunk order can be changed without invalidating it.
+type Manifest struct {
+	ID         string   `json:"id"`
+	Timestamp  int64    `json:"timestamp"`
+	ChunkCount int      `json:"chunk_count"`
+	Hashes     []string `json:"hashes"`
+	TotalSize  int64    `json:"total_size"`
+	MerkleRoot string   `json:"merkle_root"`
+	Signature  string   `json:"signature"`
+}
+
+// newManifest builds a manifest for the chunk hashes of snapshotID, in upload order
+func newManifest(snapshotID string, hashes []string, totalSize int64) (*Manifest, error) {
+	root, err := merkleRoot(hashes)
+	if err != nil {
+		return nil, err
+	}
+	return &Manifest{
+		ID:         snapshotID,
+		Timestamp:  time.Now().Unix(),
+		ChunkCount: len(hashes),
+		Hashes:     hashes,
+		TotalSize:  totalSize,
+		MerkleRoot: root,
+	}, nil
+}
+
+// signedPayload is the canonical encoding of the fields the signature covers
+func (m *Manifest) signedPayload() []byte {
+	return []byte(fmt.Sprintf("decub-snapshot\n%s\n%d\n%d\n%d\n%s", m.ID, m.Timestamp, m.ChunkCount, m.TotalSize, m.MerkleRoot))
+}
+
+// Sign signs the manifest with key
+func (m *Manifest) Sign(key ed25519.PrivateKey) {
+	m.Signature = hex.EncodeToString(ed25519.Sign(key, m.signedPayload()))
+}
+
+// Verify checks that the manifest was signed by key and that its chunk
+// hashes still produce the signed Merkle root
+func (m *Manifest) Verify(key ed25519.PublicKey) error {
+	sig, err := hex.DecodeString(m.Signature)
+	if err != nil || !ed25519.Verify(key, m.signedPayload(), sig) {
+		return fmt.Errorf("snapshot %s: manifest signature is invalid", m.ID)
+	}
+	if len(m.Hashes) != m.ChunkCount {
+		return fmt.Errorf("snapshot %s: manifest lists %d hashes for %d chunks", m.ID, len(m.Hashes), m.ChunkCount)
+	}
+	root, err := merkleRoot(m.Hashes)
+	if err != nil {
+		return fmt.Errorf("snapshot %s: %w", m.ID, err)
+	}
+	if root != m.MerkleRoot {
+		return fmt.Errorf("snapshot %s: chunk hashes do not match the signed Merkle root %s (got %s)", m.ID, m.MerkleRoot, root)
+	}
+	return nil
+}
+
+// merkleRoot computes the Merkle root over hashes in order, duplicating the
+// last hash of a level with an odd number of nodes
+func merkleRoot(hashes []string) (string, error) {
+	if len(hashes) == 0 {
+		return "", fmt.Errorf("cannot build Merkle root without chunks")
+	}
+
+	level := make([]string, len(hashes))
+	copy(level, hashes)
+	for len(level) > 1 {
+		var next []string
+		for i := 0; i < len(level); i += 2 {
+			left, right := level[i], level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			sum := sha256.Sum256([]byte(left + right))
+			next = append(next, hex.EncodeToString(sum[:]))
+		}
+		level = next
+	}
+	return level[0], nil
+}
+
+// loadSigningKey returns the private key stored at path, generating it on
+// first use. The hex public key is written next to it as path+".pub" so it
+// can be handed to the nodes that restore snapshots.
+func loadSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid signing key %s", path)
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read signing key %s: %w", path, err)
+	}
+
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key.Seed())), 0600); err != nil {
+		return nil, fmt.Errorf("failed to save signing key %s: %w", path, err)
+	}
+	if err := os.WriteFile(path+".pub", []byte(hex.EncodeToString(pub)), 0644); err != nil {
+		return nil, fmt.Errorf("failed to save public key %s.pub: %w", path, err)
+	}
+	return key, nil
+}
+
+// loadVerifyKey reads a hex public key written by loadSigningKey
+func loadVerifyKey(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read verification key %s: %w", path, err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid verification key %s", path)
+	}
+	return ed25519.PublicKey(key), nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Progress stages reported by SnapshotManager
+const (
+	StageChunking    = "chunking"
+	StageUploading   = "uploading"
+	StageDownloading = "downloading"
+	StageComplete    = "complete"
+)
+
+// ProgressFunc is called as a snapshot operation advances, with the bytes
+// processed so far in the current stage out of total
+type ProgressFunc func(current, total int64, stage string)
+
+// reportProgress calls the manager's ProgressFunc, if one is set
+func (sm *SnapshotManager) reportProgress(current, total int64, stage string) {
+	if sm.Progress != nil {
+		sm.Progress(current, total, stage)
+	}
+}
+
+const progressBarWidth = 30
+
+// progressBar returns a ProgressFunc that redraws a one-line bar on w
+func progressBar(w io.Writer) ProgressFunc {
+	return func(current, total int64, stage string) {
+		if stage == StageComplete {
+			fmt.Fprintf(w, "\r%-11s [%s] 100%% %s\n", stage, strings.Repeat("=", progressBarWidth), formatBytes(total))
+			return
+		}
+
+		percent := int64(100)
+		if total > 0 {
+			percent = current * 100 / total
+		}
+		filled := int(percent) * progressBarWidth / 100
+		bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+		fmt.Fprintf(w, "\r%-11s [%s] %3d%% %s/%s", stage, bar, percent, formatBytes(current), formatBytes(total))
+	}
+}
+
+// formatBytes renders n with a binary unit suffix
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
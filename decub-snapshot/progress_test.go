@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+type progressEvent struct {
+	current, total int64
+	stage          string
+}
+
+func TestCreateReportsProgress(t *testing.T) {
+	sm, _ := newTestManager(t)
+	sm.chunkSize = 4
+
+	var events []progressEvent
+	sm.Progress = func(current, total int64, stage string) {
+		events = append(events, progressEvent{current, total, stage})
+	}
+
+	data := "progress-report-data"
+	if err := createFrom(context.Background(), sm, "progress", data); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	if len(events) == 0 {
+		t.Fatal("no progress reported")
+	}
+	last := events[len(events)-1]
+	if last.stage != StageComplete || last.current != int64(len(data)) || last.total != int64(len(data)) {
+		t.Fatalf("expected a final complete event for %d bytes, got %+v", len(data), last)
+	}
+
+	// Within each stage the byte count only grows and never passes the total
+	seen := make(map[string]int64)
+	for _, ev := range events {
+		if ev.current <= seen[ev.stage] {
+			t.Fatalf("progress for %s went from %d to %d", ev.stage, seen[ev.stage], ev.current)
+		}
+		if ev.current > ev.total {
+			t.Fatalf("progress %d exceeds total %d", ev.current, ev.total)
+		}
+		seen[ev.stage] = ev.current
+	}
+	for _, stage := range []string{StageChunking, StageUploading} {
+		if seen[stage] != int64(len(data)) {
+			t.Errorf("%s stopped at %d of %d bytes", stage, seen[stage], len(data))
+		}
+	}
+
+	events = nil
+	if err := sm.VerifyAndRestore(context.Background(), "progress", t.TempDir()); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if last := events[len(events)-1]; last.stage != StageComplete {
+		t.Fatalf("restore ended with stage %q", last.stage)
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	var buf bytes.Buffer
+	bar := progressBar(&buf)
+	bar(512, 2048, StageUploading)
+	if !strings.Contains(buf.String(), " 25% 512B/2.0KiB") {
+		t.Fatalf("unexpected bar %q", buf.String())
+	}
+	bar(2048, 2048, StageComplete)
+	if !strings.HasSuffix(buf.String(), "\n") {
+		t.Fatal("complete did not end the line")
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PruneOptions selects the snapshots removed by Prune. The newest Keep
+// snapshots are always retained; a negative Keep disables the count limit.
+// If OlderThan is set, only snapshots older than it are removed.
+type PruneOptions struct {
+	Keep      int
+	OlderThan time.Duration
+}
+
+// Prune deletes the snapshots selected by opts and returns their IDs. A
+// chunk is removed from the object store only when no retained snapshot
+// references it, so chunks shared with newer snapshots survive.
+func (sm *SnapshotManager) Prune(ctx context.Context, opts PruneOptions) ([]string, error) {
+	if opts.Keep < 0 && opts.OlderThan <= 0 {
+		return nil, fmt.Errorf("prune needs a retention count or a minimum age")
+	}
+
+	manifests, err := sm.listManifests()
+	if err != nil {
+		return nil, err
+	}
+
+	// Newest first
+	sort.Slice(manifests, func(i, j int) bool {
+		if manifests[i].Timestamp != manifests[j].Timestamp {
+			return manifests[i].Timestamp > manifests[j].Timestamp
+		}
+		return manifests[i].ID > manifests[j].ID
+	})
+
+	refs := make(map[string]int)
+	for _, m := range manifests {
+		for _, hash := range uniqueHashes(m.Hashes) {
+			refs[hash]++
+		}
+	}
+
+	cutoff := time.Now().Add(-opts.OlderThan).Unix()
+	var pruned []string
+	for i, m := range manifests {
+		if opts.Keep >= 0 && i < opts.Keep {
+			continue
+		}
+		if opts.OlderThan > 0 && m.Timestamp > cutoff {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return pruned, err
+		}
+
+		// Drop the metadata first so a failure part-way never leaves a
+		// registered snapshot whose chunks are gone
+		if err := sm.deleteManifest(m.ID); err != nil {
+			return pruned, err
+		}
+		for _, hash := range uniqueHashes(m.Hashes) {
+			refs[hash]--
+			if refs[hash] > 0 {
+				continue
+			}
+			if err := sm.store.Delete(ctx, chunkKey(hash)); err != nil {
+				return pruned, fmt.Errorf("failed to delete chunk %s of snapshot %s: %w", hash, m.ID, err)
+			}
+		}
+		log.Printf("Pruned snapshot %s", m.ID)
+		pruned = append(pruned, m.ID)
+	}
+
+	return pruned, nil
+}
+
+// listManifests returns every snapshot registered with the GCL
+func (sm *SnapshotManager) listManifests() ([]*Manifest, error) {
+	// Simulate listing snapshots from GCL
+	cmd := fmt.Sprintf("gcl-cli query snapshots --endpoint=%s", sm.gclEndpoint)
+	log.Printf("Running: %s", cmd)
+
+	paths, err := filepath.Glob(filepath.Join(sm.metadataDir, "manifest-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	manifests := make([]*Manifest, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var manifest Manifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("invalid snapshot metadata %s: %w", path, err)
+		}
+		manifests = append(manifests, &manifest)
+	}
+	return manifests, nil
+}
+
+func (sm *SnapshotManager) deleteManifest(snapshotID string) error {
+	// Simulate GCL transaction
+	cmd := fmt.Sprintf("gcl-cli tx deregister-snapshot %s --endpoint=%s", snapshotID, sm.gclEndpoint)
+	log.Printf("Running: %s", cmd)
+	return os.Remove(sm.manifestPath(snapshotID))
+}
+
+// uniqueHashes drops repeated hashes so a chunk that appears twice in one
+// snapshot counts as a single reference
+func uniqueHashes(hashes []string) []string {
+	seen := make(map[string]bool, len(hashes))
+	var out []string
+	for _, hash := range hashes {
+		if !seen[hash] {
+			seen[hash] = true
+			out = append(out, hash)
+		}
+	}
+	return out
+}
+
+// parseAge parses a duration that may also be given in days, like "30d"
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q: %w", s, err)
+	}
+	return d, nil
+}
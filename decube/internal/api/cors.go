@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSConfig configures cross-origin access for browser clients
+type CORSConfig struct {
+	// AllowedOrigins lists the origins allowed to call the API. An empty list
+	// denies every cross-origin request; "*" allows any origin.
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	// MaxAge is how long, in seconds, browsers may cache a preflight result
+	MaxAge int
+}
+
+// DefaultCORSConfig returns a config allowing the given origins to use the API's methods
+func DefaultCORSConfig(origins []string) CORSConfig {
+	return CORSConfig{
+		AllowedOrigins: origins,
+		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Content-Type", "Authorization", "Idempotency-Key"},
+		MaxAge:         600,
+	}
+}
+
+// corsPolicy applies a CORSConfig to requests
+type corsPolicy struct {
+	cfg      CORSConfig
+	wildcard bool
+	origins  map[string]bool
+}
+
+func newCORSPolicy(cfg CORSConfig) *corsPolicy {
+	p := &corsPolicy{cfg: cfg, origins: make(map[string]bool)}
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			p.wildcard = true
+		}
+		p.origins[strings.TrimSuffix(origin, "/")] = true
+	}
+	return p
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for origin, or
+// "" if the origin is not allowed
+func (p *corsPolicy) allowOrigin(origin string) string {
+	switch {
+	case p.wildcard:
+		return "*"
+	case p.origins[origin]:
+		return origin
+	default:
+		return ""
+	}
+}
+
+// middleware sets CORS headers for allowed origins and answers preflight
+// requests; preflights from other origins are rejected with 403
+func (p *corsPolicy) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		allowed := p.allowOrigin(origin)
+		if allowed != "" {
+			w.Header().Set("Access-Control-Allow-Origin", allowed)
+		}
+
+		if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Preflight
+		if allowed == "" {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(p.cfg.AllowedMethods, ", "))
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(p.cfg.AllowedHeaders, ", "))
+		if p.cfg.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(p.cfg.MaxAge))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// EnableCORS installs the CORS middleware
+func (rs *RESTServer) EnableCORS(cfg CORSConfig) {
+	// Routes are registered per method, so preflights need a route of their
+	// own for the router to run middleware on them
+	rs.router.Methods(http.MethodOptions).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	rs.router.Use(newCORSPolicy(cfg).middleware)
+}
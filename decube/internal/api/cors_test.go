@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func preflight(rs *RESTServer, path, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodOptions, path, nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rec := httptest.NewRecorder()
+	rs.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORSPreflight(t *testing.T) {
+	rs := NewRESTServer(nil, "127.0.0.1:0")
+	rs.EnableCORS(DefaultCORSConfig([]string{"https://app.example.com"}))
+
+	rec := preflight(rs, "/api/v1/pods", "https://evil.example.com")
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("disallowed origin: expected 403, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("disallowed origin got Access-Control-Allow-Origin %q", got)
+	}
+
+	rec = preflight(rs, "/api/v1/pods", "https://app.example.com")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("allowed origin: expected 204, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Fatalf("allowed origin: Access-Control-Allow-Origin = %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); got == "" {
+		t.Fatalf("allowed origin: missing Access-Control-Allow-Methods")
+	}
+}
+
+func TestCORSOriginLists(t *testing.T) {
+	empty := NewRESTServer(nil, "127.0.0.1:0")
+	empty.EnableCORS(DefaultCORSConfig(nil))
+	if rec := preflight(empty, "/api/v1/pods", "https://app.example.com"); rec.Code != http.StatusForbidden {
+		t.Fatalf("empty origin list: expected 403, got %d", rec.Code)
+	}
+
+	wildcard := NewRESTServer(nil, "127.0.0.1:0")
+	wildcard.EnableCORS(DefaultCORSConfig([]string{"*"}))
+	rec := preflight(wildcard, "/api/v1/pods", "https://app.example.com")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); rec.Code != http.StatusNoContent || got != "*" {
+		t.Fatalf("wildcard: got %d with Access-Control-Allow-Origin %q", rec.Code, got)
+	}
+}
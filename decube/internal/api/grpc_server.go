@@ -2,29 +2,44 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 	"github.com/decube/decube/api/proto"
 	"github.com/decube/decube/internal/etcd"
+	"github.com/decube/decube/pkg/config"
 )
 
 // GRPCServer provides gRPC API endpoints for the DeCube control-plane
 type GRPCServer struct {
 	proto.UnimplementedDeCubeServiceServer
-	etcdManager *etcd.EtcdManager
-	server      *grpc.Server
+	store  etcd.Store
+	server *grpc.Server
 }
 
-// NewGRPCServer creates a new gRPC server
-func NewGRPCServer(etcdManager *etcd.EtcdManager) *GRPCServer {
-	s := grpc.NewServer()
+// NewGRPCServer creates a new gRPC server. Every call is logged and recovered
+// from panics; when cfg.AuthToken is set, calls must also carry it as a
+// bearer token in the "authorization" metadata. With cfg.LeaderOnlyWrites,
+// followers refuse writes and name the leader instead.
+func NewGRPCServer(store etcd.Store, cfg config.GRPCConfig) *GRPCServer {
+	unary := []grpc.UnaryServerInterceptor{unaryInterceptor(cfg.AuthToken)}
+	if cfg.LeaderOnlyWrites {
+		unary = append(unary, leaderUnaryInterceptor(store))
+	}
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.StreamInterceptor(streamInterceptor(cfg.AuthToken)),
+	)
 	srv := &GRPCServer{
-		etcdManager: etcdManager,
-		server:      s,
+		store:  store,
+		server: s,
 	}
 
 	proto.RegisterDeCubeServiceServer(s, srv)
@@ -54,25 +69,15 @@ func (s *GRPCServer) Stop() error {
 
 // Pod operations
 func (s *GRPCServer) CreatePod(ctx context.Context, req *proto.CreatePodRequest) (*proto.CreatePodResponse, error) {
-	// Convert to internal format and store
 	pod := req.Pod
-	key := fmt.Sprintf("/pods/%s/%s", pod.Namespace, pod.Name)
-
-	// Store pod data
-	podData := map[string]interface{}{
-		"name":       pod.Name,
-		"namespace":  pod.Namespace,
-		"status":     pod.Status,
-		"node_name":  pod.NodeName,
-		"created_at": pod.CreatedAt,
-		"updated_at": pod.UpdatedAt,
-		"labels":     pod.Labels,
-		"annotations": pod.Annotations,
-	}
-
-	// Serialize and store
-	data, _ := json.Marshal(podData)
-	err := s.etcdManager.Put(ctx, key, string(data))
+	pod.ResourceVersion = 0
+	data, err := encodeRecord(podFromProto(pod))
+	if err == nil {
+		pod.ResourceVersion, err = s.store.PutIfModRevision(ctx, podKey(pod.Namespace, pod.Name), data, 0, 0)
+	}
+	if errors.Is(err, etcd.ErrRevisionConflict) {
+		return nil, status.Error(codes.AlreadyExists, "pod already exists")
+	}
 	if err != nil {
 		return &proto.CreatePodResponse{
 			Pod:     nil,
@@ -89,9 +94,7 @@ func (s *GRPCServer) CreatePod(ctx context.Context, req *proto.CreatePodRequest)
 }
 
 func (s *GRPCServer) GetPod(ctx context.Context, req *proto.GetPodRequest) (*proto.GetPodResponse, error) {
-	key := fmt.Sprintf("/pods/%s/%s", req.Namespace, req.Name)
-
-	data, err := s.etcdManager.Get(ctx, key)
+	kv, err := s.store.GetKeyValue(ctx, podKey(req.Namespace, req.Name))
 	if err != nil {
 		return &proto.GetPodResponse{
 			Pod:   nil,
@@ -100,31 +103,36 @@ func (s *GRPCServer) GetPod(ctx context.Context, req *proto.GetPodRequest) (*pro
 		}, nil
 	}
 
-	// Parse pod data
-	var podData map[string]interface{}
-	json.Unmarshal([]byte(data), &podData)
-
-	pod := &proto.Pod{
-		Name:        getString(podData, "name"),
-		Namespace:   getString(podData, "namespace"),
-		Status:      getString(podData, "status"),
-		NodeName:    getString(podData, "node_name"),
-		CreatedAt:   getString(podData, "created_at"),
-		UpdatedAt:   getString(podData, "updated_at"),
-		Labels:      getStringMap(podData, "labels"),
-		Annotations: getStringMap(podData, "annotations"),
+	var pod podRecord
+	if err := decodeRecord(kv.Value, &pod); err != nil {
+		return &proto.GetPodResponse{
+			Pod:   nil,
+			Found: false,
+			Error: err.Error(),
+		}, nil
 	}
+	pod.ResourceVersion = kv.ModRevision
 
 	return &proto.GetPodResponse{
-		Pod:   pod,
+		Pod:   pod.toProto(),
 		Found: true,
 		Error: "",
 	}, nil
 }
 
+// ListPods lists the pods in a namespace whose labels match both the
+// label_selector and every entry of the labels map
 func (s *GRPCServer) ListPods(ctx context.Context, req *proto.ListPodsRequest) (*proto.ListPodsResponse, error) {
+	selector, err := parseLabelSelector(req.LabelSelector)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	for k, v := range req.Labels {
+		selector = append(selector, labelRequirement{Key: k, Op: opEquals, Values: []string{v}})
+	}
+
 	prefix := fmt.Sprintf("/pods/%s/", req.Namespace)
-	podsMap, err := s.etcdManager.GetWithPrefix(ctx, prefix)
+	podsMap, err := s.store.GetKeyValuesWithPrefix(ctx, prefix)
 	if err != nil {
 		return &proto.ListPodsResponse{
 			Pods:  nil,
@@ -133,21 +141,16 @@ func (s *GRPCServer) ListPods(ctx context.Context, req *proto.ListPodsRequest) (
 	}
 
 	var pods []*proto.Pod
-	for _, data := range podsMap {
-		var podData map[string]interface{}
-		json.Unmarshal([]byte(data), &podData)
-
-		pod := &proto.Pod{
-			Name:        getString(podData, "name"),
-			Namespace:   getString(podData, "namespace"),
-			Status:      getString(podData, "status"),
-			NodeName:    getString(podData, "node_name"),
-			CreatedAt:   getString(podData, "created_at"),
-			UpdatedAt:   getString(podData, "updated_at"),
-			Labels:      getStringMap(podData, "labels"),
-			Annotations: getStringMap(podData, "annotations"),
+	for _, kv := range podsMap {
+		var pod podRecord
+		if err := decodeRecord(kv.Value, &pod); err != nil {
+			continue
+		}
+		if !selector.matches(pod.Labels) {
+			continue
 		}
-		pods = append(pods, pod)
+		pod.ResourceVersion = kv.ModRevision
+		pods = append(pods, pod.toProto())
 	}
 
 	return &proto.ListPodsResponse{
@@ -156,12 +159,19 @@ func (s *GRPCServer) ListPods(ctx context.Context, req *proto.ListPodsRequest) (
 	}, nil
 }
 
+// UpdatePod replaces a pod. The pod must carry the resource_version the
+// client last read; a stale version fails with codes.Aborted.
 func (s *GRPCServer) UpdatePod(ctx context.Context, req *proto.UpdatePodRequest) (*proto.UpdatePodResponse, error) {
 	pod := req.Pod
-	key := fmt.Sprintf("/pods/%s/%s", pod.Namespace, pod.Name)
+	key := podKey(pod.Namespace, pod.Name)
+
+	expected := pod.ResourceVersion
+	if expected == 0 {
+		return nil, status.Error(codes.InvalidArgument, "resource_version is required")
+	}
 
 	// Check if pod exists
-	_, err := s.etcdManager.Get(ctx, key)
+	_, err := s.store.Get(ctx, key)
 	if err != nil {
 		return &proto.UpdatePodResponse{
 			Pod:     nil,
@@ -170,20 +180,14 @@ func (s *GRPCServer) UpdatePod(ctx context.Context, req *proto.UpdatePodRequest)
 		}, nil
 	}
 
-	// Update pod data
-	podData := map[string]interface{}{
-		"name":       pod.Name,
-		"namespace":  pod.Namespace,
-		"status":     pod.Status,
-		"node_name":  pod.NodeName,
-		"created_at": pod.CreatedAt,
-		"updated_at": pod.UpdatedAt,
-		"labels":     pod.Labels,
-		"annotations": pod.Annotations,
+	pod.ResourceVersion = 0
+	data, err := encodeRecord(podFromProto(pod))
+	if err == nil {
+		pod.ResourceVersion, err = s.store.PutIfModRevision(ctx, key, data, expected, 0)
+	}
+	if errors.Is(err, etcd.ErrRevisionConflict) {
+		return nil, status.Error(codes.Aborted, "pod was modified; re-read it and retry")
 	}
-
-	data, _ := json.Marshal(podData)
-	err = s.etcdManager.Put(ctx, key, string(data))
 	if err != nil {
 		return &proto.UpdatePodResponse{
 			Pod:     nil,
@@ -200,9 +204,7 @@ func (s *GRPCServer) UpdatePod(ctx context.Context, req *proto.UpdatePodRequest)
 }
 
 func (s *GRPCServer) DeletePod(ctx context.Context, req *proto.DeletePodRequest) (*proto.DeletePodResponse, error) {
-	key := fmt.Sprintf("/pods/%s/%s", req.Namespace, req.Name)
-
-	err := s.etcdManager.Delete(ctx, key)
+	err := s.store.Delete(ctx, podKey(req.Namespace, req.Name))
 	if err != nil {
 		return &proto.DeletePodResponse{
 			Deleted: false,
@@ -219,7 +221,7 @@ func (s *GRPCServer) DeletePod(ctx context.Context, req *proto.DeletePodRequest)
 // Snapshot operations
 func (s *GRPCServer) CreateSnapshot(ctx context.Context, req *proto.CreateSnapshotRequest) (*proto.CreateSnapshotResponse, error) {
 	// Create snapshot
-	snapshotData, err := s.etcdManager.CreateSnapshot(ctx)
+	snapshotData, err := s.store.CreateSnapshot(ctx)
 	if err != nil {
 		return &proto.CreateSnapshotResponse{
 			Snapshot: nil,
@@ -228,32 +230,12 @@ func (s *GRPCServer) CreateSnapshot(ctx context.Context, req *proto.CreateSnapsh
 		}, nil
 	}
 
-	snapshot := &proto.Snapshot{
-		Id:           fmt.Sprintf("snap-%d", time.Now().Unix()),
-		Name:         req.Name,
-		Status:       "completed",
-		CreatedAt:    time.Now().UTC().Format(time.RFC3339),
-		SizeBytes:    int64(len(snapshotData)),
-		EtcdRevision: "unknown",
-		Checksum:     "unknown",
-		Metadata:     req.Metadata,
-	}
-
 	// Store snapshot metadata
-	snapData := map[string]interface{}{
-		"id":            snapshot.Id,
-		"name":          snapshot.Name,
-		"status":        snapshot.Status,
-		"created_at":    snapshot.CreatedAt,
-		"size_bytes":    snapshot.SizeBytes,
-		"etcd_revision": snapshot.EtcdRevision,
-		"checksum":      snapshot.Checksum,
-		"metadata":      snapshot.Metadata,
-	}
-
-	data, _ := json.Marshal(snapData)
-	key := fmt.Sprintf("/snapshots/%s", snapshot.Id)
-	err = s.etcdManager.Put(ctx, key, string(data))
+	snapshot := newSnapshotRecord(req.Name, len(snapshotData), req.Metadata)
+	data, err := encodeRecord(snapshot)
+	if err == nil {
+		err = s.store.Put(ctx, snapshotKey(snapshot.ID), data)
+	}
 	if err != nil {
 		return &proto.CreateSnapshotResponse{
 			Snapshot: nil,
@@ -263,16 +245,14 @@ func (s *GRPCServer) CreateSnapshot(ctx context.Context, req *proto.CreateSnapsh
 	}
 
 	return &proto.CreateSnapshotResponse{
-		Snapshot: snapshot,
+		Snapshot: snapshot.toProto(),
 		Success:  true,
 		Error:    "",
 	}, nil
 }
 
 func (s *GRPCServer) GetSnapshot(ctx context.Context, req *proto.GetSnapshotRequest) (*proto.GetSnapshotResponse, error) {
-	key := fmt.Sprintf("/snapshots/%s", req.Id)
-
-	data, err := s.etcdManager.Get(ctx, key)
+	kv, err := s.store.GetKeyValue(ctx, snapshotKey(req.Id))
 	if err != nil {
 		return &proto.GetSnapshotResponse{
 			Snapshot: nil,
@@ -281,30 +261,32 @@ func (s *GRPCServer) GetSnapshot(ctx context.Context, req *proto.GetSnapshotRequ
 		}, nil
 	}
 
-	var snapData map[string]interface{}
-	json.Unmarshal([]byte(data), &snapData)
-
-	snapshot := &proto.Snapshot{
-		Id:           getString(snapData, "id"),
-		Name:         getString(snapData, "name"),
-		Status:       getString(snapData, "status"),
-		CreatedAt:    getString(snapData, "created_at"),
-		SizeBytes:    getInt64(snapData, "size_bytes"),
-		EtcdRevision: getString(snapData, "etcd_revision"),
-		Checksum:     getString(snapData, "checksum"),
-		Metadata:     getStringMap(snapData, "metadata"),
+	var snapshot snapshotRecord
+	if err := decodeRecord(kv.Value, &snapshot); err != nil {
+		return &proto.GetSnapshotResponse{
+			Snapshot: nil,
+			Found:    false,
+			Error:    err.Error(),
+		}, nil
 	}
+	snapshot.ResourceVersion = kv.ModRevision
 
 	return &proto.GetSnapshotResponse{
-		Snapshot: snapshot,
+		Snapshot: snapshot.toProto(),
 		Found:    true,
 		Error:    "",
 	}, nil
 }
 
 func (s *GRPCServer) ListSnapshots(ctx context.Context, req *proto.ListSnapshotsRequest) (*proto.ListSnapshotsResponse, error) {
+	descending, err := parseSortOrder(req.Order)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	filter := snapshotFilter{Status: req.Status, Metadata: req.Metadata, Descending: descending}
+
 	prefix := "/snapshots/"
-	snapshotsMap, err := s.etcdManager.GetWithPrefix(ctx, prefix)
+	snapshotsMap, err := s.store.GetKeyValuesWithPrefix(ctx, prefix)
 	if err != nil {
 		return &proto.ListSnapshotsResponse{
 			Snapshots: nil,
@@ -313,21 +295,8 @@ func (s *GRPCServer) ListSnapshots(ctx context.Context, req *proto.ListSnapshots
 	}
 
 	var snapshots []*proto.Snapshot
-	for _, data := range snapshotsMap {
-		var snapData map[string]interface{}
-		json.Unmarshal([]byte(data), &snapData)
-
-		snapshot := &proto.Snapshot{
-			Id:           getString(snapData, "id"),
-			Name:         getString(snapData, "name"),
-			Status:       getString(snapData, "status"),
-			CreatedAt:    getString(snapData, "created_at"),
-			SizeBytes:    getInt64(snapData, "size_bytes"),
-			EtcdRevision: getString(snapData, "etcd_revision"),
-			Checksum:     getString(snapData, "checksum"),
-			Metadata:     getStringMap(snapData, "metadata"),
-		}
-		snapshots = append(snapshots, snapshot)
+	for _, snapshot := range listSnapshots(snapshotsMap, filter) {
+		snapshots = append(snapshots, snapshot.toProto())
 	}
 
 	return &proto.ListSnapshotsResponse{
@@ -346,9 +315,7 @@ func (s *GRPCServer) RestoreSnapshot(ctx context.Context, req *proto.RestoreSnap
 }
 
 func (s *GRPCServer) DeleteSnapshot(ctx context.Context, req *proto.DeleteSnapshotRequest) (*proto.DeleteSnapshotResponse, error) {
-	key := fmt.Sprintf("/snapshots/%s", req.Id)
-
-	err := s.etcdManager.Delete(ctx, key)
+	err := s.store.Delete(ctx, snapshotKey(req.Id))
 	if err != nil {
 		return &proto.DeleteSnapshotResponse{
 			Deleted: false,
@@ -364,29 +331,28 @@ func (s *GRPCServer) DeleteSnapshot(ctx context.Context, req *proto.DeleteSnapsh
 
 // Lease operations
 func (s *GRPCServer) CreateLease(ctx context.Context, req *proto.CreateLeaseRequest) (*proto.CreateLeaseResponse, error) {
-	lease := &proto.Lease{
-		Id:         fmt.Sprintf("lease-%d", time.Now().Unix()),
-		Holder:     req.Holder,
-		TtlSeconds: req.TtlSeconds,
-		GrantedAt:  time.Now().UTC().Format(time.RFC3339),
-		ExpiresAt:  time.Now().Add(time.Duration(req.TtlSeconds) * time.Second).UTC().Format(time.RFC3339),
-		Metadata:   req.Metadata,
-	}
-
-	// Store lease data
-	leaseData := map[string]interface{}{
-		"id":          lease.Id,
-		"holder":      lease.Holder,
-		"ttl_seconds": lease.TtlSeconds,
-		"granted_at":  lease.GrantedAt,
-		"expires_at":  lease.ExpiresAt,
-		"metadata":    lease.Metadata,
-	}
-
-	data, _ := json.Marshal(leaseData)
-	key := fmt.Sprintf("/leases/%s", lease.Id)
-	err := s.etcdManager.Put(ctx, key, string(data))
+	ttl := req.TtlSeconds
+	if ttl <= 0 {
+		ttl = 30 // Default 30 seconds
+	}
+
+	// Back the lease with a real etcd lease so the key disappears on expiry
+	leaseID, err := s.store.GrantLease(ctx, ttl)
+	if err != nil {
+		return &proto.CreateLeaseResponse{
+			Lease:   nil,
+			Success: false,
+			Error:   err.Error(),
+		}, nil
+	}
+
+	lease := newLeaseRecord(req.Holder, ttl, leaseID, req.Metadata)
+	data, err := encodeRecord(lease)
+	if err == nil {
+		err = s.store.PutWithLease(ctx, leaseKey(lease.ID), data, leaseID)
+	}
 	if err != nil {
+		s.store.RevokeLease(ctx, leaseID)
 		return &proto.CreateLeaseResponse{
 			Lease:   nil,
 			Success: false,
@@ -395,16 +361,14 @@ func (s *GRPCServer) CreateLease(ctx context.Context, req *proto.CreateLeaseRequ
 	}
 
 	return &proto.CreateLeaseResponse{
-		Lease:   lease,
+		Lease:   lease.toProto(),
 		Success: true,
 		Error:   "",
 	}, nil
 }
 
 func (s *GRPCServer) GetLease(ctx context.Context, req *proto.GetLeaseRequest) (*proto.GetLeaseResponse, error) {
-	key := fmt.Sprintf("/leases/%s", req.Id)
-
-	data, err := s.etcdManager.Get(ctx, key)
+	kv, err := s.store.GetKeyValue(ctx, leaseKey(req.Id))
 	if err != nil {
 		return &proto.GetLeaseResponse{
 			Lease: nil,
@@ -413,20 +377,27 @@ func (s *GRPCServer) GetLease(ctx context.Context, req *proto.GetLeaseRequest) (
 		}, nil
 	}
 
-	var leaseData map[string]interface{}
-	json.Unmarshal([]byte(data), &leaseData)
+	var lease leaseRecord
+	if err := decodeRecord(kv.Value, &lease); err != nil {
+		return &proto.GetLeaseResponse{
+			Lease: nil,
+			Found: false,
+			Error: err.Error(),
+		}, nil
+	}
+	lease.ResourceVersion = kv.ModRevision
 
-	lease := &proto.Lease{
-		Id:         getString(leaseData, "id"),
-		Holder:     getString(leaseData, "holder"),
-		TtlSeconds: getInt64(leaseData, "ttl_seconds"),
-		GrantedAt:  getString(leaseData, "granted_at"),
-		ExpiresAt:  getString(leaseData, "expires_at"),
-		Metadata:   getStringMap(leaseData, "metadata"),
+	alive, err := refreshLeaseTTL(ctx, s.store, &lease)
+	if err != nil || !alive {
+		return &proto.GetLeaseResponse{
+			Lease: nil,
+			Found: false,
+			Error: "Lease not found",
+		}, nil
 	}
 
 	return &proto.GetLeaseResponse{
-		Lease: lease,
+		Lease: lease.toProto(),
 		Found: true,
 		Error: "",
 	}, nil
@@ -434,7 +405,7 @@ func (s *GRPCServer) GetLease(ctx context.Context, req *proto.GetLeaseRequest) (
 
 func (s *GRPCServer) ListLeases(ctx context.Context, req *proto.ListLeasesRequest) (*proto.ListLeasesResponse, error) {
 	prefix := "/leases/"
-	leasesMap, err := s.etcdManager.GetWithPrefix(ctx, prefix)
+	leasesMap, err := s.store.GetKeyValuesWithPrefix(ctx, prefix)
 	if err != nil {
 		return &proto.ListLeasesResponse{
 			Leases: nil,
@@ -443,19 +414,21 @@ func (s *GRPCServer) ListLeases(ctx context.Context, req *proto.ListLeasesReques
 	}
 
 	var leases []*proto.Lease
-	for _, data := range leasesMap {
-		var leaseData map[string]interface{}
-		json.Unmarshal([]byte(data), &leaseData)
-
-		lease := &proto.Lease{
-			Id:         getString(leaseData, "id"),
-			Holder:     getString(leaseData, "holder"),
-			TtlSeconds: getInt64(leaseData, "ttl_seconds"),
-			GrantedAt:  getString(leaseData, "granted_at"),
-			ExpiresAt:  getString(leaseData, "expires_at"),
-			Metadata:   getStringMap(leaseData, "metadata"),
+	for _, kv := range leasesMap {
+		var lease leaseRecord
+		if err := decodeRecord(kv.Value, &lease); err != nil {
+			continue
+		}
+		lease.ResourceVersion = kv.ModRevision
+
+		if req.Holder != "" && lease.Holder != req.Holder {
+			continue
+		}
+		alive, err := refreshLeaseTTL(ctx, s.store, &lease)
+		if err != nil || !alive {
+			continue
 		}
-		leases = append(leases, lease)
+		leases = append(leases, lease.toProto())
 	}
 
 	return &proto.ListLeasesResponse{
@@ -465,10 +438,10 @@ func (s *GRPCServer) ListLeases(ctx context.Context, req *proto.ListLeasesReques
 }
 
 func (s *GRPCServer) RenewLease(ctx context.Context, req *proto.RenewLeaseRequest) (*proto.RenewLeaseResponse, error) {
-	key := fmt.Sprintf("/leases/%s", req.Id)
+	key := leaseKey(req.Id)
 
 	// Get existing lease
-	data, err := s.etcdManager.Get(ctx, key)
+	kv, err := s.store.GetKeyValue(ctx, key)
 	if err != nil {
 		return &proto.RenewLeaseResponse{
 			Lease:   nil,
@@ -477,22 +450,24 @@ func (s *GRPCServer) RenewLease(ctx context.Context, req *proto.RenewLeaseReques
 		}, nil
 	}
 
-	var leaseData map[string]interface{}
-	json.Unmarshal([]byte(data), &leaseData)
-
-	// Update lease
-	ttl := req.TtlSeconds
-	if ttl == 0 {
-		ttl = getInt64(leaseData, "ttl_seconds")
+	var lease leaseRecord
+	if err := decodeRecord(kv.Value, &lease); err != nil {
+		return &proto.RenewLeaseResponse{
+			Lease:   nil,
+			Success: false,
+			Error:   err.Error(),
+		}, nil
 	}
+	lease.ResourceVersion = kv.ModRevision
 
-	now := time.Now().UTC()
-	leaseData["ttl_seconds"] = ttl
-	leaseData["granted_at"] = now.Format(time.RFC3339)
-	leaseData["expires_at"] = now.Add(time.Duration(ttl) * time.Second).Format(time.RFC3339)
+	if req.ResourceVersion != 0 && req.ResourceVersion != lease.ResourceVersion {
+		return nil, status.Error(codes.Aborted, "lease was modified; re-read it and retry")
+	}
 
-	updatedData, _ := json.Marshal(leaseData)
-	err = s.etcdManager.Put(ctx, key, string(updatedData))
+	err = renewLease(ctx, s.store, key, &lease, req.TtlSeconds)
+	if errors.Is(err, etcd.ErrRevisionConflict) {
+		return nil, status.Error(codes.Aborted, "lease was modified; re-read it and retry")
+	}
 	if err != nil {
 		return &proto.RenewLeaseResponse{
 			Lease:   nil,
@@ -501,26 +476,27 @@ func (s *GRPCServer) RenewLease(ctx context.Context, req *proto.RenewLeaseReques
 		}, nil
 	}
 
-	lease := &proto.Lease{
-		Id:         getString(leaseData, "id"),
-		Holder:     getString(leaseData, "holder"),
-		TtlSeconds: getInt64(leaseData, "ttl_seconds"),
-		GrantedAt:  getString(leaseData, "granted_at"),
-		ExpiresAt:  getString(leaseData, "expires_at"),
-		Metadata:   getStringMap(leaseData, "metadata"),
-	}
-
 	return &proto.RenewLeaseResponse{
-		Lease:   lease,
+		Lease:   lease.toProto(),
 		Success: true,
 		Error:   "",
 	}, nil
 }
 
 func (s *GRPCServer) DeleteLease(ctx context.Context, req *proto.DeleteLeaseRequest) (*proto.DeleteLeaseResponse, error) {
-	key := fmt.Sprintf("/leases/%s", req.Id)
+	key := leaseKey(req.Id)
+
+	// Revoking the backing etcd lease also deletes the key
+	if data, err := s.store.Get(ctx, key); err == nil {
+		var lease leaseRecord
+		if decodeRecord(data, &lease) == nil {
+			if leaseID, err := lease.etcdLeaseID(); err == nil {
+				s.store.RevokeLease(ctx, leaseID)
+			}
+		}
+	}
 
-	err := s.etcdManager.Delete(ctx, key)
+	err := s.store.Delete(ctx, key)
 	if err != nil {
 		return &proto.DeleteLeaseResponse{
 			Deleted: false,
@@ -540,7 +516,7 @@ func (s *GRPCServer) ReplicateState(ctx context.Context, req *proto.ReplicateSta
 	for _, entry := range req.Entries {
 		key := string(entry.Key)
 		value := string(entry.Value)
-		err := s.etcdManager.Put(ctx, key, value)
+		err := s.store.Put(ctx, key, value)
 		if err != nil {
 			return &proto.ReplicateStateResponse{
 				Success: false,
@@ -570,42 +546,8 @@ func (s *GRPCServer) GetReplicationStatus(ctx context.Context, req *proto.GetRep
 
 	return &proto.GetReplicationStatusResponse{
 		Peers:         peers,
-		IsLeader:      s.etcdManager.IsLeader(),
-		LeaderAddress: s.etcdManager.GetLeaderAddr(),
+		IsLeader:      s.store.IsLeader(),
+		LeaderAddress: s.store.GetLeaderAddr(),
 		CurrentRevision: 0,
 	}, nil
 }
-
-// Helper functions
-func getString(data map[string]interface{}, key string) string {
-	if val, ok := data[key]; ok {
-		if str, ok := val.(string); ok {
-			return str
-		}
-	}
-	return ""
-}
-
-func getInt64(data map[string]interface{}, key string) int64 {
-	if val, ok := data[key]; ok {
-		if num, ok := val.(float64); ok {
-			return int64(num)
-		}
-	}
-	return 0
-}
-
-func getStringMap(data map[string]interface{}, key string) map[string]string {
-	if val, ok := data[key]; ok {
-		if m, ok := val.(map[string]interface{}); ok {
-			result := make(map[string]string)
-			for k, v := range m {
-				if str, ok := v.(string); ok {
-					result[k] = str
-				}
-			}
-			return result
-		}
-	}
-	return nil
-}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/decube/decube/api/proto"
+	"github.com/decube/decube/internal/etcd"
+	"github.com/decube/decube/pkg/config"
+)
+
+func TestGRPCPodCreateGetRoundTrip(t *testing.T) {
+	s := NewGRPCServer(newTestEtcdManager(t), config.GRPCConfig{})
+	ctx := context.Background()
+
+	pod := &proto.Pod{
+		Name:        "web",
+		Namespace:   "default",
+		Status:      "running",
+		NodeName:    "node-1",
+		CreatedAt:   "2024-01-01T00:00:00Z",
+		UpdatedAt:   "2024-01-01T00:00:00Z",
+		Labels:      map[string]string{"app": "web"},
+		Annotations: map[string]string{"owner": "team-a"},
+	}
+
+	created, err := s.CreatePod(ctx, &proto.CreatePodRequest{Pod: pod})
+	if err != nil || !created.Success {
+		t.Fatalf("create pod failed: err=%v resp=%v", err, created)
+	}
+
+	got, err := s.GetPod(ctx, &proto.GetPodRequest{Name: "web", Namespace: "default"})
+	if err != nil || !got.Found {
+		t.Fatalf("get pod failed: err=%v resp=%v", err, got)
+	}
+
+	p := got.Pod
+	if p.Name != pod.Name || p.Namespace != pod.Namespace || p.Status != pod.Status ||
+		p.NodeName != pod.NodeName || p.CreatedAt != pod.CreatedAt || p.UpdatedAt != pod.UpdatedAt {
+		t.Fatalf("pod fields changed in round trip: got %v, want %v", p, pod)
+	}
+	if !reflect.DeepEqual(p.Labels, pod.Labels) || !reflect.DeepEqual(p.Annotations, pod.Annotations) {
+		t.Fatalf("pod maps changed in round trip: got %v/%v, want %v/%v", p.Labels, p.Annotations, pod.Labels, pod.Annotations)
+	}
+
+	// The REST API reads the same record
+	rs := NewRESTServer(s.store, "127.0.0.1:0")
+	if rec := doRequest(rs, "GET", "/api/v1/pods/web", ""); rec.Code != 200 {
+		t.Fatalf("REST get of gRPC-created pod: expected 200, got %d", rec.Code)
+	}
+}
+
+func TestGRPCListSnapshotsFilterAndSort(t *testing.T) {
+	s := NewGRPCServer(etcd.NewMemoryStore(), config.GRPCConfig{})
+	ctx := context.Background()
+
+	for _, snap := range []snapshotRecord{
+		{ID: "snap-a", Status: "completed", CreatedAt: "2024-03-01T00:00:00Z"},
+		{ID: "snap-b", Status: "failed", CreatedAt: "2024-02-01T00:00:00Z"},
+		{ID: "snap-c", Status: "completed", CreatedAt: "2024-01-01T00:00:00Z"},
+	} {
+		data, _ := encodeRecord(&snap)
+		if err := s.store.Put(ctx, snapshotKey(snap.ID), data); err != nil {
+			t.Fatalf("failed to store snapshot: %v", err)
+		}
+	}
+
+	resp, err := s.ListSnapshots(ctx, &proto.ListSnapshotsRequest{Status: "completed", Order: "desc"})
+	if err != nil {
+		t.Fatalf("list snapshots failed: %v", err)
+	}
+	if resp.Count != 2 || resp.Snapshots[0].Id != "snap-a" || resp.Snapshots[1].Id != "snap-c" {
+		t.Fatalf("unexpected snapshots: %v", resp.Snapshots)
+	}
+
+	if _, err := s.ListSnapshots(ctx, &proto.ListSnapshotsRequest{Order: "sideways"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("invalid order: got %v, want %v", status.Code(err), codes.InvalidArgument)
+	}
+}
+
+func TestGRPCListPodsLabelSelector(t *testing.T) {
+	s := NewGRPCServer(etcd.NewMemoryStore(), config.GRPCConfig{})
+	ctx := context.Background()
+
+	for _, pod := range []*proto.Pod{
+		{Name: "web-1", Namespace: "default", Labels: map[string]string{"app": "web", "tier": "frontend"}},
+		{Name: "web-2", Namespace: "default", Labels: map[string]string{"app": "web", "tier": "cache"}},
+		{Name: "db-1", Namespace: "default", Labels: map[string]string{"app": "db"}},
+	} {
+		if _, err := s.CreatePod(ctx, &proto.CreatePodRequest{Pod: pod}); err != nil {
+			t.Fatalf("create pod failed: %v", err)
+		}
+	}
+
+	resp, err := s.ListPods(ctx, &proto.ListPodsRequest{
+		Namespace:     "default",
+		LabelSelector: "tier!=frontend",
+		Labels:        map[string]string{"app": "web"},
+	})
+	if err != nil {
+		t.Fatalf("list pods failed: %v", err)
+	}
+	if resp.Count != 1 || resp.Pods[0].Name != "web-2" {
+		t.Fatalf("unexpected pods: %v", resp.Pods)
+	}
+
+	if _, err := s.ListPods(ctx, &proto.ListPodsRequest{Namespace: "default", LabelSelector: "app in"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for a malformed selector, got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// idempotencyHeader lets clients retry creates without creating duplicates
+const idempotencyHeader = "Idempotency-Key"
+
+// idempotencyTTLSeconds is how long a key replays its original result
+const idempotencyTTLSeconds = 24 * 60 * 60
+
+// idempotentResult is the stored response for an idempotency key
+type idempotentResult struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// idempotencyDigest hashes a client-supplied key so it is safe to use in etcd keys and resource IDs
+func idempotencyDigest(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func idempotencyStoreKey(scope, key string) string {
+	return fmt.Sprintf("/idempotency/%s/%s", scope, idempotencyDigest(key))
+}
+
+// idempotentID derives a stable resource ID from an idempotency key
+func idempotentID(prefix, key string) string {
+	return fmt.Sprintf("%s-%s", prefix, idempotencyDigest(key)[:16])
+}
+
+// replayIdempotent writes the stored result for storeKey, reporting whether there was one
+func (rs *RESTServer) replayIdempotent(w http.ResponseWriter, r *http.Request, storeKey string) bool {
+	data, err := rs.store.Get(r.Context(), storeKey)
+	if err != nil {
+		return false
+	}
+
+	var result idempotentResult
+	if err := decodeRecord(data, &result); err != nil {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(result.Status)
+	w.Write(result.Body)
+	return true
+}
+
+// saveIdempotent remembers the response for storeKey until the key expires. A
+// failure only costs deduplication of later retries, so it is logged rather
+// than returned to the client.
+func (rs *RESTServer) saveIdempotent(ctx context.Context, storeKey string, status int, response interface{}) {
+	body, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("Failed to encode idempotent result %s: %v", storeKey, err)
+		return
+	}
+	data, err := encodeRecord(idempotentResult{Status: status, Body: body})
+	if err != nil {
+		log.Printf("Failed to encode idempotent result %s: %v", storeKey, err)
+		return
+	}
+
+	leaseID, err := rs.store.GrantLease(ctx, idempotencyTTLSeconds)
+	if err != nil {
+		log.Printf("Failed to store idempotent result %s: %v", storeKey, err)
+		return
+	}
+	if err := rs.store.PutWithLease(ctx, storeKey, data, leaseID); err != nil {
+		rs.store.RevokeLease(ctx, leaseID)
+		log.Printf("Failed to store idempotent result %s: %v", storeKey, err)
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"runtime/debug"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authorizeBearer checks the bearer token in the request metadata against
+// token. An empty token disables authentication.
+func authorizeBearer(ctx context.Context, token string) error {
+	if token == "" {
+		return nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	want := []byte("Bearer " + token)
+	for _, got := range md.Get("authorization") {
+		if subtle.ConstantTimeCompare([]byte(got), want) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or invalid bearer token")
+}
+
+// logCall writes one line per gRPC call with its outcome and latency
+func logCall(method string, start time.Time, err error) {
+	log.Printf("grpc method=%s code=%s duration=%s", method, status.Code(err), time.Since(start))
+}
+
+// recoverCall converts a panic in a handler into a codes.Internal error
+func recoverCall(method string, err *error) {
+	if r := recover(); r != nil {
+		log.Printf("grpc method=%s panic=%v\n%s", method, r, debug.Stack())
+		*err = status.Error(codes.Internal, "internal server error")
+	}
+}
+
+// unaryInterceptor authenticates, logs and recovers unary calls
+func unaryInterceptor(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		start := time.Now()
+		defer func() { logCall(info.FullMethod, start, err) }()
+		defer recoverCall(info.FullMethod, &err)
+
+		if err := authorizeBearer(ctx, token); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// streamInterceptor authenticates, logs and recovers streaming calls
+func streamInterceptor(token string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		start := time.Now()
+		defer func() { logCall(info.FullMethod, start, err) }()
+		defer recoverCall(info.FullMethod, &err)
+
+		if err := authorizeBearer(ss.Context(), token); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
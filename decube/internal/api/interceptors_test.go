@@ -0,0 +1,110 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestUnaryInterceptorAuth(t *testing.T) {
+	buf := captureLog(t)
+	intercept := unaryInterceptor("s3cret")
+	info := &grpc.UnaryServerInfo{FullMethod: "/decube.DeCube/GetCluster"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+
+	if _, err := intercept(context.Background(), nil, info, handler); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("unauthenticated call: got %v, want %v", status.Code(err), codes.Unauthenticated)
+	}
+
+	wrong := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer nope"))
+	if _, err := intercept(wrong, nil, info, handler); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("wrong token: got %v, want %v", status.Code(err), codes.Unauthenticated)
+	}
+
+	buf.Reset()
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer s3cret"))
+	resp, err := intercept(ctx, nil, info, handler)
+	if err != nil {
+		t.Fatalf("authenticated call failed: %v", err)
+	}
+	if resp != "ok" {
+		t.Fatalf("unexpected response %v", resp)
+	}
+	if line := buf.String(); !strings.Contains(line, "method=/decube.DeCube/GetCluster") || !strings.Contains(line, "code=OK") {
+		t.Fatalf("call was not logged: %q", line)
+	}
+}
+
+func TestUnaryInterceptorNoTokenAllowsAll(t *testing.T) {
+	captureLog(t)
+	info := &grpc.UnaryServerInfo{FullMethod: "/decube.DeCube/GetCluster"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+
+	if _, err := unaryInterceptor("")(context.Background(), nil, info, handler); err != nil {
+		t.Fatalf("call with auth disabled failed: %v", err)
+	}
+}
+
+func TestUnaryInterceptorRecoversPanic(t *testing.T) {
+	buf := captureLog(t)
+	info := &grpc.UnaryServerInfo{FullMethod: "/decube.DeCube/CreatePod"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("boom")
+	}
+
+	_, err := unaryInterceptor("")(context.Background(), nil, info, handler)
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("got %v, want %v", status.Code(err), codes.Internal)
+	}
+	if !strings.Contains(buf.String(), "code=Internal") {
+		t.Fatalf("panic was not logged as Internal: %q", buf.String())
+	}
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context { return s.ctx }
+
+func TestStreamInterceptorAuth(t *testing.T) {
+	captureLog(t)
+	intercept := streamInterceptor("s3cret")
+	info := &grpc.StreamServerInfo{FullMethod: "/decube.DeCube/WatchPods"}
+	called := false
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		called = true
+		return nil
+	}
+
+	err := intercept(nil, &fakeServerStream{ctx: context.Background()}, info, handler)
+	if status.Code(err) != codes.Unauthenticated || called {
+		t.Fatalf("unauthenticated stream: got %v (handler called: %v)", status.Code(err), called)
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer s3cret"))
+	if err := intercept(nil, &fakeServerStream{ctx: ctx}, info, handler); err != nil || !called {
+		t.Fatalf("authenticated stream: err=%v called=%v", err, called)
+	}
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/decube/decube/internal/etcd"
+)
+
+// leaderHeader carries the leader's address on gRPC writes refused by a follower
+const leaderHeader = "x-decube-leader"
+
+// Leader-only writes. etcd already linearizes writes through its leader, so
+// followers accepting them is correct but wasteful: every write takes an
+// extra hop inside etcd. With the guard on, followers send writers to the
+// leader and keep serving reads locally.
+
+// leaderRESTAddr is the REST address of the leader at leaderAddr, an etcd
+// client address. Every node is assumed to serve REST on the port restAddr
+// uses, as the etcd manager assumes for the client port.
+func leaderRESTAddr(leaderAddr, restAddr string) string {
+	host, _, err := net.SplitHostPort(leaderAddr)
+	if err != nil {
+		host = leaderAddr
+	}
+	_, port, err := net.SplitHostPort(restAddr)
+	if err != nil {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// EnableLeaderOnlyWrites installs middleware that redirects writes on a
+// follower to the leader with a 307, which keeps the method and body.
+// Writes fail with 503 while no leader is known.
+func (rs *RESTServer) EnableLeaderOnlyWrites() {
+	rs.router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+			if rs.store.IsLeader() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			leader := rs.store.GetLeaderAddr()
+			if leader == "" {
+				http.Error(w, "No leader elected", http.StatusServiceUnavailable)
+				return
+			}
+			scheme := "http"
+			if r.TLS != nil {
+				scheme = "https"
+			}
+			target := scheme + "://" + leaderRESTAddr(leader, rs.server.Addr) + r.URL.RequestURI()
+			http.Redirect(w, r, target, http.StatusTemporaryRedirect)
+		})
+	})
+}
+
+// isReadMethod reports whether a gRPC method only reads state
+func isReadMethod(fullMethod string) bool {
+	name := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	return strings.HasPrefix(name, "Get") || strings.HasPrefix(name, "List")
+}
+
+// leaderUnaryInterceptor refuses writes on a follower with
+// codes.FailedPrecondition, naming the leader in the x-decube-leader header
+func leaderUnaryInterceptor(store etcd.Store) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if isReadMethod(info.FullMethod) || store.IsLeader() {
+			return handler(ctx, req)
+		}
+
+		leader := store.GetLeaderAddr()
+		if leader == "" {
+			return nil, status.Error(codes.Unavailable, "no leader elected")
+		}
+		grpc.SetHeader(ctx, metadata.Pairs(leaderHeader, leader))
+		return nil, status.Errorf(codes.FailedPrecondition, "not the leader; send writes to %s", leader)
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/decube/decube/internal/etcd"
+)
+
+func TestLeaderOnlyWritesREST(t *testing.T) {
+	store := etcd.NewMemoryStore()
+
+	follower := NewRESTServer(followerStore{Store: store, leader: "10.0.0.2:2379"}, "0.0.0.0:8080")
+	follower.EnableLeaderOnlyWrites()
+
+	rec := doRequest(follower, http.MethodPost, "/api/v1/pods?namespace=prod", `{"name":"web"}`)
+	if rec.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("write on follower: expected 307, got %d", rec.Code)
+	}
+	if got, want := rec.Header().Get("Location"), "http://10.0.0.2:8080/api/v1/pods?namespace=prod"; got != want {
+		t.Fatalf("redirected to %q, want %q", got, want)
+	}
+	if rec := doRequest(follower, http.MethodGet, "/api/v1/pods", ""); rec.Code != http.StatusOK {
+		t.Fatalf("read on follower: expected 200, got %d", rec.Code)
+	}
+
+	leaderless := NewRESTServer(followerStore{Store: store}, "0.0.0.0:8080")
+	leaderless.EnableLeaderOnlyWrites()
+	if rec := doRequest(leaderless, http.MethodPost, "/api/v1/pods", `{"name":"web"}`); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("write without a leader: expected 503, got %d", rec.Code)
+	}
+
+	leader := NewRESTServer(store, "0.0.0.0:8080")
+	leader.EnableLeaderOnlyWrites()
+	if rec := doRequest(leader, http.MethodPost, "/api/v1/pods", `{"name":"web"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("write on leader: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestLeaderOnlyWritesGRPC(t *testing.T) {
+	store := etcd.NewMemoryStore()
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	call := func(s etcd.Store, method string) error {
+		_, err := leaderUnaryInterceptor(s)(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/decube.DeCubeService/" + method}, handler)
+		return err
+	}
+
+	follower := followerStore{Store: store, leader: "10.0.0.2:2379"}
+	err := call(follower, "CreatePod")
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("write on follower: got %v, want FailedPrecondition", err)
+	}
+	if msg := status.Convert(err).Message(); msg != "not the leader; send writes to 10.0.0.2:2379" {
+		t.Fatalf("unexpected message %q", msg)
+	}
+	for _, method := range []string{"GetPod", "ListPods", "GetReplicationStatus"} {
+		if err := call(follower, method); err != nil {
+			t.Fatalf("read %s on follower failed: %v", method, err)
+		}
+	}
+	if err := call(followerStore{Store: store}, "DeletePod"); status.Code(err) != codes.Unavailable {
+		t.Fatalf("write without a leader: got %v, want Unavailable", err)
+	}
+	if err := call(store, "CreatePod"); err != nil {
+		t.Fatalf("write on leader failed: %v", err)
+	}
+}
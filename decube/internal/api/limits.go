@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// RequestLimitConfig bounds request body size and handler run time
+type RequestLimitConfig struct {
+	// MaxBodyBytes caps request bodies; requests over it get 413. Zero disables the cap.
+	MaxBodyBytes int64
+	// Timeout is the deadline given to each handler's context; handlers that
+	// run past it answer 504. Zero disables the deadline.
+	Timeout time.Duration
+}
+
+// middleware applies the body cap and per-request deadline
+func (cfg RequestLimitConfig) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.MaxBodyBytes > 0 {
+			if r.ContentLength > cfg.MaxBodyBytes {
+				http.Error(w, fmt.Sprintf("request body exceeds %d bytes", cfg.MaxBodyBytes), http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxBodyBytes)
+		}
+		if cfg.Timeout > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), cfg.Timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// EnableRequestLimits installs the body size and timeout middleware
+func (rs *RESTServer) EnableRequestLimits(cfg RequestLimitConfig) {
+	rs.router.Use(cfg.middleware)
+}
+
+// errorStatus maps request limit failures to 413 and 504, and anything else to def
+func errorStatus(err error, def int) int {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return def
+	}
+}
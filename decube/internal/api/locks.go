@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/decube/decube/internal/etcd"
+	"github.com/gorilla/mux"
+)
+
+// defaultLockTTL is how long a lock acquired over REST lasts without a renewal
+const defaultLockTTL = 30
+
+// maxLockWait caps how long an acquire request waits for a held lock, so it
+// answers well inside the server's write timeout
+const maxLockWait = 10 * time.Second
+
+// Lock handlers. A REST client cannot hold a keepalive open across requests,
+// so locks taken here last for their TTL and the client renews them with the
+// fencing token the acquire returned; releasing with the token frees them at
+// once. See etcd.Locker for the locking itself.
+
+// acquireLockHandler takes the lock on a key, waiting up to wait_seconds for
+// its holder to release it
+func (rs *RESTServer) acquireLockHandler(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+
+	var req struct {
+		Holder      string `json:"holder"`
+		TTLSeconds  int64  `json:"ttl_seconds"`
+		WaitSeconds int64  `json:"wait_seconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", errorStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	if req.Holder == "" {
+		http.Error(w, "Lock holder is required", http.StatusBadRequest)
+		return
+	}
+
+	if req.TTLSeconds <= 0 {
+		req.TTLSeconds = defaultLockTTL
+	}
+
+	ctx := r.Context()
+	wait := time.Duration(req.WaitSeconds) * time.Second
+	if wait > maxLockWait {
+		wait = maxLockWait
+	}
+	if wait > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, wait)
+		defer cancel()
+	}
+
+	locker := etcd.NewLocker(rs.store, req.Holder)
+	lock, err := locker.AcquireLease(ctx, key, time.Duration(req.TTLSeconds)*time.Second, wait > 0)
+	if errors.Is(err, context.DeadlineExceeded) && r.Context().Err() == nil {
+		err = etcd.ErrLockHeld // the wait ran out, not the request
+	}
+	if err != nil {
+		if errors.Is(err, etcd.ErrLockHeld) {
+			rs.lockConflict(w, r, key)
+			return
+		}
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	response := map[string]interface{}{
+		"lock":     lock,
+		"acquired": true,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// getLockHandler returns the current holder of a lock and its fencing token
+func (rs *RESTServer) getLockHandler(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+
+	lock, err := etcd.NewLocker(rs.store, "").Get(r.Context(), key)
+	if errors.Is(err, etcd.ErrLockNotHeld) {
+		http.Error(w, "Lock not held", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	response := map[string]interface{}{
+		"lock":  lock,
+		"found": true,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// renewLockHandler extends a lock by its TTL; the body carries the token the
+// acquire returned
+func (rs *RESTServer) renewLockHandler(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+
+	token, ok := decodeLockToken(w, r)
+	if !ok {
+		return
+	}
+
+	lock, err := etcd.NewLocker(rs.store, "").Renew(r.Context(), key, token)
+	if errors.Is(err, etcd.ErrLockNotHeld) {
+		http.Error(w, "Lock is not held with this token", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	response := map[string]interface{}{
+		"lock":    lock,
+		"success": true,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// releaseLockHandler frees a lock; the body carries the token the acquire returned
+func (rs *RESTServer) releaseLockHandler(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+
+	token, ok := decodeLockToken(w, r)
+	if !ok {
+		return
+	}
+
+	err := etcd.NewLocker(rs.store, "").Release(r.Context(), key, token)
+	if errors.Is(err, etcd.ErrLockNotHeld) {
+		http.Error(w, "Lock is not held with this token", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	response := map[string]interface{}{
+		"released": true,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Lock helpers
+
+// decodeLockToken reads the fencing token from a renew or release body,
+// answering 400 if it is missing
+func decodeLockToken(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	var req struct {
+		Token int64 `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", errorStatus(err, http.StatusBadRequest))
+		return 0, false
+	}
+	if req.Token <= 0 {
+		http.Error(w, "Lock token is required", http.StatusBadRequest)
+		return 0, false
+	}
+	return req.Token, true
+}
+
+// lockConflict answers 409 naming the lock's current holder, if it still has one
+func (rs *RESTServer) lockConflict(w http.ResponseWriter, r *http.Request, key string) {
+	response := map[string]interface{}{
+		"acquired": false,
+		"error":    "Lock is held",
+	}
+	if holder, err := etcd.NewLocker(rs.store, "").Get(r.Context(), key); err == nil {
+		response["holder"] = holder.Holder
+		response["ttl_seconds"] = holder.TTLSeconds
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(response)
+}
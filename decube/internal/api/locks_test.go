@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/decube/decube/internal/etcd"
+)
+
+func TestLockAcquireRenewRelease(t *testing.T) {
+	rs := NewRESTServer(etcd.NewMemoryStore(), "127.0.0.1:0")
+
+	acquire := func(holder string) (int, etcd.LockInfo) {
+		t.Helper()
+		rec := doRequest(rs, http.MethodPost, "/api/v1/locks/backup/acquire", fmt.Sprintf(`{"holder":%q,"ttl_seconds":10}`, holder))
+		var resp struct {
+			Lock etcd.LockInfo `json:"lock"`
+		}
+		json.NewDecoder(rec.Body).Decode(&resp)
+		return rec.Code, resp.Lock
+	}
+
+	if rec := doRequest(rs, http.MethodPost, "/api/v1/locks/backup/acquire", `{"ttl_seconds":10}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("acquire without holder: expected 400, got %d", rec.Code)
+	}
+
+	code, lock := acquire("worker-1")
+	if code != http.StatusOK || lock.Holder != "worker-1" || lock.Token == 0 {
+		t.Fatalf("acquire: expected 200 with a token, got %d %+v", code, lock)
+	}
+	if code, _ := acquire("worker-2"); code != http.StatusConflict {
+		t.Fatalf("acquire while held: expected 409, got %d", code)
+	}
+
+	rec := doRequest(rs, http.MethodGet, "/api/v1/locks/backup", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get lock: expected 200, got %d", rec.Code)
+	}
+
+	stale := fmt.Sprintf(`{"token":%d}`, lock.Token+1)
+	if rec := doRequest(rs, http.MethodPost, "/api/v1/locks/backup/renew", stale); rec.Code != http.StatusConflict {
+		t.Fatalf("renew with a wrong token: expected 409, got %d", rec.Code)
+	}
+	token := fmt.Sprintf(`{"token":%d}`, lock.Token)
+	if rec := doRequest(rs, http.MethodPost, "/api/v1/locks/backup/renew", token); rec.Code != http.StatusOK {
+		t.Fatalf("renew: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := doRequest(rs, http.MethodPost, "/api/v1/locks/backup/release", token); rec.Code != http.StatusOK {
+		t.Fatalf("release: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := doRequest(rs, http.MethodGet, "/api/v1/locks/backup", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("get released lock: expected 404, got %d", rec.Code)
+	}
+
+	// Once released, the next holder gets a newer fencing token
+	code, next := acquire("worker-2")
+	if code != http.StatusOK || next.Token <= lock.Token {
+		t.Fatalf("acquire after release: expected a token above %d, got %d %+v", lock.Token, code, next)
+	}
+	if rec := doRequest(rs, http.MethodPost, "/api/v1/locks/backup/release", token); rec.Code != http.StatusConflict {
+		t.Fatalf("release with the old token: expected 409, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics served on /metrics in the Prometheus text format
+var (
+	snapshotsTaken = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "decube_scheduled_snapshots_total",
+		Help: "Scheduled snapshots attempted, by result.",
+	}, []string{"result"})
+	snapshotDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "decube_scheduled_snapshot_duration_seconds",
+		Help:    "Time taken to take and store a scheduled snapshot.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 4, 8),
+	})
+	snapshotSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "decube_scheduled_snapshot_size_bytes",
+		Help: "Size of the latest scheduled snapshot as stored.",
+	})
+	snapshotLastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "decube_scheduled_snapshot_last_success_timestamp_seconds",
+		Help: "Unix time of the latest successful scheduled snapshot.",
+	})
+	snapshotsPruned = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "decube_scheduled_snapshots_pruned_total",
+		Help: "Scheduled snapshots deleted to stay within the retention count.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(snapshotsTaken, snapshotDuration, snapshotSize, snapshotLastSuccess, snapshotsPruned)
+}
+
+// metricsHandler serves every registered metric
+var metricsHandler = promhttp.Handler()
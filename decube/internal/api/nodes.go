@@ -0,0 +1,217 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/decube/decube/internal/etcd"
+	"github.com/gorilla/mux"
+)
+
+// defaultNodeTTL is how long a node stays ready without a heartbeat
+const defaultNodeTTL = 30
+
+// Node handlers. A node is stored at /nodes/{id}; its liveness is a separate
+// key at /heartbeats/{id} bound to an etcd lease of the node's TTL, which each
+// heartbeat keeps alive. The reconciler watches the heartbeat keys.
+
+// registerNodeHandler registers a node, or re-registers one that restarted,
+// and counts the registration as its first heartbeat
+func (rs *RESTServer) registerNodeHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID         string            `json:"id"`
+		Address    string            `json:"address"`
+		Labels     map[string]string `json:"labels"`
+		TTLSeconds int64             `json:"ttl_seconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", errorStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	if req.ID == "" {
+		http.Error(w, "Node ID is required", http.StatusBadRequest)
+		return
+	}
+
+	if req.TTLSeconds <= 0 {
+		req.TTLSeconds = defaultNodeTTL
+	}
+
+	// Drop the heartbeat lease of a previous registration
+	var previous nodeRecord
+	if data, err := rs.store.Get(r.Context(), nodeKey(req.ID)); err == nil && decodeRecord(data, &previous) == nil {
+		if leaseID, err := strconv.ParseInt(previous.EtcdLeaseID, 10, 64); err == nil {
+			rs.store.RevokeLease(r.Context(), leaseID)
+		}
+	}
+
+	node := &nodeRecord{
+		ID:           req.ID,
+		Address:      req.Address,
+		Labels:       req.Labels,
+		TTLSeconds:   req.TTLSeconds,
+		RegisteredAt: timestamp(),
+	}
+	if err := heartbeatNode(r.Context(), rs.store, node); err != nil {
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	response := map[string]interface{}{
+		"node":    node,
+		"success": true,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// nodeHeartbeatHandler extends a node's heartbeat lease by its TTL. A node
+// whose lease already ran out gets a fresh one and becomes ready again.
+func (rs *RESTServer) nodeHeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	data, err := rs.store.Get(r.Context(), nodeKey(id))
+	if err != nil {
+		http.Error(w, "Node not found", http.StatusNotFound)
+		return
+	}
+
+	var node nodeRecord
+	if err := decodeRecord(data, &node); err != nil {
+		http.Error(w, "Invalid node data", http.StatusInternalServerError)
+		return
+	}
+
+	if err := heartbeatNode(r.Context(), rs.store, &node); err != nil {
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	response := map[string]interface{}{
+		"node":    &node,
+		"success": true,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// listNodesHandler lists every registered node, sorted by ID, with its readiness
+func (rs *RESTServer) listNodesHandler(w http.ResponseWriter, r *http.Request) {
+	nodes, err := listNodes(r.Context(), rs.store)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	ready := 0
+	for _, node := range nodes {
+		if node.Ready {
+			ready++
+		}
+	}
+
+	response := map[string]interface{}{
+		"nodes": nodes,
+		"count": len(nodes),
+		"ready": ready,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Node helpers
+
+// heartbeatNode keeps the node's heartbeat lease alive, granting a new one if
+// it has expired, and stores the node record with the heartbeat time
+func heartbeatNode(ctx context.Context, store etcd.Store, node *nodeRecord) error {
+	node.LastHeartbeat = timestamp()
+
+	if leaseID, err := strconv.ParseInt(node.EtcdLeaseID, 10, 64); err == nil {
+		if remaining, err := store.KeepAliveOnce(ctx, leaseID); err == nil {
+			node.Ready = true
+			node.RemainingSeconds = remaining
+			return putNode(ctx, store, node)
+		}
+	}
+
+	leaseID, err := store.GrantLease(ctx, node.TTLSeconds)
+	if err != nil {
+		return err
+	}
+	if err := store.PutWithLease(ctx, heartbeatKey(node.ID), node.LastHeartbeat, leaseID); err != nil {
+		store.RevokeLease(ctx, leaseID)
+		return err
+	}
+
+	node.EtcdLeaseID = strconv.FormatInt(leaseID, 10)
+	node.Ready = true
+	node.RemainingSeconds = node.TTLSeconds
+	return putNode(ctx, store, node)
+}
+
+// putNode stores a node record without its derived fields
+func putNode(ctx context.Context, store etcd.Store, node *nodeRecord) error {
+	stored := *node
+	stored.Ready = false
+	stored.RemainingSeconds = 0
+	stored.ResourceVersion = 0
+
+	data, err := encodeRecord(&stored)
+	if err != nil {
+		return err
+	}
+	return store.Put(ctx, nodeKey(node.ID), data)
+}
+
+// refreshNodeReady fills in the node's readiness from its heartbeat lease
+func refreshNodeReady(ctx context.Context, store etcd.Store, node *nodeRecord) error {
+	node.Ready = false
+	node.RemainingSeconds = 0
+
+	leaseID, err := strconv.ParseInt(node.EtcdLeaseID, 10, 64)
+	if err != nil {
+		return nil // never heartbeated
+	}
+	remaining, err := store.LeaseTimeToLive(ctx, leaseID)
+	if err != nil {
+		return err
+	}
+	if remaining > 0 {
+		node.Ready = true
+		node.RemainingSeconds = remaining
+	}
+	return nil
+}
+
+// listNodes returns every registered node, sorted by ID, with its readiness
+func listNodes(ctx context.Context, store etcd.Store) ([]*nodeRecord, error) {
+	stored, err := store.GetKeyValuesWithPrefix(ctx, "/nodes/")
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []*nodeRecord{}
+	for _, kv := range stored {
+		var node nodeRecord
+		if err := decodeRecord(kv.Value, &node); err != nil {
+			continue
+		}
+		if err := refreshNodeReady(ctx, store, &node); err != nil {
+			return nil, err
+		}
+		node.ResourceVersion = kv.ModRevision
+		nodes = append(nodes, &node)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes, nil
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/decube/decube/internal/etcd"
+)
+
+func TestNodeHeartbeatAndExpiry(t *testing.T) {
+	var offset atomic.Int64
+	start := time.Now()
+	store := etcd.NewMemoryStoreWithClock(func() time.Time {
+		return start.Add(time.Duration(offset.Load()))
+	})
+	rs := NewRESTServer(store, "127.0.0.1:0")
+
+	listNodes := func() []nodeRecord {
+		t.Helper()
+		rec := doRequest(rs, http.MethodGet, "/api/v1/nodes", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list nodes: expected 200, got %d", rec.Code)
+		}
+		var resp struct {
+			Nodes []nodeRecord `json:"nodes"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode nodes: %v", err)
+		}
+		return resp.Nodes
+	}
+
+	if rec := doRequest(rs, http.MethodPost, "/api/v1/nodes", `{"address":"10.0.0.1"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("register without ID: expected 400, got %d", rec.Code)
+	}
+	rec := doRequest(rs, http.MethodPost, "/api/v1/nodes", `{"id":"node-1","address":"10.0.0.1:7000","ttl_seconds":10}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("register node: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if nodes := listNodes(); len(nodes) != 1 || !nodes[0].Ready || nodes[0].Address != "10.0.0.1:7000" {
+		t.Fatalf("expected one ready node, got %+v", nodes)
+	}
+
+	// A heartbeat 8s in keeps the node ready past its original 10s TTL
+	offset.Store(int64(8 * time.Second))
+	if rec := doRequest(rs, http.MethodPost, "/api/v1/nodes/node-1/heartbeat", ""); rec.Code != http.StatusOK {
+		t.Fatalf("heartbeat: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	offset.Store(int64(15 * time.Second))
+	if nodes := listNodes(); !nodes[0].Ready || nodes[0].RemainingSeconds != 3 {
+		t.Fatalf("expected node ready with 3s left, got %+v", nodes[0])
+	}
+
+	// Without further heartbeats the lease expires and the node stays listed as not ready
+	offset.Store(int64(19 * time.Second))
+	if nodes := listNodes(); len(nodes) != 1 || nodes[0].Ready {
+		t.Fatalf("expected node to be listed as not ready, got %+v", nodes)
+	}
+
+	// A late heartbeat brings it back
+	if rec := doRequest(rs, http.MethodPost, "/api/v1/nodes/node-1/heartbeat", ""); rec.Code != http.StatusOK {
+		t.Fatalf("heartbeat after expiry: expected 200, got %d", rec.Code)
+	}
+	if nodes := listNodes(); !nodes[0].Ready {
+		t.Fatalf("expected node ready again, got %+v", nodes[0])
+	}
+
+	if rec := doRequest(rs, http.MethodPost, "/api/v1/nodes/node-2/heartbeat", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("heartbeat for unknown node: expected 404, got %d", rec.Code)
+	}
+}
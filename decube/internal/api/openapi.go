@@ -0,0 +1,302 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// openAPIVersion is the OpenAPI release the generated documents follow
+const openAPIVersion = "3.0.3"
+
+// routeDoc describes a route for the OpenAPI document. Paths and methods are
+// taken from the router, so only what the router cannot know lives here.
+type routeDoc struct {
+	Summary string
+	// Query lists the query parameters the handler reads
+	Query []string
+	// Headers lists the request headers the handler reads
+	Headers []string
+	// Request is the media type of the request body, or "" for none
+	Request string
+	// Response is the media type of successful responses; defaults to JSON
+	Response  string
+	Responses map[int]string
+}
+
+// apiRouteDocs documents the API routes, keyed by "METHOD /path/template"
+var apiRouteDocs = map[string]routeDoc{
+	"GET /health": {Summary: "Report node health", Responses: map[int]string{200: "Node is healthy"}},
+
+	"GET /api/v1/pods": {Summary: "List pods in a namespace", Query: []string{"namespace", "labelSelector"}, Responses: map[int]string{
+		200: "Pods in the namespace", 400: "Malformed label selector", 500: "etcd failure", 504: "Request timed out"}},
+	"POST /api/v1/pods": {Summary: "Create a pod", Headers: []string{"Idempotency-Key"}, Request: "application/json", Responses: map[int]string{
+		201: "Pod created, or the original response for a replayed Idempotency-Key", 400: "Invalid pod",
+		409: "Pod already exists", 413: "Request body too large", 500: "etcd failure", 504: "Request timed out"}},
+	"GET /api/v1/pods/{name}": {Summary: "Get a pod", Query: []string{"namespace"}, Responses: map[int]string{
+		200: "The pod", 404: "Pod not found", 500: "Invalid pod data"}},
+	"PUT /api/v1/pods/{name}": {Summary: "Update a pod", Query: []string{"namespace"}, Request: "application/json", Responses: map[int]string{
+		200: "Pod updated", 400: "Invalid update or missing resource_version", 404: "Pod not found",
+		409: "resource_version is stale", 413: "Request body too large", 500: "etcd failure"}},
+	"DELETE /api/v1/pods/{name}": {Summary: "Delete a pod", Query: []string{"namespace"}, Responses: map[int]string{
+		200: "Pod deleted", 500: "etcd failure"}},
+
+	"GET /api/v1/snapshots": {Summary: "List snapshots sorted by creation time", Query: []string{"status", "label", "order"}, Responses: map[int]string{
+		200: "Matching snapshots", 400: "Invalid label or order", 500: "etcd failure"}},
+	"POST /api/v1/snapshots": {Summary: "Take a snapshot", Headers: []string{"Idempotency-Key"}, Request: "application/json", Responses: map[int]string{
+		201: "Snapshot created, or the original response for a replayed Idempotency-Key", 400: "Invalid request",
+		413: "Request body too large", 500: "Snapshot failed", 504: "Request timed out"}},
+	"GET /api/v1/snapshots/{id}": {Summary: "Get a snapshot", Responses: map[int]string{
+		200: "The snapshot", 404: "Snapshot not found", 500: "Invalid snapshot data"}},
+	"POST /api/v1/snapshots/{id}/restore": {Summary: "Restore a snapshot", Responses: map[int]string{200: "Restore result"}},
+	"DELETE /api/v1/snapshots/{id}": {Summary: "Delete a snapshot", Responses: map[int]string{
+		200: "Snapshot deleted", 500: "etcd failure"}},
+
+	"GET /api/v1/leases": {Summary: "List live leases", Responses: map[int]string{200: "Live leases", 500: "etcd failure"}},
+	"POST /api/v1/leases": {Summary: "Create a lease", Request: "application/json", Responses: map[int]string{
+		201: "Lease created", 400: "Missing holder", 413: "Request body too large", 500: "etcd failure"}},
+	"GET /api/v1/leases/{id}": {Summary: "Get a lease and its remaining TTL", Responses: map[int]string{
+		200: "The lease", 404: "Lease not found or expired", 500: "etcd failure"}},
+	"POST /api/v1/leases/{id}/renew": {Summary: "Renew a lease, optionally with a new TTL", Request: "application/json", Responses: map[int]string{
+		200: "Lease renewed", 404: "Lease not found or expired", 409: "resource_version is stale", 500: "Invalid lease data"}},
+	"DELETE /api/v1/leases/{id}": {Summary: "Revoke a lease", Responses: map[int]string{
+		200: "Lease deleted", 500: "etcd failure"}},
+
+	"GET /api/v1/locks/{key}": {Summary: "Get a lock's holder and fencing token", Responses: map[int]string{
+		200: "The lock", 404: "Lock not held", 500: "etcd failure"}},
+	"POST /api/v1/locks/{key}/acquire": {Summary: "Acquire a lock, optionally waiting for it", Request: "application/json", Responses: map[int]string{
+		200: "Lock acquired with its fencing token", 400: "Missing holder", 409: "Lock is held",
+		413: "Request body too large", 500: "etcd failure"}},
+	"POST /api/v1/locks/{key}/renew": {Summary: "Renew a lock by its TTL", Request: "application/json", Responses: map[int]string{
+		200: "Lock renewed", 400: "Missing token", 409: "Lock is not held with this token", 500: "etcd failure"}},
+	"POST /api/v1/locks/{key}/release": {Summary: "Release a lock", Request: "application/json", Responses: map[int]string{
+		200: "Lock released", 400: "Missing token", 409: "Lock is not held with this token", 500: "etcd failure"}},
+
+	"GET /api/v1/nodes": {Summary: "List registered nodes and their readiness", Responses: map[int]string{
+		200: "Registered nodes", 500: "etcd failure"}},
+	"POST /api/v1/nodes": {Summary: "Register a node", Request: "application/json", Responses: map[int]string{
+		201: "Node registered", 400: "Missing node ID", 413: "Request body too large", 500: "etcd failure"}},
+	"POST /api/v1/nodes/{id}/heartbeat": {Summary: "Record a node heartbeat", Responses: map[int]string{
+		200: "Heartbeat recorded", 404: "Node not registered", 500: "etcd failure"}},
+
+	"GET /node/info":    {Summary: "Get node information", Responses: map[int]string{200: "Node information"}},
+	"GET /metrics":      {Summary: "Get Prometheus metrics", Response: "text/plain", Responses: map[int]string{200: "Metrics in the Prometheus text format"}},
+	"GET /openapi.json": {Summary: "Get this OpenAPI document", Responses: map[int]string{200: "OpenAPI document"}},
+	"GET /docs":         {Summary: "Browse the API with Swagger UI", Response: "text/html", Responses: map[int]string{200: "Swagger UI page"}},
+}
+
+// OpenAPIDocument is an OpenAPI 3 description of the API
+type OpenAPIDocument struct {
+	OpenAPI string                                  `json:"openapi"`
+	Info    OpenAPIInfo                             `json:"info"`
+	Paths   map[string]map[string]*OpenAPIOperation `json:"paths"`
+}
+
+// OpenAPIInfo names and versions the API
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenAPIOperation describes one method on a path
+type OpenAPIOperation struct {
+	Summary     string                     `json:"summary,omitempty"`
+	OperationID string                     `json:"operationId"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter describes a path, query or header parameter
+type OpenAPIParameter struct {
+	Name     string        `json:"name"`
+	In       string        `json:"in"`
+	Required bool          `json:"required,omitempty"`
+	Schema   OpenAPISchema `json:"schema"`
+}
+
+// OpenAPIRequestBody describes a request body
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse describes a response for one status code
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType gives the schema of a body in one media type
+type OpenAPIMediaType struct {
+	Schema OpenAPISchema `json:"schema"`
+}
+
+// OpenAPISchema is the subset of JSON Schema the generated documents use
+type OpenAPISchema struct {
+	Type    string `json:"type"`
+	Format  string `json:"format,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// pathVarPattern matches a mux path variable with an optional regexp, e.g. {height:[0-9]+}
+var pathVarPattern = regexp.MustCompile(`\{([^{}:]+)(?::([^{}]*))?\}`)
+
+// buildOpenAPI describes every route registered on router that has a path and methods
+func buildOpenAPI(router *mux.Router, info OpenAPIInfo, docs map[string]routeDoc) (*OpenAPIDocument, error) {
+	doc := &OpenAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info:    info,
+		Paths:   make(map[string]map[string]*OpenAPIOperation),
+	}
+
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil // Catch-all routes such as CORS preflight have no path
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil // Subrouter prefixes are not endpoints themselves
+		}
+
+		path := pathVarPattern.ReplaceAllString(tmpl, "{$1}")
+		for _, method := range methods {
+			if method == http.MethodOptions {
+				continue
+			}
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = make(map[string]*OpenAPIOperation)
+			}
+			doc.Paths[path][strings.ToLower(method)] = newOpenAPIOperation(method, tmpl, path, docs[method+" "+path])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func newOpenAPIOperation(method, tmpl, path string, rd routeDoc) *OpenAPIOperation {
+	op := &OpenAPIOperation{
+		Summary:     rd.Summary,
+		OperationID: operationID(method, path),
+		Responses:   make(map[string]OpenAPIResponse),
+	}
+
+	for _, m := range pathVarPattern.FindAllStringSubmatch(tmpl, -1) {
+		op.Parameters = append(op.Parameters, OpenAPIParameter{
+			Name: m[1], In: "path", Required: true,
+			Schema: OpenAPISchema{Type: "string", Pattern: m[2]},
+		})
+	}
+	for _, name := range rd.Query {
+		op.Parameters = append(op.Parameters, OpenAPIParameter{Name: name, In: "query", Schema: OpenAPISchema{Type: "string"}})
+	}
+	for _, name := range rd.Headers {
+		op.Parameters = append(op.Parameters, OpenAPIParameter{Name: name, In: "header", Schema: OpenAPISchema{Type: "string"}})
+	}
+
+	if rd.Request != "" {
+		op.RequestBody = &OpenAPIRequestBody{
+			Required: true,
+			Content:  map[string]OpenAPIMediaType{rd.Request: {Schema: mediaSchema(rd.Request)}},
+		}
+	}
+
+	responses := rd.Responses
+	if len(responses) == 0 {
+		responses = map[int]string{http.StatusOK: "OK"}
+	}
+	codes := make([]int, 0, len(responses))
+	for code := range responses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		resp := OpenAPIResponse{Description: responses[code]}
+		if mediaType := responseMediaType(code, rd); mediaType != "" {
+			resp.Content = map[string]OpenAPIMediaType{mediaType: {Schema: mediaSchema(mediaType)}}
+		}
+		op.Responses[strconv.Itoa(code)] = resp
+	}
+	return op
+}
+
+// responseMediaType is the body type for a status code; errors are JSON and
+// bodiless statuses have none
+func responseMediaType(code int, rd routeDoc) string {
+	switch {
+	case code == http.StatusSwitchingProtocols || code == http.StatusNotModified:
+		return ""
+	case code >= 400 || rd.Response == "":
+		return "application/json"
+	default:
+		return rd.Response
+	}
+}
+
+func mediaSchema(mediaType string) OpenAPISchema {
+	switch mediaType {
+	case "application/json", "multipart/form-data":
+		return OpenAPISchema{Type: "object"}
+	case "text/html":
+		return OpenAPISchema{Type: "string"}
+	default:
+		return OpenAPISchema{Type: "string", Format: "binary"}
+	}
+}
+
+// operationID derives an identifier such as get_blocks_height from a route
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, part := range strings.Split(path, "/") {
+		part = strings.Trim(part, "{}")
+		part = strings.NewReplacer(".", "_", "-", "_").Replace(part)
+		if part != "" {
+			id += "_" + part
+		}
+	}
+	return id
+}
+
+// swaggerUIPage renders Swagger UI against the document served at /openapi.json
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>%s</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
+
+func (rs *RESTServer) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	doc, err := buildOpenAPI(rs.router, OpenAPIInfo{Title: "DeCube API", Version: nodeVersion}, apiRouteDocs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(doc)
+}
+
+func (rs *RESTServer) docsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, swaggerUIPage, "DeCube API")
+}
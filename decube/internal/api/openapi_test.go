@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIDocument(t *testing.T) {
+	rs := NewRESTServer(nil, "127.0.0.1:0")
+	rs.EnableCORS(DefaultCORSConfig([]string{"*"}))
+
+	rec := doRequest(rs, http.MethodGet, "/openapi.json", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var doc OpenAPIDocument
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid OpenAPI JSON: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") || doc.Info.Title == "" || doc.Info.Version == "" {
+		t.Fatalf("invalid OpenAPI header: %q %+v", doc.OpenAPI, doc.Info)
+	}
+
+	templateVar := regexp.MustCompile(`\{([^{}]+)\}`)
+	operationIDs := make(map[string]bool)
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			where := method + " " + path
+			if op.Summary == "" {
+				t.Errorf("%s is not documented", where)
+			}
+			if op.OperationID == "" || operationIDs[op.OperationID] {
+				t.Errorf("%s: missing or duplicate operationId %q", where, op.OperationID)
+			}
+			operationIDs[op.OperationID] = true
+
+			if len(op.Responses) == 0 {
+				t.Errorf("%s has no responses", where)
+			}
+			for code := range op.Responses {
+				if n, err := strconv.Atoi(code); err != nil || n < 100 || n > 599 {
+					t.Errorf("%s: invalid status code %q", where, code)
+				}
+			}
+
+			declared := make(map[string]bool)
+			for _, p := range op.Parameters {
+				if p.In == "path" {
+					declared[p.Name] = p.Required
+				}
+			}
+			for _, m := range templateVar.FindAllStringSubmatch(path, -1) {
+				if !declared[m[1]] {
+					t.Errorf("%s: path parameter %s is not declared as required", where, m[1])
+				}
+			}
+		}
+	}
+
+	for _, want := range []string{"/health", "/api/v1/pods", "/api/v1/snapshots/{id}/restore"} {
+		if _, ok := doc.Paths[want]; !ok {
+			t.Errorf("path %s missing from the OpenAPI document", want)
+		}
+	}
+
+	// Every documented route must still be registered
+	for key := range apiRouteDocs {
+		method, path, _ := strings.Cut(key, " ")
+		if _, ok := doc.Paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("documented route %s is not registered", key)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/decube/decube/internal/etcd"
+	"github.com/decube/decube/pkg/config"
+)
+
+// Pod lifecycle states driven by the reconciler
+const (
+	podPending = "Pending"
+	podRunning = "Running"
+	podUnknown = "Unknown"
+)
+
+// Reconciler moves pods through their lifecycle. A pod assigned to a node
+// becomes Running once that node heartbeats, and Unknown when the node's
+// heartbeat disappears. Nodes heartbeat by keeping a lease-bound key under
+// /heartbeats/ alive, so a node that stops heartbeating drops out on its own.
+type Reconciler struct {
+	store    etcd.Store
+	interval time.Duration
+}
+
+// NewReconciler creates a reconciler that, on top of reacting to watch
+// events, re-checks every pod each cfg.ReconcileInterval
+func NewReconciler(store etcd.Store, cfg config.ControllerConfig) *Reconciler {
+	return &Reconciler{store: store, interval: cfg.ReconcileInterval}
+}
+
+// Run reconciles pods until ctx is done
+func (rc *Reconciler) Run(ctx context.Context) {
+	pods := rc.store.Watch(ctx, "/pods/")
+	heartbeats := rc.store.Watch(ctx, "/heartbeats/")
+
+	var tick <-chan time.Time
+	if rc.interval > 0 {
+		ticker := time.NewTicker(rc.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	rc.reconcileAll(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-pods:
+			if !ok {
+				return
+			}
+			if ev.Type == etcd.EventPut {
+				rc.reconcilePod(ctx, ev.Key)
+			}
+		case _, ok := <-heartbeats:
+			if !ok {
+				return
+			}
+			rc.reconcileAll(ctx)
+		case <-tick:
+			rc.reconcileAll(ctx)
+		}
+	}
+}
+
+// reconcileAll reconciles every pod in every namespace
+func (rc *Reconciler) reconcileAll(ctx context.Context) {
+	pods, err := rc.store.GetKeyValuesWithPrefix(ctx, "/pods/")
+	if err != nil {
+		log.Printf("Reconciler: failed to list pods: %v", err)
+		return
+	}
+	for key := range pods {
+		rc.reconcilePod(ctx, key)
+	}
+}
+
+// reconcilePod sets the status of the pod at key from its node's liveness.
+// The write is conditional on the version read, so a concurrent update wins
+// and the reconciler sees the pod again through the watch.
+func (rc *Reconciler) reconcilePod(ctx context.Context, key string) {
+	kv, err := rc.store.GetKeyValue(ctx, key)
+	if err != nil {
+		return // deleted since the event
+	}
+	var pod podRecord
+	if err := decodeRecord(kv.Value, &pod); err != nil || pod.NodeName == "" {
+		return
+	}
+
+	alive, err := rc.nodeAlive(ctx, pod.NodeName)
+	if err != nil {
+		log.Printf("Reconciler: failed to check node %s: %v", pod.NodeName, err)
+		return
+	}
+
+	status := pod.Status
+	switch {
+	case alive && (status == "" || strings.EqualFold(status, podPending) || status == podUnknown):
+		status = podRunning
+	case !alive && status != podUnknown:
+		status = podUnknown
+	}
+	if status == pod.Status {
+		return
+	}
+
+	pod.Status = status
+	pod.UpdatedAt = timestamp()
+	pod.ResourceVersion = 0
+	data, err := encodeRecord(&pod)
+	if err == nil {
+		_, err = rc.store.PutIfModRevision(ctx, key, data, kv.ModRevision, 0)
+	}
+	if err != nil && !errors.Is(err, etcd.ErrRevisionConflict) {
+		log.Printf("Reconciler: failed to update pod %s: %v", key, err)
+	}
+}
+
+// nodeAlive reports whether node has a live heartbeat
+func (rc *Reconciler) nodeAlive(ctx context.Context, node string) (bool, error) {
+	_, err := rc.store.Get(ctx, heartbeatKey(node))
+	if errors.Is(err, etcd.ErrKeyNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/decube/decube/internal/etcd"
+	"github.com/decube/decube/pkg/config"
+)
+
+// waitForPodStatus polls the pod until it reaches want or the deadline passes
+func waitForPodStatus(t *testing.T, store etcd.Store, name, want string) {
+	t.Helper()
+	var pod podRecord
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if data, err := store.Get(context.Background(), podKey("default", name)); err == nil {
+			if err := decodeRecord(data, &pod); err == nil && pod.Status == want {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("pod %s never reached %s; last status %q", name, want, pod.Status)
+}
+
+func TestReconcilerPodLifecycle(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The reconciler reads the clock from its own goroutine
+	var offset atomic.Int64
+	start := time.Now()
+	store := etcd.NewMemoryStoreWithClock(func() time.Time {
+		return start.Add(time.Duration(offset.Load()))
+	})
+
+	rc := NewReconciler(store, config.ControllerConfig{ReconcileInterval: 20 * time.Millisecond})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		rc.Run(ctx)
+	}()
+
+	data, _ := encodeRecord(&podRecord{Name: "web", Namespace: "default", Status: podPending, NodeName: "node-1"})
+	if err := store.Put(ctx, podKey("default", "web"), data); err != nil {
+		t.Fatalf("failed to store pod: %v", err)
+	}
+	unscheduled, _ := encodeRecord(&podRecord{Name: "idle", Namespace: "default", Status: podPending})
+	store.Put(ctx, podKey("default", "idle"), unscheduled)
+
+	// No heartbeat yet, so the node counts as missing
+	waitForPodStatus(t, store, "web", podUnknown)
+
+	// The node heartbeats with a 10s lease
+	leaseID, err := store.GrantLease(ctx, 10)
+	if err != nil {
+		t.Fatalf("failed to grant lease: %v", err)
+	}
+	if err := store.PutWithLease(ctx, heartbeatKey("node-1"), timestamp(), leaseID); err != nil {
+		t.Fatalf("failed to heartbeat: %v", err)
+	}
+	waitForPodStatus(t, store, "web", podRunning)
+
+	// The heartbeat lease runs out and the periodic pass notices
+	offset.Store(int64(11 * time.Second))
+	waitForPodStatus(t, store, "web", podUnknown)
+
+	// Pods without a node are left alone
+	waitForPodStatus(t, store, "idle", podPending)
+
+	cancel()
+	<-done
+}
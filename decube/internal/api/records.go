@@ -0,0 +1,267 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/decube/decube/api/proto"
+	"github.com/decube/decube/internal/etcd"
+)
+
+// Stored records shared by the REST and gRPC servers. Both APIs read and
+// write etcd through these types so the two cannot drift apart.
+//
+// ResourceVersion is the etcd mod revision of the record's key. It is filled
+// in on every read and never stored; updates must echo it back and fail
+// with a conflict if the record changed in between.
+
+// podRecord is the stored form of a pod
+type podRecord struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace"`
+	Status      string            `json:"status"`
+	NodeName    string            `json:"node_name"`
+	CreatedAt   string            `json:"created_at"`
+	UpdatedAt   string            `json:"updated_at"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+
+	ResourceVersion int64 `json:"resource_version,omitempty"`
+}
+
+// snapshotRecord is the stored form of snapshot metadata
+type snapshotRecord struct {
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	Status       string            `json:"status"`
+	CreatedAt    string            `json:"created_at"`
+	SizeBytes    int64             `json:"size_bytes"`
+	EtcdRevision string            `json:"etcd_revision"`
+	Checksum     string            `json:"checksum"`
+	Metadata     map[string]string `json:"metadata"`
+
+	ResourceVersion int64 `json:"resource_version,omitempty"`
+}
+
+// leaseRecord is the stored form of a lease. RemainingSeconds and ExpiresAt
+// are refreshed from etcd whenever the lease is read.
+type leaseRecord struct {
+	ID               string            `json:"id"`
+	Holder           string            `json:"holder"`
+	TTLSeconds       int64             `json:"ttl_seconds"`
+	RemainingSeconds int64             `json:"remaining_seconds"`
+	GrantedAt        string            `json:"granted_at"`
+	ExpiresAt        string            `json:"expires_at"`
+	EtcdLeaseID      string            `json:"etcd_lease_id"`
+	Metadata         map[string]string `json:"metadata"`
+
+	ResourceVersion int64 `json:"resource_version,omitempty"`
+}
+
+// nodeRecord is the stored form of a registered node. The node is live while
+// the etcd lease behind its heartbeat key is; Ready and RemainingSeconds are
+// derived from that lease whenever the node is read.
+type nodeRecord struct {
+	ID            string            `json:"id"`
+	Address       string            `json:"address"`
+	Labels        map[string]string `json:"labels"`
+	TTLSeconds    int64             `json:"ttl_seconds"`
+	RegisteredAt  string            `json:"registered_at"`
+	LastHeartbeat string            `json:"last_heartbeat"`
+	EtcdLeaseID   string            `json:"etcd_lease_id"`
+
+	Ready            bool  `json:"ready"`
+	RemainingSeconds int64 `json:"remaining_seconds"`
+	ResourceVersion  int64 `json:"resource_version,omitempty"`
+}
+
+func podKey(namespace, name string) string {
+	return fmt.Sprintf("/pods/%s/%s", namespace, name)
+}
+
+func snapshotKey(id string) string {
+	return fmt.Sprintf("/snapshots/%s", id)
+}
+
+func leaseKey(id string) string {
+	return fmt.Sprintf("/leases/%s", id)
+}
+
+func nodeKey(id string) string {
+	return fmt.Sprintf("/nodes/%s", id)
+}
+
+func heartbeatKey(node string) string {
+	return fmt.Sprintf("/heartbeats/%s", node)
+}
+
+// encodeRecord serializes a record for storage in etcd
+func encodeRecord(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// decodeRecord parses a record read from etcd into v
+func decodeRecord(data string, v interface{}) error {
+	return json.Unmarshal([]byte(data), v)
+}
+
+// timestamp returns the current time in the RFC 3339 form used by all records
+func timestamp() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+func podFromProto(p *proto.Pod) *podRecord {
+	return &podRecord{
+		Name:            p.Name,
+		Namespace:       p.Namespace,
+		Status:          p.Status,
+		NodeName:        p.NodeName,
+		CreatedAt:       p.CreatedAt,
+		UpdatedAt:       p.UpdatedAt,
+		Labels:          p.Labels,
+		Annotations:     p.Annotations,
+		ResourceVersion: p.ResourceVersion,
+	}
+}
+
+func (p *podRecord) toProto() *proto.Pod {
+	return &proto.Pod{
+		Name:            p.Name,
+		Namespace:       p.Namespace,
+		Status:          p.Status,
+		NodeName:        p.NodeName,
+		CreatedAt:       p.CreatedAt,
+		UpdatedAt:       p.UpdatedAt,
+		Labels:          p.Labels,
+		Annotations:     p.Annotations,
+		ResourceVersion: p.ResourceVersion,
+	}
+}
+
+// newSnapshotRecord describes a freshly taken snapshot of size bytes
+func newSnapshotRecord(name string, size int, metadata map[string]string) *snapshotRecord {
+	t := time.Now()
+	if name == "" {
+		name = fmt.Sprintf("snapshot-%d", t.Unix())
+	}
+	return &snapshotRecord{
+		ID:           fmt.Sprintf("snap-%d", t.Unix()),
+		Name:         name,
+		Status:       "completed",
+		CreatedAt:    t.UTC().Format(time.RFC3339),
+		SizeBytes:    int64(size),
+		EtcdRevision: "unknown", // Would need to get from etcd
+		Checksum:     "unknown", // Would compute hash
+		Metadata:     metadata,
+	}
+}
+
+func (s *snapshotRecord) toProto() *proto.Snapshot {
+	return &proto.Snapshot{
+		Id:              s.ID,
+		Name:            s.Name,
+		Status:          s.Status,
+		CreatedAt:       s.CreatedAt,
+		SizeBytes:       s.SizeBytes,
+		EtcdRevision:    s.EtcdRevision,
+		Checksum:        s.Checksum,
+		Metadata:        s.Metadata,
+		ResourceVersion: s.ResourceVersion,
+	}
+}
+
+// snapshotFilter selects and orders snapshots for the list endpoints
+type snapshotFilter struct {
+	Status     string
+	Metadata   map[string]string
+	Descending bool
+}
+
+// parseSortOrder maps an "asc"/"desc" order parameter to a descending flag
+func parseSortOrder(order string) (bool, error) {
+	switch order {
+	case "", "asc":
+		return false, nil
+	case "desc":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid sort order %q: must be asc or desc", order)
+	}
+}
+
+func (f snapshotFilter) matches(s *snapshotRecord) bool {
+	if f.Status != "" && s.Status != f.Status {
+		return false
+	}
+	for k, v := range f.Metadata {
+		if got, ok := s.Metadata[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// listSnapshots decodes the stored snapshots from a prefix scan, drops the
+// ones the filter rejects and sorts the rest by created_at
+func listSnapshots(stored map[string]etcd.KeyValue, f snapshotFilter) []*snapshotRecord {
+	snapshots := []*snapshotRecord{}
+	for _, kv := range stored {
+		var snapshot snapshotRecord
+		if err := decodeRecord(kv.Value, &snapshot); err != nil {
+			continue
+		}
+		snapshot.ResourceVersion = kv.ModRevision
+		if f.matches(&snapshot) {
+			snapshots = append(snapshots, &snapshot)
+		}
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		a, b := snapshots[i], snapshots[j]
+		if f.Descending {
+			a, b = b, a
+		}
+		ta, _ := time.Parse(time.RFC3339, a.CreatedAt)
+		tb, _ := time.Parse(time.RFC3339, b.CreatedAt)
+		if !ta.Equal(tb) {
+			return ta.Before(tb)
+		}
+		return a.ID < b.ID
+	})
+	return snapshots
+}
+
+// newLeaseRecord describes a lease backed by the etcd lease leaseID
+func newLeaseRecord(holder string, ttl, leaseID int64, metadata map[string]string) *leaseRecord {
+	t := time.Now().UTC()
+	return &leaseRecord{
+		ID:               leaseRecordID(leaseID),
+		Holder:           holder,
+		TTLSeconds:       ttl,
+		RemainingSeconds: ttl,
+		GrantedAt:        t.Format(time.RFC3339),
+		ExpiresAt:        t.Add(time.Duration(ttl) * time.Second).Format(time.RFC3339),
+		EtcdLeaseID:      strconv.FormatInt(leaseID, 10),
+		Metadata:         metadata,
+	}
+}
+
+func (l *leaseRecord) toProto() *proto.Lease {
+	return &proto.Lease{
+		Id:               l.ID,
+		Holder:           l.Holder,
+		TtlSeconds:       l.TTLSeconds,
+		GrantedAt:        l.GrantedAt,
+		ExpiresAt:        l.ExpiresAt,
+		Metadata:         l.Metadata,
+		RemainingSeconds: l.RemainingSeconds,
+		ResourceVersion:  l.ResourceVersion,
+	}
+}
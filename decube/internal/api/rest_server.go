@@ -1,29 +1,35 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/decube/decube/internal/etcd"
 )
 
+// nodeVersion is reported by /node/info and the OpenAPI document
+const nodeVersion = "0.1.0"
+
 // RESTServer provides REST API endpoints for the DeCube control-plane
 type RESTServer struct {
-	etcdManager *etcd.EtcdManager
-	router      *mux.Router
-	server      *http.Server
+	store  etcd.Store
+	router *mux.Router
+	server *http.Server
 }
 
 // NewRESTServer creates a new REST server
-func NewRESTServer(etcdManager *etcd.EtcdManager, address string) *RESTServer {
+func NewRESTServer(store etcd.Store, address string) *RESTServer {
 	rs := &RESTServer{
-		etcdManager: etcdManager,
-		router:      mux.NewRouter(),
+		store:  store,
+		router: mux.NewRouter(),
 	}
 
 	rs.setupRoutes()
@@ -77,8 +83,26 @@ func (rs *RESTServer) setupRoutes() {
 	api.HandleFunc("/leases/{id}/renew", rs.renewLeaseHandler).Methods("POST")
 	api.HandleFunc("/leases/{id}", rs.deleteLeaseHandler).Methods("DELETE")
 
+	// Lock endpoints
+	api.HandleFunc("/locks/{key}", rs.getLockHandler).Methods("GET")
+	api.HandleFunc("/locks/{key}/acquire", rs.acquireLockHandler).Methods("POST")
+	api.HandleFunc("/locks/{key}/renew", rs.renewLockHandler).Methods("POST")
+	api.HandleFunc("/locks/{key}/release", rs.releaseLockHandler).Methods("POST")
+
+	// Nodes
+	api.HandleFunc("/nodes", rs.listNodesHandler).Methods("GET")
+	api.HandleFunc("/nodes", rs.registerNodeHandler).Methods("POST")
+	api.HandleFunc("/nodes/{id}/heartbeat", rs.nodeHeartbeatHandler).Methods("POST")
+
 	// Node info
 	rs.router.HandleFunc("/node/info", rs.nodeInfoHandler).Methods("GET")
+
+	// Prometheus metrics
+	rs.router.Handle("/metrics", metricsHandler).Methods("GET")
+
+	// API description, generated from the routes above
+	rs.router.HandleFunc("/openapi.json", rs.openAPIHandler).Methods("GET")
+	rs.router.HandleFunc("/docs", rs.docsHandler).Methods("GET")
 }
 
 // healthHandler handles health check requests
@@ -86,7 +110,7 @@ func (rs *RESTServer) healthHandler(w http.ResponseWriter, r *http.Request) {
 	health := map[string]interface{}{
 		"status": "healthy",
 		"timestamp": time.Now().UTC().Format(time.RFC3339),
-		"is_leader": rs.etcdManager.IsLeader(),
+		"is_leader": rs.store.IsLeader(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -94,26 +118,38 @@ func (rs *RESTServer) healthHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // Pod handlers
+// listPodsHandler lists the pods in a namespace, keeping only those whose
+// labels match the labelSelector query parameter if one is given.
 func (rs *RESTServer) listPodsHandler(w http.ResponseWriter, r *http.Request) {
 	namespace := r.URL.Query().Get("namespace")
 	if namespace == "" {
 		namespace = "default"
 	}
 
+	selector, err := parseLabelSelector(r.URL.Query().Get("labelSelector"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	prefix := fmt.Sprintf("/pods/%s/", namespace)
-	pods, err := rs.etcdManager.GetWithPrefix(r.Context(), prefix)
+	pods, err := rs.store.GetKeyValuesWithPrefix(r.Context(), prefix)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
-	var podList []map[string]interface{}
-	for key, value := range pods {
-		var pod map[string]interface{}
-		if err := json.Unmarshal([]byte(value), &pod); err != nil {
+	podList := []*podRecord{}
+	for _, kv := range pods {
+		var pod podRecord
+		if err := decodeRecord(kv.Value, &pod); err != nil {
+			continue
+		}
+		if !selector.matches(pod.Labels) {
 			continue
 		}
-		podList = append(podList, pod)
+		pod.ResourceVersion = kv.ModRevision
+		podList = append(podList, &pod)
 	}
 
 	response := map[string]interface{}{
@@ -125,43 +161,60 @@ func (rs *RESTServer) listPodsHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// createPodHandler creates a pod, failing with 409 if it already exists.
+// Requests carrying an Idempotency-Key header that was already used get the
+// original response back instead.
 func (rs *RESTServer) createPodHandler(w http.ResponseWriter, r *http.Request) {
-	var pod map[string]interface{}
+	var storeKey string
+	if key := r.Header.Get(idempotencyHeader); key != "" {
+		storeKey = idempotencyStoreKey("pods", key)
+		if rs.replayIdempotent(w, r, storeKey) {
+			return
+		}
+	}
+
+	var pod podRecord
 	if err := json.NewDecoder(r.Body).Decode(&pod); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		http.Error(w, "Invalid JSON", errorStatus(err, http.StatusBadRequest))
 		return
 	}
 
-	// Set timestamps
-	now := time.Now().UTC().Format(time.RFC3339)
-	pod["created_at"] = now
-	pod["updated_at"] = now
+	if pod.Name == "" {
+		http.Error(w, "Pod name is required", http.StatusBadRequest)
+		return
+	}
 
 	// Default namespace
-	if pod["namespace"] == nil {
-		pod["namespace"] = "default"
+	if pod.Namespace == "" {
+		pod.Namespace = "default"
 	}
 
-	name, ok := pod["name"].(string)
-	if !ok {
-		http.Error(w, "Pod name is required", http.StatusBadRequest)
+	// Set timestamps
+	pod.CreatedAt = timestamp()
+	pod.UpdatedAt = pod.CreatedAt
+	pod.ResourceVersion = 0
+
+	// Revision 0 only matches a key that doesn't exist yet
+	podJSON, err := encodeRecord(&pod)
+	if err == nil {
+		pod.ResourceVersion, err = rs.store.PutIfModRevision(r.Context(), podKey(pod.Namespace, pod.Name), podJSON, 0, 0)
+	}
+	if errors.Is(err, etcd.ErrRevisionConflict) {
+		http.Error(w, "Pod already exists", http.StatusConflict)
 		return
 	}
-
-	namespace, _ := pod["namespace"].(string)
-	key := fmt.Sprintf("/pods/%s/%s", namespace, name)
-
-	podJSON, _ := json.Marshal(pod)
-	err := rs.etcdManager.Put(r.Context(), key, string(podJSON))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
 	response := map[string]interface{}{
-		"pod":     pod,
+		"pod":     &pod,
 		"success": true,
 	}
+	if storeKey != "" {
+		rs.saveIdempotent(r.Context(), storeKey, http.StatusCreated, response)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -176,21 +229,21 @@ func (rs *RESTServer) getPodHandler(w http.ResponseWriter, r *http.Request) {
 		namespace = "default"
 	}
 
-	key := fmt.Sprintf("/pods/%s/%s", namespace, name)
-	podJSON, err := rs.etcdManager.Get(r.Context(), key)
+	kv, err := rs.store.GetKeyValue(r.Context(), podKey(namespace, name))
 	if err != nil {
 		http.Error(w, "Pod not found", http.StatusNotFound)
 		return
 	}
 
-	var pod map[string]interface{}
-	if err := json.Unmarshal([]byte(podJSON), &pod); err != nil {
+	var pod podRecord
+	if err := decodeRecord(kv.Value, &pod); err != nil {
 		http.Error(w, "Invalid pod data", http.StatusInternalServerError)
 		return
 	}
+	pod.ResourceVersion = kv.ModRevision
 
 	response := map[string]interface{}{
-		"pod":   pod,
+		"pod":   &pod,
 		"found": true,
 	}
 
@@ -198,6 +251,9 @@ func (rs *RESTServer) getPodHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// updatePodHandler merges the request body over a pod. The body must carry
+// the resource_version the client last read; if the pod has changed since,
+// the update is rejected with 409.
 func (rs *RESTServer) updatePodHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["name"]
@@ -206,42 +262,59 @@ func (rs *RESTServer) updatePodHandler(w http.ResponseWriter, r *http.Request) {
 		namespace = "default"
 	}
 
-	key := fmt.Sprintf("/pods/%s/%s", namespace, name)
+	key := podKey(namespace, name)
 
 	// Get existing pod
-	existingJSON, err := rs.etcdManager.Get(r.Context(), key)
+	existing, err := rs.store.GetKeyValue(r.Context(), key)
 	if err != nil {
 		http.Error(w, "Pod not found", http.StatusNotFound)
 		return
 	}
 
-	var existingPod map[string]interface{}
-	json.Unmarshal([]byte(existingJSON), &existingPod)
+	var pod podRecord
+	if err := decodeRecord(existing.Value, &pod); err != nil {
+		http.Error(w, "Invalid pod data", http.StatusInternalServerError)
+		return
+	}
 
-	// Update with new data
-	var updates map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	// Merge updates over the existing pod; the key fields stay fixed
+	if err := json.NewDecoder(r.Body).Decode(&pod); err != nil {
+		http.Error(w, "Invalid JSON", errorStatus(err, http.StatusBadRequest))
 		return
 	}
+	pod.Name = name
+	pod.Namespace = namespace
 
-	// Merge updates
-	for k, v := range updates {
-		existingPod[k] = v
+	expected := pod.ResourceVersion
+	if expected == 0 {
+		http.Error(w, "resource_version is required", http.StatusBadRequest)
+		return
+	}
+	if expected != existing.ModRevision {
+		http.Error(w, "Pod was modified; re-read it and retry", http.StatusConflict)
+		return
 	}
 
 	// Update timestamp
-	existingPod["updated_at"] = time.Now().UTC().Format(time.RFC3339)
+	pod.UpdatedAt = timestamp()
+	pod.ResourceVersion = 0
 
-	updatedJSON, _ := json.Marshal(existingPod)
-	err = rs.etcdManager.Put(r.Context(), key, string(updatedJSON))
+	// The swap fails if another update landed after the read above
+	updatedJSON, err := encodeRecord(&pod)
+	if err == nil {
+		pod.ResourceVersion, err = rs.store.PutIfModRevision(r.Context(), key, updatedJSON, expected, 0)
+	}
+	if errors.Is(err, etcd.ErrRevisionConflict) {
+		http.Error(w, "Pod was modified; re-read it and retry", http.StatusConflict)
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
 	response := map[string]interface{}{
-		"pod":     existingPod,
+		"pod":     &pod,
 		"success": true,
 	}
 
@@ -257,10 +330,9 @@ func (rs *RESTServer) deletePodHandler(w http.ResponseWriter, r *http.Request) {
 		namespace = "default"
 	}
 
-	key := fmt.Sprintf("/pods/%s/%s", namespace, name)
-	err := rs.etcdManager.Delete(r.Context(), key)
+	err := rs.store.Delete(r.Context(), podKey(namespace, name))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
@@ -273,23 +345,39 @@ func (rs *RESTServer) deletePodHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // Snapshot handlers
+// listSnapshotsHandler lists snapshots sorted by created_at. Query parameters:
+// status filters on snapshot status, each label=key=value must match the
+// snapshot metadata, and order is "asc" (default) or "desc".
 func (rs *RESTServer) listSnapshotsHandler(w http.ResponseWriter, r *http.Request) {
-	prefix := "/snapshots/"
-	snapshots, err := rs.etcdManager.GetWithPrefix(r.Context(), prefix)
+	query := r.URL.Query()
+	descending, err := parseSortOrder(query.Get("order"))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	var snapshotList []map[string]interface{}
-	for key, value := range snapshots {
-		var snapshot map[string]interface{}
-		if err := json.Unmarshal([]byte(value), &snapshot); err != nil {
-			continue
+	filter := snapshotFilter{Status: query.Get("status"), Descending: descending}
+	for _, label := range query["label"] {
+		k, v, ok := strings.Cut(label, "=")
+		if !ok || k == "" {
+			http.Error(w, fmt.Sprintf("invalid label %q: must be key=value", label), http.StatusBadRequest)
+			return
 		}
-		snapshotList = append(snapshotList, snapshot)
+		if filter.Metadata == nil {
+			filter.Metadata = make(map[string]string)
+		}
+		filter.Metadata[k] = v
 	}
 
+	prefix := "/snapshots/"
+	snapshots, err := rs.store.GetKeyValuesWithPrefix(r.Context(), prefix)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	snapshotList := listSnapshots(snapshots, filter)
+
 	response := map[string]interface{}{
 		"snapshots": snapshotList,
 		"count":     len(snapshotList),
@@ -299,41 +387,44 @@ func (rs *RESTServer) listSnapshotsHandler(w http.ResponseWriter, r *http.Reques
 	json.NewEncoder(w).Encode(response)
 }
 
+// createSnapshotHandler takes a snapshot. With an Idempotency-Key header the
+// snapshot ID is derived from the key, and retries get the original response.
 func (rs *RESTServer) createSnapshotHandler(w http.ResponseWriter, r *http.Request) {
-	var req map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
-		return
+	idempotencyKey := r.Header.Get(idempotencyHeader)
+	var storeKey string
+	if idempotencyKey != "" {
+		storeKey = idempotencyStoreKey("snapshots", idempotencyKey)
+		if rs.replayIdempotent(w, r, storeKey) {
+			return
+		}
 	}
 
-	name, _ := req["name"].(string)
-	if name == "" {
-		name = fmt.Sprintf("snapshot-%d", time.Now().Unix())
+	var req struct {
+		Name     string            `json:"name"`
+		Metadata map[string]string `json:"metadata"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", errorStatus(err, http.StatusBadRequest))
+		return
 	}
 
 	// Create snapshot
-	snapshotData, err := rs.etcdManager.CreateSnapshot(r.Context())
+	snapshotData, err := rs.store.CreateSnapshot(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
-	snapshot := map[string]interface{}{
-		"id":            fmt.Sprintf("snap-%d", time.Now().Unix()),
-		"name":          name,
-		"status":        "completed",
-		"created_at":    time.Now().UTC().Format(time.RFC3339),
-		"size_bytes":    len(snapshotData),
-		"etcd_revision": "unknown", // Would need to get from etcd
-		"checksum":      "unknown", // Would compute hash
-		"metadata":      req["metadata"],
+	snapshot := newSnapshotRecord(req.Name, len(snapshotData), req.Metadata)
+	if idempotencyKey != "" {
+		snapshot.ID = idempotentID("snap", idempotencyKey)
+	}
+	snapshotJSON, err := encodeRecord(snapshot)
+	if err == nil {
+		err = rs.store.Put(r.Context(), snapshotKey(snapshot.ID), snapshotJSON)
 	}
-
-	snapshotJSON, _ := json.Marshal(snapshot)
-	key := fmt.Sprintf("/snapshots/%s", snapshot["id"])
-	err = rs.etcdManager.Put(r.Context(), key, string(snapshotJSON))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
@@ -341,6 +432,9 @@ func (rs *RESTServer) createSnapshotHandler(w http.ResponseWriter, r *http.Reque
 		"snapshot": snapshot,
 		"success":  true,
 	}
+	if storeKey != "" {
+		rs.saveIdempotent(r.Context(), storeKey, http.StatusCreated, response)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -351,21 +445,21 @@ func (rs *RESTServer) getSnapshotHandler(w http.ResponseWriter, r *http.Request)
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	key := fmt.Sprintf("/snapshots/%s", id)
-	snapshotJSON, err := rs.etcdManager.Get(r.Context(), key)
+	kv, err := rs.store.GetKeyValue(r.Context(), snapshotKey(id))
 	if err != nil {
 		http.Error(w, "Snapshot not found", http.StatusNotFound)
 		return
 	}
 
-	var snapshot map[string]interface{}
-	if err := json.Unmarshal([]byte(snapshotJSON), &snapshot); err != nil {
+	var snapshot snapshotRecord
+	if err := decodeRecord(kv.Value, &snapshot); err != nil {
 		http.Error(w, "Invalid snapshot data", http.StatusInternalServerError)
 		return
 	}
+	snapshot.ResourceVersion = kv.ModRevision
 
 	response := map[string]interface{}{
-		"snapshot": snapshot,
+		"snapshot": &snapshot,
 		"found":    true,
 	}
 
@@ -374,9 +468,6 @@ func (rs *RESTServer) getSnapshotHandler(w http.ResponseWriter, r *http.Request)
 }
 
 func (rs *RESTServer) restoreSnapshotHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
-
 	// This is a simplified implementation
 	// In production, you'd retrieve the snapshot data and restore it
 	response := map[string]interface{}{
@@ -393,10 +484,9 @@ func (rs *RESTServer) deleteSnapshotHandler(w http.ResponseWriter, r *http.Reque
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	key := fmt.Sprintf("/snapshots/%s", id)
-	err := rs.etcdManager.Delete(r.Context(), key)
+	err := rs.store.Delete(r.Context(), snapshotKey(id))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
@@ -411,19 +501,25 @@ func (rs *RESTServer) deleteSnapshotHandler(w http.ResponseWriter, r *http.Reque
 // Lease handlers
 func (rs *RESTServer) listLeasesHandler(w http.ResponseWriter, r *http.Request) {
 	prefix := "/leases/"
-	leases, err := rs.etcdManager.GetWithPrefix(r.Context(), prefix)
+	leases, err := rs.store.GetKeyValuesWithPrefix(r.Context(), prefix)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
-	var leaseList []map[string]interface{}
-	for key, value := range leases {
-		var lease map[string]interface{}
-		if err := json.Unmarshal([]byte(value), &lease); err != nil {
+	leaseList := []*leaseRecord{}
+	for _, kv := range leases {
+		var lease leaseRecord
+		if err := decodeRecord(kv.Value, &lease); err != nil {
 			continue
 		}
-		leaseList = append(leaseList, lease)
+		lease.ResourceVersion = kv.ModRevision
+		// Skip leases that expired between the prefix scan and the TTL lookup
+		alive, err := refreshLeaseTTL(r.Context(), rs.store, &lease)
+		if err != nil || !alive {
+			continue
+		}
+		leaseList = append(leaseList, &lease)
 	}
 
 	response := map[string]interface{}{
@@ -436,41 +532,40 @@ func (rs *RESTServer) listLeasesHandler(w http.ResponseWriter, r *http.Request)
 }
 
 func (rs *RESTServer) createLeaseHandler(w http.ResponseWriter, r *http.Request) {
-	var req map[string]interface{}
+	var req struct {
+		Holder     string            `json:"holder"`
+		TTLSeconds int64             `json:"ttl_seconds"`
+		Metadata   map[string]string `json:"metadata"`
+	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		http.Error(w, "Invalid JSON", errorStatus(err, http.StatusBadRequest))
 		return
 	}
 
-	holder, _ := req["holder"].(string)
-	ttlSeconds, _ := req["ttl_seconds"].(float64)
-
-	if holder == "" {
+	if req.Holder == "" {
 		http.Error(w, "Lease holder is required", http.StatusBadRequest)
 		return
 	}
 
-	if ttlSeconds <= 0 {
-		ttlSeconds = 30 // Default 30 seconds
+	if req.TTLSeconds <= 0 {
+		req.TTLSeconds = 30 // Default 30 seconds
 	}
 
-	now := time.Now().UTC()
-	expiresAt := now.Add(time.Duration(ttlSeconds) * time.Second)
-
-	lease := map[string]interface{}{
-		"id":         fmt.Sprintf("lease-%d", time.Now().Unix()),
-		"holder":     holder,
-		"ttl_seconds": ttlSeconds,
-		"granted_at": now.Format(time.RFC3339),
-		"expires_at": expiresAt.Format(time.RFC3339),
-		"metadata":   req["metadata"],
+	// Back the lease with a real etcd lease so the key disappears on expiry
+	leaseID, err := rs.store.GrantLease(r.Context(), req.TTLSeconds)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
 	}
 
-	leaseJSON, _ := json.Marshal(lease)
-	key := fmt.Sprintf("/leases/%s", lease["id"])
-	err := rs.etcdManager.Put(r.Context(), key, string(leaseJSON))
+	lease := newLeaseRecord(req.Holder, req.TTLSeconds, leaseID, req.Metadata)
+	leaseJSON, err := encodeRecord(lease)
+	if err == nil {
+		err = rs.store.PutWithLease(r.Context(), leaseKey(lease.ID), leaseJSON, leaseID)
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rs.store.RevokeLease(r.Context(), leaseID)
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
@@ -488,21 +583,31 @@ func (rs *RESTServer) getLeaseHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	key := fmt.Sprintf("/leases/%s", id)
-	leaseJSON, err := rs.etcdManager.Get(r.Context(), key)
+	kv, err := rs.store.GetKeyValue(r.Context(), leaseKey(id))
 	if err != nil {
 		http.Error(w, "Lease not found", http.StatusNotFound)
 		return
 	}
 
-	var lease map[string]interface{}
-	if err := json.Unmarshal([]byte(leaseJSON), &lease); err != nil {
+	var lease leaseRecord
+	if err := decodeRecord(kv.Value, &lease); err != nil {
 		http.Error(w, "Invalid lease data", http.StatusInternalServerError)
 		return
 	}
+	lease.ResourceVersion = kv.ModRevision
+
+	alive, err := refreshLeaseTTL(r.Context(), rs.store, &lease)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	if !alive {
+		http.Error(w, "Lease not found", http.StatusNotFound)
+		return
+	}
 
 	response := map[string]interface{}{
-		"lease": lease,
+		"lease": &lease,
 		"found": true,
 	}
 
@@ -510,47 +615,51 @@ func (rs *RESTServer) getLeaseHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// renewLeaseHandler extends a lease. A resource_version in the body is
+// optional; when given, the renewal is rejected with 409 if it is stale.
 func (rs *RESTServer) renewLeaseHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	key := fmt.Sprintf("/leases/%s", id)
+	key := leaseKey(id)
 
 	// Get existing lease
-	existingJSON, err := rs.etcdManager.Get(r.Context(), key)
+	existing, err := rs.store.GetKeyValue(r.Context(), key)
 	if err != nil {
 		http.Error(w, "Lease not found", http.StatusNotFound)
 		return
 	}
 
-	var lease map[string]interface{}
-	json.Unmarshal([]byte(existingJSON), &lease)
+	var lease leaseRecord
+	if err := decodeRecord(existing.Value, &lease); err != nil {
+		http.Error(w, "Invalid lease data", http.StatusInternalServerError)
+		return
+	}
+	lease.ResourceVersion = existing.ModRevision
 
 	// Parse request for new TTL
-	var req map[string]interface{}
-	json.NewDecoder(r.Body).Decode(&req)
-	newTTL, _ := req["ttl_seconds"].(float64)
-	if newTTL <= 0 {
-		newTTL = lease["ttl_seconds"].(float64)
+	var req struct {
+		TTLSeconds      int64 `json:"ttl_seconds"`
+		ResourceVersion int64 `json:"resource_version"`
 	}
+	json.NewDecoder(r.Body).Decode(&req)
 
-	// Update lease
-	now := time.Now().UTC()
-	expiresAt := now.Add(time.Duration(newTTL) * time.Second)
-
-	lease["ttl_seconds"] = newTTL
-	lease["granted_at"] = now.Format(time.RFC3339)
-	lease["expires_at"] = expiresAt.Format(time.RFC3339)
+	if req.ResourceVersion != 0 && req.ResourceVersion != lease.ResourceVersion {
+		http.Error(w, "Lease was modified; re-read it and retry", http.StatusConflict)
+		return
+	}
 
-	updatedJSON, _ := json.Marshal(lease)
-	err = rs.etcdManager.Put(r.Context(), key, string(updatedJSON))
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := renewLease(r.Context(), rs.store, key, &lease, req.TTLSeconds); err != nil {
+		if errors.Is(err, etcd.ErrRevisionConflict) {
+			http.Error(w, "Lease was modified; re-read it and retry", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Lease not found", http.StatusNotFound)
 		return
 	}
 
 	response := map[string]interface{}{
-		"lease":   lease,
+		"lease":   &lease,
 		"success": true,
 	}
 
@@ -562,10 +671,21 @@ func (rs *RESTServer) deleteLeaseHandler(w http.ResponseWriter, r *http.Request)
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	key := fmt.Sprintf("/leases/%s", id)
-	err := rs.etcdManager.Delete(r.Context(), key)
+	key := leaseKey(id)
+
+	// Revoking the backing etcd lease also deletes the key
+	if leaseJSON, err := rs.store.Get(r.Context(), key); err == nil {
+		var lease leaseRecord
+		if decodeRecord(leaseJSON, &lease) == nil {
+			if leaseID, err := lease.etcdLeaseID(); err == nil {
+				rs.store.RevokeLease(r.Context(), leaseID)
+			}
+		}
+	}
+
+	err := rs.store.Delete(r.Context(), key)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
@@ -581,12 +701,97 @@ func (rs *RESTServer) deleteLeaseHandler(w http.ResponseWriter, r *http.Request)
 func (rs *RESTServer) nodeInfoHandler(w http.ResponseWriter, r *http.Request) {
 	info := map[string]interface{}{
 		"node_id":      "node-1", // Would get from config
-		"version":     "0.1.0",
-		"is_leader":   rs.etcdManager.IsLeader(),
-		"leader_addr": rs.etcdManager.GetLeaderAddr(),
+		"version":     nodeVersion,
+		"is_leader":   rs.store.IsLeader(),
+		"leader_addr": rs.store.GetLeaderAddr(),
 		"address":     rs.server.Addr,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(info)
 }
+
+// Lease helpers shared by the REST and gRPC servers
+
+// leaseRecordID derives the public lease ID from the backing etcd lease ID
+func leaseRecordID(leaseID int64) string {
+	return fmt.Sprintf("lease-%x", leaseID)
+}
+
+// etcdLeaseID extracts the backing etcd lease ID from a stored lease record
+func (l *leaseRecord) etcdLeaseID() (int64, error) {
+	if l.EtcdLeaseID == "" {
+		return 0, fmt.Errorf("lease record has no etcd lease")
+	}
+	return strconv.ParseInt(l.EtcdLeaseID, 10, 64)
+}
+
+// setRemaining records the remaining TTL reported by etcd
+func (l *leaseRecord) setRemaining(remaining int64) {
+	l.RemainingSeconds = remaining
+	l.ExpiresAt = time.Now().UTC().Add(time.Duration(remaining) * time.Second).Format(time.RFC3339)
+}
+
+// refreshLeaseTTL fills in the remaining TTL reported by etcd, returning false if the lease has expired
+func refreshLeaseTTL(ctx context.Context, store etcd.Store, lease *leaseRecord) (bool, error) {
+	leaseID, err := lease.etcdLeaseID()
+	if err != nil {
+		return false, err
+	}
+
+	remaining, err := store.LeaseTimeToLive(ctx, leaseID)
+	if err != nil {
+		return false, err
+	}
+	if remaining <= 0 {
+		return false, nil
+	}
+
+	lease.setRemaining(remaining)
+	return true, nil
+}
+
+// renewLease extends a lease and updates its remaining TTL. etcd leases have a fixed
+// TTL, so a renewal with a different TTL grants a fresh lease, re-attaches the record to
+// it, and revokes the old one; otherwise the existing lease is kept alive once. The
+// re-attach only succeeds if the record is still at lease.ResourceVersion.
+func renewLease(ctx context.Context, store etcd.Store, key string, lease *leaseRecord, newTTL int64) error {
+	leaseID, err := lease.etcdLeaseID()
+	if err != nil {
+		return err
+	}
+
+	lease.GrantedAt = timestamp()
+
+	if newTTL <= 0 || newTTL == lease.TTLSeconds {
+		remaining, err := store.KeepAliveOnce(ctx, leaseID)
+		if err != nil {
+			return err
+		}
+		lease.setRemaining(remaining)
+		return nil
+	}
+
+	newLeaseID, err := store.GrantLease(ctx, newTTL)
+	if err != nil {
+		return err
+	}
+
+	lease.TTLSeconds = newTTL
+	lease.EtcdLeaseID = strconv.FormatInt(newLeaseID, 10)
+	lease.setRemaining(newTTL)
+
+	expected := lease.ResourceVersion
+	lease.ResourceVersion = 0
+	data, err := encodeRecord(lease)
+	if err == nil {
+		lease.ResourceVersion, err = store.PutIfModRevision(ctx, key, data, expected, newLeaseID)
+	}
+	if err != nil {
+		store.RevokeLease(ctx, newLeaseID)
+		return err
+	}
+	store.RevokeLease(ctx, leaseID)
+
+	return nil
+}
@@ -0,0 +1,404 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/decube/decube/internal/etcd"
+	"github.com/decube/decube/pkg/config"
+)
+
+// freeAddr returns a loopback address with a port that is currently unused
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve port: %v", err)
+	}
+	defer lis.Close()
+	return lis.Addr().String()
+}
+
+// newTestEtcdManager starts a single-member embedded etcd in a temp directory.
+// Integration tests use it; everything else runs on etcd.NewMemoryStore.
+func newTestEtcdManager(t *testing.T) *etcd.EtcdManager {
+	t.Helper()
+
+	dir := t.TempDir()
+	cfg := config.DefaultConfig()
+	cfg.Node.ListenAddress = freeAddr(t)
+	cfg.Node.PeerAddresses = nil
+	cfg.Etcd.PeerAddress = freeAddr(t)
+	cfg.Etcd.DataDir = filepath.Join(dir, "etcd")
+	cfg.Etcd.WalDir = filepath.Join(dir, "etcd", "wal")
+
+	em := etcd.NewEtcdManager(cfg)
+	if err := em.Start(); err != nil {
+		t.Fatalf("failed to start etcd: %v", err)
+	}
+	t.Cleanup(func() { em.Stop() })
+
+	return em
+}
+
+func doRequest(rs *RESTServer, method, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	rs.router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestLeaseExpiresAfterTTL(t *testing.T) {
+	rs := NewRESTServer(newTestEtcdManager(t), "127.0.0.1:0")
+
+	rec := doRequest(rs, http.MethodPost, "/api/v1/leases", `{"holder":"test","ttl_seconds":2}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create lease: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var created struct {
+		Lease map[string]interface{} `json:"lease"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	id, _ := created.Lease["id"].(string)
+
+	rec = doRequest(rs, http.MethodGet, "/api/v1/leases/"+id, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get live lease: expected 200, got %d", rec.Code)
+	}
+
+	time.Sleep(4 * time.Second)
+
+	rec = doRequest(rs, http.MethodGet, "/api/v1/leases/"+id, "")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("get expired lease: expected 404, got %d", rec.Code)
+	}
+}
+
+func TestListSnapshotsFilterAndSort(t *testing.T) {
+	store := etcd.NewMemoryStore()
+	rs := NewRESTServer(store, "127.0.0.1:0")
+
+	for _, s := range []snapshotRecord{
+		{ID: "snap-1", Status: "completed", CreatedAt: "2024-01-01T00:00:00Z", Metadata: map[string]string{"env": "prod"}},
+		{ID: "snap-2", Status: "failed", CreatedAt: "2024-01-02T00:00:00Z", Metadata: map[string]string{"env": "prod"}},
+		{ID: "snap-3", Status: "completed", CreatedAt: "2024-01-03T00:00:00Z", Metadata: map[string]string{"env": "dev"}},
+		{ID: "snap-4", Status: "completed", CreatedAt: "2024-01-04T00:00:00Z", Metadata: map[string]string{"env": "prod"}},
+	} {
+		data, err := encodeRecord(&s)
+		if err != nil {
+			t.Fatalf("failed to encode snapshot: %v", err)
+		}
+		if err := store.Put(context.Background(), snapshotKey(s.ID), data); err != nil {
+			t.Fatalf("failed to store snapshot: %v", err)
+		}
+	}
+
+	list := func(query string) []string {
+		t.Helper()
+		rec := doRequest(rs, http.MethodGet, "/api/v1/snapshots"+query, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list %q: expected 200, got %d: %s", query, rec.Code, rec.Body.String())
+		}
+		var resp struct {
+			Snapshots []snapshotRecord `json:"snapshots"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		var ids []string
+		for _, s := range resp.Snapshots {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"snap-1", "snap-2", "snap-3", "snap-4"}},
+		{"?order=desc", []string{"snap-4", "snap-3", "snap-2", "snap-1"}},
+		{"?status=completed&label=env=prod&order=desc", []string{"snap-4", "snap-1"}},
+		{"?status=failed", []string{"snap-2"}},
+	}
+	for _, c := range cases {
+		if got := list(c.query); !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("list %q: got %v, want %v", c.query, got, c.want)
+		}
+	}
+
+	if rec := doRequest(rs, http.MethodGet, "/api/v1/snapshots?order=sideways", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid order: expected 400, got %d", rec.Code)
+	}
+}
+
+func TestOversizedBodyRejected(t *testing.T) {
+	rs := NewRESTServer(etcd.NewMemoryStore(), "127.0.0.1:0")
+	rs.EnableRequestLimits(RequestLimitConfig{MaxBodyBytes: 32, Timeout: time.Second})
+
+	body := `{"name":"web","namespace":"default","labels":{"app":"a-rather-long-label-value"}}`
+	rec := doRequest(rs, http.MethodPost, "/api/v1/pods", body)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized pod: expected 413, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Bodies of unknown length are cut off while the handler decodes them
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/pods", strings.NewReader(body))
+	req.ContentLength = -1
+	rec = httptest.NewRecorder()
+	rs.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized streamed pod: expected 413, got %d", rec.Code)
+	}
+
+	if rec := doRequest(rs, http.MethodPost, "/api/v1/pods", `{"name":"web"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("pod under the limit: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestIdempotentSnapshotCreate(t *testing.T) {
+	rs := NewRESTServer(etcd.NewMemoryStore(), "127.0.0.1:0")
+
+	create := func(key string) (int, string, string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/snapshots", strings.NewReader(`{"name":"nightly"}`))
+		req.Header.Set("Idempotency-Key", key)
+		rec := httptest.NewRecorder()
+		rs.router.ServeHTTP(rec, req)
+
+		var resp struct {
+			Snapshot snapshotRecord `json:"snapshot"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return rec.Code, resp.Snapshot.ID, rec.Header().Get("Idempotent-Replayed")
+	}
+
+	code, first, replayed := create("nightly-2024-01-01")
+	if code != http.StatusCreated || replayed != "" {
+		t.Fatalf("first create: got %d (replayed %q)", code, replayed)
+	}
+	code, second, replayed := create("nightly-2024-01-01")
+	if code != http.StatusCreated || replayed != "true" {
+		t.Fatalf("retried create: got %d (replayed %q)", code, replayed)
+	}
+	if first != second {
+		t.Fatalf("retry returned a different snapshot: %s != %s", first, second)
+	}
+
+	rec := doRequest(rs, http.MethodGet, "/api/v1/snapshots", "")
+	var list struct {
+		Count int `json:"count"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("failed to decode list: %v", err)
+	}
+	if list.Count != 1 {
+		t.Fatalf("expected 1 snapshot after a retried create, got %d", list.Count)
+	}
+
+	if _, other, _ := create("nightly-2024-01-02"); other == first {
+		t.Fatalf("different keys produced the same snapshot ID %s", other)
+	}
+}
+
+func TestPodHandlersWithMemoryStore(t *testing.T) {
+	rs := NewRESTServer(etcd.NewMemoryStore(), "127.0.0.1:0")
+
+	version := createTestPod(t, rs, `{"name":"web","labels":{"app":"web"}}`)
+	createTestPod(t, rs, `{"name":"db","namespace":"data"}`)
+
+	if rec := doRequest(rs, http.MethodPost, "/api/v1/pods", `{"name":"web"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("create existing pod: expected 409, got %d", rec.Code)
+	}
+	if rec := doRequest(rs, http.MethodPut, "/api/v1/pods/web", `{"status":"Running"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("update without resource_version: expected 400, got %d", rec.Code)
+	}
+
+	body := fmt.Sprintf(`{"status":"Running","node_name":"node-1","resource_version":%d}`, version)
+	rec := doRequest(rs, http.MethodPut, "/api/v1/pods/web", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update pod: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRequest(rs, http.MethodGet, "/api/v1/pods/web", "")
+	var got struct {
+		Pod podRecord `json:"pod"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode pod: %v", err)
+	}
+	if got.Pod.Status != "Running" || got.Pod.NodeName != "node-1" || got.Pod.Labels["app"] != "web" {
+		t.Fatalf("update was not applied: %+v", got.Pod)
+	}
+	if got.Pod.ResourceVersion <= version {
+		t.Fatalf("expected resource_version to advance past %d, got %d", version, got.Pod.ResourceVersion)
+	}
+
+	rec = doRequest(rs, http.MethodGet, "/api/v1/pods", "")
+	var list struct {
+		Count int `json:"count"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("failed to decode list: %v", err)
+	}
+	if list.Count != 1 {
+		t.Fatalf("expected 1 pod in the default namespace, got %d", list.Count)
+	}
+
+	if rec := doRequest(rs, http.MethodDelete, "/api/v1/pods/web", ""); rec.Code != http.StatusOK {
+		t.Fatalf("delete pod: expected 200, got %d", rec.Code)
+	}
+	if rec := doRequest(rs, http.MethodGet, "/api/v1/pods/web", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("get deleted pod: expected 404, got %d", rec.Code)
+	}
+}
+
+// createTestPod creates a pod through the REST API and returns its resource version
+func createTestPod(t *testing.T, rs *RESTServer, body string) int64 {
+	t.Helper()
+	rec := doRequest(rs, http.MethodPost, "/api/v1/pods", body)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create pod: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created struct {
+		Pod podRecord `json:"pod"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode pod: %v", err)
+	}
+	if created.Pod.ResourceVersion == 0 {
+		t.Fatalf("created pod has no resource_version")
+	}
+	return created.Pod.ResourceVersion
+}
+
+func TestConcurrentPodUpdatesConflict(t *testing.T) {
+	rs := NewRESTServer(etcd.NewMemoryStore(), "127.0.0.1:0")
+	version := createTestPod(t, rs, `{"name":"web"}`)
+
+	// Both writers start from the same version; only one may win
+	codes := make(chan int, 2)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, node := range []string{"node-1", "node-2"} {
+		wg.Add(1)
+		go func(node string) {
+			defer wg.Done()
+			<-start
+			body := fmt.Sprintf(`{"node_name":%q,"resource_version":%d}`, node, version)
+			codes <- doRequest(rs, http.MethodPut, "/api/v1/pods/web", body).Code
+		}(node)
+	}
+	close(start)
+	wg.Wait()
+	close(codes)
+
+	counts := map[int]int{}
+	for code := range codes {
+		counts[code]++
+	}
+	if counts[http.StatusOK] != 1 || counts[http.StatusConflict] != 1 {
+		t.Fatalf("expected one 200 and one 409, got %v", counts)
+	}
+}
+
+func TestLeaseHandlersWithMemoryStore(t *testing.T) {
+	now := time.Now()
+	rs := NewRESTServer(etcd.NewMemoryStoreWithClock(func() time.Time { return now }), "127.0.0.1:0")
+
+	rec := doRequest(rs, http.MethodPost, "/api/v1/leases", `{"holder":"test","ttl_seconds":10}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create lease: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created struct {
+		Lease leaseRecord `json:"lease"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	id := created.Lease.ID
+
+	// Renewing halfway through keeps the lease past its original expiry
+	now = now.Add(6 * time.Second)
+	if rec := doRequest(rs, http.MethodPost, "/api/v1/leases/"+id+"/renew", `{}`); rec.Code != http.StatusOK {
+		t.Fatalf("renew lease: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	now = now.Add(6 * time.Second)
+	if rec := doRequest(rs, http.MethodGet, "/api/v1/leases/"+id, ""); rec.Code != http.StatusOK {
+		t.Fatalf("get renewed lease: expected 200, got %d", rec.Code)
+	}
+
+	now = now.Add(5 * time.Second)
+	if rec := doRequest(rs, http.MethodGet, "/api/v1/leases/"+id, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("get expired lease: expected 404, got %d", rec.Code)
+	}
+	rec = doRequest(rs, http.MethodGet, "/api/v1/leases", "")
+	var list struct {
+		Count int `json:"count"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("failed to decode list: %v", err)
+	}
+	if list.Count != 0 {
+		t.Fatalf("expected no live leases, got %d", list.Count)
+	}
+}
+
+func TestListPodsLabelSelector(t *testing.T) {
+	rs := NewRESTServer(etcd.NewMemoryStore(), "127.0.0.1:0")
+	createTestPod(t, rs, `{"name":"web-1","labels":{"app":"web","tier":"frontend"}}`)
+	createTestPod(t, rs, `{"name":"web-2","labels":{"app":"web","tier":"cache"}}`)
+	createTestPod(t, rs, `{"name":"db-1","labels":{"app":"db","tier":"backend"}}`)
+
+	list := func(selector string) []string {
+		t.Helper()
+		rec := doRequest(rs, http.MethodGet, "/api/v1/pods?labelSelector="+url.QueryEscape(selector), "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list with %q: expected 200, got %d: %s", selector, rec.Code, rec.Body.String())
+		}
+		var resp struct {
+			Pods []podRecord `json:"pods"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		names := []string{}
+		for _, p := range resp.Pods {
+			names = append(names, p.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	for selector, want := range map[string][]string{
+		"app=web,tier=frontend":      {"web-1"},
+		"app=web,tier!=frontend":     {"web-2"},
+		"tier in (frontend,backend)": {"db-1", "web-1"},
+		"app notin (web)":            {"db-1"},
+		"":                           {"db-1", "web-1", "web-2"},
+	} {
+		if got := list(selector); !reflect.DeepEqual(got, want) {
+			t.Errorf("selector %q returned %v, want %v", selector, got, want)
+		}
+	}
+
+	if rec := doRequest(rs, http.MethodGet, "/api/v1/pods?labelSelector="+url.QueryEscape("app in web"), ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("malformed selector: expected 400, got %d", rec.Code)
+	}
+}
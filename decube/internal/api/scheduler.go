@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/decube/decube/internal/etcd"
+	"github.com/decube/decube/pkg/config"
+)
+
+// Scheduler assigns pods without a node_name to ready nodes, picking the
+// node with the fewest pods. Only the etcd leader schedules, so a cluster
+// never has two schedulers racing over the same pods.
+type Scheduler struct {
+	store    etcd.Store
+	interval time.Duration
+}
+
+// NewScheduler creates a scheduler that, on top of reacting to watch events,
+// retries unscheduled pods every cfg.ScheduleInterval
+func NewScheduler(store etcd.Store, cfg config.ControllerConfig) *Scheduler {
+	return &Scheduler{store: store, interval: cfg.ScheduleInterval}
+}
+
+// Run schedules pods until ctx is done
+func (s *Scheduler) Run(ctx context.Context) {
+	pods := s.store.Watch(ctx, "/pods/")
+	heartbeats := s.store.Watch(ctx, "/heartbeats/")
+
+	var tick <-chan time.Time
+	if s.interval > 0 {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	s.scheduleOnce(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-pods:
+			if !ok {
+				return
+			}
+			if ev.Type == etcd.EventPut {
+				s.scheduleOnce(ctx)
+			}
+		case _, ok := <-heartbeats:
+			if !ok {
+				return
+			}
+			s.scheduleOnce(ctx)
+		case <-tick:
+			s.scheduleOnce(ctx)
+		}
+	}
+}
+
+// scheduleOnce assigns every unscheduled pod to the least-loaded ready node,
+// breaking ties by node ID. Followers do nothing.
+func (s *Scheduler) scheduleOnce(ctx context.Context) {
+	if !s.store.IsLeader() {
+		return
+	}
+
+	nodes, err := listNodes(ctx, s.store)
+	if err != nil {
+		log.Printf("Scheduler: failed to list nodes: %v", err)
+		return
+	}
+	load := make(map[string]int)
+	for _, node := range nodes {
+		if node.Ready {
+			load[node.ID] = 0
+		}
+	}
+	if len(load) == 0 {
+		return
+	}
+
+	pods, err := s.store.GetKeyValuesWithPrefix(ctx, "/pods/")
+	if err != nil {
+		log.Printf("Scheduler: failed to list pods: %v", err)
+		return
+	}
+
+	var unscheduled []string
+	for key, kv := range pods {
+		var pod podRecord
+		if err := decodeRecord(kv.Value, &pod); err != nil {
+			continue
+		}
+		if pod.NodeName == "" {
+			unscheduled = append(unscheduled, key)
+		} else if _, ok := load[pod.NodeName]; ok {
+			load[pod.NodeName]++
+		}
+	}
+	sort.Strings(unscheduled)
+
+	for _, key := range unscheduled {
+		node := leastLoaded(load)
+		err := s.bind(ctx, key, pods[key], node)
+		if errors.Is(err, etcd.ErrRevisionConflict) {
+			continue // changed since the read; the watch brings it back
+		}
+		if err != nil {
+			log.Printf("Scheduler: failed to bind pod %s to %s: %v", key, node, err)
+			continue
+		}
+		load[node]++
+	}
+}
+
+// bind assigns the pod at key to node, unless the pod changed since it was read
+func (s *Scheduler) bind(ctx context.Context, key string, kv etcd.KeyValue, node string) error {
+	var pod podRecord
+	if err := decodeRecord(kv.Value, &pod); err != nil {
+		return err
+	}
+	pod.NodeName = node
+	pod.Status = podPending
+	pod.UpdatedAt = timestamp()
+	pod.ResourceVersion = 0
+
+	data, err := encodeRecord(&pod)
+	if err != nil {
+		return err
+	}
+	_, err = s.store.PutIfModRevision(ctx, key, data, kv.ModRevision, 0)
+	return err
+}
+
+// leastLoaded returns the node with the fewest pods, breaking ties by ID
+func leastLoaded(load map[string]int) string {
+	best := ""
+	for node, n := range load {
+		if best == "" || n < load[best] || (n == load[best] && node < best) {
+			best = node
+		}
+	}
+	return best
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/decube/decube/internal/etcd"
+	"github.com/decube/decube/pkg/config"
+)
+
+// followerStore is a store on a node that is not the etcd leader; leader is
+// the etcd client address of the node that is
+type followerStore struct {
+	etcd.Store
+	leader string
+}
+
+func (followerStore) IsLeader() bool          { return false }
+func (f followerStore) GetLeaderAddr() string { return f.leader }
+
+func TestSchedulerSpreadsPodsAcrossLiveNodes(t *testing.T) {
+	ctx := context.Background()
+	store := etcd.NewMemoryStore()
+	rs := NewRESTServer(store, "127.0.0.1:0")
+
+	for _, id := range []string{"node-a", "node-b"} {
+		if rec := doRequest(rs, http.MethodPost, "/api/v1/nodes", fmt.Sprintf(`{"id":%q}`, id)); rec.Code != http.StatusCreated {
+			t.Fatalf("register %s: expected 201, got %d", id, rec.Code)
+		}
+	}
+	// A registered node whose heartbeat lease is gone gets nothing
+	dead, _ := encodeRecord(&nodeRecord{ID: "node-c", TTLSeconds: 10})
+	store.Put(ctx, nodeKey("node-c"), dead)
+
+	for i := 0; i < 5; i++ {
+		createTestPod(t, rs, fmt.Sprintf(`{"name":"pod-%d"}`, i))
+	}
+	createTestPod(t, rs, `{"name":"pinned","node_name":"node-a"}`)
+
+	// Followers leave the pods alone
+	NewScheduler(followerStore{Store: store}, config.ControllerConfig{}).scheduleOnce(ctx)
+	pods, _ := store.GetKeyValuesWithPrefix(ctx, "/pods/")
+	for key, kv := range pods {
+		var pod podRecord
+		decodeRecord(kv.Value, &pod)
+		if pod.Name != "pinned" && pod.NodeName != "" {
+			t.Fatalf("follower scheduled %s onto %s", key, pod.NodeName)
+		}
+	}
+
+	NewScheduler(store, config.ControllerConfig{}).scheduleOnce(ctx)
+
+	counts := map[string]int{}
+	pods, _ = store.GetKeyValuesWithPrefix(ctx, "/pods/")
+	for key, kv := range pods {
+		var pod podRecord
+		decodeRecord(kv.Value, &pod)
+		if pod.NodeName == "" {
+			t.Fatalf("pod %s was not scheduled", key)
+		}
+		counts[pod.NodeName]++
+	}
+	if counts["node-a"] != 3 || counts["node-b"] != 3 || counts["node-c"] != 0 {
+		t.Fatalf("expected 3 pods on each live node and none on node-c, got %v", counts)
+	}
+}
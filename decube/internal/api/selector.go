@@ -0,0 +1,173 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// labelSelector filters records by their labels, using the Kubernetes
+// selector syntax: comma-separated requirements that must all match.
+// Supported forms are key=value, key==value, key!=value, key in (a,b),
+// key notin (a,b), key (label present) and !key (label absent).
+type labelSelector []labelRequirement
+
+type selectorOp int
+
+const (
+	opEquals selectorOp = iota
+	opNotEquals
+	opIn
+	opNotIn
+	opExists
+	opDoesNotExist
+)
+
+type labelRequirement struct {
+	Key    string
+	Op     selectorOp
+	Values []string
+}
+
+// parseLabelSelector parses a selector string. An empty string selects everything.
+func parseLabelSelector(s string) (labelSelector, error) {
+	var selector labelSelector
+	for _, term := range splitSelector(s) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			return nil, fmt.Errorf("invalid label selector %q: empty requirement", s)
+		}
+		req, err := parseRequirement(term)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label selector %q: %v", s, err)
+		}
+		selector = append(selector, req)
+	}
+	return selector, nil
+}
+
+// splitSelector splits on commas outside parentheses, so set values stay together
+func splitSelector(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	var terms []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(terms, s[start:])
+}
+
+func parseRequirement(term string) (labelRequirement, error) {
+	if strings.HasPrefix(term, "!") {
+		key := strings.TrimSpace(term[1:])
+		if !validLabelKey(key) {
+			return labelRequirement{}, fmt.Errorf("invalid key %q", key)
+		}
+		return labelRequirement{Key: key, Op: opDoesNotExist}, nil
+	}
+
+	for _, eq := range []struct {
+		token string
+		op    selectorOp
+	}{{"!=", opNotEquals}, {"==", opEquals}, {"=", opEquals}} {
+		key, value, ok := strings.Cut(term, eq.token)
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !validLabelKey(key) {
+			return labelRequirement{}, fmt.Errorf("invalid key %q", key)
+		}
+		if strings.ContainsAny(value, "=!() ") {
+			return labelRequirement{}, fmt.Errorf("invalid value %q", value)
+		}
+		return labelRequirement{Key: key, Op: eq.op, Values: []string{value}}, nil
+	}
+
+	if open := strings.IndexByte(term, '('); open >= 0 {
+		fields := strings.Fields(term[:open])
+		if len(fields) != 2 || !strings.HasSuffix(term, ")") {
+			return labelRequirement{}, fmt.Errorf("malformed set requirement %q", term)
+		}
+		var op selectorOp
+		switch fields[1] {
+		case "in":
+			op = opIn
+		case "notin":
+			op = opNotIn
+		default:
+			return labelRequirement{}, fmt.Errorf("unknown operator %q", fields[1])
+		}
+		if !validLabelKey(fields[0]) {
+			return labelRequirement{}, fmt.Errorf("invalid key %q", fields[0])
+		}
+
+		var values []string
+		for _, v := range strings.Split(term[open+1:len(term)-1], ",") {
+			v = strings.TrimSpace(v)
+			if v == "" || strings.ContainsAny(v, "=!() ") {
+				return labelRequirement{}, fmt.Errorf("invalid value %q in %q", v, term)
+			}
+			values = append(values, v)
+		}
+		return labelRequirement{Key: fields[0], Op: op, Values: values}, nil
+	}
+
+	if !validLabelKey(term) {
+		return labelRequirement{}, fmt.Errorf("invalid key %q", term)
+	}
+	return labelRequirement{Key: term, Op: opExists}, nil
+}
+
+func validLabelKey(key string) bool {
+	return key != "" && !strings.ContainsAny(key, "=!(), ")
+}
+
+// matches reports whether labels satisfy every requirement
+func (s labelSelector) matches(labels map[string]string) bool {
+	for _, req := range s {
+		if !req.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r labelRequirement) matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+	switch r.Op {
+	case opEquals:
+		return ok && value == r.Values[0]
+	case opNotEquals:
+		return !ok || value != r.Values[0]
+	case opIn:
+		return ok && containsString(r.Values, value)
+	case opNotIn:
+		return !ok || !containsString(r.Values, value)
+	case opExists:
+		return ok
+	case opDoesNotExist:
+		return !ok
+	}
+	return false
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package api
+
+import "testing"
+
+func TestParseLabelSelector(t *testing.T) {
+	labels := map[string]string{"app": "web", "tier": "frontend", "env": "prod"}
+
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{"", true},
+		{"app=web", true},
+		{"app==web,tier=frontend", true},
+		{"app=db", false},
+		{"tier!=backend", true},
+		{"tier!=frontend", false},
+		{"missing!=x", true},
+		{"env in (prod, staging)", true},
+		{"env notin (prod,staging)", false},
+		{"app, !canary", true},
+		{"canary", false},
+		{"!app", false},
+	}
+	for _, tt := range tests {
+		selector, err := parseLabelSelector(tt.selector)
+		if err != nil {
+			t.Fatalf("parseLabelSelector(%q) failed: %v", tt.selector, err)
+		}
+		if got := selector.matches(labels); got != tt.want {
+			t.Errorf("%q matches = %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"=web", "app=web,", "env in prod", "env within (a)", "env in (a,)", "a=b=c", "!"} {
+		if _, err := parseLabelSelector(bad); err == nil {
+			t.Errorf("parseLabelSelector(%q) succeeded, want an error", bad)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/decube/decube/internal/etcd"
+	"github.com/decube/decube/pkg/config"
+)
+
+// scheduledTrigger marks snapshots taken by the scheduler in their metadata.
+// Only these count towards the retention limit; snapshots taken through the
+// API are kept until deleted.
+const scheduledTrigger = "scheduled"
+
+// scheduledTimeFormat is RFC 3339 with fixed-width nanoseconds, so creation
+// times of snapshots taken within a second of each other still sort
+const scheduledTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// SnapshotScheduler takes a snapshot every interval and keeps the newest
+// ones. Only the etcd leader takes snapshots, so a cluster takes one per
+// interval rather than one per node.
+type SnapshotScheduler struct {
+	store etcd.Store
+	cfg   config.SnapshotConfig
+}
+
+// NewSnapshotScheduler creates a scheduler that writes snapshots to cfg.Dir
+func NewSnapshotScheduler(store etcd.Store, cfg config.SnapshotConfig) *SnapshotScheduler {
+	return &SnapshotScheduler{store: store, cfg: cfg}
+}
+
+// Run takes snapshots until ctx is done
+func (s *SnapshotScheduler) Run(ctx context.Context) {
+	if s.cfg.Interval <= 0 {
+		log.Printf("Snapshot scheduler: interval %v is not positive; not scheduling snapshots", s.cfg.Interval)
+		return
+	}
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.snapshotOnce(ctx)
+		}
+	}
+}
+
+// snapshotOnce takes and stores a snapshot, then prunes old ones. Followers
+// do nothing.
+func (s *SnapshotScheduler) snapshotOnce(ctx context.Context) {
+	if !s.store.IsLeader() {
+		return
+	}
+
+	start := time.Now()
+	snapshot, err := s.takeSnapshot(ctx)
+	if err != nil {
+		snapshotsTaken.WithLabelValues("failure").Inc()
+		log.Printf("Snapshot scheduler: failed to take snapshot: %v", err)
+		return
+	}
+	snapshotsTaken.WithLabelValues("success").Inc()
+	snapshotDuration.Observe(time.Since(start).Seconds())
+	snapshotSize.Set(float64(snapshot.SizeBytes))
+	snapshotLastSuccess.SetToCurrentTime()
+	log.Printf("Snapshot scheduler: took snapshot %s (%d bytes)", snapshot.ID, snapshot.SizeBytes)
+
+	if err := s.prune(ctx); err != nil {
+		log.Printf("Snapshot scheduler: failed to prune snapshots: %v", err)
+	}
+}
+
+// takeSnapshot writes a snapshot of the store to the snapshot directory and
+// records its metadata under /snapshots/
+func (s *SnapshotScheduler) takeSnapshot(ctx context.Context) (*snapshotRecord, error) {
+	data, err := s.store.CreateSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ext := ".db"
+	if s.cfg.Compression {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		data = buf.Bytes()
+		ext = ".db.gz"
+	}
+
+	now := time.Now()
+	id := fmt.Sprintf("snap-auto-%d", now.UnixNano())
+	path := filepath.Join(s.cfg.Dir, id+ext)
+	if err := writeFileAtomic(path, data); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	snapshot := newSnapshotRecord(fmt.Sprintf("scheduled-%s", now.UTC().Format("20060102T150405Z")), len(data), map[string]string{
+		"trigger": scheduledTrigger,
+		"path":    path,
+	})
+	snapshot.ID = id
+	snapshot.CreatedAt = now.UTC().Format(scheduledTimeFormat)
+	snapshot.Checksum = "sha256:" + hex.EncodeToString(sum[:])
+
+	record, err := encodeRecord(snapshot)
+	if err == nil {
+		err = s.store.Put(ctx, snapshotKey(id), record)
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// prune deletes the oldest scheduled snapshots, files first, until at most
+// the retention count remain. A retention count of zero keeps everything.
+func (s *SnapshotScheduler) prune(ctx context.Context) error {
+	if s.cfg.RetentionCount <= 0 {
+		return nil
+	}
+
+	stored, err := s.store.GetKeyValuesWithPrefix(ctx, "/snapshots/")
+	if err != nil {
+		return err
+	}
+
+	type scheduled struct {
+		key      string
+		path     string
+		revision int64
+	}
+	var snapshots []scheduled
+	for key, kv := range stored {
+		var snapshot snapshotRecord
+		if err := decodeRecord(kv.Value, &snapshot); err != nil || snapshot.Metadata["trigger"] != scheduledTrigger {
+			continue
+		}
+		snapshots = append(snapshots, scheduled{key: key, path: snapshot.Metadata["path"], revision: kv.ModRevision})
+	}
+	if len(snapshots) <= s.cfg.RetentionCount {
+		return nil
+	}
+
+	// Records are written once, so the revision orders them by age
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].revision < snapshots[j].revision })
+	for _, old := range snapshots[:len(snapshots)-s.cfg.RetentionCount] {
+		if old.path != "" {
+			if err := os.Remove(old.path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := s.store.Delete(ctx, old.key); err != nil {
+			return err
+		}
+		snapshotsPruned.Inc()
+		log.Printf("Snapshot scheduler: pruned snapshot %s", old.key)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to path through a temporary file, so a crash
+// never leaves a partial snapshot under the final name
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".snapshot-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
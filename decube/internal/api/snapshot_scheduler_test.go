@@ -0,0 +1,137 @@
+package api
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decube/decube/internal/etcd"
+	"github.com/decube/decube/pkg/config"
+)
+
+// scheduledSnapshots returns the scheduled snapshot records, oldest first
+func scheduledSnapshots(t *testing.T, store etcd.Store) []snapshotRecord {
+	t.Helper()
+	stored, err := store.GetKeyValuesWithPrefix(context.Background(), "/snapshots/")
+	if err != nil {
+		t.Fatalf("failed to list snapshots: %v", err)
+	}
+	var snapshots []snapshotRecord
+	for _, kv := range stored {
+		var snapshot snapshotRecord
+		if err := decodeRecord(kv.Value, &snapshot); err != nil {
+			t.Fatalf("invalid snapshot record: %v", err)
+		}
+		if snapshot.Metadata["trigger"] == scheduledTrigger {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].CreatedAt < snapshots[j].CreatedAt })
+	return snapshots
+}
+
+func TestSnapshotSchedulerTakesAndPrunesSnapshots(t *testing.T) {
+	store := etcd.NewMemoryStore()
+	rs := NewRESTServer(store, "127.0.0.1:0")
+	dir := t.TempDir()
+
+	store.Put(context.Background(), "/pods/default/web", `{"name":"web"}`)
+	if rec := doRequest(rs, http.MethodPost, "/api/v1/snapshots", `{"name":"manual"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("manual snapshot: expected 201, got %d", rec.Code)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewSnapshotScheduler(store, config.SnapshotConfig{
+			Interval:       10 * time.Millisecond,
+			RetentionCount: 2,
+			Compression:    true,
+			Dir:            dir,
+		}).Run(ctx)
+		close(done)
+	}()
+
+	// Wait until the first scheduled snapshot has been pruned
+	deadline := time.Now().Add(5 * time.Second)
+	first := ""
+	for {
+		snapshots := scheduledSnapshots(t, store)
+		if first == "" && len(snapshots) > 0 {
+			first = snapshots[0].ID
+		}
+		if first != "" && len(snapshots) > 0 && snapshots[0].ID != first {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("first snapshot %q was never pruned", first)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	snapshots := scheduledSnapshots(t, store)
+	if len(snapshots) != 2 {
+		t.Fatalf("expected 2 scheduled snapshots after pruning, got %d", len(snapshots))
+	}
+	for _, snapshot := range snapshots {
+		path := snapshot.Metadata["path"]
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("snapshot %s file: %v", snapshot.ID, err)
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("snapshot %s is not gzipped: %v", snapshot.ID, err)
+		}
+		var state map[string]string
+		if err := json.NewDecoder(zr).Decode(&state); err != nil {
+			t.Fatalf("snapshot %s does not decode: %v", snapshot.ID, err)
+		}
+		f.Close()
+		if state["/pods/default/web"] == "" {
+			t.Fatalf("snapshot %s is missing the pod: %v", snapshot.ID, state)
+		}
+		if !strings.HasPrefix(snapshot.Checksum, "sha256:") {
+			t.Fatalf("snapshot %s has checksum %q", snapshot.ID, snapshot.Checksum)
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.db.gz"))
+	if len(files) != 2 {
+		t.Fatalf("expected 2 snapshot files after pruning, got %v", files)
+	}
+
+	// Snapshots taken through the API are not subject to retention
+	rec := doRequest(rs, http.MethodGet, "/api/v1/snapshots", "")
+	if !strings.Contains(rec.Body.String(), `"name":"manual"`) {
+		t.Fatalf("manual snapshot was pruned: %s", rec.Body.String())
+	}
+
+	rec = doRequest(rs, http.MethodGet, "/metrics", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "decube_scheduled_snapshots_pruned_total") {
+		t.Fatalf("metrics missing snapshot counters: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestSnapshotSchedulerSkipsFollowers(t *testing.T) {
+	store := etcd.NewMemoryStore()
+	dir := t.TempDir()
+
+	NewSnapshotScheduler(followerStore{Store: store}, config.SnapshotConfig{RetentionCount: 2, Dir: dir}).snapshotOnce(context.Background())
+
+	if snapshots := scheduledSnapshots(t, store); len(snapshots) != 0 {
+		t.Fatalf("follower took %d snapshots", len(snapshots))
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("follower wrote %d files", len(files))
+	}
+}
@@ -4,17 +4,19 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/url"
-	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
-	"github.com/coreos/etcd/clientv3"
-	"github.com/coreos/etcd/embed"
-	"github.com/coreos/etcd/etcdserver/api/v3rpc/rpctypes"
 	"github.com/decube/decube/pkg/config"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
 )
 
 // EtcdManager manages the embedded etcd instance
@@ -26,6 +28,8 @@ type EtcdManager struct {
 	leaderAddr string
 }
 
+var _ Store = (*EtcdManager)(nil)
+
 // NewEtcdManager creates a new etcd manager
 func NewEtcdManager(cfg *config.Config) *EtcdManager {
 	return &EtcdManager{
@@ -43,20 +47,30 @@ func (e *EtcdManager) Start() error {
 	embedCfg.WalDir = e.config.Etcd.WalDir
 
 	// Network configuration
-	embedCfg.LCUrls = []url.URL{{Scheme: "http", Host: e.config.Node.ListenAddress}}
-	embedCfg.ACUrls = []url.URL{{Scheme: "http", Host: e.config.Node.ListenAddress}}
+	embedCfg.ListenClientUrls = []url.URL{{Scheme: "http", Host: e.config.Node.ListenAddress}}
+	embedCfg.AdvertiseClientUrls = []url.URL{{Scheme: "http", Host: e.config.Node.ListenAddress}}
 
 	// Peer configuration
-	peerURLs := make([]url.URL, len(e.config.Node.PeerAddresses))
-	for i, addr := range e.config.Node.PeerAddresses {
-		peerURLs[i] = url.URL{Scheme: "http", Host: addr}
+	members, err := e.clusterMembers()
+	if err != nil {
+		return err
 	}
-	embedCfg.LPUrls = peerURLs
-	embedCfg.APUrls = peerURLs
+	peerAddr := members[e.config.Etcd.Name]
+	embedCfg.ListenPeerUrls = []url.URL{{Scheme: "http", Host: bindAddr(peerAddr)}}
+	embedCfg.AdvertisePeerUrls = []url.URL{{Scheme: "http", Host: peerAddr}}
 
 	// Cluster configuration
-	embedCfg.InitialCluster = e.buildInitialCluster()
-	embedCfg.ClusterState = embed.ClusterStateFlagNew
+	embedCfg.InitialCluster = buildInitialCluster(members)
+	embedCfg.InitialClusterToken = e.config.Etcd.ClusterToken
+	switch e.config.Etcd.ClusterState {
+	case "", embed.ClusterStateFlagNew:
+		embedCfg.ClusterState = embed.ClusterStateFlagNew
+	case embed.ClusterStateFlagExisting:
+		embedCfg.ClusterState = embed.ClusterStateFlagExisting
+	default:
+		return fmt.Errorf("invalid initial cluster state %q: want %q or %q",
+			e.config.Etcd.ClusterState, embed.ClusterStateFlagNew, embed.ClusterStateFlagExisting)
+	}
 
 	// Performance tuning
 	embedCfg.SnapshotCount = uint64(e.config.Etcd.SnapshotCount)
@@ -80,7 +94,7 @@ func (e *EtcdManager) Start() error {
 			}
 			tlsConfig.Certificates = []tls.Certificate{cert}
 		}
-		embedCfg.ClientTLSInfo = embed.TLSInfo{
+		embedCfg.ClientTLSInfo = transport.TLSInfo{
 			CertFile:      e.config.Security.CertFile,
 			KeyFile:       e.config.Security.KeyFile,
 			TrustedCAFile: e.config.Security.CAFile,
@@ -166,12 +180,58 @@ func (e *EtcdManager) GetLeaderAddr() string {
 	return e.leaderAddr
 }
 
+// AddMember adds a member with the given peer address to the running
+// cluster. The new member then starts with initial_cluster_state "existing".
+func (e *EtcdManager) AddMember(ctx context.Context, peerAddr string) error {
+	_, err := e.client.MemberAdd(ctx, []string{"http://" + peerAddr})
+	return err
+}
+
 // Put stores a key-value pair with strong consistency
 func (e *EtcdManager) Put(ctx context.Context, key, value string) error {
 	_, err := e.client.Put(ctx, key, value)
 	return err
 }
 
+// GrantLease creates an etcd lease that expires after ttlSeconds unless renewed
+func (e *EtcdManager) GrantLease(ctx context.Context, ttlSeconds int64) (int64, error) {
+	resp, err := e.client.Grant(ctx, ttlSeconds)
+	if err != nil {
+		return 0, err
+	}
+	return int64(resp.ID), nil
+}
+
+// PutWithLease stores a key-value pair bound to a lease, so etcd deletes the key when the lease expires
+func (e *EtcdManager) PutWithLease(ctx context.Context, key, value string, leaseID int64) error {
+	_, err := e.client.Put(ctx, key, value, clientv3.WithLease(clientv3.LeaseID(leaseID)))
+	return err
+}
+
+// KeepAliveOnce renews a lease once and returns its refreshed TTL in seconds
+func (e *EtcdManager) KeepAliveOnce(ctx context.Context, leaseID int64) (int64, error) {
+	resp, err := e.client.KeepAliveOnce(ctx, clientv3.LeaseID(leaseID))
+	if err != nil {
+		return 0, err
+	}
+	return resp.TTL, nil
+}
+
+// LeaseTimeToLive returns the remaining TTL of a lease in seconds, or -1 if it has expired
+func (e *EtcdManager) LeaseTimeToLive(ctx context.Context, leaseID int64) (int64, error) {
+	resp, err := e.client.TimeToLive(ctx, clientv3.LeaseID(leaseID))
+	if err != nil {
+		return 0, err
+	}
+	return resp.TTL, nil
+}
+
+// RevokeLease revokes a lease, deleting every key attached to it
+func (e *EtcdManager) RevokeLease(ctx context.Context, leaseID int64) error {
+	_, err := e.client.Revoke(ctx, clientv3.LeaseID(leaseID))
+	return err
+}
+
 // Get retrieves a value by key
 func (e *EtcdManager) Get(ctx context.Context, key string) (string, error) {
 	resp, err := e.client.Get(ctx, key)
@@ -186,6 +246,42 @@ func (e *EtcdManager) Get(ctx context.Context, key string) (string, error) {
 	return string(resp.Kvs[0].Value), nil
 }
 
+// GetKeyValue retrieves a value by key along with its mod revision
+func (e *EtcdManager) GetKeyValue(ctx context.Context, key string) (KeyValue, error) {
+	resp, err := e.client.Get(ctx, key)
+	if err != nil {
+		return KeyValue{}, err
+	}
+
+	if len(resp.Kvs) == 0 {
+		return KeyValue{}, rpctypes.ErrKeyNotFound
+	}
+
+	return KeyValue{Value: string(resp.Kvs[0].Value), ModRevision: resp.Kvs[0].ModRevision}, nil
+}
+
+// PutIfModRevision stores a key-value pair only if the key's mod revision is
+// still modRevision, returning the new revision. A modRevision of 0 requires
+// the key not to exist. A non-zero leaseID binds the key to that lease.
+func (e *EtcdManager) PutIfModRevision(ctx context.Context, key, value string, modRevision, leaseID int64) (int64, error) {
+	var opts []clientv3.OpOption
+	if leaseID != 0 {
+		opts = append(opts, clientv3.WithLease(clientv3.LeaseID(leaseID)))
+	}
+
+	resp, err := e.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", modRevision)).
+		Then(clientv3.OpPut(key, value, opts...)).
+		Commit()
+	if err != nil {
+		return 0, err
+	}
+	if !resp.Succeeded {
+		return 0, ErrRevisionConflict
+	}
+	return resp.Header.Revision, nil
+}
+
 // Delete removes a key
 func (e *EtcdManager) Delete(ctx context.Context, key string) error {
 	_, err := e.client.Delete(ctx, key)
@@ -207,14 +303,58 @@ func (e *EtcdManager) GetWithPrefix(ctx context.Context, prefix string) (map[str
 	return result, nil
 }
 
-// Watch watches for changes to keys with a given prefix
-func (e *EtcdManager) Watch(ctx context.Context, prefix string) clientv3.WatchChan {
-	return e.client.Watch(ctx, prefix, clientv3.WithPrefix())
+// GetKeyValuesWithPrefix retrieves all keys with a given prefix along with their mod revisions
+func (e *EtcdManager) GetKeyValuesWithPrefix(ctx context.Context, prefix string) (map[string]KeyValue, error) {
+	resp, err := e.client.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]KeyValue)
+	for _, kv := range resp.Kvs {
+		result[string(kv.Key)] = KeyValue{Value: string(kv.Value), ModRevision: kv.ModRevision}
+	}
+
+	return result, nil
+}
+
+// Watch streams changes to keys with a given prefix until ctx is done
+func (e *EtcdManager) Watch(ctx context.Context, prefix string) <-chan WatchEvent {
+	events := make(chan WatchEvent)
+	watchCh := e.client.Watch(ctx, prefix, clientv3.WithPrefix())
+	go func() {
+		defer close(events)
+		for resp := range watchCh {
+			for _, ev := range resp.Events {
+				event := WatchEvent{
+					Type:     EventPut,
+					Key:      string(ev.Kv.Key),
+					Value:    string(ev.Kv.Value),
+					Revision: ev.Kv.ModRevision,
+				}
+				if ev.Type == clientv3.EventTypeDelete {
+					event.Type = EventDelete
+					event.Value = ""
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events
 }
 
 // CreateSnapshot creates a snapshot of the current etcd state
 func (e *EtcdManager) CreateSnapshot(ctx context.Context) ([]byte, error) {
-	return e.etcd.Server.Snapshot(ctx)
+	rc, err := e.client.Snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
 }
 
 // RestoreFromSnapshot restores etcd from a snapshot
@@ -224,50 +364,108 @@ func (e *EtcdManager) RestoreFromSnapshot(snapshotData []byte, skipHashCheck boo
 	return fmt.Errorf("snapshot restore not implemented")
 }
 
-// buildInitialCluster builds the initial cluster configuration string
-func (e *EtcdManager) buildInitialCluster() string {
-	var peers []string
-	for i, addr := range e.config.Node.PeerAddresses {
-		name := fmt.Sprintf("node-%d", i+1)
-		peers = append(peers, fmt.Sprintf("%s=http://%s", name, addr))
+// clusterMembers maps every member name to its peer address. Peers are
+// listed in node.peer_addresses as name=host:port; a bare host:port is named
+// after its host, so every node derives the same names from the same list.
+// etcd.peer_address adds the local member when the list doesn't name it.
+func (e *EtcdManager) clusterMembers() (map[string]string, error) {
+	members := make(map[string]string)
+	for _, peer := range e.config.Node.PeerAddresses {
+		name, addr, ok := strings.Cut(peer, "=")
+		if !ok {
+			addr = peer
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid peer address %q: %w", peer, err)
+			}
+			name = host
+		}
+		if _, dup := members[name]; dup {
+			return nil, fmt.Errorf("duplicate etcd member name %q in peer addresses", name)
+		}
+		members[name] = addr
+	}
+
+	local := e.config.Etcd.Name
+	if addr := e.config.Etcd.PeerAddress; addr != "" {
+		if listed, ok := members[local]; ok && listed != addr {
+			return nil, fmt.Errorf("etcd member %q has peer address %s, but peer addresses list %s", local, addr, listed)
+		}
+		members[local] = addr
+	}
+	if _, ok := members[local]; !ok {
+		return nil, fmt.Errorf("etcd member %q is not in peer addresses and has no peer address", local)
+	}
+	return members, nil
+}
+
+// buildInitialCluster builds the initial cluster configuration string,
+// sorted by member name so every node builds the same one
+func buildInitialCluster(members map[string]string) string {
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	peers := make([]string, len(names))
+	for i, name := range names {
+		peers[i] = fmt.Sprintf("%s=http://%s", name, members[name])
 	}
 	return strings.Join(peers, ",")
 }
 
-// monitorLeadership monitors leadership changes
-func (e *EtcdManager) monitorLeadership() {
-	watchCh := e.etcd.Server.WatchLeadership()
+// bindAddr is the address to listen on for addr. etcd only binds to IP
+// addresses, so a host name is replaced by every interface.
+func bindAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return addr
+	}
+	return net.JoinHostPort("0.0.0.0", port)
+}
 
+// monitorLeadership monitors leadership changes. The server closes the
+// channel from LeaderChangedNotify on every change and hands out a new one,
+// so it is fetched again each time round.
+func (e *EtcdManager) monitorLeadership() {
 	for {
+		leaderChanged := e.etcd.Server.LeaderChangedNotify()
+		e.updateLeadership()
+
 		select {
 		case <-e.etcd.Server.StopNotify():
 			return
-		case <-watchCh:
-			leaderID := e.etcd.Server.Leader()
-			e.isLeader = leaderID == e.etcd.Server.ID()
-
-			if e.isLeader {
-				e.leaderAddr = e.config.Node.ListenAddress
-			} else {
-				// Find leader address from cluster members
-				members := e.etcd.Server.Cluster().Members()
-				for _, member := range members {
-					if member.ID == leaderID {
-						if len(member.PeerURLs) > 0 {
-							u, err := url.Parse(member.PeerURLs[0])
-							if err == nil {
-								host, _, err := net.SplitHostPort(u.Host)
-								if err == nil {
-									e.leaderAddr = host + ":2379" // Assume client port
-								}
-							}
+		case <-leaderChanged:
+		}
+	}
+}
+
+// updateLeadership records whether this node leads and where the leader is
+func (e *EtcdManager) updateLeadership() {
+	leaderID := e.etcd.Server.Leader()
+	e.isLeader = leaderID == e.etcd.Server.ID()
+
+	if e.isLeader {
+		e.leaderAddr = e.config.Node.ListenAddress
+	} else {
+		// Find leader address from cluster members
+		members := e.etcd.Server.Cluster().Members()
+		for _, member := range members {
+			if member.ID == leaderID {
+				if len(member.PeerURLs) > 0 {
+					u, err := url.Parse(member.PeerURLs[0])
+					if err == nil {
+						host, _, err := net.SplitHostPort(u.Host)
+						if err == nil {
+							e.leaderAddr = host + ":2379" // Assume client port
 						}
-						break
 					}
 				}
+				break
 			}
-
-			log.Printf("Leadership changed. Is leader: %v, Leader addr: %s", e.isLeader, e.leaderAddr)
 		}
 	}
+
+	log.Printf("Leadership changed. Is leader: %v, Leader addr: %s", e.isLeader, e.leaderAddr)
 }
@@ -0,0 +1,237 @@
+package etcd
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decube/decube/pkg/config"
+)
+
+// freeAddr returns a loopback address with a port that is currently unused
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve port: %v", err)
+	}
+	defer lis.Close()
+	return lis.Addr().String()
+}
+
+// testConfig configures the member name on loopback ports in a temp
+// directory, with no peers; callers add them
+func testConfig(t *testing.T, name string) *config.Config {
+	t.Helper()
+
+	dir := t.TempDir()
+	cfg := config.DefaultConfig()
+	cfg.Node.ListenAddress = freeAddr(t)
+	cfg.Node.PeerAddresses = nil
+	cfg.Etcd.Name = name
+	cfg.Etcd.DataDir = filepath.Join(dir, "etcd")
+	cfg.Etcd.WalDir = filepath.Join(dir, "etcd", "wal")
+	return cfg
+}
+
+// startMembers starts a manager for each config at once, since a new
+// cluster only becomes ready when enough members are up to elect a leader
+func startMembers(t *testing.T, cfgs ...*config.Config) []*EtcdManager {
+	t.Helper()
+
+	managers := make([]*EtcdManager, len(cfgs))
+	errs := make(chan error, len(cfgs))
+	for i, cfg := range cfgs {
+		em := NewEtcdManager(cfg)
+		managers[i] = em
+		t.Cleanup(func() { em.Stop() })
+		go func() { errs <- em.Start() }()
+	}
+	for range cfgs {
+		if err := <-errs; err != nil {
+			t.Fatalf("failed to start etcd: %v", err)
+		}
+	}
+	return managers
+}
+
+func TestEmbeddedEtcdStartPutGet(t *testing.T) {
+	cfg := testConfig(t, "node-1")
+	cfg.Etcd.PeerAddress = freeAddr(t)
+	em := startMembers(t, cfg)[0]
+
+	// A single member elects itself; the leadership watch should notice
+	deadline := time.Now().Add(10 * time.Second)
+	for !em.IsLeader() {
+		if time.Now().After(deadline) {
+			t.Fatal("single member never became leader")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := em.Put(ctx, "/smoke/key", "value"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if v, err := em.Get(ctx, "/smoke/key"); err != nil || v != "value" {
+		t.Fatalf("Get returned %q, %v", v, err)
+	}
+	if _, err := em.Get(ctx, "/smoke/missing"); err != ErrKeyNotFound {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestEmbeddedEtcdThreeMemberCluster(t *testing.T) {
+	names := []string{"node-1", "node-2", "node-3"}
+	var peers []string
+	for _, name := range names {
+		peers = append(peers, name+"="+freeAddr(t))
+	}
+
+	cfgs := make([]*config.Config, len(names))
+	for i, name := range names {
+		cfgs[i] = testConfig(t, name)
+		cfgs[i].Node.PeerAddresses = peers
+	}
+	managers := startMembers(t, cfgs...)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		leaders := 0
+		for _, em := range managers {
+			if em.IsLeader() {
+				leaders++
+			}
+		}
+		if leaders == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected one leader, got %d", leaders)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := managers[0].Put(ctx, "/cluster/key", "replicated"); err != nil {
+		t.Fatalf("Put on node-1 failed: %v", err)
+	}
+	for _, em := range managers[1:] {
+		if v, err := em.Get(ctx, "/cluster/key"); err != nil || v != "replicated" {
+			t.Fatalf("Get on %s returned %q, %v", em.config.Etcd.Name, v, err)
+		}
+	}
+}
+
+func TestEmbeddedEtcdMemberJoinsExistingCluster(t *testing.T) {
+	first := testConfig(t, "node-1")
+	first.Etcd.PeerAddress = freeAddr(t)
+	leader := startMembers(t, first)[0]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := leader.Put(ctx, "/cluster/before", "join"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	joinAddr := freeAddr(t)
+	if err := leader.AddMember(ctx, joinAddr); err != nil {
+		t.Fatalf("AddMember failed: %v", err)
+	}
+
+	joining := testConfig(t, "node-2")
+	joining.Node.PeerAddresses = []string{"node-1=" + first.Etcd.PeerAddress, "node-2=" + joinAddr}
+	joining.Etcd.ClusterState = "existing"
+	joined := startMembers(t, joining)[0]
+
+	if v, err := joined.Get(ctx, "/cluster/before"); err != nil || v != "join" {
+		t.Fatalf("Get on joined member returned %q, %v", v, err)
+	}
+}
+
+func TestClusterMembers(t *testing.T) {
+	tests := []struct {
+		name    string
+		peers   []string
+		local   string
+		peer    string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:  "bare addresses named after their host",
+			peers: []string{"node-1:2380", "node-2:2380", "node-3:2380"},
+			local: "node-2",
+			want:  map[string]string{"node-1": "node-1:2380", "node-2": "node-2:2380", "node-3": "node-3:2380"},
+		},
+		{
+			name:  "explicit names",
+			peers: []string{"a=10.0.0.1:2380", "b=10.0.0.2:2380"},
+			local: "b",
+			want:  map[string]string{"a": "10.0.0.1:2380", "b": "10.0.0.2:2380"},
+		},
+		{
+			name:  "local member added from its peer address",
+			peers: []string{"a=10.0.0.1:2380"},
+			local: "b",
+			peer:  "10.0.0.2:2380",
+			want:  map[string]string{"a": "10.0.0.1:2380", "b": "10.0.0.2:2380"},
+		},
+		{
+			name:    "local member missing",
+			peers:   []string{"a=10.0.0.1:2380"},
+			local:   "b",
+			wantErr: "not in peer addresses",
+		},
+		{
+			name:    "peer address disagrees with the list",
+			peers:   []string{"a=10.0.0.1:2380"},
+			local:   "a",
+			peer:    "10.0.0.9:2380",
+			wantErr: "peer addresses list",
+		},
+		{
+			name:    "duplicate names",
+			peers:   []string{"10.0.0.1:2380", "10.0.0.1:2381"},
+			local:   "10.0.0.1",
+			wantErr: "duplicate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.Node.PeerAddresses = tt.peers
+			cfg.Etcd.Name = tt.local
+			cfg.Etcd.PeerAddress = tt.peer
+
+			got, err := NewEtcdManager(cfg).clusterMembers()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	got := buildInitialCluster(map[string]string{"node-2": "b:2380", "node-1": "a:2380"})
+	if want := "node-1=http://a:2380,node-2=http://b:2380"; got != want {
+		t.Fatalf("buildInitialCluster returned %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,329 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+)
+
+// Distributed locks. A lock on key k is the etcd key /locks/k, created in a
+// transaction only if it is absent and bound to a lease of the lock's TTL, so
+// a holder that dies releases it when the lease runs out. The revision that
+// created the key is the lock's fencing token: it grows with every
+// acquisition, so a resource guarded by the lock can refuse writes carrying a
+// token older than the newest it has seen.
+
+// lockPrefix is where lock keys live
+const lockPrefix = "/locks/"
+
+// lockRetryInterval bounds how long a waiter sleeps between attempts. Waiters
+// normally wake on the delete event, but a store may expire a lease without
+// telling watchers until it is next touched.
+const lockRetryInterval = 250 * time.Millisecond
+
+// lockReleaseTimeout bounds the lease revocation of a lock whose context is done
+const lockReleaseTimeout = 5 * time.Second
+
+var (
+	// ErrLockHeld is returned when a lock is held by someone else
+	ErrLockHeld = errors.New("etcd: lock is held")
+	// ErrLockNotHeld is returned when renewing or releasing a lock that is
+	// free or was acquired again since the token was issued
+	ErrLockNotHeld = errors.New("etcd: lock is not held with this token")
+	// ErrLockLost is returned when a lock's lease expired while it was held
+	ErrLockLost = errors.New("etcd: lock lost: its lease expired")
+)
+
+// LockInfo describes a held lock
+type LockInfo struct {
+	Key        string `json:"key"`
+	Holder     string `json:"holder"`
+	Token      int64  `json:"token"`
+	LeaseID    int64  `json:"lease_id"`
+	TTLSeconds int64  `json:"ttl_seconds"`
+	AcquiredAt string `json:"acquired_at"`
+}
+
+// Locker acquires locks in a Store on behalf of a holder
+type Locker struct {
+	store  Store
+	holder string
+}
+
+// NewLocker creates a locker that records holder as the owner of its locks
+func NewLocker(store Store, holder string) *Locker {
+	return &Locker{store: store, holder: holder}
+}
+
+// Acquire waits until it holds the lock on key, then keeps its lease alive
+// until the lock is released or ctx is done. Cancelling ctx releases the lock.
+func (l *Locker) Acquire(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	info, err := l.acquire(ctx, key, ttl, true)
+	if err != nil {
+		return nil, err
+	}
+
+	lockCtx, cancel := context.WithCancel(ctx)
+	lock := &Lock{
+		LockInfo: info,
+		locker:   l,
+		ctx:      lockCtx,
+		cancel:   cancel,
+		stopped:  make(chan struct{}),
+	}
+	go lock.keepAlive()
+	return lock, nil
+}
+
+// WithLock runs fn while holding the lock on key. fn's context is cancelled
+// if the lock is lost; WithLock then returns ErrLockLost unless fn failed.
+func (l *Locker) WithLock(ctx context.Context, key string, ttl time.Duration, fn func(ctx context.Context, token int64) error) error {
+	lock, err := l.Acquire(ctx, key, ttl)
+	if err != nil {
+		return err
+	}
+	if err := fn(lock.Context(), lock.Token); err != nil {
+		lock.Release()
+		return err
+	}
+	return lock.Release()
+}
+
+// AcquireLease takes the lock on key without keeping it alive; the caller
+// must Renew it within the TTL. With wait it blocks until the lock is free or
+// ctx is done, otherwise it fails at once with ErrLockHeld.
+func (l *Locker) AcquireLease(ctx context.Context, key string, ttl time.Duration, wait bool) (LockInfo, error) {
+	return l.acquire(ctx, key, ttl, wait)
+}
+
+// Get returns the current holder of the lock on key, or ErrLockNotHeld if it is free
+func (l *Locker) Get(ctx context.Context, key string) (LockInfo, error) {
+	kv, err := l.store.GetKeyValue(ctx, lockPrefix+key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return LockInfo{}, ErrLockNotHeld
+	}
+	if err != nil {
+		return LockInfo{}, err
+	}
+
+	var info LockInfo
+	if err := json.Unmarshal([]byte(kv.Value), &info); err != nil {
+		return LockInfo{}, err
+	}
+	info.Token = kv.ModRevision
+	return info, nil
+}
+
+// Renew extends the lease of the lock on key acquired with token
+func (l *Locker) Renew(ctx context.Context, key string, token int64) (LockInfo, error) {
+	info, err := l.held(ctx, key, token)
+	if err != nil {
+		return LockInfo{}, err
+	}
+	if _, err := l.store.KeepAliveOnce(ctx, info.LeaseID); err != nil {
+		if errors.Is(err, rpctypes.ErrLeaseNotFound) {
+			return LockInfo{}, ErrLockNotHeld
+		}
+		return LockInfo{}, err
+	}
+	return info, nil
+}
+
+// Release frees the lock on key acquired with token
+func (l *Locker) Release(ctx context.Context, key string, token int64) error {
+	info, err := l.held(ctx, key, token)
+	if err != nil {
+		return err
+	}
+	// Revoking the lease deletes the lock key; a lock acquired since has a
+	// lease of its own, so this never frees someone else's lock
+	if err := l.store.RevokeLease(ctx, info.LeaseID); err != nil {
+		if errors.Is(err, rpctypes.ErrLeaseNotFound) {
+			return ErrLockNotHeld
+		}
+		return err
+	}
+	return nil
+}
+
+// held returns the lock on key if it is still the acquisition that issued token
+func (l *Locker) held(ctx context.Context, key string, token int64) (LockInfo, error) {
+	info, err := l.Get(ctx, key)
+	if err != nil {
+		return LockInfo{}, err
+	}
+	if info.Token != token {
+		return LockInfo{}, ErrLockNotHeld
+	}
+	return info, nil
+}
+
+// acquire tries to take the lock, and with wait tries again whenever the
+// lock key is deleted until it succeeds or ctx is done
+func (l *Locker) acquire(ctx context.Context, key string, ttl time.Duration, wait bool) (LockInfo, error) {
+	if key == "" {
+		return LockInfo{}, errors.New("etcd: lock key is required")
+	}
+	lockKey := lockPrefix + key
+
+	// Watch before the first attempt so a release between the attempt and
+	// the wait is not missed
+	var events <-chan WatchEvent
+	if wait {
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		events = l.store.Watch(watchCtx, lockKey)
+	}
+
+	for {
+		info, err := l.tryAcquire(ctx, key, lockTTLSeconds(ttl))
+		if !wait || !errors.Is(err, ErrLockHeld) {
+			return info, err
+		}
+
+		retry := time.NewTimer(lockRetryInterval)
+	waiting:
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					events = nil // the watch ended; fall back to polling
+					continue
+				}
+				if event.Type == EventDelete && event.Key == lockKey {
+					break waiting
+				}
+			case <-retry.C:
+				break waiting
+			case <-ctx.Done():
+				retry.Stop()
+				return LockInfo{}, ctx.Err()
+			}
+		}
+		retry.Stop()
+	}
+}
+
+// tryAcquire makes one attempt to create the lock key under a fresh lease
+func (l *Locker) tryAcquire(ctx context.Context, key string, ttlSeconds int64) (LockInfo, error) {
+	leaseID, err := l.store.GrantLease(ctx, ttlSeconds)
+	if err != nil {
+		return LockInfo{}, err
+	}
+
+	info := LockInfo{
+		Key:        key,
+		Holder:     l.holder,
+		LeaseID:    leaseID,
+		TTLSeconds: ttlSeconds,
+		AcquiredAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	data, err := json.Marshal(&info)
+	if err == nil {
+		info.Token, err = l.store.PutIfModRevision(ctx, lockPrefix+key, string(data), 0, leaseID)
+	}
+	if err != nil {
+		revokeCtx, cancel := context.WithTimeout(context.Background(), lockReleaseTimeout)
+		l.store.RevokeLease(revokeCtx, leaseID)
+		cancel()
+		if errors.Is(err, ErrRevisionConflict) {
+			return LockInfo{}, ErrLockHeld
+		}
+		return LockInfo{}, err
+	}
+	return info, nil
+}
+
+// lockTTLSeconds rounds a TTL up to whole seconds, the granularity of etcd leases
+func lockTTLSeconds(ttl time.Duration) int64 {
+	seconds := int64((ttl + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// Lock is a lock held by Locker.Acquire, whose lease is kept alive in the
+// background until it is released
+type Lock struct {
+	LockInfo
+
+	locker  *Locker
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopped chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// Context returns a context that is done once the lock is released or lost
+func (lk *Lock) Context() context.Context {
+	return lk.ctx
+}
+
+// Done is closed once the lock is released or lost
+func (lk *Lock) Done() <-chan struct{} {
+	return lk.ctx.Done()
+}
+
+// Err returns ErrLockLost if the lock's lease expired while it was held
+func (lk *Lock) Err() error {
+	lk.mu.Lock()
+	defer lk.mu.Unlock()
+	if lk.err == ErrLockLost {
+		return lk.err
+	}
+	return nil
+}
+
+// Release stops the keepalive and frees the lock. It returns ErrLockLost if
+// the lock had already been lost; releasing twice is harmless.
+func (lk *Lock) Release() error {
+	lk.cancel()
+	<-lk.stopped
+	lk.mu.Lock()
+	defer lk.mu.Unlock()
+	return lk.err
+}
+
+// keepAlive renews the lease every third of its TTL, and revokes it once the
+// lock's context is done
+func (lk *Lock) keepAlive() {
+	defer close(lk.stopped)
+
+	ticker := time.NewTicker(time.Duration(lk.TTLSeconds) * time.Second / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-lk.ctx.Done():
+			ctx, cancel := context.WithTimeout(context.Background(), lockReleaseTimeout)
+			err := lk.locker.Release(ctx, lk.Key, lk.Token)
+			cancel()
+			if errors.Is(err, ErrLockNotHeld) {
+				err = ErrLockLost
+			}
+			lk.setErr(err)
+			return
+		case <-ticker.C:
+			// Other failures are retried on the next tick; if they last the
+			// whole TTL the lease expires and the next renewal reports it
+			if _, err := lk.locker.store.KeepAliveOnce(lk.ctx, lk.LeaseID); errors.Is(err, rpctypes.ErrLeaseNotFound) {
+				lk.setErr(ErrLockLost)
+				lk.cancel()
+				return
+			}
+		}
+	}
+}
+
+func (lk *Lock) setErr(err error) {
+	lk.mu.Lock()
+	lk.err = err
+	lk.mu.Unlock()
+}
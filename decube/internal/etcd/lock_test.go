@@ -0,0 +1,199 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLockMutualExclusion(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	var (
+		holders   atomic.Int32
+		mu        sync.Mutex
+		tokens    []int64
+		wg        sync.WaitGroup
+		errs      = make(chan error, 2)
+		perWorker = 10
+	)
+	for _, holder := range []string{"a", "b"} {
+		locker := NewLocker(store, holder)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				err := locker.WithLock(ctx, "shared", time.Second, func(ctx context.Context, token int64) error {
+					if n := holders.Add(1); n != 1 {
+						return errors.New("two holders at once")
+					}
+					mu.Lock()
+					tokens = append(tokens, token)
+					mu.Unlock()
+					time.Sleep(time.Millisecond)
+					holders.Add(-1)
+					return nil
+				})
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	if len(tokens) != 2*perWorker {
+		t.Fatalf("expected %d acquisitions, got %d", 2*perWorker, len(tokens))
+	}
+	for i := 1; i < len(tokens); i++ {
+		if tokens[i] <= tokens[i-1] {
+			t.Fatalf("fencing tokens must increase, got %v", tokens)
+		}
+	}
+	if _, err := NewLocker(store, "a").Get(ctx, "shared"); err != ErrLockNotHeld {
+		t.Fatalf("expected lock to be free after the workers, got %v", err)
+	}
+}
+
+func TestLockReleasedWhenHolderContextCancelled(t *testing.T) {
+	store := NewMemoryStore()
+	holderCtx, cancelHolder := context.WithCancel(context.Background())
+	defer cancelHolder()
+
+	first, err := NewLocker(store, "first").Acquire(holderCtx, "job", 10*time.Second)
+	if err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+
+	if _, err := NewLocker(store, "second").AcquireLease(context.Background(), "job", time.Second, false); err != ErrLockHeld {
+		t.Fatalf("expected ErrLockHeld while the lock is held, got %v", err)
+	}
+
+	acquired := make(chan *Lock)
+	go func() {
+		lock, err := NewLocker(store, "second").Acquire(context.Background(), "job", 10*time.Second)
+		if err != nil {
+			t.Errorf("second acquire: %v", err)
+			close(acquired)
+			return
+		}
+		acquired <- lock
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second holder acquired the lock while the first still held it")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancelHolder()
+	select {
+	case second := <-acquired:
+		if second == nil {
+			return
+		}
+		if second.Token <= first.Token {
+			t.Fatalf("expected a newer fencing token than %d, got %d", first.Token, second.Token)
+		}
+		if info, err := second.locker.Get(context.Background(), "job"); err != nil || info.Holder != "second" {
+			t.Fatalf("expected second to hold the lock, got %+v, %v", info, err)
+		}
+		if err := second.Release(); err != nil {
+			t.Fatalf("release: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("lock was not released when its holder's context was cancelled")
+	}
+
+	select {
+	case <-first.Done():
+	default:
+		t.Fatal("expected the first lock to be done")
+	}
+	if err := first.Err(); err != nil {
+		t.Fatalf("expected a clean release, got %v", err)
+	}
+}
+
+func TestLockLeaseRenewAndExpiry(t *testing.T) {
+	ctx := context.Background()
+	var offset atomic.Int64
+	start := time.Now()
+	store := NewMemoryStoreWithClock(func() time.Time {
+		return start.Add(time.Duration(offset.Load()))
+	})
+	locker := NewLocker(store, "client")
+
+	info, err := locker.AcquireLease(ctx, "report", 10*time.Second, false)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	offset.Store(int64(8 * time.Second))
+	if _, err := locker.Renew(ctx, "report", info.Token); err != nil {
+		t.Fatalf("renew: %v", err)
+	}
+	if _, err := locker.Renew(ctx, "report", info.Token+1); err != ErrLockNotHeld {
+		t.Fatalf("renew with a wrong token: expected ErrLockNotHeld, got %v", err)
+	}
+
+	// The renewal carries the lock past its original TTL, but not past the next one
+	offset.Store(int64(15 * time.Second))
+	if _, err := locker.Get(ctx, "report"); err != nil {
+		t.Fatalf("expected lock to survive after renewal, got %v", err)
+	}
+	offset.Store(int64(19 * time.Second))
+	if _, err := locker.Get(ctx, "report"); err != ErrLockNotHeld {
+		t.Fatalf("expected lock to expire, got %v", err)
+	}
+	if err := locker.Release(ctx, "report", info.Token); err != ErrLockNotHeld {
+		t.Fatalf("release after expiry: expected ErrLockNotHeld, got %v", err)
+	}
+
+	next, err := locker.AcquireLease(ctx, "report", 10*time.Second, false)
+	if err != nil {
+		t.Fatalf("acquire after expiry: %v", err)
+	}
+	if next.Token <= info.Token {
+		t.Fatalf("expected a newer fencing token than %d, got %d", info.Token, next.Token)
+	}
+	if err := locker.Release(ctx, "report", next.Token); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+}
+
+func TestLockLostWhenLeaseExpires(t *testing.T) {
+	var offset atomic.Int64
+	start := time.Now()
+	store := NewMemoryStoreWithClock(func() time.Time {
+		return start.Add(time.Duration(offset.Load()))
+	})
+
+	lock, err := NewLocker(store, "slow").Acquire(context.Background(), "job", time.Second)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	// Jump past the TTL so the next keepalive finds the lease gone
+	offset.Store(int64(5 * time.Second))
+	select {
+	case <-lock.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the lock to be lost")
+	}
+	if err := lock.Err(); err != ErrLockLost {
+		t.Fatalf("expected ErrLockLost, got %v", err)
+	}
+	if err := lock.Release(); err != ErrLockLost {
+		t.Fatalf("release of a lost lock: expected ErrLockLost, got %v", err)
+	}
+}
@@ -0,0 +1,358 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+)
+
+// MemoryStore is an in-process Store with etcd's semantics for keys, leases
+// and watches. It is always the leader. Expired leases are collected on the
+// next call that touches the store.
+type MemoryStore struct {
+	mu        sync.Mutex
+	now       func() time.Time
+	revision  int64
+	kvs       map[string]memoryValue
+	leases    map[int64]*memoryLease
+	nextLease int64
+	watchers  map[*memoryWatcher]struct{}
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+type memoryValue struct {
+	value       string
+	modRevision int64
+	lease       int64
+}
+
+type memoryLease struct {
+	ttl       int64
+	expiresAt time.Time
+	keys      map[string]struct{}
+}
+
+// memoryWatcher queues events for one Watch call, so a slow reader never
+// blocks writers
+type memoryWatcher struct {
+	prefix  string
+	pending []WatchEvent
+	wake    chan struct{}
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return NewMemoryStoreWithClock(time.Now)
+}
+
+// NewMemoryStoreWithClock creates an empty in-memory store whose leases
+// expire by the time now reports
+func NewMemoryStoreWithClock(now func() time.Time) *MemoryStore {
+	return &MemoryStore{
+		now:      now,
+		kvs:      make(map[string]memoryValue),
+		leases:   make(map[int64]*memoryLease),
+		watchers: make(map[*memoryWatcher]struct{}),
+	}
+}
+
+// Put stores a key-value pair, detaching it from any lease
+func (m *MemoryStore) Put(ctx context.Context, key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireLeases()
+	m.put(key, value, 0)
+	return nil
+}
+
+// PutWithLease stores a key-value pair that is deleted when the lease expires
+func (m *MemoryStore) PutWithLease(ctx context.Context, key, value string, leaseID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireLeases()
+	lease, ok := m.leases[leaseID]
+	if !ok {
+		return rpctypes.ErrLeaseNotFound
+	}
+	m.put(key, value, leaseID)
+	lease.keys[key] = struct{}{}
+	return nil
+}
+
+// Get retrieves a value by key
+func (m *MemoryStore) Get(ctx context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireLeases()
+	kv, ok := m.kvs[key]
+	if !ok {
+		return "", rpctypes.ErrKeyNotFound
+	}
+	return kv.value, nil
+}
+
+// Delete removes a key
+func (m *MemoryStore) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireLeases()
+	m.delete(key)
+	return nil
+}
+
+// GetWithPrefix retrieves all keys with a given prefix
+func (m *MemoryStore) GetWithPrefix(ctx context.Context, prefix string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireLeases()
+	result := make(map[string]string)
+	for key, kv := range m.kvs {
+		if strings.HasPrefix(key, prefix) {
+			result[key] = kv.value
+		}
+	}
+	return result, nil
+}
+
+// GetKeyValue retrieves a value by key along with its mod revision
+func (m *MemoryStore) GetKeyValue(ctx context.Context, key string) (KeyValue, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireLeases()
+	kv, ok := m.kvs[key]
+	if !ok {
+		return KeyValue{}, rpctypes.ErrKeyNotFound
+	}
+	return KeyValue{Value: kv.value, ModRevision: kv.modRevision}, nil
+}
+
+// GetKeyValuesWithPrefix retrieves all keys with a given prefix along with their mod revisions
+func (m *MemoryStore) GetKeyValuesWithPrefix(ctx context.Context, prefix string) (map[string]KeyValue, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireLeases()
+	result := make(map[string]KeyValue)
+	for key, kv := range m.kvs {
+		if strings.HasPrefix(key, prefix) {
+			result[key] = KeyValue{Value: kv.value, ModRevision: kv.modRevision}
+		}
+	}
+	return result, nil
+}
+
+// PutIfModRevision stores a key-value pair only if the key's mod revision is
+// still modRevision, returning the new revision. A modRevision of 0 requires
+// the key not to exist. A non-zero leaseID binds the key to that lease.
+func (m *MemoryStore) PutIfModRevision(ctx context.Context, key, value string, modRevision, leaseID int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireLeases()
+	if m.kvs[key].modRevision != modRevision {
+		return 0, ErrRevisionConflict
+	}
+	if leaseID != 0 {
+		lease, ok := m.leases[leaseID]
+		if !ok {
+			return 0, rpctypes.ErrLeaseNotFound
+		}
+		lease.keys[key] = struct{}{}
+	}
+	m.put(key, value, leaseID)
+	return m.revision, nil
+}
+
+// Watch streams changes to keys with a given prefix until ctx is done
+func (m *MemoryStore) Watch(ctx context.Context, prefix string) <-chan WatchEvent {
+	w := &memoryWatcher{prefix: prefix, wake: make(chan struct{}, 1)}
+	m.mu.Lock()
+	m.watchers[w] = struct{}{}
+	m.mu.Unlock()
+
+	events := make(chan WatchEvent)
+	go func() {
+		defer close(events)
+		defer func() {
+			m.mu.Lock()
+			delete(m.watchers, w)
+			m.mu.Unlock()
+		}()
+
+		for {
+			m.mu.Lock()
+			pending := w.pending
+			w.pending = nil
+			m.mu.Unlock()
+
+			for _, event := range pending {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-w.wake:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// GrantLease creates a lease that expires after ttlSeconds unless renewed
+func (m *MemoryStore) GrantLease(ctx context.Context, ttlSeconds int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireLeases()
+	m.nextLease++
+	m.leases[m.nextLease] = &memoryLease{
+		ttl:       ttlSeconds,
+		expiresAt: m.now().Add(time.Duration(ttlSeconds) * time.Second),
+		keys:      make(map[string]struct{}),
+	}
+	return m.nextLease, nil
+}
+
+// KeepAliveOnce renews a lease once and returns its refreshed TTL in seconds
+func (m *MemoryStore) KeepAliveOnce(ctx context.Context, leaseID int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireLeases()
+	lease, ok := m.leases[leaseID]
+	if !ok {
+		return 0, rpctypes.ErrLeaseNotFound
+	}
+	lease.expiresAt = m.now().Add(time.Duration(lease.ttl) * time.Second)
+	return lease.ttl, nil
+}
+
+// LeaseTimeToLive returns the remaining TTL of a lease in seconds, or -1 if it has expired
+func (m *MemoryStore) LeaseTimeToLive(ctx context.Context, leaseID int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireLeases()
+	lease, ok := m.leases[leaseID]
+	if !ok {
+		return -1, nil
+	}
+	return int64(math.Ceil(lease.expiresAt.Sub(m.now()).Seconds())), nil
+}
+
+// RevokeLease revokes a lease, deleting every key attached to it
+func (m *MemoryStore) RevokeLease(ctx context.Context, leaseID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireLeases()
+	if _, ok := m.leases[leaseID]; !ok {
+		return rpctypes.ErrLeaseNotFound
+	}
+	m.revoke(leaseID)
+	return nil
+}
+
+// CreateSnapshot returns every key and value as JSON
+func (m *MemoryStore) CreateSnapshot(ctx context.Context) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireLeases()
+	state := make(map[string]string, len(m.kvs))
+	for key, kv := range m.kvs {
+		state[key] = kv.value
+	}
+	return json.Marshal(state)
+}
+
+// IsLeader always reports true; a memory store is a cluster of one
+func (m *MemoryStore) IsLeader() bool {
+	return true
+}
+
+// GetLeaderAddr returns an empty address, as there is no etcd client endpoint
+func (m *MemoryStore) GetLeaderAddr() string {
+	return ""
+}
+
+func (m *MemoryStore) put(key, value string, leaseID int64) {
+	if old, ok := m.kvs[key]; ok && old.lease != 0 && old.lease != leaseID {
+		if lease, ok := m.leases[old.lease]; ok {
+			delete(lease.keys, key)
+		}
+	}
+	m.revision++
+	m.kvs[key] = memoryValue{value: value, modRevision: m.revision, lease: leaseID}
+	m.notify(WatchEvent{Type: EventPut, Key: key, Value: value, Revision: m.revision})
+}
+
+func (m *MemoryStore) delete(key string) {
+	kv, ok := m.kvs[key]
+	if !ok {
+		return
+	}
+	if lease, ok := m.leases[kv.lease]; ok {
+		delete(lease.keys, key)
+	}
+	delete(m.kvs, key)
+	m.revision++
+	m.notify(WatchEvent{Type: EventDelete, Key: key, Revision: m.revision})
+}
+
+// revoke deletes a lease and its keys, in key order so watchers see a stable sequence
+func (m *MemoryStore) revoke(leaseID int64) {
+	lease := m.leases[leaseID]
+	delete(m.leases, leaseID)
+
+	keys := make([]string, 0, len(lease.keys))
+	for key := range lease.keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		m.delete(key)
+	}
+}
+
+// expireLeases revokes every lease whose TTL has run out
+func (m *MemoryStore) expireLeases() {
+	now := m.now()
+	for id, lease := range m.leases {
+		if !now.Before(lease.expiresAt) {
+			m.revoke(id)
+		}
+	}
+}
+
+// notify queues event for every watcher of a matching prefix
+func (m *MemoryStore) notify(event WatchEvent) {
+	for w := range m.watchers {
+		if !strings.HasPrefix(event.Key, w.prefix) {
+			continue
+		}
+		w.pending = append(w.pending, event)
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+}
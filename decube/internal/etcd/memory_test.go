@@ -0,0 +1,105 @@
+package etcd
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+)
+
+func TestMemoryStorePutGetPrefix(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+
+	m.Put(ctx, "/pods/default/a", "1")
+	m.Put(ctx, "/pods/default/b", "2")
+	m.Put(ctx, "/snapshots/s1", "3")
+
+	if v, err := m.Get(ctx, "/pods/default/a"); err != nil || v != "1" {
+		t.Fatalf("Get returned %q, %v", v, err)
+	}
+	if _, err := m.Get(ctx, "/pods/default/c"); err != rpctypes.ErrKeyNotFound {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+
+	got, _ := m.GetWithPrefix(ctx, "/pods/")
+	want := map[string]string{"/pods/default/a": "1", "/pods/default/b": "2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetWithPrefix returned %v, want %v", got, want)
+	}
+
+	m.Delete(ctx, "/pods/default/a")
+	if _, err := m.Get(ctx, "/pods/default/a"); err != rpctypes.ErrKeyNotFound {
+		t.Fatalf("expected deleted key to be gone, got %v", err)
+	}
+}
+
+func TestMemoryStoreWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := NewMemoryStore()
+
+	events := m.Watch(ctx, "/pods/")
+	m.Put(ctx, "/pods/default/a", "1")
+	m.Put(ctx, "/leases/x", "ignored")
+	m.Delete(ctx, "/pods/default/a")
+
+	want := []WatchEvent{
+		{Type: EventPut, Key: "/pods/default/a", Value: "1", Revision: 1},
+		{Type: EventDelete, Key: "/pods/default/a", Revision: 3},
+	}
+	for _, w := range want {
+		select {
+		case got := <-events:
+			if got != w {
+				t.Fatalf("got event %+v, want %+v", got, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %+v", w)
+		}
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestMemoryStoreLeaseExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	m := NewMemoryStoreWithClock(func() time.Time { return now })
+
+	id, err := m.GrantLease(ctx, 10)
+	if err != nil {
+		t.Fatalf("GrantLease failed: %v", err)
+	}
+	if err := m.PutWithLease(ctx, "/leases/a", "held", id); err != nil {
+		t.Fatalf("PutWithLease failed: %v", err)
+	}
+
+	now = now.Add(4 * time.Second)
+	if ttl, _ := m.LeaseTimeToLive(ctx, id); ttl != 6 {
+		t.Fatalf("expected 6s remaining, got %d", ttl)
+	}
+	if ttl, err := m.KeepAliveOnce(ctx, id); err != nil || ttl != 10 {
+		t.Fatalf("KeepAliveOnce returned %d, %v", ttl, err)
+	}
+
+	now = now.Add(9 * time.Second)
+	if _, err := m.Get(ctx, "/leases/a"); err != nil {
+		t.Fatalf("renewed lease expired early: %v", err)
+	}
+
+	now = now.Add(time.Second)
+	if _, err := m.Get(ctx, "/leases/a"); err != rpctypes.ErrKeyNotFound {
+		t.Fatalf("expected key to expire with its lease, got %v", err)
+	}
+	if ttl, _ := m.LeaseTimeToLive(ctx, id); ttl != -1 {
+		t.Fatalf("expected -1 for an expired lease, got %d", ttl)
+	}
+	if _, err := m.KeepAliveOnce(ctx, id); err != rpctypes.ErrLeaseNotFound {
+		t.Fatalf("expected ErrLeaseNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+)
+
+// Store is the key-value store the API servers keep their records in.
+// EtcdManager implements it on embedded etcd; MemoryStore keeps everything in
+// process for tests.
+type Store interface {
+	Put(ctx context.Context, key, value string) error
+	Get(ctx context.Context, key string) (string, error)
+	Delete(ctx context.Context, key string) error
+	GetWithPrefix(ctx context.Context, prefix string) (map[string]string, error)
+	GetKeyValue(ctx context.Context, key string) (KeyValue, error)
+	GetKeyValuesWithPrefix(ctx context.Context, prefix string) (map[string]KeyValue, error)
+	PutIfModRevision(ctx context.Context, key, value string, modRevision, leaseID int64) (int64, error)
+	Watch(ctx context.Context, prefix string) <-chan WatchEvent
+
+	GrantLease(ctx context.Context, ttlSeconds int64) (int64, error)
+	PutWithLease(ctx context.Context, key, value string, leaseID int64) error
+	KeepAliveOnce(ctx context.Context, leaseID int64) (int64, error)
+	LeaseTimeToLive(ctx context.Context, leaseID int64) (int64, error)
+	RevokeLease(ctx context.Context, leaseID int64) error
+
+	CreateSnapshot(ctx context.Context) ([]byte, error)
+
+	IsLeader() bool
+	GetLeaderAddr() string
+}
+
+// ErrKeyNotFound is returned by Get and GetKeyValue for a missing key
+var ErrKeyNotFound = rpctypes.ErrKeyNotFound
+
+// ErrRevisionConflict is returned by PutIfModRevision when the key was
+// modified after the caller read it
+var ErrRevisionConflict = errors.New("etcd: key was modified since it was read")
+
+// KeyValue is a stored value with the revision that last modified it
+type KeyValue struct {
+	Value       string
+	ModRevision int64
+}
+
+// EventType says whether a watched key was written or deleted
+type EventType int
+
+const (
+	EventPut EventType = iota
+	EventDelete
+)
+
+// WatchEvent is a change to a key under a watched prefix
+type WatchEvent struct {
+	Type     EventType
+	Key      string
+	Value    string // empty for deletes
+	Revision int64
+}
@@ -13,6 +13,7 @@ type Config struct {
 	API         APIConfig         `mapstructure:"api"`
 	Replication ReplicationConfig `mapstructure:"replication"`
 	Snapshot    SnapshotConfig    `mapstructure:"snapshot"`
+	Controller  ControllerConfig  `mapstructure:"controller"`
 	Logging     LoggingConfig     `mapstructure:"logging"`
 	Security    SecurityConfig    `mapstructure:"security"`
 }
@@ -25,9 +26,16 @@ type NodeConfig struct {
 	PeerAddresses []string `mapstructure:"peer_addresses"`
 }
 
-// EtcdConfig holds etcd configuration
+// EtcdConfig holds etcd configuration. Name must be one of the member names
+// in node.peer_addresses, unless PeerAddress gives this member's peer address.
+// ClusterState is "new" to bootstrap a cluster, or "existing" to join one the
+// member has already been added to.
 type EtcdConfig struct {
+	Backend            string `mapstructure:"backend"` // "embedded" or "memory"
 	Name               string `mapstructure:"name"`
+	PeerAddress        string `mapstructure:"peer_address"`
+	ClusterToken       string `mapstructure:"initial_cluster_token"`
+	ClusterState       string `mapstructure:"initial_cluster_state"`
 	DataDir            string `mapstructure:"data_dir"`
 	WalDir             string `mapstructure:"wal_dir"`
 	SnapshotCount      uint64 `mapstructure:"snapshot_count"`
@@ -45,17 +53,25 @@ type APIConfig struct {
 	GRPC GRPCConfig `mapstructure:"grpc"`
 }
 
-// RESTConfig holds REST API configuration
+// RESTConfig holds REST API configuration. LeaderOnlyWrites redirects
+// writes on a follower to the leader.
 type RESTConfig struct {
-	Enabled bool     `mapstructure:"enabled"`
-	Address string   `mapstructure:"address"`
-	CORS    []string `mapstructure:"cors_origins"`
+	Enabled          bool          `mapstructure:"enabled"`
+	Address          string        `mapstructure:"address"`
+	CORS             []string      `mapstructure:"cors_origins"`
+	MaxBodyBytes     int64         `mapstructure:"max_body_bytes"`
+	RequestTimeout   time.Duration `mapstructure:"request_timeout"`
+	LeaderOnlyWrites bool          `mapstructure:"leader_only_writes"`
 }
 
-// GRPCConfig holds gRPC API configuration
+// GRPCConfig holds gRPC API configuration. AuthToken, when set, is the
+// bearer token every gRPC call must present. LeaderOnlyWrites makes
+// followers refuse writes with FailedPrecondition.
 type GRPCConfig struct {
-	Enabled bool   `mapstructure:"enabled"`
-	Address string `mapstructure:"address"`
+	Enabled          bool   `mapstructure:"enabled"`
+	Address          string `mapstructure:"address"`
+	AuthToken        string `mapstructure:"auth_token"`
+	LeaderOnlyWrites bool   `mapstructure:"leader_only_writes"`
 }
 
 // ReplicationConfig holds replication configuration
@@ -66,12 +82,25 @@ type ReplicationConfig struct {
 	MaxRetries   int           `mapstructure:"max_retries"`
 }
 
-// SnapshotConfig holds snapshot configuration
+// SnapshotConfig holds scheduled snapshot configuration. The leader takes a
+// snapshot every Interval, writes it to Dir, gzipped if Compression is set,
+// and keeps the newest RetentionCount scheduled snapshots.
 type SnapshotConfig struct {
 	Enabled       bool          `mapstructure:"enabled"`
 	Interval      time.Duration `mapstructure:"interval"`
 	RetentionCount int          `mapstructure:"retention_count"`
 	Compression   bool          `mapstructure:"compression"`
+	Dir           string        `mapstructure:"dir"`
+}
+
+// ControllerConfig holds pod lifecycle controller configuration.
+// ReconcileInterval is how often every pod is re-checked, and
+// ScheduleInterval how often unscheduled pods are retried, on top of
+// watch-driven updates; zero relies on watches alone.
+type ControllerConfig struct {
+	Enabled           bool          `mapstructure:"enabled"`
+	ReconcileInterval time.Duration `mapstructure:"reconcile_interval"`
+	ScheduleInterval  time.Duration `mapstructure:"schedule_interval"`
 }
 
 // LoggingConfig holds logging configuration
@@ -102,7 +131,10 @@ func DefaultConfig() *Config {
 			PeerAddresses: []string{"node-1:2380", "node-2:2380", "node-3:2380"},
 		},
 		Etcd: EtcdConfig{
+			Backend:                "embedded",
 			Name:                   "node-1",
+			ClusterToken:           "decube-cluster",
+			ClusterState:           "new",
 			DataDir:                "/var/lib/decube/etcd",
 			WalDir:                 "/var/lib/decube/etcd/wal",
 			SnapshotCount:          10000,
@@ -115,9 +147,11 @@ func DefaultConfig() *Config {
 		},
 		API: APIConfig{
 			REST: RESTConfig{
-				Enabled: true,
-				Address: "0.0.0.0:8080",
-				CORS:    []string{"*"},
+				Enabled:        true,
+				Address:        "0.0.0.0:8080",
+				CORS:           []string{"*"},
+				MaxBodyBytes:   1 << 20, // 1MB
+				RequestTimeout: 10 * time.Second,
 			},
 			GRPC: GRPCConfig{
 				Enabled: true,
@@ -135,6 +169,12 @@ func DefaultConfig() *Config {
 			Interval:      1 * time.Hour,
 			RetentionCount: 10,
 			Compression:   true,
+			Dir:           "/var/lib/decube/snapshots",
+		},
+		Controller: ControllerConfig{
+			Enabled:           true,
+			ReconcileInterval: 10 * time.Second,
+			ScheduleInterval:  10 * time.Second,
 		},
 		Logging: LoggingConfig{
 			Level:      "info",
@@ -164,7 +204,11 @@ func LoadConfig(configPath string) (*Config, error) {
 	viper.SetDefault("node.data_dir", cfg.Node.DataDir)
 	viper.SetDefault("node.listen_address", cfg.Node.ListenAddress)
 	viper.SetDefault("node.peer_addresses", cfg.Node.PeerAddresses)
+	viper.SetDefault("etcd.backend", cfg.Etcd.Backend)
 	viper.SetDefault("etcd.name", cfg.Etcd.Name)
+	viper.SetDefault("etcd.peer_address", cfg.Etcd.PeerAddress)
+	viper.SetDefault("etcd.initial_cluster_token", cfg.Etcd.ClusterToken)
+	viper.SetDefault("etcd.initial_cluster_state", cfg.Etcd.ClusterState)
 	viper.SetDefault("etcd.data_dir", cfg.Etcd.DataDir)
 	viper.SetDefault("etcd.wal_dir", cfg.Etcd.WalDir)
 	viper.SetDefault("etcd.snapshot_count", cfg.Etcd.SnapshotCount)
@@ -177,8 +221,13 @@ func LoadConfig(configPath string) (*Config, error) {
 	viper.SetDefault("api.rest.enabled", cfg.API.REST.Enabled)
 	viper.SetDefault("api.rest.address", cfg.API.REST.Address)
 	viper.SetDefault("api.rest.cors_origins", cfg.API.REST.CORS)
+	viper.SetDefault("api.rest.max_body_bytes", cfg.API.REST.MaxBodyBytes)
+	viper.SetDefault("api.rest.request_timeout", cfg.API.REST.RequestTimeout)
+	viper.SetDefault("api.rest.leader_only_writes", cfg.API.REST.LeaderOnlyWrites)
 	viper.SetDefault("api.grpc.enabled", cfg.API.GRPC.Enabled)
 	viper.SetDefault("api.grpc.address", cfg.API.GRPC.Address)
+	viper.SetDefault("api.grpc.auth_token", cfg.API.GRPC.AuthToken)
+	viper.SetDefault("api.grpc.leader_only_writes", cfg.API.GRPC.LeaderOnlyWrites)
 	viper.SetDefault("replication.enabled", cfg.Replication.Enabled)
 	viper.SetDefault("replication.peer_timeout", cfg.Replication.PeerTimeout)
 	viper.SetDefault("replication.retry_interval", cfg.Replication.RetryInterval)
@@ -187,6 +236,10 @@ func LoadConfig(configPath string) (*Config, error) {
 	viper.SetDefault("snapshot.interval", cfg.Snapshot.Interval)
 	viper.SetDefault("snapshot.retention_count", cfg.Snapshot.RetentionCount)
 	viper.SetDefault("snapshot.compression", cfg.Snapshot.Compression)
+	viper.SetDefault("snapshot.dir", cfg.Snapshot.Dir)
+	viper.SetDefault("controller.enabled", cfg.Controller.Enabled)
+	viper.SetDefault("controller.reconcile_interval", cfg.Controller.ReconcileInterval)
+	viper.SetDefault("controller.schedule_interval", cfg.Controller.ScheduleInterval)
 	viper.SetDefault("logging.level", cfg.Logging.Level)
 	viper.SetDefault("logging.format", cfg.Logging.Format)
 	viper.SetDefault("logging.output", cfg.Logging.Output)
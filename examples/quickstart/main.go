@@ -3,10 +3,10 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/REChain-Network-Solutions/DeCub/rechain/pkg/crdt"
+	"github.com/decube/client"
 )
 
 func main() {
@@ -28,7 +28,7 @@ func main() {
 
 func demoCRDT() {
 	// Create a new OR-Set CRDT
-	orset := crdt.NewORSet()
+	orset := crdt.NewORSet("quickstart")
 
 	// Add elements
 	orset.Add("item1")
@@ -59,18 +59,26 @@ func demoBasicOperations() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	// Connect to the DeCube services through the gateway started by docker-compose.yml
+	c := client.New(client.Config{
+		CatalogURL: "http://localhost:8080/catalog",
+		GossipURL:  "http://localhost:8080/gossip",
+		Timeout:    2 * time.Second,
+	})
+
 	fmt.Println("  Basic operations example:")
-	fmt.Println("  - Connect to DeCube cluster")
-	fmt.Println("  - Create snapshot")
-	fmt.Println("  - Query catalog")
-	fmt.Println("  - Sync with gossip protocol")
-
-	// Simulate async operation
-	select {
-	case <-time.After(1 * time.Second):
-		fmt.Println("  ✓ Operations completed")
-	case <-ctx.Done():
-		log.Fatal("  ✗ Operation timeout")
+
+	snapshots, err := c.QueryCatalog(ctx, "snapshots", "")
+	if err != nil {
+		fmt.Printf("  ✗ Query catalog: %v\n", err)
+	} else {
+		fmt.Printf("  ✓ Catalog holds %d snapshots\n", len(snapshots))
 	}
-}
 
+	status, err := c.GetGossipStatus(ctx)
+	if err != nil {
+		fmt.Printf("  ✗ Gossip status: %v\n", err)
+	} else {
+		fmt.Printf("  ✓ Gossip node %s has %d peers, merkle root %s\n", status.NodeID, status.Peers, status.MerkleRoot)
+	}
+}
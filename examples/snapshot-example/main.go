@@ -1,52 +1,66 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"net/http"
 	"time"
+
+	"github.com/decube/client"
 )
 
+// The services are reached through the gateway started by docker-compose.yml
 const (
-	catalogEndpoint = "http://localhost:8080/catalog"
-	snapshotEndpoint = "http://localhost:8080/snapshots"
+	controlPlaneURL = "http://localhost:8080/control-plane"
+	catalogURL      = "http://localhost:8080/catalog"
 )
 
-type Snapshot struct {
-	ID       string                 `json:"id"`
-	Metadata map[string]interface{} `json:"metadata"`
-}
-
 func main() {
 	fmt.Println("DeCube Snapshot Example")
 	fmt.Println("=======================")
 
+	c := client.New(client.Config{
+		ControlPlaneURL: controlPlaneURL,
+		CatalogURL:      catalogURL,
+		Timeout:         10 * time.Second,
+		Retries:         3,
+	})
+	ctx := context.Background()
+
 	// Wait for services to be ready
 	fmt.Println("\nWaiting for services to be ready...")
-	waitForService(catalogEndpoint, 30*time.Second)
+	waitForService(ctx, c, client.Catalog, 30*time.Second)
 
 	// Create a snapshot
 	fmt.Println("\n1. Creating snapshot...")
-	snapshot := createSnapshot("example-snapshot-001", map[string]interface{}{
+	id := "example-snapshot-001"
+	err := c.AddSnapshotToCatalog(ctx, id, map[string]interface{}{
 		"size":    1073741824,
 		"created": time.Now().Format(time.RFC3339),
 		"cluster": "cluster-a",
 	})
-	fmt.Printf("   Created snapshot: %s\n", snapshot.ID)
+	if err != nil {
+		fmt.Printf("   Error: %v\n", err)
+		return
+	}
+	fmt.Printf("   Created snapshot: %s\n", id)
 
 	// Query snapshot
 	fmt.Println("\n2. Querying snapshot...")
-	queried := querySnapshot(snapshot.ID)
-	if queried != nil {
-		fmt.Printf("   Found snapshot: %s\n", queried.ID)
-		fmt.Printf("   Metadata: %+v\n", queried.Metadata)
+	matches, err := c.QueryCatalog(ctx, "snapshots", id)
+	if err != nil {
+		fmt.Printf("   Error: %v\n", err)
+	}
+	for _, s := range matches {
+		fmt.Printf("   Found snapshot: %s\n", s.ID)
+		fmt.Printf("   Metadata: %+v\n", s.Metadata)
 	}
 
 	// List all snapshots
 	fmt.Println("\n3. Listing all snapshots...")
-	snapshots := listSnapshots()
+	snapshots, err := c.QueryCatalog(ctx, "snapshots", "")
+	if err != nil {
+		fmt.Printf("   Error: %v\n", err)
+	}
 	fmt.Printf("   Found %d snapshots\n", len(snapshots))
 	for _, s := range snapshots {
 		fmt.Printf("   - %s\n", s.ID)
@@ -54,15 +68,17 @@ func main() {
 
 	// Clean up
 	fmt.Println("\n4. Cleaning up...")
-	deleteSnapshot(snapshot.ID)
+	if err := c.RemoveSnapshotFromCatalog(ctx, id); err != nil {
+		fmt.Printf("   Error: %v\n", err)
+		return
+	}
 	fmt.Println("   Snapshot deleted")
 }
 
-func waitForService(endpoint string, timeout time.Duration) {
+func waitForService(ctx context.Context, c *client.Client, svc client.Service, timeout time.Duration) {
 	deadline := time.Now().Add(timeout)
 	for time.Now().Before(deadline) {
-		resp, err := http.Get(endpoint + "/health")
-		if err == nil && resp.StatusCode == http.StatusOK {
+		if _, err := c.Health(ctx, svc); err == nil {
 			fmt.Println("   ✓ Services are ready")
 			return
 		}
@@ -70,70 +86,3 @@ func waitForService(endpoint string, timeout time.Duration) {
 	}
 	fmt.Println("   ⚠ Services may not be ready")
 }
-
-func createSnapshot(id string, metadata map[string]interface{}) *Snapshot {
-	snapshot := &Snapshot{
-		ID:       id,
-		Metadata: metadata,
-	}
-
-	jsonData, _ := json.Marshal(snapshot)
-	resp, err := http.Post(snapshotEndpoint, "application/json", 
-		bytes.NewBuffer(jsonData))
-	if err != nil {
-		fmt.Printf("   Error: %v\n", err)
-		return nil
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		fmt.Printf("   Error: %s\n", string(body))
-		return nil
-	}
-
-	return snapshot
-}
-
-func querySnapshot(id string) *Snapshot {
-	resp, err := http.Get(fmt.Sprintf("%s/%s", snapshotEndpoint, id))
-	if err != nil {
-		fmt.Printf("   Error: %v\n", err)
-		return nil
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil
-	}
-
-	var snapshot Snapshot
-	json.NewDecoder(resp.Body).Decode(&snapshot)
-	return &snapshot
-}
-
-func listSnapshots() []Snapshot {
-	resp, err := http.Get(catalogEndpoint + "/snapshots")
-	if err != nil {
-		fmt.Printf("   Error: %v\n", err)
-		return nil
-	}
-	defer resp.Body.Close()
-
-	var snapshots []Snapshot
-	json.NewDecoder(resp.Body).Decode(&snapshots)
-	return snapshots
-}
-
-func deleteSnapshot(id string) {
-	req, _ := http.NewRequest("DELETE", 
-		fmt.Sprintf("%s/%s", snapshotEndpoint, id), nil)
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Printf("   Error: %v\n", err)
-		return
-	}
-	defer resp.Body.Close()
-}
-
@@ -10,4 +10,7 @@ require (
 	github.com/google/uuid v1.3.0
 	github.com/spf13/cobra v1.6.1
 	golang.org/x/crypto v0.9.0
+	github.com/decube/client v0.0.0
 )
+
+replace github.com/decube/client => ./pkg/client
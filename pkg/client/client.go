@@ -0,0 +1,277 @@
+// Package client is a Go client for the DeCube services: the control plane,
+// the global consensus layer (GCL), the CRDT catalog, gossip and object
+// storage. Each method takes a context, retries what is safe to retry and
+// decodes the response into a typed value; non-2xx responses come back as
+// *APIError.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Service names a DeCube service
+type Service string
+
+// The DeCube services a Client talks to
+const (
+	ControlPlane Service = "control_plane"
+	GCL          Service = "gcl"
+	Catalog      Service = "catalog"
+	Gossip       Service = "gossip"
+	Storage      Service = "storage"
+)
+
+// Services lists every service, in a stable order
+var Services = []Service{ControlPlane, GCL, Catalog, Gossip, Storage}
+
+// Defaults for the Config fields that are left unset
+const (
+	DefaultTimeout        = 30 * time.Second
+	DefaultRetryBaseDelay = 200 * time.Millisecond
+)
+
+// retryMaxDelay caps the backoff between retries
+const retryMaxDelay = 5 * time.Second
+
+var (
+	// ErrNoServiceURL is returned when calling a service that has no URL configured
+	ErrNoServiceURL = errors.New("no URL configured for service")
+	// ErrInvalidResponse is returned when a 2xx response cannot be decoded
+	ErrInvalidResponse = errors.New("invalid response")
+)
+
+// Config holds the service base URLs and the request policy. Only the
+// services a caller uses need a URL.
+type Config struct {
+	ControlPlaneURL string
+	GCLURL          string
+	CatalogURL      string
+	GossipURL       string
+	StorageURL      string
+
+	// Timeout bounds each call, retries included; defaults to DefaultTimeout
+	Timeout time.Duration
+	// Retries is how often a call that is safe to repeat is retried after a
+	// network error or a 5xx response
+	Retries int
+	// RetryBaseDelay is the backoff before the first retry; it doubles on
+	// each attempt. Defaults to DefaultRetryBaseDelay.
+	RetryBaseDelay time.Duration
+	// HTTPClient sends the requests; defaults to a plain http.Client
+	HTTPClient *http.Client
+}
+
+// Client calls the DeCube services. It is safe for concurrent use.
+type Client struct {
+	cfg  Config
+	http *http.Client
+}
+
+// New creates a client for the services in cfg
+func New(cfg Config) *Client {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.RetryBaseDelay <= 0 {
+		cfg.RetryBaseDelay = DefaultRetryBaseDelay
+	}
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	return &Client{cfg: cfg, http: httpClient}
+}
+
+// URL returns the configured base URL of a service
+func (c *Client) URL(svc Service) string {
+	switch svc {
+	case ControlPlane:
+		return c.cfg.ControlPlaneURL
+	case GCL:
+		return c.cfg.GCLURL
+	case Catalog:
+		return c.cfg.CatalogURL
+	case Gossip:
+		return c.cfg.GossipURL
+	case Storage:
+		return c.cfg.StorageURL
+	}
+	return ""
+}
+
+// APIError is a non-2xx response from a service
+type APIError struct {
+	Service    Service
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string // the response body, trimmed
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s: %s %s: %d %s", e.Service, e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// StatusCode returns the HTTP status of an *APIError in err's chain, or 0
+func StatusCode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// IsNotFound reports whether err is a 404 response
+func IsNotFound(err error) bool { return StatusCode(err) == http.StatusNotFound }
+
+// IsConflict reports whether err is a 409 response
+func IsConflict(err error) bool { return StatusCode(err) == http.StatusConflict }
+
+// IsUnauthorized reports whether err is a 401 or 403 response
+func IsUnauthorized(err error) bool {
+	code := StatusCode(err)
+	return code == http.StatusUnauthorized || code == http.StatusForbidden
+}
+
+// request describes one call to a service
+type request struct {
+	method      string
+	path        string // including any query string
+	body        []byte
+	contentType string
+	header      http.Header
+}
+
+// jsonRequest builds a request with a JSON body, or none if in is nil
+func jsonRequest(method, path string, in interface{}) (request, error) {
+	req := request{method: method, path: path}
+	if in != nil {
+		body, err := json.Marshal(in)
+		if err != nil {
+			return req, err
+		}
+		req.body = body
+		req.contentType = "application/json"
+	}
+	return req, nil
+}
+
+// retryable reports whether a request is safe to send again: its method is
+// idempotent, or it carries an Idempotency-Key the service deduplicates on
+func (r request) retryable() bool {
+	switch r.method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return r.header.Get("Idempotency-Key") != ""
+}
+
+// backoffDelay returns the exponential backoff for a retry attempt, jittered into [d/2, d]
+func (c *Client) backoffDelay(attempt int) time.Duration {
+	delay := c.cfg.RetryBaseDelay << uint(attempt)
+	if delay <= 0 || delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// do sends a request to svc and returns the body of a 2xx response. Requests
+// that are safe to repeat are retried on network errors and 5xx responses
+// with exponential backoff; Config.Timeout bounds the whole exchange.
+func (c *Client) do(ctx context.Context, svc Service, req request) ([]byte, error) {
+	base := c.URL(svc)
+	if base == "" {
+		return nil, fmt.Errorf("%w: %s", ErrNoServiceURL, svc)
+	}
+	url := strings.TrimRight(base, "/") + req.path
+
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	retries := 0
+	if req.retryable() {
+		retries = c.cfg.Retries
+	}
+
+	for attempt := 0; ; attempt++ {
+		httpReq, err := http.NewRequestWithContext(ctx, req.method, url, bytes.NewReader(req.body))
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range req.header {
+			httpReq.Header[key] = values
+		}
+		if req.contentType != "" {
+			httpReq.Header.Set("Content-Type", req.contentType)
+		}
+
+		var body []byte
+		resp, err := c.http.Do(httpReq)
+		if err == nil {
+			body, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		if err == nil && resp.StatusCode < 300 {
+			return body, nil
+		}
+
+		retry := err != nil || resp.StatusCode >= 500
+		if !retry || attempt >= retries {
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s %s: %w", svc, req.method, req.path, err)
+			}
+			return nil, &APIError{
+				Service:    svc,
+				Method:     req.method,
+				Path:       req.path,
+				StatusCode: resp.StatusCode,
+				Message:    strings.TrimSpace(string(body)),
+			}
+		}
+
+		select {
+		case <-time.After(c.backoffDelay(attempt)):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%s: %s %s: %w", svc, req.method, req.path, ctx.Err())
+		}
+	}
+}
+
+// doJSON sends a request and decodes the JSON response into out, unless out is nil
+func (c *Client) doJSON(ctx context.Context, svc Service, req request, out interface{}) error {
+	body, err := c.do(ctx, svc, req)
+	if err != nil || out == nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("%s: %s %s: %w: %v", svc, req.method, req.path, ErrInvalidResponse, err)
+	}
+	return nil
+}
+
+// getJSON decodes the JSON response of a GET
+func (c *Client) getJSON(ctx context.Context, svc Service, path string, out interface{}) error {
+	return c.doJSON(ctx, svc, request{method: http.MethodGet, path: path}, out)
+}
+
+// sendJSON sends in as a JSON body and decodes the JSON response into out
+func (c *Client) sendJSON(ctx context.Context, svc Service, method, path string, in, out interface{}) error {
+	req, err := jsonRequest(method, path, in)
+	if err != nil {
+		return err
+	}
+	return c.doJSON(ctx, svc, req, out)
+}
@@ -0,0 +1,351 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient points every service at srv
+func newTestClient(srv *httptest.Server, retries int) *Client {
+	return New(Config{
+		ControlPlaneURL: srv.URL,
+		GCLURL:          srv.URL,
+		CatalogURL:      srv.URL,
+		GossipURL:       srv.URL,
+		StorageURL:      srv.URL,
+		Timeout:         5 * time.Second,
+		Retries:         retries,
+		RetryBaseDelay:  time.Millisecond,
+	})
+}
+
+// call is one client method with the request it should send and a canned response
+type call struct {
+	name    string
+	method  string
+	path    string // including the query string
+	reply   string
+	invoke  func(c *Client) (interface{}, error)
+	want    interface{}
+	checkIn func(t *testing.T, r *http.Request, body []byte)
+}
+
+func jsonBody(t *testing.T, body []byte) map[string]interface{} {
+	t.Helper()
+	var v map[string]interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		t.Fatalf("request body is not JSON: %v: %s", err, body)
+	}
+	return v
+}
+
+func calls() []call {
+	ctx := context.Background()
+	return []call{
+		{
+			name: "Status", method: "GET", path: "/api/v1/status",
+			reply:  `{"healthy":true}`,
+			invoke: func(c *Client) (interface{}, error) { return c.Status(ctx, GCL) },
+			want:   Result{"healthy": true},
+		},
+		{
+			name: "Health", method: "GET", path: "/health",
+			reply:  `{"status":"healthy"}`,
+			invoke: func(c *Client) (interface{}, error) { return c.Health(ctx, Catalog) },
+			want:   Result{"status": "healthy"},
+		},
+		{
+			name: "NodeInfo", method: "GET", path: "/node/info",
+			reply:  `{"version":"1.0.0"}`,
+			invoke: func(c *Client) (interface{}, error) { return c.NodeInfo(ctx, Storage) },
+			want:   Result{"version": "1.0.0"},
+		},
+		{
+			name: "CreateSnapshot", method: "POST", path: "/api/v1/snapshots",
+			reply: `{"id":"snap-1","status":"completed","size_bytes":42}`,
+			invoke: func(c *Client) (interface{}, error) {
+				return c.CreateSnapshot(ctx, CreateSnapshotRequest{ID: "snap-1", EtcdDir: "/etcd", VolumeDir: "/vol"})
+			},
+			want: &Snapshot{ID: "snap-1", Status: "completed", SizeBytes: 42},
+			checkIn: func(t *testing.T, r *http.Request, body []byte) {
+				if got := jsonBody(t, body); got["etcd_dir"] != "/etcd" || got["volume_dir"] != "/vol" {
+					t.Errorf("unexpected body %v", got)
+				}
+			},
+		},
+		{
+			name: "RestoreSnapshot", method: "POST", path: "/api/v1/snapshots/restore",
+			reply:  `{"restored":true}`,
+			invoke: func(c *Client) (interface{}, error) { return c.RestoreSnapshot(ctx, "snap-1", "/restore") },
+			want:   Result{"restored": true},
+			checkIn: func(t *testing.T, r *http.Request, body []byte) {
+				if got := jsonBody(t, body); got["id"] != "snap-1" || got["restore_dir"] != "/restore" {
+					t.Errorf("unexpected body %v", got)
+				}
+			},
+		},
+		{
+			name: "SubmitTx", method: "POST", path: "/api/v1/transactions",
+			reply: `{"tx_hash":"abc"}`,
+			invoke: func(c *Client) (interface{}, error) {
+				return c.SubmitTx(ctx, &Transaction{ID: "tx-1", Type: "transfer", Payload: map[string]interface{}{"amount": 5}, Signature: "c2ln", PublicKey: "a2V5"})
+			},
+			want: Result{"tx_hash": "abc"},
+			checkIn: func(t *testing.T, r *http.Request, body []byte) {
+				if got := jsonBody(t, body); got["signature"] != "c2ln" || got["public_key"] != "a2V5" {
+					t.Errorf("unexpected body %v", got)
+				}
+			},
+		},
+		{
+			name: "GetTxProof", method: "GET", path: "/api/v1/transactions/abc/proof",
+			reply:  `{"tx_hash":"abc","height":7,"signatures":["s1","s2"]}`,
+			invoke: func(c *Client) (interface{}, error) { return c.GetTxProof(ctx, "abc") },
+			want:   &CommitProof{TxHash: "abc", Height: 7, Signatures: []string{"s1", "s2"}},
+		},
+		{
+			name: "AddSnapshotToCatalog", method: "POST", path: "/snapshots/add/snap-1",
+			reply: `{"status":"added","id":"snap-1"}`,
+			invoke: func(c *Client) (interface{}, error) {
+				return nil, c.AddSnapshotToCatalog(ctx, "snap-1", map[string]interface{}{"cluster": "a"})
+			},
+			checkIn: func(t *testing.T, r *http.Request, body []byte) {
+				if r.Header.Get("Idempotency-Key") == "" {
+					t.Error("missing Idempotency-Key")
+				}
+				if got := jsonBody(t, body); got["cluster"] != "a" {
+					t.Errorf("unexpected body %v", got)
+				}
+			},
+		},
+		{
+			name: "RemoveSnapshotFromCatalog", method: "DELETE", path: "/snapshots/remove/snap-1",
+			reply:  `{"status":"removed","id":"snap-1"}`,
+			invoke: func(c *Client) (interface{}, error) { return nil, c.RemoveSnapshotFromCatalog(ctx, "snap-1") },
+		},
+		{
+			name: "QueryCatalog", method: "GET", path: "/catalog/query?q=snap-1&type=snapshots",
+			reply:  `[{"id":"snap-1","metadata":{"cluster":"a"}}]`,
+			invoke: func(c *Client) (interface{}, error) { return c.QueryCatalog(ctx, "snapshots", "snap-1") },
+			want:   []CatalogEntry{{ID: "snap-1", Metadata: map[string]interface{}{"cluster": "a"}}},
+		},
+		{
+			name: "MergeCRDT", method: "POST", path: "/api/v1/crdt/merge",
+			reply: `{"type":"gcounter","key":"hits","value":3}`,
+			invoke: func(c *Client) (interface{}, error) {
+				return c.MergeCRDT(ctx, MergeRequest{Type: "gcounter", Key: "hits", Value: 3})
+			},
+			want: Result{"type": "gcounter", "key": "hits", "value": 3.0},
+		},
+		{
+			name: "GetGossipStatus", method: "GET", path: "/api/v1/status",
+			reply:  `{"node_id":"n1","peers":2,"pending_deltas":1,"vector_clock":{"n1":4}}`,
+			invoke: func(c *Client) (interface{}, error) { return c.GetGossipStatus(ctx) },
+			want:   &GossipStatus{NodeID: "n1", Peers: 2, PendingDeltas: 1, VectorClock: map[string]uint64{"n1": 4}},
+		},
+		{
+			name: "SyncGossip", method: "POST", path: "/api/v1/sync",
+			reply:  `{"status":"synced","deltas_published":3,"merkle_root":"r"}`,
+			invoke: func(c *Client) (interface{}, error) { return c.SyncGossip(ctx) },
+			want:   &SyncResult{Status: "synced", DeltasPublished: 3, MerkleRoot: "r"},
+		},
+		{
+			name: "StoreObject", method: "PUT", path: "/object?encrypt=true&id=obj-1",
+			reply: `{"id":"obj-1","chunks":["c1"],"size":5,"encrypted":true}`,
+			invoke: func(c *Client) (interface{}, error) {
+				return c.StoreObject(ctx, "obj-1", []byte("hello"), true)
+			},
+			want: &ObjectManifest{ID: "obj-1", Chunks: []string{"c1"}, Size: 5, Encrypted: true},
+			checkIn: func(t *testing.T, r *http.Request, body []byte) {
+				if string(body) != "hello" || r.Header.Get("Content-Type") != "application/octet-stream" {
+					t.Errorf("unexpected upload %q (%s)", body, r.Header.Get("Content-Type"))
+				}
+			},
+		},
+		{
+			name: "GetObject", method: "GET", path: "/object/obj-1",
+			reply:  `hello`,
+			invoke: func(c *Client) (interface{}, error) { return c.GetObject(ctx, "obj-1") },
+			want:   []byte("hello"),
+		},
+	}
+}
+
+func TestMethodsSendRequestsAndDecodeResponses(t *testing.T) {
+	for _, tc := range calls() {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != tc.method || r.URL.RequestURI() != tc.path {
+					t.Errorf("got %s %s, want %s %s", r.Method, r.URL.RequestURI(), tc.method, tc.path)
+				}
+				body, _ := io.ReadAll(r.Body)
+				if tc.checkIn != nil {
+					tc.checkIn(t, r, body)
+				}
+				io.WriteString(w, tc.reply)
+			}))
+			defer srv.Close()
+
+			got, err := tc.invoke(newTestClient(srv, 0))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.want != nil && !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMethodsMapErrorResponses(t *testing.T) {
+	for _, tc := range calls() {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "no such thing", http.StatusNotFound)
+			}))
+			defer srv.Close()
+
+			_, err := tc.invoke(newTestClient(srv, 0))
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("expected *APIError, got %v", err)
+			}
+			if !IsNotFound(err) || apiErr.Message != "no such thing" || apiErr.Method != tc.method {
+				t.Fatalf("unexpected error %+v", apiErr)
+			}
+		})
+	}
+}
+
+func TestErrorHelpers(t *testing.T) {
+	for _, tc := range []struct {
+		status       int
+		notFound     bool
+		conflict     bool
+		unauthorized bool
+	}{
+		{http.StatusNotFound, true, false, false},
+		{http.StatusConflict, false, true, false},
+		{http.StatusUnauthorized, false, false, true},
+		{http.StatusForbidden, false, false, true},
+		{http.StatusBadRequest, false, false, false},
+	} {
+		err := error(&APIError{Service: GCL, Method: "GET", Path: "/x", StatusCode: tc.status})
+		if IsNotFound(err) != tc.notFound || IsConflict(err) != tc.conflict || IsUnauthorized(err) != tc.unauthorized {
+			t.Errorf("status %d: helpers disagree", tc.status)
+		}
+	}
+	if StatusCode(errors.New("network down")) != 0 {
+		t.Error("plain errors have no status")
+	}
+}
+
+func TestIdempotentRequestsAreRetriedOn5xx(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `{"tx_hash":"abc","height":7}`)
+	}))
+	defer srv.Close()
+
+	proof, err := newTestClient(srv, 3).GetTxProof(context.Background(), "abc")
+	if err != nil || proof.Height != 7 {
+		t.Fatalf("GetTxProof returned %+v, %v", proof, err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestRetriesStopAfterConfiguredAttempts(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	_, err := newTestClient(srv, 2).Status(context.Background(), Gossip)
+	if StatusCode(err) != http.StatusBadGateway {
+		t.Fatalf("expected the last 502, got %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestPostIsOnlyRetriedWithIdempotencyKey(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	c := newTestClient(srv, 3)
+
+	if _, err := c.SyncGossip(context.Background()); StatusCode(err) != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %v", err)
+	}
+	if got := atomic.SwapInt32(&calls, 0); got != 1 {
+		t.Fatalf("expected a single attempt for POST, got %d", got)
+	}
+
+	// The catalog deduplicates on the key, so the add is safe to repeat
+	if err := c.AddSnapshotToCatalog(context.Background(), "snap-1", nil); StatusCode(err) != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 4 {
+		t.Fatalf("expected 4 attempts with an Idempotency-Key, got %d", got)
+	}
+}
+
+func TestUnconfiguredServiceAndUnreachableService(t *testing.T) {
+	c := New(Config{GossipURL: "http://127.0.0.1:1", Timeout: 2 * time.Second}) // nothing listens here
+
+	if _, err := c.GetTxProof(context.Background(), "abc"); !errors.Is(err, ErrNoServiceURL) {
+		t.Fatalf("expected ErrNoServiceURL, got %v", err)
+	}
+	_, err := c.GetGossipStatus(context.Background())
+	if err == nil || StatusCode(err) != 0 {
+		t.Fatalf("expected a network error, got %v", err)
+	}
+}
+
+func TestUndecodableResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "<html>not json</html>")
+	}))
+	defer srv.Close()
+
+	_, err := newTestClient(srv, 0).GetGossipStatus(context.Background())
+	if !errors.Is(err, ErrInvalidResponse) || StatusCode(err) != 0 {
+		t.Fatalf("expected ErrInvalidResponse, got %v", err)
+	}
+}
+
+func TestTimeoutBoundsTheCall(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	c := New(Config{GCLURL: srv.URL, Timeout: 50 * time.Millisecond})
+	start := time.Now()
+	if _, err := c.GetTxProof(context.Background(), "abc"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("call took %v", elapsed)
+	}
+}
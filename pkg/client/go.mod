@@ -0,0 +1,3 @@
+module github.com/decube/client
+
+go 1.19
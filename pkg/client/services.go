@@ -0,0 +1,239 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+)
+
+// Result is a service response without a fixed shape
+type Result map[string]interface{}
+
+// Status returns a service's GET /api/v1/status response
+func (c *Client) Status(ctx context.Context, svc Service) (Result, error) {
+	var status Result
+	err := c.getJSON(ctx, svc, "/api/v1/status", &status)
+	return status, err
+}
+
+// Health returns a service's GET /health response
+func (c *Client) Health(ctx context.Context, svc Service) (Result, error) {
+	var health Result
+	err := c.getJSON(ctx, svc, "/health", &health)
+	return health, err
+}
+
+// NodeInfo returns a service's GET /node/info response
+func (c *Client) NodeInfo(ctx context.Context, svc Service) (Result, error) {
+	var info Result
+	err := c.getJSON(ctx, svc, "/node/info", &info)
+	return info, err
+}
+
+// Control plane
+
+// CreateSnapshotRequest is the body of a snapshot request to the control plane
+type CreateSnapshotRequest struct {
+	ID        string `json:"id"`
+	EtcdDir   string `json:"etcd_dir"`
+	VolumeDir string `json:"volume_dir"`
+}
+
+// Snapshot is a snapshot as recorded by the control plane
+type Snapshot struct {
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	Status       string            `json:"status"`
+	CreatedAt    string            `json:"created_at"`
+	SizeBytes    int64             `json:"size_bytes"`
+	EtcdRevision string            `json:"etcd_revision"`
+	Checksum     string            `json:"checksum"`
+	Metadata     map[string]string `json:"metadata"`
+}
+
+// CreateSnapshot asks the control plane to take a snapshot
+func (c *Client) CreateSnapshot(ctx context.Context, req CreateSnapshotRequest) (*Snapshot, error) {
+	var snapshot Snapshot
+	if err := c.sendJSON(ctx, ControlPlane, http.MethodPost, "/api/v1/snapshots", req, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// RestoreSnapshot asks the control plane to restore a snapshot into restoreDir
+func (c *Client) RestoreSnapshot(ctx context.Context, id, restoreDir string) (Result, error) {
+	var result Result
+	err := c.sendJSON(ctx, ControlPlane, http.MethodPost, "/api/v1/snapshots/restore",
+		map[string]string{"id": id, "restore_dir": restoreDir}, &result)
+	return result, err
+}
+
+// GCL
+
+// Transaction is a signed GCL transaction. The signature covers the
+// canonical JSON of ID, Type, Payload and Priority; Signature and PublicKey
+// are base64.
+type Transaction struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	Payload   map[string]interface{} `json:"payload"`
+	Priority  int                    `json:"priority,omitempty"`
+	Signature string                 `json:"signature"`
+	PublicKey string                 `json:"public_key"`
+}
+
+// CommitProof shows that a transaction was committed in a block
+type CommitProof struct {
+	TxHash     string   `json:"tx_hash"`
+	BlockHash  string   `json:"block_hash"`
+	Height     int64    `json:"height"`
+	Signatures []string `json:"signatures"`
+}
+
+// SubmitTx publishes a signed transaction to the GCL. It is not retried,
+// since the GCL may have accepted a request whose response was lost.
+func (c *Client) SubmitTx(ctx context.Context, tx *Transaction) (Result, error) {
+	var result Result
+	err := c.sendJSON(ctx, GCL, http.MethodPost, "/api/v1/transactions", tx, &result)
+	return result, err
+}
+
+// GetTxProof returns the commit proof of a transaction
+func (c *Client) GetTxProof(ctx context.Context, txHash string) (*CommitProof, error) {
+	var proof CommitProof
+	if err := c.getJSON(ctx, GCL, "/api/v1/transactions/"+url.PathEscape(txHash)+"/proof", &proof); err != nil {
+		return nil, err
+	}
+	return &proof, nil
+}
+
+// Catalog
+
+// CatalogEntry is a catalog query result
+type CatalogEntry struct {
+	ID       string                 `json:"id"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+// MergeRequest merges a value into a CRDT in the catalog
+type MergeRequest struct {
+	Type  string      `json:"type"`
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// AddSnapshotToCatalog records a snapshot and its metadata in the catalog.
+// The request carries an Idempotency-Key, so it is retried like a PUT.
+func (c *Client) AddSnapshotToCatalog(ctx context.Context, id string, metadata map[string]interface{}) error {
+	req, err := jsonRequest(http.MethodPost, "/snapshots/add/"+url.PathEscape(id), metadata)
+	if err != nil {
+		return err
+	}
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	req.header = http.Header{"Idempotency-Key": []string{hex.EncodeToString(key)}}
+	return c.doJSON(ctx, Catalog, req, nil)
+}
+
+// RemoveSnapshotFromCatalog removes a snapshot from the catalog
+func (c *Client) RemoveSnapshotFromCatalog(ctx context.Context, id string) error {
+	return c.doJSON(ctx, Catalog, request{method: http.MethodDelete, path: "/snapshots/remove/" + url.PathEscape(id)}, nil)
+}
+
+// QueryCatalog returns the catalog entries of a type, "snapshots" or
+// "images", matching query; an empty query matches every entry
+func (c *Client) QueryCatalog(ctx context.Context, entryType, query string) ([]CatalogEntry, error) {
+	params := url.Values{"type": {entryType}}
+	if query != "" {
+		params.Set("q", query)
+	}
+	var entries []CatalogEntry
+	err := c.getJSON(ctx, Catalog, "/catalog/query?"+params.Encode(), &entries)
+	return entries, err
+}
+
+// MergeCRDT merges a value into a catalog CRDT and returns the merged state
+func (c *Client) MergeCRDT(ctx context.Context, req MergeRequest) (Result, error) {
+	var result Result
+	err := c.sendJSON(ctx, Catalog, http.MethodPost, "/api/v1/crdt/merge", req, &result)
+	return result, err
+}
+
+// Gossip
+
+// GossipStatus is the state of a gossip node
+type GossipStatus struct {
+	NodeID        string            `json:"node_id"`
+	MerkleRoot    string            `json:"merkle_root"`
+	Peers         int               `json:"peers"`
+	Snapshots     int               `json:"snapshots"`
+	PendingDeltas int               `json:"pending_deltas"`
+	VectorClock   map[string]uint64 `json:"vector_clock"`
+}
+
+// SyncResult is the outcome of a gossip synchronization
+type SyncResult struct {
+	Status          string `json:"status"`
+	DeltasPublished int    `json:"deltas_published"`
+	MerkleRoot      string `json:"merkle_root"`
+}
+
+// GetGossipStatus returns the state of the gossip node
+func (c *Client) GetGossipStatus(ctx context.Context) (*GossipStatus, error) {
+	var status GossipStatus
+	if err := c.getJSON(ctx, Gossip, "/api/v1/status", &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// SyncGossip publishes the gossip node's pending deltas to its peers
+func (c *Client) SyncGossip(ctx context.Context) (*SyncResult, error) {
+	var result SyncResult
+	if err := c.doJSON(ctx, Gossip, request{method: http.MethodPost, path: "/api/v1/sync"}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Object storage
+
+// ObjectManifest lists the chunks an object is stored in
+type ObjectManifest struct {
+	ID        string   `json:"id"`
+	Chunks    []string `json:"chunks"`
+	Size      int64    `json:"size"`
+	Encrypted bool     `json:"encrypted"`
+}
+
+// StoreObject stores data under id, or under the CID of data if id is
+// empty, optionally encrypted at rest
+func (c *Client) StoreObject(ctx context.Context, id string, data []byte, encrypt bool) (*ObjectManifest, error) {
+	params := url.Values{}
+	if id != "" {
+		params.Set("id", id)
+	}
+	if encrypt {
+		params.Set("encrypt", "true")
+	}
+	path := "/object"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+
+	var manifest ObjectManifest
+	req := request{method: http.MethodPut, path: path, body: data, contentType: "application/octet-stream"}
+	if err := c.doJSON(ctx, Storage, req, &manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// GetObject returns the content of a stored object
+func (c *Client) GetObject(ctx context.Context, id string) ([]byte, error) {
+	return c.do(ctx, Storage, request{method: http.MethodGet, path: "/object/" + url.PathEscape(id)})
+}
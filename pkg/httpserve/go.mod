@@ -0,0 +1,3 @@
+module github.com/decube/httpserve
+
+go 1.19
@@ -0,0 +1,69 @@
+// Package httpserve runs the standalone DeCube HTTP services: it picks the
+// listen port from --port or PORT and shuts the server down gracefully on
+// SIGINT or SIGTERM.
+package httpserve
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ShutdownTimeout bounds how long in-flight requests get to finish on shutdown
+const ShutdownTimeout = 15 * time.Second
+
+// DefaultPort is the listen port when neither --port nor PORT is set
+const DefaultPort = 8080
+
+// PortFromEnv returns the PORT environment variable, or DefaultPort if it is unset
+func PortFromEnv() (int, error) {
+	v := os.Getenv("PORT")
+	if v == "" {
+		return DefaultPort, nil
+	}
+	port, err := strconv.Atoi(v)
+	if err != nil || port < 0 || port > 65535 {
+		return 0, fmt.Errorf("invalid PORT %q", v)
+	}
+	return port, nil
+}
+
+// PortAddr is the address listening on port on all interfaces; port 0 picks a free port
+func PortAddr(port int) string {
+	return ":" + strconv.Itoa(port)
+}
+
+// ServeUntilSignal serves handler on lis until SIGINT or SIGTERM, then stops
+// accepting connections and lets in-flight requests finish. It returns once
+// the server has stopped, so callers can close their stores afterwards.
+func ServeUntilSignal(lis net.Listener, handler http.Handler) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	return Serve(ctx, lis, &http.Server{Handler: handler}, ShutdownTimeout)
+}
+
+// Serve runs srv on lis until ctx is done, then shuts it down, waiting up to
+// timeout for in-flight requests
+func Serve(ctx context.Context, lis net.Listener, srv *http.Server, timeout time.Duration) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(lis)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
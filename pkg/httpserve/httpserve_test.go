@@ -0,0 +1,87 @@
+package httpserve
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPortFromEnv(t *testing.T) {
+	tests := []struct {
+		env     string
+		want    int
+		wantErr bool
+	}{
+		{env: "", want: DefaultPort},
+		{env: "9090", want: 9090},
+		{env: "0", want: 0},
+		{env: "http", wantErr: true},
+		{env: "-1", wantErr: true},
+		{env: "65536", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Setenv("PORT", tt.env)
+		port, err := PortFromEnv()
+		if (err != nil) != tt.wantErr || port != tt.want {
+			t.Errorf("PORT=%q: got %d, %v", tt.env, port, err)
+		}
+	}
+}
+
+func TestServeFinishesInFlightRequestOnShutdown(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+
+	// Port 0 picks a free port, as PORT=0 does for the services
+	lis, err := net.Listen("tcp", "127.0.0.1"+PortAddr(0))
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	if _, bound, _ := net.SplitHostPort(lis.Addr().String()); bound == "0" {
+		t.Fatalf("no port was picked: %s", lis.Addr())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(ctx, lis, &http.Server{Handler: handler}, 5*time.Second)
+	}()
+
+	type result struct {
+		body string
+		err  error
+	}
+	got := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + lis.Addr().String() + "/slow")
+		if err != nil {
+			got <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		got <- result{string(body), err}
+	}()
+
+	<-started
+	cancel()
+
+	res := <-got
+	if res.err != nil || res.body != "done" {
+		t.Fatalf("in-flight request did not complete: body %q, err %v", res.body, res.err)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+
+	if _, err := http.Get("http://" + lis.Addr().String() + "/slow"); err == nil {
+		t.Fatal("server still accepting requests after shutdown")
+	}
+}
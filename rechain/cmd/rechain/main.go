@@ -2,12 +2,17 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/base64"
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
+	"time"
 
 	"github.com/rechain/rechain/internal/api"
 	"github.com/rechain/rechain/internal/cas"
@@ -16,6 +21,8 @@ import (
 	"github.com/rechain/rechain/internal/gossip"
 	"github.com/rechain/rechain/internal/security"
 	"github.com/rechain/rechain/internal/storage"
+	"github.com/rechain/rechain/pkg/config"
+	"github.com/rechain/rechain/pkg/logging"
 	"github.com/spf13/viper"
 )
 
@@ -29,6 +36,20 @@ func main() {
 		log.Fatalf("Error initializing config: %v", err)
 	}
 
+	// Reject bad settings before any subsystem starts
+	cfg := config.DefaultConfig()
+	if err := viper.Unmarshal(cfg); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+
+	// Route all logging through the configured level and format
+	if err := logging.Setup(cfg.Logging); err != nil {
+		log.Fatalf("Failed to initialize logging: %v", err)
+	}
+
 	// Create context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -41,10 +62,57 @@ func main() {
 	defer store.Close()
 
 	// Initialize security
-	keyManager, err := security.NewKeyManager()
+	keyManager, err := security.LoadOrCreateKeyManagerWithAlgorithm(
+		filepath.Join(viper.GetString("node.data_dir"), "node_key.pem"),
+		viper.GetString("security.signature_algorithm"),
+	)
 	if err != nil {
 		log.Fatalf("Failed to initialize security: %v", err)
 	}
+	pubKey, err := keyManager.PublicKeyBytes()
+	if err != nil {
+		log.Fatalf("Failed to initialize security: %v", err)
+	}
+	log.Printf("Node public key (validator pub_key): %s", base64.StdEncoding.EncodeToString(pubKey))
+
+	// Initialize HSM
+	if viper.GetBool("security.hsm_enabled") {
+		hsm, err := security.NewHSMManager(security.HSMConfig{
+			Backend:    viper.GetString("security.hsm_backend"),
+			ModulePath: viper.GetString("security.hsm_module_path"),
+			Slot:       viper.GetUint("security.hsm_slot"),
+			PIN:        viper.GetString("security.hsm_pin"),
+			KeyID:      viper.GetString("security.hsm_key_label"),
+		})
+		if err != nil {
+			log.Fatalf("Failed to initialize HSM: %v", err)
+		}
+		defer hsm.Close()
+
+		// Consensus still signs with the node key above; reading the HSM key
+		// checks the device holds it and lets operators record it
+		hsmKey, err := hsm.PublicKeyBytes()
+		if err != nil {
+			log.Fatalf("Failed to read HSM key %q: %v", viper.GetString("security.hsm_key_label"), err)
+		}
+		log.Printf("HSM public key: %s", base64.StdEncoding.EncodeToString(hsmKey))
+	}
+
+	// Initialize audit log
+	auditLogger := security.NewAuditLogger(false)
+	if viper.GetBool("security.audit_enabled") {
+		auditLogger, err = security.NewFileAuditLogger(security.AuditConfig{
+			Path:       viper.GetString("security.audit_log_path"),
+			MaxSize:    viper.GetInt("logging.max_size"),
+			MaxBackups: viper.GetInt("logging.max_backups"),
+			MaxAge:     viper.GetInt("logging.max_age"),
+		})
+		if err != nil {
+			log.Fatalf("Failed to initialize audit log: %v", err)
+		}
+	}
+	defer auditLogger.Close()
+	auditLogger.LogSecurityEvent("node_start", "key algorithm "+keyManager.Algorithm())
 
 	// Initialize CAS
 	casStore, err := cas.NewCAS(
@@ -57,9 +125,21 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to initialize CAS: %v", err)
 	}
+	hashAlg, err := cas.ParseHashAlgorithm(viper.GetString("cas.hash_algorithm"))
+	if err != nil {
+		log.Fatalf("Invalid CAS config: %v", err)
+	}
+	casStore.SetHashAlgorithm(hashAlg)
 
 	// Initialize gossip protocol
-	gossipProto, err := gossip.NewGossipProtocol(viper.GetString("network.listen_address"))
+	gossipAuth, err := gossip.NewPeerAuthorizer(
+		gossip.AuthMode(viper.GetString("gossip.auth_mode")),
+		viper.GetStringSlice("gossip.allowed_peers"),
+	)
+	if err != nil {
+		log.Fatalf("Invalid gossip auth config: %v", err)
+	}
+	gossipProto, err := gossip.NewGossipProtocolWithAuth(viper.GetString("network.listen_address"), gossipAuth)
 	if err != nil {
 		log.Fatalf("Failed to initialize gossip: %v", err)
 	}
@@ -73,10 +153,49 @@ func main() {
 	}
 
 	// Initialize consensus
-	consensusEngine, err := consensus.NewConsensus(store, nil) // P2P will be integrated later
+	nodeID := viper.GetString("node.id")
+	if nodeID == "" {
+		nodeID, _ = os.Hostname()
+	}
+	validators, err := loadValidators(nodeID)
+	if err != nil {
+		log.Fatalf("Failed to load validator set: %v", err)
+	}
+	genesisTime, err := time.Parse(time.RFC3339, viper.GetString("consensus.genesis_time"))
+	if err != nil {
+		log.Fatalf("Invalid genesis time: %v", err)
+	}
+	// Transaction types the chain accepts, applied to the catalog as they commit
+	txRegistry := consensus.NewTxRegistry()
+	if err := txRegistry.Register(gossip.RegisterSnapshotTxType, gossipProto.SnapshotTxHandler()); err != nil {
+		log.Fatalf("Failed to register transaction types: %v", err)
+	}
+	consensusEngine, err := consensus.NewConsensusWithConfig(store, gossipProto, &consensus.Config{
+		NodeID:         nodeID,
+		BlockInterval:  viper.GetDuration("consensus.block_time"),
+		Timeout:        viper.GetDuration("consensus.timeout_propose"),
+		Validators:     validators,
+		AuditLogger:    auditLogger,
+		Signer:         keyManager,
+		MaxMempoolSize: viper.GetInt("consensus.max_mempool_size"),
+		MaxBlockTxs:    viper.GetInt("consensus.max_txs_per_block"),
+		MaxBlockBytes:  viper.GetInt("consensus.max_block_size"),
+		Genesis: &consensus.Genesis{
+			ChainID:    viper.GetString("consensus.chain_id"),
+			Time:       genesisTime,
+			Validators: validators,
+		},
+		SnapshotInterval: viper.GetUint64("consensus.snapshot_interval"),
+		PruneRetention:   viper.GetUint64("consensus.prune_retention"),
+		StateProvider:    gossipProto,
+		TxRegistry:       txRegistry,
+	})
 	if err != nil {
 		log.Fatalf("Failed to initialize consensus: %v", err)
 	}
+	if err := consensusEngine.Start(); err != nil {
+		log.Fatalf("Failed to start consensus: %v", err)
+	}
 	defer consensusEngine.Stop()
 
 	// Initialize GCL node (legacy, will be replaced by gossip)
@@ -93,22 +212,57 @@ func main() {
 
 	// Initialize API servers
 	restServer := api.NewServer(consensusEngine, store, casStore, gossipProto, keyManager)
+	cors := api.DefaultCORSConfig(viper.GetStringSlice("api.cors_allowed_origins"))
+	cors.Enabled = viper.GetBool("api.enable_cors")
+	if methods := viper.GetStringSlice("api.cors_allowed_methods"); len(methods) > 0 {
+		cors.AllowedMethods = methods
+	}
+	if headers := viper.GetStringSlice("api.cors_allowed_headers"); len(headers) > 0 {
+		cors.AllowedHeaders = headers
+	}
+	restServer.EnableCORS(cors)
+	rateLimit := api.DefaultRateLimitConfig(viper.GetFloat64("api.rate_limit_rps"), viper.GetInt("api.rate_limit_burst"))
+	rateLimit.Enabled = viper.GetBool("api.rate_limiting_enabled")
+	restServer.EnableRateLimiting(rateLimit)
+	restServer.EnableRequestLimits(api.DefaultRequestLimitConfig(viper.GetInt64("api.max_body_bytes"), viper.GetDuration("api.request_timeout")))
 	grpcServer, err := api.NewGRPCServer(restServer)
 	if err != nil {
 		log.Fatalf("Failed to create gRPC server: %v", err)
 	}
 
+	// Load the certificates up front so a bad TLS setup stops the node
+	// instead of leaving it running without REST
+	var restTLS *tls.Config
+	if viper.GetBool("security.tls_enabled") {
+		restTLS, err = api.LoadTLSConfig(api.TLSOptions{
+			CertFile:           viper.GetString("security.cert_file"),
+			KeyFile:            viper.GetString("security.key_file"),
+			CAFile:             viper.GetString("security.ca_file"),
+			ClientCertRequired: viper.GetBool("security.client_cert_required"),
+		})
+		if err != nil {
+			log.Fatalf("Failed to load TLS config: %v", err)
+		}
+	}
+
 	// Start API servers
 	go func() {
-		restAddr := viper.GetString("api.rest_address")
+		restAddr := cfg.API.RESTAddress
 		log.Printf("Starting REST API server on %s", restAddr)
-		if err := restServer.Start(restAddr); err != nil {
+
+		var err error
+		if restTLS != nil {
+			err = restServer.ServeTLS(restAddr, restTLS)
+		} else {
+			err = restServer.Start(restAddr)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Printf("REST API server error: %v", err)
 		}
 	}()
 
 	go func() {
-		grpcAddr := viper.GetString("api.grpc_address")
+		grpcAddr := cfg.API.GRPCAddress
 		log.Printf("Starting gRPC API server on %s", grpcAddr)
 		if err := grpcServer.Start(grpcAddr); err != nil {
 			log.Printf("gRPC API server error: %v", err)
@@ -127,6 +281,7 @@ func main() {
 
 	// Shutdown sequence
 	log.Println("Shutting down...")
+	auditLogger.LogSecurityEvent("node_stop", "received shutdown signal")
 
 	if err := grpcServer.Stop(); err != nil {
 		log.Printf("Error stopping gRPC server: %v", err)
@@ -187,11 +342,18 @@ func setDefaults() {
 
 	// Consensus defaults
 	viper.SetDefault("consensus.type", "bft")
+	viper.SetDefault("consensus.chain_id", "rechain-mainnet")
+	viper.SetDefault("consensus.genesis_time", "1970-01-01T00:00:00Z")
 	viper.SetDefault("consensus.block_time", "1s")
 	viper.SetDefault("consensus.timeout_propose", "3s")
 	viper.SetDefault("consensus.timeout_prevote", "1s")
 	viper.SetDefault("consensus.timeout_precommit", "1s")
 	viper.SetDefault("consensus.timeout_commit", "1s")
+	viper.SetDefault("consensus.max_mempool_size", 5000)
+	viper.SetDefault("consensus.max_block_size", 1024*1024)
+	viper.SetDefault("consensus.max_txs_per_block", 1000)
+	viper.SetDefault("consensus.snapshot_interval", 1000)
+	viper.SetDefault("consensus.prune_retention", 0)
 
 	// CAS defaults
 	viper.SetDefault("cas.endpoint", "http://localhost:9000")
@@ -200,6 +362,7 @@ func setDefaults() {
 	viper.SetDefault("cas.bucket", "rechain-cas")
 	viper.SetDefault("cas.use_ssl", false)
 	viper.SetDefault("cas.chunk_size", 64*1024*1024)
+	viper.SetDefault("cas.hash_algorithm", "sha256")
 	viper.SetDefault("cas.max_retries", 3)
 
 	// Gossip defaults
@@ -208,6 +371,8 @@ func setDefaults() {
 	viper.SetDefault("gossip.interval", "1s")
 	viper.SetDefault("gossip.anti_entropy_interval", "30s")
 	viper.SetDefault("gossip.message_ttl", 10)
+	viper.SetDefault("gossip.auth_mode", "open")
+	viper.SetDefault("gossip.allowed_peers", []string{})
 
 	// API defaults
 	viper.SetDefault("api.enabled", true)
@@ -217,16 +382,24 @@ func setDefaults() {
 	viper.SetDefault("api.cors_allowed_origins", []string{"*"})
 	viper.SetDefault("api.rate_limiting_enabled", true)
 	viper.SetDefault("api.rate_limit_rps", 100)
+	viper.SetDefault("api.rate_limit_burst", 200)
+	viper.SetDefault("api.max_body_bytes", 256<<20)
+	viper.SetDefault("api.request_timeout", "60s")
 
 	// Security defaults
-	viper.SetDefault("security.tls_enabled", true)
+	viper.SetDefault("security.tls_enabled", false)
 	viper.SetDefault("security.cert_file", "./certs/server.crt")
 	viper.SetDefault("security.key_file", "./certs/server.key")
 	viper.SetDefault("security.ca_file", "./certs/ca.crt")
 	viper.SetDefault("security.client_cert_required", false)
+	viper.SetDefault("security.signature_algorithm", "rsa")
 	viper.SetDefault("security.hsm_enabled", false)
-	viper.SetDefault("security.hsm_address", "tcp://localhost:12345")
+	viper.SetDefault("security.hsm_backend", "pkcs11")
+	viper.SetDefault("security.hsm_module_path", "")
+	viper.SetDefault("security.hsm_slot", 0)
+	viper.SetDefault("security.hsm_key_label", "rechain-node")
 	viper.SetDefault("security.audit_enabled", true)
+	viper.SetDefault("security.audit_log_path", "./logs/audit.log")
 
 	// Monitoring defaults
 	viper.SetDefault("monitoring.prometheus_enabled", true)
@@ -235,6 +408,7 @@ func setDefaults() {
 	viper.SetDefault("monitoring.health_check_enabled", true)
 
 	// Logging defaults
+	viper.SetDefault("logging.level", "info")
 	viper.SetDefault("logging.format", "json")
 	viper.SetDefault("logging.output", "stdout")
 	viper.SetDefault("logging.max_size", 100)
@@ -261,3 +435,21 @@ func setDefaults() {
 	viper.SetDefault("development.pprof_enabled", false)
 	viper.SetDefault("development.mock_services", false)
 }
+
+// loadValidators reads consensus.validators, defaulting to a single-validator set
+// containing only this node
+func loadValidators(nodeID string) ([]consensus.Validator, error) {
+	var configured []config.ValidatorConfig
+	if err := viper.UnmarshalKey("consensus.validators", &configured); err != nil {
+		return nil, err
+	}
+	if len(configured) == 0 {
+		return []consensus.Validator{{ID: nodeID, VotingPower: 1}}, nil
+	}
+
+	validators := make([]consensus.Validator, len(configured))
+	for i, v := range configured {
+		validators[i] = consensus.Validator{ID: v.ID, PubKey: v.PubKey, VotingPower: v.VotingPower}
+	}
+	return validators, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rechain/rechain/api/proto"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
+
+var (
+	grpcAddr string
+	useTLS   bool
+	caFile   string
+)
+
+// maxMessageSize matches the node's gRPC message limit so large CAS reads are not rejected client-side
+const maxMessageSize = 16 * 1024 * 1024
+
+// rpcConn is the node connection shared by every subcommand. ownsConn records whether
+// openConn created it, so a connection supplied by the caller is left open.
+var (
+	rpcConn  *grpc.ClientConn
+	ownsConn bool
+)
+
+// transportCredentials returns TLS credentials when --tls or --ca is set, and plaintext otherwise
+func transportCredentials() (credentials.TransportCredentials, error) {
+	if !useTLS && caFile == "" {
+		return insecure.NewCredentials(), nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+	return credentials.NewTLS(cfg), nil
+}
+
+// dialNode creates a client connection to addr. The connection is established lazily on the
+// first RPC and kept alive between calls.
+func dialNode(addr string) (*grpc.ClientConn, error) {
+	creds, err := transportCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	return grpc.NewClient(addr,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                30 * time.Second,
+			Timeout:             10 * time.Second,
+			PermitWithoutStream: true,
+		}),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMessageSize)),
+	)
+}
+
+// openConn is the root PersistentPreRunE; it connects to --grpc-addr unless a connection exists
+func openConn(cmd *cobra.Command, args []string) error {
+	if rpcConn != nil {
+		return nil
+	}
+
+	conn, err := dialNode(grpcAddr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", grpcAddr, err)
+	}
+	rpcConn = conn
+	ownsConn = true
+	return nil
+}
+
+// closeConn is the root PersistentPostRun; it closes the connection openConn created
+func closeConn(cmd *cobra.Command, args []string) {
+	if !ownsConn {
+		return
+	}
+	rpcConn.Close()
+	rpcConn = nil
+	ownsConn = false
+}
+
+// rpcClient returns a service client over the shared connection
+func rpcClient() proto.RechainServiceClient {
+	return proto.NewRechainServiceClient(rpcConn)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rechain/rechain/api/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+type testNodeServer struct {
+	proto.UnimplementedRechainServiceServer
+}
+
+func (testNodeServer) GetNodeInfo(ctx context.Context, req *proto.NodeInfoRequest) (*proto.NodeInfoResponse, error) {
+	return &proto.NodeInfoResponse{NodeId: "node-1", Version: "0.1.0"}, nil
+}
+
+func (testNodeServer) GetLatestBlock(ctx context.Context, req *proto.GetLatestBlockRequest) (*proto.BlockResponse, error) {
+	return &proto.BlockResponse{Block: &proto.Block{Height: 42}, Found: true}, nil
+}
+
+// countingListener counts accepted connections
+type countingListener struct {
+	net.Listener
+	accepted int32
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		atomic.AddInt32(&l.accepted, 1)
+	}
+	return conn, err
+}
+
+// startTestNode serves srv on a loopback port and returns its address and listener
+func startTestNode(t *testing.T, srv proto.RechainServiceServer) (string, *countingListener) {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	counting := &countingListener{Listener: lis}
+
+	server := grpc.NewServer()
+	proto.RegisterRechainServiceServer(server, srv)
+	go server.Serve(counting)
+	t.Cleanup(server.Stop)
+
+	return lis.Addr().String(), counting
+}
+
+// runCLI executes rechainctl with args and returns its stdout
+func runCLI(t *testing.T, args ...string) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	root := newRootCmd()
+	root.SetOut(&out)
+	root.SetArgs(args)
+	require.NoError(t, root.Execute())
+	return out.String()
+}
+
+func TestSubcommandsShareConnection(t *testing.T) {
+	addr, lis := startTestNode(t, testNodeServer{})
+
+	conn, err := dialNode(addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	rpcConn = conn
+	defer func() { rpcConn = nil }()
+
+	var info proto.NodeInfoResponse
+	require.NoError(t, json.Unmarshal([]byte(runCLI(t, "node", "info")), &info))
+	assert.Equal(t, "node-1", info.NodeId)
+
+	var block proto.BlockResponse
+	require.NoError(t, json.Unmarshal([]byte(runCLI(t, "block", "latest")), &block))
+	require.NotNil(t, block.Block)
+	assert.Equal(t, uint64(42), block.Block.Height)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&lis.accepted), "subcommands should reuse one connection")
+	assert.Same(t, conn, rpcConn, "a caller-supplied connection must stay open")
+}
+
+func TestOpenConnClosesOwnConnection(t *testing.T) {
+	addr, _ := startTestNode(t, testNodeServer{})
+
+	runCLI(t, "--grpc-addr", addr, "node", "info")
+	assert.Nil(t, rpcConn)
+	assert.False(t, ownsConn)
+}
+
+func TestTransportCredentials(t *testing.T) {
+	defer func() { useTLS, caFile = false, "" }()
+
+	creds, err := transportCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, "insecure", creds.Info().SecurityProtocol)
+
+	useTLS = true
+	creds, err = transportCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, "tls", creds.Info().SecurityProtocol)
+
+	useTLS, caFile = false, t.TempDir()+"/missing.pem"
+	_, err = transportCredentials()
+	assert.Error(t, err)
+}
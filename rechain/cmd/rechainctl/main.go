@@ -4,24 +4,39 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"time"
 
-	"github.com/spf13/cobra"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 	"github.com/rechain/rechain/api/proto"
+	"github.com/rechain/rechain/internal/consensus"
+	"github.com/rechain/rechain/internal/security"
+	"github.com/spf13/cobra"
 )
 
-var grpcAddr string
-
 func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// newRootCmd builds the rechainctl command tree
+func newRootCmd() *cobra.Command {
 	var rootCmd = &cobra.Command{
 		Use:   "rechainctl",
 		Short: "REChain CLI tool",
+		// One connection serves the whole invocation
+		PersistentPreRunE: openConn,
+		PersistentPostRun: closeConn,
 	}
+	// Replaced by completionCmd, which only offers the shells we document
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
 
 	rootCmd.PersistentFlags().StringVar(&grpcAddr, "grpc-addr", "localhost:9090", "gRPC server address")
+	rootCmd.PersistentFlags().BoolVar(&useTLS, "tls", false, "connect to the node over TLS")
+	rootCmd.PersistentFlags().StringVar(&caFile, "ca", "", "CA certificate used to verify the node (implies --tls)")
 
 	rootCmd.AddCommand(
 		nodeCmd(),
@@ -29,12 +44,11 @@ func main() {
 		txCmd(),
 		casCmd(),
 		gossipCmd(),
+		versionCmd(),
+		completionCmd(),
 	)
 
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
-	}
+	return rootCmd
 }
 
 func nodeCmd() *cobra.Command {
@@ -48,38 +62,24 @@ func nodeCmd() *cobra.Command {
 			Use:   "info",
 			Short: "Get node information",
 			Run: func(cmd *cobra.Command, args []string) {
-				conn, err := grpc.Dial(grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-				if err != nil {
-					log.Fatalf("Failed to connect: %v", err)
-				}
-				defer conn.Close()
-
-				client := proto.NewRechainServiceClient(conn)
-				resp, err := client.GetNodeInfo(context.Background(), &proto.NodeInfoRequest{})
+				resp, err := rpcClient().GetNodeInfo(context.Background(), &proto.NodeInfoRequest{})
 				if err != nil {
 					log.Fatalf("Failed to get node info: %v", err)
 				}
 
-				printJSON(resp)
+				printJSON(cmd.OutOrStdout(), resp)
 			},
 		},
 		&cobra.Command{
 			Use:   "peers",
 			Short: "Get connected peers",
 			Run: func(cmd *cobra.Command, args []string) {
-				conn, err := grpc.Dial(grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-				if err != nil {
-					log.Fatalf("Failed to connect: %v", err)
-				}
-				defer conn.Close()
-
-				client := proto.NewRechainServiceClient(conn)
-				resp, err := client.GetPeers(context.Background(), &proto.PeersRequest{})
+				resp, err := rpcClient().GetPeers(context.Background(), &proto.PeersRequest{})
 				if err != nil {
 					log.Fatalf("Failed to get peers: %v", err)
 				}
 
-				printJSON(resp)
+				printJSON(cmd.OutOrStdout(), resp)
 			},
 		},
 	)
@@ -101,38 +101,24 @@ func blockCmd() *cobra.Command {
 			Run: func(cmd *cobra.Command, args []string) {
 				height := parseUint64(args[0])
 
-				conn, err := grpc.Dial(grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-				if err != nil {
-					log.Fatalf("Failed to connect: %v", err)
-				}
-				defer conn.Close()
-
-				client := proto.NewRechainServiceClient(conn)
-				resp, err := client.GetBlock(context.Background(), &proto.GetBlockRequest{Height: height})
+				resp, err := rpcClient().GetBlock(context.Background(), &proto.GetBlockRequest{Height: height})
 				if err != nil {
 					log.Fatalf("Failed to get block: %v", err)
 				}
 
-				printJSON(resp)
+				printJSON(cmd.OutOrStdout(), resp)
 			},
 		},
 		&cobra.Command{
 			Use:   "latest",
 			Short: "Get latest block",
 			Run: func(cmd *cobra.Command, args []string) {
-				conn, err := grpc.Dial(grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-				if err != nil {
-					log.Fatalf("Failed to connect: %v", err)
-				}
-				defer conn.Close()
-
-				client := proto.NewRechainServiceClient(conn)
-				resp, err := client.GetLatestBlock(context.Background(), &proto.GetLatestBlockRequest{})
+				resp, err := rpcClient().GetLatestBlock(context.Background(), &proto.GetLatestBlockRequest{})
 				if err != nil {
 					log.Fatalf("Failed to get latest block: %v", err)
 				}
 
-				printJSON(resp)
+				printJSON(cmd.OutOrStdout(), resp)
 			},
 		},
 	)
@@ -146,53 +132,112 @@ func txCmd() *cobra.Command {
 		Short: "Transaction operations",
 	}
 
+	var (
+		priority int32
+		keyPath  string
+		txID     string
+	)
+	submitCmd := &cobra.Command{
+		Use:   "submit [type] [payload]",
+		Short: "Sign and submit a transaction",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			txType := args[0]
+			payload := []byte(args[1])
+
+			var fields map[string]interface{}
+			if err := json.Unmarshal(payload, &fields); err != nil {
+				log.Fatalf("Payload must be a JSON object: %v", err)
+			}
+			km, err := security.LoadOrCreateKeyManagerWithAlgorithm(keyPath, security.AlgorithmEd25519)
+			if err != nil {
+				log.Fatalf("Failed to load signing key: %v", err)
+			}
+			publicKey, err := km.PublicKeyBytes()
+			if err != nil {
+				log.Fatalf("Failed to encode public key: %v", err)
+			}
+			if txID == "" {
+				txID = fmt.Sprintf("tx-%d", time.Now().UnixNano())
+			}
+			data, err := security.SubmittedTxSigningBytes(txID, txType, fields, int(priority))
+			if err != nil {
+				log.Fatalf("Failed to encode transaction: %v", err)
+			}
+			signature, err := km.SignData(data)
+			if err != nil {
+				log.Fatalf("Failed to sign transaction: %v", err)
+			}
+
+			resp, err := rpcClient().SubmitTx(context.Background(), &proto.SubmitTxRequest{
+				Id:        txID,
+				Type:      txType,
+				Payload:   payload,
+				Priority:  priority,
+				PublicKey: publicKey,
+				Signature: signature,
+			})
+			if err != nil {
+				log.Fatalf("Failed to submit transaction: %v", err)
+			}
+
+			printJSON(cmd.OutOrStdout(), resp)
+		},
+	}
+	submitCmd.Flags().Int32Var(&priority, "priority", 0, "higher priority transactions are proposed first")
+	submitCmd.Flags().StringVar(&keyPath, "key", "client.key", "PEM key the transaction is signed with, created on first use")
+	submitCmd.Flags().StringVar(&txID, "id", "", "transaction ID, used once per key (default: generated)")
+
 	cmd.AddCommand(
+		submitCmd,
 		&cobra.Command{
-			Use:   "submit [type] [payload]",
-			Short: "Submit a transaction",
-			Args:  cobra.ExactArgs(2),
+			Use:   "get [hash]",
+			Short: "Get transaction by hash",
+			Args:  cobra.ExactArgs(1),
 			Run: func(cmd *cobra.Command, args []string) {
-				txType := args[0]
-				payload := []byte(args[1])
+				hash := args[0]
 
-				conn, err := grpc.Dial(grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+				resp, err := rpcClient().GetTx(context.Background(), &proto.GetTxRequest{Hash: hash})
 				if err != nil {
-					log.Fatalf("Failed to connect: %v", err)
+					log.Fatalf("Failed to get transaction: %v", err)
 				}
-				defer conn.Close()
 
-				client := proto.NewRechainServiceClient(conn)
-				resp, err := client.SubmitTx(context.Background(), &proto.SubmitTxRequest{
-					Type:    txType,
-					Payload: payload,
-				})
+				printJSON(cmd.OutOrStdout(), resp)
+			},
+		},
+		&cobra.Command{
+			Use:   "status [hash]",
+			Short: "Get whether a transaction is pending, committed or confirmed",
+			Args:  cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				hash := args[0]
+
+				resp, err := rpcClient().GetTxStatus(context.Background(), &proto.GetTxRequest{Hash: hash})
 				if err != nil {
-					log.Fatalf("Failed to submit transaction: %v", err)
+					log.Fatalf("Failed to get transaction status: %v", err)
 				}
 
-				printJSON(resp)
+				printJSON(cmd.OutOrStdout(), resp)
 			},
 		},
 		&cobra.Command{
-			Use:   "get [hash]",
-			Short: "Get transaction by hash",
+			Use:   "proof [hash]",
+			Short: "Get a Merkle inclusion proof for a committed transaction",
 			Args:  cobra.ExactArgs(1),
 			Run: func(cmd *cobra.Command, args []string) {
 				hash := args[0]
 
-				conn, err := grpc.Dial(grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+				resp, err := rpcClient().GetTxProof(context.Background(), &proto.GetTxRequest{Hash: hash})
 				if err != nil {
-					log.Fatalf("Failed to connect: %v", err)
+					log.Fatalf("Failed to get transaction proof: %v", err)
 				}
-				defer conn.Close()
 
-				client := proto.NewRechainServiceClient(conn)
-				resp, err := client.GetTx(context.Background(), &proto.GetTxRequest{Hash: hash})
-				if err != nil {
-					log.Fatalf("Failed to get transaction: %v", err)
+				// Refuse to print a proof that does not lead to the root it claims
+				if !txProof(resp).Verify() {
+					log.Fatalf("Proof for %s does not verify against tx root %x", hash, resp.TxRoot)
 				}
 
-				printJSON(resp)
+				printJSON(cmd.OutOrStdout(), resp)
 			},
 		},
 	)
@@ -219,14 +264,7 @@ func casCmd() *cobra.Command {
 					log.Fatalf("Failed to read file: %v", err)
 				}
 
-				conn, err := grpc.Dial(grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-				if err != nil {
-					log.Fatalf("Failed to connect: %v", err)
-				}
-				defer conn.Close()
-
-				client := proto.NewRechainServiceClient(conn)
-				resp, err := client.StoreObject(context.Background(), &proto.StoreObjectRequest{
+				resp, err := rpcClient().StoreObject(context.Background(), &proto.StoreObjectRequest{
 					Data:     data,
 					Metadata: map[string]string{"filename": filePath},
 				})
@@ -234,7 +272,7 @@ func casCmd() *cobra.Command {
 					log.Fatalf("Failed to store object: %v", err)
 				}
 
-				printJSON(resp)
+				printJSON(cmd.OutOrStdout(), resp)
 			},
 		},
 		&cobra.Command{
@@ -245,14 +283,7 @@ func casCmd() *cobra.Command {
 				cid := args[0]
 				outputPath := args[1]
 
-				conn, err := grpc.Dial(grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-				if err != nil {
-					log.Fatalf("Failed to connect: %v", err)
-				}
-				defer conn.Close()
-
-				client := proto.NewRechainServiceClient(conn)
-				resp, err := client.GetObject(context.Background(), &proto.GetObjectRequest{Cid: cid})
+				resp, err := rpcClient().GetObject(context.Background(), &proto.GetObjectRequest{Cid: cid})
 				if err != nil {
 					log.Fatalf("Failed to get object: %v", err)
 				}
@@ -266,7 +297,7 @@ func casCmd() *cobra.Command {
 					log.Fatalf("Failed to write file: %v", err)
 				}
 
-				fmt.Printf("Object saved to %s\n", outputPath)
+				fmt.Fprintf(cmd.OutOrStdout(), "Object saved to %s\n", outputPath)
 			},
 		},
 	)
@@ -285,19 +316,12 @@ func gossipCmd() *cobra.Command {
 			Use:   "state",
 			Short: "Get gossip state",
 			Run: func(cmd *cobra.Command, args []string) {
-				conn, err := grpc.Dial(grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-				if err != nil {
-					log.Fatalf("Failed to connect: %v", err)
-				}
-				defer conn.Close()
-
-				client := proto.NewRechainServiceClient(conn)
-				resp, err := client.GetGossipState(context.Background(), &proto.GossipStateRequest{})
+				resp, err := rpcClient().GetGossipState(context.Background(), &proto.GossipStateRequest{})
 				if err != nil {
 					log.Fatalf("Failed to get gossip state: %v", err)
 				}
 
-				printJSON(resp)
+				printJSON(cmd.OutOrStdout(), resp)
 			},
 		},
 	)
@@ -305,12 +329,26 @@ func gossipCmd() *cobra.Command {
 	return cmd
 }
 
-func printJSON(v interface{}) {
+func printJSON(w io.Writer, v interface{}) {
 	data, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
 		log.Fatalf("Failed to marshal JSON: %v", err)
 	}
-	fmt.Println(string(data))
+	fmt.Fprintln(w, string(data))
+}
+
+// txProof converts a proof response so it can be checked with consensus.TxProof.Verify
+func txProof(resp *proto.TxProofResponse) *consensus.TxProof {
+	proof := &consensus.TxProof{
+		TxHash: resp.TxHash,
+		Height: resp.BlockHeight,
+		Index:  int(resp.Index),
+		TxRoot: resp.TxRoot,
+	}
+	for _, step := range resp.Steps {
+		proof.Steps = append(proof.Steps, consensus.ProofStep{Hash: step.Hash, Left: step.Left})
+	}
+	return proof
 }
 
 func parseUint64(s string) uint64 {
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rechain/rechain/api/proto"
+	"github.com/spf13/cobra"
+)
+
+// Build metadata, set at link time:
+//
+//	go build -ldflags "-X main.version=v1.2.3 -X main.commit=$(git rev-parse --short HEAD)" ./cmd/rechainctl
+var (
+	version = "dev"
+	commit  = "unknown"
+)
+
+// VersionInfo is the output of `rechainctl version`
+type VersionInfo struct {
+	Client VersionClient  `json:"client"`
+	Server *VersionServer `json:"server,omitempty"`
+}
+
+// VersionClient identifies the rechainctl build
+type VersionClient struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+}
+
+// VersionServer is the version reported by the node at --grpc-addr
+type VersionServer struct {
+	Address string `json:"address"`
+	NodeID  string `json:"node_id,omitempty"`
+	Version string `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// fetchServerVersion asks the node for its version, recording rather than failing on errors
+func fetchServerVersion(addr string) *VersionServer {
+	server := &VersionServer{Address: addr}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := rpcClient().GetNodeInfo(ctx, &proto.NodeInfoRequest{})
+	if err != nil {
+		server.Error = err.Error()
+		return server
+	}
+	server.NodeID = resp.NodeId
+	server.Version = resp.Version
+	return server
+}
+
+// renderVersion writes the version information as text or JSON
+func renderVersion(w io.Writer, info VersionInfo, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	case "text", "":
+		fmt.Fprintf(w, "Client: %s (commit %s)\n", info.Client.Version, info.Client.Commit)
+		if s := info.Server; s != nil {
+			if s.Error != "" {
+				fmt.Fprintf(w, "Server: %s unreachable (%s)\n", s.Address, s.Error)
+			} else {
+				fmt.Fprintf(w, "Server: %s (node %s at %s)\n", s.Version, s.NodeID, s.Address)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q (expected text or json)", format)
+	}
+}
+
+func versionCmd() *cobra.Command {
+	var output string
+	var clientOnly bool
+
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Show client and server versions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			info := VersionInfo{Client: VersionClient{Version: version, Commit: commit}}
+			if !clientOnly {
+				info.Server = fetchServerVersion(grpcAddr)
+			}
+			return renderVersion(cmd.OutOrStdout(), info, output)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "output format: text or json")
+	cmd.Flags().BoolVar(&clientOnly, "client", false, "show only the client version without contacting the node")
+	return cmd
+}
+
+func completionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion [bash|zsh|fish]",
+		Short: "Generate a shell completion script",
+		Long: `Generate a shell completion script for rechainctl.
+
+  bash: source <(rechainctl completion bash)
+  zsh:  rechainctl completion zsh > "${fpath[1]}/_rechainctl"
+  fish: rechainctl completion fish > ~/.config/fish/completions/rechainctl.fish`,
+		ValidArgs:             []string{"bash", "zsh", "fish"},
+		Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			switch args[0] {
+			case "bash":
+				return cmd.Root().GenBashCompletionV2(out, true)
+			case "zsh":
+				return cmd.Root().GenZshCompletion(out)
+			default:
+				return cmd.Root().GenFishCompletion(out, true)
+			}
+		},
+	}
+}
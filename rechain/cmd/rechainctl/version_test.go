@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionJSONOutput(t *testing.T) {
+	version, commit = "v1.2.3", "abc1234"
+	addr, _ := startTestNode(t, testNodeServer{})
+
+	var info VersionInfo
+	out := runCLI(t, "--grpc-addr", addr, "version", "--output", "json")
+	require.NoError(t, json.Unmarshal([]byte(out), &info), out)
+
+	assert.Equal(t, VersionClient{Version: "v1.2.3", Commit: "abc1234"}, info.Client)
+	require.NotNil(t, info.Server)
+	assert.Equal(t, "0.1.0", info.Server.Version)
+	assert.Equal(t, "node-1", info.Server.NodeID)
+	assert.Empty(t, info.Server.Error)
+
+	info = VersionInfo{}
+	out = runCLI(t, "version", "--client", "--output", "json")
+	require.NoError(t, json.Unmarshal([]byte(out), &info), out)
+	assert.Nil(t, info.Server)
+}
+
+func TestCompletionShells(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		assert.NotEmpty(t, runCLI(t, "completion", shell), shell)
+	}
+
+	root := newRootCmd()
+	root.SetOut(&bytes.Buffer{})
+	root.SetErr(&bytes.Buffer{})
+	root.SetArgs([]string{"completion", "tcsh"})
+	assert.Error(t, root.Execute())
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rechain/rechain/internal/cas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreObjectBatch(t *testing.T) {
+	s, _ := newTestServer(t)
+	backend := cas.NewMemoryBackend()
+	s.cas = cas.NewCASWithBackend(backend, 4) // tiny chunks so objects span several
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for i, content := range []string{"hello world!", "hello world!", "another"} {
+		part, err := mw.CreateFormFile("object", "file"+string(rune('a'+i)))
+		require.NoError(t, err)
+		_, err = part.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, mw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/cas/objects/batch", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var resp struct {
+		Objects []BatchObjectResult `json:"objects"`
+		Count   int                 `json:"count"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, 3, resp.Count)
+	require.Len(t, resp.Objects, 3)
+
+	assert.Equal(t, resp.Objects[0].CID, resp.Objects[1].CID)
+	assert.NotEqual(t, resp.Objects[0].CID, resp.Objects[2].CID)
+	assert.Equal(t, int64(12), resp.Objects[0].Size)
+	assert.Equal(t, int64(7), resp.Objects[2].Size)
+	assert.NotEmpty(t, resp.Objects[2].MerkleRoot)
+
+	// "hell", "o wo", "rld!" plus "anot", "her": the duplicate adds no chunks
+	chunks, err := backend.List(context.Background(), "chunks/")
+	require.NoError(t, err)
+	assert.Len(t, chunks, 5)
+
+	metadata, err := backend.List(context.Background(), "metadata/")
+	require.NoError(t, err)
+	assert.Len(t, metadata, 2)
+}
+
+func TestGetObjectPreservesContentTypeAndFilename(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.cas = cas.NewCASWithBackend(cas.NewMemoryBackend(), 0)
+
+	png := []byte("\x89PNG\r\n\x1a\n fake image data")
+	req := httptest.NewRequest(http.MethodPost, "/cas/objects", bytes.NewReader(png))
+	req.Header.Set("Content-Type", "image/png")
+	req.Header.Set("X-Filename", "logo.png")
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var stored struct {
+		CID string `json:"cid"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stored))
+
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/cas/objects/"+stored.CID, nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename=logo.png`, rec.Header().Get("Content-Disposition"))
+	assert.Equal(t, png, rec.Body.Bytes())
+
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/cas/objects/"+strings.Repeat("0", 64), nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestGetObjectRange(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.cas = cas.NewCASWithBackend(cas.NewMemoryBackend(), 4)
+
+	// Three chunks: "aaaa", "bbbb", "cc"
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/cas/objects", strings.NewReader("aaaabbbbcc")))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var stored struct {
+		CID    string `json:"cid"`
+		Chunks int    `json:"chunks"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stored))
+	require.Equal(t, 3, stored.Chunks)
+
+	get := func(rangeHeader string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/cas/objects/"+stored.CID, nil)
+		req.Header.Set("Range", rangeHeader)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		rangeHeader  string
+		body         string
+		contentRange string
+	}{
+		{"bytes=2-5", "aabb", "bytes 2-5/10"},     // spans the first chunk boundary
+		{"bytes=3-8", "abbbbc", "bytes 3-8/10"},   // spans both boundaries
+		{"bytes=8-", "cc", "bytes 8-9/10"},        // open-ended
+		{"bytes=-3", "bcc", "bytes 7-9/10"},       // suffix
+		{"bytes=4-100", "bbbbcc", "bytes 4-9/10"}, // end clamped to the object
+	}
+	for _, tt := range tests {
+		t.Run(tt.rangeHeader, func(t *testing.T) {
+			rec := get(tt.rangeHeader)
+			require.Equal(t, http.StatusPartialContent, rec.Code, rec.Body.String())
+			assert.Equal(t, tt.body, rec.Body.String())
+			assert.Equal(t, tt.contentRange, rec.Header().Get("Content-Range"))
+		})
+	}
+
+	rec = get("bytes=10-12")
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, rec.Code)
+	assert.Equal(t, "bytes */10", rec.Header().Get("Content-Range"))
+
+	// Without a range the whole object is served
+	rec = get("")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "aaaabbbbcc", rec.Body.String())
+	assert.Equal(t, "bytes", rec.Header().Get("Accept-Ranges"))
+}
+
+func TestObjectConditionalRequests(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.cas = cas.NewCASWithBackend(cas.NewMemoryBackend(), 0)
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/cas/objects", strings.NewReader("cache me")))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var stored struct {
+		CID string `json:"cid"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stored))
+	path := "/cas/objects/" + stored.CID
+
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	etag := rec.Header().Get("ETag")
+	assert.Equal(t, `"`+stored.CID+`"`, etag)
+	assert.Contains(t, rec.Header().Get("Cache-Control"), "immutable")
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	// A missing object is not found, whatever the client has cached
+	for _, match := range []string{etag, "*"} {
+		req = httptest.NewRequest(http.MethodGet, "/cas/objects/sha256:"+strings.Repeat("0", 64), nil)
+		req.Header.Set("If-None-Match", match)
+		rec = httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code, match)
+	}
+
+	// If-Match compares strongly, so neither another tag nor a weak one matches
+	for _, match := range []string{`"some-other-cid"`, "W/" + etag} {
+		req = httptest.NewRequest(http.MethodDelete, path, nil)
+		req.Header.Set("If-Match", match)
+		rec = httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code, match)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, path, nil)
+	req.Header.Set("If-Match", etag)
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
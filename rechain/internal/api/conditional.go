@@ -0,0 +1,33 @@
+package api
+
+import "strings"
+
+// immutableCacheControl is sent with CAS objects, whose content can never change under a CID
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// objectETag returns the strong entity tag for a CAS object
+func objectETag(cid string) string {
+	return `"` + cid + `"`
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header value lists
+// etag, or is "*". If-None-Match uses the weak comparison, where W/"x" equals
+// "x"; If-Match uses the strong one, where a weak validator never matches.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCORSServer(origins ...string) *Server {
+	s := &Server{router: mux.NewRouter()}
+	s.routes()
+	s.EnableCORS(DefaultCORSConfig(origins))
+	return s
+}
+
+func preflight(s *Server, path, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodOptions, path, nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORS(t *testing.T) {
+	t.Run("PreflightFromAllowedOrigin", func(t *testing.T) {
+		s := newCORSServer("https://app.example.com")
+
+		rec := preflight(s, "/cas/objects", "https://app.example.com")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Contains(t, rec.Header().Get("Access-Control-Allow-Methods"), "POST")
+		assert.Contains(t, rec.Header().Get("Access-Control-Allow-Headers"), "Content-Type")
+	})
+
+	t.Run("PreflightFromDisallowedOrigin", func(t *testing.T) {
+		s := newCORSServer("https://app.example.com")
+
+		rec := preflight(s, "/cas/objects", "https://evil.example.com")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Methods"))
+	})
+
+	t.Run("EmptyListDeniesAll", func(t *testing.T) {
+		s := newCORSServer()
+
+		rec := preflight(s, "/cas/objects", "https://app.example.com")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("WildcardOnlyWhenConfigured", func(t *testing.T) {
+		s := newCORSServer("*")
+
+		rec := preflight(s, "/cas/objects", "https://anything.example.com")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("SimpleRequestGetsOriginHeader", func(t *testing.T) {
+		s := newCORSServer("https://app.example.com")
+
+		req := httptest.NewRequest(http.MethodGet, "/node/info", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+}
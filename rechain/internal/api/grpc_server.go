@@ -4,8 +4,10 @@ import (
 	"context"
 	"log"
 	"net"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
 	"github.com/rechain/rechain/api/proto"
 )
@@ -17,9 +19,21 @@ type gRPCServer struct {
 	api    *Server
 }
 
+// maxGRPCMessageSize bounds a single gRPC message in either direction.
+// Objects larger than this go through StoreObjectStream and GetObjectStream.
+const maxGRPCMessageSize = 16 * 1024 * 1024
+
 // NewGRPCServer creates a new gRPC server
 func NewGRPCServer(api *Server) *gRPCServer {
-	s := grpc.NewServer()
+	s := grpc.NewServer(
+		grpc.MaxRecvMsgSize(maxGRPCMessageSize),
+		grpc.MaxSendMsgSize(maxGRPCMessageSize),
+		// Accept the keepalive pings rechainctl sends on its long-lived connection
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             15 * time.Second,
+			PermitWithoutStream: true,
+		}),
+	)
 	srv := &gRPCServer{
 		server: s,
 		api:    api,
@@ -107,15 +121,6 @@ func (s *gRPCServer) GetBlocks(ctx context.Context, req *proto.GetBlocksRequest)
 	}, nil
 }
 
-func (s *gRPCServer) SubmitTx(ctx context.Context, req *proto.SubmitTxRequest) (*proto.SubmitTxResponse, error) {
-	// This would call the REST API handler
-	return &proto.SubmitTxResponse{
-		TxId:      "tx-123",
-		Status:    "submitted",
-		Timestamp: "2023-12-01T10:00:00Z",
-	}, nil
-}
-
 func (s *gRPCServer) GetTx(ctx context.Context, req *proto.GetTxRequest) (*proto.TxResponse, error) {
 	// This would call the REST API handler
 	return &proto.TxResponse{
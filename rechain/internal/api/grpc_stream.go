@@ -0,0 +1,115 @@
+package api
+
+import (
+	"io"
+	"time"
+
+	"github.com/rechain/rechain/api/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// objectStreamChunkSize is the payload carried by each GetObjectStream message
+const objectStreamChunkSize = 1024 * 1024
+
+// StoreObjectStream stores an object uploaded as a stream of chunks. The
+// chunks are fed to the CAS as they arrive, so the object is never held in
+// memory as a whole.
+func (s *gRPCServer) StoreObjectStream(stream proto.RechainService_StoreObjectStreamServer) error {
+	if s.api.cas == nil {
+		return status.Error(codes.Unavailable, "CAS is not configured")
+	}
+
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, "empty upload")
+	}
+	if err != nil {
+		return err
+	}
+
+	reader := &storeStreamReader{stream: stream, buf: first.Data}
+	info, err := s.api.cas.Store(stream.Context(), reader, first.Metadata)
+	if reader.err != nil {
+		return reader.err
+	}
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to store object: %v", err)
+	}
+
+	return stream.SendAndClose(&proto.StoreObjectResponse{
+		Cid:        info.CID,
+		Size:       info.Size,
+		Chunks:     int32(len(info.Chunks)),
+		MerkleRoot: info.MerkleRoot,
+		Uploaded:   info.Uploaded.Format(time.RFC3339),
+	})
+}
+
+// storeStreamReader reads the data of a StoreObjectStream upload. A receive
+// error other than the end of the stream is kept so it can be returned as is.
+type storeStreamReader struct {
+	stream proto.RechainService_StoreObjectStreamServer
+	buf    []byte
+	err    error
+}
+
+func (r *storeStreamReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		msg, err := r.stream.Recv()
+		if err == io.EOF {
+			return 0, io.EOF
+		}
+		if err != nil {
+			r.err = err
+			return 0, err
+		}
+		r.buf = msg.Data
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// GetObjectStream sends an object in chunks of objectStreamChunkSize. Each
+// CAS chunk is verified against its CID before any of it is sent.
+func (s *gRPCServer) GetObjectStream(req *proto.GetObjectRequest, stream proto.RechainService_GetObjectStreamServer) error {
+	if s.api.cas == nil {
+		return status.Error(codes.Unavailable, "CAS is not configured")
+	}
+
+	ctx := stream.Context()
+	info, err := s.api.cas.GetInfo(ctx, req.Cid)
+	if err != nil {
+		return status.Errorf(codes.NotFound, "object %s not found", req.Cid)
+	}
+	body, err := s.api.cas.RetrieveRange(ctx, info, 0, info.Size)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to read object: %v", err)
+	}
+	defer body.Close()
+
+	buf := make([]byte, objectStreamChunkSize)
+	first := true
+	for {
+		n, err := body.Read(buf)
+		// The first message carries the size and metadata, even for an empty object
+		if n > 0 || (first && err == io.EOF) {
+			msg := &proto.ObjectChunk{Data: buf[:n]}
+			if first {
+				msg.Size, msg.Metadata = info.Size, info.Metadata
+				first = false
+			}
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return status.Errorf(codes.DataLoss, "failed to read object: %v", err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/rechain/rechain/api/proto"
+	"github.com/rechain/rechain/internal/cas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestGRPCClient serves s over an in-memory listener and returns a client for it
+func newTestGRPCClient(t *testing.T, s *Server) proto.RechainServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	srv := NewGRPCServer(s)
+	go srv.server.Serve(lis)
+	t.Cleanup(func() { srv.Stop() })
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return proto.NewRechainServiceClient(conn)
+}
+
+func TestObjectStreamsTransferLargeObject(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.cas = cas.NewCASWithBackend(cas.NewMemoryBackend(), 4*1024*1024)
+	client := newTestGRPCClient(t, s)
+	ctx := context.Background()
+
+	// Larger than gRPC's default 4MB message limit
+	data := make([]byte, 10*1024*1024)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+
+	upload, err := client.StoreObjectStream(ctx)
+	require.NoError(t, err)
+	for offset := 0; offset < len(data); offset += 512 * 1024 {
+		msg := &proto.StoreObjectChunk{Data: data[offset : offset+512*1024]}
+		if offset == 0 {
+			msg.Metadata = map[string]string{"filename": "large.bin"}
+		}
+		require.NoError(t, upload.Send(msg))
+	}
+	stored, err := upload.CloseAndRecv()
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), stored.Size)
+	assert.Equal(t, int32(3), stored.Chunks)
+
+	download, err := client.GetObjectStream(ctx, &proto.GetObjectRequest{Cid: stored.Cid})
+	require.NoError(t, err)
+	var got bytes.Buffer
+	for i := 0; ; i++ {
+		msg, err := download.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if i == 0 {
+			assert.Equal(t, int64(len(data)), msg.Size)
+			assert.Equal(t, "large.bin", msg.Metadata["filename"])
+		}
+		got.Write(msg.Data)
+	}
+	assert.True(t, bytes.Equal(data, got.Bytes()), "downloaded object differs from the upload")
+
+	missing, err := client.GetObjectStream(ctx, &proto.GetObjectRequest{Cid: "sha256:" + stored.MerkleRoot})
+	require.NoError(t, err)
+	_, err = missing.Recv()
+	assert.Error(t, err)
+}
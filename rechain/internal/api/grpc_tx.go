@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/rechain/rechain/api/proto"
+	"github.com/rechain/rechain/internal/consensus"
+	"github.com/rechain/rechain/internal/security"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SubmitTx adds a transaction signed by its sender to the consensus mempool
+func (s *gRPCServer) SubmitTx(ctx context.Context, req *proto.SubmitTxRequest) (*proto.SubmitTxResponse, error) {
+	if s.api.consensus == nil {
+		return nil, status.Error(codes.Unavailable, "consensus is not running")
+	}
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "an id chosen by the client is required")
+	}
+
+	var payload map[string]interface{}
+	if len(req.Payload) > 0 {
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "payload is not a JSON object: %v", err)
+		}
+	}
+	sender, err := security.VerifySubmittedTx(req.PublicKey, req.Signature, req.Id, req.Type, payload, int(req.Priority))
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "signature verification failed: %v", err)
+	}
+
+	tx := &consensus.Transaction{
+		ID:        req.Id,
+		Type:      req.Type,
+		Payload:   req.Payload,
+		Timestamp: time.Now(),
+		Sender:    sender,
+		Signature: req.Signature,
+		PublicKey: req.PublicKey,
+		Priority:  int(req.Priority),
+	}
+	if err := s.api.consensus.AddTransaction(tx); err != nil {
+		return nil, status.Error(mempoolCode(err), err.Error())
+	}
+
+	return &proto.SubmitTxResponse{
+		TxId:      tx.ID,
+		TxHash:    tx.Hash(),
+		Status:    "submitted",
+		Timestamp: tx.Timestamp.Format(time.RFC3339),
+	}, nil
+}
+
+// mempoolCode maps a rejected transaction to AlreadyExists or ResourceExhausted
+func mempoolCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, consensus.ErrDuplicateTx):
+		return codes.AlreadyExists
+	case errors.Is(err, consensus.ErrMempoolFull):
+		return codes.ResourceExhausted
+	case errors.Is(err, consensus.ErrUnknownTxType), errors.Is(err, consensus.ErrInvalidTx):
+		return codes.InvalidArgument
+	default:
+		return codes.Internal
+	}
+}
+
+// GetTxStatus reports whether a transaction is pending, committed or confirmed
+func (s *gRPCServer) GetTxStatus(ctx context.Context, req *proto.GetTxRequest) (*proto.TxStatusResponse, error) {
+	if s.api.consensus == nil {
+		return nil, status.Error(codes.Unavailable, "consensus is not running")
+	}
+
+	txStatus, err := s.api.consensus.GetTxStatus(ctx, req.Hash)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get transaction status: %v", err)
+	}
+	if txStatus == nil {
+		return nil, status.Errorf(codes.NotFound, "transaction %s not found", req.Hash)
+	}
+
+	return &proto.TxStatusResponse{
+		Hash:          txStatus.Hash,
+		Status:        txStatus.Status,
+		BlockHeight:   txStatus.Height,
+		Index:         int32(txStatus.Index),
+		Confirmations: txStatus.Confirmations,
+	}, nil
+}
+
+// GetTxProof returns a Merkle inclusion proof for a committed transaction
+func (s *gRPCServer) GetTxProof(ctx context.Context, req *proto.GetTxRequest) (*proto.TxProofResponse, error) {
+	if s.api.consensus == nil {
+		return nil, status.Error(codes.Unavailable, "consensus is not running")
+	}
+
+	proof, err := s.api.consensus.GetTxProof(ctx, req.Hash)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to build transaction proof: %v", err)
+	}
+	if proof == nil {
+		return nil, status.Errorf(codes.NotFound, "transaction %s is not committed", req.Hash)
+	}
+
+	resp := &proto.TxProofResponse{
+		TxHash:      proof.TxHash,
+		BlockHeight: proof.Height,
+		Index:       int32(proof.Index),
+		TxRoot:      proof.TxRoot,
+		Steps:       make([]*proto.ProofStep, len(proof.Steps)),
+	}
+	for i, step := range proof.Steps {
+		resp.Steps[i] = &proto.ProofStep{Hash: step.Hash, Left: step.Left}
+	}
+	return resp, nil
+}
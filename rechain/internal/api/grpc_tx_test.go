@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rechain/rechain/api/proto"
+	"github.com/rechain/rechain/internal/consensus"
+	"github.com/rechain/rechain/internal/security"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTxStatusAndProof(t *testing.T) {
+	s, engine := newTestServer(t)
+	client := newTestGRPCClient(t, s)
+	ctx := context.Background()
+
+	km, err := security.NewKeyManagerWithAlgorithm(security.AlgorithmEd25519)
+	require.NoError(t, err)
+	publicKey, err := km.PublicKeyBytes()
+	require.NoError(t, err)
+	data, err := security.SubmittedTxSigningBytes("tx-1", "transfer", map[string]interface{}{"amount": 5}, 0)
+	require.NoError(t, err)
+	signature, err := km.SignData(data)
+	require.NoError(t, err)
+	req := &proto.SubmitTxRequest{Id: "tx-1", Type: "transfer", Payload: []byte(`{"amount":5}`), PublicKey: publicKey, Signature: signature}
+
+	req.Priority = 10
+	_, err = client.SubmitTx(ctx, req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "the priority is signed")
+	req.Priority = 0
+
+	submitted, err := client.SubmitTx(ctx, req)
+	require.NoError(t, err)
+	require.NotEmpty(t, submitted.TxHash)
+	hash := &proto.GetTxRequest{Hash: submitted.TxHash}
+
+	pending, err := client.GetTxStatus(ctx, hash)
+	require.NoError(t, err)
+	assert.Equal(t, consensus.TxPending, pending.Status)
+
+	_, err = client.GetTxProof(ctx, hash)
+	assert.Equal(t, codes.NotFound, status.Code(err), "pending transactions have no proof")
+
+	// A single validator commits height 1 with the mempool as soon as it starts
+	require.NoError(t, engine.Start())
+
+	committed, err := client.GetTxStatus(ctx, hash)
+	require.NoError(t, err)
+	assert.Equal(t, consensus.TxCommitted, committed.Status)
+	assert.Equal(t, uint64(1), committed.BlockHeight)
+	assert.Equal(t, uint64(1), committed.Confirmations)
+
+	resp, err := client.GetTxProof(ctx, hash)
+	require.NoError(t, err)
+	proof := &consensus.TxProof{TxHash: resp.TxHash, Height: resp.BlockHeight, Index: int(resp.Index), TxRoot: resp.TxRoot}
+	for _, step := range resp.Steps {
+		proof.Steps = append(proof.Steps, consensus.ProofStep{Hash: step.Hash, Left: step.Left})
+	}
+	assert.True(t, proof.Verify())
+
+	proof.TxRoot = make([]byte, 32)
+	assert.False(t, proof.Verify(), "a proof must not verify against another root")
+
+	// The next height is committed after the one second commit timeout
+	require.Eventually(t, func() bool {
+		confirmed, err := client.GetTxStatus(ctx, hash)
+		return err == nil && confirmed.Status == consensus.TxConfirmed
+	}, 5*time.Second, 50*time.Millisecond)
+
+	_, err = client.GetTxStatus(ctx, &proto.GetTxRequest{Hash: "deadbeef"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
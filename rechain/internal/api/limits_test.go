@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func newLimitedServer(cfg RequestLimitConfig) *Server {
+	s := &Server{router: mux.NewRouter()}
+	s.routes()
+	s.EnableRequestLimits(cfg)
+	return s
+}
+
+func TestRequestLimits(t *testing.T) {
+	t.Run("RejectsOversizedBody", func(t *testing.T) {
+		s := newLimitedServer(DefaultRequestLimitConfig(16, time.Second))
+		body := `{"type":"transfer","payload":{"amount":100}}`
+
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/cas/objects", strings.NewReader(body)))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+		// Bodies of unknown length are cut off while the handler reads them
+		req := httptest.NewRequest(http.MethodPost, "/txs", strings.NewReader(body))
+		req.ContentLength = -1
+		rec = httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+
+	t.Run("SetsHandlerDeadline", func(t *testing.T) {
+		cfg := DefaultRequestLimitConfig(0, 10*time.Millisecond)
+		slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			http.Error(w, r.Context().Err().Error(), errorStatus(r.Context().Err(), http.StatusInternalServerError))
+		})
+
+		rec := httptest.NewRecorder()
+		cfg.middleware(slow).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/blocks", nil))
+		assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+	})
+
+	t.Run("ExemptsEventStream", func(t *testing.T) {
+		cfg := DefaultRequestLimitConfig(0, 10*time.Millisecond)
+		var deadline bool
+		probe := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, deadline = r.Context().Deadline()
+		})
+
+		cfg.middleware(probe).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ws/events", nil))
+		assert.False(t, deadline)
+	})
+}
+
+func TestErrorStatus(t *testing.T) {
+	assert.Equal(t, http.StatusGatewayTimeout, errorStatus(context.DeadlineExceeded, http.StatusInternalServerError))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, errorStatus(&http.MaxBytesError{Limit: 1}, http.StatusBadRequest))
+	assert.Equal(t, http.StatusBadRequest, errorStatus(context.Canceled, http.StatusBadRequest))
+}
@@ -0,0 +1,277 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// openAPIVersion is the OpenAPI release the generated documents follow
+const openAPIVersion = "3.0.3"
+
+// routeDoc describes a route for the OpenAPI document. Paths and methods are
+// taken from the router, so only what the router cannot know lives here.
+type routeDoc struct {
+	Summary string
+	// Query lists the query parameters the handler reads
+	Query []string
+	// Headers lists the request headers the handler reads
+	Headers []string
+	// Request is the media type of the request body, or "" for none
+	Request string
+	// Response is the media type of successful responses; defaults to JSON
+	Response  string
+	Responses map[int]string
+}
+
+// apiRouteDocs documents the API routes, keyed by "METHOD /path/template"
+var apiRouteDocs = map[string]routeDoc{
+	"GET /health": {Summary: "Report node health", Responses: map[int]string{200: "Node is healthy"}},
+	"GET /blocks/latest": {Summary: "Get the latest block", Responses: map[int]string{
+		200: "The latest block, or a message when there are no blocks yet", 500: "Storage failure", 504: "Request timed out"}},
+	"GET /blocks/{height}": {Summary: "Get a block by height", Responses: map[int]string{
+		200: "The block", 400: "Invalid height", 404: "Block not found", 500: "Storage failure", 504: "Request timed out"}},
+	"GET /blocks": {Summary: "List blocks, newest first", Query: []string{"limit", "before"}, Responses: map[int]string{
+		200: "A page of blocks", 400: "Invalid before height", 500: "Storage failure"}},
+	"POST /txs": {Summary: "Submit a signed transaction", Request: "application/json", Responses: map[int]string{
+		200: "Transaction accepted", 400: "Invalid transaction or unknown transaction type", 401: "Missing or invalid signature", 409: "Transaction already pending or committed",
+		413: "Request body too large", 503: "Mempool is full"}},
+	"GET /txs/{hash}": {Summary: "Get a transaction and its confirmation status", Responses: map[int]string{
+		200: "The transaction", 404: "Transaction not found", 500: "Storage failure"}},
+	"GET /txs": {Summary: "List recent transactions", Query: []string{"limit"}, Responses: map[int]string{200: "Recent transactions"}},
+	"POST /cas/objects": {Summary: "Store an object", Headers: []string{"X-Filename"}, Request: "application/octet-stream", Responses: map[int]string{
+		201: "Object stored", 413: "Request body too large", 500: "Storage failure", 504: "Request timed out"}},
+	"POST /cas/objects/batch": {Summary: "Store each part of a multipart body as an object", Request: "multipart/form-data", Responses: map[int]string{
+		201: "Objects stored, in input order", 400: "Invalid multipart body", 413: "Request body too large", 500: "Storage failure"}},
+	"GET /cas/objects/{cid}": {Summary: "Download an object", Headers: []string{"Range", "If-None-Match"}, Response: "application/octet-stream", Responses: map[int]string{
+		200: "Object content", 206: "Requested byte range", 304: "Client copy is current", 404: "Object not found",
+		416: "Range not satisfiable", 500: "Storage failure"}},
+	"DELETE /cas/objects/{cid}": {Summary: "Delete an object", Headers: []string{"If-Match"}, Responses: map[int]string{
+		200: "Object deleted", 412: "Precondition failed", 500: "Storage failure"}},
+	"GET /cas/objects":  {Summary: "List objects", Query: []string{"prefix"}, Responses: map[int]string{200: "Stored objects", 500: "Storage failure"}},
+	"GET /gossip/state": {Summary: "Get gossip CRDT state", Responses: map[int]string{200: "Current state"}},
+	"POST /gossip/state": {Summary: "Update a gossip CRDT", Request: "application/json", Responses: map[int]string{
+		200: "State updated", 400: "Invalid request", 500: "Update failed"}},
+	"POST /gossip/query": {Summary: "Query a gossip CRDT", Request: "application/json", Responses: map[int]string{
+		200: "Query sent", 400: "Invalid request", 500: "Query failed"}},
+	"GET /node/info":       {Summary: "Get node information", Responses: map[int]string{200: "Node information"}},
+	"GET /node/peers":      {Summary: "List connected peers", Responses: map[int]string{200: "Connected peers"}},
+	"GET /consensus/state": {Summary: "Get consensus state", Responses: map[int]string{200: "Consensus state"}},
+	"GET /ws/events": {Summary: "Subscribe to block and transaction events over a WebSocket", Responses: map[int]string{
+		101: "Switching to the WebSocket protocol", 503: "Event stream not available"}},
+	"GET /openapi.json": {Summary: "Get this OpenAPI document", Responses: map[int]string{200: "OpenAPI document"}},
+	"GET /docs":         {Summary: "Browse the API with Swagger UI", Response: "text/html", Responses: map[int]string{200: "Swagger UI page"}},
+}
+
+// OpenAPIDocument is an OpenAPI 3 description of the API
+type OpenAPIDocument struct {
+	OpenAPI string                                  `json:"openapi"`
+	Info    OpenAPIInfo                             `json:"info"`
+	Paths   map[string]map[string]*OpenAPIOperation `json:"paths"`
+}
+
+// OpenAPIInfo names and versions the API
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenAPIOperation describes one method on a path
+type OpenAPIOperation struct {
+	Summary     string                     `json:"summary,omitempty"`
+	OperationID string                     `json:"operationId"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter describes a path, query or header parameter
+type OpenAPIParameter struct {
+	Name     string        `json:"name"`
+	In       string        `json:"in"`
+	Required bool          `json:"required,omitempty"`
+	Schema   OpenAPISchema `json:"schema"`
+}
+
+// OpenAPIRequestBody describes a request body
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse describes a response for one status code
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType gives the schema of a body in one media type
+type OpenAPIMediaType struct {
+	Schema OpenAPISchema `json:"schema"`
+}
+
+// OpenAPISchema is the subset of JSON Schema the generated documents use
+type OpenAPISchema struct {
+	Type    string `json:"type"`
+	Format  string `json:"format,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// pathVarPattern matches a mux path variable with an optional regexp, e.g. {height:[0-9]+}
+var pathVarPattern = regexp.MustCompile(`\{([^{}:]+)(?::([^{}]*))?\}`)
+
+// buildOpenAPI describes every route registered on router that has a path and methods
+func buildOpenAPI(router *mux.Router, info OpenAPIInfo, docs map[string]routeDoc) (*OpenAPIDocument, error) {
+	doc := &OpenAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info:    info,
+		Paths:   make(map[string]map[string]*OpenAPIOperation),
+	}
+
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil // Catch-all routes such as CORS preflight have no path
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil // Subrouter prefixes are not endpoints themselves
+		}
+
+		path := pathVarPattern.ReplaceAllString(tmpl, "{$1}")
+		for _, method := range methods {
+			if method == http.MethodOptions {
+				continue
+			}
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = make(map[string]*OpenAPIOperation)
+			}
+			doc.Paths[path][strings.ToLower(method)] = newOpenAPIOperation(method, tmpl, path, docs[method+" "+path])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func newOpenAPIOperation(method, tmpl, path string, rd routeDoc) *OpenAPIOperation {
+	op := &OpenAPIOperation{
+		Summary:     rd.Summary,
+		OperationID: operationID(method, path),
+		Responses:   make(map[string]OpenAPIResponse),
+	}
+
+	for _, m := range pathVarPattern.FindAllStringSubmatch(tmpl, -1) {
+		op.Parameters = append(op.Parameters, OpenAPIParameter{
+			Name: m[1], In: "path", Required: true,
+			Schema: OpenAPISchema{Type: "string", Pattern: m[2]},
+		})
+	}
+	for _, name := range rd.Query {
+		op.Parameters = append(op.Parameters, OpenAPIParameter{Name: name, In: "query", Schema: OpenAPISchema{Type: "string"}})
+	}
+	for _, name := range rd.Headers {
+		op.Parameters = append(op.Parameters, OpenAPIParameter{Name: name, In: "header", Schema: OpenAPISchema{Type: "string"}})
+	}
+
+	if rd.Request != "" {
+		op.RequestBody = &OpenAPIRequestBody{
+			Required: true,
+			Content:  map[string]OpenAPIMediaType{rd.Request: {Schema: mediaSchema(rd.Request)}},
+		}
+	}
+
+	responses := rd.Responses
+	if len(responses) == 0 {
+		responses = map[int]string{http.StatusOK: "OK"}
+	}
+	codes := make([]int, 0, len(responses))
+	for code := range responses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		resp := OpenAPIResponse{Description: responses[code]}
+		if mediaType := responseMediaType(code, rd); mediaType != "" {
+			resp.Content = map[string]OpenAPIMediaType{mediaType: {Schema: mediaSchema(mediaType)}}
+		}
+		op.Responses[strconv.Itoa(code)] = resp
+	}
+	return op
+}
+
+// responseMediaType is the body type for a status code; errors are JSON and
+// bodiless statuses have none
+func responseMediaType(code int, rd routeDoc) string {
+	switch {
+	case code == http.StatusSwitchingProtocols || code == http.StatusNotModified:
+		return ""
+	case code >= 400 || rd.Response == "":
+		return "application/json"
+	default:
+		return rd.Response
+	}
+}
+
+func mediaSchema(mediaType string) OpenAPISchema {
+	switch mediaType {
+	case "application/json", "multipart/form-data":
+		return OpenAPISchema{Type: "object"}
+	case "text/html":
+		return OpenAPISchema{Type: "string"}
+	default:
+		return OpenAPISchema{Type: "string", Format: "binary"}
+	}
+}
+
+// operationID derives an identifier such as get_blocks_height from a route
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, part := range strings.Split(path, "/") {
+		part = strings.Trim(part, "{}")
+		part = strings.NewReplacer(".", "_", "-", "_").Replace(part)
+		if part != "" {
+			id += "_" + part
+		}
+	}
+	return id
+}
+
+// swaggerUIPage renders Swagger UI against the document served at /openapi.json
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>%s</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	doc, err := buildOpenAPI(s.router, OpenAPIInfo{Title: "ReChain API", Version: nodeVersion}, apiRouteDocs)
+	if err != nil {
+		s.error(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	s.respond(w, r, doc, http.StatusOK)
+}
+
+func (s *Server) handleDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, swaggerUIPage, "ReChain API")
+}
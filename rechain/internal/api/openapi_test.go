@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// validateOpenAPI checks the structural rules of an OpenAPI 3 document that
+// the generator could get wrong
+func validateOpenAPI(t *testing.T, doc *OpenAPIDocument) {
+	t.Helper()
+
+	assert.True(t, strings.HasPrefix(doc.OpenAPI, "3."), "openapi version %q", doc.OpenAPI)
+	assert.NotEmpty(t, doc.Info.Title)
+	assert.NotEmpty(t, doc.Info.Version)
+
+	templateVar := regexp.MustCompile(`\{([^{}]+)\}`)
+	operationIDs := make(map[string]string)
+	for path, item := range doc.Paths {
+		assert.True(t, strings.HasPrefix(path, "/"), "path %q", path)
+		assert.NotContains(t, path, ":", "path %q still has a mux pattern", path)
+
+		for method, op := range item {
+			where := method + " " + path
+			assert.Contains(t, []string{"get", "put", "post", "delete", "patch", "head"}, method, where)
+
+			require.NotEmpty(t, op.Responses, where)
+			for code, resp := range op.Responses {
+				n, err := strconv.Atoi(code)
+				assert.NoError(t, err, where)
+				assert.True(t, n >= 100 && n < 600, "%s: status %s", where, code)
+				assert.NotEmpty(t, resp.Description, "%s: status %s has no description", where, code)
+			}
+
+			require.NotEmpty(t, op.OperationID, where)
+			if other, dup := operationIDs[op.OperationID]; dup {
+				t.Errorf("%s and %s share operationId %s", other, where, op.OperationID)
+			}
+			operationIDs[op.OperationID] = where
+
+			declared := make(map[string]bool)
+			for _, p := range op.Parameters {
+				assert.Contains(t, []string{"path", "query", "header"}, p.In, where)
+				if p.In == "path" {
+					assert.True(t, p.Required, "%s: path parameter %s must be required", where, p.Name)
+					declared[p.Name] = true
+				}
+			}
+			for _, m := range templateVar.FindAllStringSubmatch(path, -1) {
+				assert.True(t, declared[m[1]], "%s: path parameter %s is not declared", where, m[1])
+			}
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	s := &Server{router: mux.NewRouter()}
+	s.routes()
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var doc OpenAPIDocument
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	validateOpenAPI(t, &doc)
+
+	require.Contains(t, doc.Paths, "/health")
+	require.Contains(t, doc.Paths, "/cas/objects")
+	assert.Contains(t, doc.Paths["/cas/objects"], "get")
+	assert.Contains(t, doc.Paths["/cas/objects"], "post")
+	assert.Contains(t, doc.Paths["/cas/objects/{cid}"]["get"].Responses, "206")
+
+	// Mux patterns become plain template variables
+	block := doc.Paths["/blocks/{height}"]["get"]
+	require.NotNil(t, block)
+	require.Len(t, block.Parameters, 1)
+	assert.Equal(t, "height", block.Parameters[0].Name)
+	assert.Equal(t, "[0-9]+", block.Parameters[0].Schema.Pattern)
+}
+
+func TestOpenAPIDocsMatchRoutes(t *testing.T) {
+	s := &Server{router: mux.NewRouter()}
+	s.routes()
+
+	doc, err := buildOpenAPI(s.router, OpenAPIInfo{Title: "test", Version: "0"}, apiRouteDocs)
+	require.NoError(t, err)
+
+	// Every documented route must still exist, and every route must be documented
+	for key := range apiRouteDocs {
+		method, path, _ := strings.Cut(key, " ")
+		assert.Contains(t, doc.Paths[path], strings.ToLower(method), "documented route %s is not registered", key)
+	}
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			assert.NotEmpty(t, op.Summary, "route %s %s has no documentation", strings.ToUpper(method), path)
+		}
+	}
+}
+
+func TestSwaggerUI(t *testing.T) {
+	s := &Server{router: mux.NewRouter()}
+	s.routes()
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, rec.Body.String(), `url: "/openapi.json"`)
+}
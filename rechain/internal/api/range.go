@@ -0,0 +1,61 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// errRangeUnsatisfiable means a Range header lies entirely outside the object
+var errRangeUnsatisfiable = errors.New("range not satisfiable")
+
+// parseByteRange parses a single-range "bytes=start-end" header against an
+// object of the given size, returning the offset and length to serve. ok is
+// false when the header is absent or not a single byte range, in which case
+// the whole object is served.
+func parseByteRange(header string, size int64) (offset, length int64, ok bool, err error) {
+	spec := strings.TrimPrefix(header, "bytes=")
+	if header == "" || spec == header || strings.Contains(spec, ",") {
+		return 0, 0, false, nil
+	}
+
+	startStr, endStr, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false, nil
+	}
+
+	if startStr == "" {
+		// Suffix range: the last N bytes
+		suffix, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || suffix < 0 {
+			return 0, 0, false, nil
+		}
+		if suffix == 0 || size == 0 {
+			return 0, 0, false, errRangeUnsatisfiable
+		}
+		if suffix > size {
+			suffix = size
+		}
+		return size - suffix, suffix, true, nil
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false, nil
+	}
+	end := size - 1
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false, nil
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	if start >= size {
+		return 0, 0, false, errRangeUnsatisfiable
+	}
+
+	return start, end - start + 1, true, nil
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimitConfig configures per-client request rate limiting
+type RateLimitConfig struct {
+	Enabled bool
+	RPS     float64
+	Burst   int
+	// Whitelist holds client IPs that are never limited
+	Whitelist []string
+	// ExemptPaths holds request paths that are never limited, such as health checks
+	ExemptPaths []string
+}
+
+// DefaultRateLimitConfig returns a config that exempts localhost and /health
+func DefaultRateLimitConfig(rps float64, burst int) RateLimitConfig {
+	return RateLimitConfig{
+		Enabled:     true,
+		RPS:         rps,
+		Burst:       burst,
+		Whitelist:   []string{"127.0.0.1", "::1"},
+		ExemptPaths: []string{"/health"},
+	}
+}
+
+// limiterIdleTimeout is how long a client's bucket is kept after its last request
+const limiterIdleTimeout = 10 * time.Minute
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimiter keeps a token bucket per client IP
+type rateLimiter struct {
+	config    RateLimitConfig
+	whitelist map[string]bool
+	exempt    map[string]bool
+
+	mu        sync.Mutex
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+func newRateLimiter(cfg RateLimitConfig) *rateLimiter {
+	if cfg.Burst <= 0 {
+		cfg.Burst = int(math.Max(1, math.Ceil(cfg.RPS)))
+	}
+
+	rl := &rateLimiter{
+		config:    cfg,
+		whitelist: make(map[string]bool),
+		exempt:    make(map[string]bool),
+		clients:   make(map[string]*clientLimiter),
+		lastSweep: time.Now(),
+	}
+	for _, ip := range cfg.Whitelist {
+		rl.whitelist[ip] = true
+	}
+	for _, path := range cfg.ExemptPaths {
+		rl.exempt[path] = true
+	}
+
+	return rl
+}
+
+// limiterFor returns the bucket for a client, evicting idle buckets as it goes
+func (rl *rateLimiter) limiterFor(ip string) *rate.Limiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(rl.lastSweep) > limiterIdleTimeout {
+		for key, cl := range rl.clients {
+			if now.Sub(cl.lastSeen) > limiterIdleTimeout {
+				delete(rl.clients, key)
+			}
+		}
+		rl.lastSweep = now
+	}
+
+	cl, exists := rl.clients[ip]
+	if !exists {
+		cl = &clientLimiter{limiter: rate.NewLimiter(rate.Limit(rl.config.RPS), rl.config.Burst)}
+		rl.clients[ip] = cl
+	}
+	cl.lastSeen = now
+
+	return cl.limiter
+}
+
+// middleware rejects requests over the client's rate with 429 and a Retry-After header
+func (rl *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r)
+		if rl.exempt[r.URL.Path] || rl.whitelist[ip] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		reservation := rl.limiterFor(ip).Reserve()
+		if delay := reservation.Delay(); delay > 0 {
+			reservation.Cancel()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP extracts the client IP from the connection's remote address
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// EnableRateLimiting installs the rate limiting middleware; it is a no-op when cfg.Enabled is false
+func (s *Server) EnableRateLimiting(cfg RateLimitConfig) {
+	if !cfg.Enabled || cfg.RPS <= 0 {
+		return
+	}
+	s.router.Use(newRateLimiter(cfg).middleware)
+}
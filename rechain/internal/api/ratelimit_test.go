@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRateLimitedServer(cfg RateLimitConfig) *Server {
+	s := &Server{router: mux.NewRouter()}
+	s.routes()
+	s.EnableRateLimiting(cfg)
+	return s
+}
+
+// fire sends n requests from remoteAddr and counts responses by status code
+func fire(t *testing.T, s *Server, n int, path, remoteAddr string) map[int]int {
+	codes := make(map[int]int)
+	for i := 0; i < n; i++ {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		codes[rec.Code]++
+
+		if rec.Code == http.StatusTooManyRequests {
+			assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+		}
+	}
+	return codes
+}
+
+func TestRateLimiting(t *testing.T) {
+	t.Run("RejectsRequestsOverLimit", func(t *testing.T) {
+		s := newRateLimitedServer(DefaultRateLimitConfig(5, 5))
+
+		codes := fire(t, s, 20, "/node/info", "10.0.0.1:4000")
+		assert.Greater(t, codes[http.StatusOK], 0)
+		assert.Greater(t, codes[http.StatusTooManyRequests], 0)
+
+		// Other clients have their own bucket
+		codes = fire(t, s, 1, "/node/info", "10.0.0.2:4000")
+		assert.Equal(t, 1, codes[http.StatusOK])
+	})
+
+	t.Run("SetsRetryAfter", func(t *testing.T) {
+		s := newRateLimitedServer(DefaultRateLimitConfig(1, 1))
+		fire(t, s, 1, "/node/info", "10.0.0.1:4000")
+
+		req := httptest.NewRequest(http.MethodGet, "/node/info", nil)
+		req.RemoteAddr = "10.0.0.1:4000"
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	})
+
+	t.Run("ExemptsHealthAndLocalhost", func(t *testing.T) {
+		s := newRateLimitedServer(DefaultRateLimitConfig(1, 1))
+
+		codes := fire(t, s, 10, "/health", "10.0.0.1:4000")
+		assert.Equal(t, 10, codes[http.StatusOK])
+
+		codes = fire(t, s, 10, "/node/info", "127.0.0.1:4000")
+		assert.Equal(t, 10, codes[http.StatusOK])
+	})
+
+	t.Run("NoopWhenDisabled", func(t *testing.T) {
+		cfg := DefaultRateLimitConfig(1, 1)
+		cfg.Enabled = false
+		s := newRateLimitedServer(cfg)
+
+		codes := fire(t, s, 10, "/node/info", "10.0.0.1:4000")
+		assert.Equal(t, 10, codes[http.StatusOK])
+	})
+}
@@ -2,10 +2,15 @@ package api
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"path/filepath"
 	"strconv"
 	"time"
 
@@ -17,6 +22,12 @@ import (
 	"github.com/rechain/rechain/internal/storage"
 )
 
+// maxBlocksPerPage caps the ?limit= of block listings
+const maxBlocksPerPage = 100
+
+// nodeVersion is reported by /node/info and the OpenAPI document
+const nodeVersion = "0.1.0"
+
 // Server represents the API server
 type Server struct {
 	consensus *consensus.Consensus
@@ -26,6 +37,8 @@ type Server struct {
 	security  *security.KeyManager
 	httpServer *http.Server
 	router     *mux.Router
+	events     *eventHub
+	cors       *corsPolicy // nil unless EnableCORS was called
 }
 
 // NewServer creates a new API server
@@ -39,6 +52,11 @@ func NewServer(consensus *consensus.Consensus, store storage.Store, cas *cas.CAS
 		router:    mux.NewRouter(),
 	}
 
+	if consensus != nil {
+		srv.events = newEventHub()
+		go srv.events.run(consensus.Events())
+	}
+
 	srv.routes()
 
 	return srv
@@ -57,6 +75,9 @@ func (s *Server) Start(addr string) error {
 
 // Stop gracefully stops the API server
 func (s *Server) Stop() error {
+	if s.events != nil {
+		s.events.stop()
+	}
 	if s.httpServer == nil {
 		return nil
 	}
@@ -84,6 +105,7 @@ func (s *Server) routes() {
 
 	// CAS operations
 	s.router.HandleFunc("/cas/objects", s.handleStoreObject).Methods("POST")
+	s.router.HandleFunc("/cas/objects/batch", s.handleStoreObjectBatch).Methods("POST")
 	s.router.HandleFunc("/cas/objects/{cid}", s.handleGetObject).Methods("GET")
 	s.router.HandleFunc("/cas/objects/{cid}", s.handleDeleteObject).Methods("DELETE")
 	s.router.HandleFunc("/cas/objects", s.handleListObjects).Methods("GET")
@@ -99,6 +121,13 @@ func (s *Server) routes() {
 
 	// Consensus state
 	s.router.HandleFunc("/consensus/state", s.handleGetConsensusState).Methods("GET")
+
+	// Event subscriptions
+	s.router.HandleFunc("/ws/events", s.handleEvents).Methods("GET")
+
+	// API description, generated from the routes above
+	s.router.HandleFunc("/openapi.json", s.handleOpenAPI).Methods("GET")
+	s.router.HandleFunc("/docs", s.handleDocs).Methods("GET")
 }
 
 // API Response Helpers
@@ -120,6 +149,49 @@ func (s *Server) error(w http.ResponseWriter, r *http.Request, err error, status
 	}, status)
 }
 
+// TxResponse describes a transaction and its confirmation status
+type TxResponse struct {
+	Hash          string                 `json:"hash"`
+	Status        string                 `json:"status"` // "pending" or "committed"
+	BlockHeight   uint64                 `json:"block_height,omitempty"`
+	Index         int                    `json:"index"`
+	Confirmations uint64                 `json:"confirmations"`
+	Transaction   *consensus.Transaction `json:"transaction"`
+}
+
+// mempoolStatus maps a rejected transaction to 409 for duplicates and 503 when the mempool is full
+func mempoolStatus(err error) int {
+	switch {
+	case errors.Is(err, consensus.ErrDuplicateTx):
+		return http.StatusConflict
+	case errors.Is(err, consensus.ErrMempoolFull):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, consensus.ErrUnknownTxType), errors.Is(err, consensus.ErrInvalidTx):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// latestHeight returns the height of the most recently committed block, or 0 before the first commit
+func (s *Server) latestHeight(ctx context.Context) (uint64, error) {
+	data, err := s.store.Get(ctx, []byte("latest-height"))
+	if err != nil || data == nil {
+		return 0, err
+	}
+	return strconv.ParseUint(string(data), 10, 64)
+}
+
+// lowestFullHeight returns the lowest height whose block body is still
+// stored. Consensus prunes the bodies below pruned-height.
+func (s *Server) lowestFullHeight(ctx context.Context) (uint64, error) {
+	data, err := s.store.Get(ctx, []byte("pruned-height"))
+	if err != nil || data == nil {
+		return 1, err
+	}
+	return strconv.ParseUint(string(data), 10, 64)
+}
+
 // Handlers
 func (s *Server) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
 	s.respond(w, r, map[string]interface{}{
@@ -132,9 +204,9 @@ func (s *Server) handleGetLatestBlock(w http.ResponseWriter, r *http.Request) {
 	// Get latest block from storage
 	// This is simplified - in production, get from consensus
 	key := []byte("latest-block")
-	data, err := s.store.Get(context.Background(), key)
+	data, err := s.store.Get(r.Context(), key)
 	if err != nil {
-		s.error(w, r, fmt.Errorf("failed to get latest block: %w", err), http.StatusInternalServerError)
+		s.error(w, r, fmt.Errorf("failed to get latest block: %w", err), errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
@@ -163,9 +235,9 @@ func (s *Server) handleGetBlock(w http.ResponseWriter, r *http.Request) {
 
 	// Get block by height
 	key := []byte(fmt.Sprintf("block/%d", height))
-	data, err := s.store.Get(context.Background(), key)
+	data, err := s.store.Get(r.Context(), key)
 	if err != nil {
-		s.error(w, r, fmt.Errorf("failed to get block: %w", err), http.StatusInternalServerError)
+		s.error(w, r, fmt.Errorf("failed to get block: %w", err), errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
@@ -186,54 +258,127 @@ func (s *Server) handleGetBlock(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleGetBlocks(w http.ResponseWriter, r *http.Request) {
 	// Parse query parameters
 	limitStr := r.URL.Query().Get("limit")
-	limit := uint64(10) // default
+	limit := 10 // default
 	if limitStr != "" {
-		if l, err := strconv.ParseUint(limitStr, 10, 64); err == nil && l > 0 {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
 			limit = l
 		}
 	}
+	if limit > maxBlocksPerPage {
+		limit = maxBlocksPerPage
+	}
 
-	// This is simplified - in production, implement proper block listing
-	blocks := make([]map[string]interface{}, 0)
+	latest, err := s.latestHeight(r.Context())
+	if err != nil {
+		s.error(w, r, fmt.Errorf("failed to get latest height: %w", err), http.StatusInternalServerError)
+		return
+	}
 
-	// Get latest blocks (simplified)
-	for i := uint64(1); i <= limit; i++ {
-		key := []byte(fmt.Sprintf("block/%d", i))
-		data, err := s.store.Get(context.Background(), key)
-		if err != nil || data == nil {
-			break
+	// Walk backward from the newest block, or from just below ?before=
+	start := latest
+	if beforeStr := r.URL.Query().Get("before"); beforeStr != "" {
+		before, err := strconv.ParseUint(beforeStr, 10, 64)
+		if err != nil {
+			s.error(w, r, fmt.Errorf("invalid before height: %w", err), http.StatusBadRequest)
+			return
+		}
+		if before == 0 {
+			start = 0
+		} else if before <= start {
+			start = before - 1
+		}
+	}
+
+	// Pruned heights hold no body, so the listing ends where pruning stopped
+	floor, err := s.lowestFullHeight(r.Context())
+	if err != nil {
+		s.error(w, r, fmt.Errorf("failed to get pruned height: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	blocks := make([]map[string]interface{}, 0, limit)
+	var oldest uint64
+	for height := start; height >= floor && height > 0 && len(blocks) < limit; height-- {
+		data, err := s.store.Get(r.Context(), []byte(fmt.Sprintf("block/%d", height)))
+		if err != nil {
+			s.error(w, r, fmt.Errorf("failed to get block %d: %w", height, err), http.StatusInternalServerError)
+			return
+		}
+		if data == nil {
+			continue // tolerate gaps in storage
 		}
 
 		var block map[string]interface{}
-		if err := json.Unmarshal(data, &block); err == nil {
-			blocks = append(blocks, block)
+		if err := json.Unmarshal(data, &block); err != nil {
+			continue
 		}
+		blocks = append(blocks, block)
+		oldest = height
 	}
 
-	s.respond(w, r, map[string]interface{}{
+	resp := map[string]interface{}{
 		"blocks": blocks,
 		"count":  len(blocks),
-	}, http.StatusOK)
+		"latest": latest,
+	}
+	if len(blocks) == limit && oldest > floor {
+		resp["next_before"] = oldest
+	}
+	s.respond(w, r, resp, http.StatusOK)
 }
 
 func (s *Server) handleSubmitTx(w http.ResponseWriter, r *http.Request) {
 	var txReq struct {
-		Type    string                 `json:"type"`
-		Payload map[string]interface{} `json:"payload"`
+		// ID is chosen by the client and may be used once per key
+		ID       string                 `json:"id"`
+		Type     string                 `json:"type"`
+		Payload  map[string]interface{} `json:"payload"`
+		Priority int                    `json:"priority"`
+		// PublicKey and Signature are base64; the signature covers
+		// security.SubmittedTxSigningBytes of the ID, type, payload and priority.
+		PublicKey string `json:"public_key"`
+		Signature string `json:"signature"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&txReq); err != nil {
-		s.error(w, r, err, http.StatusBadRequest)
+		s.error(w, r, err, errorStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	// Only signed transactions enter the mempool, sent by the key that signed them
+	publicKey, err := base64.StdEncoding.DecodeString(txReq.PublicKey)
+	if err != nil || len(publicKey) == 0 {
+		s.error(w, r, fmt.Errorf("a base64 public_key is required"), http.StatusUnauthorized)
+		return
+	}
+	signature, err := base64.StdEncoding.DecodeString(txReq.Signature)
+	if err != nil || len(signature) == 0 {
+		s.error(w, r, fmt.Errorf("a base64 signature is required"), http.StatusUnauthorized)
+		return
+	}
+	sender, err := security.VerifySubmittedTx(publicKey, signature, txReq.ID, txReq.Type, txReq.Payload, txReq.Priority)
+	if err != nil {
+		s.error(w, r, fmt.Errorf("signature verification failed: %w", err), http.StatusUnauthorized)
+		return
+	}
+
+	// The signed ID is what makes a signed body single-use: a resubmission
+	// carries the same sender and ID and is rejected as a duplicate
+	if txReq.ID == "" {
+		s.error(w, r, fmt.Errorf("an id chosen by the client is required"), http.StatusBadRequest)
 		return
 	}
 
 	// Create transaction
 	tx := &consensus.Transaction{
-		ID:        fmt.Sprintf("tx-%d", time.Now().UnixNano()),
+		ID:        txReq.ID,
 		Type:      txReq.Type,
 		Payload:   nil, // Serialize payload
 		Timestamp: time.Now(),
-		Sender:    "api-client", // In production, get from auth
+		Sender:    sender,
+		Signature: signature,
+		PublicKey: publicKey,
+		Priority:  txReq.Priority,
 	}
 
 	payloadBytes, err := json.Marshal(txReq.Payload)
@@ -244,10 +389,14 @@ func (s *Server) handleSubmitTx(w http.ResponseWriter, r *http.Request) {
 	tx.Payload = payloadBytes
 
 	// Add to consensus mempool
-	s.consensus.AddTransaction(tx)
+	if err := s.consensus.AddTransaction(tx); err != nil {
+		s.error(w, r, err, mempoolStatus(err))
+		return
+	}
 
 	s.respond(w, r, map[string]interface{}{
 		"tx_id":     tx.ID,
+		"tx_hash":   tx.Hash(),
 		"status":    "submitted",
 		"timestamp": tx.Timestamp.Format(time.RFC3339),
 	}, http.StatusOK)
@@ -257,26 +406,36 @@ func (s *Server) handleGetTx(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	txHash := vars["hash"]
 
-	// This is simplified - in production, query transaction index
-	key := []byte(fmt.Sprintf("tx/%s", txHash))
-	data, err := s.store.Get(context.Background(), key)
+	tx, location, err := s.consensus.GetTransaction(r.Context(), txHash)
 	if err != nil {
 		s.error(w, r, fmt.Errorf("failed to get transaction: %w", err), http.StatusInternalServerError)
 		return
 	}
 
-	if data == nil {
+	if tx == nil {
+		// Not committed yet; it may still be waiting in the mempool
+		for _, pending := range s.consensus.GetMempool() {
+			if pending.Hash() == txHash {
+				s.respond(w, r, TxResponse{Hash: txHash, Status: "pending", Transaction: pending}, http.StatusOK)
+				return
+			}
+		}
 		s.error(w, r, fmt.Errorf("transaction not found"), http.StatusNotFound)
 		return
 	}
 
-	var tx map[string]interface{}
-	if err := json.Unmarshal(data, &tx); err != nil {
-		s.error(w, r, err, http.StatusInternalServerError)
-		return
+	resp := TxResponse{
+		Hash:        txHash,
+		Status:      "committed",
+		BlockHeight: location.Height,
+		Index:       location.Index,
+		Transaction: tx,
+	}
+	if latest, err := s.latestHeight(r.Context()); err == nil && latest >= location.Height {
+		resp.Confirmations = latest - location.Height + 1
 	}
 
-	s.respond(w, r, tx, http.StatusOK)
+	s.respond(w, r, resp, http.StatusOK)
 }
 
 func (s *Server) handleGetTxs(w http.ResponseWriter, r *http.Request) {
@@ -295,7 +454,7 @@ func (s *Server) handleGetTxs(w http.ResponseWriter, r *http.Request) {
 	// Get recent transactions (simplified)
 	for i := uint64(1); i <= limit; i++ {
 		key := []byte(fmt.Sprintf("tx/tx-%d", i))
-		data, err := s.store.Get(context.Background(), key)
+		data, err := s.store.Get(r.Context(), key)
 		if err != nil || data == nil {
 			break
 		}
@@ -316,15 +475,16 @@ func (s *Server) handleStoreObject(w http.ResponseWriter, r *http.Request) {
 	// Parse metadata from headers
 	metadata := make(map[string]string)
 	for key, values := range r.Header {
-		if len(values) > 0 && key != "Content-Type" {
+		if len(values) > 0 && key != "Content-Type" && key != "X-Filename" {
 			metadata[key] = values[0]
 		}
 	}
+	setObjectMetadata(metadata, r.Header.Get("Content-Type"), r.Header.Get("X-Filename"))
 
 	// Store object in CAS
-	objInfo, err := s.cas.Store(context.Background(), r.Body, metadata)
+	objInfo, err := s.cas.Store(r.Context(), r.Body, metadata)
 	if err != nil {
-		s.error(w, r, fmt.Errorf("failed to store object: %w", err), http.StatusInternalServerError)
+		s.error(w, r, fmt.Errorf("failed to store object: %w", err), errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
@@ -337,31 +497,159 @@ func (s *Server) handleStoreObject(w http.ResponseWriter, r *http.Request) {
 	}, http.StatusCreated)
 }
 
+// BatchObjectResult describes one object stored by a batch upload
+type BatchObjectResult struct {
+	CID        string `json:"cid"`
+	Size       int64  `json:"size"`
+	MerkleRoot string `json:"merkle_root"`
+}
+
+// handleStoreObjectBatch stores each part of a multipart body as an object,
+// streaming parts into CAS one at a time. Results are returned in input order.
+func (s *Server) handleStoreObjectBatch(w http.ResponseWriter, r *http.Request) {
+	parts, err := r.MultipartReader()
+	if err != nil {
+		s.error(w, r, fmt.Errorf("expected a multipart body: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	results := make([]BatchObjectResult, 0)
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.error(w, r, fmt.Errorf("failed to read part %d: %w", len(results), err), errorStatus(err, http.StatusBadRequest))
+			return
+		}
+
+		metadata := make(map[string]string)
+		setObjectMetadata(metadata, part.Header.Get("Content-Type"), part.FileName())
+
+		objInfo, err := s.cas.Store(r.Context(), part, metadata)
+		part.Close()
+		if err != nil {
+			s.error(w, r, fmt.Errorf("failed to store object %d: %w", len(results), err), errorStatus(err, http.StatusInternalServerError))
+			return
+		}
+
+		results = append(results, BatchObjectResult{
+			CID:        objInfo.CID,
+			Size:       objInfo.Size,
+			MerkleRoot: objInfo.MerkleRoot,
+		})
+	}
+
+	s.respond(w, r, map[string]interface{}{
+		"objects": results,
+		"count":   len(results),
+	}, http.StatusCreated)
+}
+
 func (s *Server) handleGetObject(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	cid := vars["cid"]
 
-	// Retrieve object from CAS
-	reader, err := s.cas.Retrieve(context.Background(), cid)
+	if exists, err := s.cas.Exists(r.Context(), cid); err != nil {
+		s.error(w, r, fmt.Errorf("failed to look up object: %w", err), http.StatusInternalServerError)
+		return
+	} else if !exists {
+		s.error(w, r, fmt.Errorf("object not found"), http.StatusNotFound)
+		return
+	}
+
+	info, err := s.cas.GetInfo(r.Context(), cid)
+	if err != nil {
+		s.error(w, r, fmt.Errorf("failed to get object info: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Objects are immutable, so a matching validator, or "*" now that the
+	// object is known to exist, means the client's copy is current
+	etag := objectETag(cid)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", immutableCacheControl)
+	if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	offset, length, partial, err := parseByteRange(r.Header.Get("Range"), info.Size)
+	if err != nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+		s.error(w, r, err, http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	// Retrieve the object, or only the chunks covering the requested range
+	var reader io.ReadCloser
+	if partial {
+		reader, err = s.cas.RetrieveRange(r.Context(), info, offset, length)
+	} else {
+		reader, err = s.cas.Retrieve(r.Context(), cid)
+	}
 	if err != nil {
 		s.error(w, r, fmt.Errorf("failed to retrieve object: %w", err), http.StatusInternalServerError)
 		return
 	}
 	defer reader.Close()
 
-	// Stream object to response
-	w.Header().Set("Content-Type", "application/octet-stream")
+	// Stream object to response with the type and name it was uploaded with
+	contentType := info.Metadata[cas.MetadataContentType]
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	if filename := info.Metadata[cas.MetadataFilename]; filename != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	}
 	w.Header().Set("X-Content-ID", cid)
-	io.Copy(w, reader)
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	if partial {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, info.Size))
+		w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+		w.WriteHeader(http.StatusPartialContent)
+	}
+	if _, err := io.Copy(w, reader); err != nil {
+		log.Printf("Failed to stream object %s: %v", cid, err)
+	}
+}
+
+// setObjectMetadata records an upload's content type and file name. Only the
+// base name of the file is kept.
+func setObjectMetadata(metadata map[string]string, contentType, filename string) {
+	if contentType != "" {
+		metadata[cas.MetadataContentType] = contentType
+	}
+	if filename != "" {
+		if base := filepath.Base(filepath.Clean("/" + filename)); base != "/" {
+			metadata[cas.MetadataFilename] = base
+		}
+	}
 }
 
 func (s *Server) handleDeleteObject(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	cid := vars["cid"]
 
+	// Honor If-Match so clients can make sure they delete the object they expect
+	if match := r.Header.Get("If-Match"); match != "" {
+		exists, err := s.cas.Exists(r.Context(), cid)
+		if err != nil {
+			s.error(w, r, fmt.Errorf("failed to look up object: %w", err), http.StatusInternalServerError)
+			return
+		}
+		if !exists || !etagMatches(match, objectETag(cid), false) {
+			s.error(w, r, fmt.Errorf("precondition failed"), http.StatusPreconditionFailed)
+			return
+		}
+	}
+
 	// Delete object from CAS
-	if err := s.cas.Delete(context.Background(), cid); err != nil {
-		s.error(w, r, fmt.Errorf("failed to delete object: %w", err), http.StatusInternalServerError)
+	if err := s.cas.Delete(r.Context(), cid); err != nil {
+		s.error(w, r, fmt.Errorf("failed to delete object: %w", err), errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
@@ -373,9 +661,9 @@ func (s *Server) handleListObjects(w http.ResponseWriter, r *http.Request) {
 	prefix := r.URL.Query().Get("prefix")
 
 	// List objects from CAS
-	objects, err := s.cas.List(context.Background(), prefix)
+	objects, err := s.cas.List(r.Context(), prefix)
 	if err != nil {
-		s.error(w, r, fmt.Errorf("failed to list objects: %w", err), http.StatusInternalServerError)
+		s.error(w, r, fmt.Errorf("failed to list objects: %w", err), errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
@@ -407,7 +695,7 @@ func (s *Server) handleUpdateGossipState(w http.ResponseWriter, r *http.Request)
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
-		s.error(w, r, err, http.StatusBadRequest)
+		s.error(w, r, err, errorStatus(err, http.StatusBadRequest))
 		return
 	}
 
@@ -426,7 +714,7 @@ func (s *Server) handleQueryGossip(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&queryReq); err != nil {
-		s.error(w, r, err, http.StatusBadRequest)
+		s.error(w, r, err, errorStatus(err, http.StatusBadRequest))
 		return
 	}
 
@@ -442,7 +730,7 @@ func (s *Server) handleQueryGossip(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleNodeInfo(w http.ResponseWriter, r *http.Request) {
 	// Get node information
 	info := map[string]interface{}{
-		"version":       "0.1.0",
+		"version":       nodeVersion,
 		"network":       "rechain-mainnet",
 		"consensus":     "bft",
 		"start_time":    time.Now().Format(time.RFC3339), // In production, track actual start time
@@ -472,12 +760,13 @@ func (s *Server) handleGetPeers(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleGetConsensusState(w http.ResponseWriter, r *http.Request) {
 	// Get consensus state
 	state := map[string]interface{}{
-		"height":      0, // In production, get from consensus
-		"round":       0,
+		"height":      s.consensus.Height(),
+		"round":       s.consensus.Round(),
 		"step":        "unknown",
 		"proposer":    "unknown",
 		"validators":  []string{"node-1"},
 		"mempool_size": len(s.consensus.GetMempool()),
+		"missed_proposals": s.consensus.MissedProposals(),
 	}
 	s.respond(w, r, state, http.StatusOK)
 }
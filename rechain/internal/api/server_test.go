@@ -0,0 +1,232 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rechain/rechain/internal/consensus"
+	"github.com/rechain/rechain/internal/security"
+	"github.com/rechain/rechain/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer creates a Server backed by an in-memory store and a
+// single-validator consensus engine that has not been started yet
+func newTestServer(t *testing.T) (*Server, *consensus.Consensus) {
+	t.Helper()
+
+	store := storage.NewMemoryStore()
+	engine, err := consensus.NewConsensus(store, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { engine.Stop() })
+
+	return NewServer(engine, store, nil, nil, nil), engine
+}
+
+func doJSON(t *testing.T, s *Server, method, path string, body interface{}, out interface{}) int {
+	t.Helper()
+
+	var reqBody bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&reqBody).Encode(body))
+	}
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(method, path, &reqBody))
+	if out != nil {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), out))
+	}
+	return rec.Code
+}
+
+// signedTx builds a POST /txs body with the given ID signed by km
+func signedTx(t *testing.T, km *security.KeyManager, id, txType string, payload map[string]interface{}) map[string]interface{} {
+	t.Helper()
+
+	data, err := security.SubmittedTxSigningBytes(id, txType, payload, 0)
+	require.NoError(t, err)
+	signature, err := km.SignData(data)
+	require.NoError(t, err)
+	publicKey, err := km.PublicKeyBytes()
+	require.NoError(t, err)
+
+	return map[string]interface{}{
+		"id":         id,
+		"type":       txType,
+		"payload":    payload,
+		"public_key": base64.StdEncoding.EncodeToString(publicKey),
+		"signature":  base64.StdEncoding.EncodeToString(signature),
+	}
+}
+
+func TestGetTxByHash(t *testing.T) {
+	s, engine := newTestServer(t)
+	km, err := security.NewKeyManagerWithAlgorithm(security.AlgorithmEd25519)
+	require.NoError(t, err)
+
+	var submitted struct {
+		TxHash string `json:"tx_hash"`
+	}
+	code := doJSON(t, s, http.MethodPost, "/txs", signedTx(t, km, "tx-1", "transfer", map[string]interface{}{"amount": 5}), &submitted)
+	require.Equal(t, http.StatusOK, code)
+	require.NotEmpty(t, submitted.TxHash)
+
+	var pending TxResponse
+	require.Equal(t, http.StatusOK, doJSON(t, s, http.MethodGet, "/txs/"+submitted.TxHash, nil, &pending))
+	assert.Equal(t, "pending", pending.Status)
+
+	// A single validator commits height 1 with the mempool as soon as it starts
+	require.NoError(t, engine.Start())
+
+	var committed TxResponse
+	require.Equal(t, http.StatusOK, doJSON(t, s, http.MethodGet, "/txs/"+submitted.TxHash, nil, &committed))
+	assert.Equal(t, "committed", committed.Status)
+	assert.Equal(t, submitted.TxHash, committed.Hash)
+	assert.Equal(t, uint64(1), committed.BlockHeight)
+	assert.Equal(t, 0, committed.Index)
+	assert.Equal(t, uint64(1), committed.Confirmations)
+	require.NotNil(t, committed.Transaction)
+	assert.Equal(t, "transfer", committed.Transaction.Type)
+
+	assert.Equal(t, http.StatusNotFound, doJSON(t, s, http.MethodGet, "/txs/deadbeef", nil, nil))
+}
+
+func TestSubmitTxVerifiesSignature(t *testing.T) {
+	for _, algorithm := range []string{security.AlgorithmEd25519, security.AlgorithmRSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			s, engine := newTestServer(t)
+			km, err := security.NewKeyManagerWithAlgorithm(algorithm)
+			require.NoError(t, err)
+			publicKey, err := km.PublicKeyBytes()
+			require.NoError(t, err)
+
+			var submitted struct {
+				TxHash string `json:"tx_hash"`
+			}
+			body := signedTx(t, km, "tx-1", "transfer", map[string]interface{}{"amount": 5, "to": "bob"})
+			require.Equal(t, http.StatusOK, doJSON(t, s, http.MethodPost, "/txs", body, &submitted))
+
+			mempool := engine.GetMempool()
+			require.Len(t, mempool, 1)
+			assert.Equal(t, security.PublicKeyID(publicKey), mempool[0].Sender)
+			assert.NotEmpty(t, mempool[0].Signature)
+
+			// Changing the payload after signing invalidates the signature
+			tampered := signedTx(t, km, "tx-2", "transfer", map[string]interface{}{"amount": 5, "to": "bob"})
+			tampered["payload"] = map[string]interface{}{"amount": 500, "to": "bob"}
+			var failed map[string]string
+			assert.Equal(t, http.StatusUnauthorized, doJSON(t, s, http.MethodPost, "/txs", tampered, &failed))
+			assert.Contains(t, failed["error"], "signature verification failed")
+
+			// The priority is signed too, so it cannot be raised in transit
+			bumped := signedTx(t, km, "tx-4", "transfer", map[string]interface{}{"amount": 5, "to": "bob"})
+			bumped["priority"] = 10
+			assert.Equal(t, http.StatusUnauthorized, doJSON(t, s, http.MethodPost, "/txs", bumped, nil))
+
+			// So does signing with a different key than the one presented
+			other, err := security.NewKeyManagerWithAlgorithm(algorithm)
+			require.NoError(t, err)
+			forged := signedTx(t, other, "tx-3", "transfer", map[string]interface{}{"amount": 5, "to": "bob"})
+			forged["public_key"] = body["public_key"]
+			assert.Equal(t, http.StatusUnauthorized, doJSON(t, s, http.MethodPost, "/txs", forged, nil))
+
+			unsigned := map[string]interface{}{"type": "transfer", "payload": map[string]interface{}{"amount": 5}}
+			assert.Equal(t, http.StatusUnauthorized, doJSON(t, s, http.MethodPost, "/txs", unsigned, nil))
+
+			assert.Len(t, engine.GetMempool(), 1)
+		})
+	}
+}
+
+func TestSubmitTxRejectsReplay(t *testing.T) {
+	s, engine := newTestServer(t)
+	km, err := security.NewKeyManagerWithAlgorithm(security.AlgorithmEd25519)
+	require.NoError(t, err)
+
+	body := signedTx(t, km, "tx-1", "transfer", map[string]interface{}{"amount": 5, "to": "bob"})
+	require.Equal(t, http.StatusOK, doJSON(t, s, http.MethodPost, "/txs", body, nil))
+
+	// The same signed body, sent again, is rejected while pending
+	var failed map[string]string
+	require.Equal(t, http.StatusConflict, doJSON(t, s, http.MethodPost, "/txs", body, &failed))
+	assert.Contains(t, failed["error"], "duplicate transaction")
+	assert.Len(t, engine.GetMempool(), 1)
+
+	// and once committed
+	require.NoError(t, engine.Start())
+	require.Empty(t, engine.GetMempool())
+	require.Equal(t, http.StatusConflict, doJSON(t, s, http.MethodPost, "/txs", body, nil))
+
+	// A signed body without an ID is refused
+	anonymous := signedTx(t, km, "", "transfer", map[string]interface{}{"amount": 5, "to": "bob"})
+	require.Equal(t, http.StatusBadRequest, doJSON(t, s, http.MethodPost, "/txs", anonymous, nil))
+	assert.Empty(t, engine.GetMempool())
+}
+
+func TestGetBlocksNewestFirstWithPagination(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	// Five committed blocks, laid out the way consensus stores them
+	for height := uint64(1); height <= 5; height++ {
+		data, err := json.Marshal(&consensus.Block{Height: height})
+		require.NoError(t, err)
+		require.NoError(t, s.store.Set(context.Background(), []byte(fmt.Sprintf("block/%d", height)), data))
+	}
+	require.NoError(t, s.store.Set(context.Background(), []byte("latest-height"), []byte("5")))
+
+	type page struct {
+		Blocks []struct {
+			Height uint64
+		} `json:"blocks"`
+		Count      int    `json:"count"`
+		NextBefore uint64 `json:"next_before"`
+	}
+	heights := func(p page) []uint64 {
+		out := make([]uint64, len(p.Blocks))
+		for i, b := range p.Blocks {
+			out[i] = b.Height
+		}
+		return out
+	}
+
+	var all page
+	require.Equal(t, http.StatusOK, doJSON(t, s, http.MethodGet, "/blocks", nil, &all))
+	assert.Equal(t, []uint64{5, 4, 3, 2, 1}, heights(all))
+	assert.Zero(t, all.NextBefore)
+
+	var first page
+	require.Equal(t, http.StatusOK, doJSON(t, s, http.MethodGet, "/blocks?limit=2", nil, &first))
+	assert.Equal(t, []uint64{5, 4}, heights(first))
+	require.Equal(t, uint64(4), first.NextBefore)
+
+	var second page
+	require.Equal(t, http.StatusOK, doJSON(t, s, http.MethodGet, fmt.Sprintf("/blocks?limit=2&before=%d", first.NextBefore), nil, &second))
+	assert.Equal(t, []uint64{3, 2}, heights(second))
+
+	var last page
+	require.Equal(t, http.StatusOK, doJSON(t, s, http.MethodGet, fmt.Sprintf("/blocks?limit=2&before=%d", second.NextBefore), nil, &last))
+	assert.Equal(t, []uint64{1}, heights(last))
+	assert.Zero(t, last.NextBefore)
+
+	// Gaps in storage are skipped rather than ending the listing
+	require.NoError(t, s.store.Delete(context.Background(), []byte("block/4")))
+	var gapped page
+	require.Equal(t, http.StatusOK, doJSON(t, s, http.MethodGet, "/blocks?limit=3", nil, &gapped))
+	assert.Equal(t, []uint64{5, 3, 2}, heights(gapped))
+
+	// Pruned heights end the listing instead of being read one by one
+	require.NoError(t, s.store.Set(context.Background(), []byte("pruned-height"), []byte("3")))
+	var pruned page
+	require.Equal(t, http.StatusOK, doJSON(t, s, http.MethodGet, "/blocks?limit=2", nil, &pruned))
+	assert.Equal(t, []uint64{5, 3}, heights(pruned))
+	assert.Zero(t, pruned.NextBefore)
+	require.Equal(t, http.StatusOK, doJSON(t, s, http.MethodGet, "/blocks?before=3", nil, &pruned))
+	assert.Empty(t, pruned.Blocks)
+}
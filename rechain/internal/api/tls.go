@@ -0,0 +1,71 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+)
+
+// TLSOptions configures HTTPS for the API server
+type TLSOptions struct {
+	CertFile string
+	KeyFile  string
+	// CAFile is the CA bundle used to verify client certificates
+	CAFile string
+	// ClientCertRequired enables mutual TLS
+	ClientCertRequired bool
+}
+
+// LoadTLSConfig loads the server certificate and, for mTLS, the client CA pool
+func LoadTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS cert: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if opts.ClientCertRequired {
+		caPEM, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", opts.CAFile)
+		}
+
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
+}
+
+// StartTLS starts the API server over HTTPS
+func (s *Server) StartTLS(addr string, opts TLSOptions) error {
+	tlsConfig, err := LoadTLSConfig(opts)
+	if err != nil {
+		return err
+	}
+	return s.ServeTLS(addr, tlsConfig)
+}
+
+// ServeTLS starts the API server over HTTPS with a config from LoadTLSConfig
+func (s *Server) ServeTLS(addr string, tlsConfig *tls.Config) error {
+	s.httpServer = &http.Server{
+		Addr:      addr,
+		Handler:   s.router,
+		TLSConfig: tlsConfig,
+	}
+
+	log.Printf("API server starting on %s (TLS, client certs required: %v)", addr, tlsConfig.ClientAuth == tls.RequireAndVerifyClientCert)
+	return s.httpServer.ListenAndServeTLS("", "")
+}
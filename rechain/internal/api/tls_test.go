@@ -0,0 +1,194 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+// issueTestCert creates a certificate signed by parent, or a self-signed CA when parent is nil
+func issueTestCert(t *testing.T, dir, name string, parent *testCert, isClient bool) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if isClient {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.ExtKeyUsage = nil
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	tc := &testCert{
+		cert:     cert,
+		key:      key,
+		certFile: filepath.Join(dir, name+".crt"),
+		keyFile:  filepath.Join(dir, name+".key"),
+	}
+	require.NoError(t, os.WriteFile(tc.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(tc.keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	return tc
+}
+
+// startTLSServer runs a Server with TLS on a free port and returns its base URL
+func startTLSServer(t *testing.T, opts TLSOptions) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	s := &Server{router: mux.NewRouter()}
+	s.routes()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.StartTLS(addr, opts) }()
+	t.Cleanup(func() { s.Stop() })
+
+	// Wait for the listener to come up
+	for i := 0; i < 50; i++ {
+		select {
+		case err := <-errCh:
+			t.Fatalf("server failed to start: %v", err)
+		default:
+		}
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			return "https://" + addr
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("server did not start listening")
+	return ""
+}
+
+func httpsClient(ca *testCert, clientCert *testCert) *http.Client {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	tlsConfig := &tls.Config{RootCAs: pool}
+	if clientCert != nil {
+		tlsConfig.Certificates = []tls.Certificate{{
+			Certificate: [][]byte{clientCert.cert.Raw},
+			PrivateKey:  clientCert.key,
+		}}
+	}
+
+	return &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+}
+
+func TestServerTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := issueTestCert(t, dir, "ca", nil, false)
+	server := issueTestCert(t, dir, "server", ca, false)
+
+	url := startTLSServer(t, TLSOptions{CertFile: server.certFile, KeyFile: server.keyFile})
+
+	resp, err := httpsClient(ca, nil).Get(url + "/health")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestServerMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := issueTestCert(t, dir, "ca", nil, false)
+	server := issueTestCert(t, dir, "server", ca, false)
+	client := issueTestCert(t, dir, "client", ca, true)
+
+	url := startTLSServer(t, TLSOptions{
+		CertFile:           server.certFile,
+		KeyFile:            server.keyFile,
+		CAFile:             ca.certFile,
+		ClientCertRequired: true,
+	})
+
+	t.Run("RejectsClientWithoutCert", func(t *testing.T) {
+		_, err := httpsClient(ca, nil).Get(url + "/health")
+		require.Error(t, err)
+	})
+
+	t.Run("AcceptsClientWithCert", func(t *testing.T) {
+		resp, err := httpsClient(ca, client).Get(url + "/health")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
+
+func TestLoadTLSConfigRejectsMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	ca := issueTestCert(t, dir, "ca", nil, false)
+	server := issueTestCert(t, dir, "server", ca, false)
+
+	_, err := LoadTLSConfig(TLSOptions{
+		CertFile: filepath.Join(dir, "missing.crt"),
+		KeyFile:  filepath.Join(dir, "missing.key"),
+	})
+	require.Error(t, err)
+
+	_, err = LoadTLSConfig(TLSOptions{
+		CertFile:           server.certFile,
+		KeyFile:            server.keyFile,
+		CAFile:             filepath.Join(dir, "missing-ca.crt"),
+		ClientCertRequired: true,
+	})
+	require.Error(t, err)
+
+	tlsConfig, err := LoadTLSConfig(TLSOptions{CertFile: server.certFile, KeyFile: server.keyFile})
+	require.NoError(t, err)
+	require.Len(t, tlsConfig.Certificates, 1)
+}
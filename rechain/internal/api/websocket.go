@@ -0,0 +1,292 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rechain/rechain/internal/consensus"
+)
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+	wsSendBuffer = 64
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// subscriptionRequest changes the event types a client receives, e.g. {"subscribe":["block","tx"]}
+type subscriptionRequest struct {
+	Subscribe   []string `json:"subscribe"`
+	Unsubscribe []string `json:"unsubscribe"`
+}
+
+// eventHub fans the consensus event stream out to WebSocket clients
+type eventHub struct {
+	mu      sync.RWMutex
+	clients map[*wsClient]struct{}
+
+	quit     chan struct{}
+	stopOnce sync.Once
+}
+
+// wsClient is one WebSocket connection and the event types it subscribed to
+type wsClient struct {
+	hub  *eventHub
+	conn *websocket.Conn
+	send chan []byte
+
+	mu            sync.RWMutex
+	subscriptions map[string]bool
+	closed        bool
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		clients: make(map[*wsClient]struct{}),
+		quit:    make(chan struct{}),
+	}
+}
+
+// run delivers events until the hub is stopped or the stream closes
+func (h *eventHub) run(events <-chan consensus.Event) {
+	for {
+		select {
+		case <-h.quit:
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			h.broadcast(ev)
+		}
+	}
+}
+
+// broadcast sends an event to every subscribed client, dropping clients that
+// cannot keep up
+func (h *eventHub) broadcast(ev consensus.Event) {
+	msg, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("Failed to encode event: %v", err)
+		return
+	}
+
+	h.mu.RLock()
+	var slow []*wsClient
+	for client := range h.clients {
+		if client.subscribed(ev.Type) && !client.trySend(msg) {
+			slow = append(slow, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range slow {
+		log.Printf("Dropping slow WebSocket client %s", client.conn.RemoteAddr())
+		h.remove(client)
+	}
+}
+
+func (h *eventHub) add(client *wsClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[client] = struct{}{}
+}
+
+// remove unregisters a client and closes its send channel, which ends its writer
+func (h *eventHub) remove(client *wsClient) {
+	h.mu.Lock()
+	delete(h.clients, client)
+	h.mu.Unlock()
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if !client.closed {
+		client.closed = true
+		close(client.send)
+	}
+}
+
+// stop ends delivery and disconnects every client
+func (h *eventHub) stop() {
+	h.stopOnce.Do(func() {
+		close(h.quit)
+
+		h.mu.RLock()
+		clients := make([]*wsClient, 0, len(h.clients))
+		for client := range h.clients {
+			clients = append(clients, client)
+		}
+		h.mu.RUnlock()
+
+		for _, client := range clients {
+			h.remove(client)
+		}
+	})
+}
+
+func (c *wsClient) subscribed(eventType string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.subscriptions[eventType]
+}
+
+// trySend queues a message without blocking, reporting false if the buffer is full
+func (c *wsClient) trySend(msg []byte) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return true
+	}
+	select {
+	case c.send <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// update applies a subscription request and returns the resulting event types
+func (c *wsClient) update(req subscriptionRequest) ([]string, error) {
+	for _, eventType := range append(append([]string{}, req.Subscribe...), req.Unsubscribe...) {
+		if eventType != consensus.EventBlock && eventType != consensus.EventTx {
+			return nil, fmt.Errorf("unknown event type %q", eventType)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, eventType := range req.Subscribe {
+		c.subscriptions[eventType] = true
+	}
+	for _, eventType := range req.Unsubscribe {
+		delete(c.subscriptions, eventType)
+	}
+
+	types := make([]string, 0, len(c.subscriptions))
+	for eventType := range c.subscriptions {
+		types = append(types, eventType)
+	}
+	sort.Strings(types)
+	return types, nil
+}
+
+// readPump handles subscription messages until the connection closes
+func (c *wsClient) readPump() {
+	defer func() {
+		c.hub.remove(c)
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(4096)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var req subscriptionRequest
+		if err := c.conn.ReadJSON(&req); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("WebSocket read error: %v", err)
+			}
+			return
+		}
+
+		var reply interface{}
+		if types, err := c.update(req); err != nil {
+			reply = map[string]string{"error": err.Error()}
+		} else {
+			reply = map[string][]string{"subscribed": types}
+		}
+
+		msg, _ := json.Marshal(reply)
+		if !c.trySend(msg) {
+			return
+		}
+	}
+}
+
+// writePump writes queued messages and keepalive pings to the connection
+func (c *wsClient) writePump() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// checkWSOrigin allows WebSocket upgrades from clients that send no Origin,
+// from the API's own origin and from the origins CORS allows. Browsers do not
+// apply CORS to WebSocket handshakes, so any page could otherwise open one.
+func (s *Server) checkWSOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return s.cors != nil && s.cors.allowOrigin(origin) != ""
+}
+
+// handleEvents upgrades to a WebSocket that streams block and transaction events
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if s.events == nil {
+		s.error(w, r, fmt.Errorf("event stream not available"), http.StatusServiceUnavailable)
+		return
+	}
+
+	upgrader := wsUpgrader
+	upgrader.CheckOrigin = s.checkWSOrigin
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an error response
+		log.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+
+	client := &wsClient{
+		hub:           s.events,
+		conn:          conn,
+		send:          make(chan []byte, wsSendBuffer),
+		subscriptions: make(map[string]bool),
+	}
+	s.events.add(client)
+
+	go client.writePump()
+	client.readPump()
+}
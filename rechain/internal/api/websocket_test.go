@@ -0,0 +1,109 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rechain/rechain/internal/consensus"
+	"github.com/rechain/rechain/internal/security"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventsWebSocketDeliversBlocks(t *testing.T) {
+	s, engine := newTestServer(t)
+	t.Cleanup(func() { s.Stop() })
+
+	ts := httptest.NewServer(s.router)
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws/events", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	require.NoError(t, conn.WriteJSON(map[string][]string{"subscribe": {"block"}}))
+	var ack struct {
+		Subscribed []string `json:"subscribed"`
+	}
+	require.NoError(t, conn.ReadJSON(&ack))
+	assert.Equal(t, []string{"block"}, ack.Subscribed)
+
+	// The tx event is filtered out; the first message is the committed block
+	km, err := security.NewKeyManagerWithAlgorithm(security.AlgorithmEd25519)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, doJSON(t, s, http.MethodPost, "/txs", signedTx(t, km, "tx-1", "test", nil), nil))
+	require.NoError(t, engine.Start())
+
+	var ev consensus.Event
+	require.NoError(t, conn.ReadJSON(&ev))
+	assert.Equal(t, consensus.EventBlock, ev.Type)
+	assert.Equal(t, uint64(1), ev.Height)
+	assert.Equal(t, 1, ev.TxCount)
+	assert.NotEmpty(t, ev.Hash)
+}
+
+func TestEventsWebSocketRejectsUnknownType(t *testing.T) {
+	s, _ := newTestServer(t)
+	t.Cleanup(func() { s.Stop() })
+
+	ts := httptest.NewServer(s.router)
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws/events", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	require.NoError(t, conn.WriteJSON(map[string][]string{"subscribe": {"votes"}}))
+	var reply map[string]interface{}
+	require.NoError(t, conn.ReadJSON(&reply))
+	assert.Contains(t, reply["error"], "votes")
+}
+
+func TestEventsWebSocketChecksOrigin(t *testing.T) {
+	s, _ := newTestServer(t)
+	t.Cleanup(func() { s.Stop() })
+
+	ts := httptest.NewServer(s.router)
+	defer ts.Close()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws/events"
+
+	dial := func(origin string) (int, error) {
+		header := http.Header{}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+		conn, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+		if err == nil {
+			conn.Close()
+			return resp.StatusCode, nil
+		}
+		if resp == nil {
+			return 0, err
+		}
+		return resp.StatusCode, err
+	}
+
+	// Non-browser clients and the API's own origin may always connect
+	for _, origin := range []string{"", ts.URL} {
+		code, err := dial(origin)
+		require.NoError(t, err, origin)
+		assert.Equal(t, http.StatusSwitchingProtocols, code)
+	}
+
+	// Other origins need to be allowed by CORS
+	code, _ := dial("https://app.example.com")
+	assert.Equal(t, http.StatusForbidden, code)
+
+	s.EnableCORS(DefaultCORSConfig([]string{"https://app.example.com"}))
+	code, err := dial("https://app.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, code)
+	code, _ = dial("https://evil.example.com")
+	assert.Equal(t, http.StatusForbidden, code)
+}
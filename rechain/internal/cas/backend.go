@@ -0,0 +1,127 @@
+package cas
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// Backend is the object store that CAS keeps chunks and metadata in
+type Backend interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	Remove(ctx context.Context, key string) error
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// minioBackend stores objects in an S3-compatible bucket
+type minioBackend struct {
+	client *minio.Client
+	bucket string
+}
+
+func (b *minioBackend) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	_, err := b.client.PutObject(ctx, b.bucket, key, r, size, minio.PutObjectOptions{})
+	return err
+}
+
+func (b *minioBackend) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return b.client.GetObject(ctx, b.bucket, key, minio.GetObjectOptions{})
+}
+
+func (b *minioBackend) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := b.client.StatObject(ctx, b.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (b *minioBackend) Remove(ctx context.Context, key string) error {
+	return b.client.RemoveObject(ctx, b.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (b *minioBackend) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for obj := range b.client.ListObjects(ctx, b.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		keys = append(keys, obj.Key)
+	}
+	return keys, nil
+}
+
+// MemoryBackend keeps objects in memory, for tests and single-process tooling
+type MemoryBackend struct {
+	mu      sync.RWMutex
+	objects map[string][]byte
+}
+
+// NewMemoryBackend creates an empty in-memory backend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{objects: make(map[string][]byte)}
+}
+
+func (b *MemoryBackend) Put(_ context.Context, key string, r io.Reader, _ int64) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.objects[key] = data
+	return nil
+}
+
+func (b *MemoryBackend) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	data, exists := b.objects[key]
+	if !exists {
+		return nil, fmt.Errorf("object %s not found", key)
+	}
+	return io.NopCloser(strings.NewReader(string(data))), nil
+}
+
+func (b *MemoryBackend) Exists(_ context.Context, key string) (bool, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	_, exists := b.objects[key]
+	return exists, nil
+}
+
+func (b *MemoryBackend) Remove(_ context.Context, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.objects, key)
+	return nil
+}
+
+// List returns the keys with the given prefix in sorted order
+func (b *MemoryBackend) List(_ context.Context, prefix string) ([]string, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	keys := make([]string, 0)
+	for key := range b.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
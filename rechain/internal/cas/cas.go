@@ -1,36 +1,48 @@
 package cas
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
-	"log"
-	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/rechain/rechain/pkg/logging"
 )
 
+var logger = logging.Component("cas")
+
+// Metadata keys that the API serves back when an object is retrieved
+const (
+	MetadataContentType = "content_type"
+	MetadataFilename    = "filename"
+)
+
+// defaultChunkSize is the chunk size used when none is configured
+const defaultChunkSize = 64 * 1024 * 1024 // 64MB chunks
+
 // CAS implements Content-Addressed Storage with S3 compatibility
 type CAS struct {
-	client     *minio.Client
-	bucket     string
+	backend    Backend
 	chunkSize  int64
 	maxRetries int
+	hashAlg    HashAlgorithm
 }
 
 // ObjectInfo holds metadata about a stored object
 type ObjectInfo struct {
-	CID       string    // Content ID (hash)
-	Size      int64     // Object size in bytes
-	Chunks    []string  // Chunk CIDs
-	MerkleRoot string   // Merkle root hash
-	Uploaded  time.Time // Upload timestamp
-	Metadata  map[string]string // Additional metadata
+	CID        string            `json:"cid"`         // Content ID (hash)
+	Size       int64             `json:"size"`        // Object size in bytes
+	Chunks     []string          `json:"chunks"`      // Chunk CIDs
+	ChunkSize  int64             `json:"chunk_size"`  // Size of every chunk but the last
+	MerkleRoot string            `json:"merkle_root"` // Merkle root hash
+	Uploaded   time.Time         `json:"uploaded"`    // Upload timestamp
+	Metadata   map[string]string `json:"metadata"`    // Additional metadata
 }
 
 // NewCAS creates a new CAS instance
@@ -43,49 +55,85 @@ func NewCAS(endpoint, accessKey, secretKey, bucket string, secure bool) (*CAS, e
 		return nil, fmt.Errorf("failed to create MinIO client: %w", err)
 	}
 
-	cas := &CAS{
-		client:     client,
-		bucket:     bucket,
-		chunkSize:  64 * 1024 * 1024, // 64MB chunks
-		maxRetries: 3,
-	}
-
 	// Ensure bucket exists
-	if err := cas.ensureBucket(); err != nil {
+	if err := ensureBucket(client, bucket); err != nil {
 		return nil, fmt.Errorf("failed to ensure bucket: %w", err)
 	}
 
-	return cas, nil
+	return NewCASWithBackend(&minioBackend{client: client, bucket: bucket}, defaultChunkSize), nil
+}
+
+// NewCASWithBackend creates a CAS on top of an arbitrary backend. A chunkSize of
+// zero uses the default.
+func NewCASWithBackend(backend Backend, chunkSize int64) *CAS {
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	return &CAS{
+		backend:    backend,
+		chunkSize:  chunkSize,
+		maxRetries: 3,
+		hashAlg:    SHA256,
+	}
+}
+
+// SetHashAlgorithm selects the algorithm new objects and chunks are addressed
+// with. Objects stored with another algorithm stay readable by their CIDs.
+func (cas *CAS) SetHashAlgorithm(alg HashAlgorithm) {
+	cas.hashAlg = alg
 }
 
 // ensureBucket creates the bucket if it doesn't exist
-func (cas *CAS) ensureBucket() error {
-	exists, err := cas.client.BucketExists(context.Background(), cas.bucket)
+func ensureBucket(client *minio.Client, bucket string) error {
+	exists, err := client.BucketExists(context.Background(), bucket)
 	if err != nil {
 		return err
 	}
 
 	if !exists {
-		err = cas.client.MakeBucket(context.Background(), cas.bucket, minio.MakeBucketOptions{})
+		err = client.MakeBucket(context.Background(), bucket, minio.MakeBucketOptions{})
 		if err != nil {
 			return err
 		}
-		log.Printf("Created bucket: %s", cas.bucket)
+		logger.Info("Created bucket", "bucket", bucket)
 	}
 
 	return nil
 }
 
-// Store stores data in CAS and returns the content ID
+// Store streams data into CAS one chunk at a time and returns the object info.
+// Chunks that are already stored are not uploaded again.
 func (cas *CAS) Store(ctx context.Context, reader io.Reader, metadata map[string]string) (*ObjectInfo, error) {
-	// Read all data
-	data, err := io.ReadAll(reader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read data: %w", err)
+	objectHash := cas.hashAlg.New()
+	var chunkCIDs []string
+	var size int64
+
+	var chunk bytes.Buffer
+	for {
+		chunk.Reset()
+		n, err := io.CopyN(&chunk, reader, cas.chunkSize)
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read data: %w", err)
+		}
+		if n == 0 {
+			break
+		}
+
+		objectHash.Write(chunk.Bytes())
+		chunkCID := cas.calculateCID(chunk.Bytes())
+		if err := cas.uploadChunk(ctx, chunkCID, chunk.Bytes()); err != nil {
+			return nil, fmt.Errorf("failed to upload chunk %d: %w", len(chunkCIDs), err)
+		}
+		chunkCIDs = append(chunkCIDs, chunkCID)
+		size += n
+
+		if n < cas.chunkSize {
+			break
+		}
 	}
 
-	// Calculate content ID
-	cid := cas.calculateCID(data)
+	cid := cas.hashAlg.CID(objectHash.Sum(nil))
 
 	// Check if already exists
 	if exists, err := cas.Exists(ctx, cid); err != nil {
@@ -95,29 +143,13 @@ func (cas *CAS) Store(ctx context.Context, reader io.Reader, metadata map[string
 		return cas.GetInfo(ctx, cid)
 	}
 
-	// Chunk the data
-	chunks, merkleRoot, err := cas.chunkData(data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to chunk data: %w", err)
-	}
-
-	// Upload chunks
-	chunkCIDs := make([]string, len(chunks))
-	for i, chunk := range chunks {
-		chunkCID := cas.calculateCID(chunk)
-		chunkCIDs[i] = chunkCID
-
-		if err := cas.uploadChunk(ctx, chunkCID, chunk); err != nil {
-			return nil, fmt.Errorf("failed to upload chunk %d: %w", i, err)
-		}
-	}
-
 	// Create object info
 	objInfo := &ObjectInfo{
 		CID:        cid,
-		Size:       int64(len(data)),
+		Size:       size,
 		Chunks:     chunkCIDs,
-		MerkleRoot: merkleRoot,
+		ChunkSize:  cas.chunkSize,
+		MerkleRoot: merkleRootFromHashes(chunkCIDs),
 		Uploaded:   time.Now(),
 		Metadata:   metadata,
 	}
@@ -127,7 +159,7 @@ func (cas *CAS) Store(ctx context.Context, reader io.Reader, metadata map[string
 		return nil, fmt.Errorf("failed to store object info: %w", err)
 	}
 
-	log.Printf("Stored object %s (%d bytes, %d chunks)", cid, len(data), len(chunks))
+	logger.Debug("Stored object", "cid", cid, "bytes", size, "chunks", len(chunkCIDs))
 	return objInfo, nil
 }
 
@@ -149,8 +181,13 @@ func (cas *CAS) Retrieve(ctx context.Context, cid string) (io.ReadCloser, error)
 		chunks[i] = chunk
 	}
 
-	// Verify Merkle root
-	if !cas.verifyMerkleRoot(chunks, objInfo.MerkleRoot) {
+	// Verify each chunk against its CID, then the CIDs against the Merkle root
+	for i, chunkCID := range objInfo.Chunks {
+		if !verifyCID(chunkCID, chunks[i]) {
+			return nil, fmt.Errorf("chunk %d failed verification", i)
+		}
+	}
+	if merkleRootFromHashes(objInfo.Chunks) != objInfo.MerkleRoot {
 		return nil, fmt.Errorf("Merkle root verification failed")
 	}
 
@@ -161,60 +198,130 @@ func (cas *CAS) Retrieve(ctx context.Context, cid string) (io.ReadCloser, error)
 	}
 
 	// Return as reader
-	return io.NopCloser(strings.NewReader(string(data))), nil
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// RetrieveRange returns length bytes of an object starting at offset. Only the
+// chunks overlapping the range are downloaded, each verified against its CID.
+func (cas *CAS) RetrieveRange(ctx context.Context, info *ObjectInfo, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 || length < 0 || offset+length > info.Size {
+		return nil, fmt.Errorf("range %d+%d outside object of %d bytes", offset, length, info.Size)
+	}
+
+	chunkSize := info.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = cas.chunkSize
+	}
+
+	return &rangeReader{
+		ctx:       ctx,
+		cas:       cas,
+		chunks:    info.Chunks,
+		next:      int(offset / chunkSize),
+		skip:      offset % chunkSize,
+		remaining: length,
+	}, nil
+}
+
+// rangeReader streams a byte range of an object, downloading chunks on demand
+type rangeReader struct {
+	ctx       context.Context
+	cas       *CAS
+	chunks    []string
+	next      int   // index of the next chunk to download
+	skip      int64 // bytes to drop from the start of the next chunk
+	remaining int64
+	current   []byte
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	if r.remaining == 0 {
+		return 0, io.EOF
+	}
+
+	for len(r.current) == 0 {
+		if r.next >= len(r.chunks) {
+			return 0, io.ErrUnexpectedEOF
+		}
+
+		chunkCID := r.chunks[r.next]
+		chunk, err := r.cas.downloadChunk(r.ctx, chunkCID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to download chunk %d: %w", r.next, err)
+		}
+		if !verifyCID(chunkCID, chunk) {
+			return 0, fmt.Errorf("chunk %d failed verification", r.next)
+		}
+		if r.skip > int64(len(chunk)) {
+			return 0, io.ErrUnexpectedEOF
+		}
+
+		r.current = chunk[r.skip:]
+		r.skip = 0
+		r.next++
+	}
+
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n := copy(p, r.current)
+	r.current = r.current[n:]
+	r.remaining -= int64(n)
+	return n, nil
+}
+
+func (r *rangeReader) Close() error {
+	r.current = nil
+	return nil
 }
 
 // Exists checks if an object exists in CAS
 func (cas *CAS) Exists(ctx context.Context, cid string) (bool, error) {
-	_, err := cas.client.StatObject(ctx, cas.bucket, cas.getObjectKey(cid), minio.StatObjectOptions{})
+	key, err := cas.getMetadataKey(cid)
 	if err != nil {
-		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
-			return false, nil
-		}
 		return false, err
 	}
-	return true, nil
+	return cas.backend.Exists(ctx, key)
 }
 
 // GetInfo gets object information
 func (cas *CAS) GetInfo(ctx context.Context, cid string) (*ObjectInfo, error) {
-	obj, err := cas.client.GetObject(ctx, cas.bucket, cas.getMetadataKey(cid), minio.GetObjectOptions{})
+	key, err := cas.getMetadataKey(cid)
 	if err != nil {
 		return nil, err
 	}
-	defer obj.Close()
-
-	var objInfo ObjectInfo
-	data, err := io.ReadAll(obj)
+	obj, err := cas.backend.Get(ctx, key)
 	if err != nil {
 		return nil, err
 	}
+	defer obj.Close()
 
-	// Simple JSON deserialization (in production, use proper serialization)
-	// This is simplified - in production, use protobuf or similar
-	return &objInfo, fmt.Errorf("metadata parsing not implemented")
+	var objInfo ObjectInfo
+	if err := json.NewDecoder(obj).Decode(&objInfo); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata for %s: %w", cid, err)
+	}
+	return &objInfo, nil
 }
 
-// Delete removes an object from CAS
+// Delete removes an object from CAS. Chunks are content-addressed and may be
+// shared with other objects, so only the object's metadata is removed.
 func (cas *CAS) Delete(ctx context.Context, cid string) error {
-	objInfo, err := cas.GetInfo(ctx, cid)
-	if err != nil {
+	if exists, err := cas.Exists(ctx, cid); err != nil {
 		return err
-	}
-
-	// Delete all chunks
-	for _, chunkCID := range objInfo.Chunks {
-		if err := cas.client.RemoveObject(ctx, cas.bucket, cas.getChunkKey(chunkCID), minio.RemoveObjectOptions{}); err != nil {
-			log.Printf("Failed to delete chunk %s: %v", chunkCID, err)
-		}
+	} else if !exists {
+		return fmt.Errorf("object %s not found", cid)
 	}
 
 	// Delete metadata
-	if err := cas.client.RemoveObject(ctx, cas.bucket, cas.getMetadataKey(cid), minio.RemoveObjectOptions{}); err != nil {
+	key, err := cas.getMetadataKey(cid)
+	if err != nil {
+		return err
+	}
+	if err := cas.backend.Remove(ctx, key); err != nil {
 		return err
 	}
 
-	log.Printf("Deleted object %s", cid)
+	logger.Debug("Deleted object", "cid", cid)
 	return nil
 }
 
@@ -225,44 +332,19 @@ func (cas *CAS) List(ctx context.Context, prefix string) ([]*ObjectInfo, error)
 	return nil, fmt.Errorf("list operation not fully implemented")
 }
 
-// calculateCID calculates the content ID for data
+// calculateCID calculates the content ID for data with the configured algorithm
 func (cas *CAS) calculateCID(data []byte) string {
-	hash := sha256.Sum256(data)
-	return hex.EncodeToString(hash[:])
-}
-
-// chunkData splits data into chunks and computes Merkle root
-func (cas *CAS) chunkData(data []byte) ([][]byte, string, error) {
-	var chunks [][]byte
-	size := int64(len(data))
-
-	for offset := int64(0); offset < size; offset += cas.chunkSize {
-		end := offset + cas.chunkSize
-		if end > size {
-			end = size
-		}
-		chunk := data[offset:end]
-		chunks = append(chunks, chunk)
-	}
-
-	// Compute Merkle root
-	merkleRoot := cas.computeMerkleRoot(chunks)
-
-	return chunks, merkleRoot, nil
+	h := cas.hashAlg.New()
+	h.Write(data)
+	return cas.hashAlg.CID(h.Sum(nil))
 }
 
-// computeMerkleRoot computes the Merkle root of chunks
-func (cas *CAS) computeMerkleRoot(chunks [][]byte) string {
-	if len(chunks) == 0 {
+// merkleRootFromHashes computes the Merkle root over chunk CIDs
+func merkleRootFromHashes(hashes []string) string {
+	if len(hashes) == 0 {
 		return ""
 	}
 
-	// Convert chunks to hashes
-	hashes := make([]string, len(chunks))
-	for i, chunk := range chunks {
-		hashes[i] = cas.calculateCID(chunk)
-	}
-
 	// Build Merkle tree
 	for len(hashes) > 1 {
 		var nextLevel []string
@@ -281,25 +363,28 @@ func (cas *CAS) computeMerkleRoot(chunks [][]byte) string {
 	return hashes[0]
 }
 
-// verifyMerkleRoot verifies chunks against Merkle root
-func (cas *CAS) verifyMerkleRoot(chunks [][]byte, expectedRoot string) bool {
-	computedRoot := cas.computeMerkleRoot(chunks)
-	return computedRoot == expectedRoot
-}
-
-// uploadChunk uploads a chunk to storage
+// uploadChunk uploads a chunk to storage unless an identical chunk is already stored
 func (cas *CAS) uploadChunk(ctx context.Context, cid string, data []byte) error {
-	key := cas.getChunkKey(cid)
-	reader := strings.NewReader(string(data))
+	key, err := cas.getChunkKey(cid)
+	if err != nil {
+		return err
+	}
+	if exists, err := cas.backend.Exists(ctx, key); err != nil {
+		return err
+	} else if exists {
+		return nil
+	}
 
-	_, err := cas.client.PutObject(ctx, cas.bucket, key, reader, int64(len(data)), minio.PutObjectOptions{})
-	return err
+	return cas.backend.Put(ctx, key, bytes.NewReader(data), int64(len(data)))
 }
 
 // downloadChunk downloads a chunk from storage
 func (cas *CAS) downloadChunk(ctx context.Context, cid string) ([]byte, error) {
-	key := cas.getChunkKey(cid)
-	obj, err := cas.client.GetObject(ctx, cas.bucket, key, minio.GetObjectOptions{})
+	key, err := cas.getChunkKey(cid)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := cas.backend.Get(ctx, key)
 	if err != nil {
 		return nil, err
 	}
@@ -308,27 +393,26 @@ func (cas *CAS) downloadChunk(ctx context.Context, cid string) ([]byte, error) {
 	return io.ReadAll(obj)
 }
 
-// storeObjectInfo stores object metadata
+// storeObjectInfo stores object metadata as JSON
 func (cas *CAS) storeObjectInfo(ctx context.Context, info *ObjectInfo) error {
-	// Simplified - in production, serialize properly
-	data := []byte(fmt.Sprintf("CID: %s, Size: %d", info.CID, info.Size))
-	key := cas.getMetadataKey(info.CID)
-
-	_, err := cas.client.PutObject(ctx, cas.bucket, key, strings.NewReader(string(data)), int64(len(data)), minio.PutObjectOptions{})
-	return err
-}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
 
-// getObjectKey returns the S3 key for an object
-func (cas *CAS) getObjectKey(cid string) string {
-	return filepath.Join("objects", cid[:2], cid[2:4], cid)
+	key, err := cas.getMetadataKey(info.CID)
+	if err != nil {
+		return err
+	}
+	return cas.backend.Put(ctx, key, bytes.NewReader(data), int64(len(data)))
 }
 
 // getChunkKey returns the S3 key for a chunk
-func (cas *CAS) getChunkKey(cid string) string {
-	return filepath.Join("chunks", cid[:2], cid[2:4], cid)
+func (cas *CAS) getChunkKey(cid string) (string, error) {
+	return storageKey("chunks", cid, "")
 }
 
 // getMetadataKey returns the S3 key for metadata
-func (cas *CAS) getMetadataKey(cid string) string {
-	return filepath.Join("metadata", cid[:2], cid[2:4], cid+".json")
+func (cas *CAS) getMetadataKey(cid string) (string, error) {
+	return storageKey("metadata", cid, ".json")
 }
@@ -0,0 +1,111 @@
+package cas
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+
+	"lukechampine.com/blake3"
+)
+
+// HashAlgorithm names the hash function a CID was computed with. CIDs carry
+// it as a prefix, like "blake3:<hex>"; a bare hex CID is a legacy SHA-256 one.
+type HashAlgorithm string
+
+const (
+	SHA256 HashAlgorithm = "sha256"
+	BLAKE3 HashAlgorithm = "blake3"
+)
+
+// ParseHashAlgorithm validates an algorithm name; empty selects SHA-256
+func ParseHashAlgorithm(name string) (HashAlgorithm, error) {
+	switch alg := HashAlgorithm(strings.ToLower(name)); alg {
+	case "":
+		return SHA256, nil
+	case SHA256, BLAKE3:
+		return alg, nil
+	default:
+		return "", fmt.Errorf("unknown hash algorithm %q", name)
+	}
+}
+
+// New returns a streaming hasher for the algorithm
+func (a HashAlgorithm) New() hash.Hash {
+	if a == BLAKE3 {
+		return blake3.New(32, nil)
+	}
+	return sha256.New()
+}
+
+// CID formats a digest produced by the algorithm as a prefixed CID
+func (a HashAlgorithm) CID(digest []byte) string {
+	return string(a) + ":" + hex.EncodeToString(digest)
+}
+
+// ParseCID splits a CID into its algorithm and hex digest
+func ParseCID(cid string) (HashAlgorithm, string, error) {
+	alg, digest := SHA256, cid
+	if prefix, rest, ok := strings.Cut(cid, ":"); ok {
+		var err error
+		if alg, err = ParseHashAlgorithm(prefix); err != nil || prefix == "" {
+			return "", "", fmt.Errorf("invalid CID %q: unknown hash algorithm", cid)
+		}
+		digest = rest
+	}
+	if len(digest) != 2*sha256.Size {
+		return "", "", fmt.Errorf("invalid CID %q: expected a %d character digest", cid, 2*sha256.Size)
+	}
+	if _, err := hex.DecodeString(digest); err != nil {
+		return "", "", fmt.Errorf("invalid CID %q: digest is not hex", cid)
+	}
+	return alg, strings.ToLower(digest), nil
+}
+
+// verifyCID reports whether data hashes to cid with the algorithm cid names
+func verifyCID(cid string, data []byte) bool {
+	alg, digest, err := ParseCID(cid)
+	if err != nil {
+		return false
+	}
+	h := alg.New()
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)) == digest
+}
+
+// storageKey lays out a CID under dir. SHA-256 CIDs keep the bare digest
+// layout used before CIDs were prefixed, so legacy objects resolve by either
+// form; other algorithms get their own subtree.
+func storageKey(dir, cid, suffix string) (string, error) {
+	alg, digest, err := ParseCID(cid)
+	if err != nil {
+		return "", err
+	}
+	if alg != SHA256 {
+		dir += "/" + string(alg)
+	}
+	return dir + "/" + digest[:2] + "/" + digest[2:4] + "/" + digest + suffix, nil
+}
+
+// chunkCIDFromKey returns the CID of the chunk stored under key, or false if
+// key is not a chunk key
+func chunkCIDFromKey(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, "chunks/")
+	if !ok {
+		return "", false
+	}
+	parts := strings.Split(rest, "/")
+	cid := parts[len(parts)-1]
+	switch len(parts) {
+	case 3:
+	case 4:
+		cid = parts[0] + ":" + cid
+	default:
+		return "", false
+	}
+	if _, _, err := ParseCID(cid); err != nil {
+		return "", false
+	}
+	return cid, true
+}
@@ -0,0 +1,76 @@
+package cas
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreAndRetrieveWithBLAKE3(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemoryBackend()
+	c := NewCASWithBackend(backend, 4)
+	c.SetHashAlgorithm(BLAKE3)
+
+	info, err := c.Store(ctx, strings.NewReader("hashed with blake3"), nil)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(info.CID, "blake3:"), info.CID)
+	for _, chunk := range info.Chunks {
+		assert.True(t, strings.HasPrefix(chunk, "blake3:"), chunk)
+	}
+
+	rc, err := c.Retrieve(ctx, info.CID)
+	require.NoError(t, err)
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "hashed with blake3", string(data))
+
+	// BLAKE3 objects live in their own subtree
+	keys, err := backend.List(ctx, "metadata/blake3/")
+	require.NoError(t, err)
+	assert.Len(t, keys, 1)
+}
+
+func TestRetrieveLegacySHA256Object(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemoryBackend()
+	c := NewCASWithBackend(backend, 0)
+	c.SetHashAlgorithm(BLAKE3)
+
+	// Lay out an object the way it was stored before CIDs were prefixed
+	content := []byte("stored before algorithm agility")
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+	chunkKey := "chunks/" + digest[:2] + "/" + digest[2:4] + "/" + digest
+	require.NoError(t, backend.Put(ctx, chunkKey, bytes.NewReader(content), int64(len(content))))
+	meta, err := json.Marshal(&ObjectInfo{
+		CID:        digest,
+		Size:       int64(len(content)),
+		Chunks:     []string{digest},
+		MerkleRoot: merkleRootFromHashes([]string{digest}),
+	})
+	require.NoError(t, err)
+	metaKey := "metadata/" + digest[:2] + "/" + digest[2:4] + "/" + digest + ".json"
+	require.NoError(t, backend.Put(ctx, metaKey, bytes.NewReader(meta), int64(len(meta))))
+
+	for _, cid := range []string{digest, "sha256:" + digest} {
+		rc, err := c.Retrieve(ctx, cid)
+		require.NoError(t, err, cid)
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		assert.Equal(t, content, data, cid)
+	}
+
+	_, err = c.Retrieve(ctx, "md5:"+digest)
+	assert.Error(t, err)
+	_, err = c.Retrieve(ctx, "ab")
+	assert.Error(t, err)
+}
@@ -0,0 +1,229 @@
+package cas
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rechain/rechain/pkg/hashring"
+)
+
+// DefaultReplicationFactor is the number of nodes each key is written to
+const DefaultReplicationFactor = 2
+
+// repairTimeout bounds a background read-repair
+const repairTimeout = 30 * time.Second
+
+// RingBackend shards keys across several backend nodes with a consistent
+// hashing ring. Each key is written to the first replicas nodes the ring
+// returns for it, and reads try those nodes in order. Replicas that a read
+// finds missing or corrupt are repaired in the background.
+type RingBackend struct {
+	mu       sync.RWMutex
+	ring     *hashring.Ring
+	nodes    map[string]Backend
+	replicas int
+
+	repairs sync.WaitGroup // in-flight read-repairs
+}
+
+// NewRingBackend creates a router writing each key to replicas nodes. A
+// replicas of zero uses DefaultReplicationFactor.
+func NewRingBackend(replicas int) *RingBackend {
+	if replicas <= 0 {
+		replicas = DefaultReplicationFactor
+	}
+	return &RingBackend{
+		ring:     hashring.New(0),
+		nodes:    make(map[string]Backend),
+		replicas: replicas,
+	}
+}
+
+// AddNode adds a backend node under name. Keys the ring now places on it are
+// not copied over; reads of them fall back to the remaining replicas.
+func (b *RingBackend) AddNode(name string, backend Backend) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nodes[name] = backend
+	b.ring.Add(name)
+}
+
+// RemoveNode takes the node name out of the ring
+func (b *RingBackend) RemoveNode(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.nodes, name)
+	b.ring.Remove(name)
+}
+
+// replicasFor returns the nodes holding key, in the order reads try them
+func (b *RingBackend) replicasFor(key string) ([]string, []Backend) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	names := b.ring.Lookup(key, b.replicas)
+	backends := make([]Backend, len(names))
+	for i, name := range names {
+		backends[i] = b.nodes[name]
+	}
+	return names, backends
+}
+
+// Put writes key to every replica. It succeeds if at least one replica took
+// the write, so a single unavailable node doesn't fail the store.
+func (b *RingBackend) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	names, backends := b.replicasFor(key)
+	if len(backends) == 0 {
+		return fmt.Errorf("no nodes to store %s on", key)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, backend := range backends {
+		if err := backend.Put(ctx, key, bytes.NewReader(data), int64(len(data))); err != nil {
+			logger.Warn("Failed to write replica", "key", key, "node", names[i], "error", err)
+			errs = append(errs, fmt.Errorf("node %s: %w", names[i], err))
+		}
+	}
+	if len(errs) == len(backends) {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// Get reads key from the first replica that has an intact copy. Chunks are
+// verified against the CID in their key. Replicas that were missing the key
+// or held a corrupt chunk are then repaired with the good copy in the
+// background, as are later replicas found not to have it.
+func (b *RingBackend) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	names, backends := b.replicasFor(key)
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("no nodes to read %s from", key)
+	}
+	cid, isChunk := chunkCIDFromKey(key)
+
+	var errs []error
+	for i, backend := range backends {
+		data, err := readAll(ctx, backend, key)
+		if err == nil && isChunk && !verifyCID(cid, data) {
+			err = fmt.Errorf("chunk failed verification")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("node %s: %w", names[i], err))
+			continue
+		}
+
+		b.repairs.Add(1)
+		go b.repair(key, data, names[:i:i], backends[:i:i], names[i+1:], backends[i+1:])
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+// repair writes data to the replicas known to be bad, and to the unchecked
+// replicas that don't have key
+func (b *RingBackend) repair(key string, data []byte, badNames []string, bad []Backend, uncheckedNames []string, unchecked []Backend) {
+	defer b.repairs.Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), repairTimeout)
+	defer cancel()
+
+	for i, backend := range unchecked {
+		exists, err := backend.Exists(ctx, key)
+		if err != nil || exists {
+			continue
+		}
+		badNames = append(badNames, uncheckedNames[i])
+		bad = append(bad, backend)
+	}
+
+	for i, backend := range bad {
+		if err := backend.Put(ctx, key, bytes.NewReader(data), int64(len(data))); err != nil {
+			logger.Warn("Read-repair failed", "key", key, "node", badNames[i], "error", err)
+			continue
+		}
+		logger.Info("Repaired replica", "key", key, "node", badNames[i])
+	}
+}
+
+// readAll reads key from backend in full
+func readAll(ctx context.Context, backend Backend, key string) ([]byte, error) {
+	rc, err := backend.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// Exists reports whether any replica has key
+func (b *RingBackend) Exists(ctx context.Context, key string) (bool, error) {
+	_, backends := b.replicasFor(key)
+
+	var firstErr error
+	for _, backend := range backends {
+		exists, err := backend.Exists(ctx, key)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if exists {
+			return true, nil
+		}
+	}
+	return false, firstErr
+}
+
+// Remove deletes key from every replica
+func (b *RingBackend) Remove(ctx context.Context, key string) error {
+	names, backends := b.replicasFor(key)
+
+	var errs []error
+	for i, backend := range backends {
+		if err := backend.Remove(ctx, key); err != nil {
+			errs = append(errs, fmt.Errorf("node %s: %w", names[i], err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// List returns the keys with the given prefix across every node, in sorted order
+func (b *RingBackend) List(ctx context.Context, prefix string) ([]string, error) {
+	b.mu.RLock()
+	backends := make([]Backend, 0, len(b.nodes))
+	for _, backend := range b.nodes {
+		backends = append(backends, backend)
+	}
+	b.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	keys := make([]string, 0)
+	for _, backend := range backends {
+		nodeKeys, err := backend.List(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range nodeKeys {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -0,0 +1,98 @@
+package cas
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRingBackendReadsReplicasAfterNodeRemoval(t *testing.T) {
+	ctx := context.Background()
+	router := NewRingBackend(2)
+	nodes := make(map[string]*MemoryBackend)
+	for i := 0; i < 3; i++ {
+		name := fmt.Sprintf("cas-%d", i)
+		nodes[name] = NewMemoryBackend()
+		router.AddNode(name, nodes[name])
+	}
+
+	c := NewCASWithBackend(router, 4)
+	content := "sharded across the ring"
+	info, err := c.Store(ctx, strings.NewReader(content), nil)
+	require.NoError(t, err)
+
+	// Every chunk lives on exactly two nodes
+	for _, chunk := range info.Chunks {
+		key, err := c.getChunkKey(chunk)
+		require.NoError(t, err)
+		copies := 0
+		for _, node := range nodes {
+			exists, err := node.Exists(ctx, key)
+			require.NoError(t, err)
+			if exists {
+				copies++
+			}
+		}
+		assert.Equal(t, 2, copies, chunk)
+	}
+
+	// Losing any one node leaves a replica of everything
+	for name := range nodes {
+		router.RemoveNode(name)
+
+		rc, err := c.Retrieve(ctx, info.CID)
+		require.NoError(t, err, "without %s", name)
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data), "without %s", name)
+
+		router.AddNode(name, nodes[name])
+	}
+
+	keys, err := router.List(ctx, "chunks/")
+	require.NoError(t, err)
+	assert.Len(t, keys, len(info.Chunks))
+}
+
+func TestRingBackendReadRepair(t *testing.T) {
+	ctx := context.Background()
+	router := NewRingBackend(2)
+	nodes := []*MemoryBackend{NewMemoryBackend(), NewMemoryBackend()}
+	router.AddNode("cas-0", nodes[0])
+	router.AddNode("cas-1", nodes[1])
+
+	c := NewCASWithBackend(router, 4)
+	info, err := c.Store(ctx, strings.NewReader("drifting replicas"), nil)
+	require.NoError(t, err)
+
+	missingKey, err := c.getChunkKey(info.Chunks[0])
+	require.NoError(t, err)
+	corruptKey, err := c.getChunkKey(info.Chunks[1])
+	require.NoError(t, err)
+
+	// One replica lost a chunk and the other holds a corrupt copy of another
+	require.NoError(t, nodes[0].Remove(ctx, missingKey))
+	require.NoError(t, nodes[1].Put(ctx, corruptKey, strings.NewReader("bit rot"), 7))
+
+	rc, err := c.Retrieve(ctx, info.CID)
+	require.NoError(t, err)
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "drifting replicas", string(data))
+
+	router.repairs.Wait()
+
+	exists, err := nodes[0].Exists(ctx, missingKey)
+	require.NoError(t, err)
+	assert.True(t, exists, "missing replica was not repaired")
+
+	repaired, err := readAll(ctx, nodes[1], corruptKey)
+	require.NoError(t, err)
+	assert.True(t, verifyCID(info.Chunks[1], repaired), "corrupt replica was not repaired")
+}
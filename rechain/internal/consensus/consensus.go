@@ -1,67 +1,121 @@
 package consensus
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"strconv"
 	"sync"
 	"time"
 
-	"github.com/rechain/rechain/internal/gcl"
+	"github.com/rechain/rechain/internal/security"
 	"github.com/rechain/rechain/internal/storage"
+	"github.com/rechain/rechain/pkg/logging"
 )
 
+var logger = logging.Component("consensus")
+
 // Consensus implements the BFT consensus algorithm (Tendermint-style)
 type Consensus struct {
 	store     storage.Store
-	p2p       *gcl.P2PServer
+	transport Transport
 	config    *Config
 	proposals chan *Proposal
 	blocks    chan *Block
+	outbox    chan outboundMessage
+	events    chan Event
 	quit      chan struct{}
 
-	height    uint64
-	round     int32
-	step      Step
-	locked    *Block
-	validated *Block
+	height      uint64
+	round       int32
+	step        Step
+	proposal    *Block // Block proposed for the current round
+	locked      *Block // Block we precommitted, kept across rounds of this height
+	lockedRound int32
+	validated   *Block
+
+	genesisHash []byte
+
+	// Votes by validator ID for each (height, round, type)
+	votes map[voteKey]map[string]*Vote
 
-	voted map[uint32]bool // Track votes in current round
-	votes  []*Vote        // Collected votes for current round/step
+	// Propose timeouts without a proposal, by proposer
+	missedProposals map[string]uint64
+
+	// Conflicting votes detected from Byzantine validators
+	evidence    []*Evidence
+	auditLogger *security.AuditLogger
 
 	votingMutex sync.Mutex
 
-	// Validator set (simplified - all connected peers are validators)
-	validators []string
-	validatorIndex int
+	// Validator set
+	validators *validatorSet
 
-	// Timing
+	// Timing. Propose, prevote and precommit timeouts double with every round.
+	timeoutPropose   time.Duration
 	timeoutPrevote   time.Duration
 	timeoutPrecommit time.Duration
 	timeoutCommit    time.Duration
 
-	// Mempool for transactions
-	mempool []*Transaction
+	// Mempool for transactions, in arrival order, and the hashes and
+	// sender/ID pairs it holds
+	mempool        []*Transaction
+	mempoolHashes  map[string]struct{}
+	mempoolIDs     map[string]struct{}
+	maxMempoolSize int
+
+	// Limits on the transactions taken into a proposed block
+	maxBlockTxs   int
+	maxBlockBytes int
 }
 
+// voteKey identifies the set of votes cast in one step of one round
+type voteKey struct {
+	height   uint64
+	round    int32
+	voteType VoteType
+}
+
+// maxTimeoutDoublings caps the round timeout backoff at 64 times the base timeout
+const maxTimeoutDoublings = 6
+
 // Step represents the current step in the consensus round
 type Step int
 
 const (
-	Propose Step = iota
-	Prevote
-	Precommit
-	Commit
+	StepPropose Step = iota
+	StepPrevote
+	StepPrecommit
+	StepCommit
 )
 
 // Config holds consensus configuration
 type Config struct {
-	NodeID        string
-	BlockInterval time.Duration
-	Timeout       time.Duration
+	NodeID         string
+	BlockInterval  time.Duration
+	Timeout        time.Duration
+	Validators     []Validator
+	AuditLogger    *security.AuditLogger // receives equivocation events; defaults to the standard logger
+	Signer         *security.KeyManager  // signs this node's votes; required with a transport and other validators
+	MaxMempoolSize int                   // pending transactions held at most; defaults to DefaultMaxMempoolSize
+	MaxBlockTxs    int                   // transactions per block at most; defaults to DefaultMaxBlockTxs
+	MaxBlockBytes  int                   // encoded transaction bytes per block at most; defaults to DefaultMaxBlockBytes
+	Genesis        *Genesis              // chain origin; defaults to DefaultChainID, and its validators to Validators
+
+	// Snapshots of StateProvider are taken every SnapshotInterval heights, and
+	// block bodies older than the last PruneRetention blocks are pruned. Zero
+	// disables either.
+	SnapshotInterval uint64
+	PruneRetention   uint64
+	StateProvider    StateProvider
+
+	// TxRegistry restricts transactions to its registered types and applies
+	// them as their blocks commit. Nil accepts any type and applies nothing.
+	TxRegistry *TxRegistry
 }
 
 // Transaction represents a transaction to be included in a block
@@ -70,29 +124,95 @@ type Transaction struct {
 	Type      string
 	Payload   []byte
 	Timestamp time.Time
-	Sender    string
-	Signature []byte
+	Sender    string // security.PublicKeyID of PublicKey
+	Signature []byte // by PublicKey over security.SubmittedTxSigningBytes
+	PublicKey []byte
+	Priority  int // higher priority transactions are proposed first
+}
+
+// NewConsensus creates a new single-validator consensus instance
+func NewConsensus(store storage.Store, transport Transport) (*Consensus, error) {
+	return NewConsensusWithConfig(store, transport, &Config{
+		NodeID:        "node-1",
+		BlockInterval: 1 * time.Second,
+		Timeout:       5 * time.Second,
+		Validators:    []Validator{{ID: "node-1", VotingPower: 1}},
+	})
 }
 
-// NewConsensus creates a new consensus instance
-func NewConsensus(store storage.Store, p2p *gcl.P2PServer) (*Consensus, error) {
+// NewConsensusWithConfig creates a consensus instance for the given validator set,
+// which must include cfg.NodeID. A nil transport runs the node in single-node mode
+// without networking.
+func NewConsensusWithConfig(store storage.Store, transport Transport, cfg *Config) (*Consensus, error) {
+	validators, err := newValidatorSet(cfg.Validators)
+	if err != nil {
+		return nil, fmt.Errorf("invalid validator set: %w", err)
+	}
+	if !validators.has(cfg.NodeID) {
+		return nil, fmt.Errorf("local node %q is not in the validator set", cfg.NodeID)
+	}
+	if transport != nil && len(validators.validators) > 1 {
+		if err := checkSigningKeys(validators, cfg.NodeID, cfg.Signer); err != nil {
+			return nil, err
+		}
+	}
+
 	c := &Consensus{
-		store:     store,
-		p2p:       p2p,
-		proposals: make(chan *Proposal, 100),
-		blocks:    make(chan *Block, 100),
-		quit:      make(chan struct{}),
-		voted:     make(map[uint32]bool),
-		config: &Config{
-			BlockInterval: 1 * time.Second,
-			Timeout:       5 * time.Second,
-		},
+		store:            store,
+		transport:        transport,
+		proposals:        make(chan *Proposal, 100),
+		blocks:           make(chan *Block, 100),
+		outbox:           make(chan outboundMessage, 256),
+		events:           make(chan Event, 256),
+		quit:             make(chan struct{}),
+		votes:            make(map[voteKey]map[string]*Vote),
+		missedProposals:  make(map[string]uint64),
+		config:           cfg,
+		lockedRound:      -1,
+		timeoutPropose:   cfg.Timeout,
 		timeoutPrevote:   3 * time.Second,
 		timeoutPrecommit: 3 * time.Second,
-		timeoutCommit:    1 * time.Second,
-		validators:       []string{"node-1"}, // Simplified
-		validatorIndex:   0,
+		timeoutCommit:    cfg.BlockInterval,
+		validators:       validators,
 		mempool:          make([]*Transaction, 0),
+		mempoolHashes:    make(map[string]struct{}),
+		mempoolIDs:       make(map[string]struct{}),
+		maxMempoolSize:   cfg.MaxMempoolSize,
+		maxBlockTxs:      cfg.MaxBlockTxs,
+		maxBlockBytes:    cfg.MaxBlockBytes,
+		auditLogger:      cfg.AuditLogger,
+	}
+	if c.auditLogger == nil {
+		c.auditLogger = security.NewAuditLogger(true)
+	}
+	if c.timeoutPropose <= 0 {
+		c.timeoutPropose = 3 * time.Second
+	}
+	if c.maxMempoolSize <= 0 {
+		c.maxMempoolSize = DefaultMaxMempoolSize
+	}
+	if c.maxBlockTxs <= 0 {
+		c.maxBlockTxs = DefaultMaxBlockTxs
+	}
+	if c.maxBlockBytes <= 0 {
+		c.maxBlockBytes = DefaultMaxBlockBytes
+	}
+
+	genesis := defaultGenesis(cfg.Validators)
+	if cfg.Genesis != nil {
+		genesis = &Genesis{ChainID: cfg.Genesis.ChainID, Time: cfg.Genesis.Time, Validators: cfg.Genesis.Validators}
+		if len(genesis.Validators) == 0 {
+			genesis.Validators = cfg.Validators
+		}
+	}
+	if err := c.initChain(genesis); err != nil {
+		return nil, err
+	}
+
+	if transport != nil {
+		transport.HandleMessage(ProposalMsg, c.receiveProposal)
+		transport.HandleMessage(VoteMsg, c.receiveVote)
+		go c.sendLoop()
 	}
 
 	// Start the consensus loop
@@ -101,9 +221,13 @@ func NewConsensus(store storage.Store, p2p *gcl.P2PServer) (*Consensus, error) {
 	return c, nil
 }
 
-// Start starts the consensus process
+// Start starts the consensus process at the next height
 func (c *Consensus) Start() error {
-	log.Println("Consensus engine started")
+	c.votingMutex.Lock()
+	defer c.votingMutex.Unlock()
+
+	c.startNewHeight()
+	logger.Info("Consensus engine started")
 	return nil
 }
 
@@ -113,44 +237,30 @@ func (c *Consensus) Stop() error {
 	return nil
 }
 
-// AddTransaction adds a transaction to the mempool
-func (c *Consensus) AddTransaction(tx *Transaction) {
-	c.votingMutex.Lock()
-	defer c.votingMutex.Unlock()
-	c.mempool = append(c.mempool, tx)
-	log.Printf("Added transaction %s to mempool", tx.ID)
-}
-
-// GetMempool returns current transactions in mempool
-func (c *Consensus) GetMempool() []*Transaction {
+// Height returns the height currently being decided
+func (c *Consensus) Height() uint64 {
 	c.votingMutex.Lock()
 	defer c.votingMutex.Unlock()
-	return append([]*Transaction{}, c.mempool...)
+	return c.height
 }
 
-// Propose proposes a new block
+// Propose submits a block proposal for the current round
 func (c *Consensus) Propose(block *Block) error {
 	c.proposals <- &Proposal{
-		Block: block,
-		Round: c.round,
+		Block:      block,
+		Round:      block.Round,
+		ProposerID: c.config.NodeID,
 	}
 	return nil
 }
 
 // run is the main consensus loop
 func (c *Consensus) run() {
-	ticker := time.NewTicker(c.config.BlockInterval)
-	defer ticker.Stop()
-
 	for {
 		select {
 		case <-c.quit:
 			return
 
-		case <-ticker.C:
-			// Start new height
-			c.startNewHeight()
-
 		case prop := <-c.proposals:
 			c.handleProposal(prop)
 
@@ -160,71 +270,104 @@ func (c *Consensus) run() {
 	}
 }
 
-// startNewHeight starts a new consensus height
+// startNewHeight starts a new consensus height. Callers must hold votingMutex.
 func (c *Consensus) startNewHeight() {
-	c.votingMutex.Lock()
-	defer c.votingMutex.Unlock()
-
 	c.height++
 	c.round = 0
-	c.step = Propose
+	c.proposal = nil
 	c.locked = nil
+	c.lockedRound = -1
 	c.validated = nil
-	c.voted = make(map[uint32]bool)
-	c.votes = nil
 
-	log.Printf("Starting new height: %d", c.height)
+	// Drop votes for heights that are already decided
+	for key := range c.votes {
+		if key.height < c.height {
+			delete(c.votes, key)
+		}
+	}
+
+	logger.Info("Starting new height", "height", c.height)
+	c.startRound()
+}
+
+// startRound enters the propose step of the current round. Callers must hold votingMutex.
+func (c *Consensus) startRound() {
+	c.step = StepPropose
 
 	// If we're the proposer for this round, propose a new block
 	if c.isProposer() {
-		block := c.createProposal()
-		c.Propose(block)
+		proposal := &Proposal{Block: c.createProposal(), Round: c.round, ProposerID: c.config.NodeID}
+		if err := c.signProposal(proposal); err != nil {
+			logger.Error("Failed to sign own proposal", "err", err)
+		} else {
+			c.broadcastProposal(proposal)
+			c.processProposal(proposal)
+		}
 	}
 
 	// Start timeout for propose step
-	go c.startTimeout(Propose, c.timeoutPrevote)
+	go c.startTimeout(c.height, c.round, StepPropose, c.roundTimeout(c.timeoutPropose))
+}
+
+// startNextRound moves to the next round of the current height after a round
+// failed to decide a block, handing the proposal to the next proposer.
+// Callers must hold votingMutex.
+func (c *Consensus) startNextRound(round int32) {
+	c.round = round
+	c.proposal = nil
+	logger.Warn("Starting new round", "height", c.height, "round", c.round, "proposer", c.proposer(c.height, c.round))
+	c.startRound()
+}
+
+// roundTimeout scales a step timeout for the current round, doubling it each
+// round up to maxTimeoutDoublings so a slow network eventually gets enough time
+func (c *Consensus) roundTimeout(base time.Duration) time.Duration {
+	doublings := c.round
+	if doublings > maxTimeoutDoublings {
+		doublings = maxTimeoutDoublings
+	}
+	return base << uint(doublings)
+}
+
+// proposer returns the validator that proposes at the given height and round
+func (c *Consensus) proposer(height uint64, round int32) string {
+	return c.validators.proposer(height, round)
 }
 
 // isProposer checks if the current node is the proposer for the current round
 func (c *Consensus) isProposer() bool {
-	// Simple round-robin proposer selection
-	proposerIndex := (int(c.height) + int(c.round)) % len(c.validators)
-	return c.validators[proposerIndex] == c.config.NodeID
+	return c.proposer(c.height, c.round) == c.config.NodeID
+}
+
+// isValidator reports whether id belongs to the validator set
+func (c *Consensus) isValidator(id string) bool {
+	return c.validators.has(id)
 }
 
-// createProposal creates a new block proposal
+// createProposal creates a new block proposal. Callers must hold votingMutex.
 func (c *Consensus) createProposal() *Block {
-	// Get transactions from mempool
-	txs := c.GetMempool()
+	// Highest priority transactions from the mempool, within the block limits
+	txs := c.selectTransactions()
 
 	// Create a new block with transactions
 	block := &Block{
 		Height:    c.height,
 		Round:     c.round,
 		Timestamp: time.Now(),
-		Txs:       make([][]byte, len(txs)),
+		Txs:       txs,
+		TxRoot:    txMerkleRoot(txs),
 		LastHash:  c.getLastBlockHash(),
 		StateHash: c.getStateHash(),
 	}
 
-	// Serialize transactions
-	for i, tx := range txs {
-		txBytes, _ := json.Marshal(tx)
-		block.Txs[i] = txBytes
-	}
-
-	log.Printf("Created proposal for height %d with %d transactions", c.height, len(txs))
+	logger.Info("Created proposal", "height", c.height, "txs", len(txs))
 	return block
 }
 
 // getLastBlockHash returns the hash of the last committed block
 func (c *Consensus) getLastBlockHash() []byte {
-	// Simplified - in production, get from committed blocks
-	if c.height == 1 {
-		return make([]byte, 32) // Genesis block hash
-	}
-	// Get from storage
-	key := []byte(fmt.Sprintf("block/%d", c.height-1))
+	// Height 1 builds on the genesis block stored at height 0
+	key := []byte(fmt.Sprintf("block-hash/%d", c.height-1))
 	hash, _ := c.store.Get(context.Background(), key)
 	if hash == nil {
 		return make([]byte, 32)
@@ -239,63 +382,118 @@ func (c *Consensus) getStateHash() []byte {
 	return hash[:]
 }
 
-// startTimeout starts a timeout for the given step
-func (c *Consensus) startTimeout(step Step, duration time.Duration) {
-	time.Sleep(duration)
+// startTimeout advances the step if the round is still at the given step when the timeout fires
+func (c *Consensus) startTimeout(height uint64, round int32, step Step, duration time.Duration) {
+	select {
+	case <-c.quit:
+		return
+	case <-time.After(duration):
+	}
 
 	c.votingMutex.Lock()
 	defer c.votingMutex.Unlock()
 
-	if c.step == step {
-		log.Printf("Timeout for step %v at height %d, round %d", step, c.height, c.round)
+	if c.height == height && c.round == round && c.step == step {
+		logger.Warn("Step timed out", "step", step, "height", c.height, "round", c.round)
 		c.advanceToNextStep()
 	}
 }
 
-// handleProposal handles a new proposal
+// handleProposal handles a proposal received from the network
 func (c *Consensus) handleProposal(proposal *Proposal) {
+	c.votingMutex.Lock()
+	defer c.votingMutex.Unlock()
+
+	c.processProposal(proposal)
+}
+
+// processProposal validates a proposal and prevotes for it. Callers must hold votingMutex.
+func (c *Consensus) processProposal(proposal *Proposal) {
+	// A proposal from the proposer of a later round of this height means our
+	// round timers lag behind the other validators; catch up instead of dropping it
+	if proposal.Block != nil && proposal.Block.Height == c.height && proposal.Round > c.round &&
+		c.step != StepCommit && proposal.ProposerID == c.proposer(c.height, proposal.Round) {
+		c.startNextRound(proposal.Round)
+	}
+
 	// Validate the proposal
 	if !c.validateProposal(proposal) {
-		log.Printf("Invalid proposal for height %d", proposal.Block.Height)
+		logger.Warn("Invalid proposal", "height", proposal.Block.Height)
 		return
 	}
 
-	log.Printf("Received valid proposal for height %d", proposal.Block.Height)
+	logger.Debug("Received valid proposal", "height", proposal.Block.Height)
+	c.proposal = proposal.Block
+
+	if c.step != StepPropose {
+		// Already prevoted this round; the block may still complete a pending quorum
+		c.checkQuorums(c.round)
+		return
+	}
 
 	// Move to prevote step
-	c.step = Prevote
+	c.step = StepPrevote
+	go c.startTimeout(c.height, c.round, StepPrevote, c.roundTimeout(c.timeoutPrevote))
 
-	// Send prevote
-	vote := &Vote{
-		Height:   proposal.Block.Height,
-		Round:    proposal.Block.Round,
-		Type:     Prevote,
-		BlockID:  proposal.Block.Hash(),
-		SenderID: c.config.NodeID,
+	// Prevote for the block we're locked on, otherwise for the proposal
+	blockID := proposal.Block.Hash()
+	if c.locked != nil {
+		blockID = c.locked.Hash()
 	}
 
-	c.broadcastVote(vote)
+	c.castVote(Prevote, blockID)
 }
 
 // validateProposal validates a proposal
 func (c *Consensus) validateProposal(proposal *Proposal) bool {
+	if proposal.Block == nil {
+		return false
+	}
+
 	// Check height and round
 	if proposal.Block.Height != c.height || proposal.Block.Round != c.round {
 		return false
 	}
 
 	// Check proposer
-	if !c.isProposer() {
-		return false // Only proposer can send proposals in this simplified version
+	if proposal.ProposerID != c.proposer(c.height, c.round) {
+		return false
 	}
 
-	// Validate transactions (simplified)
+	// The tx root must commit to exactly these transactions, in this order
+	if !bytes.Equal(proposal.Block.TxRoot, txMerkleRoot(proposal.Block.Txs)) {
+		return false
+	}
+
+	// Each transaction must be signed by its sender, and a sender/ID pair may
+	// appear once, across this block and every committed one
+	idKeys := make(map[string]struct{}, len(proposal.Block.Txs))
 	for _, txBytes := range proposal.Block.Txs {
 		var tx Transaction
 		if err := json.Unmarshal(txBytes, &tx); err != nil {
 			return false
 		}
-		if !c.validateTransaction(&tx) {
+		if err := validateTransaction(&tx); err != nil {
+			logger.Warn("Proposal holds an unauthenticated transaction", "tx", tx.ID, "err", err)
+			return false
+		}
+		idKey := senderTxKey(tx.Sender, tx.ID)
+		if _, seen := idKeys[idKey]; seen {
+			logger.Warn("Proposal holds a transaction twice", "tx", tx.ID, "sender", tx.Sender)
+			return false
+		}
+		idKeys[idKey] = struct{}{}
+		committed, err := c.store.Get(context.Background(), txSenderIndexKey(idKey))
+		if err != nil {
+			logger.Error("Failed to read transaction index", "err", err)
+			return false
+		}
+		if committed != nil {
+			logger.Warn("Proposal replays a committed transaction", "tx", tx.ID, "sender", tx.Sender)
+			return false
+		}
+		if err := c.validateTxType(&tx); err != nil {
+			logger.Warn("Proposal holds an invalid transaction", "tx", tx.ID, "err", err)
 			return false
 		}
 	}
@@ -303,30 +501,172 @@ func (c *Consensus) validateProposal(proposal *Proposal) bool {
 	return true
 }
 
-// validateTransaction validates a transaction
-func (c *Consensus) validateTransaction(tx *Transaction) bool {
-	// Simplified validation - check signature, etc.
-	return len(tx.ID) > 0 && len(tx.Sender) > 0
+// validateTransaction checks the client's signature over the transaction and
+// that Sender is the identity of the signing key
+func validateTransaction(tx *Transaction) error {
+	if tx.ID == "" {
+		return errors.New("missing transaction ID")
+	}
+	var payload map[string]interface{}
+	if len(tx.Payload) > 0 {
+		if err := json.Unmarshal(tx.Payload, &payload); err != nil {
+			return fmt.Errorf("payload is not a JSON object: %w", err)
+		}
+	}
+	sender, err := security.VerifySubmittedTx(tx.PublicKey, tx.Signature, tx.ID, tx.Type, payload, tx.Priority)
+	if err != nil {
+		return err
+	}
+	if sender != tx.Sender {
+		return fmt.Errorf("sender %q does not match the signing key %s", tx.Sender, sender)
+	}
+	return nil
 }
 
-// broadcastVote broadcasts a vote to all peers
-func (c *Consensus) broadcastVote(vote *Vote) {
-	// Serialize vote
-	voteBytes, err := json.Marshal(vote)
-	if err != nil {
-		log.Printf("Failed to serialize vote: %v", err)
+// castVote records our own vote and broadcasts it. Callers must hold votingMutex.
+func (c *Consensus) castVote(voteType VoteType, blockID []byte) {
+	vote := &Vote{
+		Height:   c.height,
+		Round:    c.round,
+		Type:     voteType,
+		BlockID:  blockID,
+		SenderID: c.config.NodeID,
+	}
+	if err := c.signVote(vote); err != nil {
+		logger.Error("Failed to sign own vote", "err", err)
+		return
+	}
+
+	c.broadcastVote(vote)
+	if err := c.addVote(vote); err != nil {
+		logger.Error("Failed to record own vote", "err", err)
+	}
+}
+
+// AddVote records a vote received from a validator and advances the round once
+// a two-thirds quorum is reached. The vote must be signed by the validator it
+// names; otherwise it is neither tallied nor taken as evidence of equivocation.
+func (c *Consensus) AddVote(vote *Vote) error {
+	if err := c.verifyVote(vote); err != nil {
+		return err
+	}
+
+	c.votingMutex.Lock()
+	defer c.votingMutex.Unlock()
+
+	return c.addVote(vote)
+}
+
+// addVote tallies a vote. Callers must hold votingMutex.
+func (c *Consensus) addVote(vote *Vote) error {
+	if !c.isValidator(vote.SenderID) {
+		return fmt.Errorf("vote from unknown validator %q", vote.SenderID)
+	}
+	if vote.Type != Prevote && vote.Type != Precommit {
+		return fmt.Errorf("unknown vote type %d", vote.Type)
+	}
+	if vote.Height != c.height {
+		return fmt.Errorf("vote for height %d, current height is %d", vote.Height, c.height)
+	}
+
+	key := voteKey{height: vote.Height, round: vote.Round, voteType: vote.Type}
+	if c.votes[key] == nil {
+		c.votes[key] = make(map[string]*Vote)
+	}
+	if first, exists := c.votes[key][vote.SenderID]; exists {
+		if bytes.Equal(first.BlockID, vote.BlockID) {
+			return nil // Duplicate delivery
+		}
+		c.recordEquivocation(first, vote)
+		return fmt.Errorf("%w: %s sent conflicting %s votes at height %d, round %d",
+			ErrEquivocation, vote.SenderID, vote.Type, vote.Height, vote.Round)
+	}
+	c.votes[key][vote.SenderID] = vote
+
+	c.checkQuorums(vote.Round)
+	return nil
+}
+
+// quorumBlock returns the block ID backed by over two thirds of the voting power in the given step, if any
+func (c *Consensus) quorumBlock(round int32, voteType VoteType) ([]byte, bool) {
+	power := make(map[string]int64)
+	for _, vote := range c.votes[voteKey{height: c.height, round: round, voteType: voteType}] {
+		if len(vote.BlockID) == 0 {
+			continue // nil votes never decide a block
+		}
+		power[string(vote.BlockID)] += c.validators.power(vote.SenderID)
+		if c.validators.hasQuorum(power[string(vote.BlockID)]) {
+			return vote.BlockID, true
+		}
+	}
+	return nil, false
+}
+
+// blockByID returns the proposal or locked block with the given hash
+func (c *Consensus) blockByID(blockID []byte) *Block {
+	for _, block := range []*Block{c.proposal, c.locked} {
+		if block != nil && bytes.Equal(block.Hash(), blockID) {
+			return block
+		}
+	}
+	return nil
+}
+
+// checkQuorums locks on a block with a prevote quorum and commits a block with a
+// precommit quorum. Callers must hold votingMutex.
+func (c *Consensus) checkQuorums(round int32) {
+	if c.step == StepCommit {
+		return
+	}
+
+	// Precommit quorum in any round of this height decides the block
+	if blockID, ok := c.quorumBlock(round, Precommit); ok {
+		block := c.blockByID(blockID)
+		if block == nil {
+			logger.Info("Precommit quorum for unknown block, waiting for proposal", "height", c.height)
+			return
+		}
+		c.commitBlock(block)
+		return
+	}
+
+	// Prevote quorum in the current round locks the block and moves to precommit
+	if round != c.round || c.step == StepPrecommit {
 		return
 	}
+	if blockID, ok := c.quorumBlock(round, Prevote); ok {
+		block := c.blockByID(blockID)
+		if block == nil {
+			return
+		}
 
-	// Broadcast via P2P (simplified - in production, use proper message types)
-	log.Printf("Broadcasting %s vote for height %d, round %d", vote.Type, vote.Height, vote.Round)
+		c.locked = block
+		c.lockedRound = round
+		c.validated = block
+		c.step = StepPrecommit
+		go c.startTimeout(c.height, c.round, StepPrecommit, c.roundTimeout(c.timeoutPrecommit))
 
-	// For now, just log - in production, send to all validators
-	_ = voteBytes
+		c.castVote(Precommit, blockID)
+	}
+}
+
+// broadcastVote broadcasts a vote to all peers
+func (c *Consensus) broadcastVote(vote *Vote) {
+	logger.Debug("Broadcasting vote", "type", vote.Type, "height", vote.Height, "round", vote.Round)
+	c.broadcast(VoteMsg, vote)
+}
+
+// broadcastProposal broadcasts a proposal to all peers
+func (c *Consensus) broadcastProposal(proposal *Proposal) {
+	logger.Debug("Broadcasting proposal", "height", proposal.Block.Height, "round", proposal.Round)
+	c.broadcast(ProposalMsg, proposal)
 }
 
 // handleBlock handles a new block
 func (c *Consensus) handleBlock(block *Block) {
+	c.votingMutex.Lock()
+	defer c.votingMutex.Unlock()
+
 	// Validate and apply block
 	if c.validateBlock(block) {
 		c.commitBlock(block)
@@ -336,44 +676,73 @@ func (c *Consensus) handleBlock(block *Block) {
 // validateBlock validates a block
 func (c *Consensus) validateBlock(block *Block) bool {
 	// Simplified validation
-	return block.Height == c.height && len(block.Txs) >= 0
+	return block.Height == c.height && c.step != StepCommit
 }
 
-// commitBlock commits a block to the blockchain
+// commitBlock commits a block to the blockchain and schedules the next height.
+// Callers must hold votingMutex.
 func (c *Consensus) commitBlock(block *Block) {
-	log.Printf("Committing block at height %d", block.Height)
+	logger.Info("Committing block", "height", block.Height)
+	c.step = StepCommit
 
-	// Store block
-	blockBytes, _ := json.Marshal(block)
-	blockKey := []byte(fmt.Sprintf("block/%d", block.Height))
-	c.store.Set(context.Background(), blockKey, blockBytes)
+	if err := c.storeBlock(block); err != nil {
+		logger.Error("Failed to store block", "height", block.Height, "err", err)
+	}
+	c.indexTransactions(block)
+	c.applyTransactions(block)
+	c.maintain(block)
+	c.publishBlock(block)
 
-	// Store block hash
-	hashKey := []byte(fmt.Sprintf("block-hash/%d", block.Height))
-	c.store.Set(context.Background(), hashKey, block.Hash())
+	// Drop the committed transactions; the rest wait for a later block
+	c.removeFromMempool(block.Txs)
 
-	// Clear mempool (transactions are now in block)
-	c.votingMutex.Lock()
-	c.mempool = nil
-	c.votingMutex.Unlock()
+	// Move to next height after the commit timeout
+	go c.startTimeout(c.height, c.round, StepCommit, c.timeoutCommit)
+}
 
-	// Move to next height
-	c.height++
+// storeBlock writes a block and its hash and makes it the latest block
+func (c *Consensus) storeBlock(block *Block) error {
+	ctx := context.Background()
+	blockBytes, err := json.Marshal(block)
+	if err != nil {
+		return err
+	}
+
+	// The latest pointers go last so they never name a block that is not stored
+	writes := []struct {
+		key   string
+		value []byte
+	}{
+		{fmt.Sprintf("block/%d", block.Height), blockBytes},
+		{fmt.Sprintf("block-hash/%d", block.Height), block.Hash()},
+		{"latest-block", blockBytes},
+		{"latest-height", []byte(strconv.FormatUint(block.Height, 10))},
+	}
+	for _, w := range writes {
+		if err := c.store.Set(ctx, []byte(w.key), w.value); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// advanceToNextStep advances to the next consensus step
+// advanceToNextStep moves on when a step times out. Without a proposal or a
+// quorum the node votes nil, and a round that fails to decide a block is
+// followed by the next round with the next proposer. Callers must hold votingMutex.
 func (c *Consensus) advanceToNextStep() {
 	switch c.step {
-	case Propose:
-		c.step = Prevote
-		go c.startTimeout(Prevote, c.timeoutPrevote)
-	case Prevote:
-		c.step = Precommit
-		go c.startTimeout(Precommit, c.timeoutPrecommit)
-	case Precommit:
-		c.step = Commit
-		go c.startTimeout(Commit, c.timeoutCommit)
-	case Commit:
+	case StepPropose:
+		c.recordMissedProposal()
+		c.step = StepPrevote
+		go c.startTimeout(c.height, c.round, StepPrevote, c.roundTimeout(c.timeoutPrevote))
+		c.castVote(Prevote, nil)
+	case StepPrevote:
+		c.step = StepPrecommit
+		go c.startTimeout(c.height, c.round, StepPrecommit, c.roundTimeout(c.timeoutPrecommit))
+		c.castVote(Precommit, nil)
+	case StepPrecommit:
+		c.startNextRound(c.round + 1)
+	case StepCommit:
 		// Start new height
 		c.startNewHeight()
 	}
@@ -385,6 +754,7 @@ type Block struct {
 	Round     int32
 	Timestamp time.Time
 	Txs       [][]byte
+	TxRoot    []byte // Merkle root over the transaction hashes, see txMerkleRoot
 	LastHash  []byte
 	StateHash []byte
 }
@@ -396,25 +766,27 @@ func (b *Block) Hash() []byte {
 	binary.Write(h, binary.BigEndian, b.Round)
 	h.Write(b.LastHash)
 	h.Write(b.StateHash)
-	for _, tx := range b.Txs {
-		h.Write(tx)
-	}
+	// Transactions are committed through their Merkle root so each can be proven on its own
+	h.Write(b.TxRoot)
 	return h.Sum(nil)
 }
 
 // Proposal represents a block proposal
 type Proposal struct {
-	Block *Block
-	Round int32
+	Block      *Block
+	Round      int32
+	ProposerID string
+	Signature  []byte // by ProposerID's key over SignBytes
 }
 
 // Vote represents a vote in the consensus process
 type Vote struct {
-	Height   uint64
-	Round    int32
-	Type     VoteType
-	BlockID  []byte
-	SenderID string
+	Height    uint64
+	Round     int32
+	Type      VoteType
+	BlockID   []byte
+	SenderID  string
+	Signature []byte // by SenderID's key over SignBytes
 }
 
 // VoteType represents the type of vote
@@ -0,0 +1,285 @@
+package consensus
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rechain/rechain/internal/security"
+	"github.com/rechain/rechain/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKeys holds the signing key of every validator keyedValidators has built
+var testKeys = map[string]*security.KeyManager{}
+
+var testValidators = keyedValidators("node-1", "node-2", "node-3", "node-4")
+
+// keyedValidators builds unit-power validators whose public keys are in testKeys
+func keyedValidators(ids ...string) []Validator {
+	validators := make([]Validator, len(ids))
+	for i, id := range ids {
+		km, exists := testKeys[id]
+		if !exists {
+			var err error
+			if km, err = security.NewKeyManagerWithAlgorithm(security.AlgorithmEd25519); err != nil {
+				panic(err)
+			}
+			testKeys[id] = km
+		}
+		pubKey, err := km.PublicKeyBytes()
+		if err != nil {
+			panic(err)
+		}
+		validators[i] = Validator{ID: id, PubKey: base64.StdEncoding.EncodeToString(pubKey), VotingPower: 1}
+	}
+	return validators
+}
+
+// newTestConsensus creates a consensus instance for nodeID in the four-validator test set
+func newTestConsensus(t *testing.T, nodeID string) (*Consensus, *storage.MemoryStore) {
+	t.Helper()
+
+	store := storage.NewMemoryStore()
+	c, err := NewConsensusWithConfig(store, nil, &Config{
+		NodeID:        nodeID,
+		BlockInterval: time.Hour, // keep timeouts out of the way; tests drive every step
+		Timeout:       time.Hour,
+		Validators:    testValidators,
+	})
+	require.NoError(t, err)
+	c.timeoutPrevote = time.Hour
+	c.timeoutPrecommit = time.Hour
+	t.Cleanup(func() { c.Stop() })
+
+	return c, store
+}
+
+// voteFrom returns a vote signed by sender's key in testKeys
+func voteFrom(sender string, voteType VoteType, block *Block) *Vote {
+	vote := &Vote{
+		Height:   block.Height,
+		Round:    block.Round,
+		Type:     voteType,
+		BlockID:  block.Hash(),
+		SenderID: sender,
+	}
+	if km := testKeys[sender]; km != nil {
+		vote.Signature, _ = km.SignData(vote.SignBytes())
+	}
+	return vote
+}
+
+func TestQuorumCommitsBlock(t *testing.T) {
+	c, store := newTestConsensus(t, "node-1")
+	require.NoError(t, c.AddTransaction(testTx("tx-1")))
+
+	require.NoError(t, c.Start())
+	require.Equal(t, uint64(1), c.Height())
+
+	// Height 1, round 0 is proposed by node-2
+	c.votingMutex.Lock()
+	proposer := c.proposer(1, 0)
+	block := c.createProposal()
+	c.votingMutex.Unlock()
+	require.Equal(t, "node-2", proposer)
+
+	c.handleProposal(&Proposal{Block: block, Round: 0, ProposerID: proposer})
+	assert.Equal(t, StepPrevote, c.step)
+
+	// node-1 prevoted itself; one more prevote is not yet two thirds of four
+	require.NoError(t, c.AddVote(voteFrom("node-2", Prevote, block)))
+	assert.Equal(t, StepPrevote, c.step)
+	assert.Nil(t, c.locked)
+
+	// Third prevote reaches quorum: lock and precommit
+	require.NoError(t, c.AddVote(voteFrom("node-3", Prevote, block)))
+	assert.Equal(t, StepPrecommit, c.step)
+	require.NotNil(t, c.locked)
+	assert.Equal(t, block.Hash(), c.locked.Hash())
+
+	// Precommits from two more validators complete the quorum and commit
+	require.NoError(t, c.AddVote(voteFrom("node-2", Precommit, block)))
+	assert.Equal(t, StepPrecommit, c.step)
+	require.NoError(t, c.AddVote(voteFrom("node-4", Precommit, block)))
+	assert.Equal(t, StepCommit, c.step)
+
+	stored, err := store.Get(context.Background(), []byte("block/1"))
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+
+	var committed Block
+	require.NoError(t, json.Unmarshal(stored, &committed))
+	assert.Equal(t, block.Hash(), committed.Hash())
+	assert.Len(t, committed.Txs, 1)
+	assert.Empty(t, c.GetMempool())
+}
+
+func TestAddVoteRejectsInvalidVotes(t *testing.T) {
+	c, _ := newTestConsensus(t, "node-1")
+	require.NoError(t, c.Start())
+
+	block := &Block{Height: 1, Round: 0}
+	assert.Error(t, c.AddVote(voteFrom("node-9", Prevote, block)), "unknown validator")
+
+	block.Height = 5
+	assert.Error(t, c.AddVote(voteFrom("node-2", Prevote, block)), "wrong height")
+}
+
+func TestRejectsProposalFromWrongProposer(t *testing.T) {
+	c, _ := newTestConsensus(t, "node-1")
+	require.NoError(t, c.Start())
+
+	block := &Block{Height: 1, Round: 0, Timestamp: time.Now()}
+	c.handleProposal(&Proposal{Block: block, Round: 0, ProposerID: "node-3"})
+
+	assert.Equal(t, StepPropose, c.step)
+	assert.Nil(t, c.proposal)
+}
+
+func TestRejectsProposalWithUnauthenticatedTx(t *testing.T) {
+	c, _ := newTestConsensus(t, "node-1")
+	require.NoError(t, c.Start())
+
+	unsigned := &Transaction{ID: "tx-1", Type: "test", Sender: "client", Timestamp: time.Now()}
+	forged := testTx("tx-2")
+	forged.Sender = "someone-else"
+	raised := testTx("tx-3")
+	raised.Priority = 10
+
+	for _, tx := range []*Transaction{unsigned, forged, raised} {
+		txBytes, err := json.Marshal(tx)
+		require.NoError(t, err)
+		block := &Block{Height: 1, Round: 0, Timestamp: time.Now(), Txs: [][]byte{txBytes}, TxRoot: txMerkleRoot([][]byte{txBytes})}
+
+		// node-2 proposes height 1
+		c.handleProposal(&Proposal{Block: block, Round: 0, ProposerID: "node-2"})
+		assert.Equal(t, StepPropose, c.step, tx.ID)
+		assert.Nil(t, c.proposal, tx.ID)
+	}
+}
+
+func TestSingleValidatorCommitsOnItsOwn(t *testing.T) {
+	store := storage.NewMemoryStore()
+	c, err := NewConsensus(store, nil)
+	require.NoError(t, err)
+	defer c.Stop()
+
+	require.NoError(t, c.Start())
+
+	// With one validator, proposing, prevoting and precommitting all complete immediately
+	data, err := store.Get(context.Background(), []byte(fmt.Sprintf("block/%d", 1)))
+	require.NoError(t, err)
+	assert.NotNil(t, data)
+
+	latest, err := store.Get(context.Background(), []byte("latest-height"))
+	require.NoError(t, err)
+	assert.Equal(t, "1", string(latest))
+}
+
+func TestEquivocationIsDetectedAndNotCounted(t *testing.T) {
+	auditPath := filepath.Join(t.TempDir(), "audit.log")
+	auditLogger, err := security.NewFileAuditLogger(security.AuditConfig{Path: auditPath})
+	require.NoError(t, err)
+	defer auditLogger.Close()
+
+	c, err := NewConsensusWithConfig(storage.NewMemoryStore(), nil, &Config{
+		NodeID:        "node-1",
+		BlockInterval: time.Hour,
+		Timeout:       time.Hour,
+		Validators:    testValidators,
+		AuditLogger:   auditLogger,
+	})
+	require.NoError(t, err)
+	c.timeoutPrevote = time.Hour
+	c.timeoutPrecommit = time.Hour
+	defer c.Stop()
+	require.NoError(t, c.Start())
+
+	blockA := &Block{Height: 1, Round: 0, StateHash: []byte("a")}
+	blockB := &Block{Height: 1, Round: 0, StateHash: []byte("b")}
+
+	require.NoError(t, c.AddVote(voteFrom("node-2", Prevote, blockA)))
+	err = c.AddVote(voteFrom("node-2", Prevote, blockB))
+	require.ErrorIs(t, err, ErrEquivocation)
+
+	// Redelivering the original vote is not equivocation
+	require.NoError(t, c.AddVote(voteFrom("node-2", Prevote, blockA)))
+
+	c.votingMutex.Lock()
+	counted := c.votes[voteKey{height: 1, round: 0, voteType: Prevote}]["node-2"]
+	c.votingMutex.Unlock()
+	require.NotNil(t, counted)
+	assert.Equal(t, blockA.Hash(), counted.BlockID, "only the first vote counts")
+
+	evidence := c.Evidence()
+	require.Len(t, evidence, 1)
+	assert.Equal(t, blockA.Hash(), evidence[0].VoteA.BlockID)
+	assert.Equal(t, blockB.Hash(), evidence[0].VoteB.BlockID)
+
+	require.NoError(t, auditLogger.Close())
+	data, err := os.ReadFile(auditPath)
+	require.NoError(t, err)
+
+	var entry security.AuditEntry
+	require.NoError(t, json.Unmarshal(bytes.TrimSpace(data), &entry))
+	assert.Equal(t, "equivocation", entry.EventType)
+	assert.Contains(t, entry.Details, "node-2")
+}
+
+func TestSpoofedVoteIsDroppedNotRecordedAsEvidence(t *testing.T) {
+	c, _ := newTestConsensus(t, "node-1")
+	require.NoError(t, c.Start())
+
+	blockA := &Block{Height: 1, Round: 0, StateHash: []byte("a")}
+	blockB := &Block{Height: 1, Round: 0, StateHash: []byte("b")}
+
+	// node-3 forges a vote in node-2's name, unsigned and then with its own key
+	unsigned := voteFrom("node-2", Prevote, blockA)
+	unsigned.Signature = nil
+	assert.ErrorIs(t, c.AddVote(unsigned), ErrInvalidVoteSignature)
+
+	forged := voteFrom("node-2", Prevote, blockA)
+	var err error
+	forged.Signature, err = testKeys["node-3"].SignData(forged.SignBytes())
+	require.NoError(t, err)
+	assert.ErrorIs(t, c.AddVote(forged), ErrInvalidVoteSignature)
+
+	// node-2's own conflicting vote is counted, not taken as equivocation
+	require.NoError(t, c.AddVote(voteFrom("node-2", Prevote, blockB)))
+	assert.Empty(t, c.Evidence())
+
+	c.votingMutex.Lock()
+	counted := c.votes[voteKey{height: 1, round: 0, voteType: Prevote}]["node-2"]
+	c.votingMutex.Unlock()
+	require.NotNil(t, counted)
+	assert.Equal(t, blockB.Hash(), counted.BlockID)
+}
+
+func TestNetworkedNodeNeedsValidatorKeys(t *testing.T) {
+	network := &memNetwork{}
+	cfg := func(validators []Validator, signer *security.KeyManager) *Config {
+		return &Config{NodeID: "node-1", BlockInterval: time.Hour, Timeout: time.Hour, Validators: validators, Signer: signer}
+	}
+
+	unkeyed := []Validator{{ID: "node-1", VotingPower: 1}, {ID: "node-2", VotingPower: 1}}
+	_, err := NewConsensusWithConfig(storage.NewMemoryStore(), network.join(), cfg(unkeyed, testKeys["node-1"]))
+	assert.Error(t, err, "validators without keys")
+
+	_, err = NewConsensusWithConfig(storage.NewMemoryStore(), network.join(), cfg(testValidators, nil))
+	assert.Error(t, err, "no signer")
+
+	_, err = NewConsensusWithConfig(storage.NewMemoryStore(), network.join(), cfg(testValidators, testKeys["node-2"]))
+	assert.Error(t, err, "signer with another validator's key")
+
+	c, err := NewConsensusWithConfig(storage.NewMemoryStore(), network.join(), cfg(testValidators, testKeys["node-1"]))
+	require.NoError(t, err)
+	c.Stop()
+}
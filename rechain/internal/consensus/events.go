@@ -0,0 +1,46 @@
+package consensus
+
+import (
+	"encoding/hex"
+	"time"
+)
+
+// Event types published by the consensus engine
+const (
+	EventBlock = "block"
+	EventTx    = "tx"
+)
+
+// Event describes a committed block or a transaction accepted into the mempool
+type Event struct {
+	Type      string    `json:"type"`
+	Hash      string    `json:"hash"`
+	Height    uint64    `json:"height,omitempty"`
+	TxCount   int       `json:"tx_count,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Events returns the engine's event stream. It is meant for a single consumer;
+// events are dropped when the stream is not drained.
+func (c *Consensus) Events() <-chan Event {
+	return c.events
+}
+
+// publish emits an event without blocking consensus
+func (c *Consensus) publish(ev Event) {
+	ev.Timestamp = time.Now()
+	select {
+	case c.events <- ev:
+	default:
+	}
+}
+
+// publishBlock emits a block event for a committed block
+func (c *Consensus) publishBlock(block *Block) {
+	c.publish(Event{
+		Type:    EventBlock,
+		Hash:    hex.EncodeToString(block.Hash()),
+		Height:  block.Height,
+		TxCount: len(block.Txs),
+	})
+}
@@ -0,0 +1,57 @@
+package consensus
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrEquivocation is returned for a vote that conflicts with one the validator already cast
+var ErrEquivocation = errors.New("equivocation")
+
+// Evidence records two conflicting votes from the same validator in the same step,
+// kept for a future slashing transaction
+type Evidence struct {
+	VoteA      *Vote
+	VoteB      *Vote
+	DetectedAt time.Time
+}
+
+// Evidence returns the equivocation evidence collected so far
+func (c *Consensus) Evidence() []*Evidence {
+	c.votingMutex.Lock()
+	defer c.votingMutex.Unlock()
+
+	return append([]*Evidence{}, c.evidence...)
+}
+
+// recordEquivocation stores evidence for a conflicting vote and reports it to the
+// audit log. Only the first conflict per validator and step is recorded. Callers
+// must hold votingMutex.
+func (c *Consensus) recordEquivocation(first, second *Vote) {
+	for _, ev := range c.evidence {
+		if ev.VoteA.SenderID == first.SenderID && ev.VoteA.Height == first.Height &&
+			ev.VoteA.Round == first.Round && ev.VoteA.Type == first.Type {
+			return
+		}
+	}
+
+	c.evidence = append(c.evidence, &Evidence{
+		VoteA:      first,
+		VoteB:      second,
+		DetectedAt: time.Now(),
+	})
+
+	c.auditLogger.LogSecurityEvent("equivocation", fmt.Sprintf(
+		"validator %s sent conflicting %s votes at height %d, round %d: %x and %x",
+		first.SenderID, first.Type, first.Height, first.Round,
+		shortID(first.BlockID), shortID(second.BlockID)))
+}
+
+// shortID truncates a block ID for log output
+func shortID(id []byte) []byte {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
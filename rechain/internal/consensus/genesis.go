@@ -0,0 +1,96 @@
+package consensus
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultChainID names the chain when no genesis is configured
+const DefaultChainID = "rechain-mainnet"
+
+// ErrGenesisMismatch is returned when the store holds a chain that was started
+// from a different genesis than the configured one
+var ErrGenesisMismatch = errors.New("genesis mismatch")
+
+// Genesis fixes the origin of a chain. Every node of a chain must be configured
+// with the same genesis; its block is committed at height 0.
+type Genesis struct {
+	ChainID    string
+	Time       time.Time
+	Validators []Validator
+}
+
+// defaultGenesis returns the genesis used when Config.Genesis is not set. The
+// time is fixed so that restarts and other nodes derive the same genesis block.
+func defaultGenesis(validators []Validator) *Genesis {
+	return &Genesis{ChainID: DefaultChainID, Time: time.Unix(0, 0).UTC(), Validators: validators}
+}
+
+// Block returns the genesis block. Its state hash commits to the chain ID, the
+// time and the initial validator set.
+func (g *Genesis) Block() *Block {
+	doc, _ := json.Marshal(g)
+	stateHash := sha256.Sum256(doc)
+
+	return &Block{
+		Height:    0,
+		Timestamp: g.Time,
+		TxRoot:    txMerkleRoot(nil),
+		LastHash:  make([]byte, 32),
+		StateHash: stateHash[:],
+	}
+}
+
+// GenesisHash returns the hash of the block the chain started from
+func (c *Consensus) GenesisHash() []byte {
+	return c.genesisHash
+}
+
+// initChain commits the genesis block on first start and otherwise checks that
+// the stored chain grew from the same genesis, then resumes at the latest
+// committed height. It runs before the consensus loop starts.
+func (c *Consensus) initChain(genesis *Genesis) error {
+	ctx := context.Background()
+	block := genesis.Block()
+
+	stored, err := c.store.Get(ctx, []byte("block-hash/0"))
+	if err != nil {
+		return fmt.Errorf("failed to read genesis hash: %w", err)
+	}
+	latest, err := c.store.Get(ctx, []byte("latest-height"))
+	if err != nil {
+		return fmt.Errorf("failed to read latest height: %w", err)
+	}
+
+	switch {
+	case stored == nil && latest == nil:
+		if err := c.storeBlock(block); err != nil {
+			return fmt.Errorf("failed to store genesis block: %w", err)
+		}
+		logger.Info("Initialized chain from genesis", "chain_id", genesis.ChainID, "hash", fmt.Sprintf("%x", block.Hash()))
+	case stored == nil:
+		// Chains started before genesis blocks existed only lack the block at height 0
+		if err := c.store.Set(ctx, []byte("block-hash/0"), block.Hash()); err != nil {
+			return fmt.Errorf("failed to store genesis hash: %w", err)
+		}
+		logger.Warn("Adopted configured genesis for an existing chain", "chain_id", genesis.ChainID)
+	case !bytes.Equal(stored, block.Hash()):
+		return fmt.Errorf("%w: store holds chain %x, configured genesis is %x", ErrGenesisMismatch, stored, block.Hash())
+	}
+
+	height, err := c.latestHeight(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to parse latest height: %w", err)
+	}
+	c.height = height
+	c.genesisHash = block.Hash()
+	if height > 0 {
+		logger.Info("Resuming chain", "chain_id", genesis.ChainID, "height", height)
+	}
+	return nil
+}
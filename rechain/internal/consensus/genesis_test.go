@@ -0,0 +1,70 @@
+package consensus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rechain/rechain/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChainStartsFromGenesisAndResumes(t *testing.T) {
+	store := storage.NewMemoryStore()
+	cfg := &Config{
+		NodeID:        "node-1",
+		BlockInterval: time.Hour, // commit one block per Start
+		Validators:    []Validator{{ID: "node-1", VotingPower: 1}},
+		Genesis:       &Genesis{ChainID: "rechain-test", Time: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	c, err := NewConsensusWithConfig(store, nil, cfg)
+	require.NoError(t, err)
+	genesisHash := c.GenesisHash()
+	require.NotEmpty(t, genesisHash)
+	assert.Equal(t, uint64(0), c.Height())
+
+	stored, err := store.Get(context.Background(), []byte("block/0"))
+	require.NoError(t, err)
+	var genesis Block
+	require.NoError(t, json.Unmarshal(stored, &genesis))
+	assert.Equal(t, genesisHash, genesis.Hash())
+
+	// Height 1 builds on the genesis block
+	require.NoError(t, c.Start())
+	first := committedBlock(t, store, 1)
+	assert.Equal(t, genesisHash, first.LastHash)
+	require.NoError(t, c.Stop())
+
+	// A restart resumes after the latest block instead of starting over
+	restarted, err := NewConsensusWithConfig(store, nil, cfg)
+	require.NoError(t, err)
+	defer restarted.Stop()
+	assert.Equal(t, genesisHash, restarted.GenesisHash())
+	assert.Equal(t, uint64(1), restarted.Height())
+
+	require.NoError(t, restarted.Start())
+	second := committedBlock(t, store, 2)
+	assert.Equal(t, first.Hash(), second.LastHash)
+
+	// A store from one chain cannot be reused with another genesis
+	other := *cfg
+	other.Genesis = &Genesis{ChainID: "other-chain", Time: cfg.Genesis.Time}
+	_, err = NewConsensusWithConfig(store, nil, &other)
+	require.ErrorIs(t, err, ErrGenesisMismatch)
+}
+
+func committedBlock(t *testing.T, store storage.Store, height uint64) *Block {
+	t.Helper()
+
+	data, err := store.Get(context.Background(), []byte(fmt.Sprintf("block/%d", height)))
+	require.NoError(t, err)
+	require.NotNil(t, data, "block %d not committed", height)
+
+	var block Block
+	require.NoError(t, json.Unmarshal(data, &block))
+	return &block
+}
@@ -0,0 +1,34 @@
+package consensus
+
+// Round returns the round currently being decided at the current height
+func (c *Consensus) Round() int32 {
+	c.votingMutex.Lock()
+	defer c.votingMutex.Unlock()
+	return c.round
+}
+
+// MissedProposals returns how many rounds each validator failed to propose in
+// since the node started. Validators that never missed a round are omitted.
+func (c *Consensus) MissedProposals() map[string]uint64 {
+	c.votingMutex.Lock()
+	defer c.votingMutex.Unlock()
+
+	missed := make(map[string]uint64, len(c.missedProposals))
+	for id, count := range c.missedProposals {
+		missed[id] = count
+	}
+	return missed
+}
+
+// recordMissedProposal counts a propose timeout against the round's proposer
+// when no valid proposal arrived. Callers must hold votingMutex.
+func (c *Consensus) recordMissedProposal() {
+	if c.proposal != nil {
+		return
+	}
+
+	proposer := c.proposer(c.height, c.round)
+	c.missedProposals[proposer]++
+	logger.Warn("Proposer missed its round", "proposer", proposer, "height", c.height, "round", c.round,
+		"missed", c.missedProposals[proposer])
+}
@@ -0,0 +1,157 @@
+package consensus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Defaults for the Config limits that are left unset
+const (
+	DefaultMaxMempoolSize = 5000
+	DefaultMaxBlockTxs    = 1000
+	DefaultMaxBlockBytes  = 1024 * 1024
+)
+
+var (
+	// ErrMempoolFull is returned when the mempool is at capacity
+	ErrMempoolFull = errors.New("mempool is full")
+	// ErrDuplicateTx is returned for a transaction that is already pending or committed
+	ErrDuplicateTx = errors.New("duplicate transaction")
+)
+
+// AddTransaction adds a transaction to the mempool. It fails with
+// ErrInvalidTx if the client's signature does not verify, with
+// ErrUnknownTxType or ErrInvalidTx if Config.TxRegistry does not accept the
+// transaction, with ErrDuplicateTx if the same transaction, or another with
+// the same sender and ID, is already pending or committed, and with ErrMempoolFull once Config.MaxMempoolSize
+// transactions are pending.
+func (c *Consensus) AddTransaction(tx *Transaction) error {
+	// Proposals are checked the same way, so an unsigned transaction here
+	// would get every block built from it rejected
+	if err := validateTransaction(tx); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidTx, tx.ID, err)
+	}
+	if err := c.validateTxType(tx); err != nil {
+		return err
+	}
+	hash := tx.Hash()
+
+	c.votingMutex.Lock()
+	defer c.votingMutex.Unlock()
+
+	if _, pending := c.mempoolHashes[hash]; pending {
+		return fmt.Errorf("%w: %s is already pending", ErrDuplicateTx, hash)
+	}
+	committed, err := c.store.Get(context.Background(), txIndexKey(hash))
+	if err != nil {
+		return fmt.Errorf("failed to read transaction index: %w", err)
+	}
+	if committed != nil {
+		return fmt.Errorf("%w: %s is already committed", ErrDuplicateTx, hash)
+	}
+	// The hash covers the receive time, so a resubmitted signed body only
+	// shows up as a duplicate by its sender and ID
+	idKey := senderTxKey(tx.Sender, tx.ID)
+	if idKey != "" {
+		if _, pending := c.mempoolIDs[idKey]; pending {
+			return fmt.Errorf("%w: %s from %s is already pending", ErrDuplicateTx, tx.ID, tx.Sender)
+		}
+		committed, err := c.store.Get(context.Background(), txSenderIndexKey(idKey))
+		if err != nil {
+			return fmt.Errorf("failed to read transaction index: %w", err)
+		}
+		if committed != nil {
+			return fmt.Errorf("%w: %s from %s is already committed", ErrDuplicateTx, tx.ID, tx.Sender)
+		}
+	}
+	if len(c.mempool) >= c.maxMempoolSize {
+		return fmt.Errorf("%w: %d transactions pending", ErrMempoolFull, len(c.mempool))
+	}
+
+	c.mempool = append(c.mempool, tx)
+	c.mempoolHashes[hash] = struct{}{}
+	if idKey != "" {
+		c.mempoolIDs[idKey] = struct{}{}
+	}
+	logger.Debug("Added transaction to mempool", "tx", tx.ID)
+
+	c.publish(Event{Type: EventTx, Hash: hash})
+	return nil
+}
+
+// GetMempool returns current transactions in mempool
+func (c *Consensus) GetMempool() []*Transaction {
+	c.votingMutex.Lock()
+	defer c.votingMutex.Unlock()
+	return append([]*Transaction{}, c.mempool...)
+}
+
+// removeFromMempool drops the pending transactions included in txs, keeping the
+// order of the rest. A pending copy of an included transaction received by
+// another validator has its own receive time and hash, so entries are also
+// matched by sender and ID. Callers must hold votingMutex.
+func (c *Consensus) removeFromMempool(txs [][]byte) {
+	included := make(map[string]struct{}, len(txs))
+	includedIDs := make(map[string]struct{}, len(txs))
+	for _, txBytes := range txs {
+		included[hashTxBytes(txBytes)] = struct{}{}
+
+		var tx Transaction
+		if err := json.Unmarshal(txBytes, &tx); err != nil {
+			continue
+		}
+		if idKey := senderTxKey(tx.Sender, tx.ID); idKey != "" {
+			includedIDs[idKey] = struct{}{}
+		}
+	}
+
+	remaining := c.mempool[:0]
+	for _, tx := range c.mempool {
+		hash := tx.Hash()
+		idKey := senderTxKey(tx.Sender, tx.ID)
+		_, sameHash := included[hash]
+		_, sameID := includedIDs[idKey]
+		if sameHash || (idKey != "" && sameID) {
+			delete(c.mempoolHashes, hash)
+			delete(c.mempoolIDs, idKey)
+			continue
+		}
+		remaining = append(remaining, tx)
+	}
+	c.mempool = remaining
+}
+
+// selectTransactions encodes the transactions for the next block, highest
+// priority first and in arrival order within a priority. It stops at
+// maxBlockTxs and skips any transaction that would take the block past
+// maxBlockBytes, leaving it for a later block. Callers must hold votingMutex.
+func (c *Consensus) selectTransactions() [][]byte {
+	candidates := append([]*Transaction{}, c.mempool...)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Priority > candidates[j].Priority
+	})
+
+	var (
+		txs  [][]byte
+		size int
+	)
+	for _, tx := range candidates {
+		if len(txs) == c.maxBlockTxs {
+			break
+		}
+		txBytes, err := json.Marshal(tx)
+		if err != nil {
+			logger.Error("Failed to encode transaction", "tx", tx.ID, "err", err)
+			continue
+		}
+		if size+len(txBytes) > c.maxBlockBytes {
+			continue
+		}
+		txs = append(txs, txBytes)
+		size += len(txBytes)
+	}
+	return txs
+}
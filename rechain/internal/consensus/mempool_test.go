@@ -0,0 +1,238 @@
+package consensus
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rechain/rechain/internal/security"
+	"github.com/rechain/rechain/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClient and otherClient sign the transactions the tests submit
+var testClient, otherClient = newTestClient(), newTestClient()
+
+func newTestClient() *security.KeyManager {
+	km, err := security.NewKeyManagerWithAlgorithm(security.AlgorithmEd25519)
+	if err != nil {
+		panic(err)
+	}
+	return km
+}
+
+// signTx signs tx the way a client submits it and sets Sender to the key's identity
+func signTx(tx *Transaction, km *security.KeyManager) *Transaction {
+	publicKey, err := km.PublicKeyBytes()
+	if err != nil {
+		panic(err)
+	}
+	var payload map[string]interface{}
+	if len(tx.Payload) > 0 {
+		if err := json.Unmarshal(tx.Payload, &payload); err != nil {
+			panic(err)
+		}
+	}
+	data, err := security.SubmittedTxSigningBytes(tx.ID, tx.Type, payload, tx.Priority)
+	if err != nil {
+		panic(err)
+	}
+	if tx.Signature, err = km.SignData(data); err != nil {
+		panic(err)
+	}
+	tx.PublicKey = publicKey
+	tx.Sender = security.PublicKeyID(publicKey)
+	return tx
+}
+
+func testTx(id string) *Transaction {
+	return signTx(&Transaction{ID: id, Type: "test", Timestamp: time.Unix(1700000000, 0)}, testClient)
+}
+
+func TestMempoolRejectsDuplicates(t *testing.T) {
+	c, _ := newTestConsensus(t, "node-1")
+
+	require.NoError(t, c.AddTransaction(testTx("tx-1")))
+	err := c.AddTransaction(testTx("tx-1"))
+	require.ErrorIs(t, err, ErrDuplicateTx)
+	assert.Len(t, c.GetMempool(), 1)
+
+	// Once committed, the same transaction cannot be queued again
+	c.votingMutex.Lock()
+	c.height = 1
+	block := c.createProposal()
+	c.commitBlock(block)
+	c.votingMutex.Unlock()
+
+	require.ErrorIs(t, c.AddTransaction(testTx("tx-1")), ErrDuplicateTx)
+	assert.Empty(t, c.GetMempool())
+}
+
+func TestMempoolRejectsReusedSenderID(t *testing.T) {
+	c, _ := newTestConsensus(t, "node-1")
+
+	// A resubmission gets a new receive time, and so a new hash
+	replay := func() *Transaction {
+		tx := testTx("tx-1")
+		tx.Timestamp = tx.Timestamp.Add(time.Minute)
+		return tx
+	}
+
+	require.NoError(t, c.AddTransaction(testTx("tx-1")))
+	require.ErrorIs(t, c.AddTransaction(replay()), ErrDuplicateTx)
+
+	// Another sender may use the same ID
+	other := signTx(&Transaction{ID: "tx-1", Type: "test", Timestamp: time.Unix(1700000000, 0)}, otherClient)
+	require.NoError(t, c.AddTransaction(other))
+
+	c.votingMutex.Lock()
+	c.height = 1
+	block := c.createProposal()
+	c.commitBlock(block)
+	c.votingMutex.Unlock()
+
+	require.Empty(t, c.GetMempool())
+	require.ErrorIs(t, c.AddTransaction(replay()), ErrDuplicateTx)
+}
+
+func TestMempoolRejectsWhenFull(t *testing.T) {
+	c, err := NewConsensusWithConfig(storage.NewMemoryStore(), nil, &Config{
+		NodeID:         "node-1",
+		BlockInterval:  time.Hour,
+		Validators:     []Validator{{ID: "node-1", VotingPower: 1}},
+		MaxMempoolSize: 2,
+	})
+	require.NoError(t, err)
+	defer c.Stop()
+
+	require.NoError(t, c.AddTransaction(testTx("tx-1")))
+	require.NoError(t, c.AddTransaction(testTx("tx-2")))
+	require.ErrorIs(t, c.AddTransaction(testTx("tx-3")), ErrMempoolFull)
+	assert.Len(t, c.GetMempool(), 2)
+}
+
+func TestCommitRemovesOnlyIncludedTransactions(t *testing.T) {
+	c, _ := newTestConsensus(t, "node-1")
+	require.NoError(t, c.AddTransaction(testTx("tx-1")))
+	require.NoError(t, c.AddTransaction(testTx("tx-2")))
+
+	c.votingMutex.Lock()
+	c.height = 1
+	block := c.createProposal()
+	c.votingMutex.Unlock()
+
+	// Arrives after the block was proposed, so it must survive the commit
+	require.NoError(t, c.AddTransaction(testTx("tx-3")))
+
+	c.votingMutex.Lock()
+	c.commitBlock(block)
+	c.votingMutex.Unlock()
+
+	mempool := c.GetMempool()
+	require.Len(t, mempool, 1)
+	assert.Equal(t, "tx-3", mempool[0].ID)
+
+	// A committed transaction's slot is free again
+	require.NoError(t, c.AddTransaction(testTx("tx-4")))
+}
+
+func TestCommitRemovesCopiesWithAnotherReceiveTime(t *testing.T) {
+	c, _ := newTestConsensus(t, "node-1")
+
+	// This node received tx-1 a second after the proposer did
+	copied := testTx("tx-1")
+	copied.Timestamp = copied.Timestamp.Add(time.Second)
+	require.NoError(t, c.AddTransaction(copied))
+	require.NoError(t, c.AddTransaction(testTx("tx-2")))
+
+	txBytes, err := json.Marshal(testTx("tx-1"))
+	require.NoError(t, err)
+
+	c.votingMutex.Lock()
+	c.height = 1
+	c.commitBlock(&Block{Height: 1, Txs: [][]byte{txBytes}, TxRoot: txMerkleRoot([][]byte{txBytes})})
+	c.votingMutex.Unlock()
+
+	mempool := c.GetMempool()
+	require.Len(t, mempool, 1)
+	assert.Equal(t, "tx-2", mempool[0].ID)
+	assert.ErrorIs(t, c.AddTransaction(copied), ErrDuplicateTx)
+}
+
+func TestProposalCannotRepeatASenderID(t *testing.T) {
+	c, _ := newTestConsensus(t, "node-1")
+	require.NoError(t, c.AddTransaction(testTx("tx-1")))
+
+	c.votingMutex.Lock()
+	defer c.votingMutex.Unlock()
+	c.height = 1
+	c.commitBlock(c.createProposal())
+
+	proposal := func(txs ...*Transaction) *Proposal {
+		var encoded [][]byte
+		for _, tx := range txs {
+			txBytes, err := json.Marshal(tx)
+			require.NoError(t, err)
+			encoded = append(encoded, txBytes)
+		}
+		block := &Block{Height: c.height, Round: c.round, Txs: encoded, TxRoot: txMerkleRoot(encoded)}
+		return &Proposal{Block: block, Round: c.round, ProposerID: c.proposer(c.height, c.round)}
+	}
+
+	// The signed body of tx-1 is still valid, but its sender/ID pair is committed
+	replay := testTx("tx-1")
+	replay.Timestamp = replay.Timestamp.Add(time.Minute)
+	assert.False(t, c.validateProposal(proposal(replay)))
+
+	twice := testTx("tx-2")
+	twice.Timestamp = twice.Timestamp.Add(time.Minute)
+	assert.False(t, c.validateProposal(proposal(testTx("tx-2"), twice)))
+
+	assert.True(t, c.validateProposal(proposal(testTx("tx-2"))))
+}
+
+func TestProposalOrdersByPriorityAndRespectsLimits(t *testing.T) {
+	c, err := NewConsensusWithConfig(storage.NewMemoryStore(), nil, &Config{
+		NodeID:        "node-1",
+		BlockInterval: time.Hour,
+		Validators:    []Validator{{ID: "node-1", VotingPower: 1}},
+		MaxBlockTxs:   3,
+	})
+	require.NoError(t, err)
+	defer c.Stop()
+
+	for _, tx := range []*Transaction{
+		{ID: "low-1", Priority: 0},
+		{ID: "high-1", Priority: 10},
+		{ID: "mid-1", Priority: 5},
+		{ID: "high-2", Priority: 10},
+		{ID: "low-2", Priority: 0},
+	} {
+		tx.Type, tx.Timestamp = "test", time.Unix(1700000000, 0)
+		require.NoError(t, c.AddTransaction(signTx(tx, testClient)))
+	}
+
+	proposedIDs := func(block *Block) []string {
+		ids := make([]string, len(block.Txs))
+		for i, txBytes := range block.Txs {
+			var tx Transaction
+			require.NoError(t, json.Unmarshal(txBytes, &tx))
+			ids[i] = tx.ID
+		}
+		return ids
+	}
+
+	c.votingMutex.Lock()
+	block := c.createProposal()
+	c.votingMutex.Unlock()
+	assert.Equal(t, []string{"high-1", "high-2", "mid-1"}, proposedIDs(block))
+
+	// A byte limit that fits two transactions leaves the rest for a later block
+	c.votingMutex.Lock()
+	c.maxBlockTxs = DefaultMaxBlockTxs
+	c.maxBlockBytes = len(block.Txs[0]) + len(block.Txs[1])
+	block = c.createProposal()
+	c.votingMutex.Unlock()
+	assert.Equal(t, []string{"high-1", "high-2"}, proposedIDs(block))
+}
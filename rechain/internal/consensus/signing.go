@@ -0,0 +1,128 @@
+package consensus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/rechain/rechain/internal/security"
+)
+
+var (
+	// ErrInvalidVoteSignature is returned for a vote that is not signed by the validator it names
+	ErrInvalidVoteSignature = errors.New("invalid vote signature")
+	// ErrInvalidProposalSignature is returned for a proposal that is not signed by the validator it names
+	ErrInvalidProposalSignature = errors.New("invalid proposal signature")
+)
+
+// signingProbe is signed and verified at startup to check that the node's
+// signer matches its validator key
+var signingProbe = []byte("rechain vote signing key check")
+
+// SignBytes returns the bytes a validator signs for a vote: every field but the signature
+func (v *Vote) SignBytes() []byte {
+	data, _ := json.Marshal(struct {
+		Height   uint64   `json:"height"`
+		Round    int32    `json:"round"`
+		Type     VoteType `json:"type"`
+		BlockID  []byte   `json:"block_id"`
+		SenderID string   `json:"sender_id"`
+	}{v.Height, v.Round, v.Type, v.BlockID, v.SenderID})
+	return data
+}
+
+// signVote signs one of this node's votes
+func (c *Consensus) signVote(vote *Vote) error {
+	signature, err := c.sign(vote.SignBytes())
+	if err != nil {
+		return err
+	}
+	vote.Signature = signature
+	return nil
+}
+
+// verifyVote checks that a received vote is signed by the validator it names
+func (c *Consensus) verifyVote(vote *Vote) error {
+	if !c.validators.has(vote.SenderID) {
+		return fmt.Errorf("vote from unknown validator %q", vote.SenderID)
+	}
+	if err := c.verifySignedBy(vote.SenderID, vote.SignBytes(), vote.Signature); err != nil {
+		return fmt.Errorf("%w: vote from %s: %v", ErrInvalidVoteSignature, vote.SenderID, err)
+	}
+	return nil
+}
+
+// SignBytes returns the bytes a proposer signs for a proposal. The block hash
+// commits to the height, round, parent and, through the tx root, the transactions.
+func (p *Proposal) SignBytes() []byte {
+	data, _ := json.Marshal(struct {
+		Round      int32  `json:"round"`
+		BlockHash  []byte `json:"block_hash"`
+		ProposerID string `json:"proposer_id"`
+	}{p.Round, p.Block.Hash(), p.ProposerID})
+	return data
+}
+
+// signProposal signs one of this node's proposals
+func (c *Consensus) signProposal(proposal *Proposal) error {
+	signature, err := c.sign(proposal.SignBytes())
+	if err != nil {
+		return err
+	}
+	proposal.Signature = signature
+	return nil
+}
+
+// verifyProposal checks that a received proposal is signed by the validator it names
+func (c *Consensus) verifyProposal(proposal *Proposal) error {
+	if !c.validators.has(proposal.ProposerID) {
+		return fmt.Errorf("proposal from unknown validator %q", proposal.ProposerID)
+	}
+	if err := c.verifySignedBy(proposal.ProposerID, proposal.SignBytes(), proposal.Signature); err != nil {
+		return fmt.Errorf("%w: proposal from %s: %v", ErrInvalidProposalSignature, proposal.ProposerID, err)
+	}
+	return nil
+}
+
+// sign signs data with the node key. A node without a signer is the only
+// validator, so its messages never leave it and stay unsigned.
+func (c *Consensus) sign(data []byte) ([]byte, error) {
+	if c.config.Signer == nil {
+		return nil, nil
+	}
+	return c.config.Signer.SignData(data)
+}
+
+// verifySignedBy checks signature over data against the key of validator id
+func (c *Consensus) verifySignedBy(id string, data, signature []byte) error {
+	key := c.validators.publicKey(id)
+	if key == nil {
+		return fmt.Errorf("validator %s has no public key", id)
+	}
+	if len(signature) == 0 {
+		return errors.New("unsigned")
+	}
+	return security.VerifyWithPublicKey(key, data, signature)
+}
+
+// checkSigningKeys makes sure a networked node can verify every validator's
+// votes and proposals and sign its own with the key the others hold for it
+func checkSigningKeys(validators *validatorSet, nodeID string, signer *security.KeyManager) error {
+	for _, v := range validators.validators {
+		if validators.publicKey(v.ID) == nil {
+			return fmt.Errorf("validator %s has no public key; networked validators must all have one", v.ID)
+		}
+	}
+	if signer == nil {
+		return fmt.Errorf("a signer is required to vote over a transport")
+	}
+
+	signature, err := signer.SignData(signingProbe)
+	if err != nil {
+		return fmt.Errorf("failed to sign with the node key: %w", err)
+	}
+	if err := security.VerifyWithPublicKey(validators.publicKey(nodeID), signingProbe, signature); err != nil {
+		return fmt.Errorf("node key does not match the public key of validator %s", nodeID)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package consensus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// StateProvider supplies the application state captured in snapshots, such as
+// the CRDT catalog replicated over gossip
+type StateProvider interface {
+	SnapshotState(ctx context.Context) ([]byte, error)
+}
+
+// StateProviderFunc adapts a function to a StateProvider
+type StateProviderFunc func(ctx context.Context) ([]byte, error)
+
+// SnapshotState calls f
+func (f StateProviderFunc) SnapshotState(ctx context.Context) ([]byte, error) {
+	return f(ctx)
+}
+
+// StateSnapshot is the application state at a committed height together with
+// the chain it belongs to. A new node can fast-sync from it instead of
+// replaying every block.
+type StateSnapshot struct {
+	Height      uint64
+	BlockHash   []byte
+	GenesisHash []byte
+	State       []byte
+	TakenAt     time.Time
+}
+
+func snapshotKey(height uint64) []byte {
+	return []byte(fmt.Sprintf("snapshot/%d", height))
+}
+
+func blockHeaderKey(height uint64) []byte {
+	return []byte(fmt.Sprintf("block-header/%d", height))
+}
+
+// GetSnapshotAtHeight returns the snapshot taken at the given height, or nil if
+// there is none
+func (c *Consensus) GetSnapshotAtHeight(ctx context.Context, height uint64) (*StateSnapshot, error) {
+	data, err := c.store.Get(ctx, snapshotKey(height))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %d: %w", height, err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var snapshot StateSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot %d: %w", height, err)
+	}
+	return &snapshot, nil
+}
+
+// LatestSnapshot returns the most recent snapshot, or nil before the first one
+func (c *Consensus) LatestSnapshot(ctx context.Context) (*StateSnapshot, error) {
+	height, ok, err := c.readHeight(ctx, "latest-snapshot")
+	if err != nil || !ok {
+		return nil, err
+	}
+	return c.GetSnapshotAtHeight(ctx, height)
+}
+
+// GetBlockHeader returns the block at height without its transactions. It is
+// available for pruned blocks as well as for those still stored in full.
+func (c *Consensus) GetBlockHeader(ctx context.Context, height uint64) (*Block, error) {
+	for _, key := range [][]byte{blockHeaderKey(height), []byte(fmt.Sprintf("block/%d", height))} {
+		data, err := c.store.Get(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read block %d: %w", height, err)
+		}
+		if data == nil {
+			continue
+		}
+
+		var block Block
+		if err := json.Unmarshal(data, &block); err != nil {
+			return nil, fmt.Errorf("failed to decode block %d: %w", height, err)
+		}
+		block.Txs = nil
+		return &block, nil
+	}
+	return nil, nil
+}
+
+// maintain takes a snapshot and prunes old block bodies after a commit, as
+// configured by SnapshotInterval and PruneRetention. Callers must hold votingMutex.
+func (c *Consensus) maintain(block *Block) {
+	ctx := context.Background()
+
+	if c.config.SnapshotInterval > 0 && block.Height%c.config.SnapshotInterval == 0 {
+		if err := c.takeSnapshot(ctx, block); err != nil {
+			logger.Error("Failed to take state snapshot", "height", block.Height, "err", err)
+		}
+	}
+	if c.config.PruneRetention > 0 && block.Height > c.config.PruneRetention {
+		if err := c.pruneBelow(ctx, block.Height-c.config.PruneRetention+1); err != nil {
+			logger.Error("Failed to prune blocks", "height", block.Height, "err", err)
+		}
+	}
+}
+
+// takeSnapshot stores the application state as of a just-committed block
+func (c *Consensus) takeSnapshot(ctx context.Context, block *Block) error {
+	previous, hadPrevious, err := c.readHeight(ctx, "latest-snapshot")
+	if err != nil {
+		return err
+	}
+
+	var state []byte
+	if c.config.StateProvider != nil {
+		if state, err = c.config.StateProvider.SnapshotState(ctx); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(&StateSnapshot{
+		Height:      block.Height,
+		BlockHash:   block.Hash(),
+		GenesisHash: c.genesisHash,
+		State:       state,
+		TakenAt:     time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := c.store.Set(ctx, snapshotKey(block.Height), data); err != nil {
+		return err
+	}
+	if err := c.store.Set(ctx, []byte("latest-snapshot"), []byte(strconv.FormatUint(block.Height, 10))); err != nil {
+		return err
+	}
+	logger.Info("Took state snapshot", "height", block.Height, "bytes", len(state))
+
+	// The previous snapshot was kept by pruning only while it was the latest
+	if hadPrevious && c.config.PruneRetention > 0 && previous+c.config.PruneRetention <= block.Height {
+		return c.store.Delete(ctx, snapshotKey(previous))
+	}
+	return nil
+}
+
+// pruneBelow replaces the bodies of blocks 1 to cutoff-1 with their headers and
+// drops their transaction index entries and any snapshots other than the latest.
+// Block hashes are kept so the chain and tx roots can still be checked. The
+// genesis block is never pruned. pruned-height records the progress, so each
+// block is only visited once.
+func (c *Consensus) pruneBelow(ctx context.Context, cutoff uint64) error {
+	next, ok, err := c.readHeight(ctx, "pruned-height")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		next = 1
+	}
+	latestSnapshot, _, err := c.readHeight(ctx, "latest-snapshot")
+	if err != nil {
+		return err
+	}
+
+	for ; next < cutoff; next++ {
+		if err := c.pruneBlock(ctx, next); err != nil {
+			return fmt.Errorf("block %d: %w", next, err)
+		}
+		if next != latestSnapshot {
+			if err := c.store.Delete(ctx, snapshotKey(next)); err != nil {
+				return fmt.Errorf("snapshot %d: %w", next, err)
+			}
+		}
+		if err := c.store.Set(ctx, []byte("pruned-height"), []byte(strconv.FormatUint(next+1, 10))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneBlock stores the header of one block and deletes its body
+func (c *Consensus) pruneBlock(ctx context.Context, height uint64) error {
+	key := []byte(fmt.Sprintf("block/%d", height))
+	data, err := c.store.Get(ctx, key)
+	if err != nil || data == nil {
+		return err // already pruned or never stored
+	}
+
+	var block Block
+	if err := json.Unmarshal(data, &block); err != nil {
+		return err
+	}
+	for _, txBytes := range block.Txs {
+		if err := c.store.Delete(ctx, txIndexKey(hashTxBytes(txBytes))); err != nil {
+			return err
+		}
+	}
+
+	block.Txs = nil
+	header, err := json.Marshal(&block)
+	if err != nil {
+		return err
+	}
+	if err := c.store.Set(ctx, blockHeaderKey(height), header); err != nil {
+		return err
+	}
+	return c.store.Delete(ctx, key)
+}
+
+// readHeight parses a height stored under key, reporting whether it was set
+func (c *Consensus) readHeight(ctx context.Context, key string) (uint64, bool, error) {
+	data, err := c.store.Get(ctx, []byte(key))
+	if err != nil || data == nil {
+		return 0, false, err
+	}
+	height, err := strconv.ParseUint(string(data), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("corrupt %s: %w", key, err)
+	}
+	return height, true, nil
+}
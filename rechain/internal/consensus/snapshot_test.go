@@ -0,0 +1,89 @@
+package consensus
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rechain/rechain/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotsAndPruning(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	var snapshots int
+	c, err := NewConsensusWithConfig(store, nil, &Config{
+		NodeID:           "node-1",
+		BlockInterval:    time.Hour, // heights are advanced by hand below
+		Validators:       []Validator{{ID: "node-1", VotingPower: 1}},
+		SnapshotInterval: 5,
+		PruneRetention:   3,
+		StateProvider: StateProviderFunc(func(ctx context.Context) ([]byte, error) {
+			snapshots++
+			return []byte(fmt.Sprintf("state-%d", snapshots)), nil
+		}),
+	})
+	require.NoError(t, err)
+	defer c.Stop()
+
+	// A single validator commits each height as soon as it starts
+	require.NoError(t, c.AddTransaction(testTx("tx-early")))
+	require.NoError(t, c.Start())
+	for c.Height() < 12 {
+		c.votingMutex.Lock()
+		c.startNewHeight()
+		c.votingMutex.Unlock()
+	}
+	require.NoError(t, c.AddTransaction(testTx("tx-late")))
+	c.votingMutex.Lock()
+	c.startNewHeight()
+	c.votingMutex.Unlock()
+
+	// Heights 13, 12 and 11 keep their bodies; older ones only their headers
+	for height := uint64(1); height <= 13; height++ {
+		body, err := store.Get(ctx, []byte(fmt.Sprintf("block/%d", height)))
+		require.NoError(t, err)
+		assert.Equal(t, height >= 11, body != nil, "body of block %d", height)
+
+		header, err := c.GetBlockHeader(ctx, height)
+		require.NoError(t, err)
+		require.NotNil(t, header, "header of block %d", height)
+		assert.Equal(t, height, header.Height)
+		assert.Nil(t, header.Txs)
+
+		hash, err := store.Get(ctx, []byte(fmt.Sprintf("block-hash/%d", height)))
+		require.NoError(t, err)
+		assert.Equal(t, hash, header.Hash(), "header of block %d must still match its hash", height)
+	}
+	genesis, err := store.Get(ctx, []byte("block/0"))
+	require.NoError(t, err)
+	assert.NotNil(t, genesis, "the genesis block is never pruned")
+
+	// Transactions in pruned blocks are no longer indexed; recent ones still prove
+	status, err := c.GetTxStatus(ctx, testTx("tx-early").Hash())
+	require.NoError(t, err)
+	assert.Nil(t, status)
+	proof, err := c.GetTxProof(ctx, testTx("tx-late").Hash())
+	require.NoError(t, err)
+	require.NotNil(t, proof)
+	assert.True(t, proof.Verify())
+
+	// Snapshots were taken at 5 and 10; only the latest survives pruning
+	old, err := c.GetSnapshotAtHeight(ctx, 5)
+	require.NoError(t, err)
+	assert.Nil(t, old)
+
+	latest, err := c.LatestSnapshot(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, latest)
+	assert.Equal(t, uint64(10), latest.Height)
+	assert.Equal(t, []byte("state-2"), latest.State)
+	assert.Equal(t, c.GenesisHash(), latest.GenesisHash)
+
+	header, err := c.GetBlockHeader(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, header.Hash(), latest.BlockHash)
+}
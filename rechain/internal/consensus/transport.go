@@ -0,0 +1,93 @@
+package consensus
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Message codes for consensus traffic on the P2P transport
+const (
+	ProposalMsg uint64 = 0x10
+	VoteMsg     uint64 = 0x11
+)
+
+// Transport delivers consensus messages between validators. gcl.P2PServer implements it.
+type Transport interface {
+	// SendMessage broadcasts a payload to all connected peers
+	SendMessage(code uint64, payload []byte) error
+	// HandleMessage registers the handler for inbound messages with the given code
+	HandleMessage(code uint64, handler func(payload []byte))
+}
+
+type outboundMessage struct {
+	code    uint64
+	payload []byte
+}
+
+// broadcast queues a message for the transport. It never blocks on network I/O,
+// so it is safe to call while holding votingMutex.
+func (c *Consensus) broadcast(code uint64, msg interface{}) {
+	if c.transport == nil {
+		return // single-node mode
+	}
+
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		logger.Error("Failed to serialize consensus message", "err", err)
+		return
+	}
+
+	select {
+	case c.outbox <- outboundMessage{code: code, payload: payload}:
+	default:
+		logger.Warn("Consensus outbox full, dropping message", "code", fmt.Sprintf("%#x", code))
+	}
+}
+
+// sendLoop drains the outbox onto the transport
+func (c *Consensus) sendLoop() {
+	for {
+		select {
+		case <-c.quit:
+			return
+		case msg := <-c.outbox:
+			if err := c.transport.SendMessage(msg.code, msg.payload); err != nil {
+				logger.Warn("Failed to send consensus message", "code", fmt.Sprintf("%#x", msg.code), "err", err)
+			}
+		}
+	}
+}
+
+// receiveProposal feeds a signed proposal from a peer into the consensus loop
+func (c *Consensus) receiveProposal(payload []byte) {
+	var proposal Proposal
+	if err := json.Unmarshal(payload, &proposal); err != nil || proposal.Block == nil {
+		logger.Warn("Dropping malformed proposal", "err", err)
+		return
+	}
+
+	// Only the signed proposal of a validator may move the round or get a
+	// prevote; nobody else can speak for the expected proposer
+	if err := c.verifyProposal(&proposal); err != nil {
+		logger.Warn("Rejected proposal", "validator", proposal.ProposerID, "err", err)
+		return
+	}
+
+	select {
+	case c.proposals <- &proposal:
+	case <-c.quit:
+	}
+}
+
+// receiveVote feeds a vote from a peer into the vote tally
+func (c *Consensus) receiveVote(payload []byte) {
+	var vote Vote
+	if err := json.Unmarshal(payload, &vote); err != nil {
+		logger.Warn("Dropping malformed vote", "err", err)
+		return
+	}
+
+	if err := c.AddVote(&vote); err != nil {
+		logger.Warn("Rejected vote", "validator", vote.SenderID, "err", err)
+	}
+}
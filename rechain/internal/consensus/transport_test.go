@@ -0,0 +1,211 @@
+package consensus
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rechain/rechain/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memNetwork connects memTransports in-process
+type memNetwork struct {
+	mu      sync.Mutex
+	members []*memTransport
+}
+
+type memTransport struct {
+	network  *memNetwork
+	mu       sync.RWMutex
+	handlers map[uint64]func(payload []byte)
+}
+
+func (n *memNetwork) join() *memTransport {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	t := &memTransport{network: n, handlers: make(map[uint64]func(payload []byte))}
+	n.members = append(n.members, t)
+	return t
+}
+
+func (t *memTransport) HandleMessage(code uint64, handler func(payload []byte)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handlers[code] = handler
+}
+
+// SendMessage delivers asynchronously to every other member, like a real network
+func (t *memTransport) SendMessage(code uint64, payload []byte) error {
+	t.network.mu.Lock()
+	members := append([]*memTransport{}, t.network.members...)
+	t.network.mu.Unlock()
+
+	for _, member := range members {
+		if member == t {
+			continue
+		}
+		member.mu.RLock()
+		handler := member.handlers[code]
+		member.mu.RUnlock()
+		if handler != nil {
+			go handler(append([]byte{}, payload...))
+		}
+	}
+	return nil
+}
+
+func TestTwoNodesReachConsensusOverTransport(t *testing.T) {
+	network := &memNetwork{}
+	validators := keyedValidators("node-1", "node-2")
+
+	nodes := make([]*Consensus, len(validators))
+	stores := make([]*storage.MemoryStore, len(validators))
+	for i, v := range validators {
+		stores[i] = storage.NewMemoryStore()
+		c, err := NewConsensusWithConfig(stores[i], network.join(), &Config{
+			NodeID:        v.ID,
+			BlockInterval: time.Hour,
+			Timeout:       time.Hour,
+			Validators:    validators,
+			Signer:        testKeys[v.ID],
+		})
+		require.NoError(t, err)
+		c.timeoutPrevote = time.Hour
+		c.timeoutPrecommit = time.Hour
+		defer c.Stop()
+		nodes[i] = c
+	}
+
+	// node-2 proposes height 1; start node-1 first so it is ready for the proposal
+	require.NoError(t, nodes[1].AddTransaction(testTx("tx-1")))
+	require.NoError(t, nodes[0].Start())
+	require.NoError(t, nodes[1].Start())
+
+	var hashes [2][]byte
+	for i, store := range stores {
+		var data []byte
+		require.Eventually(t, func() bool {
+			data, _ = store.Get(context.Background(), []byte("block/1"))
+			return data != nil
+		}, 5*time.Second, 10*time.Millisecond, "node %s did not commit height 1", validators[i].ID)
+
+		var block Block
+		require.NoError(t, json.Unmarshal(data, &block))
+		assert.Len(t, block.Txs, 1)
+		hashes[i] = block.Hash()
+	}
+	assert.Equal(t, hashes[0], hashes[1])
+}
+
+func TestNilTransportDoesNotBroadcast(t *testing.T) {
+	c, _ := newTestConsensus(t, "node-1")
+
+	c.votingMutex.Lock()
+	c.broadcastVote(&Vote{Height: 1, Type: Prevote, SenderID: "node-1"})
+	c.votingMutex.Unlock()
+
+	assert.Empty(t, c.outbox)
+}
+
+func TestSilentProposerIsSkippedByRoundTimeout(t *testing.T) {
+	network := &memNetwork{}
+	validators := testValidators
+
+	schedule, err := newValidatorSet(validators)
+	require.NoError(t, err)
+	silent := schedule.proposer(1, 0)
+
+	// Every validator but the round-0 proposer runs; three of four is still a quorum
+	var nodes []*Consensus
+	var stores []*storage.MemoryStore
+	for _, v := range validators {
+		if v.ID == silent {
+			continue
+		}
+		store := storage.NewMemoryStore()
+		c, err := NewConsensusWithConfig(store, network.join(), &Config{
+			NodeID:        v.ID,
+			BlockInterval: time.Hour, // stop after height 1
+			Timeout:       50 * time.Millisecond,
+			Validators:    validators,
+			Signer:        testKeys[v.ID],
+		})
+		require.NoError(t, err)
+		c.timeoutPrevote = 50 * time.Millisecond
+		c.timeoutPrecommit = 50 * time.Millisecond
+		defer c.Stop()
+		nodes = append(nodes, c)
+		stores = append(stores, store)
+	}
+	for _, c := range nodes {
+		require.NoError(t, c.Start())
+	}
+
+	var hashes [][]byte
+	for i, store := range stores {
+		var data []byte
+		require.Eventually(t, func() bool {
+			data, _ = store.Get(context.Background(), []byte("block/1"))
+			return data != nil
+		}, 10*time.Second, 10*time.Millisecond, "node %s did not commit height 1", nodes[i].config.NodeID)
+
+		var block Block
+		require.NoError(t, json.Unmarshal(data, &block))
+		assert.Greater(t, block.Round, int32(0), "round 0 had no proposal")
+		assert.NotEqual(t, silent, schedule.proposer(1, block.Round))
+		hashes = append(hashes, block.Hash())
+	}
+	for _, hash := range hashes[1:] {
+		assert.Equal(t, hashes[0], hash)
+	}
+
+	for _, c := range nodes {
+		assert.NotZero(t, c.MissedProposals()[silent], "node %s should count the missed proposal", c.config.NodeID)
+	}
+}
+
+func TestRoundTimeoutBackoff(t *testing.T) {
+	c, _ := newTestConsensus(t, "node-1")
+
+	c.votingMutex.Lock()
+	defer c.votingMutex.Unlock()
+	for round, want := range map[int32]time.Duration{0: time.Second, 1: 2 * time.Second, 3: 8 * time.Second, 20: 64 * time.Second} {
+		c.round = round
+		assert.Equal(t, want, c.roundTimeout(time.Second), "round %d", round)
+	}
+}
+
+func TestForgedProposalIsDropped(t *testing.T) {
+	c, _ := newTestConsensus(t, "node-1")
+
+	block := &Block{Height: 1, Round: 5, Timestamp: time.Now()}
+	proposer := c.validators.proposer(1, 5)
+	receive := func(proposal *Proposal) {
+		data, err := json.Marshal(proposal)
+		require.NoError(t, err)
+		c.receiveProposal(data)
+	}
+
+	// Neither an unsigned proposal nor one signed by another validator
+	// reaches the loop, where it could move the round or draw a prevote
+	receive(&Proposal{Block: block, Round: 5, ProposerID: proposer})
+
+	forger := "node-3"
+	if proposer == forger {
+		forger = "node-4"
+	}
+	forged := &Proposal{Block: block, Round: 5, ProposerID: proposer}
+	forged.Signature, _ = testKeys[forger].SignData(forged.SignBytes())
+	receive(forged)
+	assert.Empty(t, c.proposals)
+
+	signed := &Proposal{Block: block, Round: 5, ProposerID: proposer}
+	signed.Signature, _ = testKeys[proposer].SignData(signed.SignBytes())
+	receive(signed)
+	assert.Len(t, c.proposals, 1)
+}
@@ -0,0 +1,118 @@
+package consensus
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// TxLocation records where a committed transaction is stored
+type TxLocation struct {
+	Height uint64 `json:"height"`
+	Index  int    `json:"index"`
+}
+
+// Hash returns the hex-encoded SHA-256 of the transaction as it is encoded in a block
+func (tx *Transaction) Hash() string {
+	txBytes, _ := json.Marshal(tx)
+	return hashTxBytes(txBytes)
+}
+
+func hashTxBytes(txBytes []byte) string {
+	hash := sha256.Sum256(txBytes)
+	return hex.EncodeToString(hash[:])
+}
+
+func txIndexKey(hash string) []byte {
+	return []byte(fmt.Sprintf("tx/%s", hash))
+}
+
+// senderTxKey identifies a transaction by its sender and the ID the sender
+// chose, which each sender may use once. It is "" unless both are set.
+func senderTxKey(sender, id string) string {
+	if sender == "" || id == "" {
+		return ""
+	}
+	return sender + "/" + id
+}
+
+// txSenderIndexKey records that a sender/ID pair was committed. These entries
+// survive pruning, so a replayed transaction stays rejected.
+func txSenderIndexKey(idKey string) []byte {
+	return []byte(fmt.Sprintf("tx-sender/%s", idKey))
+}
+
+// indexTransactions points tx/<hash> at the height and position of each
+// transaction in a committed block, and tx-sender/<sender>/<id> at its hash
+func (c *Consensus) indexTransactions(block *Block) {
+	for i, txBytes := range block.Txs {
+		hash := hashTxBytes(txBytes)
+		location, _ := json.Marshal(TxLocation{Height: block.Height, Index: i})
+		if err := c.store.Set(context.Background(), txIndexKey(hash), location); err != nil {
+			logger.Error("Failed to index transaction", "index", i, "height", block.Height, "err", err)
+		}
+
+		var tx Transaction
+		if err := json.Unmarshal(txBytes, &tx); err != nil {
+			continue
+		}
+		if idKey := senderTxKey(tx.Sender, tx.ID); idKey != "" {
+			if err := c.store.Set(context.Background(), txSenderIndexKey(idKey), []byte(hash)); err != nil {
+				logger.Error("Failed to index transaction sender", "index", i, "height", block.Height, "err", err)
+			}
+		}
+	}
+}
+
+// GetTransaction looks up a committed transaction by hash. It returns nil if the
+// transaction has not been committed.
+func (c *Consensus) GetTransaction(ctx context.Context, hash string) (*Transaction, *TxLocation, error) {
+	block, location, err := c.lookupTransaction(ctx, hash)
+	if err != nil || block == nil {
+		return nil, nil, err
+	}
+
+	var tx Transaction
+	if err := json.Unmarshal(block.Txs[location.Index], &tx); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode transaction %s: %w", hash, err)
+	}
+
+	return &tx, location, nil
+}
+
+// lookupTransaction returns the committed block holding a transaction and the
+// transaction's position in it, or nil if the transaction has not been committed
+func (c *Consensus) lookupTransaction(ctx context.Context, hash string) (*Block, *TxLocation, error) {
+	data, err := c.store.Get(ctx, txIndexKey(hash))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read transaction index: %w", err)
+	}
+	if data == nil {
+		return nil, nil, nil
+	}
+
+	var location TxLocation
+	if err := json.Unmarshal(data, &location); err != nil {
+		return nil, nil, fmt.Errorf("corrupt index entry for transaction %s: %w", hash, err)
+	}
+
+	blockData, err := c.store.Get(ctx, []byte(fmt.Sprintf("block/%d", location.Height)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read block %d: %w", location.Height, err)
+	}
+	if blockData == nil {
+		return nil, nil, fmt.Errorf("indexed block %d for transaction %s is missing", location.Height, hash)
+	}
+
+	var block Block
+	if err := json.Unmarshal(blockData, &block); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode block %d: %w", location.Height, err)
+	}
+	if location.Index >= len(block.Txs) {
+		return nil, nil, fmt.Errorf("transaction %s index %d out of range for block %d", hash, location.Index, location.Height)
+	}
+
+	return &block, &location, nil
+}
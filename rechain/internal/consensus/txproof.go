@@ -0,0 +1,185 @@
+package consensus
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Transaction statuses reported by GetTxStatus. A transaction is committed once
+// it is in a block and confirmed once another block has been committed on top.
+const (
+	TxPending   = "pending"
+	TxCommitted = "committed"
+	TxConfirmed = "confirmed"
+)
+
+// TxStatus describes how far a transaction has progressed towards finality
+type TxStatus struct {
+	Hash          string `json:"hash"`
+	Status        string `json:"status"`
+	Height        uint64 `json:"height,omitempty"`
+	Index         int    `json:"index"`
+	Confirmations uint64 `json:"confirmations"`
+}
+
+// ProofStep is one sibling hash on the path from a transaction to the tx root
+type ProofStep struct {
+	Hash []byte `json:"hash"`
+	Left bool   `json:"left"` // the sibling is hashed on the left
+}
+
+// TxProof shows that a transaction is included in the block at Height
+type TxProof struct {
+	TxHash string      `json:"tx_hash"`
+	Height uint64      `json:"height"`
+	Index  int         `json:"index"`
+	TxRoot []byte      `json:"tx_root"`
+	Steps  []ProofStep `json:"steps"`
+}
+
+// Verify recomputes the tx root from the transaction hash and the proof steps
+func (p *TxProof) Verify() bool {
+	leaf, err := hex.DecodeString(p.TxHash)
+	if err != nil {
+		return false
+	}
+
+	current := leaf
+	for _, step := range p.Steps {
+		if step.Left {
+			current = hashPair(step.Hash, current)
+		} else {
+			current = hashPair(current, step.Hash)
+		}
+	}
+	return bytes.Equal(current, p.TxRoot)
+}
+
+// GetTxStatus reports whether a transaction is pending or committed. It returns
+// nil if the transaction is neither in the mempool nor in a committed block.
+func (c *Consensus) GetTxStatus(ctx context.Context, hash string) (*TxStatus, error) {
+	_, location, err := c.GetTransaction(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if location == nil {
+		for _, pending := range c.GetMempool() {
+			if pending.Hash() == hash {
+				return &TxStatus{Hash: hash, Status: TxPending}, nil
+			}
+		}
+		return nil, nil
+	}
+
+	status := &TxStatus{Hash: hash, Status: TxCommitted, Height: location.Height, Index: location.Index}
+	if latest, err := c.latestHeight(ctx); err == nil && latest >= location.Height {
+		status.Confirmations = latest - location.Height + 1
+	}
+	if status.Confirmations > 1 {
+		status.Status = TxConfirmed
+	}
+	return status, nil
+}
+
+// GetTxProof returns an inclusion proof for a committed transaction against the
+// tx root of its block. It returns nil if the transaction has not been committed.
+func (c *Consensus) GetTxProof(ctx context.Context, hash string) (*TxProof, error) {
+	block, location, err := c.lookupTransaction(ctx, hash)
+	if err != nil || block == nil {
+		return nil, err
+	}
+
+	return block.proveTxAt(location.Index), nil
+}
+
+// ProveTx returns an inclusion proof for the transaction with the given hash
+// against the block's tx root, or nil if the block does not contain it
+func (b *Block) ProveTx(hash string) *TxProof {
+	for i, txBytes := range b.Txs {
+		if hashTxBytes(txBytes) == hash {
+			return b.proveTxAt(i)
+		}
+	}
+	return nil
+}
+
+func (b *Block) proveTxAt(index int) *TxProof {
+	return &TxProof{
+		TxHash: hashTxBytes(b.Txs[index]),
+		Height: b.Height,
+		Index:  index,
+		TxRoot: b.TxRoot,
+		Steps:  txMerklePath(b.Txs, index),
+	}
+}
+
+// latestHeight returns the height of the most recently committed block, or 0 before the first commit
+func (c *Consensus) latestHeight(ctx context.Context) (uint64, error) {
+	height, _, err := c.readHeight(ctx, "latest-height")
+	return height, err
+}
+
+// txMerkleRoot computes the Merkle root over the hashes of txs. Leaves keep the
+// order of the transactions in the block, so reordering them changes the root.
+// An odd node at any level is paired with itself; a block without transactions
+// has an all-zero root.
+func txMerkleRoot(txs [][]byte) []byte {
+	if len(txs) == 0 {
+		return make([]byte, 32)
+	}
+
+	level := txLeaves(txs)
+	for len(level) > 1 {
+		level = nextMerkleLevel(level)
+	}
+	return level[0]
+}
+
+// txMerklePath returns the sibling hashes from the transaction at index up to the root
+func txMerklePath(txs [][]byte, index int) []ProofStep {
+	var steps []ProofStep
+
+	level := txLeaves(txs)
+	for len(level) > 1 {
+		sibling := index ^ 1
+		if sibling >= len(level) {
+			sibling = index // odd node, paired with itself
+		}
+		steps = append(steps, ProofStep{Hash: level[sibling], Left: sibling < index})
+
+		level = nextMerkleLevel(level)
+		index /= 2
+	}
+	return steps
+}
+
+func txLeaves(txs [][]byte) [][]byte {
+	leaves := make([][]byte, len(txs))
+	for i, tx := range txs {
+		hash := sha256.Sum256(tx)
+		leaves[i] = hash[:]
+	}
+	return leaves
+}
+
+func nextMerkleLevel(level [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		right := level[i]
+		if i+1 < len(level) {
+			right = level[i+1]
+		}
+		next = append(next, hashPair(level[i], right))
+	}
+	return next
+}
+
+func hashPair(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
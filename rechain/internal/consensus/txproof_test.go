@@ -0,0 +1,78 @@
+package consensus
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTxs returns n encoded transactions the way createProposal lays them out
+func testTxs(t *testing.T, n int) [][]byte {
+	t.Helper()
+
+	txs := make([][]byte, n)
+	for i := range txs {
+		data, err := json.Marshal(&Transaction{ID: fmt.Sprintf("tx-%d", i), Type: "test", Sender: "client"})
+		require.NoError(t, err)
+		txs[i] = data
+	}
+	return txs
+}
+
+func TestTxRootIsStable(t *testing.T) {
+	txs := testTxs(t, 5)
+
+	root := txMerkleRoot(txs)
+	assert.Equal(t, root, txMerkleRoot(testTxs(t, 5)), "the same transactions must give the same root")
+
+	swapped := append([][]byte{}, txs...)
+	swapped[0], swapped[1] = swapped[1], swapped[0]
+	assert.NotEqual(t, root, txMerkleRoot(swapped), "reordering transactions must change the root")
+
+	// The block hash commits to the transactions through the root
+	block := &Block{Height: 1, Txs: txs, TxRoot: root}
+	reordered := &Block{Height: 1, Txs: swapped, TxRoot: txMerkleRoot(swapped)}
+	assert.NotEqual(t, block.Hash(), reordered.Hash())
+
+	assert.Equal(t, make([]byte, 32), txMerkleRoot(nil))
+}
+
+func TestTxInclusionProofsVerify(t *testing.T) {
+	for n := 1; n <= 7; n++ {
+		txs := testTxs(t, n)
+		block := &Block{Height: 3, Txs: txs, TxRoot: txMerkleRoot(txs)}
+
+		for i, txBytes := range txs {
+			proof := block.ProveTx(hashTxBytes(txBytes))
+			require.NotNil(t, proof, "tx %d of %d", i, n)
+			assert.Equal(t, i, proof.Index)
+			assert.Equal(t, uint64(3), proof.Height)
+			assert.True(t, proof.Verify(), "tx %d of %d", i, n)
+
+			// A proof for one transaction does not prove another
+			proof.TxHash = hashTxBytes([]byte("other"))
+			assert.False(t, proof.Verify(), "tx %d of %d", i, n)
+		}
+	}
+
+	block := &Block{Txs: testTxs(t, 2)}
+	assert.Nil(t, block.ProveTx(hashTxBytes([]byte("missing"))))
+}
+
+func TestRejectsProposalWithWrongTxRoot(t *testing.T) {
+	c, _ := newTestConsensus(t, "node-1")
+	require.NoError(t, c.AddTransaction(testTx("tx-1")))
+	require.NoError(t, c.Start())
+
+	c.votingMutex.Lock()
+	block := c.createProposal()
+	c.votingMutex.Unlock()
+	block.TxRoot = txMerkleRoot(nil)
+
+	c.handleProposal(&Proposal{Block: block, Round: 0, ProposerID: "node-2"})
+	assert.Equal(t, StepPropose, c.step)
+	assert.Nil(t, c.proposal)
+}
@@ -0,0 +1,124 @@
+package consensus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrUnknownTxType is returned for a transaction whose type has no registered handler
+	ErrUnknownTxType = errors.New("unknown transaction type")
+	// ErrInvalidTx is returned for a transaction its handler refuses
+	ErrInvalidTx = errors.New("invalid transaction")
+)
+
+// TxHandler gives a transaction type its meaning. ValidateTx runs when a
+// transaction is submitted and again when it is proposed, and must not depend
+// on application state, which may differ between the two. ApplyTx runs once
+// the block holding the transaction is committed and applies its effect, such
+// as adding a snapshot to the CRDT catalog. ApplyTx is called while the engine
+// commits the block and must not call back into it.
+type TxHandler interface {
+	ValidateTx(tx *Transaction) error
+	ApplyTx(ctx context.Context, tx *Transaction) error
+}
+
+// TxRegistry maps transaction types to their handlers
+type TxRegistry struct {
+	mu       sync.RWMutex
+	handlers map[string]TxHandler
+}
+
+// NewTxRegistry creates an empty registry
+func NewTxRegistry() *TxRegistry {
+	return &TxRegistry{handlers: make(map[string]TxHandler)}
+}
+
+// Register sets the handler for a transaction type. A type can be registered once.
+func (r *TxRegistry) Register(txType string, handler TxHandler) error {
+	if txType == "" {
+		return fmt.Errorf("transaction type must not be empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.handlers[txType]; exists {
+		return fmt.Errorf("transaction type %q is already registered", txType)
+	}
+	r.handlers[txType] = handler
+	return nil
+}
+
+// Types returns the registered transaction types, sorted
+func (r *TxRegistry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]string, 0, len(r.handlers))
+	for txType := range r.handlers {
+		types = append(types, txType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// handler returns the handler for a transaction type
+func (r *TxRegistry) handler(txType string) (TxHandler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	handler, ok := r.handlers[txType]
+	return handler, ok
+}
+
+// Validate checks that a transaction has a registered type and that its
+// handler accepts it
+func (r *TxRegistry) Validate(tx *Transaction) error {
+	handler, ok := r.handler(tx.Type)
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownTxType, tx.Type)
+	}
+	if err := handler.ValidateTx(tx); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidTx, tx.ID, err)
+	}
+	return nil
+}
+
+// validateTxType checks a transaction against Config.TxRegistry. Without a
+// registry every type is accepted and transactions have no effect.
+func (c *Consensus) validateTxType(tx *Transaction) error {
+	if c.config.TxRegistry == nil {
+		return nil
+	}
+	return c.config.TxRegistry.Validate(tx)
+}
+
+// applyTransactions applies the transactions of a committed block in block
+// order. A transaction that fails to apply is logged and skipped; the block
+// is committed regardless.
+func (c *Consensus) applyTransactions(block *Block) {
+	if c.config.TxRegistry == nil {
+		return
+	}
+
+	ctx := context.Background()
+	for i, txBytes := range block.Txs {
+		var tx Transaction
+		if err := json.Unmarshal(txBytes, &tx); err != nil {
+			logger.Error("Failed to decode committed transaction", "index", i, "height", block.Height, "err", err)
+			continue
+		}
+		handler, ok := c.config.TxRegistry.handler(tx.Type)
+		if !ok {
+			logger.Error("No handler for committed transaction", "tx", tx.ID, "type", tx.Type, "height", block.Height)
+			continue
+		}
+		if err := handler.ApplyTx(ctx, &tx); err != nil {
+			logger.Error("Failed to apply transaction", "tx", tx.ID, "type", tx.Type, "height", block.Height, "err", err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package consensus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rechain/rechain/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// catalogHandler records snapshot registrations in a map standing in for the catalog
+type catalogHandler struct {
+	catalog map[string]string
+}
+
+func (h *catalogHandler) ValidateTx(tx *Transaction) error {
+	var payload struct {
+		ID  string `json:"id"`
+		CID string `json:"cid"`
+	}
+	if err := json.Unmarshal(tx.Payload, &payload); err != nil {
+		return err
+	}
+	if payload.ID == "" || payload.CID == "" {
+		return fmt.Errorf("id and cid are required")
+	}
+	return nil
+}
+
+func (h *catalogHandler) ApplyTx(ctx context.Context, tx *Transaction) error {
+	var payload struct {
+		ID  string `json:"id"`
+		CID string `json:"cid"`
+	}
+	if err := json.Unmarshal(tx.Payload, &payload); err != nil {
+		return err
+	}
+	h.catalog[payload.ID] = payload.CID
+	return nil
+}
+
+func TestTxRegistryAppliesCommittedTransactions(t *testing.T) {
+	registry := NewTxRegistry()
+	handler := &catalogHandler{catalog: make(map[string]string)}
+	require.NoError(t, registry.Register("register-snapshot", handler))
+	assert.Error(t, registry.Register("register-snapshot", handler))
+	assert.Equal(t, []string{"register-snapshot"}, registry.Types())
+
+	c, err := NewConsensusWithConfig(storage.NewMemoryStore(), nil, &Config{
+		NodeID:        "node-1",
+		BlockInterval: time.Hour, // heights are advanced by hand below
+		Validators:    []Validator{{ID: "node-1", VotingPower: 1}},
+		TxRegistry:    registry,
+	})
+	require.NoError(t, err)
+	defer c.Stop()
+
+	register := signTx(&Transaction{
+		ID:        "tx-register",
+		Type:      "register-snapshot",
+		Payload:   []byte(`{"id":"snap-1","cid":"bafy-snap-1"}`),
+		Timestamp: time.Unix(1700000000, 0),
+	}, testClient)
+	require.NoError(t, c.AddTransaction(register))
+
+	// Unknown types and payloads the handler refuses never reach the mempool
+	err = c.AddTransaction(testTx("tx-untyped"))
+	assert.ErrorIs(t, err, ErrUnknownTxType)
+	err = c.AddTransaction(signTx(&Transaction{ID: "tx-bad", Type: "register-snapshot", Payload: []byte(`{"id":"snap-2"}`)}, testClient))
+	assert.ErrorIs(t, err, ErrInvalidTx)
+	assert.Len(t, c.GetMempool(), 1)
+
+	// Nothing is applied while the transaction is pending
+	assert.Empty(t, handler.catalog)
+
+	// A single validator commits the block as soon as the height starts
+	require.NoError(t, c.Start())
+	c.votingMutex.Lock()
+	step := c.step
+	c.votingMutex.Unlock()
+	require.Equal(t, StepCommit, step)
+
+	assert.Equal(t, map[string]string{"snap-1": "bafy-snap-1"}, handler.catalog)
+	assert.Empty(t, c.GetMempool())
+
+	committed, _, err := c.GetTransaction(context.Background(), register.Hash())
+	require.NoError(t, err)
+	require.NotNil(t, committed)
+}
+
+func TestProposalWithUnknownTxTypeIsRejected(t *testing.T) {
+	registry := NewTxRegistry()
+	require.NoError(t, registry.Register("register-snapshot", &catalogHandler{catalog: make(map[string]string)}))
+
+	c, _ := newTestConsensus(t, "node-1")
+	c.config.TxRegistry = registry
+	require.NoError(t, c.Start())
+
+	// node-2 proposes height 1 with a transaction of a type this node does not know
+	txBytes, err := json.Marshal(testTx("tx-untyped"))
+	require.NoError(t, err)
+	block := &Block{Height: 1, Round: 0, Timestamp: time.Now(), Txs: [][]byte{txBytes}, TxRoot: txMerkleRoot([][]byte{txBytes})}
+
+	c.handleProposal(&Proposal{Block: block, Round: 0, ProposerID: "node-2"})
+	assert.Equal(t, StepPropose, c.step)
+	assert.Nil(t, c.proposal)
+}
@@ -0,0 +1,141 @@
+package consensus
+
+import (
+	"crypto"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/rechain/rechain/internal/security"
+)
+
+// maxScheduleLength bounds the proposer schedule, which has one slot per unit of
+// (GCD-reduced) voting power
+const maxScheduleLength = 1 << 16
+
+// Validator is a member of the validator set. PubKey is the base64 of the key
+// it signs votes with, as security.KeyManager.PublicKeyBytes returns it.
+type Validator struct {
+	ID          string
+	PubKey      string
+	VotingPower int64
+}
+
+// validatorSet holds the validators and their weighted proposer schedule
+type validatorSet struct {
+	validators []Validator
+	index      map[string]int
+	keys       []crypto.PublicKey // parsed PubKey per validator, nil where unset
+	totalPower int64
+	schedule   []int // validator index for each proposer slot
+}
+
+// newValidatorSet validates the validators and precomputes the proposer schedule
+func newValidatorSet(validators []Validator) (*validatorSet, error) {
+	if len(validators) == 0 {
+		return nil, fmt.Errorf("validator set is empty")
+	}
+
+	vs := &validatorSet{
+		validators: append([]Validator{}, validators...),
+		index:      make(map[string]int, len(validators)),
+		keys:       make([]crypto.PublicKey, len(validators)),
+	}
+
+	var divisor int64
+	for i, v := range vs.validators {
+		if v.ID == "" {
+			return nil, fmt.Errorf("validator %d has no ID", i)
+		}
+		if v.VotingPower <= 0 {
+			return nil, fmt.Errorf("validator %s has non-positive voting power %d", v.ID, v.VotingPower)
+		}
+		if _, exists := vs.index[v.ID]; exists {
+			return nil, fmt.Errorf("duplicate validator %s", v.ID)
+		}
+		if v.PubKey != "" {
+			key, err := parseValidatorKey(v.PubKey)
+			if err != nil {
+				return nil, fmt.Errorf("validator %s has an invalid public key: %w", v.ID, err)
+			}
+			vs.keys[i] = key
+		}
+		vs.index[v.ID] = i
+		vs.totalPower += v.VotingPower
+		divisor = gcd(divisor, v.VotingPower)
+	}
+
+	slots := vs.totalPower / divisor
+	if slots > maxScheduleLength {
+		return nil, fmt.Errorf("voting powers need a proposer schedule of %d slots, limit is %d", slots, maxScheduleLength)
+	}
+
+	// Smooth weighted round-robin: every slot each validator accumulates its power,
+	// the highest accumulated priority proposes and pays back the total. The
+	// sequence repeats after one slot per unit of power.
+	priorities := make([]int64, len(vs.validators))
+	vs.schedule = make([]int, slots)
+	for slot := range vs.schedule {
+		best := 0
+		for i, v := range vs.validators {
+			priorities[i] += v.VotingPower / divisor
+			if priorities[i] > priorities[best] {
+				best = i
+			}
+		}
+		priorities[best] -= slots
+		vs.schedule[slot] = best
+	}
+
+	return vs, nil
+}
+
+// proposer returns the ID of the validator that proposes at the given height and round
+func (vs *validatorSet) proposer(height uint64, round int32) string {
+	slot := (height + uint64(round)) % uint64(len(vs.schedule))
+	return vs.validators[vs.schedule[slot]].ID
+}
+
+// has reports whether id belongs to the validator set
+func (vs *validatorSet) has(id string) bool {
+	_, exists := vs.index[id]
+	return exists
+}
+
+// power returns the voting power of id, or zero for non-validators
+func (vs *validatorSet) power(id string) int64 {
+	i, exists := vs.index[id]
+	if !exists {
+		return 0
+	}
+	return vs.validators[i].VotingPower
+}
+
+// publicKey returns the key id signs votes with, or nil if it has none
+func (vs *validatorSet) publicKey(id string) crypto.PublicKey {
+	i, exists := vs.index[id]
+	if !exists {
+		return nil
+	}
+	return vs.keys[i]
+}
+
+// hasQuorum reports whether power is more than two thirds of the total voting power
+func (vs *validatorSet) hasQuorum(power int64) bool {
+	return power*3 > vs.totalPower*2
+}
+
+// parseValidatorKey decodes a base64 public key
+func parseValidatorKey(pubKey string) (crypto.PublicKey, error) {
+	data, err := base64.StdEncoding.DecodeString(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	return security.ParsePublicKey(data)
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
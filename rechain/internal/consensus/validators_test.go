@@ -0,0 +1,77 @@
+package consensus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rechain/rechain/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProposerRotationIsWeighted(t *testing.T) {
+	c, err := NewConsensusWithConfig(storage.NewMemoryStore(), nil, &Config{
+		NodeID:        "alice",
+		BlockInterval: time.Hour,
+		Timeout:       time.Hour,
+		Validators: []Validator{
+			{ID: "alice", VotingPower: 2},
+			{ID: "bob", VotingPower: 1},
+			{ID: "carol", VotingPower: 1},
+		},
+	})
+	require.NoError(t, err)
+	defer c.Stop()
+
+	// alice holds half the power, so she proposes every other slot
+	expected := []string{"alice", "bob", "carol", "alice"}
+	for height := uint64(0); height < 8; height++ {
+		assert.Equal(t, expected[height%4], c.proposer(height, 0), "height %d", height)
+	}
+
+	// A new round hands the proposal to the next validator in the schedule
+	for round := int32(0); round < 8; round++ {
+		assert.Equal(t, c.proposer(5+uint64(round), 0), c.proposer(5, round), "round %d", round)
+	}
+
+	counts := make(map[string]int)
+	for height := uint64(1); height <= 400; height++ {
+		counts[c.proposer(height, 0)]++
+	}
+	assert.Equal(t, map[string]int{"alice": 200, "bob": 100, "carol": 100}, counts)
+}
+
+func TestQuorumUsesVotingPower(t *testing.T) {
+	vs, err := newValidatorSet([]Validator{
+		{ID: "alice", VotingPower: 5},
+		{ID: "bob", VotingPower: 2},
+		{ID: "carol", VotingPower: 1},
+	})
+	require.NoError(t, err)
+
+	assert.False(t, vs.hasQuorum(vs.power("alice")))
+	assert.True(t, vs.hasQuorum(vs.power("alice")+vs.power("bob")))
+	assert.Zero(t, vs.power("mallory"))
+}
+
+func TestValidatorSetValidation(t *testing.T) {
+	cfg := func(nodeID string, validators ...Validator) *Config {
+		return &Config{NodeID: nodeID, BlockInterval: time.Hour, Timeout: time.Hour, Validators: validators}
+	}
+
+	tests := []struct {
+		name string
+		cfg  *Config
+	}{
+		{"Empty", cfg("alice")},
+		{"LocalNodeMissing", cfg("dave", Validator{ID: "alice", VotingPower: 1})},
+		{"ZeroPower", cfg("alice", Validator{ID: "alice", VotingPower: 0})},
+		{"Duplicate", cfg("alice", Validator{ID: "alice", VotingPower: 1}, Validator{ID: "alice", VotingPower: 1})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewConsensusWithConfig(storage.NewMemoryStore(), nil, tt.cfg)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package gcl
 
 import (
-	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -20,8 +19,11 @@ type P2PServer struct {
 	config  *Config
 	privKey *ecdsa.PrivateKey
 
-	peers     map[enode.ID]*p2p.Peer
+	peers     map[enode.ID]p2p.MsgReadWriter
 	peersLock sync.RWMutex
+
+	handlers     map[uint64]func(payload []byte)
+	handlersLock sync.RWMutex
 }
 
 // NewP2PServer creates a new P2P server
@@ -33,9 +35,10 @@ func NewP2PServer(config *Config) (*P2PServer, error) {
 	}
 
 	srv := &P2PServer{
-		config:  config,
-		privKey: privKey,
-		peers:   make(map[enode.ID]*p2p.Peer),
+		config:   config,
+		privKey:  privKey,
+		peers:    make(map[enode.ID]p2p.MsgReadWriter),
+		handlers: make(map[uint64]func(payload []byte)),
 	}
 
 	// Create the P2P server configuration
@@ -76,15 +79,6 @@ func (s *P2PServer) Start() error {
 		return fmt.Errorf("failed to start P2P server: %w", err)
 	}
 
-	// Start the discovery service
-	s.server.AddPeer = func(node *enode.Node) {
-		log.Printf("New peer connected: %s", node)
-	}
-
-	s.server.DelPeer = func(node *enode.Node) {
-		log.Printf("Peer disconnected: %s", node)
-	}
-
 	log.Printf("P2P server started, node ID: %s", s.server.Self())
 	return nil
 }
@@ -111,14 +105,16 @@ func (s *P2PServer) makeProtocols() []p2p.Protocol {
 func (s *P2PServer) handlePeer(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
 	// Add to peer list
 	s.peersLock.Lock()
-	s.peers[peer.ID()] = peer
+	s.peers[peer.ID()] = rw
 	s.peersLock.Unlock()
+	log.Printf("New peer connected: %s", peer.ID())
 
 	// Remove from peer list when done
 	defer func() {
 		s.peersLock.Lock()
 		delete(s.peers, peer.ID())
 		s.peersLock.Unlock()
+		log.Printf("Peer disconnected: %s", peer.ID())
 	}()
 
 	// Main message loop
@@ -128,25 +124,55 @@ func (s *P2PServer) handlePeer(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
 			return err
 		}
 
-		// Handle the message
-		switch msg.Code {
-		// Add message handlers here
-		default:
+		s.handlersLock.RLock()
+		handler, ok := s.handlers[msg.Code]
+		s.handlersLock.RUnlock()
+
+		if !ok {
 			log.Printf("Unknown message code: %d", msg.Code)
+			msg.Discard()
+			continue
 		}
 
-		msg.Discard()
+		var payload []byte
+		if err := msg.Decode(&payload); err != nil {
+			log.Printf("Failed to decode message %d from peer %s: %v", msg.Code, peer.ID(), err)
+			continue
+		}
+		handler(payload)
 	}
 }
 
+// HandleMessage registers the handler for inbound messages with the given code
+func (s *P2PServer) HandleMessage(code uint64, handler func(payload []byte)) {
+	s.handlersLock.Lock()
+	defer s.handlersLock.Unlock()
+
+	s.handlers[code] = handler
+}
+
+// SendMessage sends a payload with the given message code to all connected peers
+func (s *P2PServer) SendMessage(code uint64, payload []byte) error {
+	s.peersLock.RLock()
+	defer s.peersLock.RUnlock()
+
+	for id, rw := range s.peers {
+		if err := p2p.Send(rw, code, payload); err != nil {
+			log.Printf("Failed to send message to peer %s: %v", id, err)
+		}
+	}
+
+	return nil
+}
+
 // Broadcast sends a message to all connected peers
 func (s *P2PServer) Broadcast(msg p2p.Msg) error {
 	s.peersLock.RLock()
 	defer s.peersLock.RUnlock()
 
-	for _, peer := range s.peers {
-		if err := p2p.Send(peer.RW(), msg.Code, msg.Payload); err != nil {
-			log.Printf("Failed to send message to peer %s: %v", peer.ID(), err)
+	for id, rw := range s.peers {
+		if err := p2p.Send(rw, msg.Code, msg.Payload); err != nil {
+			log.Printf("Failed to send message to peer %s: %v", id, err)
 		}
 	}
 
@@ -0,0 +1,73 @@
+package gossip
+
+import (
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// AuthMode selects which peers may submit gossip messages
+type AuthMode string
+
+const (
+	// AuthOpen accepts messages from any peer; intended for development
+	AuthOpen AuthMode = "open"
+	// AuthAllowlist accepts messages only from configured peer IDs
+	AuthAllowlist AuthMode = "allowlist"
+)
+
+// Peer scoring applied to rejected messages
+const (
+	rejectPenalty = 10
+	minPeerScore  = -100
+)
+
+// PeerAuthorizer decides whether a remote peer may submit messages.
+//
+// The peer ID it checks is the one libp2p authenticated for the connection:
+// the Noise/TLS handshake has the remote sign a challenge with the private
+// key behind its ID, so a peer cannot claim an allowlisted ID it doesn't own.
+type PeerAuthorizer struct {
+	mode    AuthMode
+	allowed map[peer.ID]struct{}
+}
+
+// NewPeerAuthorizer creates an authorizer for mode. allowedPeers lists the
+// peer IDs accepted in allowlist mode and must be empty in open mode.
+func NewPeerAuthorizer(mode AuthMode, allowedPeers []string) (*PeerAuthorizer, error) {
+	switch mode {
+	case "", AuthOpen:
+		if len(allowedPeers) > 0 {
+			return nil, fmt.Errorf("allowed peers are only used in %s mode", AuthAllowlist)
+		}
+		return &PeerAuthorizer{mode: AuthOpen}, nil
+	case AuthAllowlist:
+	default:
+		return nil, fmt.Errorf("unknown gossip auth mode %q", mode)
+	}
+
+	allowed := make(map[peer.ID]struct{}, len(allowedPeers))
+	for _, s := range allowedPeers {
+		id, err := peer.Decode(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed peer %q: %w", s, err)
+		}
+		allowed[id] = struct{}{}
+	}
+
+	return &PeerAuthorizer{mode: AuthAllowlist, allowed: allowed}, nil
+}
+
+// Mode returns the authorizer's mode
+func (a *PeerAuthorizer) Mode() AuthMode {
+	return a.mode
+}
+
+// Authorized reports whether id may submit messages
+func (a *PeerAuthorizer) Authorized(id peer.ID) bool {
+	if a == nil || a.mode == AuthOpen {
+		return true
+	}
+	_, ok := a.allowed[id]
+	return ok
+}
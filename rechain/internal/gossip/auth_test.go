@@ -0,0 +1,109 @@
+package gossip
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPeerID(t *testing.T) peer.ID {
+	t.Helper()
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	id, err := peer.IDFromPrivateKey(priv)
+	require.NoError(t, err)
+	return id
+}
+
+// newTestGossip builds a protocol instance without a libp2p host
+func newTestGossip(auth *PeerAuthorizer) *GossipProtocol {
+	return &GossipProtocol{
+		peers:     make(map[peer.ID]*PeerInfo),
+		incoming:  make(chan *Message, 10),
+		crdtState: make(map[string]interface{}),
+		handlers:  make(map[MessageType]func(payload []byte)),
+		auth:      auth,
+	}
+}
+
+func updateFrom(t *testing.T, sender peer.ID, key, value string) *Message {
+	t.Helper()
+	payload, err := json.Marshal(map[string]interface{}{key: value})
+	require.NoError(t, err)
+	return &Message{ID: key, Type: UpdateMessage, Payload: payload, Sender: sender}
+}
+
+func TestAllowlistRejectsUnauthorizedPeer(t *testing.T) {
+	allowed := newTestPeerID(t)
+	intruder := newTestPeerID(t)
+
+	auth, err := NewPeerAuthorizer(AuthAllowlist, []string{allowed.String()})
+	require.NoError(t, err)
+	gp := newTestGossip(auth)
+	gp.peers[intruder] = &PeerInfo{ID: intruder}
+
+	gp.deliver(intruder, updateFrom(t, intruder, "snapshot", "forged"))
+	assert.Empty(t, gp.incoming, "update from unauthorized peer was queued")
+	assert.NotContains(t, gp.peers, intruder, "unauthorized peer was kept")
+
+	gp.deliver(allowed, updateFrom(t, allowed, "snapshot", "genuine"))
+	require.Len(t, gp.incoming, 1)
+	gp.handleUpdateMessage(<-gp.incoming)
+
+	value, ok := gp.GetCRDT("snapshot")
+	require.True(t, ok)
+	assert.Equal(t, "genuine", value)
+}
+
+func TestDeliverRejectsSpoofedSender(t *testing.T) {
+	allowed := newTestPeerID(t)
+	other := newTestPeerID(t)
+
+	auth, err := NewPeerAuthorizer(AuthAllowlist, []string{allowed.String(), other.String()})
+	require.NoError(t, err)
+	gp := newTestGossip(auth)
+	gp.peers[allowed] = &PeerInfo{ID: allowed}
+
+	// An authorized peer may not relay a message in another peer's name
+	gp.deliver(allowed, updateFrom(t, other, "snapshot", "spoofed"))
+	assert.Empty(t, gp.incoming)
+	require.Contains(t, gp.peers, allowed)
+	assert.Equal(t, -rejectPenalty, gp.peers[allowed].Score)
+
+	for i := 0; i < -minPeerScore/rejectPenalty; i++ {
+		gp.deliver(allowed, updateFrom(t, other, "snapshot", "spoofed"))
+	}
+	assert.NotContains(t, gp.peers, allowed, "peer kept after its score hit the minimum")
+}
+
+func TestOpenModeAcceptsAnyPeer(t *testing.T) {
+	auth, err := NewPeerAuthorizer(AuthOpen, nil)
+	require.NoError(t, err)
+	gp := newTestGossip(auth)
+
+	sender := newTestPeerID(t)
+	gp.deliver(sender, updateFrom(t, sender, "image", "ubuntu"))
+	assert.Len(t, gp.incoming, 1)
+}
+
+func TestNewPeerAuthorizerValidation(t *testing.T) {
+	id := newTestPeerID(t)
+
+	_, err := NewPeerAuthorizer(AuthOpen, []string{id.String()})
+	assert.Error(t, err, "allowed peers in open mode")
+
+	_, err = NewPeerAuthorizer(AuthAllowlist, []string{"not-a-peer-id"})
+	assert.Error(t, err, "malformed peer ID")
+
+	_, err = NewPeerAuthorizer("strict", nil)
+	assert.Error(t, err, "unknown mode")
+
+	auth, err := NewPeerAuthorizer("", nil)
+	require.NoError(t, err)
+	assert.Equal(t, AuthOpen, auth.Mode())
+}
@@ -0,0 +1,64 @@
+package gossip
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// Payloads larger than compressThreshold bytes are gzipped on the wire;
+// smaller ones are sent as-is since gzip's framing would outweigh the savings
+const compressThreshold = 1024
+
+// maxPayloadSize bounds a decompressed payload so a small message cannot
+// expand into an arbitrarily large one
+const maxPayloadSize = 64 << 20
+
+// compressMessage returns msg ready to send: a copy with a gzipped payload
+// and Compressed set if the payload is above compressThreshold, or msg itself
+func compressMessage(msg *Message) (*Message, error) {
+	if msg.Compressed || len(msg.Payload) <= compressThreshold {
+		return msg, nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(msg.Payload); err != nil {
+		return nil, fmt.Errorf("failed to compress payload: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress payload: %w", err)
+	}
+
+	wire := *msg
+	wire.Payload = buf.Bytes()
+	wire.Compressed = true
+	return &wire, nil
+}
+
+// decompressMessage restores a received message's payload in place.
+// Messages without the Compressed flag are left untouched.
+func decompressMessage(msg *Message) error {
+	if !msg.Compressed {
+		return nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(msg.Payload))
+	if err != nil {
+		return fmt.Errorf("failed to decompress payload: %w", err)
+	}
+	defer zr.Close()
+
+	payload, err := io.ReadAll(io.LimitReader(zr, maxPayloadSize+1))
+	if err != nil {
+		return fmt.Errorf("failed to decompress payload: %w", err)
+	}
+	if len(payload) > maxPayloadSize {
+		return fmt.Errorf("decompressed payload exceeds %d bytes", maxPayloadSize)
+	}
+
+	msg.Payload = payload
+	msg.Compressed = false
+	return nil
+}
@@ -0,0 +1,75 @@
+package gossip
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// roundTrip encodes msg the way sendMessage does and decodes it the way
+// handleStream does, returning the wire form and the received message
+func roundTrip(t *testing.T, msg *Message) ([]byte, *Message) {
+	t.Helper()
+
+	wire, err := compressMessage(msg)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, json.NewEncoder(&buf).Encode(wire))
+	encoded := append([]byte(nil), buf.Bytes()...)
+
+	var received Message
+	require.NoError(t, json.NewDecoder(&buf).Decode(&received))
+	require.NoError(t, decompressMessage(&received))
+	return encoded, &received
+}
+
+func TestLargePayloadIsCompressed(t *testing.T) {
+	state := make(map[string]interface{})
+	for i := 0; i < 500; i++ {
+		state[strings.Repeat("k", i%40+1)+string(rune('a'+i%26))] = strings.Repeat("snapshot-metadata ", 10)
+	}
+	payload, err := json.Marshal(state)
+	require.NoError(t, err)
+	require.Greater(t, len(payload), compressThreshold)
+
+	msg := &Message{ID: "large", Type: AntiEntropyMessage, Payload: payload, Sender: newTestPeerID(t)}
+	encoded, received := roundTrip(t, msg)
+
+	assert.Less(t, len(encoded), len(payload), "compressed message is not smaller than its payload")
+	assert.Contains(t, string(encoded), `"Compressed":true`)
+	assert.Equal(t, payload, received.Payload)
+	assert.False(t, received.Compressed)
+	assert.Equal(t, payload, msg.Payload, "sender's message was modified")
+}
+
+func TestSmallPayloadIsSentUncompressed(t *testing.T) {
+	payload := []byte(`{"key":"value"}`)
+	msg := &Message{ID: "small", Type: UpdateMessage, Payload: payload, Sender: newTestPeerID(t), TTL: 5}
+
+	encoded, received := roundTrip(t, msg)
+
+	// Identical to what a node without compression support sends
+	legacy, err := json.Marshal(struct {
+		ID        string
+		Type      MessageType
+		Payload   []byte
+		Timestamp time.Time
+		Sender    peer.ID
+		TTL       int
+	}{msg.ID, msg.Type, msg.Payload, msg.Timestamp, msg.Sender, msg.TTL})
+	require.NoError(t, err)
+	assert.JSONEq(t, string(legacy), string(encoded))
+	assert.Equal(t, payload, received.Payload)
+}
+
+func TestDecompressRejectsCorruptPayload(t *testing.T) {
+	msg := &Message{Payload: []byte("not gzip"), Compressed: true}
+	assert.Error(t, decompressMessage(msg))
+}
@@ -5,7 +5,6 @@ import (
 	"crypto/rand"
 	"encoding/json"
 	"fmt"
-	"log"
 	"sync"
 	"time"
 
@@ -15,8 +14,11 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/multiformats/go-multiaddr"
+	"github.com/rechain/rechain/pkg/logging"
 )
 
+var logger = logging.Component("gossip")
+
 // GossipProtocol implements epidemic broadcast for metadata synchronization
 type GossipProtocol struct {
 	host       host.Host
@@ -31,6 +33,13 @@ type GossipProtocol struct {
 	crdtState map[string]interface{}
 	stateMutex sync.RWMutex
 
+	// Handlers for application messages such as consensus traffic
+	handlers      map[MessageType]func(payload []byte)
+	handlersMutex sync.RWMutex
+
+	// Which peers may submit messages
+	auth *PeerAuthorizer
+
 	// Configuration
 	fanout      int           // Number of peers to send to initially
 	gossipInterval time.Duration
@@ -54,6 +63,10 @@ type Message struct {
 	Timestamp time.Time
 	Sender    peer.ID
 	TTL       int // Time to live
+
+	// Compressed marks a gzipped Payload; omitted for uncompressed
+	// messages so they match what older nodes send
+	Compressed bool `json:",omitempty"`
 }
 
 // MessageType defines the type of gossip message
@@ -66,8 +79,18 @@ const (
 	AntiEntropyMessage
 )
 
-// NewGossipProtocol creates a new gossip protocol instance
+// NewGossipProtocol creates a new gossip protocol instance that accepts messages from any peer
 func NewGossipProtocol(listenAddr string) (*GossipProtocol, error) {
+	return NewGossipProtocolWithAuth(listenAddr, nil)
+}
+
+// NewGossipProtocolWithAuth creates a gossip protocol instance that only
+// accepts messages from peers auth authorizes; a nil auth accepts any peer
+func NewGossipProtocolWithAuth(listenAddr string, auth *PeerAuthorizer) (*GossipProtocol, error) {
+	if auth == nil {
+		auth = &PeerAuthorizer{mode: AuthOpen}
+	}
+
 	// Create libp2p host
 	host, err := libp2p.New(
 		libp2p.ListenAddrStrings(listenAddr),
@@ -82,6 +105,8 @@ func NewGossipProtocol(listenAddr string) (*GossipProtocol, error) {
 		incoming:   make(chan *Message, 1000),
 		outgoing:   make(chan *Message, 1000),
 		crdtState:  make(map[string]interface{}),
+		handlers:   make(map[MessageType]func(payload []byte)),
+		auth:       auth,
 		fanout:     3,
 		gossipInterval: 1 * time.Second,
 		antiEntropyInterval: 30 * time.Second,
@@ -93,16 +118,17 @@ func NewGossipProtocol(listenAddr string) (*GossipProtocol, error) {
 
 	// Start background processes
 	go gp.processMessages()
+	go gp.sendLoop()
 	go gp.gossipLoop()
 	go gp.antiEntropyLoop()
 
-	log.Printf("Gossip protocol started on %s", host.ID())
+	logger.Info("Gossip protocol started", "peer_id", host.ID(), "auth_mode", auth.Mode())
 	return gp, nil
 }
 
 // Start starts the gossip protocol
 func (gp *GossipProtocol) Start() error {
-	log.Println("Gossip protocol running")
+	logger.Info("Gossip protocol running")
 	return nil
 }
 
@@ -123,6 +149,9 @@ func (gp *GossipProtocol) AddPeer(peerAddr string) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse peer info: %w", err)
 	}
+	if !gp.auth.Authorized(peerInfo.ID) {
+		return fmt.Errorf("peer %s is not in the gossip allowlist", peerInfo.ID)
+	}
 
 	// Connect to peer
 	if err := gp.host.Connect(context.Background(), *peerInfo); err != nil {
@@ -137,7 +166,7 @@ func (gp *GossipProtocol) AddPeer(peerAddr string) error {
 	}
 	gp.peersMutex.Unlock()
 
-	log.Printf("Added peer: %s", peerInfo.ID)
+	logger.Info("Added peer", "peer", peerInfo.ID)
 	return nil
 }
 
@@ -187,6 +216,13 @@ func (gp *GossipProtocol) GetCRDT(key string) (interface{}, bool) {
 	return value, exists
 }
 
+// SnapshotState encodes the whole CRDT state for consensus state snapshots
+func (gp *GossipProtocol) SnapshotState(ctx context.Context) ([]byte, error) {
+	gp.stateMutex.RLock()
+	defer gp.stateMutex.RUnlock()
+	return json.Marshal(gp.crdtState)
+}
+
 // QueryCRDT queries for CRDT state from peers
 func (gp *GossipProtocol) QueryCRDT(key string) error {
 	query := map[string]string{"key": key}
@@ -198,6 +234,41 @@ func (gp *GossipProtocol) QueryCRDT(key string) error {
 	return gp.Broadcast(QueryMessage, payload)
 }
 
+// HandleMessage registers the handler for application messages with the given code.
+// Codes must not collide with the built-in message types.
+func (gp *GossipProtocol) HandleMessage(code uint64, handler func(payload []byte)) {
+	gp.handlersMutex.Lock()
+	defer gp.handlersMutex.Unlock()
+
+	gp.handlers[MessageType(code)] = handler
+}
+
+// SendMessage broadcasts an application message with the given code to all peers
+func (gp *GossipProtocol) SendMessage(code uint64, payload []byte) error {
+	return gp.Broadcast(MessageType(code), payload)
+}
+
+// sendLoop delivers broadcast messages to every known peer
+func (gp *GossipProtocol) sendLoop() {
+	for {
+		select {
+		case <-gp.quit:
+			return
+		case msg := <-gp.outgoing:
+			gp.peersMutex.RLock()
+			peerIDs := make([]peer.ID, 0, len(gp.peers))
+			for id := range gp.peers {
+				peerIDs = append(peerIDs, id)
+			}
+			gp.peersMutex.RUnlock()
+
+			for _, peerID := range peerIDs {
+				gp.sendMessage(peerID, msg)
+			}
+		}
+	}
+}
+
 // gossipLoop periodically gossips recent updates
 func (gp *GossipProtocol) gossipLoop() {
 	ticker := time.NewTicker(gp.gossipInterval)
@@ -322,10 +393,12 @@ func (gp *GossipProtocol) processMessages() {
 
 // handleMessage handles an incoming message
 func (gp *GossipProtocol) handleMessage(msg *Message) {
-	// Update peer last seen
+	// Update peer last seen, learning peers that dialed us
 	gp.peersMutex.Lock()
 	if peer, exists := gp.peers[msg.Sender]; exists {
 		peer.LastSeen = time.Now()
+	} else if msg.Sender != "" && msg.Sender != gp.host.ID() {
+		gp.peers[msg.Sender] = &PeerInfo{ID: msg.Sender, LastSeen: time.Now()}
 	}
 	gp.peersMutex.Unlock()
 
@@ -338,6 +411,16 @@ func (gp *GossipProtocol) handleMessage(msg *Message) {
 		gp.handleResponseMessage(msg)
 	case AntiEntropyMessage:
 		gp.handleAntiEntropyMessage(msg)
+	default:
+		gp.handlersMutex.RLock()
+		handler, exists := gp.handlers[msg.Type]
+		gp.handlersMutex.RUnlock()
+
+		if !exists {
+			logger.Warn("Unknown message type", "type", msg.Type, "peer", msg.Sender)
+			return
+		}
+		handler(msg.Payload)
 	}
 }
 
@@ -345,7 +428,7 @@ func (gp *GossipProtocol) handleMessage(msg *Message) {
 func (gp *GossipProtocol) handleUpdateMessage(msg *Message) {
 	var update map[string]interface{}
 	if err := json.Unmarshal(msg.Payload, &update); err != nil {
-		log.Printf("Failed to unmarshal update message: %v", err)
+		logger.Warn("Failed to unmarshal update message", "peer", msg.Sender, "err", err)
 		return
 	}
 
@@ -356,14 +439,14 @@ func (gp *GossipProtocol) handleUpdateMessage(msg *Message) {
 	}
 	gp.stateMutex.Unlock()
 
-	log.Printf("Applied update from %s: %v", msg.Sender, update)
+	logger.Debug("Applied update", "peer", msg.Sender, "keys", len(update))
 }
 
 // handleQueryMessage handles a query message
 func (gp *GossipProtocol) handleQueryMessage(msg *Message) {
 	var query map[string]string
 	if err := json.Unmarshal(msg.Payload, &query); err != nil {
-		log.Printf("Failed to unmarshal query message: %v", err)
+		logger.Warn("Failed to unmarshal query message", "peer", msg.Sender, "err", err)
 		return
 	}
 
@@ -397,18 +480,18 @@ func (gp *GossipProtocol) handleQueryMessage(msg *Message) {
 func (gp *GossipProtocol) handleResponseMessage(msg *Message) {
 	var response map[string]interface{}
 	if err := json.Unmarshal(msg.Payload, &response); err != nil {
-		log.Printf("Failed to unmarshal response message: %v", err)
+		logger.Warn("Failed to unmarshal response message", "peer", msg.Sender, "err", err)
 		return
 	}
 
-	log.Printf("Received response from %s: %v", msg.Sender, response)
+	logger.Debug("Received response", "peer", msg.Sender, "keys", len(response))
 }
 
 // handleAntiEntropyMessage handles an anti-entropy message
 func (gp *GossipProtocol) handleAntiEntropyMessage(msg *Message) {
 	var antiEntropy map[string]string
 	if err := json.Unmarshal(msg.Payload, &antiEntropy); err != nil {
-		log.Printf("Failed to unmarshal anti-entropy message: %v", err)
+		logger.Warn("Failed to unmarshal anti-entropy message", "peer", msg.Sender, "err", err)
 		return
 	}
 
@@ -431,7 +514,7 @@ func (gp *GossipProtocol) handleAntiEntropyMessage(msg *Message) {
 		}
 
 		gp.sendMessage(msg.Sender, reconcileMsg)
-		log.Printf("Sent state reconciliation to %s", msg.Sender)
+		logger.Debug("Sent state reconciliation", "peer", msg.Sender)
 	}
 }
 
@@ -442,29 +525,77 @@ func (gp *GossipProtocol) handleStream(s network.Stream) {
 	// Read message from stream
 	var msg Message
 	if err := json.NewDecoder(s).Decode(&msg); err != nil {
-		log.Printf("Failed to decode message: %v", err)
+		logger.Warn("Failed to decode message", "peer", s.Conn().RemotePeer(), "err", err)
+		return
+	}
+	if err := decompressMessage(&msg); err != nil {
+		logger.Warn("Failed to decode message", "peer", s.Conn().RemotePeer(), "err", err)
+		return
+	}
+
+	gp.deliver(s.Conn().RemotePeer(), &msg)
+}
+
+// deliver queues a message received from remote if remote may submit it.
+// Messages are sent directly rather than relayed, so the claimed sender must
+// be the authenticated remote peer.
+func (gp *GossipProtocol) deliver(remote peer.ID, msg *Message) {
+	if !gp.auth.Authorized(remote) {
+		logger.Warn("Dropping message from unauthorized peer", "peer", remote)
+		gp.penalize(remote)
+		return
+	}
+	if msg.Sender != remote {
+		logger.Warn("Dropping message with spoofed sender", "peer", remote, "claimed_sender", msg.Sender)
+		gp.penalize(remote)
 		return
 	}
 
 	// Add to incoming queue
 	select {
-	case gp.incoming <- &msg:
+	case gp.incoming <- msg:
 	default:
-		log.Println("Incoming message queue full, dropping message")
+		logger.Warn("Incoming message queue full, dropping message", "peer", remote)
+	}
+}
+
+// penalize lowers a known peer's score for a rejected message. Peers that
+// are not authorized at all, or whose score reaches minPeerScore, are
+// dropped from the peer set and disconnected.
+func (gp *GossipProtocol) penalize(id peer.ID) {
+	gp.peersMutex.Lock()
+	drop := !gp.auth.Authorized(id)
+	if info, exists := gp.peers[id]; exists {
+		info.Score -= rejectPenalty
+		drop = drop || info.Score <= minPeerScore
+	}
+	if drop {
+		delete(gp.peers, id)
+	}
+	gp.peersMutex.Unlock()
+
+	if drop && gp.host != nil {
+		gp.host.Network().ClosePeer(id)
 	}
 }
 
 // sendMessage sends a message to a specific peer
 func (gp *GossipProtocol) sendMessage(peerID peer.ID, msg *Message) {
+	wire, err := compressMessage(msg)
+	if err != nil {
+		logger.Error("Failed to encode message", "peer", peerID, "err", err)
+		return
+	}
+
 	s, err := gp.host.NewStream(context.Background(), peerID, protocol.ID("/rechain/gossip/1.0.0"))
 	if err != nil {
-		log.Printf("Failed to create stream to %s: %v", peerID, err)
+		logger.Warn("Failed to create stream", "peer", peerID, "err", err)
 		return
 	}
 	defer s.Close()
 
-	if err := json.NewEncoder(s).Encode(msg); err != nil {
-		log.Printf("Failed to send message to %s: %v", peerID, err)
+	if err := json.NewEncoder(s).Encode(wire); err != nil {
+		logger.Warn("Failed to send message", "peer", peerID, "err", err)
 	}
 }
 
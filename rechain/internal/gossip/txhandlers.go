@@ -0,0 +1,78 @@
+package gossip
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rechain/rechain/internal/consensus"
+)
+
+// RegisterSnapshotTxType is the transaction type that adds a snapshot to the catalog
+const RegisterSnapshotTxType = "register-snapshot"
+
+// SnapshotRegistration is the payload of a register-snapshot transaction
+type SnapshotRegistration struct {
+	ID      string `json:"id"`
+	CID     string `json:"cid"`
+	Size    int64  `json:"size,omitempty"`
+	Cluster string `json:"cluster,omitempty"`
+}
+
+// SnapshotEntry is a snapshot as recorded in the catalog
+type SnapshotEntry struct {
+	SnapshotRegistration
+	RegisteredBy string `json:"registered_by"`
+}
+
+// snapshotKey is the catalog key of a snapshot
+func snapshotKey(id string) string {
+	return "snapshots/" + id
+}
+
+// snapshotTxHandler applies register-snapshot transactions to the CRDT catalog
+type snapshotTxHandler struct {
+	gp *GossipProtocol
+}
+
+// SnapshotTxHandler returns the consensus handler for register-snapshot
+// transactions. Every node applies committed transactions itself, so entries
+// are written to the local catalog without being gossiped.
+func (gp *GossipProtocol) SnapshotTxHandler() consensus.TxHandler {
+	return &snapshotTxHandler{gp: gp}
+}
+
+func decodeSnapshotRegistration(tx *consensus.Transaction) (*SnapshotRegistration, error) {
+	var reg SnapshotRegistration
+	if err := json.Unmarshal(tx.Payload, &reg); err != nil {
+		return nil, fmt.Errorf("invalid snapshot registration: %w", err)
+	}
+	if reg.ID == "" || reg.CID == "" {
+		return nil, fmt.Errorf("snapshot registration requires an id and a cid")
+	}
+	if reg.Size < 0 {
+		return nil, fmt.Errorf("snapshot size must not be negative")
+	}
+	return &reg, nil
+}
+
+// ValidateTx checks the registration payload
+func (h *snapshotTxHandler) ValidateTx(tx *consensus.Transaction) error {
+	_, err := decodeSnapshotRegistration(tx)
+	return err
+}
+
+// ApplyTx records the snapshot in the catalog under snapshots/<id>
+func (h *snapshotTxHandler) ApplyTx(ctx context.Context, tx *consensus.Transaction) error {
+	reg, err := decodeSnapshotRegistration(tx)
+	if err != nil {
+		return err
+	}
+
+	h.gp.stateMutex.Lock()
+	h.gp.crdtState[snapshotKey(reg.ID)] = SnapshotEntry{SnapshotRegistration: *reg, RegisteredBy: tx.Sender}
+	h.gp.stateMutex.Unlock()
+
+	logger.Info("Registered snapshot", "id", reg.ID, "cid", reg.CID, "sender", tx.Sender)
+	return nil
+}
@@ -0,0 +1,177 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// Supported HSM backends
+const (
+	HSMBackendPKCS11 = "pkcs11"
+	HSMBackendMock   = "mock"
+)
+
+// ErrHSMNotConfigured is returned when HSM operations are attempted without a real backend
+var ErrHSMNotConfigured = errors.New("no HSM backend configured")
+
+// HSMBackend is a hardware security module holding signing keys that never leave the device
+type HSMBackend interface {
+	Connect() error
+	Sign(keyID string, data []byte) ([]byte, error)
+	GenerateKey(keyID string) error
+	PublicKey(keyID string) (crypto.PublicKey, error)
+	Close() error
+}
+
+// HSMConfig selects and configures the HSM backend
+type HSMConfig struct {
+	Backend    string // "pkcs11", or "mock" for tests
+	ModulePath string // path to the PKCS#11 shared library
+	Slot       uint
+	PIN        string
+	KeyID      string // label of the node signing key
+}
+
+// HSMManager signs data with keys held in an HSM
+type HSMManager struct {
+	backend HSMBackend
+	keyID   string
+}
+
+// NewHSMManager creates an HSM manager for the configured backend. An empty
+// backend yields a manager that refuses every operation.
+func NewHSMManager(cfg HSMConfig) (*HSMManager, error) {
+	hsm := &HSMManager{keyID: cfg.KeyID}
+	if hsm.keyID == "" {
+		hsm.keyID = "rechain-node"
+	}
+
+	switch cfg.Backend {
+	case "":
+		return hsm, nil
+	case HSMBackendPKCS11:
+		if cfg.ModulePath == "" {
+			return nil, fmt.Errorf("pkcs11 backend requires a module path")
+		}
+		hsm.backend = NewPKCS11Backend(cfg.ModulePath, cfg.Slot, cfg.PIN)
+	case HSMBackendMock:
+		log.Printf("Using mock HSM backend; signatures are NOT hardware-backed")
+		hsm.backend = NewMockHSMBackend()
+	default:
+		return nil, fmt.Errorf("unsupported HSM backend %q", cfg.Backend)
+	}
+
+	if err := hsm.backend.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to HSM: %w", err)
+	}
+
+	return hsm, nil
+}
+
+// SignWithHSM signs data with the node key held in the HSM
+func (hsm *HSMManager) SignWithHSM(data []byte) ([]byte, error) {
+	if hsm.backend == nil {
+		return nil, ErrHSMNotConfigured
+	}
+	return hsm.backend.Sign(hsm.keyID, data)
+}
+
+// GenerateKeyWithHSM generates a key inside the HSM
+func (hsm *HSMManager) GenerateKeyWithHSM(keyID string) error {
+	if hsm.backend == nil {
+		return ErrHSMNotConfigured
+	}
+	return hsm.backend.GenerateKey(keyID)
+}
+
+// PublicKey returns the public half of the node key held in the HSM
+func (hsm *HSMManager) PublicKey() (crypto.PublicKey, error) {
+	if hsm.backend == nil {
+		return nil, ErrHSMNotConfigured
+	}
+	return hsm.backend.PublicKey(hsm.keyID)
+}
+
+// PublicKeyBytes returns the HSM node key in PKIX DER form, like KeyManager.PublicKeyBytes
+func (hsm *HSMManager) PublicKeyBytes() ([]byte, error) {
+	pub, err := hsm.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	return x509.MarshalPKIXPublicKey(pub)
+}
+
+// Close releases the HSM session
+func (hsm *HSMManager) Close() error {
+	if hsm.backend == nil {
+		return nil
+	}
+	return hsm.backend.Close()
+}
+
+// MockHSMBackend keeps ECDSA P-256 keys in memory. It is for tests only.
+type MockHSMBackend struct {
+	mu   sync.Mutex
+	keys map[string]*ecdsa.PrivateKey
+}
+
+// NewMockHSMBackend creates an in-memory HSM backend
+func NewMockHSMBackend() *MockHSMBackend {
+	return &MockHSMBackend{keys: make(map[string]*ecdsa.PrivateKey)}
+}
+
+// Connect is a no-op for the mock backend
+func (m *MockHSMBackend) Connect() error {
+	return nil
+}
+
+// Close is a no-op for the mock backend
+func (m *MockHSMBackend) Close() error {
+	return nil
+}
+
+// GenerateKey creates an in-memory key
+func (m *MockHSMBackend) GenerateKey(keyID string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[keyID] = key
+	return nil
+}
+
+// Sign returns an ASN.1 ECDSA signature over the SHA-256 digest of data
+func (m *MockHSMBackend) Sign(keyID string, data []byte) ([]byte, error) {
+	m.mu.Lock()
+	key, exists := m.keys[keyID]
+	m.mu.Unlock()
+	if !exists {
+		return nil, fmt.Errorf("key %s not found", keyID)
+	}
+
+	digest := sha256.Sum256(data)
+	return ecdsa.SignASN1(rand.Reader, key, digest[:])
+}
+
+// PublicKey returns the public key for keyID
+func (m *MockHSMBackend) PublicKey(keyID string) (crypto.PublicKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, exists := m.keys[keyID]
+	if !exists {
+		return nil, fmt.Errorf("key %s not found", keyID)
+	}
+	return &key.PublicKey, nil
+}
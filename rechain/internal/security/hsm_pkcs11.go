@@ -0,0 +1,214 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+// oidP256 is the DER-encoded object identifier of the NIST P-256 curve
+var oidP256 = []byte{0x06, 0x08, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x03, 0x01, 0x07}
+
+// PKCS11Backend talks to an HSM through a PKCS#11 module and uses ECDSA P-256 keys
+type PKCS11Backend struct {
+	modulePath string
+	slot       uint
+	pin        string
+
+	mu      sync.Mutex
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+}
+
+// NewPKCS11Backend creates a backend for the PKCS#11 module at modulePath
+func NewPKCS11Backend(modulePath string, slot uint, pin string) *PKCS11Backend {
+	return &PKCS11Backend{
+		modulePath: modulePath,
+		slot:       slot,
+		pin:        pin,
+	}
+}
+
+// Connect loads the module, opens a session on the configured slot and logs in
+func (p *PKCS11Backend) Connect() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ctx := pkcs11.New(p.modulePath)
+	if ctx == nil {
+		return fmt.Errorf("failed to load PKCS#11 module %s", p.modulePath)
+	}
+
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return fmt.Errorf("failed to initialize PKCS#11 module: %w", err)
+	}
+
+	session, err := ctx.OpenSession(p.slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		ctx.Finalize()
+		ctx.Destroy()
+		return fmt.Errorf("failed to open session on slot %d: %w", p.slot, err)
+	}
+
+	if err := ctx.Login(session, pkcs11.CKU_USER, p.pin); err != nil {
+		ctx.CloseSession(session)
+		ctx.Finalize()
+		ctx.Destroy()
+		return fmt.Errorf("failed to log in to HSM: %w", err)
+	}
+
+	p.ctx = ctx
+	p.session = session
+	return nil
+}
+
+// Close logs out and unloads the module
+func (p *PKCS11Backend) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ctx == nil {
+		return nil
+	}
+
+	p.ctx.Logout(p.session)
+	p.ctx.CloseSession(p.session)
+	err := p.ctx.Finalize()
+	p.ctx.Destroy()
+	p.ctx = nil
+	return err
+}
+
+// GenerateKey creates a persistent ECDSA P-256 key pair labelled keyID
+func (p *PKCS11Backend) GenerateKey(keyID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ctx == nil {
+		return ErrHSMNotConfigured
+	}
+
+	publicTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, oidP256),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, keyID),
+	}
+	privateTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, keyID),
+	}
+
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil)}
+	if _, _, err := p.ctx.GenerateKeyPair(p.session, mechanism, publicTemplate, privateTemplate); err != nil {
+		return fmt.Errorf("failed to generate key %s: %w", keyID, err)
+	}
+
+	return nil
+}
+
+// Sign returns an ASN.1 ECDSA signature over the SHA-256 digest of data
+func (p *PKCS11Backend) Sign(keyID string, data []byte) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ctx == nil {
+		return nil, ErrHSMNotConfigured
+	}
+
+	key, err := p.findObject(pkcs11.CKO_PRIVATE_KEY, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(data)
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)}
+	if err := p.ctx.SignInit(p.session, mechanism, key); err != nil {
+		return nil, fmt.Errorf("failed to initialize signing: %w", err)
+	}
+
+	raw, err := p.ctx.Sign(p.session, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+	if len(raw)%2 != 0 {
+		return nil, fmt.Errorf("unexpected ECDSA signature length %d", len(raw))
+	}
+
+	// PKCS#11 returns r||s; re-encode as ASN.1 so ecdsa.VerifyASN1 can check it
+	half := len(raw) / 2
+	return asn1.Marshal(struct{ R, S *big.Int }{
+		R: new(big.Int).SetBytes(raw[:half]),
+		S: new(big.Int).SetBytes(raw[half:]),
+	})
+}
+
+// PublicKey reads the EC point of the public key labelled keyID
+func (p *PKCS11Backend) PublicKey(keyID string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ctx == nil {
+		return nil, ErrHSMNotConfigured
+	}
+
+	obj, err := p.findObject(pkcs11.CKO_PUBLIC_KEY, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs, err := p.ctx.GetAttributeValue(p.session, obj, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil || len(attrs) == 0 {
+		return nil, fmt.Errorf("failed to read public key %s: %v", keyID, err)
+	}
+
+	// CKA_EC_POINT is a DER OCTET STRING wrapping the uncompressed point
+	var point []byte
+	if _, err := asn1.Unmarshal(attrs[0].Value, &point); err != nil {
+		return nil, fmt.Errorf("failed to decode EC point: %w", err)
+	}
+
+	x, y := elliptic.Unmarshal(elliptic.P256(), point)
+	if x == nil {
+		return nil, fmt.Errorf("invalid EC point for key %s", keyID)
+	}
+
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+}
+
+// findObject returns the single object of the given class labelled keyID
+func (p *PKCS11Backend) findObject(class uint, keyID string) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, keyID),
+	}
+
+	if err := p.ctx.FindObjectsInit(p.session, template); err != nil {
+		return 0, fmt.Errorf("failed to search for key %s: %w", keyID, err)
+	}
+	defer p.ctx.FindObjectsFinal(p.session)
+
+	objects, _, err := p.ctx.FindObjects(p.session, 1)
+	if err != nil {
+		return 0, fmt.Errorf("failed to search for key %s: %w", keyID, err)
+	}
+	if len(objects) == 0 {
+		return 0, fmt.Errorf("key %s not found", keyID)
+	}
+
+	return objects[0], nil
+}
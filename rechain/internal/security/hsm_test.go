@@ -0,0 +1,64 @@
+package security
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnconfiguredHSMRefusesToSign(t *testing.T) {
+	hsm, err := NewHSMManager(HSMConfig{})
+	require.NoError(t, err)
+
+	signature, err := hsm.SignWithHSM([]byte("data"))
+	assert.ErrorIs(t, err, ErrHSMNotConfigured)
+	assert.Nil(t, signature)
+
+	assert.ErrorIs(t, hsm.GenerateKeyWithHSM("key"), ErrHSMNotConfigured)
+	assert.NoError(t, hsm.Close())
+}
+
+func TestHSMRejectsUnknownBackend(t *testing.T) {
+	_, err := NewHSMManager(HSMConfig{Backend: "softhsm-over-tcp"})
+	assert.Error(t, err)
+}
+
+func TestPKCS11BackendFailsWithoutModule(t *testing.T) {
+	_, err := NewHSMManager(HSMConfig{Backend: HSMBackendPKCS11})
+	assert.Error(t, err)
+
+	_, err = NewHSMManager(HSMConfig{
+		Backend:    HSMBackendPKCS11,
+		ModulePath: filepath.Join(t.TempDir(), "missing.so"),
+	})
+	assert.Error(t, err)
+}
+
+func TestMockHSMSignsAndVerifies(t *testing.T) {
+	hsm, err := NewHSMManager(HSMConfig{Backend: HSMBackendMock, KeyID: "node"})
+	require.NoError(t, err)
+	require.NoError(t, hsm.GenerateKeyWithHSM("node"))
+
+	data := []byte("hsm payload")
+	signature, err := hsm.SignWithHSM(data)
+	require.NoError(t, err)
+
+	pub, err := hsm.PublicKey()
+	require.NoError(t, err)
+
+	digest := sha256.Sum256(data)
+	assert.True(t, ecdsa.VerifyASN1(pub.(*ecdsa.PublicKey), digest[:], signature))
+
+	der, err := hsm.PublicKeyBytes()
+	require.NoError(t, err)
+	parsed, err := x509.ParsePKIXPublicKey(der)
+	require.NoError(t, err)
+	assert.True(t, pub.(*ecdsa.PublicKey).Equal(parsed))
+
+	assert.NoError(t, hsm.Close())
+}
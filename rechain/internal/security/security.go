@@ -1,41 +1,186 @@
 package security
 
 import (
+	"crypto"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
+// Supported signature algorithms
+const (
+	AlgorithmRSA     = "rsa"
+	AlgorithmEd25519 = "ed25519"
+)
+
+// ErrEncryptionUnsupported is returned when encrypting with a key that has no RSA component
+var ErrEncryptionUnsupported = errors.New("hybrid encryption requires an RSA key")
+
 // KeyManager manages encryption keys
 type KeyManager struct {
+	algorithm string
+
+	// RSA path: RSA-PSS signatures and AES+RSA hybrid encryption
 	privateKey *rsa.PrivateKey
 	publicKey  *rsa.PublicKey
+
+	// Ed25519 path: signatures only
+	edPrivateKey ed25519.PrivateKey
+	edPublicKey  ed25519.PublicKey
 }
 
 // NewKeyManager creates a new key manager
 func NewKeyManager() (*KeyManager, error) {
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	return NewKeyManagerWithAlgorithm(AlgorithmRSA)
+}
+
+// NewKeyManagerWithAlgorithm creates a key manager that signs with the given algorithm ("rsa" or "ed25519")
+func NewKeyManagerWithAlgorithm(algorithm string) (*KeyManager, error) {
+	switch algorithm {
+	case AlgorithmRSA, "":
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate RSA key: %w", err)
+		}
+		return newKeyManagerFromKey(privateKey)
+	case AlgorithmEd25519:
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate Ed25519 key: %w", err)
+		}
+		return newKeyManagerFromKey(privateKey)
+	default:
+		return nil, fmt.Errorf("unsupported signature algorithm %q", algorithm)
+	}
+}
+
+// newKeyManagerFromKey wraps a parsed private key, deriving the algorithm from its type
+func newKeyManagerFromKey(key crypto.PrivateKey) (*KeyManager, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return &KeyManager{
+			algorithm:  AlgorithmRSA,
+			privateKey: k,
+			publicKey:  &k.PublicKey,
+		}, nil
+	case ed25519.PrivateKey:
+		return &KeyManager{
+			algorithm:    AlgorithmEd25519,
+			edPrivateKey: k,
+			edPublicKey:  k.Public().(ed25519.PublicKey),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+}
+
+// Algorithm returns the signature algorithm of the key manager
+func (km *KeyManager) Algorithm() string {
+	return km.algorithm
+}
+
+// LoadOrCreateKeyManager loads a PKCS#8 PEM private key from path, generating
+// and persisting an RSA key with 0600 perms on first run so keys survive restarts
+func LoadOrCreateKeyManager(path string) (*KeyManager, error) {
+	return LoadOrCreateKeyManagerWithAlgorithm(path, AlgorithmRSA)
+}
+
+// LoadOrCreateKeyManagerWithAlgorithm is like LoadOrCreateKeyManager but generates a key
+// of the given algorithm on first run. An existing key always keeps its own algorithm.
+func LoadOrCreateKeyManagerWithAlgorithm(path, algorithm string) (*KeyManager, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		km, err := NewKeyManagerWithAlgorithm(algorithm)
+		if err != nil {
+			return nil, err
+		}
+		if err := km.SavePrivateKey(path); err != nil {
+			return nil, err
+		}
+		return km, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate RSA key: %w", err)
+		return nil, fmt.Errorf("failed to read key file: %w", err)
 	}
 
-	return &KeyManager{
-		privateKey: privateKey,
-		publicKey:  &privateKey.PublicKey,
-	}, nil
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%s does not contain a PEM private key", path)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	km, err := newKeyManagerFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if stored, ok := block.Headers["Algorithm"]; ok && stored != km.algorithm {
+		return nil, fmt.Errorf("%s is labelled %s but contains a %s key", path, stored, km.algorithm)
+	}
+	if algorithm != "" && algorithm != km.algorithm {
+		log.Printf("Key at %s uses %s, ignoring configured signature algorithm %s", path, km.algorithm, algorithm)
+	}
+
+	return km, nil
+}
+
+// SavePrivateKey writes the private key to path as PKCS#8 PEM with 0600 perms,
+// labelling the block with its algorithm so a loaded key self-describes
+func (km *KeyManager) SavePrivateKey(path string) error {
+	var key crypto.PrivateKey = km.privateKey
+	if km.algorithm == AlgorithmEd25519 {
+		key = km.edPrivateKey
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode private key: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create key directory: %w", err)
+	}
+
+	pemData := pem.EncodeToMemory(&pem.Block{
+		Type:    "PRIVATE KEY",
+		Headers: map[string]string{"Algorithm": km.algorithm},
+		Bytes:   der,
+	})
+	if err := os.WriteFile(path, pemData, 0600); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+
+	return nil
 }
 
 // EncryptData encrypts data with AES-GCM
 func (km *KeyManager) EncryptData(plaintext []byte) ([]byte, []byte, error) {
+	if km.publicKey == nil {
+		return nil, nil, ErrEncryptionUnsupported
+	}
+
 	// Generate random key for AES
 	key := make([]byte, 32) // 256-bit key
 	if _, err := io.ReadFull(rand.Reader, key); err != nil {
@@ -73,6 +218,10 @@ func (km *KeyManager) EncryptData(plaintext []byte) ([]byte, []byte, error) {
 
 // DecryptData decrypts data with AES-GCM
 func (km *KeyManager) DecryptData(ciphertext, encryptedKey []byte) ([]byte, error) {
+	if km.privateKey == nil {
+		return nil, ErrEncryptionUnsupported
+	}
+
 	// Decrypt the AES key
 	key, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, km.privateKey, encryptedKey, nil)
 	if err != nil {
@@ -108,20 +257,80 @@ func (km *KeyManager) DecryptData(ciphertext, encryptedKey []byte) ([]byte, erro
 	return plaintext, nil
 }
 
-// SignData signs data with RSA-PSS
+// SignData signs data with RSA-PSS or Ed25519, depending on the key algorithm
 func (km *KeyManager) SignData(data []byte) ([]byte, error) {
+	if km.algorithm == AlgorithmEd25519 {
+		return ed25519.Sign(km.edPrivateKey, data), nil
+	}
+
 	hashed := sha256.Sum256(data)
-	signature, err := rsa.SignPSS(rand.Reader, km.privateKey, 0, hashed[:], nil)
+	signature, err := rsa.SignPSS(rand.Reader, km.privateKey, crypto.SHA256, hashed[:], nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign data: %w", err)
 	}
 	return signature, nil
 }
 
-// VerifySignature verifies an RSA-PSS signature
+// VerifySignature verifies an RSA-PSS or Ed25519 signature, depending on the key algorithm
 func (km *KeyManager) VerifySignature(data, signature []byte) error {
+	if km.algorithm == AlgorithmEd25519 {
+		if !ed25519.Verify(km.edPublicKey, data, signature) {
+			return fmt.Errorf("invalid ed25519 signature")
+		}
+		return nil
+	}
+
 	hashed := sha256.Sum256(data)
-	return rsa.VerifyPSS(km.publicKey, 0, hashed[:], signature, nil)
+	return rsa.VerifyPSS(km.publicKey, crypto.SHA256, hashed[:], signature, nil)
+}
+
+// PublicKeyBytes returns the public key as clients send it: the raw 32 bytes
+// for Ed25519, PKIX DER for RSA
+func (km *KeyManager) PublicKeyBytes() ([]byte, error) {
+	if km.algorithm == AlgorithmEd25519 {
+		return []byte(km.edPublicKey), nil
+	}
+	return x509.MarshalPKIXPublicKey(km.publicKey)
+}
+
+// ParsePublicKey parses a raw Ed25519 public key or a PKIX DER RSA or Ed25519 key
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	if len(data) == ed25519.PublicKeySize {
+		return ed25519.PublicKey(data), nil
+	}
+	key, err := x509.ParsePKIXPublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	switch key.(type) {
+	case *rsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+}
+
+// VerifyWithPublicKey verifies an RSA-PSS or Ed25519 signature made by the holder of publicKey
+func VerifyWithPublicKey(publicKey crypto.PublicKey, data, signature []byte) error {
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, data, signature) {
+			return fmt.Errorf("invalid ed25519 signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		hashed := sha256.Sum256(data)
+		return rsa.VerifyPSS(key, crypto.SHA256, hashed[:], signature, nil)
+	default:
+		return fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+}
+
+// PublicKeyID identifies the holder of an encoded public key: the first 20
+// bytes of its SHA-256, hex encoded
+func PublicKeyID(publicKey []byte) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:20])
 }
 
 // GenerateNonce generates a random nonce
@@ -165,50 +374,37 @@ func (ts *TransactionSigner) VerifyTransaction(txID string, txData, signature []
 	payload := fmt.Sprintf("%s:%s:%s", ts.nodeID, txID, string(txData))
 
 	hashed := sha256.Sum256([]byte(payload))
-	return rsa.VerifyPSS(signerPublicKey, 0, hashed[:], signature, nil)
+	return rsa.VerifyPSS(signerPublicKey, crypto.SHA256, hashed[:], signature, nil)
 }
 
-// HSMManager provides HSM integration stubs
-type HSMManager struct {
-	connected bool
+// SubmittedTxSigningBytes returns the canonical bytes a client signs to
+// submit a transaction, the same bytes decubectl signs. The id is unique per
+// signing key, so a signed body cannot be submitted twice. encoding/json sorts
+// map keys, so the payload serializes deterministically.
+func SubmittedTxSigningBytes(id, txType string, payload map[string]interface{}, priority int) ([]byte, error) {
+	return json.Marshal(struct {
+		ID       string                 `json:"id"`
+		Type     string                 `json:"type"`
+		Payload  map[string]interface{} `json:"payload"`
+		Priority int                    `json:"priority"`
+	}{id, txType, payload, priority})
 }
 
-// NewHSMManager creates a new HSM manager
-func NewHSMManager(hsmAddress string) (*HSMManager, error) {
-	// Stub implementation - in production, connect to actual HSM
-	log.Printf("Connecting to HSM at %s (stub)", hsmAddress)
-
-	return &HSMManager{
-		connected: true, // Pretend we're connected
-	}, nil
-}
-
-// SignWithHSM signs data using HSM (stub)
-func (hsm *HSMManager) SignWithHSM(data []byte) ([]byte, error) {
-	if !hsm.connected {
-		return nil, fmt.Errorf("HSM not connected")
+// VerifySubmittedTx checks a client's signature over a submitted
+// transaction and returns the signer's identity, PublicKeyID of publicKey
+func VerifySubmittedTx(publicKey, signature []byte, id, txType string, payload map[string]interface{}, priority int) (string, error) {
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return "", err
 	}
-
-	// Stub - in production, use actual HSM signing
-	log.Printf("Signing with HSM (stub): %d bytes", len(data))
-
-	// Generate a fake signature for demo
-	signature := make([]byte, 256)
-	if _, err := io.ReadFull(rand.Reader, signature); err != nil {
-		return nil, err
+	data, err := SubmittedTxSigningBytes(id, txType, payload, priority)
+	if err != nil {
+		return "", err
 	}
-
-	return signature, nil
-}
-
-// GenerateKeyWithHSM generates a key using HSM (stub)
-func (hsm *HSMManager) GenerateKeyWithHSM(keyID string) error {
-	if !hsm.connected {
-		return fmt.Errorf("HSM not connected")
+	if err := VerifyWithPublicKey(key, data, signature); err != nil {
+		return "", err
 	}
-
-	log.Printf("Generating key %s with HSM (stub)", keyID)
-	return nil
+	return PublicKeyID(publicKey), nil
 }
 
 // TLSConfig holds TLS configuration
@@ -245,30 +441,114 @@ func GenerateCertID() string {
 	return uuid.New().String()
 }
 
+// AuditEntry is a single structured audit log record
+type AuditEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	EventType string    `json:"event_type"`
+	Actor     string    `json:"actor"`
+	Resource  string    `json:"resource"`
+	Action    string    `json:"action,omitempty"`
+	Outcome   string    `json:"outcome"`
+	Details   string    `json:"details,omitempty"`
+}
+
+// AuditConfig configures the audit log file and its rotation
+type AuditConfig struct {
+	Path       string
+	MaxSize    int // megabytes before the file is rotated
+	MaxBackups int // rotated files to keep
+	MaxAge     int // days to keep rotated files
+}
+
 // AuditLogger logs security events
 type AuditLogger struct {
 	enabled bool
+
+	mu  sync.Mutex
+	out io.WriteCloser // nil falls back to the standard logger
 }
 
-// NewAuditLogger creates a new audit logger
+// NewAuditLogger creates a new audit logger that writes to the standard logger
 func NewAuditLogger(enabled bool) *AuditLogger {
 	return &AuditLogger{enabled: enabled}
 }
 
-// LogSecurityEvent logs a security event
-func (al *AuditLogger) LogSecurityEvent(eventType, details string) {
+// NewFileAuditLogger creates an audit logger that writes JSON lines to cfg.Path,
+// rotating the file by size and age
+func NewFileAuditLogger(cfg AuditConfig) (*AuditLogger, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("audit log path is required")
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	return &AuditLogger{
+		enabled: true,
+		out: &lumberjack.Logger{
+			Filename:   cfg.Path,
+			MaxSize:    cfg.MaxSize,
+			MaxBackups: cfg.MaxBackups,
+			MaxAge:     cfg.MaxAge,
+		},
+	}, nil
+}
+
+// Log writes an audit entry, stamping it with the current time if unset
+func (al *AuditLogger) Log(entry AuditEntry) {
 	if !al.enabled {
 		return
 	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Failed to encode audit entry: %v", err)
+		return
+	}
 
-	log.Printf("SECURITY EVENT [%s]: %s", eventType, details)
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	if al.out == nil {
+		log.Printf("AUDIT %s", line)
+		return
+	}
+	if _, err := al.out.Write(append(line, '\n')); err != nil {
+		log.Printf("Failed to write audit entry: %v", err)
+	}
+}
+
+// LogSecurityEvent logs a security event
+func (al *AuditLogger) LogSecurityEvent(eventType, details string) {
+	al.Log(AuditEntry{
+		EventType: eventType,
+		Actor:     "system",
+		Outcome:   "recorded",
+		Details:   details,
+	})
 }
 
 // LogAccess logs an access event
 func (al *AuditLogger) LogAccess(resource, action, userID string) {
-	if !al.enabled {
-		return
-	}
+	al.Log(AuditEntry{
+		EventType: "access",
+		Actor:     userID,
+		Resource:  resource,
+		Action:    action,
+		Outcome:   "allowed",
+	})
+}
+
+// Close flushes and closes the audit log file
+func (al *AuditLogger) Close() error {
+	al.mu.Lock()
+	defer al.mu.Unlock()
 
-	log.Printf("ACCESS: %s %s by %s", action, resource, userID)
+	if al.out == nil {
+		return nil
+	}
+	return al.out.Close()
 }
@@ -0,0 +1,198 @@
+package security
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadOrCreateKeyManager(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "node_key.pem")
+
+	km, err := LoadOrCreateKeyManager(path)
+	require.NoError(t, err)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	data := []byte("signed before restart")
+	signature, err := km.SignData(data)
+	require.NoError(t, err)
+
+	ciphertext, encryptedKey, err := km.EncryptData(data)
+	require.NoError(t, err)
+
+	// Reload from the same path, as after a process restart
+	reloaded, err := LoadOrCreateKeyManager(path)
+	require.NoError(t, err)
+
+	assert.NoError(t, reloaded.VerifySignature(data, signature))
+
+	plaintext, err := reloaded.DecryptData(ciphertext, encryptedKey)
+	require.NoError(t, err)
+	assert.Equal(t, data, plaintext)
+}
+
+func TestLoadOrCreateKeyManagerRejectsInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node_key.pem")
+	require.NoError(t, os.WriteFile(path, []byte("not a key"), 0600))
+
+	_, err := LoadOrCreateKeyManager(path)
+	assert.Error(t, err)
+}
+
+func TestEd25519KeyManager(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node_key.pem")
+
+	km, err := LoadOrCreateKeyManagerWithAlgorithm(path, AlgorithmEd25519)
+	require.NoError(t, err)
+	assert.Equal(t, AlgorithmEd25519, km.Algorithm())
+
+	data := []byte("ed25519 payload")
+	signature, err := km.SignData(data)
+	require.NoError(t, err)
+	assert.NoError(t, km.VerifySignature(data, signature))
+	assert.Error(t, km.VerifySignature([]byte("tampered"), signature))
+
+	// Hybrid encryption stays on the RSA path
+	_, _, err = km.EncryptData(data)
+	assert.ErrorIs(t, err, ErrEncryptionUnsupported)
+
+	// The stored key self-describes, whatever algorithm is requested on reload
+	reloaded, err := LoadOrCreateKeyManagerWithAlgorithm(path, AlgorithmRSA)
+	require.NoError(t, err)
+	assert.Equal(t, AlgorithmEd25519, reloaded.Algorithm())
+	assert.NoError(t, reloaded.VerifySignature(data, signature))
+}
+
+func TestSignatureAlgorithmsDoNotCrossVerify(t *testing.T) {
+	rsaKM, err := NewKeyManagerWithAlgorithm(AlgorithmRSA)
+	require.NoError(t, err)
+	edKM, err := NewKeyManagerWithAlgorithm(AlgorithmEd25519)
+	require.NoError(t, err)
+
+	data := []byte("cross-check")
+
+	edSig, err := edKM.SignData(data)
+	require.NoError(t, err)
+	assert.Error(t, rsaKM.VerifySignature(data, edSig))
+
+	rsaSig, err := rsaKM.SignData(data)
+	require.NoError(t, err)
+	assert.Error(t, edKM.VerifySignature(data, rsaSig))
+}
+
+func TestUnsupportedSignatureAlgorithm(t *testing.T) {
+	_, err := NewKeyManagerWithAlgorithm("dsa")
+	assert.Error(t, err)
+}
+
+func TestVerifySubmittedTx(t *testing.T) {
+	payload := map[string]interface{}{"amount": 5, "to": "bob"}
+
+	for _, algorithm := range []string{AlgorithmRSA, AlgorithmEd25519} {
+		t.Run(algorithm, func(t *testing.T) {
+			km, err := NewKeyManagerWithAlgorithm(algorithm)
+			require.NoError(t, err)
+			publicKey, err := km.PublicKeyBytes()
+			require.NoError(t, err)
+
+			data, err := SubmittedTxSigningBytes("tx-1", "transfer", payload, 0)
+			require.NoError(t, err)
+			signature, err := km.SignData(data)
+			require.NoError(t, err)
+
+			sender, err := VerifySubmittedTx(publicKey, signature, "tx-1", "transfer", payload, 0)
+			require.NoError(t, err)
+			assert.Equal(t, PublicKeyID(publicKey), sender)
+
+			// Any signed field changing invalidates the signature
+			_, err = VerifySubmittedTx(publicKey, signature, "tx-2", "transfer", payload, 0)
+			assert.Error(t, err)
+			_, err = VerifySubmittedTx(publicKey, signature, "tx-1", "mint", payload, 0)
+			assert.Error(t, err)
+			_, err = VerifySubmittedTx(publicKey, signature, "tx-1", "transfer", map[string]interface{}{"amount": 500, "to": "bob"}, 0)
+			assert.Error(t, err)
+			_, err = VerifySubmittedTx(publicKey, signature, "tx-1", "transfer", payload, 10)
+			assert.Error(t, err)
+
+			tampered := append([]byte{}, signature...)
+			tampered[0] ^= 0xff
+			_, err = VerifySubmittedTx(publicKey, tampered, "tx-1", "transfer", payload, 0)
+			assert.Error(t, err)
+
+			_, err = VerifySubmittedTx([]byte("not a key"), signature, "tx-1", "transfer", payload, 0)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func benchmarkSign(b *testing.B, algorithm string) {
+	km, err := NewKeyManagerWithAlgorithm(algorithm)
+	require.NoError(b, err)
+	data := []byte("benchmark transaction payload")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := km.SignData(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkVerify(b *testing.B, algorithm string) {
+	km, err := NewKeyManagerWithAlgorithm(algorithm)
+	require.NoError(b, err)
+	data := []byte("benchmark transaction payload")
+	signature, err := km.SignData(data)
+	require.NoError(b, err)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := km.VerifySignature(data, signature); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSignRSA(b *testing.B)       { benchmarkSign(b, AlgorithmRSA) }
+func BenchmarkSignEd25519(b *testing.B)   { benchmarkSign(b, AlgorithmEd25519) }
+func BenchmarkVerifyRSA(b *testing.B)     { benchmarkVerify(b, AlgorithmRSA) }
+func BenchmarkVerifyEd25519(b *testing.B) { benchmarkVerify(b, AlgorithmEd25519) }
+
+func TestFileAuditLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "audit.log")
+
+	al, err := NewFileAuditLogger(AuditConfig{Path: path, MaxSize: 1, MaxBackups: 1, MaxAge: 1})
+	require.NoError(t, err)
+
+	al.LogSecurityEvent("key_rotation", "rotated node key")
+	al.LogAccess("/cas/objects/abc", "read", "user-1")
+	require.NoError(t, al.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	var event AuditEntry
+	require.NoError(t, json.Unmarshal(lines[0], &event))
+	assert.Equal(t, "key_rotation", event.EventType)
+	assert.Equal(t, "system", event.Actor)
+	assert.Equal(t, "rotated node key", event.Details)
+	assert.False(t, event.Timestamp.IsZero())
+
+	var access AuditEntry
+	require.NoError(t, json.Unmarshal(lines[1], &access))
+	assert.Equal(t, "access", access.EventType)
+	assert.Equal(t, "user-1", access.Actor)
+	assert.Equal(t, "/cas/objects/abc", access.Resource)
+	assert.Equal(t, "allowed", access.Outcome)
+}
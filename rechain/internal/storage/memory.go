@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryStore implements the Store interface in memory, for tests and single-process tooling
+type MemoryStore struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: make(map[string][]byte)}
+}
+
+// Get retrieves a value by key, returning nil if the key does not exist
+func (s *MemoryStore) Get(_ context.Context, key []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, exists := s.data[string(key)]
+	if !exists {
+		return nil, nil
+	}
+	return append([]byte{}, value...), nil
+}
+
+// Set sets a value for a key
+func (s *MemoryStore) Set(_ context.Context, key, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data[string(key)] = append([]byte{}, value...)
+	return nil
+}
+
+// Delete removes a key
+func (s *MemoryStore) Delete(_ context.Context, key []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.data, string(key))
+	return nil
+}
+
+// Has checks if a key exists
+func (s *MemoryStore) Has(_ context.Context, key []byte) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, exists := s.data[string(key)]
+	return exists, nil
+}
+
+// Iterate iterates over all keys with the given prefix in key order
+func (s *MemoryStore) Iterate(_ context.Context, prefix []byte, fn func(key, value []byte) error) error {
+	s.mu.RLock()
+	keys := make([]string, 0)
+	for key := range s.data {
+		if strings.HasPrefix(key, string(prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = append([]byte{}, s.data[key]...)
+	}
+	s.mu.RUnlock()
+
+	for i, key := range keys {
+		if err := fn([]byte(key), values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close is a no-op for the in-memory store
+func (s *MemoryStore) Close() error {
+	return nil
+}
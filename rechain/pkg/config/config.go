@@ -46,11 +46,26 @@ type StorageConfig struct {
 // ConsensusConfig holds consensus configuration
 type ConsensusConfig struct {
 	Type        string        `mapstructure:"type"`
+	ChainID     string        `mapstructure:"chain_id"`
+	GenesisTime string        `mapstructure:"genesis_time"` // RFC 3339; the chain's fixed origin time
 	BlockTime   time.Duration `mapstructure:"block_time"`
 	TimeoutPropose time.Duration `mapstructure:"timeout_propose"`
 	TimeoutPrevote time.Duration `mapstructure:"timeout_prevote"`
 	TimeoutPrecommit time.Duration `mapstructure:"timeout_precommit"`
 	TimeoutCommit time.Duration `mapstructure:"timeout_commit"`
+	MaxMempoolSize int          `mapstructure:"max_mempool_size"`
+	MaxBlockSize  int           `mapstructure:"max_block_size"`
+	MaxTxsPerBlock int          `mapstructure:"max_txs_per_block"`
+	SnapshotInterval uint64     `mapstructure:"snapshot_interval"` // heights between state snapshots; 0 disables
+	PruneRetention uint64       `mapstructure:"prune_retention"`   // recent block bodies kept; 0 keeps all
+	Validators    []ValidatorConfig `mapstructure:"validators"`
+}
+
+// ValidatorConfig describes one member of the validator set
+type ValidatorConfig struct {
+	ID          string `mapstructure:"id"`
+	PubKey      string `mapstructure:"pub_key"`
+	VotingPower int64  `mapstructure:"voting_power"`
 }
 
 // CASConfig holds CAS configuration
@@ -61,6 +76,7 @@ type CASConfig struct {
 	SecretKey  string `mapstructure:"secret_key"`
 	ChunkSize  int64  `mapstructure:"chunk_size"`
 	UseSSL     bool   `mapstructure:"use_ssl"`
+	HashAlgorithm string `mapstructure:"hash_algorithm"` // sha256 or blake3
 }
 
 // GossipConfig holds gossip configuration
@@ -70,25 +86,19 @@ type GossipConfig struct {
 	Fanout          int           `mapstructure:"fanout"`
 	GossipInterval  time.Duration `mapstructure:"gossip_interval"`
 	AntiEntropyInterval time.Duration `mapstructure:"anti_entropy_interval"`
+	AuthMode        string        `mapstructure:"auth_mode"`
+	AllowedPeers    []string      `mapstructure:"allowed_peers"`
 }
 
 // APIConfig holds API configuration
 type APIConfig struct {
-	REST RESTConfig `mapstructure:"rest"`
-	GRPC GRPCConfig `mapstructure:"grpc"`
-}
+	Enabled     bool   `mapstructure:"enabled"`
+	RESTAddress string `mapstructure:"rest_address"`
+	GRPCAddress string `mapstructure:"grpc_address"`
 
-// RESTConfig holds REST API configuration
-type RESTConfig struct {
-	Enabled bool     `mapstructure:"enabled"`
-	Address string   `mapstructure:"address"`
-	CORS    []string `mapstructure:"cors"`
-}
-
-// GRPCConfig holds gRPC API configuration
-type GRPCConfig struct {
-	Enabled bool   `mapstructure:"enabled"`
-	Address string `mapstructure:"address"`
+	RateLimitingEnabled bool    `mapstructure:"rate_limiting_enabled"`
+	RateLimitRPS        float64 `mapstructure:"rate_limit_rps"`
+	RateLimitBurst      int     `mapstructure:"rate_limit_burst"`
 }
 
 // SecurityConfig holds security configuration
@@ -97,9 +107,16 @@ type SecurityConfig struct {
 	CertFile      string `mapstructure:"cert_file"`
 	KeyFile       string `mapstructure:"key_file"`
 	CAFile        string `mapstructure:"ca_file"`
+	ClientCertRequired bool `mapstructure:"client_cert_required"`
 	EncryptData   bool   `mapstructure:"encrypt_data"`
 	SignTxs       bool   `mapstructure:"sign_txs"`
+	SignatureAlgorithm string `mapstructure:"signature_algorithm"`
 	HSMEnabled    bool   `mapstructure:"hsm_enabled"`
+	HSMBackend    string `mapstructure:"hsm_backend"`
+	HSMModulePath string `mapstructure:"hsm_module_path"`
+	HSMSlot       uint   `mapstructure:"hsm_slot"`
+	HSMPIN        string `mapstructure:"hsm_pin"`
+	HSMKeyLabel   string `mapstructure:"hsm_key_label"`
 	AuditLogPath  string `mapstructure:"audit_log_path"`
 }
 
@@ -108,6 +125,7 @@ type LoggingConfig struct {
 	Level      string `mapstructure:"level"`
 	Format     string `mapstructure:"format"`
 	Output     string `mapstructure:"output"`
+	FilePath   string `mapstructure:"file_path"`
 	MaxSize    int    `mapstructure:"max_size"`
 	MaxBackups int    `mapstructure:"max_backups"`
 	MaxAge     int    `mapstructure:"max_age"`
@@ -141,19 +159,26 @@ func DefaultConfig() *Config {
 		},
 		Consensus: ConsensusConfig{
 			Type:             "bft",
+			ChainID:          "rechain-mainnet",
+			GenesisTime:      "1970-01-01T00:00:00Z",
 			BlockTime:        1 * time.Second,
 			TimeoutPropose:   3 * time.Second,
 			TimeoutPrevote:   1 * time.Second,
 			TimeoutPrecommit: 1 * time.Second,
 			TimeoutCommit:    1 * time.Second,
+			MaxMempoolSize:   5000,
+			MaxBlockSize:     1024 * 1024, // 1MB
+			MaxTxsPerBlock:   1000,
+			SnapshotInterval: 1000,
 		},
 		CAS: CASConfig{
-			Endpoint:  "localhost:9000",
-			Bucket:    "rechain-objects",
-			AccessKey: "rechain",
-			SecretKey: "rechain123",
-			ChunkSize: 64 * 1024 * 1024, // 64MB
-			UseSSL:    false,
+			Endpoint:      "localhost:9000",
+			Bucket:        "rechain-objects",
+			AccessKey:     "rechain",
+			SecretKey:     "rechain123",
+			ChunkSize:     64 * 1024 * 1024, // 64MB
+			UseSSL:        false,
+			HashAlgorithm: "sha256",
 		},
 		Gossip: GossipConfig{
 			Port:               26656,
@@ -161,17 +186,16 @@ func DefaultConfig() *Config {
 			Fanout:             3,
 			GossipInterval:     100 * time.Millisecond,
 			AntiEntropyInterval: 10 * time.Second,
+			AuthMode:           "open",
+			AllowedPeers:       []string{},
 		},
 		API: APIConfig{
-			REST: RESTConfig{
-				Enabled: true,
-				Address: "0.0.0.0:1317",
-				CORS:    []string{"*"},
-			},
-			GRPC: GRPCConfig{
-				Enabled: true,
-				Address: "0.0.0.0:9090",
-			},
+			Enabled:             true,
+			RESTAddress:         "0.0.0.0:1317",
+			GRPCAddress:         "0.0.0.0:9090",
+			RateLimitingEnabled: true,
+			RateLimitRPS:        100,
+			RateLimitBurst:      200,
 		},
 		Security: SecurityConfig{
 			TLSEnabled:   false,
@@ -180,13 +204,17 @@ func DefaultConfig() *Config {
 			CAFile:       "",
 			EncryptData:  true,
 			SignTxs:      true,
+			SignatureAlgorithm: "rsa",
 			HSMEnabled:   false,
+			HSMBackend:   "pkcs11",
+			HSMKeyLabel:  "rechain-node",
 			AuditLogPath: "./logs/audit.log",
 		},
 		Logging: LoggingConfig{
 			Level:      "info",
 			Format:     "json",
 			Output:     "stdout",
+			FilePath:   "./logs/rechain.log",
 			MaxSize:    100,
 			MaxBackups: 3,
 			MaxAge:     28,
@@ -214,11 +242,18 @@ func LoadConfig(configPath string) (*Config, error) {
 	viper.SetDefault("storage.cache_size", cfg.Storage.CacheSize)
 	viper.SetDefault("storage.sync", cfg.Storage.Sync)
 	viper.SetDefault("consensus.type", cfg.Consensus.Type)
+	viper.SetDefault("consensus.chain_id", cfg.Consensus.ChainID)
+	viper.SetDefault("consensus.genesis_time", cfg.Consensus.GenesisTime)
 	viper.SetDefault("consensus.block_time", cfg.Consensus.BlockTime)
 	viper.SetDefault("consensus.timeout_propose", cfg.Consensus.TimeoutPropose)
 	viper.SetDefault("consensus.timeout_prevote", cfg.Consensus.TimeoutPrevote)
 	viper.SetDefault("consensus.timeout_precommit", cfg.Consensus.TimeoutPrecommit)
 	viper.SetDefault("consensus.timeout_commit", cfg.Consensus.TimeoutCommit)
+	viper.SetDefault("consensus.max_mempool_size", cfg.Consensus.MaxMempoolSize)
+	viper.SetDefault("consensus.max_block_size", cfg.Consensus.MaxBlockSize)
+	viper.SetDefault("consensus.max_txs_per_block", cfg.Consensus.MaxTxsPerBlock)
+	viper.SetDefault("consensus.snapshot_interval", cfg.Consensus.SnapshotInterval)
+	viper.SetDefault("consensus.prune_retention", cfg.Consensus.PruneRetention)
 	viper.SetDefault("cas.endpoint", cfg.CAS.Endpoint)
 	viper.SetDefault("cas.bucket", cfg.CAS.Bucket)
 	viper.SetDefault("cas.access_key", cfg.CAS.AccessKey)
@@ -229,19 +264,27 @@ func LoadConfig(configPath string) (*Config, error) {
 	viper.SetDefault("gossip.fanout", cfg.Gossip.Fanout)
 	viper.SetDefault("gossip.gossip_interval", cfg.Gossip.GossipInterval)
 	viper.SetDefault("gossip.anti_entropy_interval", cfg.Gossip.AntiEntropyInterval)
-	viper.SetDefault("api.rest.enabled", cfg.API.REST.Enabled)
-	viper.SetDefault("api.rest.address", cfg.API.REST.Address)
-	viper.SetDefault("api.rest.cors", cfg.API.REST.CORS)
-	viper.SetDefault("api.grpc.enabled", cfg.API.GRPC.Enabled)
-	viper.SetDefault("api.grpc.address", cfg.API.GRPC.Address)
+	viper.SetDefault("gossip.auth_mode", cfg.Gossip.AuthMode)
+	viper.SetDefault("gossip.allowed_peers", cfg.Gossip.AllowedPeers)
+	viper.SetDefault("api.enabled", cfg.API.Enabled)
+	viper.SetDefault("api.rest_address", cfg.API.RESTAddress)
+	viper.SetDefault("api.grpc_address", cfg.API.GRPCAddress)
+	viper.SetDefault("api.rate_limiting_enabled", cfg.API.RateLimitingEnabled)
+	viper.SetDefault("api.rate_limit_rps", cfg.API.RateLimitRPS)
+	viper.SetDefault("api.rate_limit_burst", cfg.API.RateLimitBurst)
 	viper.SetDefault("security.tls_enabled", cfg.Security.TLSEnabled)
+	viper.SetDefault("security.client_cert_required", cfg.Security.ClientCertRequired)
 	viper.SetDefault("security.encrypt_data", cfg.Security.EncryptData)
 	viper.SetDefault("security.sign_txs", cfg.Security.SignTxs)
+	viper.SetDefault("security.signature_algorithm", cfg.Security.SignatureAlgorithm)
 	viper.SetDefault("security.hsm_enabled", cfg.Security.HSMEnabled)
+	viper.SetDefault("security.hsm_backend", cfg.Security.HSMBackend)
+	viper.SetDefault("security.hsm_key_label", cfg.Security.HSMKeyLabel)
 	viper.SetDefault("security.audit_log_path", cfg.Security.AuditLogPath)
 	viper.SetDefault("logging.level", cfg.Logging.Level)
 	viper.SetDefault("logging.format", cfg.Logging.Format)
 	viper.SetDefault("logging.output", cfg.Logging.Output)
+	viper.SetDefault("logging.file_path", cfg.Logging.FilePath)
 	viper.SetDefault("logging.max_size", cfg.Logging.MaxSize)
 	viper.SetDefault("logging.max_backups", cfg.Logging.MaxBackups)
 	viper.SetDefault("logging.max_age", cfg.Logging.MaxAge)
@@ -0,0 +1,256 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/multiformats/go-multiaddr"
+)
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d configuration problem(s): %s", len(e.Problems), strings.Join(e.Problems, "; "))
+}
+
+// Validate checks the configuration and reports every problem at once as a
+// *ValidationError, so bad values fail at startup instead of deep inside a
+// subsystem
+func (c *Config) Validate() error {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	positive := func(name string, d time.Duration) {
+		if d <= 0 {
+			addf("%s must be positive", name)
+		}
+	}
+
+	// Node
+	if c.Node.DataDir == "" {
+		addf("node.data_dir cannot be empty")
+	}
+
+	// Network
+	if c.Network.MaxPeers <= 0 {
+		addf("network.max_peers must be positive")
+	}
+
+	// Storage
+	if c.Storage.Engine == "" {
+		addf("storage.engine cannot be empty")
+	}
+	if c.Storage.CacheSize < 0 {
+		addf("storage.cache_size cannot be negative")
+	}
+
+	// Consensus
+	if c.Consensus.ChainID == "" {
+		addf("consensus.chain_id cannot be empty")
+	}
+	if _, err := time.Parse(time.RFC3339, c.Consensus.GenesisTime); err != nil {
+		addf("consensus.genesis_time %q is not an RFC 3339 time", c.Consensus.GenesisTime)
+	}
+	positive("consensus.block_time", c.Consensus.BlockTime)
+	positive("consensus.timeout_propose", c.Consensus.TimeoutPropose)
+	positive("consensus.timeout_prevote", c.Consensus.TimeoutPrevote)
+	positive("consensus.timeout_precommit", c.Consensus.TimeoutPrecommit)
+	positive("consensus.timeout_commit", c.Consensus.TimeoutCommit)
+	if c.Consensus.MaxMempoolSize <= 0 {
+		addf("consensus.max_mempool_size must be positive")
+	}
+	if c.Consensus.MaxBlockSize <= 0 {
+		addf("consensus.max_block_size must be positive")
+	}
+	if c.Consensus.MaxTxsPerBlock <= 0 {
+		addf("consensus.max_txs_per_block must be positive")
+	}
+	seen := make(map[string]bool, len(c.Consensus.Validators))
+	for i, v := range c.Consensus.Validators {
+		if v.ID == "" {
+			addf("consensus.validators[%d].id cannot be empty", i)
+		} else if seen[v.ID] {
+			addf("consensus.validators[%d].id %q is a duplicate", i, v.ID)
+		}
+		seen[v.ID] = true
+		if v.VotingPower <= 0 {
+			addf("consensus.validators[%d].voting_power must be positive", i)
+		}
+	}
+
+	// CAS
+	if c.CAS.Endpoint == "" {
+		addf("cas.endpoint cannot be empty")
+	}
+	if c.CAS.Bucket == "" {
+		addf("cas.bucket cannot be empty")
+	}
+	if c.CAS.ChunkSize <= 0 {
+		addf("cas.chunk_size must be positive")
+	}
+	switch c.CAS.HashAlgorithm {
+	case "", "sha256", "blake3":
+	default:
+		addf("cas.hash_algorithm must be sha256 or blake3, got %q", c.CAS.HashAlgorithm)
+	}
+
+	// Gossip
+	if c.Gossip.Port < 0 || c.Gossip.Port > 65535 {
+		addf("gossip.port %d is out of range", c.Gossip.Port)
+	}
+	if c.Gossip.Fanout <= 0 {
+		addf("gossip.fanout must be positive")
+	}
+	positive("gossip.gossip_interval", c.Gossip.GossipInterval)
+	positive("gossip.anti_entropy_interval", c.Gossip.AntiEntropyInterval)
+	switch c.Gossip.AuthMode {
+	case "", "open":
+		if len(c.Gossip.AllowedPeers) > 0 {
+			addf("gossip.allowed_peers is only used when gossip.auth_mode is allowlist")
+		}
+	case "allowlist":
+		if len(c.Gossip.AllowedPeers) == 0 {
+			addf("gossip.allowed_peers cannot be empty when gossip.auth_mode is allowlist")
+		}
+	default:
+		addf("gossip.auth_mode %q must be open or allowlist", c.Gossip.AuthMode)
+	}
+
+	// API
+	if c.API.RateLimitingEnabled {
+		if c.API.RateLimitRPS <= 0 {
+			addf("api.rate_limit_rps must be positive when rate limiting is enabled")
+		}
+		if c.API.RateLimitBurst <= 0 {
+			addf("api.rate_limit_burst must be positive when rate limiting is enabled")
+		}
+	}
+
+	// Security
+	if c.Security.TLSEnabled && (c.Security.CertFile == "" || c.Security.KeyFile == "") {
+		addf("security.cert_file and security.key_file are required when TLS is enabled")
+	}
+	if c.Security.ClientCertRequired && c.Security.CAFile == "" {
+		addf("security.ca_file is required when client certificates are required")
+	}
+	switch c.Security.SignatureAlgorithm {
+	case "", "rsa", "ed25519":
+	default:
+		addf("security.signature_algorithm %q must be rsa or ed25519", c.Security.SignatureAlgorithm)
+	}
+	if c.Security.HSMEnabled && c.Security.HSMBackend == "pkcs11" && c.Security.HSMModulePath == "" {
+		addf("security.hsm_module_path is required for the pkcs11 HSM backend")
+	}
+
+	// Logging
+	switch strings.ToLower(c.Logging.Level) {
+	case "", "debug", "info", "warn", "warning", "error":
+	default:
+		addf("logging.level %q must be debug, info, warn or error", c.Logging.Level)
+	}
+	switch strings.ToLower(c.Logging.Format) {
+	case "", "json", "text":
+	default:
+		addf("logging.format %q must be json or text", c.Logging.Format)
+	}
+	switch c.Logging.Output {
+	case "", "stdout", "stderr":
+	case "file":
+		if c.Logging.FilePath == "" {
+			addf("logging.file_path is required when logging.output is file")
+		}
+	default:
+		addf("logging.output %q must be stdout, stderr or file", c.Logging.Output)
+	}
+
+	// Listen addresses must parse and must not share a port
+	listeners := []struct {
+		name    string
+		addr    string
+		enabled bool
+	}{
+		{"network.listen_address", c.Network.ListenAddress, true},
+		{"api.rest_address", c.API.RESTAddress, true},
+		{"api.grpc_address", c.API.GRPCAddress, true},
+		{"metrics.address", c.Metrics.Address, c.Metrics.Enabled},
+	}
+	type bound struct{ name, host, port string }
+	var bounds []bound
+	for _, l := range listeners {
+		if !l.enabled {
+			continue
+		}
+		host, port, err := listenHostPort(l.addr)
+		if err != nil {
+			addf("%s %q: %v", l.name, l.addr, err)
+			continue
+		}
+		for _, b := range bounds {
+			if port != "0" && port == b.port && hostsOverlap(host, b.host) {
+				addf("%s %q collides with %s", l.name, l.addr, b.name)
+			}
+		}
+		bounds = append(bounds, bound{l.name, host, port})
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// listenHostPort extracts the host and port from a listen address given as
+// host:port, scheme://host:port or an /ip4, /ip6 or /dns multiaddr with /tcp
+func listenHostPort(addr string) (string, string, error) {
+	if addr == "" {
+		return "", "", fmt.Errorf("address cannot be empty")
+	}
+
+	if strings.HasPrefix(addr, "/") {
+		maddr, err := multiaddr.NewMultiaddr(addr)
+		if err != nil {
+			return "", "", fmt.Errorf("not a valid multiaddr: %w", err)
+		}
+		port, err := maddr.ValueForProtocol(multiaddr.P_TCP)
+		if err != nil {
+			return "", "", fmt.Errorf("multiaddr has no /tcp port")
+		}
+		for _, proto := range []int{multiaddr.P_IP4, multiaddr.P_IP6, multiaddr.P_DNS, multiaddr.P_DNS4, multiaddr.P_DNS6} {
+			if host, err := maddr.ValueForProtocol(proto); err == nil {
+				return host, port, nil
+			}
+		}
+		return "", "", fmt.Errorf("multiaddr has no host")
+	}
+
+	if strings.Contains(addr, "://") {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return "", "", fmt.Errorf("not a valid URL: %w", err)
+		}
+		addr = u.Host
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", "", err
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return "", "", fmt.Errorf("invalid port %q", port)
+	}
+	return host, port, nil
+}
+
+// hostsOverlap reports whether listeners on a and b would contend for the same port
+func hostsOverlap(a, b string) bool {
+	wildcard := func(h string) bool { return h == "" || h == "0.0.0.0" || h == "::" }
+	return a == b || wildcard(a) || wildcard(b)
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultConfigIsValid(t *testing.T) {
+	require.NoError(t, DefaultConfig().Validate())
+}
+
+func TestShippedConfigIsValid(t *testing.T) {
+	cfg, err := LoadConfig("../../config/config.yaml")
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+}
+
+func TestValidateChecksShippedListenerKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+api:
+  rest_address: "0.0.0.0:8080"
+  grpc_address: "127.0.0.1:8080"
+`), 0o644))
+
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "0.0.0.0:8080", cfg.API.RESTAddress)
+	assert.Equal(t, "127.0.0.1:8080", cfg.API.GRPCAddress)
+
+	err = cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `api.grpc_address "127.0.0.1:8080" collides with api.rest_address`)
+}
+
+func TestValidateRejectsInvalidFields(t *testing.T) {
+	cases := []struct {
+		name    string
+		mutate  func(*Config)
+		problem string
+	}{
+		{"empty data dir", func(c *Config) { c.Node.DataDir = "" }, "node.data_dir"},
+		{"zero max peers", func(c *Config) { c.Network.MaxPeers = 0 }, "network.max_peers"},
+		{"bad listen address", func(c *Config) { c.Network.ListenAddress = "/ip4/0.0.0.0/udp/1" }, "network.listen_address"},
+		{"empty storage engine", func(c *Config) { c.Storage.Engine = "" }, "storage.engine"},
+		{"negative cache size", func(c *Config) { c.Storage.CacheSize = -1 }, "storage.cache_size"},
+		{"empty chain id", func(c *Config) { c.Consensus.ChainID = "" }, "consensus.chain_id"},
+		{"bad genesis time", func(c *Config) { c.Consensus.GenesisTime = "yesterday" }, "consensus.genesis_time"},
+		{"zero block time", func(c *Config) { c.Consensus.BlockTime = 0 }, "consensus.block_time"},
+		{"negative propose timeout", func(c *Config) { c.Consensus.TimeoutPropose = -1 }, "consensus.timeout_propose"},
+		{"zero prevote timeout", func(c *Config) { c.Consensus.TimeoutPrevote = 0 }, "consensus.timeout_prevote"},
+		{"zero precommit timeout", func(c *Config) { c.Consensus.TimeoutPrecommit = 0 }, "consensus.timeout_precommit"},
+		{"zero commit timeout", func(c *Config) { c.Consensus.TimeoutCommit = 0 }, "consensus.timeout_commit"},
+		{"zero mempool size", func(c *Config) { c.Consensus.MaxMempoolSize = 0 }, "consensus.max_mempool_size"},
+		{"zero block size", func(c *Config) { c.Consensus.MaxBlockSize = 0 }, "consensus.max_block_size"},
+		{"negative txs per block", func(c *Config) { c.Consensus.MaxTxsPerBlock = -1 }, "consensus.max_txs_per_block"},
+		{"validator without id", func(c *Config) {
+			c.Consensus.Validators = []ValidatorConfig{{VotingPower: 1}}
+		}, "consensus.validators[0].id"},
+		{"duplicate validator", func(c *Config) {
+			c.Consensus.Validators = []ValidatorConfig{{ID: "a", VotingPower: 1}, {ID: "a", VotingPower: 1}}
+		}, "consensus.validators[1].id"},
+		{"validator without power", func(c *Config) {
+			c.Consensus.Validators = []ValidatorConfig{{ID: "a"}}
+		}, "consensus.validators[0].voting_power"},
+		{"empty cas endpoint", func(c *Config) { c.CAS.Endpoint = "" }, "cas.endpoint"},
+		{"empty cas bucket", func(c *Config) { c.CAS.Bucket = "" }, "cas.bucket"},
+		{"zero chunk size", func(c *Config) { c.CAS.ChunkSize = 0 }, "cas.chunk_size"},
+		{"unknown hash algorithm", func(c *Config) { c.CAS.HashAlgorithm = "md5" }, "cas.hash_algorithm"},
+		{"gossip port out of range", func(c *Config) { c.Gossip.Port = 70000 }, "gossip.port"},
+		{"negative fanout", func(c *Config) { c.Gossip.Fanout = -1 }, "gossip.fanout"},
+		{"zero gossip interval", func(c *Config) { c.Gossip.GossipInterval = 0 }, "gossip.gossip_interval"},
+		{"zero anti-entropy interval", func(c *Config) { c.Gossip.AntiEntropyInterval = 0 }, "gossip.anti_entropy_interval"},
+		{"unknown auth mode", func(c *Config) { c.Gossip.AuthMode = "strict" }, "gossip.auth_mode"},
+		{"empty allowlist", func(c *Config) { c.Gossip.AuthMode = "allowlist" }, "gossip.allowed_peers"},
+		{"zero rate limit", func(c *Config) { c.API.RateLimitRPS = 0 }, "api.rate_limit_rps"},
+		{"zero rate limit burst", func(c *Config) { c.API.RateLimitBurst = 0 }, "api.rate_limit_burst"},
+		{"bad rest address", func(c *Config) { c.API.RESTAddress = "localhost" }, "api.rest_address"},
+		{"bad grpc port", func(c *Config) { c.API.GRPCAddress = "0.0.0.0:99999" }, "api.grpc_address"},
+		{"rest and grpc collide", func(c *Config) { c.API.GRPCAddress = "127.0.0.1:1317" }, "api.grpc_address"},
+		{"metrics collides with rest", func(c *Config) { c.Metrics.Address = "0.0.0.0:1317" }, "metrics.address"},
+		{"tls without cert", func(c *Config) { c.Security.TLSEnabled = true }, "security.cert_file"},
+		{"client certs without ca", func(c *Config) { c.Security.ClientCertRequired = true }, "security.ca_file"},
+		{"unknown signature algorithm", func(c *Config) { c.Security.SignatureAlgorithm = "dsa" }, "security.signature_algorithm"},
+		{"pkcs11 without module", func(c *Config) { c.Security.HSMEnabled = true }, "security.hsm_module_path"},
+		{"unknown log level", func(c *Config) { c.Logging.Level = "verbose" }, "logging.level"},
+		{"unknown log format", func(c *Config) { c.Logging.Format = "xml" }, "logging.format"},
+		{"log file without path", func(c *Config) {
+			c.Logging.Output = "file"
+			c.Logging.FilePath = ""
+		}, "logging.file_path"},
+		{"unknown log output", func(c *Config) { c.Logging.Output = "syslog" }, "logging.output"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			tc.mutate(cfg)
+
+			err := cfg.Validate()
+			var verr *ValidationError
+			require.True(t, errors.As(err, &verr), "expected *ValidationError, got %v", err)
+			require.Len(t, verr.Problems, 1, "problems: %v", verr.Problems)
+			assert.Contains(t, verr.Problems[0], tc.problem)
+		})
+	}
+}
+
+func TestValidateAggregatesProblems(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Gossip.Fanout = -1
+	cfg.CAS.Bucket = ""
+	cfg.Consensus.BlockTime = 0
+
+	var verr *ValidationError
+	require.ErrorAs(t, cfg.Validate(), &verr)
+	assert.Len(t, verr.Problems, 3)
+}
+
+func TestDisabledListenersAreNotChecked(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Metrics.Enabled = false
+	cfg.Metrics.Address = "not an address"
+
+	assert.NoError(t, cfg.Validate())
+}
@@ -0,0 +1,192 @@
+package crdt_test
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/rechain/rechain/pkg/crdt"
+	"github.com/stretchr/testify/require"
+)
+
+// Run with -crdt.seed=<seed> to replay the operation scripts of a failed run
+var convergenceSeed = flag.Int64("crdt.seed", 0, "seed for the CRDT convergence tests (0 picks a random seed)")
+
+const (
+	convergenceReplicas = 4
+	convergenceRuns     = 200
+	convergenceMaxOps   = 64
+	convergenceElements = 5
+)
+
+type opKind int
+
+const (
+	opAdd    opKind = iota // Add, Increment or Set
+	opRemove               // Remove, Decrement or Set(nil)
+	opMerge                // merge the state of another replica
+)
+
+// replicaOp is one step of a convergence script, applied to Replica
+type replicaOp struct {
+	Replica int
+	Kind    opKind
+	Element string
+	Amount  int64
+	From    int
+}
+
+// opScript is a random interleaving of updates and merges across replicas
+type opScript []replicaOp
+
+// Generate implements quick.Generator
+func (opScript) Generate(r *rand.Rand, size int) reflect.Value {
+	n := r.Intn(convergenceMaxOps) + 1
+	script := make(opScript, n)
+	for i := range script {
+		script[i] = replicaOp{
+			Replica: r.Intn(convergenceReplicas),
+			Kind:    opKind(r.Intn(3)),
+			Element: fmt.Sprintf("e%d", r.Intn(convergenceElements)),
+			Amount:  r.Int63n(10) + 1,
+			From:    r.Intn(convergenceReplicas),
+		}
+	}
+	return reflect.ValueOf(script)
+}
+
+// convergenceTarget adapts one CRDT type to the harness
+type convergenceTarget struct {
+	name  string
+	new   func(nodeID string) crdt.CRDT
+	apply func(replica crdt.CRDT, op replicaOp)
+	state func(replica crdt.CRDT) string
+}
+
+func TestCRDTConvergence(t *testing.T) {
+	seed := *convergenceSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	t.Logf("crdt convergence seed: %d (rerun with -crdt.seed=%d)", seed, seed)
+
+	targets := []convergenceTarget{
+		{
+			name: "LWWRegister",
+			new:  func(nodeID string) crdt.CRDT { return crdt.NewLWWRegister(nodeID) },
+			apply: func(replica crdt.CRDT, op replicaOp) {
+				reg := replica.(*crdt.LWWRegister)
+				if op.Kind == opAdd {
+					reg.Set(op.Element)
+				} else {
+					reg.Set(nil)
+				}
+			},
+			state: func(replica crdt.CRDT) string {
+				return fmt.Sprint(replica.(*crdt.LWWRegister).GetValue())
+			},
+		},
+		{
+			name: "PNCounter",
+			new:  func(nodeID string) crdt.CRDT { return crdt.NewPNCounter(nodeID) },
+			apply: func(replica crdt.CRDT, op replicaOp) {
+				counter := replica.(*crdt.PNCounter)
+				if op.Kind == opAdd {
+					counter.Increment(op.Amount)
+				} else {
+					counter.Decrement(op.Amount)
+				}
+			},
+			state: func(replica crdt.CRDT) string { return fmt.Sprint(replica.Value()) },
+		},
+		{
+			name: "GCounter",
+			new:  func(nodeID string) crdt.CRDT { return crdt.NewGCounter(nodeID) },
+			apply: func(replica crdt.CRDT, op replicaOp) {
+				replica.(*crdt.GCounter).Increment(op.Amount)
+			},
+			state: func(replica crdt.CRDT) string { return fmt.Sprint(replica.Value()) },
+		},
+		{
+			name: "ORSet",
+			new:  func(nodeID string) crdt.CRDT { return crdt.NewORSet(nodeID) },
+			apply: func(replica crdt.CRDT, op replicaOp) {
+				set := replica.(*crdt.ORSet)
+				if op.Kind == opAdd {
+					set.Add(op.Element)
+				} else {
+					set.Remove(op.Element)
+				}
+			},
+			state: func(replica crdt.CRDT) string {
+				var elements []string
+				for _, e := range replica.(*crdt.ORSet).Elements() {
+					elements = append(elements, fmt.Sprint(e))
+				}
+				sort.Strings(elements)
+				return strings.Join(elements, ",")
+			},
+		},
+	}
+
+	for i, target := range targets {
+		target := target
+		rng := rand.New(rand.NewSource(seed + int64(i)))
+
+		t.Run(target.name, func(t *testing.T) {
+			converges := func(script opScript) bool {
+				states := runConvergenceScript(t, target, script, rng)
+				for _, state := range states[1:] {
+					if state != states[0] {
+						t.Logf("replicas diverged: %q", states)
+						return false
+					}
+				}
+				return true
+			}
+
+			err := quick.Check(converges, &quick.Config{MaxCount: convergenceRuns, Rand: rng})
+			require.NoError(t, err, "seed %d", seed)
+		})
+	}
+}
+
+// runConvergenceScript applies script to fresh replicas, then has every replica
+// merge all the others in its own random order and returns their final states
+func runConvergenceScript(t *testing.T, target convergenceTarget, script opScript, rng *rand.Rand) []string {
+	replicas := make([]crdt.CRDT, convergenceReplicas)
+	for i := range replicas {
+		replicas[i] = target.new(fmt.Sprintf("node-%d", i))
+	}
+
+	for _, op := range script {
+		replica := replicas[op.Replica]
+		if op.Kind == opMerge {
+			if op.From != op.Replica {
+				require.NoError(t, replica.Merge(replicas[op.From]))
+			}
+			continue
+		}
+		target.apply(replica, op)
+	}
+
+	for i, replica := range replicas {
+		for _, j := range rng.Perm(convergenceReplicas) {
+			if j != i {
+				require.NoError(t, replica.Merge(replicas[j]))
+			}
+		}
+	}
+
+	states := make([]string, convergenceReplicas)
+	for i, replica := range replicas {
+		states[i] = target.state(replica)
+	}
+	return states
+}
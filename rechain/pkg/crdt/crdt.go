@@ -31,6 +31,26 @@ type CRDT interface {
 
 	// Unmarshal deserializes the CRDT from bytes
 	Unmarshal(data []byte) error
+
+	// Stats reports how large the CRDT has grown
+	Stats() CRDTStats
+}
+
+// CRDTStats describes the size of a CRDT. TombstoneCount counts the removal
+// markers kept so that removes converge; they only go away on compaction.
+type CRDTStats struct {
+	EntryCount     int `json:"entry_count"`
+	TombstoneCount int `json:"tombstone_count"`
+	ApproxBytes    int `json:"approx_bytes"` // size of the serialized form
+}
+
+// approxBytes estimates the size of c from its serialized form
+func approxBytes(c CRDT) int {
+	data, err := c.Marshal()
+	if err != nil {
+		return 0
+	}
+	return len(data)
 }
 
 // New creates a new CRDT instance of the specified type
@@ -117,3 +117,12 @@ func (c *GCounter) Unmarshal(data []byte) error {
 
 	return nil
 }
+
+// Stats implements the CRDT interface. Each node's count is one entry.
+func (c *GCounter) Stats() CRDTStats {
+	c.mu.RLock()
+	entries := len(c.counts)
+	c.mu.RUnlock()
+
+	return CRDTStats{EntryCount: entries, ApproxBytes: approxBytes(c)}
+}
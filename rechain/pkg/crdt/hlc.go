@@ -0,0 +1,114 @@
+package crdt
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// HLCTimestamp is a reading of a hybrid logical clock. Timestamps are ordered
+// by wall time, then by the logical counter, then by node ID, so two distinct
+// nodes never produce equal timestamps.
+type HLCTimestamp struct {
+	WallTime int64  `json:"wall_time"` // nanoseconds since the Unix epoch
+	Logical  uint32 `json:"logical"`
+	NodeID   string `json:"node_id"`
+}
+
+// Compare compares two timestamps
+// Returns -1 if t < other, 0 if t == other, 1 if t > other
+func (t HLCTimestamp) Compare(other HLCTimestamp) int {
+	switch {
+	case t.WallTime != other.WallTime:
+		return compareInts(t.WallTime, other.WallTime)
+	case t.Logical != other.Logical:
+		return compareInts(int64(t.Logical), int64(other.Logical))
+	case t.NodeID < other.NodeID:
+		return -1
+	case t.NodeID > other.NodeID:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// String returns the timestamp as wall.logical@node, which is unique per event
+func (t HLCTimestamp) String() string {
+	return fmt.Sprintf("%d.%d@%s", t.WallTime, t.Logical, t.NodeID)
+}
+
+// HLC is a hybrid logical clock. It follows the local wall clock when that is
+// ahead, and otherwise advances a logical counter, so timestamps never go
+// backwards and an event observed from another node is always ordered before
+// anything that happens locally afterwards, even when the wall clocks disagree.
+type HLC struct {
+	nodeID string
+	wall   func() int64
+	mu     sync.Mutex
+	last   HLCTimestamp
+}
+
+// NewHLC creates a hybrid logical clock for nodeID backed by the system clock
+func NewHLC(nodeID string) *HLC {
+	return NewHLCWithClock(nodeID, func() int64 { return time.Now().UnixNano() })
+}
+
+// NewHLCWithClock creates a hybrid logical clock that reads wall time from wall
+func NewHLCWithClock(nodeID string, wall func() int64) *HLC {
+	return &HLC{
+		nodeID: nodeID,
+		wall:   wall,
+		last:   HLCTimestamp{NodeID: nodeID},
+	}
+}
+
+// Now returns a timestamp for a local event, greater than any timestamp the
+// clock has returned or observed before
+func (c *HLC) Now() HLCTimestamp {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if wall := c.wall(); wall > c.last.WallTime {
+		c.last.WallTime = wall
+		c.last.Logical = 0
+	} else {
+		c.last.Logical++
+	}
+	return c.last
+}
+
+// Update records a timestamp received from another node and returns a local
+// timestamp greater than both it and anything the clock has returned before
+func (c *HLC) Update(remote HLCTimestamp) HLCTimestamp {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wall := c.wall()
+	switch {
+	case wall > c.last.WallTime && wall > remote.WallTime:
+		c.last.WallTime = wall
+		c.last.Logical = 0
+	case c.last.WallTime == remote.WallTime:
+		if remote.Logical > c.last.Logical {
+			c.last.Logical = remote.Logical
+		}
+		c.last.Logical++
+	case c.last.WallTime > remote.WallTime:
+		c.last.Logical++
+	default:
+		c.last.WallTime = remote.WallTime
+		c.last.Logical = remote.Logical + 1
+	}
+	return c.last
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,57 @@
+package crdt_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rechain/rechain/pkg/crdt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHLC(t *testing.T) {
+	t.Run("MonotonicWhenWallClockStepsBack", func(t *testing.T) {
+		wall := time.Now().UnixNano()
+		clock := crdt.NewHLCWithClock("node1", func() int64 { return wall })
+
+		first := clock.Now()
+		wall -= int64(time.Second)
+		second := clock.Now()
+
+		assert.Equal(t, 1, second.Compare(first))
+		assert.Equal(t, first.WallTime, second.WallTime)
+		assert.Equal(t, first.Logical+1, second.Logical)
+	})
+
+	t.Run("UpdateMovesPastRemote", func(t *testing.T) {
+		wall := time.Now().UnixNano()
+		clock := crdt.NewHLCWithClock("node1", func() int64 { return wall })
+
+		remote := crdt.HLCTimestamp{WallTime: wall + int64(time.Minute), Logical: 3, NodeID: "node2"}
+		observed := clock.Update(remote)
+		assert.Equal(t, 1, observed.Compare(remote))
+		assert.Equal(t, 1, clock.Now().Compare(observed))
+	})
+
+	t.Run("OrdersCausallyUnderClockSkew", func(t *testing.T) {
+		// node-a's wall clock runs a second ahead of node-b's
+		base := time.Now().UnixNano()
+		fast := crdt.NewHLCWithClock("node-a", func() int64 { return base + int64(time.Second) })
+		slow := crdt.NewHLCWithClock("node-b", func() int64 { return base })
+
+		a := crdt.NewLWWRegisterWithClock("node-a", fast)
+		b := crdt.NewLWWRegisterWithClock("node-b", slow)
+
+		// node-b overwrites node-a's value after having seen it
+		a.Set("first")
+		require.NoError(t, b.Merge(a))
+		b.Set("second")
+
+		// Raw wall clock readings would order the writes the other way round
+		assert.Less(t, base, a.Timestamp.WallTime)
+
+		require.NoError(t, a.Merge(b))
+		assert.Equal(t, "second", a.GetValue())
+		assert.Equal(t, "second", b.GetValue())
+	})
+}
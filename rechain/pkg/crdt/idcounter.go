@@ -239,6 +239,20 @@ func (c *IDCounter) Unmarshal(data []byte) error {
 	return nil
 }
 
+// Stats implements the CRDT interface. Each node's increment and decrement
+// totals count as one entry each.
+func (c *IDCounter) Stats() CRDTStats {
+	var entries int
+	count := func(key, value interface{}) bool {
+		entries++
+		return true
+	}
+	c.p.Range(count)
+	c.n.Range(count)
+
+	return CRDTStats{EntryCount: entries, ApproxBytes: approxBytes(c)}
+}
+
 // ApplyOperation applies an operation to the counter
 func (c *IDCounter) ApplyOperation(op Operation) error {
 	switch op.Type {
@@ -5,19 +5,29 @@ import (
 	"fmt"
 )
 
-// LWWRegister is a Last-Write-Wins Register CRDT
+// LWWRegister is a Last-Write-Wins Register CRDT. NodeID is the replica
+// that owns the register and stamps its writes; the node that wrote the
+// current value is Timestamp.NodeID, see Writer.
 type LWWRegister struct {
-	NodeID    string    `json:"node_id"`
-	Value     any       `json:"value"`
-	Timestamp Timestamp `json:"timestamp"`
+	NodeID    string       `json:"node_id"`
+	Value     any          `json:"value"`
+	Timestamp HLCTimestamp `json:"timestamp"`
+
+	clock *HLC
 }
 
-// NewLWWRegister creates a new LWWRegister
+// NewLWWRegister creates a new LWWRegister with its own hybrid logical clock
 func NewLWWRegister(nodeID string) *LWWRegister {
+	return NewLWWRegisterWithClock(nodeID, NewHLC(nodeID))
+}
+
+// NewLWWRegisterWithClock creates a new LWWRegister that timestamps writes
+// with clock, which may be shared with the node's other CRDTs
+func NewLWWRegisterWithClock(nodeID string, clock *HLC) *LWWRegister {
 	return &LWWRegister{
-		NodeID:    nodeID,
-		Value:     nil,
-		Timestamp: NewTimestamp(),
+		NodeID: nodeID,
+		Value:  nil,
+		clock:  clock,
 	}
 }
 
@@ -34,7 +44,12 @@ func (r *LWWRegister) GetValue() any {
 // Set updates the value with a new value and timestamp
 func (r *LWWRegister) Set(value any) {
 	r.Value = value
-	r.Timestamp = NewTimestamp()
+	r.Timestamp = r.hlc().Now()
+}
+
+// Writer returns the node that wrote the current value
+func (r *LWWRegister) Writer() string {
+	return r.Timestamp.NodeID
 }
 
 // Merge merges another LWWRegister
@@ -44,11 +59,14 @@ func (r *LWWRegister) Merge(other CRDT) error {
 		return fmt.Errorf("%w: expected LWWRegister, got %T", ErrIncompatibleTypes, other)
 	}
 
-	// Keep the value with the latest timestamp
+	// Observe the other write so later local writes are ordered after it
+	r.hlc().Update(otherReg.Timestamp)
+
+	// Keep the value with the latest timestamp. Timestamps from different
+	// nodes never compare equal, so both replicas pick the same write.
 	if otherReg.Timestamp.Compare(r.Timestamp) > 0 {
 		r.Value = otherReg.Value
 		r.Timestamp = otherReg.Timestamp
-		r.NodeID = otherReg.NodeID
 	}
 
 	return nil
@@ -59,12 +77,41 @@ func (r *LWWRegister) Marshal() ([]byte, error) {
 	return json.Marshal(r)
 }
 
-// Unmarshal deserializes the LWWRegister from JSON
+// Unmarshal deserializes the LWWRegister from JSON. A register that already
+// has an owner keeps it, so loading another replica's state does not make
+// later writes look like that replica's.
 func (r *LWWRegister) Unmarshal(data []byte) error {
-	return json.Unmarshal(data, r)
+	owner := r.NodeID
+	if err := json.Unmarshal(data, r); err != nil {
+		return err
+	}
+	if owner != "" {
+		r.NodeID = owner
+	}
+	// Order later local writes after the loaded one
+	r.hlc().Update(r.Timestamp)
+	return nil
 }
 
 // Value implements the CRDT interface
 func (r *LWWRegister) Value() interface{} {
 	return r.GetValue()
 }
+
+// hlc returns the register's clock, creating one for the owner of a register
+// that was unmarshaled rather than constructed
+func (r *LWWRegister) hlc() *HLC {
+	if r.clock == nil {
+		r.clock = NewHLC(r.NodeID)
+	}
+	return r.clock
+}
+
+// Stats implements the CRDT interface. A register holds one entry once written.
+func (r *LWWRegister) Stats() CRDTStats {
+	stats := CRDTStats{ApproxBytes: approxBytes(r)}
+	if r.Timestamp != (HLCTimestamp{}) {
+		stats.EntryCount = 1
+	}
+	return stats
+}
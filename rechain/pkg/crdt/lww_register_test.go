@@ -51,6 +51,8 @@ func TestLWWRegister(t *testing.T) {
 		testTime := time.Now().UTC()
 		reg1.Set("value from node1")
 		reg2.Set("value from node2")
+		reg1.Timestamp = crdt.HLCTimestamp{WallTime: testTime.UnixNano(), NodeID: node1}
+		reg2.Timestamp = crdt.HLCTimestamp{WallTime: testTime.UnixNano(), NodeID: node2}
 
 		// Merge should prefer the value from the register with the node that has the higher ID
 		err := reg1.Merge(reg2)
@@ -64,6 +66,27 @@ func TestLWWRegister(t *testing.T) {
 		assert.Equal(t, expectedValue, reg1.GetValue())
 	})
 
+	t.Run("MergeEqualTimestamps", func(t *testing.T) {
+		reg1 := crdt.NewLWWRegister(node1)
+		reg2 := crdt.NewLWWRegister(node2)
+
+		reg1.Set("value from node1")
+		reg2.Set("value from node2")
+		reg2.Timestamp.WallTime = reg1.Timestamp.WallTime
+		reg2.Timestamp.Logical = reg1.Timestamp.Logical
+
+		// Merge in both directions; each replica must pick the same write
+		a, b := *reg1, *reg2
+		assert.NoError(t, reg1.Merge(&b))
+		assert.NoError(t, reg2.Merge(&a))
+		assert.Equal(t, "value from node2", reg1.GetValue())
+		assert.Equal(t, reg1.GetValue(), reg2.GetValue())
+
+		// Merging again changes nothing
+		assert.NoError(t, reg1.Merge(reg2))
+		assert.Equal(t, "value from node2", reg1.GetValue())
+	})
+
 	t.Run("MarshalUnmarshal", func(t *testing.T) {
 		reg1 := crdt.NewLWWRegister(node1)
 		reg1.Set("test value")
@@ -78,6 +101,32 @@ func TestLWWRegister(t *testing.T) {
 		assert.Equal(t, reg1.GetValue(), reg2.GetValue())
 	})
 
+	t.Run("OwnerSurvivesMergeAndUnmarshal", func(t *testing.T) {
+		reg1 := crdt.NewLWWRegister(node1)
+		reg2 := crdt.NewLWWRegister(node2)
+		reg1.Set("value from node1")
+		time.Sleep(time.Millisecond)
+		reg2.Set("value from node2")
+
+		// node2 wrote the value node1 now holds, but node1 still owns the register
+		assert.NoError(t, reg1.Merge(reg2))
+		assert.Equal(t, node1, reg1.NodeID)
+		assert.Equal(t, node2, reg1.Writer())
+		reg1.Set("later value from node1")
+		assert.Equal(t, node1, reg1.Writer())
+
+		// Loading node2's state into node1's register keeps node1 as the owner
+		data, err := reg2.Marshal()
+		assert.NoError(t, err)
+		loaded := crdt.NewLWWRegister(node1)
+		assert.NoError(t, loaded.Unmarshal(data))
+		assert.Equal(t, node1, loaded.NodeID)
+		assert.Equal(t, node2, loaded.Writer())
+		loaded.Set("value from node1")
+		assert.Equal(t, node1, loaded.Writer())
+		assert.Equal(t, "value from node1", loaded.GetValue())
+	})
+
 	t.Run("IncompatibleMerge", func(t *testing.T) {
 		reg := crdt.NewLWWRegister(node1)
 		counter := crdt.NewPNCounter(node1)
@@ -10,6 +10,7 @@ import (
 // ORSet is an Observed-Removed Set CRDT
 type ORSet struct {
 	nodeID string
+	clock  *HLC
 	mu     sync.RWMutex
 	adds   map[interface{}]map[string]struct{} // value -> set of add tags
 	dels   map[interface{}]map[string]struct{} // value -> set of remove tags
@@ -19,6 +20,7 @@ type ORSet struct {
 func NewORSet(nodeID string) *ORSet {
 	return &ORSet{
 		nodeID: nodeID,
+		clock:  NewHLC(nodeID),
 		adds:   make(map[interface{}]map[string]struct{}),
 		dels:   make(map[interface{}]map[string]struct{}),
 	}
@@ -64,39 +66,55 @@ func (s *ORSet) Contains(element interface{}) bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	adds, added := s.adds[element]
-	if !added {
-		return false
-	}
-
-	dels, deleted := s.dels[element]
-	if !deleted {
-		return true
-	}
+	return s.contains(element)
+}
 
-	// An element is in the set if it has at least one add tag that is not in the remove set
-	for tag := range adds {
-		if _, exists := dels[tag]; !exists {
-			return true
-		}
-	}
+// Elements returns every element in the set once, sorted by its printed form
+// so the order is deterministic
+func (s *ORSet) Elements() []interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	return false
+	return s.elements()
 }
 
-// Elements returns all elements in the set
-func (s *ORSet) Elements() []interface{} {
+// Size returns the number of elements in the set
+func (s *ORSet) Size() int {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var elements []interface{}
+	return len(s.elements())
+}
+
+// contains reports whether element has at least one add tag that is not in
+// the remove set. The caller holds s.mu.
+func (s *ORSet) contains(element interface{}) bool {
+	dels := s.dels[element]
+	for tag := range s.adds[element] {
+		if _, removed := dels[tag]; !removed {
+			return true
+		}
+	}
+	return false
+}
 
+// elements lists the elements in the set, sorted. Elements that print alike,
+// such as 1 and "1", are ordered by type name. The caller holds s.mu.
+func (s *ORSet) elements() []interface{} {
+	elements := make([]interface{}, 0, len(s.adds))
 	for element := range s.adds {
-		if s.Contains(element) {
+		if s.contains(element) {
 			elements = append(elements, element)
 		}
 	}
 
+	sort.Slice(elements, func(i, j int) bool {
+		a, b := fmt.Sprint(elements[i]), fmt.Sprint(elements[j])
+		if a != b {
+			return a < b
+		}
+		return fmt.Sprintf("%T", elements[i]) < fmt.Sprintf("%T", elements[j])
+	})
 	return elements
 }
 
@@ -218,7 +236,47 @@ func (s *ORSet) Unmarshal(data []byte) error {
 	return nil
 }
 
-// generateTag generates a unique tag for an operation
+// Stats implements the CRDT interface. Entries are add tags that have not
+// been removed; every removed tag is kept as a tombstone until Compact.
+func (s *ORSet) Stats() CRDTStats {
+	s.mu.RLock()
+	var stats CRDTStats
+	for element, tags := range s.adds {
+		for tag := range tags {
+			if _, removed := s.dels[element][tag]; !removed {
+				stats.EntryCount++
+			}
+		}
+	}
+	for _, tags := range s.dels {
+		stats.TombstoneCount += len(tags)
+	}
+	s.mu.RUnlock()
+
+	stats.ApproxBytes = approxBytes(s)
+	return stats
+}
+
+// Compact drops removed add tags together with their tombstones. It is only
+// safe once every replica has merged the removes: merging a replica that still
+// holds a dropped add tag would bring the element back.
+func (s *ORSet) Compact() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for element, tags := range s.dels {
+		for tag := range tags {
+			delete(s.adds[element], tag)
+		}
+		if len(s.adds[element]) == 0 {
+			delete(s.adds, element)
+		}
+		delete(s.dels, element)
+	}
+}
+
+// generateTag generates a unique tag for an operation. Hybrid logical clock
+// readings never repeat on a node, even if its wall clock stalls or steps back.
 func (s *ORSet) generateTag() string {
-	return fmt.Sprintf("%s-%d", s.nodeID, time.Now().UnixNano())
+	return s.clock.Now().String()
 }
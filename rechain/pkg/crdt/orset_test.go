@@ -0,0 +1,77 @@
+package crdt_test
+
+import (
+	"testing"
+
+	"github.com/rechain/rechain/pkg/crdt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestORSetStats(t *testing.T) {
+	set := crdt.NewORSet("node1")
+	set.Add("a")
+	set.Add("b")
+	set.Add("c")
+
+	stats := set.Stats()
+	assert.Equal(t, 3, stats.EntryCount)
+	assert.Equal(t, 0, stats.TombstoneCount)
+	assert.Positive(t, stats.ApproxBytes)
+
+	set.Remove("a")
+	set.Remove("b")
+
+	removed := set.Stats()
+	assert.Equal(t, 1, removed.EntryCount)
+	assert.Equal(t, 2, removed.TombstoneCount)
+
+	set.Compact()
+
+	compacted := set.Stats()
+	assert.Equal(t, 1, compacted.EntryCount)
+	assert.Equal(t, 0, compacted.TombstoneCount)
+	assert.Less(t, compacted.ApproxBytes, removed.ApproxBytes)
+	assert.Equal(t, []interface{}{"c"}, set.Elements())
+
+	// A compacted replica still converges with one that saw the same removes
+	other := crdt.NewORSet("node2")
+	require.NoError(t, other.Merge(set))
+	other.Add("d")
+	require.NoError(t, set.Merge(other))
+	assert.ElementsMatch(t, []interface{}{"c", "d"}, set.Elements())
+}
+
+func TestORSetElementsAndSize(t *testing.T) {
+	set := crdt.NewORSet("node1")
+	assert.Empty(t, set.Elements())
+	assert.Equal(t, 0, set.Size())
+
+	set.Add("c")
+	set.Add("a")
+	set.Add("b")
+	set.Add("a") // a second add tag for the same element
+	assert.Equal(t, []interface{}{"a", "b", "c"}, set.Elements())
+	assert.Equal(t, 3, set.Size())
+
+	set.Remove("a")
+	set.Remove("c")
+	set.Remove("missing")
+	assert.Equal(t, []interface{}{"b"}, set.Elements())
+	assert.Equal(t, 1, set.Size())
+
+	// A re-add after a remove brings the element back with a fresh tag
+	set.Add("a")
+	set.Add("d")
+	assert.Equal(t, []interface{}{"a", "b", "d"}, set.Elements())
+	assert.Equal(t, 3, set.Size())
+
+	// Merging a replica that shares elements lists each of them once
+	other := crdt.NewORSet("node2")
+	other.Add("b")
+	other.Add("e")
+	require.NoError(t, set.Merge(other))
+	elements := set.Elements()
+	assert.Equal(t, []interface{}{"a", "b", "d", "e"}, elements)
+	assert.Equal(t, len(elements), set.Size())
+}
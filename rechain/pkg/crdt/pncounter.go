@@ -134,3 +134,13 @@ func (c *PNCounter) Unmarshal(data []byte) error {
 
 	return nil
 }
+
+// Stats implements the CRDT interface. Each node's increment and decrement
+// totals count as one entry each.
+func (c *PNCounter) Stats() CRDTStats {
+	c.mu.RLock()
+	entries := len(c.P) + len(c.N)
+	c.mu.RUnlock()
+
+	return CRDTStats{EntryCount: entries, ApproxBytes: approxBytes(c)}
+}
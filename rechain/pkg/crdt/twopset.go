@@ -243,3 +243,20 @@ func (s *TwoPhaseSet) Unmarshal(data []byte) error {
 
 	return nil
 }
+
+// Stats implements the CRDT interface. Removed elements stay as tombstones
+// for good, since a two-phase set never lets them back in.
+func (s *TwoPhaseSet) Stats() CRDTStats {
+	var stats CRDTStats
+	s.added.Range(func(key, value interface{}) bool {
+		stats.EntryCount++
+		return true
+	})
+	s.removed.Range(func(key, value interface{}) bool {
+		stats.TombstoneCount++
+		return true
+	})
+
+	stats.ApproxBytes = approxBytes(s)
+	return stats
+}
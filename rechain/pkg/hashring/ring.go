@@ -0,0 +1,127 @@
+// Package hashring implements a consistent-hashing ring that maps keys, such
+// as chunk CIDs, to the nodes that store them.
+package hashring
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultVirtualNodes is the number of points each node gets on the ring.
+// More points spread keys more evenly at the cost of a larger ring.
+const DefaultVirtualNodes = 128
+
+// Ring is a consistent-hashing ring. Each node is placed on the ring at
+// several virtual points, and a key belongs to the nodes at the first points
+// clockwise from its hash. Adding or removing a node only moves the keys
+// next to that node's points, about 1/N of them.
+type Ring struct {
+	mu     sync.RWMutex
+	vnodes int
+	points []uint64          // sorted hashes of every virtual node
+	owners map[uint64]string // virtual node hash -> node
+	nodes  map[string]struct{}
+}
+
+// New creates an empty ring placing each node at vnodes points. A vnodes of
+// zero uses DefaultVirtualNodes.
+func New(vnodes int) *Ring {
+	if vnodes <= 0 {
+		vnodes = DefaultVirtualNodes
+	}
+	return &Ring{
+		vnodes: vnodes,
+		owners: make(map[uint64]string),
+		nodes:  make(map[string]struct{}),
+	}
+}
+
+// Add places node on the ring. Adding a node twice has no effect.
+func (r *Ring) Add(node string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.nodes[node]; exists {
+		return
+	}
+	r.nodes[node] = struct{}{}
+	for i := 0; i < r.vnodes; i++ {
+		point := hash(node + "#" + strconv.Itoa(i))
+		if _, taken := r.owners[point]; taken {
+			continue // a collision keeps the first owner
+		}
+		r.owners[point] = node
+		r.points = append(r.points, point)
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+}
+
+// Remove takes node off the ring
+func (r *Ring) Remove(node string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.nodes[node]; !exists {
+		return
+	}
+	delete(r.nodes, node)
+	points := r.points[:0]
+	for _, point := range r.points {
+		if r.owners[point] == node {
+			delete(r.owners, point)
+			continue
+		}
+		points = append(points, point)
+	}
+	r.points = points
+}
+
+// Nodes returns the nodes on the ring in sorted order
+func (r *Ring) Nodes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	nodes := make([]string, 0, len(r.nodes))
+	for node := range r.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// Lookup returns up to n distinct nodes for key, in preference order. The
+// first is the key's primary; the rest hold its replicas.
+func (r *Ring) Lookup(key string, n int) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if n > len(r.nodes) {
+		n = len(r.nodes)
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	h := hash(key)
+	start := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+
+	nodes := make([]string, 0, n)
+	seen := make(map[string]bool, n)
+	for i := 0; i < len(r.points) && len(nodes) < n; i++ {
+		node := r.owners[r.points[(start+i)%len(r.points)]]
+		if !seen[node] {
+			seen[node] = true
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// hash maps s to a point on the ring
+func hash(s string) uint64 {
+	sum := sha256.Sum256([]byte(s))
+	return binary.BigEndian.Uint64(sum[:8])
+}
@@ -0,0 +1,64 @@
+package hashring
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRingPlacement(t *testing.T) {
+	ring := New(0)
+	for i := 0; i < 4; i++ {
+		ring.Add(fmt.Sprintf("node-%d", i))
+	}
+
+	const keys = 10000
+	before := make(map[string]string, keys)
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("sha256:%d", i)
+		before[key] = ring.Lookup(key, 1)[0]
+	}
+
+	t.Run("Replicas", func(t *testing.T) {
+		nodes := ring.Lookup("sha256:replicated", 3)
+		require.Len(t, nodes, 3)
+		assert.NotEqual(t, nodes[0], nodes[1])
+		assert.NotEqual(t, nodes[1], nodes[2])
+		assert.NotEqual(t, nodes[0], nodes[2])
+		assert.Len(t, ring.Lookup("sha256:replicated", 10), 4, "no more replicas than nodes")
+	})
+
+	t.Run("AddMovesFewKeys", func(t *testing.T) {
+		ring.Add("node-4")
+		moved := 0
+		for key, node := range before {
+			after := ring.Lookup(key, 1)[0]
+			if after != node {
+				assert.Equal(t, "node-4", after, "keys only move to the new node")
+				moved++
+			}
+		}
+		// Ideally 1/5 of the keys move; allow for uneven spread
+		assert.Less(t, moved, keys*3/10, "moved %d of %d keys", moved, keys)
+		assert.Greater(t, moved, 0)
+	})
+
+	t.Run("RemoveMovesOnlyItsKeys", func(t *testing.T) {
+		ring.Remove("node-4")
+		for key, node := range before {
+			assert.Equal(t, node, ring.Lookup(key, 1)[0], key)
+		}
+		assert.Equal(t, []string{"node-0", "node-1", "node-2", "node-3"}, ring.Nodes())
+	})
+}
+
+func TestEmptyRing(t *testing.T) {
+	ring := New(0)
+	assert.Empty(t, ring.Lookup("sha256:anything", 2))
+
+	ring.Add("only")
+	ring.Add("only")
+	assert.Equal(t, []string{"only"}, ring.Lookup("sha256:anything", 2))
+}
@@ -0,0 +1,131 @@
+// Package logging provides leveled, structured logging on top of log/slog,
+// configured from the node's logging section.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/rechain/rechain/pkg/config"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// ParseLevel parses debug, info, warn or error; an empty level means info
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q", level)
+	}
+}
+
+// NewHandler returns a handler writing records at level or above to w, as
+// JSON or as text
+func NewHandler(w io.Writer, level, format string) (slog.Handler, error) {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case "", "json":
+		return slog.NewJSONHandler(w, opts), nil
+	case "text":
+		return slog.NewTextHandler(w, opts), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
+
+// Setup installs a handler built from cfg as the default logger. The stdlib
+// log package is routed through it too, so log.Fatal still logs and exits
+// non-zero.
+func Setup(cfg config.LoggingConfig) error {
+	w, err := output(cfg)
+	if err != nil {
+		return err
+	}
+	h, err := NewHandler(w, cfg.Level, cfg.Format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// output returns the writer selected by cfg.Output
+func output(cfg config.LoggingConfig) (io.Writer, error) {
+	switch cfg.Output {
+	case "", "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	case "file":
+		if cfg.FilePath == "" {
+			return nil, fmt.Errorf("logging.file_path is required when output is file")
+		}
+		return &lumberjack.Logger{
+			Filename:   cfg.FilePath,
+			MaxSize:    cfg.MaxSize,
+			MaxBackups: cfg.MaxBackups,
+			MaxAge:     cfg.MaxAge,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown log output %q", cfg.Output)
+	}
+}
+
+// Component returns a logger that tags every record with component. It
+// resolves the default logger when each record is written, so package-level
+// component loggers follow a later Setup.
+func Component(component string) *slog.Logger {
+	return slog.New(deferredHandler{}).With("component", component)
+}
+
+// deferredHandler forwards to the default logger's handler at call time,
+// replaying the attributes and groups added to it
+type deferredHandler struct {
+	wrap []func(slog.Handler) slog.Handler
+}
+
+func (h deferredHandler) resolve() slog.Handler {
+	next := slog.Default().Handler()
+	for _, w := range h.wrap {
+		next = w(next)
+	}
+	return next
+}
+
+func (h deferredHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return slog.Default().Handler().Enabled(ctx, level)
+}
+
+func (h deferredHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.resolve().Handle(ctx, r)
+}
+
+func (h deferredHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h deferredHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+func (h deferredHandler) with(w func(slog.Handler) slog.Handler) slog.Handler {
+	wrap := make([]func(slog.Handler) slog.Handler, len(h.wrap), len(h.wrap)+1)
+	copy(wrap, h.wrap)
+	return deferredHandler{wrap: append(wrap, w)}
+}
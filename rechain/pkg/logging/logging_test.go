@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useDefault installs a handler writing to a buffer as the default logger
+// for the duration of the test
+func useDefault(t *testing.T, level, format string) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	h, err := NewHandler(&buf, level, format)
+	require.NoError(t, err)
+
+	prev := slog.Default()
+	slog.SetDefault(slog.New(h))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func TestDebugSuppressedAtInfoLevel(t *testing.T) {
+	logger := Component("test")
+
+	buf := useDefault(t, "info", "json")
+	logger.Debug("hidden detail")
+	logger.Info("visible")
+	assert.NotContains(t, buf.String(), "hidden detail")
+	assert.Contains(t, buf.String(), "visible")
+
+	buf = useDefault(t, "debug", "json")
+	logger.Debug("hidden detail", "height", 7)
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "DEBUG", record["level"])
+	assert.Equal(t, "hidden detail", record["msg"])
+	assert.Equal(t, "test", record["component"])
+	assert.Equal(t, float64(7), record["height"])
+}
+
+func TestTextFormat(t *testing.T) {
+	buf := useDefault(t, "warn", "text")
+
+	logger := Component("gossip").With("peer", "p1")
+	logger.Info("ignored")
+	logger.Warn("dropping message")
+
+	out := strings.TrimSpace(buf.String())
+	assert.NotContains(t, out, "ignored")
+	assert.Contains(t, out, "level=WARN")
+	assert.Contains(t, out, "component=gossip")
+	assert.Contains(t, out, "peer=p1")
+}
+
+func TestInvalidSettingsRejected(t *testing.T) {
+	_, err := NewHandler(&bytes.Buffer{}, "verbose", "json")
+	assert.Error(t, err)
+
+	_, err = NewHandler(&bytes.Buffer{}, "info", "xml")
+	assert.Error(t, err)
+}
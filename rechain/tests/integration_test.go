@@ -3,6 +3,7 @@ package tests
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -26,8 +27,8 @@ func TestFullSystemIntegration(t *testing.T) {
 	cfg := config.DefaultConfig()
 	cfg.Node.DataDir = t.TempDir()
 	cfg.Storage.Path = cfg.Node.DataDir + "/storage"
-	cfg.API.REST.Address = "localhost:0" // Use random port
-	cfg.API.GRPC.Address = "localhost:0" // Use random port
+	cfg.API.RESTAddress = "localhost:0" // Use random port
+	cfg.API.GRPCAddress = "localhost:0" // Use random port
 
 	// Initialize components
 	store, err := storage.NewBadgerStore(cfg.Storage.Path)
@@ -130,10 +131,24 @@ func TestFullSystemIntegration(t *testing.T) {
 	})
 
 	t.Run("Submit and Query Transaction", func(t *testing.T) {
-		// Submit transaction
+		// Submit a transaction signed by a client key
+		clientKey, err := security.NewKeyManagerWithAlgorithm(security.AlgorithmEd25519)
+		require.NoError(t, err)
+		payload := map[string]interface{}{"message": "integration test"}
+		txID := fmt.Sprintf("integration-%d", time.Now().UnixNano())
+		signingBytes, err := security.SubmittedTxSigningBytes(txID, "test", payload, 0)
+		require.NoError(t, err)
+		signature, err := clientKey.SignData(signingBytes)
+		require.NoError(t, err)
+		publicKey, err := clientKey.PublicKeyBytes()
+		require.NoError(t, err)
+
 		txReq := map[string]interface{}{
-			"type":    "test",
-			"payload": map[string]string{"message": "integration test"},
+			"id":         txID,
+			"type":       "test",
+			"payload":    payload,
+			"public_key": base64.StdEncoding.EncodeToString(publicKey),
+			"signature":  base64.StdEncoding.EncodeToString(signature),
 		}
 		txJSON, _ := json.Marshal(txReq)
 